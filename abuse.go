@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ABUSE: 公开识别接口的滥用防护
+// ==========================================
+
+// 不带 API Key 的识别请求（公开小程序、网页）在调用 AI 之前先过两道检查：
+//
+//  1. 异常检测：同一 IP 一分钟内上传的不同图片超过 ABUSE_IMAGES_PER_MINUTE（默认 120）张，
+//     该 IP 被标记为可疑，持续 ABUSE_FLAG_TTL（默认 10m）。重复上传同一张图片命中识别缓存，不计数。
+//  2. 人机验证：配置了 TURNSTILE_SECRET（Cloudflare Turnstile）时，可疑 IP 的请求需携带验证令牌
+//     （请求头 CF-Turnstile-Response 或表单字段 cf-turnstile-response），验证通过后解除标记；
+//     CAPTCHA_MODE=always 时所有公开请求都要验证。
+//
+// 需要验证时返回 403 {"code": "CHALLENGE_REQUIRED", "site_key": TURNSTILE_SITE_KEY}，客户端弹出验证后重试；
+// 未配置 Turnstile 时可疑 IP 直接返回 429 {"code": "ABUSE_DETECTED"}。带 API Key 的请求不受影响
+
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+type abuseWindow struct {
+	start       time.Time
+	images      map[string]bool
+	flaggedTill time.Time
+}
+
+type abuseDetector struct {
+	mu      sync.Mutex
+	clients map[string]*abuseWindow
+}
+
+var abuse = &abuseDetector{clients: map[string]*abuseWindow{}}
+
+func abuseImagesPerMinute() int   { return envInt("ABUSE_IMAGES_PER_MINUTE", 120) }
+func abuseFlagTTL() time.Duration { return envDuration("ABUSE_FLAG_TTL", 10*time.Minute) }
+func captchaAlways() bool         { return strings.EqualFold(os.Getenv("CAPTCHA_MODE"), "always") }
+func turnstileConfigured() bool   { return os.Getenv("TURNSTILE_SECRET") != "" }
+func turnstileSiteKey() string    { return os.Getenv("TURNSTILE_SITE_KEY") }
+func abuseLimitEnabled() bool     { return abuseImagesPerMinute() > 0 }
+
+// Observe 记录 IP 本次上传的图片，返回该 IP 当前是否可疑；多副本部署时计数在 Redis 中共享
+func (d *abuseDetector) Observe(ip string, hashes []string) bool {
+	if sharedRedis != nil {
+		flagged, err := observeShared(ip, hashes, time.Now())
+		if !logSharedErr("异常上传检测", err) {
+			return flagged
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	w, ok := d.clients[ip]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		flagged := time.Time{}
+		if ok {
+			flagged = w.flaggedTill
+		}
+		w = &abuseWindow{start: now, images: map[string]bool{}, flaggedTill: flagged}
+		d.clients[ip] = w
+	}
+	for _, h := range hashes {
+		w.images[h] = true
+	}
+	if limit := abuseImagesPerMinute(); limit > 0 && len(w.images) > limit && !now.Before(w.flaggedTill) {
+		log.Printf("IP %s 一分钟内上传 %d 张不同图片，标记为可疑", ip, len(w.images))
+		w.flaggedTill = now.Add(abuseFlagTTL())
+	}
+	d.prune(now)
+	return now.Before(w.flaggedTill)
+}
+
+// observeShared Observe 的 Redis 实现：按自然分钟分桶计数，可疑标记带过期时间
+func observeShared(ip string, hashes []string, now time.Time) (bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	bucket := sharedKey("abuse", "images", ip, fmt.Sprint(now.Unix()/60))
+	flagKey := sharedKey("abuse", "flag", ip)
+	pipe := sharedRedis.TxPipeline()
+	for _, h := range hashes {
+		pipe.SAdd(ctx, bucket, h)
+	}
+	pipe.Expire(ctx, bucket, 2*time.Minute)
+	count := pipe.SCard(ctx, bucket)
+	flagged := pipe.Exists(ctx, flagKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	if flagged.Val() == 1 {
+		return true, nil
+	}
+	if limit := abuseImagesPerMinute(); limit > 0 && count.Val() > int64(limit) {
+		log.Printf("IP %s 一分钟内上传 %d 张不同图片，标记为可疑", ip, count.Val())
+		return true, sharedRedis.Set(ctx, flagKey, 1, abuseFlagTTL()).Err()
+	}
+	return false, nil
+}
+
+// Clear 人机验证通过后解除标记并重新计数
+func (d *abuseDetector) Clear(ip string) {
+	if sharedRedis != nil {
+		ctx, cancel := redisCtx()
+		defer cancel()
+		bucket := sharedKey("abuse", "images", ip, fmt.Sprint(time.Now().Unix()/60))
+		logSharedErr("解除异常标记", sharedRedis.Del(ctx, sharedKey("abuse", "flag", ip), bucket).Err())
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.clients, ip)
+}
+
+// prune 清理已过期且未被标记的窗口，避免 IP 表无限增长；调用方需持有锁
+func (d *abuseDetector) prune(now time.Time) {
+	if len(d.clients) < 10000 {
+		return
+	}
+	for ip, w := range d.clients {
+		if now.Sub(w.start) >= time.Minute && !now.Before(w.flaggedTill) {
+			delete(d.clients, ip)
+		}
+	}
+}
+
+// requestImageKeys 请求中的图片标识：上传的文件按内容摘要，断点续传按 upload_id
+func requestImageKeys(c *gin.Context) []string {
+	if id := c.PostForm("upload_id"); id != "" {
+		return []string{"upload:" + id}
+	}
+	if id := c.Query("upload_id"); id != "" {
+		return []string{"upload:" + id}
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, field := range []string{"image", "images"} {
+		for _, fh := range form.File[field] {
+			f, err := fh.Open()
+			if err != nil {
+				continue
+			}
+			raw, _ := io.ReadAll(f)
+			f.Close()
+			keys = append(keys, imageHash(raw))
+		}
+	}
+	return keys
+}
+
+// verifyTurnstile 向 Cloudflare 校验人机验证令牌
+func verifyTurnstile(ctx context.Context, token, ip string) (bool, error) {
+	form := url.Values{"secret": {os.Getenv("TURNSTILE_SECRET")}, "response": {token}, "remoteip": {ip}}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, turnstileVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, err
+	}
+	return out.Success, nil
+}
+
+func challengeToken(c *gin.Context) string {
+	if t := strings.TrimSpace(c.GetHeader("CF-Turnstile-Response")); t != "" {
+		return t
+	}
+	return strings.TrimSpace(c.PostForm("cf-turnstile-response"))
+}
+
+// abuseGuard 公开识别接口的滥用防护，放在识别类路由最前面
+func abuseGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := apiKeyOf(c); ok || (!abuseLimitEnabled() && !captchaAlways()) {
+			c.Next()
+			return
+		}
+		ip := c.ClientIP()
+		suspicious := abuse.Observe(ip, requestImageKeys(c))
+		if !suspicious && !captchaAlways() {
+			c.Next()
+			return
+		}
+		if !turnstileConfigured() {
+			if suspicious {
+				c.AbortWithStatusJSON(429, gin.H{"error": "请求过于频繁，请稍后再试", "code": "ABUSE_DETECTED"})
+				return
+			}
+			c.Next()
+			return
+		}
+		if token := challengeToken(c); token != "" {
+			ok, err := verifyTurnstile(c.Request.Context(), token, ip)
+			if err != nil {
+				log.Printf("人机验证服务调用失败: %v", err)
+				c.AbortWithStatusJSON(503, gin.H{"error": "人机验证服务暂时不可用，请稍后重试"})
+				return
+			}
+			if ok {
+				if suspicious {
+					abuse.Clear(ip)
+				}
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(403, gin.H{"error": "请先完成人机验证", "code": "CHALLENGE_REQUIRED", "site_key": turnstileSiteKey()})
+	}
+}
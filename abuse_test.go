@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roundTripFunc 替换 http.DefaultClient 的传输层，拦截发往外部服务的请求
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// useTestTurnstile 模拟 Turnstile 校验：令牌 good 通过，down 模拟服务不可用，其余不通过
+func useTestTurnstile(t *testing.T) {
+	t.Helper()
+	old := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = old })
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.String() != turnstileVerifyURL {
+			return nil, errors.New("unexpected request to " + r.URL.String())
+		}
+		raw, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(raw))
+		if form.Get("secret") != "ts-secret" || form.Get("remoteip") == "" {
+			return nil, errors.New("bad siteverify form: " + string(raw))
+		}
+		if form.Get("response") == "down" {
+			return nil, errors.New("connection refused")
+		}
+		body, _ := json.Marshal(map[string]bool{"success": form.Get("response") == "good"})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(body)), Header: http.Header{}}, nil
+	})
+}
+
+// useTestAbuse 换成空的异常检测计数
+func useTestAbuse(t *testing.T) {
+	t.Helper()
+	old := abuse
+	t.Cleanup(func() { abuse = old })
+	abuse = &abuseDetector{clients: map[string]*abuseWindow{}}
+}
+
+func TestAbuseDetectorObserve(t *testing.T) {
+	useTestAbuse(t)
+	t.Setenv("ABUSE_IMAGES_PER_MINUTE", "2")
+	t.Setenv("ABUSE_FLAG_TTL", "")
+	const ip = "192.0.2.1"
+	expire := func() { abuse.clients[ip].start = time.Now().Add(-time.Minute) }
+	unflag := func() { expire(); abuse.clients[ip].flaggedTill = time.Now().Add(-time.Second) }
+
+	steps := []struct {
+		name   string
+		before func()
+		ip     string
+		hashes []string
+		want   bool
+	}{
+		{"第一张", nil, ip, []string{"a"}, false},
+		{"重复上传不计数", nil, ip, []string{"a", "a"}, false},
+		{"达到上限", nil, ip, []string{"b"}, false},
+		{"超过上限标记可疑", nil, ip, []string{"c"}, true},
+		{"其他 IP 不受影响", nil, "192.0.2.2", []string{"a", "b"}, false},
+		{"下一分钟仍在标记期内", expire, ip, []string{"d"}, true},
+		{"标记过期后恢复", unflag, ip, []string{"e"}, false},
+	}
+	for _, st := range steps {
+		if st.before != nil {
+			st.before()
+		}
+		if got := abuse.Observe(st.ip, st.hashes); got != st.want {
+			t.Errorf("%s: Observe() = %v, want %v", st.name, got, st.want)
+		}
+	}
+	if d := time.Until(abuse.clients[ip].flaggedTill); d > 0 {
+		t.Errorf("flaggedTill still %v ahead", d)
+	}
+
+	abuse.Clear(ip)
+	if _, ok := abuse.clients[ip]; ok {
+		t.Error("Clear() kept the window")
+	}
+}
+
+func TestRequestImageKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name  string
+		build func() *http.Request
+		want  []string
+	}{
+		{"断点续传按 upload_id", func() *http.Request {
+			return httptest.NewRequest("POST", "/scan?upload_id=u1", nil)
+		}, []string{"upload:u1"}},
+		{"上传的文件按内容摘要", func() *http.Request {
+			return scanUploadRequest(t, "", "", []byte("img-1"), []byte("img-2"))
+		}, []string{imageHash([]byte("img-1")), imageHash([]byte("img-2"))}},
+		{"没有图片", func() *http.Request {
+			return httptest.NewRequest("POST", "/scan", nil)
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = tt.build()
+			if got := requestImageKeys(c); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("requestImageKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// scanUploadRequest 一次识别请求：第一张图放 image 字段，其余放 images；token 放在表单字段
+func scanUploadRequest(t *testing.T, apiKey, formToken string, imgs ...[]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, img := range imgs {
+		field := "images"
+		if i == 0 {
+			field = "image"
+		}
+		fw, _ := mw.CreateFormFile(field, "ticket.jpg")
+		fw.Write(img)
+	}
+	if formToken != "" {
+		mw.WriteField("cf-turnstile-response", formToken)
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/scan", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	return req
+}
+
+func TestAbuseGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	img := func(n int) []byte { return []byte{byte(n)} }
+	type step struct {
+		name       string
+		apiKey     string
+		header     string // CF-Turnstile-Response
+		form       string // cf-turnstile-response
+		img        int
+		wantStatus int
+		wantCode   string
+	}
+	tests := []struct {
+		name      string
+		turnstile bool
+		captcha   string
+		limit     string
+		steps     []step
+	}{
+		{"未配置人机验证时可疑 IP 返回 429", false, "", "2", []step{
+			{"第一张", "", "", "", 1, 200, ""},
+			{"第二张", "", "", "", 2, 200, ""},
+			{"重复上传同一张图不计数", "", "", "", 1, 200, ""},
+			{"超出上限", "", "", "", 3, 429, "ABUSE_DETECTED"},
+			{"带 API Key 不受影响", "sk-a", "", "", 4, 200, ""},
+			{"仍在标记期内", "", "", "", 1, 429, "ABUSE_DETECTED"},
+		}},
+		{"可疑 IP 完成验证后解除标记", true, "", "2", []step{
+			{"第一张", "", "", "", 1, 200, ""},
+			{"第二张", "", "", "", 2, 200, ""},
+			{"超出上限要求验证", "", "", "", 3, 403, "CHALLENGE_REQUIRED"},
+			{"验证不通过", "", "bad", "", 3, 403, "CHALLENGE_REQUIRED"},
+			{"验证服务不可用", "", "down", "", 3, 503, ""},
+			{"验证通过", "", "good", "", 3, 200, ""},
+			{"解除标记后不再要求验证", "", "", "", 4, 200, ""},
+		}},
+		{"always 模式所有公开请求都要验证", true, "always", "", []step{
+			{"没有令牌", "", "", "", 1, 403, "CHALLENGE_REQUIRED"},
+			{"请求头令牌", "", "good", "", 1, 200, ""},
+			{"表单字段令牌", "", "", "good", 1, 200, ""},
+			{"带 API Key 不需要验证", "sk-a", "", "", 1, 200, ""},
+		}},
+		{"always 模式未配置 Turnstile 时放行", false, "always", "", []step{
+			{"没有令牌", "", "", "", 1, 200, ""},
+		}},
+		{"关闭异常检测", false, "", "0", []step{
+			{"第一张", "", "", "", 1, 200, ""},
+			{"第二张", "", "", "", 2, 200, ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAbuse(t)
+			useTestTurnstile(t)
+			useTestAPIKeys(t, APIKey{Key: "sk-a", Tenant: "shop-a"})
+			t.Setenv("ABUSE_IMAGES_PER_MINUTE", tt.limit)
+			t.Setenv("CAPTCHA_MODE", tt.captcha)
+			t.Setenv("TURNSTILE_SITE_KEY", "ts-site")
+			t.Setenv("TURNSTILE_SECRET", "")
+			if tt.turnstile {
+				t.Setenv("TURNSTILE_SECRET", "ts-secret")
+			}
+			r := gin.New()
+			r.Use(apiKeyAuth())
+			r.POST("/scan", abuseGuard(), func(c *gin.Context) { c.JSON(200, gin.H{}) })
+
+			for _, st := range tt.steps {
+				req := scanUploadRequest(t, st.apiKey, st.form, img(st.img))
+				if st.header != "" {
+					req.Header.Set("CF-Turnstile-Response", st.header)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != st.wantStatus {
+					t.Fatalf("%s: status = %d, want %d: %s", st.name, w.Code, st.wantStatus, w.Body)
+				}
+				var resp map[string]string
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp["code"] != st.wantCode {
+					t.Errorf("%s: code = %q, want %q", st.name, resp["code"], st.wantCode)
+				}
+				if st.wantCode == "CHALLENGE_REQUIRED" && resp["site_key"] != "ts-site" {
+					t.Errorf("%s: site_key = %q", st.name, resp["site_key"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ADMIN: 管理接口鉴权与角色
+// ==========================================
+
+// 管理员凭据分三种角色，高级角色包含低级角色的全部权限：
+//
+//	viewer    查看用量、账单、站点报表等看板数据
+//	operator  另外可以处理人工复核，查看调试记录（含原图和模型原始输出）并重放识别
+//	admin     另外可以重新加载奖金规则、派奖活动、兑奖规则、API Key 等配置
+//
+// ADMIN_TOKEN 始终是 admin 角色；门店员工等其他凭据配置在 data/admin_tokens.json：
+//
+//	[{"token": "...", "name": "前台小王", "role": "viewer"}]
+//
+// 请求通过 X-Admin-Token 或 Authorization: Bearer 携带令牌。两者都未配置时管理接口整体关闭
+
+const (
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+	roleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{roleViewer: 1, roleOperator: 2, roleAdmin: 3}
+
+// AdminCredential 一个管理员令牌
+type AdminCredential struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+type adminStore struct {
+	mu    sync.RWMutex
+	creds []AdminCredential
+}
+
+var admins = &adminStore{}
+
+// Load 读取管理员凭据，文件不存在时只有 ADMIN_TOKEN 可用；重新加载时删掉文件即撤销其中全部令牌
+func (s *adminStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.mu.Lock()
+		s.creds = nil
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []AdminCredential
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for i, cred := range list {
+		list[i].Token = strings.TrimSpace(cred.Token)
+		if list[i].Token == "" {
+			return fmt.Errorf("第%d个管理员令牌为空", i+1)
+		}
+		if roleRank[cred.Role] == 0 {
+			return fmt.Errorf("管理员 %s 的角色 %q 无效，应为 viewer、operator 或 admin", cred.Name, cred.Role)
+		}
+	}
+	s.mu.Lock()
+	s.creds = list
+	s.mu.Unlock()
+	return nil
+}
+
+// Lookup 按令牌查找凭据；逐个做常量时间比较，不因提前命中泄露信息
+func (s *adminStore) Lookup(token string) (AdminCredential, bool) {
+	var found AdminCredential
+	ok := false
+	if want := os.Getenv("ADMIN_TOKEN"); want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+		found, ok = AdminCredential{Name: "ADMIN_TOKEN", Role: roleAdmin}, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, cred := range s.creds {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cred.Token)) == 1 && !ok {
+			found, ok = cred, true
+		}
+	}
+	return found, ok
+}
+
+func (s *adminStore) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return os.Getenv("ADMIN_TOKEN") != "" || len(s.creds) > 0
+}
+
+// adminOnly 需要 admin 角色
+func adminOnly() gin.HandlerFunc { return requireRole(roleAdmin) }
+
+// requireRole 管理接口鉴权：令牌有效且角色不低于 role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ipAccess.AdminAllowed(c) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "来源地址不在管理接口白名单内"})
+			return
+		}
+		if !admins.Enabled() {
+			c.AbortWithStatusJSON(403, gin.H{"error": "未配置 ADMIN_TOKEN，管理接口未开放"})
+			return
+		}
+		got := c.GetHeader("X-Admin-Token")
+		if got == "" {
+			got = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		cred, ok := admins.Lookup(got)
+		if got == "" || !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "管理员令牌无效"})
+			return
+		}
+		if roleRank[cred.Role] < roleRank[role] {
+			c.AbortWithStatusJSON(403, gin.H{"error": "当前角色无权执行该操作", "role": cred.Role, "required": role})
+			return
+		}
+		c.Set("admin", cred)
+		c.Next()
+	}
+}
+
+// registerAdminRoutes 管理接口及所需角色；处理复核与查看原图同公开的 /api/v1/reviews 一样需要 operator
+func registerAdminRoutes(admin *gin.RouterGroup) {
+	viewer, operator := requireRole(roleViewer), requireRole(roleOperator)
+	admin.GET("/whoami", viewer, adminWhoamiHandler)
+	admin.GET("/usage", viewer, usageHandler)
+	admin.GET("/billing", viewer, billingHandler)
+	admin.GET("/reviews", operator, reviewListHandler)
+	admin.GET("/reviews/:id", operator, reviewGetHandler)
+	admin.GET("/reviews/:id/image", operator, reviewImageHandler)
+	admin.POST("/reviews/:id/resolve", operator, reviewResolveHandler)
+	admin.GET("/jobs", viewer, adminJobsHandler)
+	admin.GET("/accuracy", viewer, adminAccuracyHandler)
+	admin.GET("/trends", viewer, trendsHandler)
+	admin.GET("/dataset", adminOnly(), adminDatasetHandler)
+	admin.GET("/scans/similar", viewer, similarHandler)
+	admin.POST("/scans/similar", viewer, similarHandler)
+	admin.GET("/debug", operator, debugListHandler)
+	admin.GET("/debug/:id", operator, debugGetHandler)
+	admin.GET("/debug/:id/image", operator, debugImageHandler)
+	admin.POST("/debug/:id/replay", operator, debugReplayHandler)
+	admin.GET("/sessions", operator, adminSessionsHandler)
+	admin.DELETE("/sessions/:id", operator, adminRevokeSessionHandler)
+	admin.POST("/reload", adminOnly(), adminReloadHandler)
+}
+
+// adminOf 通过鉴权的管理员
+func adminOf(c *gin.Context) (AdminCredential, bool) {
+	v, ok := c.Get("admin")
+	if !ok {
+		return AdminCredential{}, false
+	}
+	cred, ok := v.(AdminCredential)
+	return cred, ok
+}
+
+// adminWhoamiHandler GET /api/v1/admin/whoami 当前令牌的名称与角色，管理后台据此隐藏无权限的入口
+func adminWhoamiHandler(c *gin.Context) {
+	cred, _ := adminOf(c)
+	c.JSON(200, gin.H{"name": cred.Name, "role": cred.Role})
+}
+
+// reloadConfig 重新读取奖金规则、派奖活动、兑奖规则、API Key、管理员、IP 访问控制与路由限制配置，
+// 任一文件有误时返回错误，已成功加载的部分保持生效。验奖缓存里的奖金按旧规则算出，一律作废
+func reloadConfig() error {
+	defer verifyCache.Purge()
+	steps := []struct {
+		name string
+		load func(string) error
+		file string
+	}{
+		{"奖金规则", prizeRules.Load, "prize_rules.json"},
+		{"派奖活动", promotions.Load, "promotions.json"},
+		{"兑奖规则", claimRules.Load, "claim_rules.json"},
+		{"API Key", apiKeys.Load, "api_keys.json"},
+		{"管理员令牌", admins.Load, "admin_tokens.json"},
+		{"IP 访问控制", ipAccess.Load, "ip_access.json"},
+		{"路由限制", routeLimits.Load, "route_limits.json"},
+	}
+	for _, s := range steps {
+		if err := s.load(filepath.Join(dataDir(), s.file)); err != nil {
+			return fmt.Errorf("加载%s失败: %v", s.name, err)
+		}
+	}
+	return nil
+}
+
+// reloadOnSIGHUP 收到 SIGHUP 时重新加载配置，效果同 POST /api/v1/admin/reload
+func reloadOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if err := reloadConfig(); err != nil {
+			log.Printf("重新加载配置失败: %v", err)
+			continue
+		}
+		log.Printf("已重新加载配置")
+	}
+}
+
+// adminReloadHandler POST /api/v1/admin/reload 重新加载配置，见 reloadConfig
+func adminReloadHandler(c *gin.Context) {
+	if err := reloadConfig(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"reloaded": true})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "root")
+	prev := admins.creds
+	t.Cleanup(func() { admins.creds = prev })
+	admins.creds = []AdminCredential{
+		{Token: "v", Name: "前台", Role: roleViewer},
+		{Token: "o", Name: "复核员", Role: roleOperator},
+	}
+
+	tests := []struct {
+		name  string
+		role  string
+		token string
+		want  int
+	}{
+		{"未带令牌", roleOperator, "", 401},
+		{"令牌无效", roleOperator, "nope", 401},
+		{"viewer 不能处理复核", roleOperator, "v", 403},
+		{"operator 可以处理复核", roleOperator, "o", 200},
+		{"ADMIN_TOKEN 是 admin", roleOperator, "root", 200},
+		{"viewer 可以看看板", roleViewer, "v", 200},
+		{"operator 不能重新加载配置", roleAdmin, "o", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/x", requireRole(tt.role), func(c *gin.Context) { c.Status(200) })
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/x", nil)
+			if tt.token != "" {
+				req.Header.Set("X-Admin-Token", tt.token)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+// 复核列表、详情和原图与公开的 /api/v1/reviews 一样只对 operator 开放
+func TestAdminRoutesRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestReviews(t)
+	t.Setenv("ADMIN_TOKEN", "")
+	prev := admins.creds
+	t.Cleanup(func() { admins.creds = prev })
+	admins.creds = []AdminCredential{
+		{Token: "v", Name: "前台", Role: roleViewer},
+		{Token: "o", Name: "复核员", Role: roleOperator},
+	}
+	r := gin.New()
+	registerAdminRoutes(r.Group("/api/v1/admin"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"viewer 看不到复核列表", "GET", "/api/v1/admin/reviews", "v", 403},
+		{"viewer 看不到复核详情", "GET", "/api/v1/admin/reviews/r1", "v", 403},
+		{"viewer 取不到原图", "GET", "/api/v1/admin/reviews/r1/image", "v", 403},
+		{"viewer 不能处理复核", "POST", "/api/v1/admin/reviews/r1/resolve", "v", 403},
+		{"operator 可以看复核列表", "GET", "/api/v1/admin/reviews", "o", 200},
+		{"viewer 可以看自己的角色", "GET", "/api/v1/admin/whoami", "v", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Admin-Token", tt.token)
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestReloadConfigPurgesVerifyCache(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	tests := []struct {
+		name   string
+		broken bool
+	}{
+		{"全部加载成功", false},
+		{"部分配置有误", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.broken {
+				writeTestFile(t, dataDir(), "admin_tokens.json", []byte("{"))
+			}
+			verifyCache.Set("reload-test", VerificationResult{TotalPrize: 500})
+			if err := reloadConfig(); (err != nil) != tt.broken {
+				t.Fatalf("reloadConfig() error = %v, broken = %v", err, tt.broken)
+			}
+			if _, ok := verifyCache.Get("reload-test"); ok {
+				t.Error("verify cache entry survived reload")
+			}
+		})
+	}
+}
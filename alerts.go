@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// ALERTS: 运维告警（与面向用户的中奖通知分开）
+// ==========================================
+
+const (
+	AlertErrorRate   = "ops.error_rate"
+	AlertDrawStale   = "ops.draw_stale"
+	AlertTokenBudget = "ops.token_budget"
+)
+
+// opsAlerter 定时巡检指标，超阈值时推送到运维渠道；同类告警在冷却期内只发一次
+type opsAlerter struct {
+	mu       sync.Mutex
+	channels []Notifier
+	cooldown time.Duration
+	lastSent map[string]time.Time
+}
+
+var opsAlerts = &opsAlerter{lastSent: map[string]time.Time{}}
+
+// loadOpsAlerter 环境变量 DINGTALK_WEBHOOK / DINGTALK_SECRET
+func loadOpsAlerter() *opsAlerter {
+	a := &opsAlerter{
+		cooldown: envDuration("ALERT_COOLDOWN", 30*time.Minute),
+		lastSent: map[string]time.Time{},
+	}
+	if hook := strings.TrimSpace(os.Getenv("DINGTALK_WEBHOOK")); hook != "" {
+		a.channels = append(a.channels, &dingTalkNotifier{webhook: hook, secret: os.Getenv("DINGTALK_SECRET")})
+	}
+	return a
+}
+
+// Fire 发送一条告警，冷却期内重复的同类告警直接丢弃
+func (a *opsAlerter) Fire(kind, title string, lines ...string) {
+	if len(a.channels) == 0 {
+		return
+	}
+	a.mu.Lock()
+	if last, ok := a.lastSent[kind]; ok && time.Since(last) < a.cooldown {
+		a.mu.Unlock()
+		return
+	}
+	a.lastSent[kind] = time.Now()
+	a.mu.Unlock()
+
+	ev := NotifyEvent{Kind: kind, Title: title, Lines: lines, Time: time.Now()}
+	for _, ch := range a.channels {
+		go func(ch Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := ch.Notify(ctx, ev); err != nil {
+				log.Printf("运维告警发送失败 [%s/%s]: %v", ch.Name(), kind, err)
+			}
+		}(ch)
+	}
+}
+
+// Run 每分钟巡检一次
+func (a *opsAlerter) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.checkErrorRate()
+		a.checkDrawFeed()
+		a.checkTokenBudget()
+	}
+}
+
+// checkErrorRate 最近窗口内 5xx 比例超过 ALERT_ERROR_RATE（默认 0.2），且请求量不少于 ALERT_MIN_REQUESTS
+func (a *opsAlerter) checkErrorRate() {
+	window := envDuration("ALERT_ERROR_WINDOW", 5*time.Minute)
+	threshold := float64(envInt("ALERT_ERROR_RATE_PERCENT", 20)) / 100
+	total, errs := requestStats.Snapshot(window)
+	if total < envInt("ALERT_MIN_REQUESTS", 20) {
+		return
+	}
+	rate := float64(errs) / float64(total)
+	if rate >= threshold {
+		a.Fire(AlertErrorRate, "接口错误率升高",
+			fmt.Sprintf("最近 %s: %d/%d 请求失败 (%.1f%%)", window, errs, total, rate*100),
+			fmt.Sprintf("熔断器状态: %s", ocrBreaker.State()))
+	}
+}
+
+// checkDrawFeed 配置了开奖源但超过 ALERT_DRAW_STALE（默认 3h）没有同步成功
+func (a *opsAlerter) checkDrawFeed() {
+	if os.Getenv("DRAW_FEED_URL") == "" {
+		return
+	}
+	stale := envDuration("ALERT_DRAW_STALE", 3*time.Hour)
+	last := draws.LastSync()
+	if time.Since(last) < stale || time.Since(serverStartedAt) < stale {
+		return
+	}
+	lastText := "从未成功"
+	if !last.IsZero() {
+		lastText = last.Format("2006-01-02 15:04:05")
+	}
+	a.Fire(AlertDrawStale, "开奖数据源长时间未更新", "上次成功同步: "+lastText, fmt.Sprintf("阈值: %s", stale))
+}
+
+// checkTokenBudget 今日 Token 用量超过 OCR_DAILY_TOKEN_BUDGET，或估算费用超过 OCR_DAILY_SPEND_BUDGET
+func (a *opsAlerter) checkTokenBudget() {
+	if budget := int64(envInt("OCR_DAILY_TOKEN_BUDGET", 0)); budget > 0 {
+		if used := tokenUsage.Today(); used >= budget {
+			a.Fire(AlertTokenBudget, "今日 Token 用量超出预算", fmt.Sprintf("已用: %d / 预算: %d", used, budget), budgetActionText())
+		}
+	}
+	if budget := dailySpendBudget(); budget > 0 {
+		if spent := tokenUsage.SpendToday(); spent >= budget {
+			a.Fire(AlertTokenBudget, "今日识别费用超出预算", fmt.Sprintf("已用: %.2f元 / 预算: %.2f元", spent, budget), budgetActionText())
+		}
+	}
+}
+
+func budgetActionText() string {
+	switch budgetAction() {
+	case budgetReject:
+		return "处理方式: 拒绝新的识别请求"
+	case budgetDowngrade:
+		return "处理方式: 已降级为 " + ocrModel()
+	}
+	return "处理方式: 仅告警（OCR_BUDGET_ACTION 未设置）"
+}
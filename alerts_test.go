@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingNotifier 把收到的事件交给 channel，测试里等待异步发送
+type recordingNotifier struct {
+	events chan NotifyEvent
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	n.events <- ev
+	return nil
+}
+
+func TestOpsAlerterCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		kinds    []string
+		want     int
+	}{
+		{"冷却期内同类告警只发一次", time.Hour, []string{AlertErrorRate, AlertErrorRate}, 1},
+		{"不同类告警各发一次", time.Hour, []string{AlertErrorRate, AlertDrawStale}, 2},
+		{"没有冷却期", 0, []string{AlertErrorRate, AlertErrorRate}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingNotifier{events: make(chan NotifyEvent, len(tt.kinds))}
+			a := &opsAlerter{channels: []Notifier{rec}, cooldown: tt.cooldown, lastSent: map[string]time.Time{}}
+			for _, kind := range tt.kinds {
+				a.Fire(kind, "告警")
+			}
+			got := 0
+			for got < tt.want {
+				select {
+				case <-rec.events:
+					got++
+				case <-time.After(time.Second):
+					t.Fatalf("received %d alerts, want %d", got, tt.want)
+				}
+			}
+			select {
+			case <-rec.events:
+				t.Errorf("received more than %d alerts", tt.want)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestDingTalkSignedURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook string
+		secret  string
+		prefix  string
+	}{
+		{"未配置加签原样返回", "https://oapi.dingtalk.com/robot/send?access_token=x", "", ""},
+		{"已有参数用 & 连接", "https://oapi.dingtalk.com/robot/send?access_token=x", "SEC1", "https://oapi.dingtalk.com/robot/send?access_token=x&timestamp="},
+		{"没有参数用 ? 连接", "https://example.com/hook", "SEC1", "https://example.com/hook?timestamp="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&dingTalkNotifier{webhook: tt.webhook, secret: tt.secret}).signedURL()
+			if tt.secret == "" {
+				if got != tt.webhook {
+					t.Errorf("signedURL() = %q, want %q", got, tt.webhook)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, tt.prefix) {
+				t.Fatalf("signedURL() = %q, want prefix %q", got, tt.prefix)
+			}
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := u.Query().Get("timestamp")
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write([]byte(ts + "\n" + tt.secret))
+			if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); u.Query().Get("sign") != want {
+				t.Errorf("sign = %q, want %q", u.Query().Get("sign"), want)
+			}
+		})
+	}
+}
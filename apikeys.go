@@ -0,0 +1,395 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// APIKEYS: 接入方 API Key 与扫描配额
+// ==========================================
+
+// 接入方的 Key 配置在 data/api_keys.json：
+//
+//	[{"key": "sk-...", "name": "门店 App", "tenant": "shop-a", "daily_quota": 1000, "monthly_quota": 20000, "rate_per_minute": 60}]
+//
+// 请求通过 X-API-Key（或 Authorization: Bearer）携带 Key，带了 Key 的请求租户以 Key 配置为准。
+// 配置了任意 Key 后，识别类接口（/api/v1/scan、/api/v1/ocr 及批量接口）必须带 Key，
+// 每张图片计一次（批量接口按图片张数），按北京时间的自然日 / 自然月计数，0 表示不限；服务端出错（5xx）
+// 或当日识别预算用完（429）的请求退回配额。
+// 计数文件在 data/quota，多个实例共用同一个数据目录时通过锁文件串行更新，不会超发。
+// Key 的持有者可以用 GET /api/v1/quota 查询剩余配额
+
+// APIKey 一个接入方
+type APIKey struct {
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	Tenant       string `json:"tenant,omitempty"`
+	DailyQuota   int    `json:"daily_quota,omitempty"`
+	MonthlyQuota int    `json:"monthly_quota,omitempty"`
+	// RatePerMinute 每分钟最多请求数（所有接口合计），0 表示不限，见 ratelimit.go
+	RatePerMinute int `json:"rate_per_minute,omitempty"`
+}
+
+// id 计数文件名用 Key 的摘要，不把 Key 本身写进文件名
+func (k APIKey) id() string {
+	sum := sha256.Sum256([]byte(k.Key))
+	return hex.EncodeToString(sum[:8])
+}
+
+type apiKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+var apiKeys = &apiKeyStore{keys: map[string]APIKey{}}
+
+// Load 读取 Key 配置，文件不存在时不启用 Key 鉴权；重新加载时删掉文件即停用原有的全部 Key
+func (s *apiKeyStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.mu.Lock()
+		s.keys = map[string]APIKey{}
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []APIKey
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	keys := map[string]APIKey{}
+	for i, k := range list {
+		k.Key = strings.TrimSpace(k.Key)
+		if k.Key == "" {
+			return fmt.Errorf("第%d个 Key 为空", i+1)
+		}
+		if _, dup := keys[k.Key]; dup {
+			return fmt.Errorf("Key %s 重复", k.Name)
+		}
+		keys[k.Key] = k
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *apiKeyStore) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys) > 0
+}
+
+func (s *apiKeyStore) Get(key string) (APIKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[key]
+	return k, ok
+}
+
+func requestAPIKey(c *gin.Context) string {
+	if k := strings.TrimSpace(c.GetHeader("X-API-Key")); k != "" {
+		return k
+	}
+	// 管理接口也用 Bearer，只认已配置的 Key
+	if k := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); k != "" {
+		if _, ok := apiKeys.Get(k); ok {
+			return k
+		}
+	}
+	return ""
+}
+
+// apiKeyAuth 识别请求携带的 Key：无效 Key 直接 401，有效 Key 按配置覆盖租户；没带 Key 的请求照常放行
+func apiKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := requestAPIKey(c)
+		if raw == "" {
+			c.Next()
+			return
+		}
+		k, ok := apiKeys.Get(raw)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "API Key 无效"})
+			return
+		}
+		if k.Tenant != "" {
+			c.Request.Header.Set("X-Tenant-ID", k.Tenant)
+		}
+		c.Set("api_key", k)
+		c.Next()
+	}
+}
+
+func apiKeyOf(c *gin.Context) (APIKey, bool) {
+	v, ok := c.Get("api_key")
+	if !ok {
+		return APIKey{}, false
+	}
+	k, ok := v.(APIKey)
+	return k, ok
+}
+
+// QuotaUsage 某个 Key 当日、当月已用次数
+type QuotaUsage struct {
+	Day        string `json:"day"`
+	DayCount   int    `json:"day_count"`
+	Month      string `json:"month"`
+	MonthCount int    `json:"month_count"`
+}
+
+func (u *QuotaUsage) rollover(now time.Time) {
+	now = now.In(chinaTime)
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day, u.DayCount = day, 0
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.MonthCount = month, 0
+	}
+}
+
+var errQuotaExceeded = errors.New("API Key 配额已用完")
+
+// quotaStore 配额计数目录；每个 Key 一个计数文件，更新时持有同名 .lock 文件
+type quotaStore struct {
+	dir string
+}
+
+var quotas *quotaStore
+
+func newQuotaStore(dir string) (*quotaStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &quotaStore{dir: dir}, nil
+}
+
+func (s *quotaStore) path(k APIKey) string { return filepath.Join(s.dir, k.id()+".json") }
+
+// lock 以 O_EXCL 创建锁文件实现跨进程互斥，持有超过 10 秒的锁视为持有者已崩溃
+func (s *quotaStore) lock(k APIKey) (func(), error) {
+	p := s.path(k) + ".lock"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(p) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, serr := os.Stat(p); serr == nil && time.Since(info.ModTime()) > 10*time.Second {
+			os.Remove(p)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("配额计数繁忙，请稍后重试")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *quotaStore) read(k APIKey) QuotaUsage {
+	var u QuotaUsage
+	if raw, err := os.ReadFile(s.path(k)); err == nil {
+		json.Unmarshal(raw, &u)
+	}
+	u.rollover(time.Now())
+	return u
+}
+
+// Usage 当前用量（只读，不加锁）
+func (s *quotaStore) Usage(k APIKey) QuotaUsage {
+	if sharedRedis != nil {
+		if u, err := sharedQuotaAdd(k, 0); !logSharedErr("读取配额", err) {
+			return u
+		}
+	}
+	return s.read(k)
+}
+
+// Add 原子地增加 n 次（n 为负数时退回）；增加后超出日或月配额则不写入并返回 errQuotaExceeded。
+// 多副本部署时计数在 Redis 中，见 cluster.go
+func (s *quotaStore) Add(k APIKey, n int) (QuotaUsage, error) {
+	if sharedRedis != nil {
+		u, err := sharedQuotaAdd(k, n)
+		if !logSharedErr("配额计数", err) {
+			return u, err
+		}
+	}
+	unlock, err := s.lock(k)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	defer unlock()
+	u := s.read(k)
+	if n > 0 && ((k.DailyQuota > 0 && u.DayCount+n > k.DailyQuota) || (k.MonthlyQuota > 0 && u.MonthCount+n > k.MonthlyQuota)) {
+		return u, errQuotaExceeded
+	}
+	u.DayCount, u.MonthCount = max(u.DayCount+n, 0), max(u.MonthCount+n, 0)
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return u, err
+	}
+	p := s.path(k)
+	if err := os.WriteFile(p+".tmp", raw, 0o644); err != nil {
+		return u, err
+	}
+	return u, os.Rename(p+".tmp", p)
+}
+
+// quotaAddScript 检查日、月配额后同时增加两个计数；n 为 0 时只读取
+var quotaAddScript = redis.NewScript(`
+local day = tonumber(redis.call('GET', KEYS[1]) or '0')
+local month = tonumber(redis.call('GET', KEYS[2]) or '0')
+local n, dayQuota, monthQuota = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+if n > 0 and ((dayQuota > 0 and day + n > dayQuota) or (monthQuota > 0 and month + n > monthQuota)) then
+	return {day, month, 0}
+end
+if n ~= 0 then
+	day = math.max(redis.call('INCRBY', KEYS[1], n), 0)
+	month = math.max(redis.call('INCRBY', KEYS[2], n), 0)
+	redis.call('EXPIRE', KEYS[1], 172800)
+	redis.call('EXPIRE', KEYS[2], 3024000)
+end
+return {day, month, 1}`)
+
+// sharedQuotaAdd quotaStore.Add 的 Redis 实现，按自然日、自然月分别计数
+func sharedQuotaAdd(k APIKey, n int) (QuotaUsage, error) {
+	now := time.Now().In(chinaTime)
+	u := QuotaUsage{Day: now.Format("2006-01-02"), Month: now.Format("2006-01")}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	keys := []string{sharedKey("quota", k.id(), u.Day), sharedKey("quota", k.id(), u.Month)}
+	res, err := quotaAddScript.Run(ctx, sharedRedis, keys, n, k.DailyQuota, k.MonthlyQuota).Int64Slice()
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	u.DayCount, u.MonthCount = int(res[0]), int(res[1])
+	if res[2] == 0 {
+		return u, errQuotaExceeded
+	}
+	return u, nil
+}
+
+func setQuotaHeaders(c *gin.Context, k APIKey, u QuotaUsage) {
+	if k.DailyQuota > 0 {
+		c.Header("X-Quota-Daily-Remaining", strconv.Itoa(max(k.DailyQuota-u.DayCount, 0)))
+	}
+	if k.MonthlyQuota > 0 {
+		c.Header("X-Quota-Monthly-Remaining", strconv.Itoa(max(k.MonthlyQuota-u.MonthCount, 0)))
+	}
+}
+
+// scanQuota 识别类接口的配额检查；cost 为本次请求的图片数
+func scanQuota(cost func(c *gin.Context) int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !apiKeys.Enabled() {
+			c.Next()
+			return
+		}
+		k, ok := apiKeyOf(c)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"error": "缺少 API Key (X-API-Key)"})
+			return
+		}
+		n := max(cost(c), 1)
+		u, err := quotas.Add(k, n)
+		if errors.Is(err, errQuotaExceeded) {
+			setQuotaHeaders(c, k, u)
+			c.AbortWithStatusJSON(429, gin.H{"error": errQuotaExceeded.Error(), "code": "QUOTA_EXCEEDED"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(503, gin.H{"error": err.Error()})
+			return
+		}
+		setQuotaHeaders(c, k, u)
+		c.Next()
+		if status := c.Writer.Status(); status >= 500 || status == 429 {
+			quotas.Add(k, -n)
+		}
+	}
+}
+
+// perRequest 单张图片的接口
+func perRequest(*gin.Context) int { return 1 }
+
+// perImage 批量接口按上传的图片数计
+func perImage(c *gin.Context) int {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return 1
+	}
+	return len(form.File["images"])
+}
+
+// perZipImage ZIP 批量按压缩包内的图片数计
+func perZipImage(c *gin.Context) int {
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		return 1
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	zr, err := zip.NewReader(f, fh.Size)
+	if err != nil {
+		return 1
+	}
+	return len(zipImageEntries(zr))
+}
+
+type quotaWindow struct {
+	Limit     int       `json:"limit"` // 0 表示不限
+	Used      int       `json:"used"`
+	Remaining *int      `json:"remaining,omitempty"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+func newQuotaWindow(limit, used int, resets time.Time) quotaWindow {
+	w := quotaWindow{Limit: limit, Used: used, ResetsAt: resets}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		w.Remaining = &remaining
+	}
+	return w
+}
+
+// quotaHandler GET /api/v1/quota 当前 Key 的配额与剩余次数
+func quotaHandler(c *gin.Context) {
+	k, ok := apiKeyOf(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "缺少 API Key (X-API-Key)"})
+		return
+	}
+	u := quotas.Usage(k)
+	now := time.Now().In(chinaTime)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, chinaTime)
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, chinaTime)
+	c.JSON(200, gin.H{
+		"name":    k.Name,
+		"tenant":  tenantOf(c),
+		"daily":   newQuotaWindow(k.DailyQuota, u.DayCount, tomorrow),
+		"monthly": newQuotaWindow(k.MonthlyQuota, u.MonthCount, nextMonth),
+	})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestAPIKeys 只启用 keys，配额计数换到临时目录
+func useTestAPIKeys(t *testing.T, keys ...APIKey) {
+	t.Helper()
+	prevKeys, prevQuotas := apiKeys.keys, quotas
+	t.Cleanup(func() { apiKeys.keys, quotas = prevKeys, prevQuotas })
+	apiKeys.keys = map[string]APIKey{}
+	for _, k := range keys {
+		apiKeys.keys[k.Key] = k
+	}
+	var err error
+	if quotas, err = newQuotaStore(filepath.Join(t.TempDir(), "quota")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIKeyStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		file        string
+		wantErr     bool
+		wantEnabled bool
+	}{
+		{"文件不存在不启用", "", false, false},
+		{"正常配置", `[{"key": " sk-a ", "name": "门店 App", "tenant": "shop-a", "daily_quota": 10}]`, false, true},
+		{"Key 为空", `[{"key": "", "name": "x"}]`, true, false},
+		{"Key 重复", `[{"key": "sk-a", "name": "x"}, {"key": "sk-a", "name": "y"}]`, true, false},
+		{"JSON 格式错误", `[{`, true, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "missing.json")
+			if tt.file != "" {
+				path = writeTestFile(t, dir, string(rune('a'+i))+".json", []byte(tt.file))
+			}
+			s := &apiKeyStore{keys: map[string]APIKey{}}
+			if err := s.Load(path); (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", s.Enabled(), tt.wantEnabled)
+			}
+			if tt.wantEnabled {
+				if k, ok := s.Get("sk-a"); !ok || k.Tenant != "shop-a" || k.DailyQuota != 10 {
+					t.Errorf("Get(sk-a) = %+v, %v", k, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestAPIKeys(t, APIKey{Key: "sk-a", Name: "门店 App", Tenant: "shop-a"}, APIKey{Key: "sk-free", Name: "不限租户"})
+	r := gin.New()
+	r.Use(apiKeyAuth())
+	r.GET("/whoami", func(c *gin.Context) {
+		k, _ := apiKeyOf(c)
+		c.JSON(200, gin.H{"tenant": tenantOf(c), "key": k.Name})
+	})
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		wantTenant string
+		wantKey    string
+	}{
+		{"没带 Key 照常放行", nil, 200, "default", ""},
+		{"X-API-Key", map[string]string{"X-API-Key": "sk-a"}, 200, "shop-a", "门店 App"},
+		{"Key 的租户覆盖请求头", map[string]string{"X-API-Key": "sk-a", "X-Tenant-ID": "shop-b"}, 200, "shop-a", "门店 App"},
+		{"Key 未配置租户时沿用请求头", map[string]string{"X-API-Key": "sk-free", "X-Tenant-ID": "shop-b"}, 200, "shop-b", "不限租户"},
+		{"Bearer", map[string]string{"Authorization": "Bearer sk-a"}, 200, "shop-a", "门店 App"},
+		{"Bearer 不是 Key 时不当作 Key", map[string]string{"Authorization": "Bearer session-token"}, 200, "default", ""},
+		{"无效 Key", map[string]string{"X-API-Key": "sk-wrong"}, 401, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp map[string]string
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp["tenant"] != tt.wantTenant || resp["key"] != tt.wantKey {
+				t.Errorf("got %v, want tenant %q key %q", resp, tt.wantTenant, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestQuotaStoreAdd(t *testing.T) {
+	useTestAPIKeys(t)
+	daily := APIKey{Key: "sk-daily", DailyQuota: 3}
+	monthly := APIKey{Key: "sk-monthly", MonthlyQuota: 2}
+	unlimited := APIKey{Key: "sk-unlimited"}
+	// 昨天的计数按新的一天重新开始
+	writeTestFile(t, quotas.dir, unlimited.id()+".json", []byte(`{"day": "2000-01-01", "day_count": 99, "month": "2000-01", "month_count": 99}`))
+
+	steps := []struct {
+		name      string
+		key       APIKey
+		n         int
+		wantErr   bool
+		wantDay   int
+		wantMonth int
+	}{
+		{"日配额内", daily, 2, false, 2, 2},
+		{"超出日配额不计数", daily, 2, true, 2, 2},
+		{"正好用完", daily, 1, false, 3, 3},
+		{"退回", daily, -1, false, 2, 2},
+		{"退回不小于 0", monthly, -5, false, 0, 0},
+		{"月配额", monthly, 2, false, 2, 2},
+		{"超出月配额", monthly, 1, true, 2, 2},
+		{"不限", unlimited, 100, false, 100, 100},
+	}
+	for _, st := range steps {
+		u, err := quotas.Add(st.key, st.n)
+		if (err == errQuotaExceeded) != st.wantErr || (err != nil && err != errQuotaExceeded) {
+			t.Fatalf("%s: Add() error = %v", st.name, err)
+		}
+		if u.DayCount != st.wantDay || u.MonthCount != st.wantMonth {
+			t.Errorf("%s: usage = %d/%d, want %d/%d", st.name, u.DayCount, u.MonthCount, st.wantDay, st.wantMonth)
+		}
+	}
+	if u := quotas.Usage(daily); u.DayCount != 2 {
+		t.Errorf("Usage() = %+v, want day_count 2", u)
+	}
+}
+
+func TestScanQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestAPIKeys(t, APIKey{Key: "sk-a", Tenant: "shop-a", DailyQuota: 3, MonthlyQuota: 100})
+	r := gin.New()
+	r.Use(apiKeyAuth())
+	r.POST("/scan", scanQuota(perRequest), func(c *gin.Context) {
+		status := 200
+		if s := c.Query("status"); s != "" {
+			json.Unmarshal([]byte(s), &status)
+		}
+		c.JSON(status, gin.H{})
+	})
+
+	steps := []struct {
+		name          string
+		key           string
+		query         string
+		wantStatus    int
+		wantRemaining string
+		wantUsedAfter int
+	}{
+		{"缺少 Key", "", "", 401, "", 0},
+		{"第一次", "sk-a", "", 200, "2", 1},
+		{"服务端出错退回配额", "sk-a", "?status=500", 500, "1", 1},
+		{"预算用完退回配额", "sk-a", "?status=429", 429, "1", 1},
+		{"客户端错误照常计数", "sk-a", "?status=400", 400, "1", 2},
+		{"用完", "sk-a", "", 200, "0", 3},
+		{"超出配额", "sk-a", "", 429, "0", 3},
+	}
+	for _, st := range steps {
+		req := httptest.NewRequest("POST", "/scan"+st.query, nil)
+		if st.key != "" {
+			req.Header.Set("X-API-Key", st.key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != st.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", st.name, w.Code, st.wantStatus, w.Body)
+		}
+		if got := w.Header().Get("X-Quota-Daily-Remaining"); got != st.wantRemaining {
+			t.Errorf("%s: X-Quota-Daily-Remaining = %q, want %q", st.name, got, st.wantRemaining)
+		}
+		if u := quotas.Usage(apiKeys.keys["sk-a"]); u.DayCount != st.wantUsedAfter {
+			t.Errorf("%s: day_count = %d, want %d", st.name, u.DayCount, st.wantUsedAfter)
+		}
+	}
+
+	// 没有配置任何 Key 时不检查
+	apiKeys.keys = map[string]APIKey{}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/scan", nil))
+	if w.Code != 200 {
+		t.Errorf("keys disabled: status = %d", w.Code)
+	}
+}
+
+func TestQuotaHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limited := APIKey{Key: "sk-a", Name: "门店 App", Tenant: "shop-a", DailyQuota: 10}
+	useTestAPIKeys(t, limited)
+	quotas.Add(limited, 4)
+	r := gin.New()
+	r.Use(apiKeyAuth())
+	r.GET("/api/v1/quota", quotaHandler)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"查询剩余配额", "sk-a", 200},
+		{"缺少 Key", "", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/quota", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Tenant  string      `json:"tenant"`
+				Daily   quotaWindow `json:"daily"`
+				Monthly quotaWindow `json:"monthly"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Tenant != "shop-a" || resp.Daily.Used != 4 || resp.Daily.Remaining == nil || *resp.Daily.Remaining != 6 {
+				t.Errorf("daily = %+v, tenant = %q", resp.Daily, resp.Tenant)
+			}
+			if resp.Monthly.Limit != 0 || resp.Monthly.Remaining != nil || !resp.Monthly.ResetsAt.After(resp.Daily.ResetsAt) {
+				t.Errorf("monthly = %+v", resp.Monthly)
+			}
+		})
+	}
+}
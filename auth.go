@@ -0,0 +1,433 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// AUTH: 终端用户令牌（访问令牌 + 刷新令牌 + 会话吊销）
+// ==========================================
+
+// 移动端不必把 API Key 打包进 App：接入方后台用自己的 API Key 为用户换取一对令牌，
+//
+//	POST /api/v1/auth/token    {"user_id": "u123", "device_id": "...", "device_name": "iPhone 15"}
+//
+// 返回短期访问令牌（ua_…，默认 15 分钟，AUTH_ACCESS_TTL）和长期刷新令牌（ur_…，默认 30 天，AUTH_REFRESH_TTL）。
+// App 以 Authorization: Bearer <访问令牌> 调用接口，租户、用户和设备以令牌为准，不再信任请求头。
+// 访问令牌过期后用 POST /api/v1/auth/refresh {"refresh_token": "..."} 换新的一对，旧刷新令牌随即作废；
+// 已作废的刷新令牌再次出现说明令牌被盗用，整个会话立即吊销。
+// 每个会话对应一台设备，用户可以列出自己的会话（GET /api/v1/auth/sessions）并吊销其中任意一个，
+// 管理员也可按用户查看和吊销。访问令牌每次使用都会核对会话状态，吊销立即生效，不需要更换签名密钥。
+// 签名密钥为 AUTH_TOKEN_SECRET，多实例需配置相同的值；未配置时每次启动随机生成，重启后所有访问令牌失效（刷新令牌不受影响）。
+// 会话保存在 data/sessions.json，只保存刷新令牌的摘要
+
+const (
+	accessTokenPrefix  = "ua_"
+	refreshTokenPrefix = "ur_"
+)
+
+// AuthSession 一个登录会话（一台设备）
+type AuthSession struct {
+	ID          string     `json:"id"`
+	Tenant      string     `json:"tenant"`
+	UserID      string     `json:"user_id"`
+	DeviceID    string     `json:"device_id,omitempty"`
+	DeviceName  string     `json:"device_name,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  time.Time  `json:"last_used_at"`
+	ExpiresAt   time.Time  `json:"expires_at"` // 刷新令牌过期时间，每次刷新顺延
+	RefreshHash string     `json:"refresh_hash"`
+	UsedHashes  []string   `json:"used_hashes,omitempty"` // 已轮换掉的刷新令牌摘要，用来识别盗用
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy   string     `json:"revoked_by,omitempty"` // user / admin / reuse
+}
+
+func (s AuthSession) active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// sessionView 列表接口返回的会话，不含刷新令牌摘要
+type sessionView struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	DeviceID   string     `json:"device_id,omitempty"`
+	DeviceName string     `json:"device_name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+	Current    bool       `json:"current,omitempty"`
+}
+
+func (s AuthSession) view(current string) sessionView {
+	return sessionView{
+		ID: s.ID, UserID: s.UserID, DeviceID: s.DeviceID, DeviceName: s.DeviceName,
+		CreatedAt: s.CreatedAt, LastUsedAt: s.LastUsedAt, ExpiresAt: s.ExpiresAt,
+		RevokedAt: s.RevokedAt, RevokedBy: s.RevokedBy, Current: s.ID == current,
+	}
+}
+
+// accessClaims 访问令牌的内容
+type accessClaims struct {
+	Session string `json:"sid"`
+	Tenant  string `json:"ten"`
+	UserID  string `json:"uid"`
+	Device  string `json:"dev,omitempty"`
+	Expires int64  `json:"exp"`
+}
+
+var (
+	authSecretOnce sync.Once
+	authSecretKey  []byte
+)
+
+func authSecret() []byte {
+	authSecretOnce.Do(func() {
+		if s := os.Getenv("AUTH_TOKEN_SECRET"); s != "" {
+			authSecretKey = []byte(s)
+			return
+		}
+		authSecretKey = make([]byte, 32)
+		rand.Read(authSecretKey)
+	})
+	return authSecretKey
+}
+
+func accessTTL() time.Duration  { return envDuration("AUTH_ACCESS_TTL", 15*time.Minute) }
+func refreshTTL() time.Duration { return envDuration("AUTH_REFRESH_TTL", 30*24*time.Hour) }
+
+// signAccessToken ua_<base64url(claims)>.<base64url(HMAC-SHA256)>
+func signAccessToken(c accessClaims) string {
+	payload, _ := json.Marshal(c)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, authSecret())
+	mac.Write([]byte(body))
+	return accessTokenPrefix + body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseAccessToken 校验签名与有效期，不检查会话状态
+func parseAccessToken(token string, now time.Time) (accessClaims, bool) {
+	body, sig, ok := strings.Cut(strings.TrimPrefix(token, accessTokenPrefix), ".")
+	if !ok {
+		return accessClaims{}, false
+	}
+	mac := hmac.New(sha256.New, authSecret())
+	mac.Write([]byte(body))
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return accessClaims{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return accessClaims{}, false
+	}
+	var c accessClaims
+	if json.Unmarshal(payload, &c) != nil || now.Unix() >= c.Expires {
+		return accessClaims{}, false
+	}
+	return c, true
+}
+
+// newRefreshToken ur_<会话ID>.<随机串>；会话里只保存随机串的摘要
+func newRefreshToken(sessionID string) (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	return refreshTokenPrefix + sessionID + "." + secret, refreshHash(secret)
+}
+
+func refreshHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type sessionStore struct {
+	mu       sync.Mutex
+	path     string
+	sessions map[string]*AuthSession
+}
+
+var authSessions = &sessionStore{sessions: map[string]*AuthSession{}}
+
+func (s *sessionStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := readSealedFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*AuthSession
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for _, sess := range list {
+		s.sessions[sess.ID] = sess
+	}
+	return nil
+}
+
+// persist 过期或吊销超过 7 天的会话不再保存；调用方需持有锁
+func (s *sessionStore) persist() {
+	if s.path == "" {
+		return
+	}
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	list := make([]*AuthSession, 0, len(s.sessions))
+	for id, sess := range s.sessions {
+		if sess.ExpiresAt.Before(cutoff) || (sess.RevokedAt != nil && sess.RevokedAt.Before(cutoff)) {
+			delete(s.sessions, id)
+			continue
+		}
+		list = append(list, sess)
+	}
+	raw, _ := json.MarshalIndent(list, "", "  ")
+	tmp := s.path + ".tmp"
+	if err := writeSealedFile(tmp, raw); err != nil {
+		log.Printf("保存登录会话失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("保存登录会话失败: %v", err)
+	}
+}
+
+// tokenPair 签发给客户端的一对令牌
+type tokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int64     `json:"expires_in"` // 访问令牌有效秒数
+	SessionID    string    `json:"session_id"`
+	RefreshUntil time.Time `json:"refresh_expires_at"`
+}
+
+// maxUsedHashes 每个会话记住的已轮换刷新令牌个数；更早的令牌再出现只按无效处理
+const maxUsedHashes = 32
+
+// issue 为会话签发新的令牌并轮换刷新令牌；调用方需持有锁
+func (s *sessionStore) issue(sess *AuthSession, now time.Time) tokenPair {
+	if sess.RefreshHash != "" {
+		sess.UsedHashes = append(sess.UsedHashes, sess.RefreshHash)
+		if n := len(sess.UsedHashes); n > maxUsedHashes {
+			sess.UsedHashes = sess.UsedHashes[n-maxUsedHashes:]
+		}
+	}
+	refresh, hash := newRefreshToken(sess.ID)
+	sess.RefreshHash, sess.LastUsedAt, sess.ExpiresAt = hash, now, now.Add(refreshTTL())
+	exp := now.Add(accessTTL())
+	access := signAccessToken(accessClaims{Session: sess.ID, Tenant: sess.Tenant, UserID: sess.UserID, Device: sess.DeviceID, Expires: exp.Unix()})
+	return tokenPair{
+		AccessToken: access, RefreshToken: refresh, TokenType: "Bearer",
+		ExpiresIn: int64(accessTTL() / time.Second), SessionID: sess.ID, RefreshUntil: sess.ExpiresAt,
+	}
+}
+
+// Create 新建会话
+func (s *sessionStore) Create(tenant, userID, deviceID, deviceName string) tokenPair {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	sess := &AuthSession{ID: newJobID(), Tenant: tenant, UserID: userID, DeviceID: deviceID, DeviceName: deviceName, CreatedAt: now}
+	pair := s.issue(sess, now)
+	s.sessions[sess.ID] = sess
+	s.persist()
+	return pair
+}
+
+// Refresh 用刷新令牌换新的一对；本会话签发过、已轮换掉的旧令牌再次使用时吊销整个会话，
+// 从未签发过的令牌（伪造或抄错）只按无效处理，不影响会话
+func (s *sessionStore) Refresh(token string) (tokenPair, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, refreshTokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, refreshTokenPrefix) {
+		return tokenPair{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	now := time.Now()
+	if !ok || !sess.active(now) {
+		return tokenPair{}, false
+	}
+	hash := refreshHash(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(sess.RefreshHash)) != 1 {
+		if !slices.Contains(sess.UsedHashes, hash) {
+			return tokenPair{}, false
+		}
+		log.Printf("刷新令牌被重复使用，吊销会话 %s [%s]", sess.ID, sess.Tenant)
+		sess.RevokedAt, sess.RevokedBy = &now, "reuse"
+		s.persist()
+		return tokenPair{}, false
+	}
+	pair := s.issue(sess, now)
+	s.persist()
+	return pair, true
+}
+
+// Check 访问令牌对应的会话仍然有效
+func (s *sessionStore) Check(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	return ok && sess.active(time.Now())
+}
+
+// List 某用户的全部会话，最近使用的在前；userID 为空时列出整个租户
+func (s *sessionStore) List(tenant, userID string) []AuthSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []AuthSession
+	for _, sess := range s.sessions {
+		if sess.Tenant == tenant && (userID == "" || sess.UserID == userID) {
+			out = append(out, *sess)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastUsedAt.After(out[j].LastUsedAt) })
+	return out
+}
+
+// Revoke 吊销会话；userID 非空时只能吊销该用户自己的会话
+func (s *sessionStore) Revoke(tenant, userID, id, by string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || sess.Tenant != tenant || (userID != "" && sess.UserID != userID) {
+		return false
+	}
+	if sess.RevokedAt == nil {
+		now := time.Now()
+		sess.RevokedAt, sess.RevokedBy = &now, by
+		s.persist()
+	}
+	return true
+}
+
+// userTokenAuth 识别访问令牌：无效或会话已吊销直接 401；有效时以令牌覆盖租户、用户和设备。
+// 没带访问令牌的请求照常放行，但不再有用户身份（见 userOf），按用户汇总的接口会拒绝
+func userTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, accessTokenPrefix) {
+			c.Next()
+			return
+		}
+		claims, ok := parseAccessToken(token, time.Now())
+		if !ok || !authSessions.Check(claims.Session) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "访问令牌无效或已过期", "code": "TOKEN_INVALID"})
+			return
+		}
+		c.Request.Header.Set("X-Tenant-ID", claims.Tenant)
+		if claims.Device != "" {
+			c.Request.Header.Set("X-Device-ID", claims.Device)
+		}
+		c.Set("auth_session", claims.Session)
+		c.Set("auth_user", claims.UserID)
+		c.Next()
+	}
+}
+
+// sessionOf 当前请求的会话编号，未使用访问令牌时为空
+func sessionOf(c *gin.Context) string { return c.GetString("auth_session") }
+
+// authTokenHandler POST /api/v1/auth/token 接入方后台凭 API Key 为用户签发令牌。
+// 会话的租户只取 Key 配置的租户，Key 未配置时为 default，不采信请求头 X-Tenant-ID
+func authTokenHandler(c *gin.Context) {
+	key, ok := apiKeyOf(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "缺少 API Key (X-API-Key)"})
+		return
+	}
+	var req struct {
+		UserID     string `json:"user_id"`
+		DeviceID   string `json:"device_id"`
+		DeviceName string `json:"device_name"`
+	}
+	if !bindValidJSON(c, authTokenSchema, &req) {
+		return
+	}
+	tenant := key.Tenant
+	if tenant == "" {
+		tenant = "default"
+	}
+	c.JSON(200, authSessions.Create(tenant, strings.TrimSpace(req.UserID), strings.TrimSpace(req.DeviceID), strings.TrimSpace(req.DeviceName)))
+}
+
+// authRefreshHandler POST /api/v1/auth/refresh
+func authRefreshHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
+		return
+	}
+	pair, ok := authSessions.Refresh(strings.TrimSpace(req.RefreshToken))
+	if !ok {
+		c.JSON(401, gin.H{"error": "刷新令牌无效或已过期", "code": "TOKEN_INVALID"})
+		return
+	}
+	c.JSON(200, pair)
+}
+
+// authSessionsHandler GET /api/v1/auth/sessions 当前用户的会话列表
+func authSessionsHandler(c *gin.Context) {
+	if sessionOf(c) == "" {
+		c.JSON(401, gin.H{"error": "访问令牌无效或已过期", "code": "TOKEN_INVALID"})
+		return
+	}
+	items := []sessionView{}
+	for _, sess := range authSessions.List(tenantOf(c), userOf(c)) {
+		items = append(items, sess.view(sessionOf(c)))
+	}
+	c.JSON(200, gin.H{"items": items})
+}
+
+// authRevokeHandler DELETE /api/v1/auth/sessions/:id 吊销自己的某个会话（含当前会话，即退出登录）
+func authRevokeHandler(c *gin.Context) {
+	if sessionOf(c) == "" {
+		c.JSON(401, gin.H{"error": "访问令牌无效或已过期", "code": "TOKEN_INVALID"})
+		return
+	}
+	if !authSessions.Revoke(tenantOf(c), userOf(c), c.Param("id"), "user") {
+		c.JSON(404, gin.H{"error": "会话不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"revoked": true})
+}
+
+// adminSessionsHandler GET /api/v1/admin/sessions?user_id= 按用户查看会话（租户取 X-Tenant-ID）
+func adminSessionsHandler(c *gin.Context) {
+	items := []sessionView{}
+	for _, sess := range authSessions.List(tenantOf(c), strings.TrimSpace(c.Query("user_id"))) {
+		items = append(items, sess.view(""))
+	}
+	c.JSON(200, gin.H{"items": items})
+}
+
+// adminRevokeSessionHandler DELETE /api/v1/admin/sessions/:id 吊销任意会话，如用户报告手机丢失
+func adminRevokeSessionHandler(c *gin.Context) {
+	if !authSessions.Revoke(tenantOf(c), "", c.Param("id"), "admin") {
+		c.JSON(404, gin.H{"error": "会话不存在"})
+		return
+	}
+	c.JSON(200, gin.H{"revoked": true})
+}
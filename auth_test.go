@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionRefresh(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       func(first, second tokenPair) string
+		wantOK      bool
+		wantRevoked bool
+	}{
+		{"当前刷新令牌", func(_, second tokenPair) string { return second.RefreshToken }, true, false},
+		{"已轮换的旧令牌再次出现视为盗用", func(first, _ tokenPair) string { return first.RefreshToken }, false, true},
+		{"从未签发过的令牌不吊销会话", func(_, second tokenPair) string {
+			return refreshTokenPrefix + second.SessionID + ".forged"
+		}, false, false},
+		{"会话不存在", func(_, _ tokenPair) string { return refreshTokenPrefix + "nope.x" }, false, false},
+		{"格式错误", func(_, second tokenPair) string { return second.SessionID }, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &sessionStore{sessions: map[string]*AuthSession{}}
+			first := store.Create("shop-a", "u1", "d1", "iPhone")
+			second, ok := store.Refresh(first.RefreshToken)
+			if !ok {
+				t.Fatal("first refresh failed")
+			}
+			_, ok = store.Refresh(tt.token(first, second))
+			if ok != tt.wantOK {
+				t.Errorf("Refresh ok = %v, want %v", ok, tt.wantOK)
+			}
+			if revoked := !store.Check(second.SessionID); revoked != tt.wantRevoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+// 签发令牌时会话租户取自 API Key，未配置租户的 Key 不能用 X-Tenant-ID 指定租户
+func TestAuthTokenHandlerTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prevKeys := apiKeys.keys
+	t.Cleanup(func() { apiKeys.keys = prevKeys })
+	apiKeys.keys = map[string]APIKey{
+		"sk-shop": {Key: "sk-shop", Name: "门店 App", Tenant: "shop-a"},
+		"sk-open": {Key: "sk-open", Name: "未分租户"},
+	}
+	tests := []struct {
+		name   string
+		key    string
+		tenant string // 请求头 X-Tenant-ID
+		want   string
+	}{
+		{"Key 配置了租户", "sk-shop", "", "shop-a"},
+		{"Key 的租户优先于请求头", "sk-shop", "shop-b", "shop-a"},
+		{"Key 未配置租户", "sk-open", "", "default"},
+		{"Key 未配置租户时不采信请求头", "sk-open", "shop-b", "default"},
+	}
+	r := gin.New()
+	r.Use(apiKeyAuth())
+	r.POST("/api/v1/auth/token", authTokenHandler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/auth/token", strings.NewReader(`{"user_id": "u1"}`))
+			req.Header.Set("X-API-Key", tt.key)
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant-ID", tt.tenant)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != 200 {
+				t.Fatalf("status = %d (%s)", w.Code, w.Body)
+			}
+			var pair tokenPair
+			if err := json.Unmarshal(w.Body.Bytes(), &pair); err != nil {
+				t.Fatal(err)
+			}
+			claims, ok := parseAccessToken(pair.AccessToken, time.Now())
+			if !ok || claims.Tenant != tt.want {
+				t.Errorf("tenant = %q, want %q", claims.Tenant, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prevKeys := apiKeys.keys
+	t.Cleanup(func() { apiKeys.keys = prevKeys })
+	apiKeys.keys = map[string]APIKey{"sk-test": {Key: "sk-test", Name: "门店 App", Tenant: "shop-a"}}
+	pair := authSessions.Create("shop-a", "u-token", "", "")
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    string
+		status  int
+	}{
+		{"匿名请求", "/h", nil, "", 401},
+		{"只带 X-User-ID 不采信", "/h", map[string]string{"X-User-ID": "u-forged"}, "", 401},
+		{"查询参数 user_id 不采信", "/h?user_id=u-forged", nil, "", 401},
+		{"API Key 调用采信 X-User-ID", "/h", map[string]string{"X-API-Key": "sk-test", "X-User-ID": "u-backend"}, "u-backend", 200},
+		{"API Key 调用未带用户", "/h", map[string]string{"X-API-Key": "sk-test"}, "", 401},
+		{"访问令牌中的用户", "/h", map[string]string{"Authorization": "Bearer " + pair.AccessToken}, "u-token", 200},
+		{"访问令牌优先于请求头", "/h", map[string]string{"Authorization": "Bearer " + pair.AccessToken, "X-User-ID": "u-forged"}, "u-token", 200},
+	}
+	r := gin.New()
+	r.Use(apiKeyAuth(), userTokenAuth())
+	r.GET("/h", func(c *gin.Context) {
+		userID, ok := requireUser(c)
+		if !ok {
+			return
+		}
+		c.JSON(200, gin.H{"user": userID})
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+			var body struct{ User string }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.User != tt.want {
+				t.Errorf("user = %q, want %q", body.User, tt.want)
+			}
+		})
+	}
+
+	t.Run("吊销后的访问令牌", func(t *testing.T) {
+		authSessions.Revoke("shop-a", "", pair.SessionID, "admin")
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/h", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		r.ServeHTTP(w, req)
+		if w.Code != 401 || !strings.Contains(w.Body.String(), "TOKEN_INVALID") {
+			t.Errorf("status = %d, body = %s", w.Code, w.Body)
+		}
+	})
+}
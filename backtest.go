@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// BACKTEST: 用历史开奖回测一组号码
+// ==========================================
+
+// BacktestRequest POST /api/v1/backtest
+type BacktestRequest struct {
+	Type    string       `json:"type"`
+	Tickets []UserTicket `json:"tickets"`
+	Years   int          `json:"years"` // 回测最近 N 年，默认 1，0 表示全部历史
+}
+
+// BacktestLevel 某奖级命中次数
+type BacktestLevel struct {
+	Level    int    `json:"level"`
+	Name     string `json:"name"`
+	Hits     int    `json:"hits"`
+	Prize    int64  `json:"prize"` // 该奖级累计奖金（元）
+	PrizeFen Fen    `json:"prize_fen"`
+}
+
+// BacktestHit 一次中奖记录，只保存三等奖及以上的
+type BacktestHit struct {
+	Issue    string `json:"issue"`
+	DrawDate string `json:"draw_date"`
+	RowIndex int    `json:"row_index"`
+	Level    int    `json:"level"`
+	Prize    int64  `json:"prize"` // 元
+	PrizeFen Fen    `json:"prize_fen"`
+}
+
+const backtestMajorLevel = 3
+
+// backtestHandler 对给定号码逐期验奖，统计各奖级命中次数与假设收益
+func backtestHandler(c *gin.Context) {
+	req := BacktestRequest{Years: 1}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
+		return
+	}
+	spec, ok := specOf(req.Type)
+	verifier := selectVerifier(spec.Name)
+	if !ok || verifier == nil {
+		c.JSON(400, gin.H{"error": "不支持的彩种: " + req.Type})
+		return
+	}
+	if len(req.Tickets) == 0 {
+		c.JSON(400, gin.H{"error": "tickets 不能为空"})
+		return
+	}
+	if req.Years < 0 {
+		c.JSON(400, gin.H{"error": "years 不能为负数"})
+		return
+	}
+	var costPerDraw Fen
+	for i, t := range req.Tickets {
+		for _, z := range spec.Zones {
+			dan, tuo := zoneSelection(z, t)
+			if err := validateSelection(z, dan, tuo); err != nil {
+				c.JSON(400, gin.H{"error": fmt.Sprintf("第%d行: %v", i+1, err)})
+				return
+			}
+		}
+		costPerDraw += Fen(betCount(spec, t)) * betPrice * Fen(max(t.Multiplier, 1))
+	}
+
+	since := ""
+	if req.Years > 0 {
+		since = time.Now().In(chinaTime).AddDate(-req.Years, 0, 0).Format("2006-01-02")
+	}
+	levels := map[int]*BacktestLevel{}
+	var hits []BacktestHit
+	drawsTested, winningDraws := 0, 0
+	var won Fen
+	for _, d := range draws.History(spec.Name) {
+		// 没有开奖日期的记录无法判断是否在回测区间内，只在回测全部历史时计入
+		if since != "" && (d.DrawDate == "" || d.DrawDate < since) {
+			continue
+		}
+		drawsTested++
+		win := d.winning()
+		drawWon := false
+		for i, t := range req.Tickets {
+			level, prize, _ := verifier.Verify(t, win)
+			if prize <= 0 {
+				continue
+			}
+			prize *= Fen(max(t.Multiplier, 1))
+			drawWon = true
+			won += prize
+			if levels[level] == nil {
+				levels[level] = &BacktestLevel{Level: level, Name: levelName(level)}
+			}
+			levels[level].Hits++
+			levels[level].PrizeFen += prize
+			levels[level].Prize = levels[level].PrizeFen.Yuan()
+			if level <= backtestMajorLevel {
+				hits = append(hits, BacktestHit{Issue: d.Issue, DrawDate: d.DrawDate, RowIndex: i + 1, Level: level, Prize: prize.Yuan(), PrizeFen: prize})
+			}
+		}
+		if drawWon {
+			winningDraws++
+		}
+	}
+
+	levelList := make([]BacktestLevel, 0, len(levels))
+	for _, l := range levels {
+		levelList = append(levelList, *l)
+	}
+	sort.Slice(levelList, func(i, j int) bool { return levelList[i].Level < levelList[j].Level })
+	if hits == nil {
+		hits = []BacktestHit{}
+	}
+	spent := costPerDraw * Fen(drawsTested)
+	c.JSON(200, gin.H{
+		"type":          spec.Name,
+		"draws":         drawsTested,
+		"winning_draws": winningDraws,
+		"cost_per_draw": costPerDraw.Yuan(),
+		"spent":         spent.Yuan(),
+		"won":           won.Yuan(),
+		"net":           (won - spent).Yuan(),
+		"spent_fen":     spent,
+		"won_fen":       won,
+		"net_fen":       won - spent,
+		"levels":        levelList,
+		"major_hits":    hits,
+		"summary": fmt.Sprintf("这组号码在最近 %d 期中有 %d 期中奖，投入 %s，奖金 %s",
+			drawsTested, winningDraws, spent, won),
+	})
+}
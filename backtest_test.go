@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBacktestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	daysAgo := func(n int) string { return time.Now().In(chinaTime).AddDate(0, 0, -n).Format("2006-01-02") }
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "d1", DrawDate: daysAgo(10), Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}},
+		DrawRecord{Game: "双色球", Issue: "d2", DrawDate: daysAgo(12), Red: []string{"10", "11", "12", "13", "14", "15"}, Blue: []string{"07"}},
+		DrawRecord{Game: "双色球", Issue: "d3", DrawDate: daysAgo(14), Red: []string{"20", "21", "22", "23", "24", "25"}, Blue: []string{"16"}},
+		DrawRecord{Game: "双色球", Issue: "d4", DrawDate: daysAgo(3 * 365), Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}},
+		DrawRecord{Game: "双色球", Issue: "d5", Red: []string{"20", "21", "22", "23", "24", "25"}, Blue: []string{"16"}},
+	)
+	ticket := `{"red":["01","02","03","04","05","09"],"blue":["07"]}`
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		draws        int
+		winningDraws int
+		spent, won   Fen
+		majorHits    int
+	}{
+		{"默认回测最近一年", `{"type":"双色球","tickets":[` + ticket + `]}`, 200, 3, 2, 6 * Yuan, 3005 * Yuan, 1},
+		{"全部历史包括没有日期的记录", `{"type":"ssq","years":0,"tickets":[` + ticket + `]}`, 200, 5, 3, 10 * Yuan, 6005 * Yuan, 2},
+		{"倍投", `{"type":"双色球","tickets":[{"red":["01","02","03","04","05","09"],"blue":["07"],"multiplier":2}]}`, 200, 3, 2, 12 * Yuan, 6010 * Yuan, 1},
+		{"年数为负", `{"type":"双色球","years":-1,"tickets":[` + ticket + `]}`, 400, 0, 0, 0, 0, 0},
+		{"不支持的彩种", `{"type":"快乐8","tickets":[` + ticket + `]}`, 400, 0, 0, 0, 0, 0},
+		{"没有号码", `{"type":"双色球","tickets":[]}`, 400, 0, 0, 0, 0, 0},
+		{"号码超出范围", `{"type":"双色球","tickets":[{"red":["01","02","03","04","05","34"],"blue":["07"]}]}`, 400, 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/backtest", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			backtestHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Draws        int           `json:"draws"`
+				WinningDraws int           `json:"winning_draws"`
+				SpentFen     Fen           `json:"spent_fen"`
+				WonFen       Fen           `json:"won_fen"`
+				MajorHits    []BacktestHit `json:"major_hits"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Draws != tt.draws || resp.WinningDraws != tt.winningDraws || resp.SpentFen != tt.spent ||
+				resp.WonFen != tt.won || len(resp.MajorHits) != tt.majorHits {
+				t.Errorf("resp = %+v, want draws %d winning %d spent %s won %s major %d",
+					resp, tt.draws, tt.winningDraws, tt.spent, tt.won, tt.majorHits)
+			}
+		})
+	}
+}
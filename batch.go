@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// BATCH: ZIP 批量上传（门店日终整叠彩票）
+// ==========================================
+
+// 压缩包里按扩展名识别图片
+var zipImageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true, ".heic": true}
+
+// ScanBatch 一次 ZIP 上传，每张图片对应一个排队任务；结果从任务队列实时汇总
+type ScanBatch struct {
+	ID string `json:"batch_id"`
+	ScanOrigin
+	CreatedAt time.Time    `json:"created_at"`
+	Archive   string       `json:"archive"`
+	Items     []BatchEntry `json:"items"`
+}
+
+// BatchEntry 压缩包中的一张图片；解压失败的图片没有任务，只记录原因
+type BatchEntry struct {
+	FileName string `json:"file_name"`
+	JobID    string `json:"job_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type batchStore struct {
+	mu      sync.Mutex
+	dir     string
+	batches map[string]*ScanBatch
+}
+
+var scanBatches *batchStore
+
+func newBatchStore(dir string) (*batchStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &batchStore{dir: dir, batches: map[string]*ScanBatch{}}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, f := range files {
+		raw, err := readSealedFile(f)
+		if err != nil {
+			continue
+		}
+		var b ScanBatch
+		if err := json.Unmarshal(raw, &b); err != nil {
+			log.Printf("跳过损坏的批次文件 %s: %v", f, err)
+			continue
+		}
+		s.batches[b.ID] = &b
+	}
+	return s, nil
+}
+
+// Add 保存批次，先写临时文件再改名
+func (s *batchStore) Add(b *ScanBatch) error {
+	raw, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(s.dir, b.ID+".json")
+	if err := writeSealedFile(p+".tmp", raw); err != nil {
+		return err
+	}
+	if err := os.Rename(p+".tmp", p); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[b.ID] = b
+	return nil
+}
+
+func (s *batchStore) Get(id string) (*ScanBatch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[id]
+	return b, ok
+}
+
+// zipImageEntries 压缩包里的图片，跳过目录、隐藏文件和 macOS 附带的 __MACOSX
+func zipImageEntries(zr *zip.Reader) []*zip.File {
+	var entries []*zip.File
+	for _, zf := range zr.File {
+		name := zf.Name
+		if zf.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if zipImageExts[strings.ToLower(path.Ext(name))] {
+			entries = append(entries, zf)
+		}
+	}
+	return entries
+}
+
+// zipBatchHandler POST /api/v1/scan/zip，字段名 archive。
+// 解压后每张图片进入排队任务，立即返回批次编号，进度与结果通过 GET /api/v1/batches/:id 查询
+func zipBatchHandler(c *gin.Context) {
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "请上传名为 'archive' 的 ZIP 文件"})
+		return
+	}
+	if os.Getenv("GEMINI_API_KEY") == "" {
+		c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "读取文件失败: " + err.Error()})
+		return
+	}
+	defer f.Close()
+	zr, err := zip.NewReader(f, fh.Size)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "不是有效的 ZIP 文件: " + err.Error()})
+		return
+	}
+
+	// 防止压缩炸弹：限制图片张数、单张大小和解压总量
+	maxFiles := envInt("ZIP_MAX_FILES", 500)
+	maxImage := int64(envInt("ZIP_MAX_IMAGE_MB", 20)) << 20
+	maxTotal := int64(envInt("ZIP_MAX_TOTAL_MB", 500)) << 20
+	entries := zipImageEntries(zr)
+	if len(entries) == 0 {
+		c.JSON(400, gin.H{"error": "压缩包内没有图片"})
+		return
+	}
+	if len(entries) > maxFiles {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("压缩包内图片过多 (%d 张，上限 %d 张)", len(entries), maxFiles)})
+		return
+	}
+
+	origin := originOf(c)
+	batch := &ScanBatch{ID: newJobID(), ScanOrigin: origin, CreatedAt: time.Now(), Archive: fh.Filename}
+	var total int64
+	for _, zf := range entries {
+		entry := BatchEntry{FileName: zf.Name}
+		fileBytes, err := readZipEntry(zf, min(maxImage, maxTotal-total))
+		if err == nil {
+			total += int64(len(fileBytes))
+			var job *ScanJob
+			if job, err = jobQueue.Enqueue(fileBytes, origin); err == nil {
+				entry.JobID = job.ID
+			} else {
+				err = fmt.Errorf("排队失败: %v", err)
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		batch.Items = append(batch.Items, entry)
+	}
+	if err := scanBatches.Add(batch); err != nil {
+		c.JSON(500, gin.H{"error": "保存批次失败: " + err.Error()})
+		return
+	}
+	c.JSON(202, gin.H{"batch_id": batch.ID, "images": len(batch.Items), "status_url": "/api/v1/batches/" + batch.ID,
+		"report_url": "/api/v1/batches/" + batch.ID + "/report"})
+}
+
+// readZipEntry 解压一张图片，超过 limit 字节即报错
+func readZipEntry(zf *zip.File, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("解压总大小超过上限")
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("解压失败: %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("解压失败: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("图片过大")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("空文件")
+	}
+	return data, nil
+}
+
+// batchItems 从任务队列取批次内每张图片的当前状态和结果
+func batchItems(batch *ScanBatch) []BatchItem {
+	items := make([]BatchItem, 0, len(batch.Items))
+	for i, e := range batch.Items {
+		item := BatchItem{ImageIndex: i + 1, FileName: e.FileName, JobID: e.JobID, Error: e.Error, Status: JobFailed}
+		if job, ok := jobQueue.Get(e.JobID); ok {
+			item.Status, item.Results, item.ReviewID = job.Status, job.Results, job.ReviewID
+			item.Partial = failedCount(job.Results) > 0
+			if job.Error != "" {
+				item.Error = job.Error
+			}
+		} else if e.JobID != "" {
+			item.Error = "任务不存在"
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// batchStatusHandler GET /api/v1/batches/:id，汇总每张图片的任务状态、结果与总奖金；?format=card 附带卡片，?format=slip 附带展示单
+func batchStatusHandler(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	batch, ok := scanBatches.Get(id)
+	if !ok || batch.Tenant != tenantOf(c) {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("批次 %s 不存在", id)})
+		return
+	}
+
+	cat := catalogOf(c)
+	counts := map[string]int{}
+	items := batchItems(batch)
+	var all []VerificationResult
+	var total Fen
+	winning := 0
+	for i, item := range items {
+		counts[item.Status]++
+		imageWon := false
+		for _, r := range item.Results {
+			total += r.TotalPrize
+			imageWon = imageWon || r.TotalPrize > 0
+		}
+		if imageWon {
+			winning++
+		}
+		all = append(all, item.Results...)
+		item.Results, item.Error = presentResults(c, item.Results), cat.T(item.Error)
+		items[i] = item
+	}
+
+	status := JobDone
+	if counts[JobQueued]+counts[JobProcessing] > 0 {
+		status = JobProcessing
+	}
+	resp := gin.H{
+		"batch_id":        batch.ID,
+		"archive":         batch.Archive,
+		"created_at":      batch.CreatedAt,
+		"status":          status,
+		"images":          len(items),
+		"counts":          counts,
+		"winning_images":  winning,
+		"total_prize":     total.Yuan(),
+		"total_prize_fen": total,
+		"items":           items,
+	}
+	if c.Query("format") == "card" {
+		resp["card"] = presentCards(c, buildCardResponse(all))
+	}
+	if c.Query("format") == "slip" {
+		resp["slip"] = presentSlip(c, all)
+	}
+	c.JSON(200, resp)
+}
+
+// batchReportHandler GET /api/v1/batches/:id/report，批次汇总报告；?format=html 输出可打印页面
+func batchReportHandler(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	batch, ok := scanBatches.Get(id)
+	if !ok || batch.Tenant != tenantOf(c) {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("批次 %s 不存在", id)})
+		return
+	}
+	report := buildBatchReport("zip", "批量验奖汇总报告", batchItems(batch))
+	report.BatchID = batch.ID
+	respondReport(c, report, c.Query("format"))
+}
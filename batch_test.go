@@ -0,0 +1,284 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testZip 按文件名 → 内容打包
+func testZip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestZipImageEntries(t *testing.T) {
+	raw := testZip(t, map[string][]byte{
+		"a.jpg": {1}, "dir/B.PNG": {1}, "c.heic": {1}, "notes.txt": {1},
+		"__MACOSX/._a.jpg": {1}, "dir/.hidden.jpg": {1}, "empty/": nil,
+	})
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, zf := range zipImageEntries(zr) {
+		got[zf.Name] = true
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a.jpg", true},
+		{"dir/B.PNG", true},
+		{"c.heic", true},
+		{"notes.txt", false},
+		{"__MACOSX/._a.jpg", false},
+		{"dir/.hidden.jpg", false},
+		{"empty/", false},
+	}
+	for _, tt := range tests {
+		if got[tt.name] != tt.want {
+			t.Errorf("%s included = %v, want %v", tt.name, got[tt.name], tt.want)
+		}
+	}
+}
+
+func TestReadZipEntry(t *testing.T) {
+	raw := testZip(t, map[string][]byte{"a.jpg": []byte("0123456789"), "empty.jpg": nil})
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*zip.File{}
+	for _, zf := range zr.File {
+		files[zf.Name] = zf
+	}
+	tests := []struct {
+		name    string
+		file    string
+		limit   int64
+		wantErr string
+	}{
+		{"正常解压", "a.jpg", 10, ""},
+		{"超过单张上限", "a.jpg", 9, "图片过大"},
+		{"总量已用完", "a.jpg", 0, "解压总大小超过上限"},
+		{"空文件", "empty.jpg", 10, "空文件"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readZipEntry(files[tt.file], tt.limit)
+			if tt.wantErr == "" {
+				if err != nil || string(data) != "0123456789" {
+					t.Errorf("readZipEntry() = %q, %v", data, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestZipBatchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GEMINI_API_KEY", "test")
+	useTestQueue(t)
+
+	upload := func(archive []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if archive != nil {
+			fw, _ := mw.CreateFormFile("archive", "day.zip")
+			fw.Write(archive)
+		}
+		mw.Close()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/v1/scan/zip", &body)
+		c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+		c.Request.Header.Set("X-Tenant-ID", "shop-a")
+		zipBatchHandler(c)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		archive    []byte
+		maxFiles   string
+		wantStatus int
+		wantImages int
+	}{
+		{"两张图片一张空文件", testZip(t, map[string][]byte{"1.jpg": {1, 2}, "2.png": {3}, "3.jpg": nil, "readme.txt": {1}}), "", 202, 3},
+		{"没有上传文件", nil, "", 400, 0},
+		{"不是 ZIP", []byte("not a zip"), "", 400, 0},
+		{"压缩包里没有图片", testZip(t, map[string][]byte{"readme.txt": {1}}), "", 400, 0},
+		{"图片超过张数上限", testZip(t, map[string][]byte{"1.jpg": {1}, "2.jpg": {2}}), "1", 400, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ZIP_MAX_FILES", tt.maxFiles)
+			w := upload(tt.archive)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != 202 {
+				return
+			}
+			var resp struct {
+				BatchID string `json:"batch_id"`
+				Images  int    `json:"images"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Images != tt.wantImages {
+				t.Errorf("images = %d, want %d", resp.Images, tt.wantImages)
+			}
+			batch, ok := scanBatches.Get(resp.BatchID)
+			if !ok || batch.Tenant != "shop-a" {
+				t.Fatalf("batch = %+v, %v", batch, ok)
+			}
+			jobs := 0
+			for _, e := range batch.Items {
+				if e.JobID != "" {
+					jobs++
+				}
+			}
+			if jobs != 2 {
+				t.Errorf("queued jobs = %d, want 2", jobs)
+			}
+		})
+	}
+}
+
+func TestBatchStatusHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestQueue(t)
+	job, err := jobQueue.Enqueue([]byte{1}, ScanOrigin{Tenant: "shop-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := &ScanBatch{ID: "b1", ScanOrigin: ScanOrigin{Tenant: "shop-a"}, Items: []BatchEntry{
+		{FileName: "1.jpg", JobID: job.ID},
+		{FileName: "2.jpg", Error: "空文件"},
+		{FileName: "3.jpg", JobID: "missing"},
+	}}
+	if err := scanBatches.Add(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		wantStatus int
+		counts     map[string]int
+	}{
+		{"按任务状态汇总", "shop-a", 200, map[string]int{JobQueued: 1, JobFailed: 2}},
+		{"其他租户看不到", "shop-b", 404, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/batches/b1", nil)
+			c.Request.Header.Set("X-Tenant-ID", tt.tenant)
+			c.Params = gin.Params{{Key: "id", Value: "b1"}}
+			batchStatusHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Status string         `json:"status"`
+				Counts map[string]int `json:"counts"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Status != JobProcessing {
+				t.Errorf("status = %q, want %q", resp.Status, JobProcessing)
+			}
+			for k, v := range tt.counts {
+				if resp.Counts[k] != v {
+					t.Errorf("counts = %v, want %v", resp.Counts, tt.counts)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchReportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestQueue(t)
+	done, err := jobQueue.Enqueue([]byte{1}, ScanOrigin{Tenant: "shop-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobQueue.update(done.ID, func(job *ScanJob) {
+		job.Status = JobDone
+		job.Results = []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107"}, TotalPrize: 5 * Yuan,
+			Details: []ResultDetail{{Level: 6, Prize: 5 * Yuan}}}}
+	})
+	batch := &ScanBatch{ID: "b-report", ScanOrigin: ScanOrigin{Tenant: "shop-a"}, Items: []BatchEntry{
+		{FileName: "1.jpg", JobID: done.ID},
+		{FileName: "2.jpg", Error: "空文件"},
+	}}
+	if err := scanBatches.Add(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		query      string
+		wantStatus int
+		wantType   string
+	}{
+		{"JSON", "shop-a", "", 200, "application/json"},
+		{"HTML", "shop-a", "?format=html", 200, "text/html"},
+		{"其他租户看不到", "shop-b", "", 404, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/batches/b-report/report"+tt.query, nil)
+			c.Request.Header.Set("X-Tenant-ID", tt.tenant)
+			c.Params = gin.Params{{Key: "id", Value: "b-report"}}
+			batchReportHandler(c)
+			if w.Code != tt.wantStatus || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.wantType) {
+				t.Fatalf("response = %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+			}
+			if tt.wantType == "text/html" && !strings.Contains(w.Body.String(), "b-report") {
+				t.Errorf("page missing batch id")
+			}
+			if w.Code != 200 || tt.wantType != "application/json" {
+				return
+			}
+			var report BatchReport
+			json.Unmarshal(w.Body.Bytes(), &report)
+			if report.BatchID != "b-report" || report.Source != "zip" || report.Images != 2 || report.ShopPayable != 5*Yuan ||
+				len(report.Exceptions) != 1 || report.Exceptions[0].Detail != "空文件" {
+				t.Errorf("report = %s", w.Body)
+			}
+		})
+	}
+}
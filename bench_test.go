@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+// ==========================================
+// BENCH: 验奖与解析基准测试
+// ==========================================
+
+// go test -run '^$' -bench . -benchmem，用 benchstat 对比前后两次输出
+
+var benchWin = WinningNumbers{
+	Red:  []string{"02", "11", "15", "21", "28", "33"},
+	Blue: []string{"07"},
+}
+
+// 模型典型输出：数字与字符串混用、带代码块围栏
+const benchOCRText = "```json\n" + `[{"type":"双色球","issue":"2025107","tickets":[
+{"red":["02","11","15","21","28","33"],"blue":["07"],"multiplier":1,"mode":"单式"},
+{"red":[3,8,12,19,24,30],"blue":[5],"multiplier":2,"mode":"单式"},
+{"red":["01","05","09","14","22","27","31","32"],"blue":["07","12"],"multiplier":1,"mode":"复式"},
+{"red":["06","10","13","18","25","29"],"blue":["16"],"multiplier":5,"mode":"单式"},
+{"red":[4,7,11,20,26,33],"blue":[1],"multiplier":1,"mode":"单式"}]}]` + "\n```"
+
+func BenchmarkDoubleColorSingle(b *testing.B) {
+	v := &DoubleColorVerifier{}
+	t := UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.Verify(t, benchWin)
+	}
+}
+
+func BenchmarkDoubleColorCompound10x3(b *testing.B) {
+	v := &DoubleColorVerifier{}
+	t := UserTicket{
+		Red:  []string{"01", "02", "05", "11", "15", "19", "21", "26", "28", "33"},
+		Blue: []string{"03", "07", "12"}, Multiplier: 1,
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.Verify(t, benchWin)
+	}
+}
+
+func BenchmarkLottoSingle(b *testing.B) {
+	v := &LottoVerifier{}
+	t := UserTicket{Red: []string{"03", "09", "17", "24", "35"}, Blue: []string{"02", "11"}, Multiplier: 1}
+	win := WinningNumbers{Red: []string{"03", "09", "17", "22", "31"}, Blue: []string{"02", "08"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.Verify(t, win)
+	}
+}
+
+func BenchmarkPermutation5(b *testing.B) {
+	v := &Permutation5Verifier{}
+	t := UserTicket{Red: []string{"1", "2", "3", "4", "5"}, Multiplier: 1}
+	win := WinningNumbers{Red: []string{"1", "2", "3", "4", "5"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.Verify(t, win)
+	}
+}
+
+func BenchmarkParseOCRText(b *testing.B) {
+	b.SetBytes(int64(len(benchOCRText)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseOCRText(benchOCRText); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyCacheKey(b *testing.B) {
+	data, err := parseOCRText(benchOCRText)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		verifyCacheKey(data[0])
+	}
+}
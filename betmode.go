@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ==========================================
+// BETMODE: 投注方式与选号方式
+// ==========================================
+
+// 投注方式，同时用作票面 mode 的归一值
+const (
+	BetSingle   = "单式"
+	BetCompound = "复式"
+	BetDanTuo   = "胆拖"
+)
+
+// 选号方式
+const (
+	PickQuick  = "机选"
+	PickManual = "自选"
+)
+
+const betModeWarning = "BET_MODE_MISMATCH"
+
+// normalizeBetMode 从票面标注（如 "复式 机选"、"胆拖投注"）中取出投注方式，认不出时返回空
+func normalizeBetMode(s string) string {
+	switch {
+	case strings.Contains(s, "胆拖") || strings.Contains(s, "胆"):
+		return BetDanTuo
+	case strings.Contains(s, "复式"):
+		return BetCompound
+	case strings.Contains(s, "单式"):
+		return BetSingle
+	}
+	return ""
+}
+
+// normalizePickMethod 机选 / 自选，认不出时返回空
+func normalizePickMethod(s string) string {
+	switch {
+	case strings.Contains(s, "机选"):
+		return PickQuick
+	case strings.Contains(s, "自选") || strings.Contains(s, "手选"):
+		return PickManual
+	}
+	return ""
+}
+
+// betModeSupporter 验奖器声明自己能正确处理的投注方式；未实现的验奖器视为都支持
+type betModeSupporter interface {
+	SupportsBetMode(mode string) bool
+}
+
+func (v *DoubleColorVerifier) SupportsBetMode(string) bool { return true }
+
+// 大乐透验奖器按整行号码求交集，复式/胆拖会算错奖级
+func (v *LottoVerifier) SupportsBetMode(mode string) bool { return mode == BetSingle }
+
+func (v *Permutation5Verifier) SupportsBetMode(mode string) bool { return mode == BetSingle }
+
+func (v *DSLVerifier) SupportsBetMode(mode string) bool { return !v.def.Ordered || mode == BetSingle }
+
+// checkBetMode 按号码结构判断投注方式，并与票面标注、验奖器能力比对；不一致时返回错误，该行不验奖
+func checkBetMode(lotteryType string, row int, t UserTicket, verifier Verifier) (string, error) {
+	detected := betTypeOf(lotteryType, t)
+	if printed := normalizeBetMode(t.Mode); printed != "" && printed != detected {
+		return detected, fmt.Errorf("第%d行票面标注%s，识别出的号码却是%s", row, printed, detected)
+	}
+	if s, ok := verifier.(betModeSupporter); ok && !s.SupportsBetMode(detected) {
+		return detected, fmt.Errorf("第%d行为%s投注，%s验奖暂不支持", row, detected, canonicalGame(lotteryType))
+	}
+	return detected, nil
+}
+
+// betModeWarnings 整张票的投注方式校验提示，结果可缓存，在验奖缓存命中时也要附上
+func betModeWarnings(lottery LotteryData, verifier Verifier) []string {
+	if verifier == nil {
+		return nil
+	}
+	var out []string
+	for i, t := range lottery.Tickets {
+		if _, err := checkBetMode(lottery.Type, i+1, t, verifier); err != nil {
+			out = append(out, betModeWarning+": "+err.Error())
+		}
+	}
+	return out
+}
+
+// pickMethodOf 优先取 pick_method，没有时从 mode 文字里找
+func pickMethodOf(t UserTicket) string {
+	if m := normalizePickMethod(t.PickMethod); m != "" {
+		return m
+	}
+	return normalizePickMethod(t.Mode)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBetMode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"单式", BetSingle},
+		{"复式 机选", BetCompound},
+		{"胆拖投注", BetDanTuo},
+		{"红胆", BetDanTuo},
+		{"自选", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeBetMode(tt.in); got != tt.want {
+			t.Errorf("normalizeBetMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPickMethodOf(t *testing.T) {
+	tests := []struct {
+		name string
+		t    UserTicket
+		want string
+	}{
+		{"pick_method 优先", UserTicket{PickMethod: "机选", Mode: "自选"}, PickQuick},
+		{"从 mode 里找", UserTicket{Mode: "单式 自选"}, PickManual},
+		{"手选算自选", UserTicket{PickMethod: "手选"}, PickManual},
+		{"未标注", UserTicket{Mode: "单式"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickMethodOf(tt.t); got != tt.want {
+				t.Errorf("pickMethodOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBetMode(t *testing.T) {
+	single := UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}}
+	compound := UserTicket{Red: []string{"01", "02", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}
+	lottoCompound := UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"01", "02"}}
+	tests := []struct {
+		name     string
+		game     string
+		ticket   UserTicket
+		verifier Verifier
+		wantType string
+		wantErr  string
+	}{
+		{"单式", "双色球", single, &DoubleColorVerifier{}, BetSingle, ""},
+		{"复式", "双色球", compound, &DoubleColorVerifier{}, BetCompound, ""},
+		{"标注与号码不符", "双色球", withMode(single, "复式"), &DoubleColorVerifier{}, BetSingle, "票面标注复式"},
+		{"验奖器不支持复式", "大乐透", lottoCompound, &LottoVerifier{}, BetCompound, "暂不支持"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkBetMode(tt.game, 1, tt.ticket, tt.verifier)
+			if got != tt.wantType {
+				t.Errorf("bet type = %q, want %q", got, tt.wantType)
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func withMode(t UserTicket, mode string) UserTicket {
+	t.Mode = mode
+	return t
+}
+
+// 号码相同、选号方式不同的两张票不能共用验奖缓存，否则回显的是先到那张的机选/自选
+func TestVerifyCachedPickMethod(t *testing.T) {
+	ticket := func(pick string) LotteryData {
+		return LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+			{Red: []string{"02", "11", "15", "21", "01", "04"}, Blue: []string{"07"}, Multiplier: 1, PickMethod: pick},
+		}}
+	}
+	for _, pick := range []string{PickQuick, PickManual, PickQuick} {
+		b := newRequestBudget(context.Background())
+		res, err := verifyLottery(b, 0, ticket(pick))
+		b.Done()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.Details[0].PickMethod; got != pick {
+			t.Errorf("pick_method = %q, want %q（cached=%v）", got, pick, res.Cached)
+		}
+	}
+	if verifyCacheKey(ticket(PickQuick)) == verifyCacheKey(ticket(PickManual)) {
+		t.Error("选号方式不同的票缓存键相同")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// BILLING: 按租户的月度用量（计费对账）
+// ==========================================
+
+// 托管部署按租户向客户收费。每个租户按北京时间的自然月累计：
+//
+//	scans / tickets      完成验奖的图片数与票数（重复扫描同样计数）
+//	tokens / spend       识别服务的 Token 用量与估算费用（命中识别缓存的不计）
+//	notifications        实际发出的通知条数，按渠道（wecom、sms、email…）分开
+//	storage_bytes        该月底仍保存在服务端的原图与缩略图大小（STORE_IMAGES=true 时才有）
+//
+// 累计结果存在 data/billing.json，重启不清零。管理员通过
+// GET /api/v1/admin/billing?month=2025-09（可带 &tenant=）查询，?format=csv 导出对账单
+
+// TenantUsage 某租户某月的用量
+type TenantUsage struct {
+	Tenant        string         `json:"tenant"`
+	Scans         int            `json:"scans"`
+	Tickets       int            `json:"tickets"`
+	PromptTokens  int64          `json:"prompt_tokens"`
+	OutputTokens  int64          `json:"output_tokens"`
+	Spend         float64        `json:"spend"` // 元，按 OCR_MODEL_PRICES 估算
+	Notifications map[string]int `json:"notifications,omitempty"`
+	StorageBytes  int64          `json:"storage_bytes"`
+}
+
+// NotificationTotal 各渠道通知条数合计
+func (u TenantUsage) NotificationTotal() int {
+	n := 0
+	for _, v := range u.Notifications {
+		n += v
+	}
+	return n
+}
+
+// billingMeter 月份 → 租户 → 用量
+type billingMeter struct {
+	mu     sync.Mutex
+	path   string
+	months map[string]map[string]*TenantUsage
+}
+
+var billing = &billingMeter{months: map[string]map[string]*TenantUsage{}}
+
+// Load 读取历史累计，文件不存在时从零开始
+func (m *billingMeter) Load(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.path = path
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &m.months)
+}
+
+// save 调用方需持有锁；写失败只记日志
+func (m *billingMeter) save() {
+	if m.path == "" {
+		return
+	}
+	raw, err := json.Marshal(m.months)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(m.path+".tmp", raw, 0o644); err != nil {
+		log.Printf("保存计费用量失败: %v", err)
+		return
+	}
+	if err := os.Rename(m.path+".tmp", m.path); err != nil {
+		log.Printf("保存计费用量失败: %v", err)
+	}
+}
+
+func billingMonth(t time.Time) string { return t.In(chinaTime).Format("2006-01") }
+
+func (m *billingMeter) update(tenant string, fn func(u *TenantUsage)) {
+	if tenant == "" {
+		tenant = "default"
+	}
+	month := billingMonth(time.Now())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenants, ok := m.months[month]
+	if !ok {
+		tenants = map[string]*TenantUsage{}
+		m.months[month] = tenants
+	}
+	u, ok := tenants[tenant]
+	if !ok {
+		u = &TenantUsage{Tenant: tenant}
+		tenants[tenant] = u
+	}
+	fn(u)
+	m.save()
+}
+
+// AddScan 一张图片完成验奖
+func (m *billingMeter) AddScan(tenant string, tickets int) {
+	m.update(tenant, func(u *TenantUsage) {
+		u.Scans++
+		u.Tickets += tickets
+	})
+}
+
+// AddTokens 一次识别调用的用量
+func (m *billingMeter) AddTokens(tenant, model string, prompt, output int64) {
+	m.update(tenant, func(u *TenantUsage) {
+		u.PromptTokens += prompt
+		u.OutputTokens += output
+		u.Spend += modelCost(model, prompt, output)
+	})
+}
+
+// AddNotification 一条通知发送成功
+func (m *billingMeter) AddNotification(tenant, channel string) {
+	m.update(tenant, func(u *TenantUsage) {
+		if u.Notifications == nil {
+			u.Notifications = map[string]int{}
+		}
+		u.Notifications[channel]++
+	})
+}
+
+// Month 某月全部租户的用量（副本），按租户名排序
+func (m *billingMeter) Month(month string) []TenantUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []TenantUsage
+	for _, u := range m.months[month] {
+		cp := *u
+		cp.Notifications = map[string]int{}
+		for k, v := range u.Notifications {
+			cp.Notifications[k] = v
+		}
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out
+}
+
+type billingTenantKey struct{}
+
+// withBillingTenant 识别调用的 Token 记到哪个租户，沿 recognizeCached 传递
+func withBillingTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, billingTenantKey{}, tenant)
+}
+
+func billingTenantFrom(ctx context.Context) string {
+	if t, ok := ctx.Value(billingTenantKey{}).(string); ok {
+		return t
+	}
+	return "default"
+}
+
+// billingContext 把请求租户写入上下文，需在 apiKeyAuth 之后（Key 会覆盖租户）
+func billingContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(withBillingTenant(c.Request.Context(), tenantOf(c)))
+		c.Next()
+	}
+}
+
+// StorageBytes 各租户在 end 之前扫描、目前仍保存着的图片大小；同一租户同一张图只算一次
+func (s *historyStore) StorageBytes(end time.Time) map[string]int64 {
+	s.mu.RLock()
+	seen := map[string]map[string]bool{}
+	for _, r := range s.records {
+		if r.ImageHash == "" || !r.Time.Before(end) {
+			continue
+		}
+		if seen[r.Tenant] == nil {
+			seen[r.Tenant] = map[string]bool{}
+		}
+		seen[r.Tenant][r.ImageHash] = true
+	}
+	s.mu.RUnlock()
+
+	out := map[string]int64{}
+	for tenant, hashes := range seen {
+		for hash := range hashes {
+			for _, p := range []string{images.OriginalPath(hash), images.ThumbnailPath(hash)} {
+				if p == "" {
+					continue
+				}
+				if info, err := os.Stat(p); err == nil {
+					out[tenant] += info.Size()
+				}
+			}
+		}
+	}
+	return out
+}
+
+// billingReport 某月的用量报表，补上存储占用；只有存储占用的租户也列出
+func billingReport(month time.Time, tenant string) []TenantUsage {
+	end := month.AddDate(0, 1, 0)
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	list := billing.Month(billingMonth(month))
+	storage := scanHistory.StorageBytes(end)
+	listed := map[string]bool{}
+	for i := range list {
+		list[i].StorageBytes = storage[list[i].Tenant]
+		listed[list[i].Tenant] = true
+	}
+	for t, n := range storage {
+		if !listed[t] && n > 0 {
+			list = append(list, TenantUsage{Tenant: t, StorageBytes: n})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tenant < list[j].Tenant })
+	if tenant == "" {
+		return list
+	}
+	for _, u := range list {
+		if u.Tenant == tenant {
+			return []TenantUsage{u}
+		}
+	}
+	return []TenantUsage{{Tenant: tenant}}
+}
+
+// billingHandler GET /api/v1/admin/billing?month=2025-09&tenant=shop-a，?format=csv 导出
+func billingHandler(c *gin.Context) {
+	now := time.Now().In(chinaTime)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, chinaTime)
+	if v := c.Query("month"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, chinaTime)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "month 格式应为 2006-01"})
+			return
+		}
+		month = t
+	}
+	list := billingReport(month, c.Query("tenant"))
+	label := month.Format("2006-01")
+
+	if c.Query("format") != "csv" {
+		if list == nil {
+			list = []TenantUsage{}
+		}
+		c.JSON(200, gin.H{"month": label, "tenants": list})
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff") // Excel 按 UTF-8 打开
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"月份", "租户", "扫描次数", "票数", "输入Token", "输出Token", "识别费用(元)", "通知条数", "短信", "邮件", "存储(MB)"})
+	for _, u := range list {
+		w.Write([]string{
+			label, u.Tenant, strconv.Itoa(u.Scans), strconv.Itoa(u.Tickets),
+			strconv.FormatInt(u.PromptTokens, 10), strconv.FormatInt(u.OutputTokens, 10),
+			strconv.FormatFloat(u.Spend, 'f', 2, 64), strconv.Itoa(u.NotificationTotal()),
+			strconv.Itoa(u.Notifications["sms"]), strconv.Itoa(u.Notifications["email"]),
+			strconv.FormatFloat(float64(u.StorageBytes)/(1<<20), 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	name := fmt.Sprintf("billing-%s.csv", month.Format("200601"))
+	if t := c.Query("tenant"); t != "" {
+		name = fmt.Sprintf("billing-%s-%s.csv", t, month.Format("200601"))
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filepath.Base(name)+`"`)
+	c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestBilling 换成落盘到临时目录的空计量
+func useTestBilling(t *testing.T) string {
+	t.Helper()
+	old := billing
+	t.Cleanup(func() { billing = old })
+	billing = &billingMeter{months: map[string]map[string]*TenantUsage{}}
+	path := filepath.Join(t.TempDir(), "billing.json")
+	if err := billing.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBillingMeter(t *testing.T) {
+	path := useTestBilling(t)
+	t.Setenv("OCR_MODEL_PRICES", "")
+	billing.AddScan("shop-a", 2)
+	billing.AddScan("shop-a", 1)
+	billing.AddScan("", 1)
+	billing.AddTokens("shop-a", "gemini-2.5-flash", 1e6, 0)
+	billing.AddNotification("shop-a", "sms")
+	billing.AddNotification("shop-a", "sms")
+	billing.AddNotification("shop-a", "email")
+
+	// 重启后从文件恢复
+	reloaded := &billingMeter{months: map[string]map[string]*TenantUsage{}}
+	if err := reloaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	month := billingMonth(time.Now())
+	for _, m := range []*billingMeter{billing, reloaded} {
+		got := map[string]TenantUsage{}
+		for _, u := range m.Month(month) {
+			got[u.Tenant] = u
+		}
+		tests := []struct {
+			name   string
+			tenant string
+			want   TenantUsage
+		}{
+			{"扫描、Token 与通知", "shop-a", TenantUsage{Scans: 2, Tickets: 3, PromptTokens: 1e6, Spend: 2.2}},
+			{"未指定租户记到 default", "default", TenantUsage{Scans: 1, Tickets: 1}},
+		}
+		for _, tt := range tests {
+			u := got[tt.tenant]
+			if u.Scans != tt.want.Scans || u.Tickets != tt.want.Tickets || u.PromptTokens != tt.want.PromptTokens || u.Spend != tt.want.Spend {
+				t.Errorf("%s: usage = %+v, want %+v", tt.name, u, tt.want)
+			}
+		}
+		if u := got["shop-a"]; u.NotificationTotal() != 3 || u.Notifications["sms"] != 2 {
+			t.Errorf("notifications = %v", u.Notifications)
+		}
+	}
+	if got := billing.Month("2000-01"); len(got) != 0 {
+		t.Errorf("Month(2000-01) = %v", got)
+	}
+}
+
+// Token 记到请求租户：billingContext 取 Key 覆盖后的租户写入上下文
+func TestBillingContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestAPIKeys(t, APIKey{Key: "sk-a", Tenant: "shop-a"})
+	r := gin.New()
+	r.Use(apiKeyAuth(), billingContext())
+	r.GET("/", func(c *gin.Context) { c.String(200, billingTenantFrom(c.Request.Context())) })
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"请求头租户", map[string]string{"X-Tenant-ID": "shop-b"}, "shop-b"},
+		{"Key 的租户", map[string]string{"X-API-Key": "sk-a", "X-Tenant-ID": "shop-b"}, "shop-a"},
+		{"默认租户", nil, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Body.String() != tt.want {
+				t.Errorf("billing tenant = %q, want %q", w.Body, tt.want)
+			}
+		})
+	}
+	if got := billingTenantFrom(context.Background()); got != "default" {
+		t.Errorf("billingTenantFrom(empty) = %q", got)
+	}
+}
+
+func TestBillingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestBilling(t)
+	old := scanHistory
+	t.Cleanup(func() { scanHistory = old })
+	scanHistory = &historyStore{}
+	billing.AddScan("shop-b", 1)
+	billing.AddScan("shop-a", 2)
+	billing.AddNotification("shop-a", "email")
+	month := billingMonth(time.Now())
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantTenants []string
+		wantScans   []int
+	}{
+		{"默认本月全部租户", "", 200, []string{"shop-a", "shop-b"}, []int{1, 1}},
+		{"按租户过滤", "?tenant=shop-b&month=" + month, 200, []string{"shop-b"}, []int{1}},
+		{"租户没有用量时返回空行", "?tenant=shop-c", 200, []string{"shop-c"}, []int{0}},
+		{"其他月份", "?month=2000-01", 200, nil, nil},
+		{"月份格式错误", "?month=2025-9-1", 400, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/admin/billing"+tt.query, nil)
+			billingHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Tenants []TenantUsage `json:"tenants"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Tenants == nil {
+				t.Fatalf("body = %s: %v", w.Body, err)
+			}
+			var tenants []string
+			var scans []int
+			for _, u := range resp.Tenants {
+				tenants, scans = append(tenants, u.Tenant), append(scans, u.Scans)
+			}
+			if strings.Join(tenants, ",") != strings.Join(tt.wantTenants, ",") || len(scans) != len(tt.wantScans) {
+				t.Fatalf("tenants = %v, want %v", tenants, tt.wantTenants)
+			}
+			for i := range scans {
+				if scans[i] != tt.wantScans[i] {
+					t.Errorf("%s scans = %d, want %d", tenants[i], scans[i], tt.wantScans[i])
+				}
+			}
+		})
+	}
+
+	// CSV 对账单：每个租户一行，文件名带租户和月份
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/admin/billing?format=csv&tenant=shop-a", nil)
+	billingHandler(c)
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "\ufeff")), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "月份,租户") || lines[1] != month+",shop-a,1,2,0,0,0.00,1,0,1,0.00" {
+		t.Errorf("csv = %q", w.Body.String())
+	}
+	want := "billing-shop-a-" + strings.ReplaceAll(month, "-", "") + ".csv"
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, want) {
+		t.Errorf("Content-Disposition = %q, want %s", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// BREAKDOWN: 复式 / 胆拖逐注明细（?detail=full）
+// ==========================================
+
+// BetDetail 拆出的一注单式及其奖金（已乘倍数，与行奖金口径一致）
+type BetDetail struct {
+	Red   []string `json:"red"`
+	Blue  []string `json:"blue"`
+	Level int      `json:"level"`
+	Prize Fen      `json:"prize_fen"`
+}
+
+// BetBreakdown 一行投注的逐注明细；注数超过 DETAIL_MAX_BETS（默认 200）时只列前 200 个中奖注，其余按奖级汇总
+type BetBreakdown struct {
+	TotalBets   int         `json:"total_bets"`
+	WinningBets int         `json:"winning_bets"`
+	ByLevel     map[int]int `json:"by_level,omitempty"` // 奖级 → 中奖注数
+	Bets        []BetDetail `json:"bets"`
+	Truncated   bool        `json:"truncated,omitempty"`
+}
+
+// detailFullOf ?detail=full
+func detailFullOf(c *gin.Context) bool {
+	return c.Query("detail") == "full"
+}
+
+// expandBets 按彩种规则把一行拆成单式；排列类和不认识的彩种不拆
+func expandBets(lotteryType string, t UserTicket) []UserTicket {
+	spec, ok := specOf(lotteryType)
+	if !ok || spec.Ordered {
+		return []UserTicket{t}
+	}
+	out := []UserTicket{{Multiplier: t.Multiplier, AddOn: t.AddOn}}
+	for _, z := range spec.Zones {
+		dan, tuo := zoneSelection(z, t)
+		var next []UserTicket
+		for _, comb := range zoneCombinations(dan, tuo, z.Pick) {
+			for _, b := range out {
+				if z.Field == "blue" {
+					b.Blue = comb
+				} else {
+					b.Red = comb
+				}
+				next = append(next, b)
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+// betBreakdown 逐注验奖；只有一注的行返回 nil，注数超过 DETAIL_MAX_EXPAND（默认 100000）时只返回注数
+func betBreakdown(lotteryType string, t UserTicket, win WinningNumbers, verifier Verifier) *BetBreakdown {
+	if spec, ok := specOf(lotteryType); ok && !spec.Ordered {
+		if n := betCount(spec, t); n > int64(envInt("DETAIL_MAX_EXPAND", 100000)) {
+			return &BetBreakdown{TotalBets: int(n), Truncated: true}
+		}
+	}
+	bets := expandBets(lotteryType, t)
+	if len(bets) <= 1 {
+		return nil
+	}
+	limit := envInt("DETAIL_MAX_BETS", 200)
+	bd := &BetBreakdown{TotalBets: len(bets), ByLevel: map[int]int{}, Truncated: len(bets) > limit}
+	for _, bet := range bets {
+		level, prize, _ := verifier.Verify(bet, win)
+		prize *= Fen(t.Multiplier)
+		if prize > 0 {
+			bd.WinningBets++
+			bd.ByLevel[level]++
+		}
+		if (prize > 0 || !bd.Truncated) && len(bd.Bets) < limit {
+			bd.Bets = append(bd.Bets, BetDetail{Red: bet.Red, Blue: bet.Blue, Level: level, Prize: prize})
+		}
+	}
+	return bd
+}
+
+// addBetBreakdown 给已开奖的多注行附上逐注明细。Details 可能与验奖缓存共用底层数组，先复制再写
+func addBetBreakdown(results []VerificationResult) {
+	for i := range results {
+		res := &results[i]
+		if len(res.Sections) > 0 {
+			res.Sections = append([]VerificationResult(nil), res.Sections...)
+			addBetBreakdown(res.Sections)
+		}
+		verifier := selectVerifier(res.OCRData.Type)
+		if verifier == nil || res.Pending || res.Rejected {
+			continue
+		}
+		win, drawn := res.winning()
+		if !drawn {
+			continue
+		}
+		details := append([]ResultDetail(nil), res.Details...)
+		for j := range details {
+			row := details[j].RowIndex - 1
+			if row < 0 || row >= len(res.OCRData.Tickets) {
+				continue
+			}
+			details[j].Breakdown = betBreakdown(strings.TrimSpace(res.OCRData.Type), res.OCRData.Tickets[row], win, verifier)
+		}
+		res.Details = details
+	}
+}
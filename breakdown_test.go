@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestExpandBets(t *testing.T) {
+	tests := []struct {
+		name   string
+		game   string
+		ticket UserTicket
+		want   int
+	}{
+		{"单式", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}}, 1},
+		{"红球复式 8+1", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06", "07", "08"}, Blue: []string{"07"}}, 28},
+		{"蓝球复式 6+3", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"01", "02", "03"}}, 3},
+		{"胆拖 2 胆 5 拖", "双色球", UserTicket{Dan: []string{"01", "02"}, Red: []string{"03", "04", "05", "06", "07"}, Blue: []string{"07"}}, 5},
+		{"大乐透后区胆拖", "大乐透", UserTicket{Red: []string{"01", "02", "03", "04", "05"}, BlueDan: []string{"01"}, Blue: []string{"02", "03", "04"}}, 3},
+		{"按位玩法不拆", "排列5", UserTicket{Red: []string{"1", "2", "3", "4", "5"}}, 1},
+		{"不认识的彩种不拆", "未知彩种", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06", "07"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bets := expandBets(tt.game, tt.ticket)
+			if len(bets) != tt.want {
+				t.Fatalf("expandBets() = %d bets, want %d", len(bets), tt.want)
+			}
+			if len(bets) > 1 && len(bets[0].Dan)+len(bets[0].BlueDan) > 0 {
+				t.Errorf("expanded bet still has dan: %+v", bets[0])
+			}
+		})
+	}
+}
+
+func TestBetBreakdown(t *testing.T) {
+	win := WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	compound := UserTicket{Red: []string{"02", "11", "15", "21", "28", "33", "01"}, Blue: []string{"07"}, Multiplier: 2}
+	tests := []struct {
+		name        string
+		ticket      UserTicket
+		maxBets     string
+		maxExpand   string
+		wantNil     bool
+		wantTotal   int
+		wantWinning int
+		wantListed  int
+		wantTrunc   bool
+		wantPrize   Fen // 列出的第一注奖金，已乘倍数
+	}{
+		{"单式不拆", UserTicket{Red: win.Red, Blue: win.Blue, Multiplier: 1}, "", "", true, 0, 0, 0, false, 0},
+		{"7+1 复式全部列出", compound, "", "", false, 7, 7, 7, false, 2 * 5000000 * Yuan},
+		{"超过条数只列中奖注", UserTicket{Red: []string{"02", "11", "01", "03", "04", "05", "06", "08"}, Blue: []string{"09"}, Multiplier: 1}, "3", "", false, 28, 0, 0, true, 0},
+		{"超过条数按上限截断", compound, "3", "", false, 7, 7, 3, true, 2 * 5000000 * Yuan},
+		{"注数过多只返回注数", compound, "", "6", false, 7, 0, 0, true, 0},
+	}
+	v := &DoubleColorVerifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DETAIL_MAX_BETS", tt.maxBets)
+			t.Setenv("DETAIL_MAX_EXPAND", tt.maxExpand)
+			bd := betBreakdown("双色球", tt.ticket, win, v)
+			if tt.wantNil {
+				if bd != nil {
+					t.Errorf("betBreakdown() = %+v, want nil", bd)
+				}
+				return
+			}
+			if bd == nil {
+				t.Fatal("betBreakdown() = nil")
+			}
+			if bd.TotalBets != tt.wantTotal || bd.WinningBets != tt.wantWinning || len(bd.Bets) != tt.wantListed || bd.Truncated != tt.wantTrunc {
+				t.Errorf("betBreakdown() = total %d winning %d listed %d truncated %v", bd.TotalBets, bd.WinningBets, len(bd.Bets), bd.Truncated)
+			}
+			if len(bd.Bets) > 0 && bd.Bets[0].Prize != tt.wantPrize {
+				t.Errorf("bets[0].Prize = %s, want %s", bd.Bets[0].Prize, tt.wantPrize)
+			}
+		})
+	}
+}
+
+func TestAddBetBreakdown(t *testing.T) {
+	draw := DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	useTestDraws(t, draw)
+	compound := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1},
+		{Red: []string{"02", "11", "15", "21", "28", "33", "01"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	details := []ResultDetail{{RowIndex: 1}, {RowIndex: 2}}
+	tests := []struct {
+		name string
+		res  VerificationResult
+		want []bool // 每行是否有明细
+	}{
+		{"只给多注行附明细", VerificationResult{OCRData: compound, Details: details}, []bool{false, true}},
+		{"未开奖不附明细", VerificationResult{OCRData: compound, Details: details, Pending: true}, []bool{false, false}},
+		{"未通过校验不附明细", VerificationResult{OCRData: compound, Details: details, Rejected: true}, []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []VerificationResult{tt.res}
+			addBetBreakdown(results)
+			for i, d := range results[0].Details {
+				if (d.Breakdown != nil) != tt.want[i] {
+					t.Errorf("row %d breakdown = %+v, want %v", i+1, d.Breakdown, tt.want[i])
+				}
+			}
+			// 缓存里的 Details 不能被改写
+			for _, d := range details {
+				if d.Breakdown != nil {
+					t.Fatal("shared details modified")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// BREAKER: AI 服务熔断器
+// ==========================================
+
+// errCircuitOpen 熔断打开期间直接拒绝调用，不再等超时
+var errCircuitOpen = errors.New("AI 识别服务熔断中")
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// circuitBreaker 连续失败达到阈值后打开，冷却期过后放行一个探测请求（半开），
+// 探测成功则恢复，失败则重新打开
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	probing   bool
+}
+
+var ocrBreaker = newCircuitBreaker(envInt("OCR_BREAKER_FAILURES", 5), envDuration("OCR_BREAKER_COOLDOWN", 30*time.Second))
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &circuitBreaker{state: breakerClosed, threshold: threshold, cooldown: cooldown}
+}
+
+// Allow 判断当前是否允许发起调用
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		// 半开状态只允许一个探测请求在途
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Record 记录一次调用结果，failed 仅应对服务侧错误传 true
+func (b *circuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Finish 按调用结果记录：成功恢复，只有 AI 服务本身的错误算失败；调用方取消（客户端断开）
+// 或图片、解析等其他错误说明不了服务好坏，交还半开探测名额，既不恢复也不重新打开
+func (b *circuitBreaker) Finish(ctx context.Context, err error) {
+	if err == nil {
+		b.Record(false)
+		return
+	}
+	if errors.Is(err, errOCRProvider) && !errors.Is(ctx.Err(), context.Canceled) {
+		b.Record(true)
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// State 返回当前状态（不会触发半开转换）
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return breakerHalfOpen
+	}
+	return b.state
+}
+
+// callOCRWithBreaker 经过熔断器调用 AI 识别
+func callOCRWithBreaker(ctx context.Context, fileBytes []byte, apiKey string, temperature *float32) ([]LotteryData, error) {
+	if mockOCREnabled() {
+		return mockRecognize(ctx, fileBytes)
+	}
+	// 预算用完不是服务故障，不计入熔断
+	if err := checkSpendBudget(); err != nil {
+		return nil, err
+	}
+	if !ocrBreaker.Allow() {
+		return nil, errCircuitOpen
+	}
+	data, err := callGeminiOCR(ctx, fileBytes, apiKey, temperature)
+	ocrBreaker.Finish(ctx, err)
+	return data, err
+}
+
+// queueOnOutage 是否开启熔断期间的排队降级
+func queueOnOutage() bool {
+	return envBool("OCR_QUEUE_ON_OUTAGE", false)
+}
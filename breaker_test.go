@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		results   []bool // Record 的 failed 参数
+		wantState string
+	}{
+		{"未达阈值保持关闭", 3, []bool{true, true}, breakerClosed},
+		{"连续失败达到阈值打开", 3, []bool{true, true, true}, breakerOpen},
+		{"成功清零失败计数", 3, []bool{true, true, false, true, true}, breakerClosed},
+		{"阈值小于 1 按 1 处理", 0, []bool{true}, breakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(tt.threshold, time.Hour)
+			for _, failed := range tt.results {
+				b.Allow()
+				b.Record(failed)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("State() = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+// 图片、解析等其他错误不清零服务错误的计数
+func TestCircuitBreakerFinishOtherErrors(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+	ctx := context.Background()
+	for _, err := range []error{fmt.Errorf("%w: 503", errOCRProvider), errors.New("解析失败"), fmt.Errorf("%w: 503", errOCRProvider)} {
+		b.Allow()
+		b.Finish(ctx, err)
+	}
+	if got := b.State(); got != breakerOpen {
+		t.Errorf("State() = %s, want %s", got, breakerOpen)
+	}
+}
+
+// 冷却期过后只放行一个探测请求，探测结果决定恢复还是重新打开
+func TestCircuitBreakerProbe(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		err       error
+		wantState string
+		wantAllow bool // 探测结束后能否立即再放行一个请求
+	}{
+		{"探测成功恢复", context.Background(), nil, breakerClosed, true},
+		{"服务错误重新打开", context.Background(), fmt.Errorf("%w: 503", errOCRProvider), breakerOpen, false},
+		{"非服务错误不计成败", context.Background(), errors.New("解析失败"), breakerHalfOpen, true},
+		{"调用方取消不计成败", canceled, fmt.Errorf("%w: %v", errOCRProvider, context.Canceled), breakerHalfOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(1, time.Millisecond)
+			b.Allow()
+			b.Record(true)
+			time.Sleep(2 * time.Millisecond)
+			if !b.Allow() {
+				t.Fatal("冷却期过后应放行探测请求")
+			}
+			if b.Allow() {
+				t.Fatal("探测在途时不应再放行")
+			}
+			b.Finish(tt.ctx, tt.err)
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("State() = %s, want %s", got, tt.wantState)
+			}
+			if tt.wantState == breakerOpen {
+				b.openedAt = time.Now()
+			}
+			if got := b.Allow(); got != tt.wantAllow {
+				t.Errorf("Allow() = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// BUDGET: 单请求端到端超时预算
+// ==========================================
+
+const (
+	stagePreprocess = "preprocess"
+	stageOCR        = "ocr"
+	stageDraw       = "draw"
+	stageVerify     = "verify"
+)
+
+// 各阶段占总预算的比例，可用 REQUEST_BUDGET_SPLIT="preprocess=0.1,ocr=0.7,draw=0.1,verify=0.1" 覆盖
+var defaultStageShares = map[string]float64{
+	stagePreprocess: 0.1,
+	stageOCR:        0.7,
+	stageDraw:       0.1,
+	stageVerify:     0.1,
+}
+
+// budgetError 某个阶段用完了时间预算
+type budgetError struct {
+	Stage string
+	Err   error
+}
+
+func (e *budgetError) Error() string {
+	return fmt.Sprintf("处理超时（阶段: %s）", e.Stage)
+}
+
+func (e *budgetError) Unwrap() error { return e.Err }
+
+// requestBudget 总截止时间 + 各阶段分配；任何阶段的上限都不会超过总截止时间
+type requestBudget struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	total  time.Duration
+	shares map[string]float64
+}
+
+// newRequestBudget 总预算为 REQUEST_TIMEOUT，调用方 context 的截止时间（如路由超时）更早时以后者为准
+func newRequestBudget(parent context.Context) *requestBudget {
+	total := envDuration("REQUEST_TIMEOUT", 25*time.Second)
+	if deadline, ok := parent.Deadline(); ok {
+		total = min(total, time.Until(deadline))
+	}
+	ctx, cancel := context.WithTimeout(parent, total)
+	return &requestBudget{ctx: ctx, cancel: cancel, total: total, shares: stageShares()}
+}
+
+func stageShares() map[string]float64 {
+	shares := make(map[string]float64, len(defaultStageShares))
+	for k, v := range defaultStageShares {
+		shares[k] = v
+	}
+	for _, pair := range strings.Split(os.Getenv("REQUEST_BUDGET_SPLIT"), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f > 0 {
+			shares[strings.TrimSpace(k)] = f
+		}
+	}
+	return shares
+}
+
+// Context 返回整个请求的 context
+func (b *requestBudget) Context() context.Context { return b.ctx }
+
+// Done 释放总预算
+func (b *requestBudget) Done() { b.cancel() }
+
+// Stage 为某个阶段派生带超时的 context
+func (b *requestBudget) Stage(name string) (context.Context, context.CancelFunc) {
+	share, ok := b.shares[name]
+	if !ok {
+		share = 1
+	}
+	return context.WithTimeout(b.ctx, time.Duration(float64(b.total)*share))
+}
+
+// Check 阶段结束时检查是否已超时/被取消，超时时包装成 budgetError
+func (b *requestBudget) Check(ctx context.Context, stage string) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &budgetError{Stage: stage, Err: err}
+	}
+	// 阶段 context 已被调用方结束、整个请求仍有效时不算失败
+	if b.ctx.Err() == nil {
+		return nil
+	}
+	return err
+}
+
+// ctxReader 每次读取前检查 ctx，阶段预算用完后不再继续读
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ctxReadCloser 用 ctxReader 替换请求体时保留原来的 Close
+type ctxReadCloser struct {
+	ctxReader
+	io.Closer
+}
+
+// isTimeout 判断错误是否来自预算耗尽
+func isTimeout(err error) bool {
+	var be *budgetError
+	return errors.As(err, &be) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestBudgetCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(b *requestBudget) context.Context
+		wantErr    error
+		wantBudget bool // 是否包装为 budgetError
+	}{
+		{"阶段进行中", func(b *requestBudget) context.Context {
+			ctx, _ := b.Stage(stageOCR)
+			return ctx
+		}, nil, false},
+		{"调用方结束了自己的阶段", func(b *requestBudget) context.Context {
+			ctx, cancel := b.Stage(stagePreprocess)
+			cancel()
+			return ctx
+		}, nil, false},
+		{"阶段超时", func(b *requestBudget) context.Context {
+			ctx, cancel := context.WithTimeout(b.Context(), time.Nanosecond)
+			defer cancel()
+			<-ctx.Done()
+			return ctx
+		}, context.DeadlineExceeded, true},
+		{"整个请求被取消", func(b *requestBudget) context.Context {
+			ctx, cancel := b.Stage(stageDraw)
+			defer cancel()
+			b.Done()
+			return ctx
+		}, context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRequestBudget(context.Background())
+			defer b.Done()
+			err := b.Check(tt.setup(b), "stage")
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("Check() = %v, want %v", err, tt.wantErr)
+			}
+			var be *budgetError
+			if got := errors.As(err, &be); got != tt.wantBudget {
+				t.Errorf("errors.As(budgetError) = %v, want %v", got, tt.wantBudget)
+			}
+			if tt.wantBudget && !isTimeout(err) {
+				t.Errorf("isTimeout(%v) = false", err)
+			}
+		})
+	}
+}
+
+func TestStageShares(t *testing.T) {
+	tests := []struct {
+		name  string
+		split string
+		stage string
+		want  float64
+	}{
+		{"默认比例", "", stageOCR, 0.7},
+		{"覆盖单个阶段", "ocr=0.5", stageOCR, 0.5},
+		{"其余阶段保持默认", "ocr=0.5", stageDraw, 0.1},
+		{"忽略非法值", "verify=abc,draw=-1", stageDraw, 0.1},
+		{"允许空格", " preprocess = 0.2 ", stagePreprocess, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUEST_BUDGET_SPLIT", tt.split)
+			if got := stageShares()[tt.stage]; got != tt.want {
+				t.Errorf("stageShares()[%s] = %v, want %v", tt.stage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRequestBudgetHonorsParentDeadline(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "1m")
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := newRequestBudget(parent)
+	defer b.Done()
+	if b.total > time.Second {
+		t.Errorf("total = %v, 应不超过调用方的截止时间", b.total)
+	}
+}
+
+func TestVerifyHandlerHonorsPreprocessBudget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GEMINI_API_KEY", "")
+	tests := []struct {
+		name       string
+		timeout    string
+		wantStatus int
+	}{
+		{"预算内读取", "1m", 500}, // 读完图片才检查 API Key
+		{"预处理预算已用完", "1ns", 504},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUEST_TIMEOUT", tt.timeout)
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			part, _ := mw.CreateFormFile("image", "t.jpg")
+			part.Write([]byte("jpeg"))
+			mw.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			verifyHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d（响应 %s）", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLookupWinningNumbersHonorsContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantDrawn bool
+	}{
+		{"正常查询", context.Background(), true},
+		{"ctx 已结束", canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, drawn := lookupWinningNumbers(tt.ctx, "双色球", "2025107"); drawn != tt.wantDrawn {
+				t.Errorf("drawn = %v, want %v", drawn, tt.wantDrawn)
+			}
+		})
+	}
+}
+
+func TestCtxReader(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		wantErr error
+	}{
+		{"正常读取", context.Background(), "hello", nil},
+		{"ctx 已结束", canceled, "", context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(ctxReader{tt.ctx, strings.NewReader("hello")})
+			if string(got) != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadAll = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// CACHE: 识别与验奖结果缓存
+// ==========================================
+
+// 热门开奖后大量用户会反复核对同一组号码/同一张照片，
+// 这里用两层缓存分别跳过 OCR 调用和验奖计算；多副本部署时可由 Redis 共享，见 cluster.go。
+var (
+	ocrCache    = newSharedCache[[]LotteryData]("ocr", envInt("OCR_CACHE_SIZE", 2000), envDuration("OCR_CACHE_TTL", 24*time.Hour))
+	verifyCache = newSharedCache[VerificationResult]("verify", envInt("VERIFY_CACHE_SIZE", 20000), envDuration("VERIFY_CACHE_TTL", 7*24*time.Hour))
+)
+
+// ttlCache 带过期时间的 LRU 缓存，容量满时淘汰最久未使用的条目
+type ttlCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](capacity int, ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c.capacity <= 0 {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) Set(key string, value V) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry[V])
+		entry.value, entry.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry[V]{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry[V]).key)
+	}
+}
+
+// Purge 清空全部条目
+func (c *ttlCache[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+// imageHash 计算图片内容的 SHA-256，作为 OCR 缓存的键
+func imageHash(fileBytes []byte) string {
+	sum := sha256.Sum256(fileBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// recognizeCached 同一张图片只调用一次 AI 识别；高精度模式的合并结果单独缓存。带 roi 时按区域分别识别，见 roi.go
+func recognizeCached(ctx context.Context, fileBytes []byte, apiKey string) ([]LotteryData, error) {
+	if boxes := roiFrom(ctx); len(boxes) > 0 {
+		return recognizeRegions(ctx, fileBytes, apiKey, boxes)
+	}
+	passes := ocrPassesFrom(ctx)
+	key := imageHash(fileBytes)
+	if passes > 1 {
+		key += fmt.Sprintf("#x%d", passes)
+	}
+	key += ocrCacheSuffix(ctx, fileBytes)
+	// 调试模式要看到真实的模型调用，不读缓存；mock 识别按文件名选样例、样例随时会改，也不读缓存
+	if cached, ok := ocrCache.Get(key); ok && debugTraceFrom(ctx) == nil && !mockOCREnabled() {
+		ocrOutcomes.Record(billingTenantFrom(ctx), false)
+		return hooks.AfterOCR(ctx, cached), nil
+	}
+	var data []LotteryData
+	var err error
+	if passes > 1 {
+		data, err = multiPassOCR(ctx, fileBytes, apiKey, passes)
+	} else {
+		data, err = callOCRWithBreaker(ctx, fileBytes, apiKey, nil)
+	}
+	ocrOutcomes.Record(billingTenantFrom(ctx), err != nil)
+	if err != nil {
+		return nil, err
+	}
+	data = retryEnhanced(ctx, fileBytes, apiKey, data)
+	ocrCache.Set(key, data)
+	return hooks.AfterOCR(ctx, data), nil
+}
+
+// verifyCacheKey 对票面内容做归一化后取哈希：彩种取标准名称，去掉首尾空格，
+// 无序玩法的号码排序，按位比较的玩法（排列5 及 ordered 的 YAML 彩种）保持原顺序；
+// 结果里回显的机选/自选也来自票面，一并计入
+func verifyCacheKey(lottery LotteryData) string {
+	game := canonicalGame(lottery.Type)
+	spec, known := specOf(game)
+	ordered := !known || spec.Ordered
+	normalize := func(nums []string) []string {
+		out := make([]string, len(nums))
+		for i, n := range nums {
+			out[i] = strings.TrimSpace(n)
+		}
+		if !ordered {
+			sort.Strings(out)
+		}
+		return out
+	}
+
+	norm := LotteryData{
+		Type:    game,
+		Issue:   strings.TrimSpace(lottery.Issue),
+		Tickets: make([]UserTicket, len(lottery.Tickets)),
+	}
+	for i, t := range lottery.Tickets {
+		norm.Tickets[i] = UserTicket{
+			Red:        normalize(t.Red),
+			Blue:       normalize(t.Blue),
+			Multiplier: t.Multiplier,
+			Mode:       strings.TrimSpace(t.Mode),
+			Dan:        normalize(t.Dan),
+			BlueDan:    normalize(t.BlueDan),
+			AddOn:      t.AddOn,
+			PickMethod: pickMethodOf(t),
+		}
+	}
+	raw, _ := json.Marshal(norm)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVerifyCacheKey(t *testing.T) {
+	loadTestGames(t)
+	row := func(game string, red, blue []string) LotteryData {
+		return LotteryData{Type: game, Issue: "2025107", Tickets: []UserTicket{{Red: red, Blue: blue, Multiplier: 1}}}
+	}
+	tests := []struct {
+		name string
+		a, b LotteryData
+		same bool
+	}{
+		{"无序玩法号码顺序无关", row("双色球", []string{"02", "11", "15", "21", "28", "33"}, []string{"07"}),
+			row("双色球", []string{"33", "28", "21", "15", "11", "02"}, []string{"07"}), true},
+		{"代码与中文名等价", row("ssq", []string{"02", "11", "15", "21", "28", "33"}, []string{"07"}),
+			row(" 双色球 ", []string{"02", "11", "15", "21", "28", "33"}, []string{"07"}), true},
+		{"排列5 按位", row("排列5", []string{"1", "2", "3", "4", "5"}, nil),
+			row("排列5", []string{"5", "4", "3", "2", "1"}, nil), false},
+		{"排列5 代码按位", row("pl5", []string{"1", "2", "3", "4", "5"}, nil),
+			row("pl5", []string{"5", "4", "3", "2", "1"}, nil), false},
+		{"YAML 按位彩种", row("pl3", []string{"1", "2", "3"}, nil),
+			row("排列3", []string{"3", "2", "1"}, nil), false},
+		{"YAML 按位彩种代码与名称等价", row("p3", []string{"1", "2", "3"}, nil),
+			row("排列3", []string{"1", "2", "3"}, nil), true},
+		{"YAML 无序彩种", row("qlc", []string{"01", "02", "03", "04", "05", "06", "07"}, nil),
+			row("七乐彩", []string{"07", "06", "05", "04", "03", "02", "01"}, nil), true},
+		{"期号不同", row("双色球", []string{"02"}, []string{"07"}),
+			LotteryData{Type: "双色球", Issue: "2025108", Tickets: []UserTicket{{Red: []string{"02"}, Blue: []string{"07"}, Multiplier: 1}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCacheKey(tt.a) == verifyCacheKey(tt.b); got != tt.same {
+				t.Errorf("keys equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestVerifyLotteryCache(t *testing.T) {
+	row := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "09"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	pending := row
+	pending.Issue = "2099001"
+	tests := []struct {
+		name       string
+		lottery    LotteryData
+		supplied   []DrawRecord
+		wantCached bool
+	}{
+		{"已开奖的票第二次命中缓存", row, nil, true},
+		{"未开奖不缓存", pending, nil, false},
+		{"调用方提供的开奖号码不缓存", row, []DrawRecord{{Game: "双色球", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyCache.Purge()
+			var results []VerificationResult
+			for i := 0; i < 2; i++ {
+				b := newRequestBudget(withSuppliedDraws(context.Background(), tt.supplied))
+				res, err := verifyLottery(b, i, tt.lottery)
+				b.Done()
+				if err != nil {
+					t.Fatal(err)
+				}
+				results = append(results, res)
+			}
+			if results[0].Cached {
+				t.Error("first verification reported cached")
+			}
+			if results[1].Cached != tt.wantCached {
+				t.Errorf("second cached = %v, want %v", results[1].Cached, tt.wantCached)
+			}
+			if results[0].TotalPrize != results[1].TotalPrize || results[1].TicketIndex != 2 {
+				t.Errorf("cached result differs: %+v vs %+v", results[0], results[1])
+			}
+		})
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		ttl      time.Duration
+		set      []string
+		get      string
+		want     bool
+	}{
+		{"命中", 2, time.Hour, []string{"a"}, "a", true},
+		{"容量满淘汰最久未用", 2, time.Hour, []string{"a", "b", "c"}, "a", false},
+		{"未淘汰的仍在", 2, time.Hour, []string{"a", "b", "c"}, "c", true},
+		{"过期", 2, -time.Second, []string{"a"}, "a", false},
+		{"容量为 0 不缓存", 0, time.Hour, []string{"a"}, "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTTLCache[int](tt.capacity, tt.ttl)
+			for i, k := range tt.set {
+				c.Set(k, i)
+			}
+			if _, ok := c.Get(tt.get); ok != tt.want {
+				t.Errorf("Get(%q) ok = %v, want %v", tt.get, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// CALENDAR: 开奖日历、下一期倒计时与停售时间
+// ==========================================
+
+// chinaTime 开奖时间统一按北京时间计算（无夏令时）
+var chinaTime = time.FixedZone("CST", 8*3600)
+
+// DrawSchedule 一个彩种的开奖规律
+type DrawSchedule struct {
+	Weekdays []time.Weekday `json:"weekdays"`  // 0=周日
+	DrawTime string         `json:"draw_time"` // 21:15
+	Cutoff   string         `json:"cutoff"`    // 当天停售时间 20:00
+}
+
+// DateRange 休市区间（含首尾），如春节、国庆
+type DateRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CalendarConfig data/calendar.json，可覆盖默认开奖规律并配置休市
+type CalendarConfig struct {
+	Games     map[string]DrawSchedule `json:"games"`
+	Suspended []DateRange             `json:"suspended"`
+}
+
+var defaultSchedules = map[string]DrawSchedule{
+	"双色球": {Weekdays: []time.Weekday{time.Sunday, time.Tuesday, time.Thursday}, DrawTime: "21:15", Cutoff: "20:00"},
+	"大乐透": {Weekdays: []time.Weekday{time.Monday, time.Wednesday, time.Saturday}, DrawTime: "21:25", Cutoff: "20:00"},
+	"排列5": {Weekdays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, DrawTime: "21:25", Cutoff: "20:00"},
+}
+
+type drawCalendar struct {
+	mu  sync.RWMutex
+	cfg CalendarConfig
+}
+
+var calendar = &drawCalendar{cfg: CalendarConfig{Games: defaultSchedules}}
+
+// Load 读取日历配置，文件不存在时使用默认规律
+func (c *drawCalendar) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cfg CalendarConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return err
+	}
+	games := map[string]DrawSchedule{}
+	for name, s := range defaultSchedules {
+		games[name] = s
+	}
+	for name, s := range cfg.Games {
+		games[canonicalGame(name)] = s
+	}
+	cfg.Games = games
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *drawCalendar) suspended(day time.Time) bool {
+	d := day.Format("2006-01-02")
+	for _, r := range c.cfg.Suspended {
+		if d >= r.From && d <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// isDrawDay 该日是否开奖
+func (c *drawCalendar) isDrawDay(s DrawSchedule, day time.Time) bool {
+	if c.suspended(day) {
+		return false
+	}
+	for _, w := range s.Weekdays {
+		if day.Weekday() == w {
+			return true
+		}
+	}
+	return false
+}
+
+// at 某天的 HH:MM
+func at(day time.Time, hhmm string) time.Time {
+	h, m := 0, 0
+	fmt.Sscanf(hhmm, "%d:%d", &h, &m)
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, chinaTime)
+}
+
+// NextDraw 下一期开奖信息
+type NextDraw struct {
+	Game            string    `json:"game"`
+	Issue           string    `json:"issue,omitempty"` // 依据最近一期开奖推算，缺少开奖数据时为空
+	DrawTime        time.Time `json:"draw_time"`
+	SaleCutoff      time.Time `json:"sale_cutoff"`
+	OnSale          bool      `json:"on_sale"`
+	SecondsToDraw   int64     `json:"seconds_to_draw"`
+	SecondsToCutoff int64     `json:"seconds_to_cutoff"`
+}
+
+// Next 计算 now 之后的下一次开奖；停售后到开奖前仍返回当期，此时 OnSale 为 false
+func (c *drawCalendar) Next(game string, now time.Time) (NextDraw, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	game = canonicalGame(game)
+	s, ok := c.cfg.Games[game]
+	if !ok || len(s.Weekdays) == 0 {
+		return NextDraw{}, false
+	}
+	now = now.In(chinaTime)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, chinaTime)
+	for i := 0; i < 366; i++ {
+		d := day.AddDate(0, 0, i)
+		if !c.isDrawDay(s, d) || !at(d, s.DrawTime).After(now) {
+			continue
+		}
+		next := NextDraw{Game: game, DrawTime: at(d, s.DrawTime), SaleCutoff: at(d, s.Cutoff)}
+		next.OnSale = now.Before(next.SaleCutoff)
+		next.SecondsToDraw = int64(next.DrawTime.Sub(now).Seconds())
+		if next.OnSale {
+			next.SecondsToCutoff = int64(next.SaleCutoff.Sub(now).Seconds())
+		}
+		next.Issue = c.nextIssue(game, s, d)
+		return next, true
+	}
+	return NextDraw{}, false
+}
+
+// nextIssue 从 drawDay 之前最近的一期开奖往后数开奖日推算期号；跨年时期号从 001 重新开始
+func (c *drawCalendar) nextIssue(game string, s DrawSchedule, drawDay time.Time) string {
+	var last DrawRecord
+	var lastDay time.Time
+	for _, d := range draws.History(game) {
+		day, err := time.ParseInLocation("2006-01-02", d.DrawDate, chinaTime)
+		if err == nil && !day.After(drawDay) {
+			last, lastDay = d, day
+			break
+		}
+	}
+	if last.Issue == "" {
+		return ""
+	}
+	if lastDay.Equal(drawDay) {
+		return last.Issue
+	}
+	yearDigits := len(last.Issue) - 3 // 2025107 -> 4，25107 -> 2
+	if yearDigits != 2 && yearDigits != 4 {
+		return ""
+	}
+	seq, err := strconv.Atoi(last.Issue[yearDigits:])
+	if err != nil {
+		return ""
+	}
+
+	from := lastDay.AddDate(0, 0, 1)
+	if drawDay.Year() != lastDay.Year() {
+		seq = 0
+		from = time.Date(drawDay.Year(), 1, 1, 0, 0, 0, 0, chinaTime)
+	}
+	for d := from; !d.After(drawDay); d = d.AddDate(0, 0, 1) {
+		if c.isDrawDay(s, d) {
+			seq++
+		}
+	}
+	year := strconv.Itoa(drawDay.Year())
+	return year[4-yearDigits:] + fmt.Sprintf("%03d", seq)
+}
+
+// OnSaleAt 某个时刻正在销售的期次：停售后到开奖前卖的是下一期
+func (c *drawCalendar) OnSaleAt(game string, t time.Time) (NextDraw, bool) {
+	next, ok := c.Next(game, t)
+	if ok && !next.OnSale {
+		next, ok = c.Next(game, next.DrawTime.Add(time.Second))
+	}
+	return next, ok
+}
+
+// nextDrawHandler GET /api/v1/draws/next?game=ssq，不带 game 时返回全部彩种
+func nextDrawHandler(c *gin.Context) {
+	now := time.Now()
+	if game := c.Query("game"); game != "" {
+		spec, ok := specOf(game)
+		if !ok {
+			c.JSON(400, gin.H{"error": "不支持的彩种: " + game})
+			return
+		}
+		next, ok := calendar.Next(spec.Name, now)
+		if !ok {
+			c.JSON(404, gin.H{"error": "未配置该彩种的开奖日历"})
+			return
+		}
+		c.JSON(200, next)
+		return
+	}
+
+	names := make([]string, 0, len(gameSpecs))
+	for name := range gameSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []NextDraw{}
+	for _, name := range names {
+		if next, ok := calendar.Next(name, now); ok {
+			list = append(list, next)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].DrawTime.Before(list[j].DrawTime) })
+	c.JSON(200, list)
+}
+
+// issueDrawn 期号对应的开奖时间是否已过，供待开奖逻辑判断是否该有开奖结果
+func issueDrawn(game, issue string, now time.Time) bool {
+	next, ok := calendar.Next(game, now)
+	if !ok || next.Issue == "" {
+		return false
+	}
+	return strings.TrimSpace(issue) < next.Issue && len(issue) == len(next.Issue)
+}
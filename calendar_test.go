@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrawCalendarNext(t *testing.T) {
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025106", DrawDate: "2025-09-14"},
+		DrawRecord{Game: "双色球", Issue: "2025151", DrawDate: "2025-12-30"},
+	)
+	cst := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, chinaTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		name      string
+		suspended []DateRange
+		now       string
+		draw      string
+		issue     string
+		onSale    bool
+		toCutoff  int64
+	}{
+		{"开奖日停售前", nil, "2025-09-16 19:00", "2025-09-16 21:15", "2025107", true, 3600},
+		{"停售后开奖前仍是当期", nil, "2025-09-16 20:30", "2025-09-16 21:15", "2025107", false, 0},
+		{"开奖后顺延到下个开奖日", nil, "2025-09-16 21:30", "2025-09-18 21:15", "2025108", true, 167400},
+		{"休市日跳过且不计期号", []DateRange{{From: "2025-09-18", To: "2025-09-20"}}, "2025-09-16 21:30", "2025-09-21 21:15", "2025108", true, 426600},
+		{"跨年期号从 001 开始", nil, "2025-12-31 12:00", "2026-01-01 21:15", "2026001", true, 115200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &drawCalendar{cfg: CalendarConfig{Games: defaultSchedules, Suspended: tt.suspended}}
+			next, ok := c.Next("双色球", cst(tt.now))
+			if !ok {
+				t.Fatal("Next() not found")
+			}
+			if !next.DrawTime.Equal(cst(tt.draw)) || next.Issue != tt.issue || next.OnSale != tt.onSale || next.SecondsToCutoff != tt.toCutoff {
+				t.Errorf("Next() = %+v, want draw %s issue %s on_sale %v cutoff in %ds", next, tt.draw, tt.issue, tt.onSale, tt.toCutoff)
+			}
+		})
+	}
+}
+
+func TestIssueDrawn(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025106", DrawDate: "2025-09-14"})
+	now := time.Date(2025, 9, 16, 19, 0, 0, 0, chinaTime)
+	tests := []struct {
+		game  string
+		issue string
+		want  bool
+	}{
+		{"双色球", "2025106", true},
+		{"双色球", "2025107", false},
+		{"双色球", "25106", false},
+		{"大乐透", "2025106", false},
+	}
+	for _, tt := range tests {
+		if got := issueDrawn(tt.game, tt.issue, now); got != tt.want {
+			t.Errorf("issueDrawn(%s, %s) = %v, want %v", tt.game, tt.issue, got, tt.want)
+		}
+	}
+}
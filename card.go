@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// CARD: 小程序结果卡片 (?format=card)
+// ==========================================
+
+// CardResponse 小程序直接渲染的完整结构，客户端不需要再拼接任何文案
+type CardResponse struct {
+	Summary       string       `json:"summary"`
+	TotalPrize    int64        `json:"total_prize"` // v1，元
+	TotalPrizeFen Fen          `json:"total_prize_fen"`
+	TotalText     string       `json:"total_text"`
+	Currency      string       `json:"currency,omitempty"` // en 模式下金额的币种
+	Cards         []ResultCard `json:"cards"`
+}
+
+// ResultCard 一张彩票对应一张卡片
+type ResultCard struct {
+	Title    string      `json:"title"`    // 双色球 第2025107期
+	Icon     string      `json:"icon"`     // 🎉 😢 ⏳ ⚠️
+	Theme    string      `json:"theme"`    // win / lose / pending / unsupported
+	Headline string      `json:"headline"` // 恭喜中奖 3,000元
+	Subtitle string      `json:"subtitle"` // 共 5 行 · 中奖 2 行
+	Winning  []CardBall  `json:"winning,omitempty"`
+	Rows     []CardRow   `json:"rows"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Claim    *ClaimGuide `json:"claim,omitempty"`
+	Jackpot  string      `json:"jackpot,omitempty"` // 当前奖池 12.35亿元
+}
+
+// CardRow 一行号码
+type CardRow struct {
+	Label      string     `json:"label"` // 第1行
+	Balls      []CardBall `json:"balls"`
+	Multiplier string     `json:"multiplier,omitempty"` // ×2
+	Icon       string     `json:"icon"`
+	Status     string     `json:"status"` // 三等奖 3,000元
+	Highlight  bool       `json:"highlight"`
+}
+
+// CardBall 单个号码球，Hit 表示与开奖号码命中
+type CardBall struct {
+	Number string `json:"number"`
+	Color  string `json:"color"` // red / blue / digit
+	Hit    bool   `json:"hit"`
+}
+
+const (
+	iconWin         = "🎉"
+	iconLose        = "😢"
+	iconPending     = "⏳"
+	iconUnsupported = "⚠️"
+)
+
+var chineseLevels = []string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// levelName 1 -> 一等奖
+func levelName(level int) string {
+	if level <= 0 {
+		return "未中奖"
+	}
+	if level < len(chineseLevels) {
+		return chineseLevels[level] + "等奖"
+	}
+	return strconv.Itoa(level) + "等奖"
+}
+
+// formatYuan 5000 -> 5,000元
+func formatYuan(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	s := strconv.FormatInt(n, 10)
+	var sb strings.Builder
+	for i, ch := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(ch)
+	}
+	return sign + sb.String() + "元"
+}
+
+func buildBalls(nums, winning []string, color string, ordered bool) []CardBall {
+	balls := make([]CardBall, len(nums))
+	for i, n := range nums {
+		hit := false
+		if ordered {
+			hit = i < len(winning) && winning[i] == n
+		} else {
+			for _, w := range winning {
+				if w == n {
+					hit = true
+					break
+				}
+			}
+		}
+		balls[i] = CardBall{Number: n, Color: color, Hit: hit}
+	}
+	return balls
+}
+
+// buildCard 把验奖结果转换成卡片
+func buildCard(res VerificationResult) ResultCard {
+	lottery := res.OCRData
+	card := ResultCard{Title: fmt.Sprintf("%s 第%s期", lottery.Type, lottery.Issue), Rows: []CardRow{}}
+	win, drawn := res.winning()
+	ordered := strings.Contains(lottery.Type, "排列")
+	redColor := "red"
+	if ordered {
+		redColor = "digit"
+	}
+
+	card.Warnings, card.Claim = res.Warnings, res.Claim
+	if j, ok := currentJackpot(lottery.Type); ok {
+		card.Jackpot = "当前奖池 " + j.PoolText
+	}
+	supported := selectVerifier(lottery.Type) != nil
+	if drawn {
+		card.Winning = append(buildBalls(win.Red, nil, redColor, ordered), buildBalls(win.Blue, nil, "blue", false)...)
+	}
+
+	winRows := 0
+	for i, t := range lottery.Tickets {
+		row := CardRow{Label: fmt.Sprintf("第%d行", i+1)}
+		if drawn {
+			row.Balls = append(buildBalls(t.Red, win.Red, redColor, ordered), buildBalls(t.Blue, win.Blue, "blue", false)...)
+		} else {
+			row.Balls = append(buildBalls(t.Red, nil, redColor, ordered), buildBalls(t.Blue, nil, "blue", false)...)
+		}
+		if t.Multiplier > 1 {
+			row.Multiplier = fmt.Sprintf("×%d", t.Multiplier)
+		}
+		var detail ResultDetail
+		if i < len(res.Details) {
+			detail = res.Details[i]
+		}
+		switch {
+		case res.Rejected:
+			row.Icon, row.Status = iconUnsupported, "票据校验未通过"
+		case !supported:
+			row.Icon, row.Status = iconUnsupported, "暂不支持"
+		case res.Pending:
+			row.Icon, row.Status = iconPending, "待开奖"
+		case detail.Prize > 0:
+			row.Icon, row.Status, row.Highlight = iconWin, levelName(detail.Level)+" "+detail.Prize.String(), true
+			winRows++
+		default:
+			row.Icon, row.Status = iconLose, "未中奖"
+		}
+		card.Rows = append(card.Rows, row)
+	}
+
+	card.Subtitle = fmt.Sprintf("共 %d 行 · 中奖 %d 行", len(lottery.Tickets), winRows)
+	switch {
+	case res.Rejected:
+		card.Theme, card.Icon, card.Headline = "unsupported", iconUnsupported, "票据校验未通过"
+	case !supported:
+		card.Theme, card.Icon, card.Headline = "unsupported", iconUnsupported, "暂不支持该彩种验奖"
+	case res.Pending:
+		card.Theme, card.Icon, card.Headline = "pending", iconPending, "本期尚未开奖"
+	case res.TotalPrize > 0:
+		card.Theme, card.Icon, card.Headline = "win", iconWin, "恭喜中奖 "+res.TotalPrize.String()
+	default:
+		card.Theme, card.Icon, card.Headline = "lose", iconLose, "未中奖，下次好运"
+	}
+	return card
+}
+
+// buildCardResponse 多张彩票汇总成一个卡片响应
+func buildCardResponse(results []VerificationResult) CardResponse {
+	resp := CardResponse{Cards: make([]ResultCard, 0, len(results))}
+	for _, r := range results {
+		resp.TotalPrizeFen += r.TotalPrize
+		resp.Cards = append(resp.Cards, buildCard(r))
+	}
+	resp.TotalPrize = resp.TotalPrizeFen.Yuan()
+	resp.TotalText = resp.TotalPrizeFen.String()
+	if resp.TotalPrizeFen > 0 {
+		resp.Summary = fmt.Sprintf("%s 共识别 %d 张彩票，合计中奖 %s", iconWin, len(results), resp.TotalText)
+	} else {
+		resp.Summary = fmt.Sprintf("共识别 %d 张彩票，本次未中奖", len(results))
+	}
+	return resp
+}
+
+// respondResults 按 ?format= 选择输出结构，默认输出原始验奖结果
+func respondResults(c *gin.Context, results []VerificationResult) {
+	if detailFullOf(c) {
+		addBetBreakdown(results)
+	}
+	switch c.Query("format") {
+	case "card":
+		c.JSON(200, presentCards(c, buildCardResponse(results)))
+		return
+	case "slip":
+		c.JSON(200, presentSlip(c, results))
+		return
+	}
+	c.JSON(200, presentResults(c, results))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatYuan(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0元"},
+		{999, "999元"},
+		{5000, "5,000元"},
+		{10000000, "10,000,000元"},
+		{-1200, "-1,200元"},
+	}
+	for _, tt := range tests {
+		if got := formatYuan(tt.in); got != tt.want {
+			t.Errorf("formatYuan(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBuildBalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		nums    []string
+		winning []string
+		ordered bool
+		want    []bool
+	}{
+		{"乐透型不看位置", []string{"01", "02", "03"}, []string{"03", "01", "09"}, false, []bool{true, false, true}},
+		{"排列型按位比对", []string{"1", "2", "3"}, []string{"1", "3", "2"}, true, []bool{true, false, false}},
+		{"未开奖不标命中", []string{"01"}, nil, false, []bool{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balls := buildBalls(tt.nums, tt.winning, "red", tt.ordered)
+			for i, b := range balls {
+				if b.Hit != tt.want[i] {
+					t.Errorf("ball %s hit = %v, want %v", b.Number, b.Hit, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildCard(t *testing.T) {
+	ticket := UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 2}
+	tests := []struct {
+		name     string
+		res      VerificationResult
+		theme    string
+		rowIcon  string
+		rowBalls int
+	}{
+		{"中奖", VerificationResult{TotalPrize: 5000000 * Yuan,
+			Details: []ResultDetail{{Level: 1, Prize: 5000000 * Yuan}}}, "win", iconWin, 7},
+		{"未中奖", VerificationResult{Details: []ResultDetail{{}}}, "lose", iconLose, 7},
+		{"待开奖", VerificationResult{Pending: true}, "pending", iconPending, 7},
+		{"票据校验未通过", VerificationResult{Rejected: true}, "unsupported", iconUnsupported, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.res.OCRData = LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{ticket}}
+			card := buildCard(tt.res)
+			if card.Theme != tt.theme {
+				t.Errorf("theme = %q, want %q", card.Theme, tt.theme)
+			}
+			if len(card.Rows) != 1 || card.Rows[0].Icon != tt.rowIcon || len(card.Rows[0].Balls) != tt.rowBalls {
+				t.Fatalf("rows = %+v", card.Rows)
+			}
+			if card.Rows[0].Multiplier != "×2" {
+				t.Errorf("multiplier = %q, want ×2", card.Rows[0].Multiplier)
+			}
+			if len(card.Winning) != 7 {
+				t.Errorf("winning balls = %d, want 7", len(card.Winning))
+			}
+		})
+	}
+}
+
+func TestBuildCardResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		prizes  []Fen
+		total   string
+		summary string
+	}{
+		{"合计中奖", []Fen{10 * Yuan, 5 * Yuan}, "15元", "🎉 共识别 2 张彩票，合计中奖 15元"},
+		{"都没中", []Fen{0}, "0元", "共识别 1 张彩票，本次未中奖"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]VerificationResult, len(tt.prizes))
+			for i, p := range tt.prizes {
+				results[i] = VerificationResult{TotalPrize: p, OCRData: LotteryData{Type: "双色球", Issue: "2025107"}}
+			}
+			resp := buildCardResponse(results)
+			if resp.TotalText != tt.total || resp.Summary != tt.summary {
+				t.Errorf("total/summary = %q / %q, want %q / %q", resp.TotalText, resp.Summary, tt.total, tt.summary)
+			}
+		})
+	}
+}
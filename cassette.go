@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// CASSETTE: 录制与回放 AI 识别的原始响应
+// ==========================================
+
+// 解析、清洗、验奖的改动需要拿真实的模型输出反复验证，但每次都调用 AI 服务既慢又花钱、结果还不固定。
+// OCR_CASSETTE_MODE 控制录制与回放（目录 OCR_CASSETTE_DIR，默认 data/cassettes）：
+//
+//	record   照常调用 AI 服务，并把每次的原始输出写入磁盘（已有的覆盖）
+//	replay   不联网，只读录制的输出；没有录制过的请求直接失败
+//	auto     有录制就回放，没有就调用并录制
+//
+// 一条录制对应“图片 + 识别服务 + 模型 + 提示词 + 温度”，任何一项变化都视为新请求；
+// 回放的原始输出同样经过 parseOCRText，调试记录（?debug=1）与真实调用格式相同。
+// Gemini 与第二识别服务（OCR_SECONDARY_*）都支持
+
+const (
+	cassetteRecord = "record"
+	cassetteReplay = "replay"
+	cassetteAuto   = "auto"
+)
+
+var errCassetteMiss = errors.New("回放模式下没有该请求的录制")
+
+func cassetteMode() string {
+	switch m := strings.ToLower(strings.TrimSpace(os.Getenv("OCR_CASSETTE_MODE"))); m {
+	case cassetteRecord, cassetteReplay, cassetteAuto:
+		return m
+	}
+	return ""
+}
+
+func cassetteDir() string {
+	if dir := strings.TrimSpace(os.Getenv("OCR_CASSETTE_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(dataDir(), "cassettes")
+}
+
+// cassette 一次识别请求与模型的原始输出
+type cassette struct {
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	ImageHash   string    `json:"image_hash"`
+	Prompt      string    `json:"prompt"`
+	Temperature *float32  `json:"temperature,omitempty"`
+	RawOutput   string    `json:"raw_output"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// cassettePath 文件名为 <图片哈希>.<识别服务>.<请求参数哈希>.json，同一张图片的录制排在一起
+func cassettePath(ex debugExchange, temperature *float32, imgHash string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", ex.Provider, ex.Model, ex.Prompt)
+	if temperature != nil {
+		fmt.Fprintf(h, "%g", *temperature)
+	}
+	req := hex.EncodeToString(h.Sum(nil))[:12]
+	return filepath.Join(cassetteDir(), fmt.Sprintf("%s.%s.%s.json", imgHash, ex.Provider, req))
+}
+
+// replayOCR 命中录制时返回解析结果，handled 为 false 表示需要真实调用；
+// ex 已填好识别服务、模型与提示词
+func replayOCR(ex debugExchange, trace *DebugTrace, temperature *float32, fileBytes []byte) (data []LotteryData, handled bool, err error) {
+	mode := cassetteMode()
+	if mode != cassetteReplay && mode != cassetteAuto {
+		return nil, false, nil
+	}
+	path := cassettePath(ex, temperature, imageHash(fileBytes))
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if mode == cassetteAuto {
+			return nil, false, nil
+		}
+		return nil, true, fmt.Errorf("%w: %s", errCassetteMiss, filepath.Base(path))
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	var c cassette
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, true, fmt.Errorf("录制文件 %s 格式错误: %v", filepath.Base(path), err)
+	}
+
+	ex.Temperature, ex.StartedAt, ex.RawOutput = temperature, time.Now(), c.RawOutput
+	data, err = parseOCRText(strings.TrimSpace(c.RawOutput))
+	if err != nil {
+		ex.Error = err.Error()
+	}
+	ex.Cleaned = data
+	trace.Record(ex)
+	return data, true, err
+}
+
+// recordOCR 录制一次真实调用的原始输出，失败只记日志
+func recordOCR(ex debugExchange, temperature *float32, fileBytes []byte) {
+	if mode := cassetteMode(); mode != cassetteRecord && mode != cassetteAuto {
+		return
+	}
+	imgHash := imageHash(fileBytes)
+	c := cassette{
+		Provider: ex.Provider, Model: ex.Model, ImageHash: imgHash, Prompt: ex.Prompt,
+		Temperature: temperature, RawOutput: ex.RawOutput, RecordedAt: time.Now(),
+	}
+	path := cassettePath(ex, temperature, imgHash)
+	raw, _ := json.MarshalIndent(c, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("录制识别响应失败: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		log.Printf("录制识别响应失败: %v", err)
+	}
+}
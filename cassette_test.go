@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteMode(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"", ""},
+		{"record", cassetteRecord},
+		{" Replay ", cassetteReplay},
+		{"AUTO", cassetteAuto},
+		{"live", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("OCR_CASSETTE_MODE", tt.env)
+		if got := cassetteMode(); got != tt.want {
+			t.Errorf("cassetteMode(%q) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+// 图片、识别服务、模型、提示词、温度任何一项变化都是新请求
+func TestCassettePath(t *testing.T) {
+	t.Setenv("OCR_CASSETTE_DIR", "/tmp/cassettes")
+	zero, warm := float32(0), float32(0.4)
+	base := debugExchange{Provider: "gemini", Model: "gemini-2.5-flash", Prompt: "p"}
+	want := cassettePath(base, nil, "img")
+	if !strings.HasPrefix(want, "/tmp/cassettes/img.gemini.") || !strings.HasSuffix(want, ".json") {
+		t.Fatalf("cassettePath() = %s", want)
+	}
+
+	tests := []struct {
+		name        string
+		ex          debugExchange
+		temperature *float32
+		img         string
+		same        bool
+	}{
+		{"相同请求", base, nil, "img", true},
+		{"MIME 类型不影响", debugExchange{Provider: "gemini", Model: "gemini-2.5-flash", Prompt: "p", MIMEType: "image/png"}, nil, "img", true},
+		{"图片不同", base, nil, "img2", false},
+		{"识别服务不同", debugExchange{Provider: "secondary", Model: "gemini-2.5-flash", Prompt: "p"}, nil, "img", false},
+		{"模型不同", debugExchange{Provider: "gemini", Model: "gemini-2.5-pro", Prompt: "p"}, nil, "img", false},
+		{"提示词不同", debugExchange{Provider: "gemini", Model: "gemini-2.5-flash", Prompt: "p2"}, nil, "img", false},
+		{"指定温度", base, &zero, "img", false},
+		{"温度不同", base, &warm, "img", false},
+	}
+	for _, tt := range tests {
+		if got := cassettePath(tt.ex, tt.temperature, tt.img); (got == want) != tt.same {
+			t.Errorf("%s: cassettePath() = %s, same as base %v, want %v", tt.name, got, got == want, tt.same)
+		}
+	}
+	if cassettePath(base, &zero, "img") == cassettePath(base, &warm, "img") {
+		t.Error("temperatures 0 and 0.4 share a cassette")
+	}
+}
+
+func TestRecordAndReplayOCR(t *testing.T) {
+	img := []byte("\xff\xd8\xff\xe0cassette")
+	ex := debugExchange{Provider: "gemini", Model: "gemini-2.5-flash", Prompt: "p", RawOutput: "```json\n" + hookTestFixture + "\n```"}
+	other := []byte("\xff\xd8\xff\xe0other")
+
+	tests := []struct {
+		name        string
+		recordMode  string
+		replayMode  string
+		replayImg   []byte
+		corrupt     bool
+		wantHandled bool
+		wantCount   int
+		wantErr     error
+	}{
+		{"录制后回放", cassetteRecord, cassetteReplay, img, false, true, 2, nil},
+		{"auto 模式录制并回放", cassetteAuto, cassetteAuto, img, false, true, 2, nil},
+		{"回放模式没有录制", cassetteRecord, cassetteReplay, other, false, true, 0, errCassetteMiss},
+		{"auto 模式没有录制时真实调用", cassetteRecord, cassetteAuto, other, false, false, 0, nil},
+		{"回放模式不录制", cassetteReplay, cassetteReplay, img, false, true, 0, errCassetteMiss},
+		{"未开启时不录制", "", cassetteAuto, img, false, false, 0, nil},
+		{"未开启时不回放", cassetteRecord, "", img, false, false, 0, nil},
+		{"录制文件损坏", cassetteRecord, cassetteReplay, img, true, true, 0, errors.New("格式错误")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OCR_CASSETTE_DIR", t.TempDir())
+			t.Setenv("OCR_CASSETTE_MODE", tt.recordMode)
+			recordOCR(ex, nil, img)
+			if tt.corrupt {
+				os.WriteFile(cassettePath(ex, nil, imageHash(img)), []byte("{"), 0o644)
+			}
+
+			t.Setenv("OCR_CASSETTE_MODE", tt.replayMode)
+			trace := &DebugTrace{}
+			replay := ex
+			replay.RawOutput = ""
+			data, handled, err := replayOCR(replay, trace, nil, tt.replayImg)
+			if handled != tt.wantHandled || len(data) != tt.wantCount {
+				t.Fatalf("replayOCR() = %d lotteries, handled %v, want %d, %v", len(data), handled, tt.wantCount, tt.wantHandled)
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("replayOCR() error = %v", err)
+			case errors.Is(tt.wantErr, errCassetteMiss) && !errors.Is(err, errCassetteMiss):
+				t.Errorf("replayOCR() error = %v, want miss", err)
+			case tt.wantErr != nil && !strings.Contains(fmt.Sprint(err), tt.wantErr.Error()):
+				t.Errorf("replayOCR() error = %v, want %v", err, tt.wantErr)
+			}
+			// 命中录制时调试记录带原始输出
+			if tt.wantCount > 0 && (len(trace.Exchanges) != 1 || trace.Exchanges[0].RawOutput != ex.RawOutput) {
+				t.Errorf("trace = %+v", trace.Exchanges)
+			}
+		})
+	}
+}
+
+// 第二识别服务录制一次后可离线回放
+func TestSecondaryOCRCassette(t *testing.T) {
+	t.Setenv("OCR_CASSETTE_DIR", t.TempDir())
+	useTestTokenUsage(t)
+	useTestBilling(t)
+	old := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = old })
+	calls := 0
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		body, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": hookTestFixture}}}})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(body)), Header: http.Header{}}, nil
+	})
+	sec := secondaryOCR{BaseURL: "http://secondary.test/v1", APIKey: "k", Model: "gpt-4o-mini"}
+	img := []byte("\xff\xd8\xff\xe0secondary")
+
+	steps := []struct {
+		name      string
+		mode      string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"录制", cassetteRecord, 1, false},
+		{"回放不联网", cassetteReplay, 1, false},
+		{"auto 命中录制", cassetteAuto, 1, false},
+	}
+	for _, st := range steps {
+		t.Setenv("OCR_CASSETTE_MODE", st.mode)
+		data, err := sec.Recognize(context.Background(), img)
+		if (err != nil) != st.wantErr || len(data) != 2 {
+			t.Errorf("%s: Recognize() = %d lotteries, %v", st.name, len(data), err)
+		}
+		if calls != st.wantCalls {
+			t.Errorf("%s: %d HTTP calls, want %d", st.name, calls, st.wantCalls)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(cassetteDir(), imageHash(img)+".secondary.*.json"))
+	if len(files) != 1 {
+		t.Errorf("cassettes = %v", files)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ==========================================
+// CLAIM: 兑奖指引（按金额分档，可按部署地区配置）
+// ==========================================
+
+// 中奖结果（含 ?format=card / slip）里附带 claim：按中奖金额落在哪一档，给出兑奖地点、所需材料、
+// 税费说明和截止日期，客户端直接展示即可。默认分档见 defaultClaimRules，部署方可用
+// data/claim_rules.json 按本省规定替换；文案随 lang 翻译，自定义的文案在 data/i18n/<语言>.json 里补译文
+
+// ClaimTier 一档兑奖规则；MaxAmount 为本档上限（元，含），0 表示不设上限
+type ClaimTier struct {
+	MaxAmount    int64    `json:"max_amount"`
+	Where        string   `json:"where"` // 支持 {center} 占位符，替换为对应彩票中心
+	Documents    []string `json:"documents"`
+	Tax          string   `json:"tax,omitempty"`
+	DeadlineDays int      `json:"deadline_days,omitempty"` // 开奖后多少天内兑奖，默认 60
+	Notes        []string `json:"notes,omitempty"`
+}
+
+// ClaimRules data/claim_rules.json
+type ClaimRules struct {
+	Region string `json:"region"`
+	// Centers 福彩/体彩中心的名称与地址，如 {"福彩": "广东省福利彩票发行中心（广州市…）"}
+	Centers map[string]string `json:"centers"`
+	Tiers   []ClaimTier       `json:"tiers"`
+}
+
+// ClaimGuide 附在中奖结果里的兑奖指引
+type ClaimGuide struct {
+	Region    string   `json:"region,omitempty"`
+	Where     string   `json:"where"`
+	Documents []string `json:"documents"`
+	Tax       string   `json:"tax,omitempty"`
+	Deadline  string   `json:"deadline,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+var defaultClaimRules = ClaimRules{
+	Centers: map[string]string{"福彩": "省福利彩票发行中心兑奖大厅", "体彩": "省体育彩票管理中心兑奖大厅"},
+	Tiers: []ClaimTier{
+		{MaxAmount: 10000, Where: "本省任意{operator}销售网点", Documents: []string{"彩票原件"}, Tax: "单注奖金 1 万元及以下免征个人所得税"},
+		{
+			MaxAmount: 0,
+			Where:     "{center}",
+			Documents: []string{"彩票原件", "中奖人有效身份证件原件"},
+			Tax:       "单注奖金超过 1 万元需缴纳 20% 个人偶然所得税，由兑奖中心代扣",
+			Notes:     []string{"建议提前致电中心确认兑奖时间"},
+		},
+	},
+}
+
+type claimRuleStore struct {
+	mu    sync.RWMutex
+	rules ClaimRules
+}
+
+var claimRules = &claimRuleStore{rules: defaultClaimRules}
+
+// Load 读取地区兑奖规则，文件不存在时使用默认分档
+func (s *claimRuleStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var rules ClaimRules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return err
+	}
+	if len(rules.Tiers) == 0 {
+		rules.Tiers = defaultClaimRules.Tiers
+	}
+	if rules.Centers == nil {
+		rules.Centers = defaultClaimRules.Centers
+	}
+	// 按上限从小到大排，不设上限的一档放最后
+	sort.SliceStable(rules.Tiers, func(i, j int) bool {
+		a, b := rules.Tiers[i].MaxAmount, rules.Tiers[j].MaxAmount
+		return a != 0 && (b == 0 || a < b)
+	})
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+// operatorOf 双色球属福彩，大乐透/排列类属体彩
+func operatorOf(lotteryType string) string {
+	if canonicalGame(lotteryType) == "双色球" || strings.Contains(lotteryType, "福彩") {
+		return "福彩"
+	}
+	return "体彩"
+}
+
+// Guide 根据中奖金额选出兑奖指引，未中奖或没有匹配档位时返回 nil
+func (s *claimRuleStore) Guide(lotteryType, issue string, amount Fen) *ClaimGuide {
+	if amount <= 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	operator := operatorOf(lotteryType)
+	for _, tier := range s.rules.Tiers {
+		if tier.MaxAmount != 0 && amount > Fen(tier.MaxAmount)*Yuan {
+			continue
+		}
+		where := strings.NewReplacer(
+			"{center}", s.rules.Centers[operator],
+			"{operator}", operator,
+			"{region}", s.rules.Region,
+		).Replace(tier.Where)
+		guide := &ClaimGuide{
+			Region:    s.rules.Region,
+			Where:     where,
+			Documents: tier.Documents,
+			Tax:       tier.Tax,
+			Notes:     tier.Notes,
+		}
+		if d, ok := draws.Get(lotteryType, issue); ok && d.DrawDate != "" {
+			guide.Deadline = claimDeadlineAfter(d.DrawDate, cmp.Or(tier.DeadlineDays, claimPeriodDays))
+		}
+		return guide
+	}
+	return nil
+}
+
+// localizeClaim 翻译兑奖指引；指引可能与缓存的验奖结果共用，返回副本
+func localizeClaim(cat *messageCatalog, guide *ClaimGuide) *ClaimGuide {
+	if cat == nil || guide == nil {
+		return guide
+	}
+	out := *guide
+	out.Where, out.Tax = cat.T(guide.Where), cat.T(guide.Tax)
+	out.Documents, out.Notes = cat.all(guide.Documents), cat.all(guide.Notes)
+	return &out
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClaimGuide(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", DrawDate: "2025-09-16"})
+	tests := []struct {
+		name      string
+		game      string
+		issue     string
+		amount    Fen
+		wantNil   bool
+		where     string
+		documents int
+		deadline  bool
+	}{
+		{"未中奖", "双色球", "2025107", 0, true, "", 0, false},
+		{"小奖在销售网点兑", "双色球", "2025107", 200 * Yuan, false, "本省任意福彩销售网点", 1, true},
+		{"一万元整仍在网点", "大乐透", "2025107", 10000 * Yuan, false, "本省任意体彩销售网点", 1, false},
+		{"超过一万去中心", "双色球", "2025107", 10000*Yuan + 1, false, "省福利彩票发行中心兑奖大厅", 2, true},
+		{"体彩大奖", "排列5", "2025107", 100000 * Yuan, false, "省体育彩票管理中心兑奖大厅", 2, false},
+	}
+	s := &claimRuleStore{rules: defaultClaimRules}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := s.Guide(tt.game, tt.issue, tt.amount)
+			if (g == nil) != tt.wantNil {
+				t.Fatalf("guide = %+v, wantNil %v", g, tt.wantNil)
+			}
+			if g == nil {
+				return
+			}
+			if g.Where != tt.where || len(g.Documents) != tt.documents || (g.Deadline != "") != tt.deadline {
+				t.Errorf("guide = %+v, want where %q with %d documents, deadline %v", g, tt.where, tt.documents, tt.deadline)
+			}
+		})
+	}
+}
+
+func TestClaimRulesLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "claim_rules.json", []byte(`{
+		"region": "广东",
+		"tiers": [
+			{"max_amount": 0, "where": "{region}{center}", "documents": ["彩票原件", "身份证"]},
+			{"max_amount": 10000, "where": "地市{operator}中心", "documents": ["彩票原件"]},
+			{"max_amount": 1000, "where": "{operator}网点", "documents": ["彩票原件"], "deadline_days": 30}
+		]
+	}`))
+	s := &claimRuleStore{rules: defaultClaimRules}
+	if err := s.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		amount Fen
+		where  string
+	}{
+		{"最低一档", 500 * Yuan, "体彩网点"},
+		{"中间一档", 5000 * Yuan, "地市体彩中心"},
+		{"不设上限的一档排最后", 50000 * Yuan, "广东省体育彩票管理中心兑奖大厅"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if g := s.Guide("大乐透", "2025107", tt.amount); g == nil || g.Where != tt.where || g.Region != "广东" {
+				t.Errorf("guide = %+v, want where %q", g, tt.where)
+			}
+		})
+	}
+	if err := s.Load(dir + "/missing.json"); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+// 每档的税费说明随指引返回，截止日按本档的 deadline_days 计，未配置时为 60 天
+func TestClaimGuideTaxAndDeadline(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "大乐透", Issue: "25107", DrawDate: "2025-09-16"})
+	s := &claimRuleStore{rules: ClaimRules{Centers: defaultClaimRules.Centers, Tiers: []ClaimTier{
+		{MaxAmount: 1000, Where: "{operator}网点", Tax: "免税", DeadlineDays: 30},
+		{MaxAmount: 0, Where: "{center}", Tax: "代扣 20%"},
+	}}}
+	tests := []struct {
+		name         string
+		issue        string
+		amount       Fen
+		wantTax      string
+		wantDeadline string
+	}{
+		{"本档配置了天数", "25107", 500 * Yuan, "免税", "2025-10-16"},
+		{"默认 60 天", "25107", 5000 * Yuan, "代扣 20%", "2025-11-15"},
+		{"没有开奖日期不给截止日", "25108", 500 * Yuan, "免税", ""},
+	}
+	for _, tt := range tests {
+		g := s.Guide("大乐透", tt.issue, tt.amount)
+		if g == nil || g.Tax != tt.wantTax || g.Deadline != tt.wantDeadline {
+			t.Errorf("%s: guide = %+v, want tax %q deadline %q", tt.name, g, tt.wantTax, tt.wantDeadline)
+		}
+	}
+
+	// 默认分档：万元以下免税，以上代扣
+	def := &claimRuleStore{rules: defaultClaimRules}
+	if g := def.Guide("双色球", "", 200*Yuan); !strings.Contains(g.Tax, "免征") {
+		t.Errorf("small prize tax = %q", g.Tax)
+	}
+	if g := def.Guide("双色球", "", 20000*Yuan); !strings.Contains(g.Tax, "20%") || len(g.Notes) != 1 {
+		t.Errorf("large prize guide = %+v", g)
+	}
+}
+
+func TestLocalizeClaim(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	guide := (&claimRuleStore{rules: defaultClaimRules}).Guide("双色球", "", 20000*Yuan)
+	tests := []struct {
+		name      string
+		cat       *messageCatalog
+		wantWhere string
+		wantTax   string
+		wantDoc   string
+	}{
+		{"简体不翻译", nil, "省福利彩票发行中心兑奖大厅", "单注奖金超过 1 万元需缴纳 20% 个人偶然所得税，由兑奖中心代扣", "中奖人有效身份证件原件"},
+		{"英文", catalogFor("en"), "the claim hall of the provincial Welfare Lottery center",
+			"Prizes over 10,000 yuan per bet are subject to 20% income tax, withheld by the claim center", "the winner's original valid ID"},
+	}
+	for _, tt := range tests {
+		got := localizeClaim(tt.cat, guide)
+		if got.Where != tt.wantWhere || got.Tax != tt.wantTax || got.Documents[1] != tt.wantDoc {
+			t.Errorf("%s: localizeClaim() = %+v", tt.name, got)
+		}
+	}
+	if guide.Where != "省福利彩票发行中心兑奖大厅" || guide.Documents[1] != "中奖人有效身份证件原件" {
+		t.Errorf("localizeClaim modified its input: %+v", guide)
+	}
+	if localizeClaim(catalogFor("en"), nil) != nil {
+		t.Error("localizeClaim(nil) != nil")
+	}
+	// 网点一档的地点带彩种类别占位
+	small := (&claimRuleStore{rules: defaultClaimRules}).Guide("大乐透", "", 200*Yuan)
+	if got := localizeClaim(catalogFor("en"), small).Where; got != "any Sports Lottery retailer in the province" {
+		t.Errorf("small prize where = %q", got)
+	}
+}
@@ -0,0 +1,372 @@
+// Package client 彩票扫描服务的 Go 客户端。
+//
+// 应用代码只需要一个阻塞调用：
+//
+//	c := client.New("https://lottery.example.com", "sk_live_…")
+//	c.UserID = "u-1001"
+//	results, err := c.ScanFile("ticket.jpg")
+//
+// ScanFile 在内部依次完成：
+//
+//  1. 按图片 SHA-256 调用 POST /api/v1/scan/precheck，服务端已有结果时直接返回，不再上传
+//  2. 通过 /api/v1/uploads 分块断点续传，网络中断时查询已收到的字节数后从断点继续
+//  3. 以 POST /api/v1/scan?async=true 提交，轮询 GET /api/v1/jobs/:id 直到完成
+//
+// 需要人工复核的票返回 *ReviewError，服务端返回的错误为 *APIError
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client 一个服务端地址与凭证；字段可在首次调用前修改
+type Client struct {
+	BaseURL    string
+	APIKey     string // X-API-Key，为空时不带
+	TenantID   string // X-Tenant-ID
+	UserID     string // X-User-ID，用于汇总个人扫描记录；服务端只在带 API Key 时采信
+	HTTPClient *http.Client
+
+	ChunkSize    int64         // 上传分块大小，默认 1MB
+	MaxRetries   int           // 单个分块连续失败的重试次数，默认 5
+	PollInterval time.Duration // 轮询任务状态的间隔，默认 1s
+}
+
+// New 创建客户端
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		HTTPClient:   &http.Client{Timeout: 60 * time.Second},
+		ChunkSize:    1 << 20,
+		MaxRetries:   5,
+		PollInterval: time.Second,
+	}
+}
+
+// Ticket 一行号码
+type Ticket struct {
+	Red        []string `json:"red"`
+	Blue       []string `json:"blue"`
+	Multiplier int      `json:"multiplier"`
+	Mode       string   `json:"mode"`
+	Dan        []string `json:"dan,omitempty"`
+	BlueDan    []string `json:"blue_dan,omitempty"`
+	AddOn      bool     `json:"add_on,omitempty"`
+}
+
+// Lottery 识别出的一张票
+type Lottery struct {
+	Type     string    `json:"type"`
+	Issue    string    `json:"issue"`
+	SaleTime string    `json:"sale_time,omitempty"`
+	Serial   string    `json:"serial,omitempty"`
+	Station  string    `json:"station,omitempty"`
+	Tickets  []Ticket  `json:"tickets"`
+	Sections []Lottery `json:"sections,omitempty"`
+}
+
+// Detail 一行号码的验奖结果
+type Detail struct {
+	RowIndex  int    `json:"row_index"`
+	Level     int    `json:"level"`
+	PrizeFen  int64  `json:"prize_fen"`
+	Status    string `json:"status"`
+	Estimated bool   `json:"estimated,omitempty"`
+	BetType   string `json:"bet_type,omitempty"`
+}
+
+// Result 一张票的验奖结果，金额单位为分
+type Result struct {
+	TicketIndex   int             `json:"ticket_index"`
+	OCRData       Lottery         `json:"ocr_data"`
+	TotalPrizeFen int64           `json:"total_prize_fen"`
+	Details       []Detail        `json:"details"`
+	Pending       bool            `json:"pending,omitempty"`
+	Warnings      []string        `json:"warnings,omitempty"`
+	Rejected      bool            `json:"rejected,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	DuplicateOf   string          `json:"duplicate_of,omitempty"`
+	Claim         json.RawMessage `json:"claim,omitempty"`
+	Sections      []Result        `json:"sections,omitempty"`
+}
+
+// APIError 服务端返回的错误
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("lottery: HTTP %d: %s", e.Status, e.Message)
+}
+
+// ReviewError 高额票识别结果不一致，已转人工复核；复核结果稍后经 webhook 或任务状态获得
+type ReviewError struct {
+	ReviewID string
+	JobID    string
+}
+
+func (e *ReviewError) Error() string {
+	return "lottery: 需要人工复核 (review " + e.ReviewID + ")"
+}
+
+// ScanFile 扫描一张本地图片，阻塞直到拿到验奖结果
+func (c *Client) ScanFile(path string) ([]Result, error) {
+	return c.ScanFileContext(context.Background(), path)
+}
+
+// ScanFileContext 同 ScanFile，可通过 ctx 取消或设置总超时
+func (c *Client) ScanFileContext(ctx context.Context, path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Scan(ctx, data)
+}
+
+// Scan 扫描内存中的图片
+func (c *Client) Scan(ctx context.Context, image []byte) ([]Result, error) {
+	sum := sha256.Sum256(image)
+	if results, ok, err := c.precheck(ctx, hex.EncodeToString(sum[:])); err != nil || ok {
+		return results, err
+	}
+	uploadID, err := c.upload(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return c.submit(ctx, uploadID)
+}
+
+// precheck 服务端已有该图片的结果时返回 ok
+func (c *Client) precheck(ctx context.Context, hash string) ([]Result, bool, error) {
+	body, _ := json.Marshal(map[string]string{"image_hash": hash})
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/scan/precheck", bytes.NewReader(body), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, apiError(resp)
+	}
+	var results []Result
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// upload 创建上传会话并分块上传，返回 upload_id
+func (c *Client) upload(ctx context.Context, image []byte) (string, error) {
+	length := int64(len(image))
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/uploads", nil, map[string]string{"Upload-Length": strconv.FormatInt(length, 10)})
+	if err != nil {
+		return "", err
+	}
+	var session struct {
+		ID string `json:"upload_id"`
+	}
+	err = decodeJSON(resp, http.StatusCreated, &session)
+	if err != nil {
+		return "", err
+	}
+
+	chunk := c.ChunkSize
+	if chunk <= 0 {
+		chunk = 1 << 20
+	}
+	var offset int64
+	for failures := 0; offset < length; {
+		end := min(offset+chunk, length)
+		next, err := c.patch(ctx, session.ID, offset, image[offset:end])
+		if err == nil {
+			offset, failures = next, 0
+			continue
+		}
+		var apiErr *APIError
+		if ctx.Err() != nil || failures >= c.MaxRetries || errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return "", err
+		}
+		failures++
+		if err := sleep(ctx, time.Duration(failures)*time.Second); err != nil {
+			return "", err
+		}
+		// 断点续传：以服务端实际收到的字节数为准
+		if got, herr := c.uploadOffset(ctx, session.ID); herr == nil {
+			offset = got
+		}
+	}
+	return session.ID, nil
+}
+
+func (c *Client) patch(ctx context.Context, id string, offset int64, chunk []byte) (int64, error) {
+	resp, err := c.do(ctx, http.MethodPatch, "/api/v1/uploads/"+id, bytes.NewReader(chunk), map[string]string{
+		"Upload-Offset": strconv.FormatInt(offset, 10),
+		"Content-Type":  "application/offset+octet-stream",
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, apiError(resp)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+func (c *Client) uploadOffset(ctx context.Context, id string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/api/v1/uploads/"+id, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &APIError{Status: resp.StatusCode, Message: resp.Status}
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// submit 以异步方式提交扫描并等待任务完成
+func (c *Client) submit(ctx context.Context, uploadID string) ([]Result, error) {
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	w.WriteField("upload_id", uploadID)
+	w.WriteField("async", "true")
+	w.Close()
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/scan", &form, map[string]string{"Content-Type": w.FormDataContentType()})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// 不支持 async 的旧版服务端直接返回结果
+		var results []Result
+		return results, json.Unmarshal(raw, &results)
+	case http.StatusAccepted:
+		var accepted struct {
+			JobID    string `json:"job_id"`
+			ReviewID string `json:"review_id"`
+		}
+		if err := json.Unmarshal(raw, &accepted); err != nil {
+			return nil, err
+		}
+		if accepted.JobID == "" {
+			return nil, &ReviewError{ReviewID: accepted.ReviewID}
+		}
+		return c.wait(ctx, accepted.JobID)
+	}
+	return nil, apiErrorFrom(resp.StatusCode, raw)
+}
+
+// wait 轮询任务直到完成、失败或转人工复核
+func (c *Client) wait(ctx context.Context, jobID string) ([]Result, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		resp, err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+jobID, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var job struct {
+			Status   string   `json:"status"`
+			Results  []Result `json:"results"`
+			Error    string   `json:"error"`
+			ReviewID string   `json:"review_id"`
+		}
+		if err := decodeJSON(resp, http.StatusOK, &job); err != nil {
+			return nil, err
+		}
+		switch job.Status {
+		case "DONE":
+			return job.Results, nil
+		case "FAILED":
+			return nil, &APIError{Status: http.StatusUnprocessableEntity, Message: job.Error}
+		case "REVIEW":
+			return nil, &ReviewError{ReviewID: job.ReviewID, JobID: jobID}
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.TenantID != "" {
+		req.Header.Set("X-Tenant-ID", c.TenantID)
+	}
+	if c.UserID != "" {
+		req.Header.Set("X-User-ID", c.UserID)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return hc.Do(req)
+}
+
+func decodeJSON(resp *http.Response, want int, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		return apiError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func apiError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return apiErrorFrom(resp.StatusCode, raw)
+}
+
+func apiErrorFrom(status int, raw []byte) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(raw))
+	}
+	return &APIError{Status: status, Message: body.Error}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer 模拟服务端的预检、分块上传、异步扫描和任务查询
+type fakeServer struct {
+	mu        sync.Mutex
+	precheck  int         // 预检返回的状态码，200 时返回 results
+	failPatch map[int]int // 第 n 次 PATCH（从 1 开始）返回的错误状态码
+	submit    int         // 提交扫描返回的状态码
+	submitRaw string      // 提交扫描的响应
+	jobs      []string    // 依次返回的任务状态
+	results   string
+
+	patches  int
+	received []byte
+	calls    []string
+	headers  http.Header
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, r.Method+" "+r.URL.Path)
+	s.headers = r.Header.Clone()
+	switch {
+	case r.URL.Path == "/api/v1/scan/precheck":
+		if s.precheck != http.StatusOK {
+			w.WriteHeader(s.precheck)
+			return
+		}
+		io.WriteString(w, s.results)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/uploads":
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"upload_id": "up1"}`)
+	case r.Method == http.MethodPatch:
+		s.patches++
+		if status := s.failPatch[s.patches]; status != 0 {
+			// 模拟连接中断前已经收到了一部分
+			s.received = append(s.received, readAll(r)[:1]...)
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error": "中断"}`)
+			return
+		}
+		if off, _ := strconv.Atoi(r.Header.Get("Upload-Offset")); off != len(s.received) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.received = append(s.received, readAll(r)...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.received)))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.received)))
+	case r.URL.Path == "/api/v1/scan":
+		if r.FormValue("upload_id") != "up1" || r.FormValue("async") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(s.submit)
+		io.WriteString(w, s.submitRaw)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/jobs/"):
+		status := s.jobs[0]
+		if len(s.jobs) > 1 {
+			s.jobs = s.jobs[1:]
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status, "results": json.RawMessage(s.results), "error": "识别失败", "review_id": "rv1"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func readAll(r *http.Request) []byte {
+	raw, _ := io.ReadAll(r.Body)
+	return raw
+}
+
+const fakeResults = `[{"ticket_index": 0, "ocr_data": {"type": "双色球", "issue": "2025107", "tickets": []}, "total_prize_fen": 300000}]`
+
+func TestScan(t *testing.T) {
+	image := []byte("0123456789abcdefghij")
+	tests := []struct {
+		name       string
+		server     *fakeServer
+		wantPrize  int64
+		wantErr    string // 错误类型：api、review
+		wantStatus int
+		wantCalls  string // 按顺序的请求，相同的连续请求只记一次
+	}{
+		{"预检命中不上传", &fakeServer{precheck: 200}, 300000, "", 0,
+			"POST /api/v1/scan/precheck"},
+		{"分块上传后轮询到完成", &fakeServer{precheck: 204, submit: 202, submitRaw: `{"job_id": "j1"}`, jobs: []string{"QUEUED", "RUNNING", "DONE"}}, 300000, "", 0,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan,GET /api/v1/jobs/j1"},
+		{"分块失败后从服务端的断点续传", &fakeServer{precheck: 204, failPatch: map[int]int{2: 502}, submit: 202, submitRaw: `{"job_id": "j1"}`, jobs: []string{"DONE"}}, 300000, "", 0,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,HEAD /api/v1/uploads/up1,PATCH /api/v1/uploads/up1,POST /api/v1/scan,GET /api/v1/jobs/j1"},
+		{"上传会话不存在时不重试", &fakeServer{precheck: 204, failPatch: map[int]int{1: 404}}, 0, "api", 404,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1"},
+		{"旧版服务端直接返回结果", &fakeServer{precheck: 204, submit: 200, submitRaw: fakeResults}, 300000, "", 0,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan"},
+		{"提交时转人工复核", &fakeServer{precheck: 204, submit: 202, submitRaw: `{"review_id": "rv1"}`}, 0, "review", 0,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan"},
+		{"任务转人工复核", &fakeServer{precheck: 204, submit: 202, submitRaw: `{"job_id": "j1"}`, jobs: []string{"REVIEW"}}, 0, "review", 0,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan,GET /api/v1/jobs/j1"},
+		{"任务失败", &fakeServer{precheck: 204, submit: 202, submitRaw: `{"job_id": "j1"}`, jobs: []string{"FAILED"}}, 0, "api", 422,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan,GET /api/v1/jobs/j1"},
+		{"提交被拒绝", &fakeServer{precheck: 204, submit: 429, submitRaw: `{"error": "请求过于频繁"}`}, 0, "api", 429,
+			"POST /api/v1/scan/precheck,POST /api/v1/uploads,PATCH /api/v1/uploads/up1,POST /api/v1/scan"},
+		{"预检出错", &fakeServer{precheck: 401}, 0, "api", 401,
+			"POST /api/v1/scan/precheck"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := tt.server
+			fs.results = fakeResults
+			srv := httptest.NewServer(fs)
+			defer srv.Close()
+
+			c := New(srv.URL+"/", "sk_test")
+			c.TenantID, c.UserID = "shop-a", "u-1"
+			c.ChunkSize, c.PollInterval = 8, time.Millisecond
+			results, err := c.Scan(context.Background(), image)
+
+			var apiErr *APIError
+			var reviewErr *ReviewError
+			switch tt.wantErr {
+			case "":
+				if err != nil {
+					t.Fatal(err)
+				}
+			case "api":
+				if !errors.As(err, &apiErr) || apiErr.Status != tt.wantStatus {
+					t.Fatalf("err = %v, want APIError %d", err, tt.wantStatus)
+				}
+			case "review":
+				if !errors.As(err, &reviewErr) || reviewErr.ReviewID != "rv1" {
+					t.Fatalf("err = %v, want ReviewError", err)
+				}
+			}
+			if tt.wantPrize > 0 && (len(results) != 1 || results[0].TotalPrizeFen != tt.wantPrize || results[0].OCRData.Issue != "2025107") {
+				t.Errorf("results = %+v", results)
+			}
+			var calls []string
+			for _, call := range fs.calls {
+				if len(calls) == 0 || calls[len(calls)-1] != call {
+					calls = append(calls, call)
+				}
+			}
+			if got := strings.Join(calls, ","); got != tt.wantCalls {
+				t.Errorf("calls = %s\nwant %s", got, tt.wantCalls)
+			}
+			if fs.patches > 0 && tt.wantErr == "" && string(fs.received) != string(image) {
+				t.Errorf("received = %q", fs.received)
+			}
+			if fs.headers.Get("X-API-Key") != "sk_test" || fs.headers.Get("X-Tenant-ID") != "shop-a" || fs.headers.Get("X-User-ID") != "u-1" {
+				t.Errorf("headers = %v", fs.headers)
+			}
+		})
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	fs := &fakeServer{precheck: 200, results: fakeResults}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+	c := New(srv.URL, "")
+
+	path := filepath.Join(t.TempDir(), "ticket.jpg")
+	if err := os.WriteFile(path, []byte("ticket"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := c.ScanFile(path); err != nil || len(results) != 1 {
+		t.Errorf("ScanFile() = %+v, %v", results, err)
+	}
+	if fs.headers.Get("X-API-Key") != "" {
+		t.Errorf("empty API key sent: %v", fs.headers)
+	}
+	if _, err := c.ScanFile(filepath.Join(t.TempDir(), "missing.jpg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
+
+// 轮询中取消时返回 ctx 的错误
+func TestScanCanceled(t *testing.T) {
+	fs := &fakeServer{precheck: 204, submit: 202, submitRaw: `{"job_id": "j1"}`, jobs: []string{"RUNNING"}, results: fakeResults}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+	c := New(srv.URL, "")
+	c.PollInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Scan(ctx, []byte("ticket")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestAPIErrorFrom(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`{"error": "图片过大"}`, "lottery: HTTP 400: 图片过大"},
+		{"bad gateway\n", "lottery: HTTP 400: bad gateway"},
+		{`{"code": "X"}`, `lottery: HTTP 400: {"code": "X"}`},
+	}
+	for _, tt := range tests {
+		if got := apiErrorFrom(400, []byte(tt.raw)).Error(); got != tt.want {
+			t.Errorf("apiErrorFrom(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+	}
+}
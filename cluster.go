@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// CLUSTER: 多副本部署的共享状态
+// ==========================================
+
+// 默认所有运行时状态都在进程内存里，只适合单副本。多副本部署在负载均衡之后时设置 STATE_BACKEND=redis
+// （连接参数同 jobstore.go 的 REDIS_URL / REDIS_PREFIX），以下状态改由 Redis 共享：
+//
+//	识别 / 验奖缓存   本地 LRU 仍作为一级缓存，未命中再查 Redis
+//	API Key 配额     计数改为 Redis 原子计数，不再依赖共享目录的锁文件
+//	异常上传检测     每个 IP 一分钟内的图片集合与可疑标记
+//	重复扫描索引     同一用户上传完全相同的图片时，不论落在哪个副本都能关联到原记录
+//	飞书事件去重     重推的事件可能落到另一个副本
+//	排队任务         未单独配置 JOBS_BACKEND 时同样使用 Redis
+//
+// 开奖同步只由选举出的 leader 执行（Redis 锁，租期为同步间隔的 3 倍），leader 同步到的新开奖通过
+// Redis 频道广播，各副本更新本地开奖数据、推送给本副本的订阅连接并检查本副本登记的待开奖票据。
+// 扫描历史、复核单等仍保存在各副本的数据目录；Redis 暂时不可用时回退到本地状态并记录日志
+
+var sharedRedis *redis.Client
+
+// instanceID 本副本的标识，用于 leader 锁
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), newJobID()[:6])
+}()
+
+// initSharedState 按 STATE_BACKEND 连接共享状态后端
+func initSharedState() error {
+	switch strings.ToLower(os.Getenv("STATE_BACKEND")) {
+	case "", "memory":
+		return nil
+	case "redis":
+		client, err := redisClient()
+		if err != nil {
+			return err
+		}
+		sharedRedis = client
+		if os.Getenv("JOBS_BACKEND") == "" {
+			os.Setenv("JOBS_BACKEND", "redis")
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的 STATE_BACKEND: %s", os.Getenv("STATE_BACKEND"))
+	}
+}
+
+func sharedKey(parts ...string) string { return redisPrefix() + strings.Join(parts, ":") }
+
+// logSharedErr Redis 出错时记录并回退到本地状态
+func logSharedErr(what string, err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+	log.Printf("共享状态 %s 失败，使用本地状态: %v", what, err)
+	return true
+}
+
+// ------------------------------------------
+// 缓存
+// ------------------------------------------
+
+// sharedCache 本地 LRU + 可选的 Redis 二级缓存；值按 DATA_ENCRYPTION_KEY 加密后写入 Redis
+type sharedCache[V any] struct {
+	name  string
+	local *ttlCache[V]
+}
+
+func newSharedCache[V any](name string, capacity int, ttl time.Duration) *sharedCache[V] {
+	return &sharedCache[V]{name: name, local: newTTLCache[V](capacity, ttl)}
+}
+
+func (c *sharedCache[V]) Get(key string) (V, bool) {
+	if v, ok := c.local.Get(key); ok || sharedRedis == nil || c.local.capacity <= 0 {
+		return v, ok
+	}
+	var zero V
+	ctx, cancel := redisCtx()
+	defer cancel()
+	raw, err := sharedRedis.Get(ctx, sharedKey("cache", c.name, key)).Bytes()
+	if logSharedErr("读取缓存", err) || err != nil {
+		return zero, false
+	}
+	plain, err := atRest.Open(raw)
+	if err != nil {
+		return zero, false
+	}
+	var v V
+	if err := json.Unmarshal(plain, &v); err != nil {
+		return zero, false
+	}
+	c.local.Set(key, v)
+	return v, true
+}
+
+func (c *sharedCache[V]) Set(key string, value V) {
+	c.local.Set(key, value)
+	if sharedRedis == nil || c.local.capacity <= 0 {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	sealed, err := atRest.Seal(raw)
+	if err != nil {
+		return
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	logSharedErr("写入缓存", sharedRedis.Set(ctx, sharedKey("cache", c.name, key), sealed, c.local.ttl).Err())
+}
+
+// Purge 清空本副本的缓存和 Redis 中的共享条目；其他副本的本地缓存仍要等 TTL 过期
+func (c *sharedCache[V]) Purge() {
+	c.local.Purge()
+	if sharedRedis == nil {
+		return
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	iter := sharedRedis.Scan(ctx, 0, sharedKey("cache", c.name, "*"), 500).Iterator()
+	for iter.Next(ctx) {
+		logSharedErr("清空缓存", sharedRedis.Del(ctx, iter.Val()).Err())
+	}
+	logSharedErr("清空缓存", iter.Err())
+}
+
+// ------------------------------------------
+// 重复扫描索引
+// ------------------------------------------
+
+// dedupRetention 重复扫描索引的保留时间
+func dedupRetention() time.Duration { return envDuration("DEDUP_RETENTION", 180*24*time.Hour) }
+
+func dedupKey(r ScanRecord) string {
+	return sharedKey("dedup", r.Tenant, r.UserID, r.ImageHash)
+}
+
+// sharedDuplicateOf 其他副本记录过同一用户的同一张图片时返回原记录编号
+func sharedDuplicateOf(r ScanRecord) (string, bool) {
+	if sharedRedis == nil || r.ImageHash == "" || duplicateDistance() <= 0 {
+		return "", false
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	id, err := sharedRedis.Get(ctx, dedupKey(r)).Result()
+	if logSharedErr("查询重复扫描", err) || err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// rememberScan 登记新记录，供其他副本查重
+func rememberScan(r ScanRecord) {
+	if sharedRedis == nil || r.ImageHash == "" {
+		return
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	logSharedErr("登记重复扫描索引", sharedRedis.SetNX(ctx, dedupKey(r), r.ID, dedupRetention()).Err())
+}
+
+// ------------------------------------------
+// leader 选举
+// ------------------------------------------
+
+// renewLeaderScript 锁仍归自己时续期
+var renewLeaderScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+// acquireLeadership 尝试成为 task 的 leader 或为已持有的锁续期；未启用共享状态时总是 leader
+func acquireLeadership(task string, ttl time.Duration) bool {
+	if sharedRedis == nil {
+		return true
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	key := sharedKey("leader", task)
+	ok, err := sharedRedis.SetNX(ctx, key, instanceID, ttl).Result()
+	if err != nil {
+		// Redis 不可用时宁可暂停同步，也不要多个副本同时执行
+		log.Printf("leader 选举失败 (%s): %v", task, err)
+		return false
+	}
+	if ok {
+		log.Printf("本副本 %s 成为 %s 的 leader", instanceID, task)
+		return true
+	}
+	renewed, err := renewLeaderScript.Run(ctx, sharedRedis, []string{key}, instanceID, ttl.Milliseconds()).Int()
+	return err == nil && renewed == 1
+}
+
+// ------------------------------------------
+// 开奖广播
+// ------------------------------------------
+
+// broadcastDraws leader 把新开奖广播给其他副本
+func broadcastDraws(changed []DrawRecord) {
+	if sharedRedis == nil || len(changed) == 0 {
+		return
+	}
+	raw, err := json.Marshal(drawBroadcast{From: instanceID, Draws: changed})
+	if err != nil {
+		return
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	logSharedErr("广播开奖", sharedRedis.Publish(ctx, sharedKey("draws"), raw).Err())
+}
+
+type drawBroadcast struct {
+	From  string       `json:"from"`
+	Draws []DrawRecord `json:"draws"`
+}
+
+// followDraws 接收其他副本同步到的开奖，断线后由客户端自动重连
+func followDraws() {
+	if sharedRedis == nil {
+		return
+	}
+	sub := sharedRedis.Subscribe(context.Background(), sharedKey("draws"))
+	for msg := range sub.Channel() {
+		var b drawBroadcast
+		if err := json.Unmarshal([]byte(msg.Payload), &b); err != nil || b.From == instanceID {
+			continue
+		}
+		changed, err := draws.Upsert(b.Draws)
+		if err != nil {
+			log.Printf("保存广播的开奖数据失败: %v", err)
+		}
+		drawFeed.Publish(changed)
+		pendingTickets.OnDraws(changed)
+	}
+}
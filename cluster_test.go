@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// useTestSharedRedis 换成连不上的 Redis，模拟共享状态后端暂时不可用
+func useTestSharedRedis(t *testing.T) {
+	t.Helper()
+	old := sharedRedis
+	t.Cleanup(func() { sharedRedis = old })
+	sharedRedis = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: time.Second})
+	t.Cleanup(func() { sharedRedis.Close() })
+}
+
+func TestInitSharedState(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		url     string
+		wantErr string
+	}{
+		{"默认进程内存", "", "", ""},
+		{"指定进程内存", "memory", "", ""},
+		{"Redis 未配置地址", "redis", "", "REDIS_URL"},
+		{"Redis 连接失败", "redis", "redis://127.0.0.1:1/0?max_retries=-1", "连接 Redis 失败"},
+		{"未知后端", "etcd", "", "未知的 STATE_BACKEND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := sharedRedis
+			t.Cleanup(func() { sharedRedis = old })
+			sharedRedis = nil
+			t.Setenv("STATE_BACKEND", tt.backend)
+			t.Setenv("REDIS_URL", tt.url)
+			t.Setenv("JOBS_BACKEND", "")
+			err := initSharedState()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("initSharedState() error = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("initSharedState() error = %v, want %q", err, tt.wantErr)
+			}
+			if sharedRedis != nil {
+				t.Error("sharedRedis set without a working connection")
+			}
+		})
+	}
+}
+
+func TestLogSharedErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"成功", nil, false},
+		{"键不存在不算故障", redis.Nil, false},
+		{"连接失败", errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		if got := logSharedErr("测试", tt.err); got != tt.want {
+			t.Errorf("%s: logSharedErr() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 未启用共享状态时只用本地状态，开奖同步总由本副本执行
+func TestSharedStateDisabled(t *testing.T) {
+	old := sharedRedis
+	t.Cleanup(func() { sharedRedis = old })
+	sharedRedis = nil
+
+	c := newSharedCache[int]("test", 10, time.Minute)
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get() = %d, %v", v, ok)
+	}
+	c.Purge()
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() after Purge hit")
+	}
+	if !acquireLeadership("draw-sync", time.Minute) {
+		t.Error("acquireLeadership() = false without shared state")
+	}
+	if _, ok := sharedDuplicateOf(ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "abc"}); ok {
+		t.Error("sharedDuplicateOf() found a record without shared state")
+	}
+}
+
+// Redis 暂时不可用时缓存、配额、异常检测回退到本地状态，开奖同步暂停
+func TestSharedStateFallback(t *testing.T) {
+	useTestSharedRedis(t)
+	useTestAbuse(t)
+	key := APIKey{Key: "sk-a", Tenant: "shop-a", DailyQuota: 2}
+	useTestAPIKeys(t, key)
+	t.Setenv("ABUSE_IMAGES_PER_MINUTE", "1")
+
+	tests := []struct {
+		name string
+		run  func() bool
+	}{
+		{"缓存使用本地 LRU", func() bool {
+			c := newSharedCache[int]("test", 10, time.Minute)
+			c.Set("a", 1)
+			v, ok := c.Get("a")
+			c.Purge()
+			_, after := c.Get("a")
+			return ok && v == 1 && !after
+		}},
+		{"本地未命中时不报错", func() bool {
+			_, ok := newSharedCache[int]("test", 10, time.Minute).Get("missing")
+			return !ok
+		}},
+		{"配额回退到计数文件", func() bool {
+			_, err1 := quotas.Add(key, 1)
+			_, err2 := quotas.Add(key, 1)
+			_, err3 := quotas.Add(key, 1)
+			return err1 == nil && err2 == nil && errors.Is(err3, errQuotaExceeded) && quotas.Usage(key).DayCount == 2
+		}},
+		{"异常检测回退到本地计数", func() bool {
+			return !abuse.Observe("192.0.2.1", []string{"a"}) && abuse.Observe("192.0.2.1", []string{"b"})
+		}},
+		{"查重只看本副本", func() bool {
+			rememberScan(ScanRecord{ID: "r1", Tenant: "shop-a", UserID: "u1", ImageHash: "abc"})
+			_, ok := sharedDuplicateOf(ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "abc"})
+			return !ok
+		}},
+		{"选举失败时暂停开奖同步", func() bool {
+			return !acquireLeadership("draw-sync", time.Minute)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.run() {
+				t.Error("unexpected result")
+			}
+		})
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	t.Setenv("REDIS_PREFIX", "")
+	r := ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "abc"}
+	if got := dedupKey(r); got != "lottery:dedup:shop-a:u1:abc" {
+		t.Errorf("dedupKey() = %q", got)
+	}
+	t.Setenv("REDIS_PREFIX", "stage:")
+	if got := sharedKey("leader", "draw-sync"); got != "stage:leader:draw-sync" {
+		t.Errorf("sharedKey() = %q", got)
+	}
+}
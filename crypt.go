@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ==========================================
+// CRYPT: 落盘数据加密（信封加密，AES-256-GCM）
+// ==========================================
+
+// 配置主密钥后，保存的原图、缩略图、排队/复核/调试图片与元数据、待开奖票据、兑奖提醒，
+// 以及扫描记录中的票面内容和用户标识都加密落盘，数据目录或备份泄露不会暴露用户的彩票。
+// 每个文件（每条扫描记录）随机生成数据密钥加密内容，数据密钥再用主密钥加密后一起保存。
+// 主密钥为 32 字节，按以下顺序取第一个配置了的：
+//
+//	DATA_ENCRYPTION_KEY       base64 编码的主密钥
+//	DATA_ENCRYPTION_KEY_FILE  主密钥文件（base64），适合由密钥管理服务挂载
+//	DATA_ENCRYPTION_KEY_CMD   执行命令，标准输出为 base64 主密钥，如调用 KMS 解密数据密钥的 CLI
+//
+// 更换主密钥时把旧密钥放进 DATA_ENCRYPTION_OLD_KEYS（逗号分隔），旧数据仍可读取，新写入用新密钥。
+// 未配置主密钥时照常明文保存；开启后以前的明文文件仍可读取。断点续传的分片是临时文件，不加密
+
+// sealedMagic 加密文件的前缀，读取时据此区分明文旧数据
+var sealedMagic = []byte("LSE1")
+
+const keyIDLen = 4
+
+var errSealedNoKey = errors.New("数据已加密，但未配置主密钥")
+
+type atRestCipher struct {
+	current []byte            // 新数据使用的主密钥
+	keys    map[string][]byte // 密钥 ID → 主密钥，含旧密钥
+}
+
+var atRest = &atRestCipher{}
+
+// Enabled 是否配置了主密钥
+func (a *atRestCipher) Enabled() bool { return a.current != nil }
+
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return string(sum[:keyIDLen])
+}
+
+func decodeMasterKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("主密钥不是合法的 base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("主密钥应为 32 字节，实际 %d 字节", len(key))
+	}
+	return key, nil
+}
+
+// loadAtRestKeys 按环境变量加载主密钥，未配置时不加密
+func loadAtRestKeys() error {
+	raw := os.Getenv("DATA_ENCRYPTION_KEY")
+	if raw == "" {
+		if p := os.Getenv("DATA_ENCRYPTION_KEY_FILE"); p != "" {
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			raw = string(b)
+		}
+	}
+	if raw == "" {
+		if cmd := os.Getenv("DATA_ENCRYPTION_KEY_CMD"); cmd != "" {
+			out, err := exec.Command("sh", "-c", cmd).Output()
+			if err != nil {
+				return fmt.Errorf("执行 DATA_ENCRYPTION_KEY_CMD 失败: %v", err)
+			}
+			raw = string(out)
+		}
+	}
+	if raw == "" {
+		return nil
+	}
+	key, err := decodeMasterKey(raw)
+	if err != nil {
+		return err
+	}
+	a := &atRestCipher{current: key, keys: map[string][]byte{masterKeyID(key): key}}
+	for _, s := range strings.Split(os.Getenv("DATA_ENCRYPTION_OLD_KEYS"), ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		old, err := decodeMasterKey(s)
+		if err != nil {
+			return fmt.Errorf("DATA_ENCRYPTION_OLD_KEYS: %v", err)
+		}
+		a.keys[masterKeyID(old)] = old
+	}
+	atRest = a
+	return nil
+}
+
+func gcmSeal(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func gcmOpen(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("密文过短")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+// Seal 加密；未配置主密钥时原样返回。
+// 格式：LSE1 | 主密钥 ID(4) | 加密后的数据密钥长度(1) | 加密后的数据密钥 | 加密后的内容
+func (a *atRestCipher) Seal(plain []byte) ([]byte, error) {
+	if !a.Enabled() {
+		return plain, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	wrapped, err := gcmSeal(a.current, dek)
+	if err != nil {
+		return nil, err
+	}
+	body, err := gcmSeal(dek, plain)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(sealedMagic)+keyIDLen+1+len(wrapped)+len(body))
+	out = append(out, sealedMagic...)
+	out = append(out, masterKeyID(a.current)...)
+	out = append(out, byte(len(wrapped)))
+	out = append(out, wrapped...)
+	return append(out, body...), nil
+}
+
+// Open 解密；没有加密前缀的明文旧数据原样返回
+func (a *atRestCipher) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, sealedMagic) {
+		return data, nil
+	}
+	rest := data[len(sealedMagic):]
+	if len(rest) < keyIDLen+1 {
+		return nil, fmt.Errorf("加密数据已损坏")
+	}
+	if !a.Enabled() {
+		return nil, errSealedNoKey
+	}
+	key, ok := a.keys[string(rest[:keyIDLen])]
+	if !ok {
+		return nil, fmt.Errorf("加密数据使用的主密钥未配置")
+	}
+	n := int(rest[keyIDLen])
+	rest = rest[keyIDLen+1:]
+	if len(rest) < n {
+		return nil, fmt.Errorf("加密数据已损坏")
+	}
+	dek, err := gcmOpen(key, rest[:n])
+	if err != nil {
+		return nil, fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	plain, err := gcmOpen(dek, rest[n:])
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %v", err)
+	}
+	return plain, nil
+}
+
+// writeSealedFile 加密后写入（未配置主密钥时即普通写入）
+func writeSealedFile(path string, data []byte) error {
+	sealed, err := atRest.Seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, 0o644)
+}
+
+// readSealedFile 读取并解密，兼容明文文件
+func readSealedFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return atRest.Open(raw)
+}
+
+// sealedRecordFields 扫描记录中加密保存的字段
+type sealedRecordFields struct {
+	UserID    string        `json:"user_id,omitempty"`
+	DeviceID  string        `json:"device_id,omitempty"`
+	Station   string        `json:"station,omitempty"`
+	Lotteries []LotteryData `json:"lotteries"`
+}
+
+// storedRecord 落盘的扫描记录：编号、租户、时间、哈希保持明文，用于排查和统计；其余字段在 sealed 中
+type storedRecord struct {
+	ScanRecord
+	Sealed string `json:"sealed,omitempty"`
+}
+
+// marshalRecord 扫描记录写入 history.jsonl 的一行
+func marshalRecord(r ScanRecord) ([]byte, error) {
+	if !atRest.Enabled() {
+		return json.Marshal(r)
+	}
+	plain, err := json.Marshal(sealedRecordFields{UserID: r.UserID, DeviceID: r.DeviceID, Station: r.Station, Lotteries: r.Lotteries})
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := atRest.Seal(plain)
+	if err != nil {
+		return nil, err
+	}
+	r.UserID, r.DeviceID, r.Station, r.Lotteries = "", "", "", nil
+	return json.Marshal(storedRecord{ScanRecord: r, Sealed: base64.StdEncoding.EncodeToString(sealed)})
+}
+
+// errSealedRecord 扫描记录的加密字段无法解密（主密钥不对或数据损坏），与 JSON 损坏的行区分开
+var errSealedRecord = errors.New("扫描记录解密失败")
+
+// unmarshalRecord 解析一行扫描记录
+func unmarshalRecord(line []byte) (ScanRecord, error) {
+	var st storedRecord
+	if err := json.Unmarshal(line, &st); err != nil {
+		return ScanRecord{}, err
+	}
+	r := st.ScanRecord
+	if st.Sealed == "" {
+		return r, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(st.Sealed)
+	if err != nil {
+		return r, fmt.Errorf("%w: 记录 %s: %v", errSealedRecord, r.ID, err)
+	}
+	plain, err := atRest.Open(sealed)
+	if err != nil {
+		return r, fmt.Errorf("%w: 记录 %s: %v", errSealedRecord, r.ID, err)
+	}
+	var f sealedRecordFields
+	if err := json.Unmarshal(plain, &f); err != nil {
+		return r, fmt.Errorf("%w: 记录 %s: %v", errSealedRecord, r.ID, err)
+	}
+	r.UserID, r.DeviceID, r.Station, r.Lotteries = f.UserID, f.DeviceID, f.Station, f.Lotteries
+	return r, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testMasterKey 32 字节主密钥的 base64，b 为每个字节的值
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// useTestAtRest 按 key（及旧密钥）启用落盘加密；key 为空时不加密
+func useTestAtRest(t *testing.T, key string, oldKeys ...string) {
+	t.Helper()
+	old := atRest
+	t.Cleanup(func() { atRest = old })
+	atRest = &atRestCipher{}
+	t.Setenv("DATA_ENCRYPTION_KEY", key)
+	t.Setenv("DATA_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("DATA_ENCRYPTION_KEY_CMD", "")
+	t.Setenv("DATA_ENCRYPTION_OLD_KEYS", strings.Join(oldKeys, ","))
+	if err := loadAtRestKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadAtRestKeys(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeTestFile(t, dir, "master.key", []byte(testMasterKey(2)+"\n"))
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantErr     bool
+		wantEnabled bool
+		wantKey     byte
+	}{
+		{"未配置不加密", nil, false, false, 0},
+		{"环境变量", map[string]string{"DATA_ENCRYPTION_KEY": testMasterKey(1)}, false, true, 1},
+		{"密钥文件", map[string]string{"DATA_ENCRYPTION_KEY_FILE": keyFile}, false, true, 2},
+		{"环境变量优先于文件", map[string]string{"DATA_ENCRYPTION_KEY": testMasterKey(1), "DATA_ENCRYPTION_KEY_FILE": keyFile}, false, true, 1},
+		{"命令输出", map[string]string{"DATA_ENCRYPTION_KEY_CMD": "echo " + testMasterKey(3)}, false, true, 3},
+		{"命令执行失败", map[string]string{"DATA_ENCRYPTION_KEY_CMD": "exit 1"}, true, false, 0},
+		{"密钥文件不存在", map[string]string{"DATA_ENCRYPTION_KEY_FILE": filepath.Join(dir, "missing")}, true, false, 0},
+		{"不是 base64", map[string]string{"DATA_ENCRYPTION_KEY": "not base64!"}, true, false, 0},
+		{"长度不对", map[string]string{"DATA_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString([]byte("short"))}, true, false, 0},
+		{"旧密钥格式错误", map[string]string{"DATA_ENCRYPTION_KEY": testMasterKey(1), "DATA_ENCRYPTION_OLD_KEYS": "x"}, true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := atRest
+			t.Cleanup(func() { atRest = old })
+			atRest = &atRestCipher{}
+			for _, k := range []string{"DATA_ENCRYPTION_KEY", "DATA_ENCRYPTION_KEY_FILE", "DATA_ENCRYPTION_KEY_CMD", "DATA_ENCRYPTION_OLD_KEYS"} {
+				t.Setenv(k, tt.env[k])
+			}
+			if err := loadAtRestKeys(); (err != nil) != tt.wantErr {
+				t.Fatalf("loadAtRestKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if atRest.Enabled() != tt.wantEnabled {
+				t.Fatalf("Enabled() = %v, want %v", atRest.Enabled(), tt.wantEnabled)
+			}
+			if tt.wantEnabled && !bytes.Equal(atRest.current, bytes.Repeat([]byte{tt.wantKey}, 32)) {
+				t.Errorf("current key = %x", atRest.current)
+			}
+		})
+	}
+}
+
+func TestAtRestSealOpen(t *testing.T) {
+	plain := []byte("双色球 2025107 02 11 15 21 28 33 + 07")
+	useTestAtRest(t, testMasterKey(1))
+	sealedOld, err := atRest.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealedOld, plain) || !bytes.HasPrefix(sealedOld, sealedMagic) {
+		t.Fatalf("sealed = %q", sealedOld)
+	}
+	tampered := bytes.Clone(sealedOld)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		key     string
+		oldKeys []string
+		data    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"明文旧数据原样读取", testMasterKey(1), nil, plain, plain, nil},
+		{"未配置主密钥时明文可读", "", nil, plain, plain, nil},
+		{"同一主密钥解密", testMasterKey(1), nil, sealedOld, plain, nil},
+		{"更换主密钥后用旧密钥解密", testMasterKey(2), []string{testMasterKey(1)}, sealedOld, plain, nil},
+		{"旧密钥未配置", testMasterKey(2), nil, sealedOld, nil, errors.New("主密钥未配置")},
+		{"未配置主密钥读不了密文", "", nil, sealedOld, nil, errSealedNoKey},
+		{"内容被篡改", testMasterKey(1), nil, tampered, nil, errors.New("解密失败")},
+		{"数据截断", testMasterKey(1), nil, sealedOld[:len(sealedMagic)+2], nil, errors.New("已损坏")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAtRest(t, tt.key, tt.oldKeys...)
+			got, err := atRest.Open(tt.data)
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("Open() = %q, %v", got, err)
+			}
+		})
+	}
+
+	// 每次加密随机生成数据密钥，同一内容两次密文不同
+	useTestAtRest(t, testMasterKey(1))
+	again, _ := atRest.Seal(plain)
+	if bytes.Equal(again, sealedOld) {
+		t.Error("Seal() is deterministic")
+	}
+}
+
+func TestSealedFile(t *testing.T) {
+	useTestAtRest(t, testMasterKey(1))
+	path := filepath.Join(t.TempDir(), "img")
+	if err := writeSealedFile(path, []byte("jpeg")); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("jpeg")) {
+		t.Errorf("file stored in plaintext: %q", raw)
+	}
+	if got, err := readSealedFile(path); err != nil || string(got) != "jpeg" {
+		t.Errorf("readSealedFile() = %q, %v", got, err)
+	}
+}
+
+// 扫描记录只加密票面和用户标识，编号、租户、时间保持明文
+func TestMarshalRecord(t *testing.T) {
+	r := ScanRecord{ID: "r1", Tenant: "shop-a", UserID: "user-1", DeviceID: "kiosk-1", Station: "44010001",
+		Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "SN-123"}}}
+	tests := []struct {
+		name      string
+		key       string
+		readKey   string
+		wantPlain []string
+		wantHide  []string
+		wantErr   bool
+	}{
+		{"未配置主密钥明文保存", "", "", []string{"user-1", "44010001", "SN-123"}, nil, false},
+		{"加密票面和用户标识", testMasterKey(1), testMasterKey(1), []string{`"id":"r1"`, "shop-a"}, []string{"user-1", "kiosk-1", "44010001", "SN-123", "2025107"}, false},
+		{"主密钥不对", testMasterKey(1), testMasterKey(2), nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAtRest(t, tt.key)
+			line, err := marshalRecord(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.wantPlain {
+				if !strings.Contains(string(line), s) {
+					t.Errorf("line missing %q: %s", s, line)
+				}
+			}
+			for _, s := range tt.wantHide {
+				if strings.Contains(string(line), s) {
+					t.Errorf("line exposes %q: %s", s, line)
+				}
+			}
+			useTestAtRest(t, tt.readKey)
+			got, err := unmarshalRecord(line)
+			if tt.wantErr {
+				if !errors.Is(err, errSealedRecord) {
+					t.Errorf("unmarshalRecord() error = %v, want errSealedRecord", err)
+				}
+				return
+			}
+			if err != nil || got.UserID != "user-1" || got.DeviceID != "kiosk-1" || got.Station != "44010001" || got.Lotteries[0].Serial != "SN-123" {
+				t.Errorf("unmarshalRecord() = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+// 主密钥配错时加载历史报错，而不是跳过全部记录
+func TestHistoryStoreLoadSealed(t *testing.T) {
+	useTestAtRest(t, testMasterKey(1))
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := &historyStore{path: path}
+	s.Add(ScanOrigin{Tenant: "shop-a", UserID: "u1"}, nil, []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107"}}})
+
+	loaded := &historyStore{}
+	if err := loaded.Load(path); err != nil || len(loaded.records) != 1 || loaded.records[0].UserID != "u1" {
+		t.Fatalf("Load() = %d records, %v", len(loaded.records), err)
+	}
+	useTestAtRest(t, testMasterKey(2))
+	if err := (&historyStore{}).Load(path); !errors.Is(err, errSealedRecord) {
+		t.Errorf("Load() with wrong key error = %v", err)
+	}
+}
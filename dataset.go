@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// DATASET: 导出人工确认过的识别样本，用于微调本地视觉模型
+// ==========================================
+
+// GET /api/v1/admin/dataset 导出 zip（仅 admin）：
+//
+//	dataset.jsonl      每行一个样本
+//	images/00001.jpg   原图（扩展名按图片内容）
+//	manifest.json      导出参数、各来源的样本数与跳过数
+//
+// 标签只取人工确认过的结果，同一张图片有多个来源时以最后确认的为准：
+//
+//	feedback   用户反馈“识别正确”（针对整次扫描，或记录中只有一张票）
+//	correction 用户更正后重新验奖产生的记录版本
+//	review     人工复核完成的结果
+//
+// 参数：tenant 只导出某个租户；since=2025-01-01 只导出此后确认的样本；
+// strip_pii=1 去掉标签中的序列号、站点编号和销售时间，样本中也不带租户与用户（原图上印的内容无法去除）；
+// format=chat 每行为对话格式（用户消息为图片 + 识别提示词，助手消息为标签 JSON），便于直接交给常见的微调工具。
+// 没有保存原图的记录（未开启 STORE_IMAGES 或租户开启了匿名化）跳过
+
+// datasetSample 一个样本
+type datasetSample struct {
+	Image         string        `json:"image"`
+	Label         []LotteryData `json:"label"`
+	Source        string        `json:"source"`
+	ScanID        string        `json:"scan_id,omitempty"`
+	Tenant        string        `json:"tenant,omitempty"`
+	UserID        string        `json:"user_id,omitempty"`
+	OCREngine     string        `json:"ocr_engine,omitempty"`
+	PromptVersion string        `json:"prompt_version,omitempty"`
+	ConfirmedAt   time.Time     `json:"confirmed_at"`
+
+	hash      string // 去重用
+	imagePath string // 原图位置，读取时由 readSealedFile 解密
+}
+
+// datasetOptions 导出参数
+type datasetOptions struct {
+	Tenant   string    `json:"tenant,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+	StripPII bool      `json:"strip_pii"`
+	Format   string    `json:"format"`
+}
+
+// datasetLabel 标签去掉仅供内部使用的字段；strip 时再去掉序列号、站点与销售时间
+func datasetLabel(list []LotteryData, strip bool) []LotteryData {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]LotteryData, len(list))
+	for i, l := range list {
+		l.Uncertain, l.Enhanced = nil, false
+		if strip {
+			l = anonymizeLottery(l)
+			l.SaleTime = ""
+		}
+		l.Sections = datasetLabel(l.Sections, strip)
+		out[i] = l
+	}
+	return out
+}
+
+// collectDataset 收集样本，按图片去重；skipped 为确认过但缺原图的数量
+func collectDataset(opts datasetOptions) (samples []*datasetSample, skipped int) {
+	byHash := map[string]*datasetSample{}
+	add := func(s *datasetSample) {
+		if (opts.Tenant != "" && s.Tenant != opts.Tenant) || s.ConfirmedAt.Before(opts.Since) || len(s.Label) == 0 {
+			return
+		}
+		if s.imagePath == "" {
+			skipped++
+			return
+		}
+		if prev, ok := byHash[s.hash]; !ok || s.ConfirmedAt.After(prev.ConfirmedAt) {
+			byHash[s.hash] = s
+		}
+	}
+
+	for _, fb := range scanFeedback.latest() {
+		rec, ok := scanHistory.Get(fb.ScanID)
+		if !ok {
+			continue
+		}
+		s := &datasetSample{
+			ScanID: rec.ID, Tenant: rec.Tenant, UserID: rec.UserID, OCREngine: rec.OCREngine,
+			PromptVersion: rec.PromptVersion, ConfirmedAt: fb.CreatedAt, hash: rec.ImageHash,
+		}
+		if rec.ImageHash != "" {
+			s.imagePath = images.OriginalPath(rec.ImageHash)
+		}
+		switch {
+		case fb.Correct && (fb.LotteryIndex == nil || len(rec.Lotteries) == 1):
+			s.Source, s.Label = "feedback", rec.Lotteries
+		case fb.VersionID != "":
+			corrected, ok := scanHistory.Get(fb.VersionID)
+			if !ok {
+				continue
+			}
+			s.Source, s.Label = "correction", corrected.Lotteries
+		default:
+			continue
+		}
+		add(s)
+	}
+	// 人工复核的原图随复核单保存，不依赖 STORE_IMAGES；复核单没有图片哈希，以编号区分
+	for _, item := range reviewQueue.Resolved() {
+		s := &datasetSample{
+			Source: "review", Tenant: item.Tenant, UserID: item.UserID, ConfirmedAt: *item.ResolvedAt,
+			hash: "review-" + item.ID, imagePath: reviewQueue.imagePath(item.ID),
+		}
+		for _, r := range item.Results {
+			s.Label = append(s.Label, r.OCRData)
+		}
+		add(s)
+	}
+
+	for _, s := range byHash {
+		s.Label = datasetLabel(s.Label, opts.StripPII)
+		if opts.StripPII {
+			s.Tenant, s.UserID, s.ScanID = "", "", ""
+		}
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].ConfirmedAt.Before(samples[j].ConfirmedAt) })
+	return samples, skipped
+}
+
+// datasetLine 一行 JSONL；chat 格式按 user（图片 + 提示词）/ assistant（标签）组织。
+// 样本与标签按脱敏规则处理（不想脱敏的字段可在 redact.json 中把规则置空）
+func datasetLine(s *datasetSample, format string) ([]byte, error) {
+	if format != "chat" {
+		return redaction.Marshal(s, "")
+	}
+	label, err := redaction.Marshal(s.Label, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(gin.H{
+		"messages": []gin.H{
+			{"role": "user", "content": []gin.H{
+				{"type": "image", "image": s.Image},
+				{"type": "text", "text": ocrPrompt},
+			}},
+			{"role": "assistant", "content": string(label)},
+		},
+		"source": s.Source,
+	})
+}
+
+// imageExt 按图片内容决定扩展名
+func imageExt(raw []byte) string {
+	switch http.DetectContentType(raw) {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".jpg"
+}
+
+// adminDatasetHandler GET /api/v1/admin/dataset?tenant=&since=2025-01-01&strip_pii=1&format=chat
+func adminDatasetHandler(c *gin.Context) {
+	opts := datasetOptions{
+		Tenant:   c.Query("tenant"),
+		StripPII: c.Query("strip_pii") == "1" || c.Query("strip_pii") == "true",
+		Format:   c.DefaultQuery("format", "plain"),
+	}
+	if opts.Format != "plain" && opts.Format != "chat" {
+		c.JSON(400, gin.H{"error": "format 应为 plain 或 chat"})
+		return
+	}
+	if v := c.Query("since"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, chinaTime)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "since 格式应为 YYYY-MM-DD"})
+			return
+		}
+		opts.Since = t
+	}
+	samples, skipped := collectDataset(opts)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="dataset-%s.zip"`, time.Now().In(chinaTime).Format("20060102")))
+	c.Header("Content-Type", "application/zip")
+	c.Status(200)
+	// 响应已经开始，中途出错只能记日志，客户端会拿到不完整的压缩包
+	zw := zip.NewWriter(c.Writer)
+	defer func() {
+		if err := zw.Close(); err != nil {
+			log.Printf("导出训练数据失败: %v", err)
+		}
+	}()
+
+	var lines []byte
+	counts := map[string]int{}
+	written := 0
+	for i, s := range samples {
+		raw, err := readSealedFile(s.imagePath)
+		if err != nil {
+			skipped++
+			continue
+		}
+		s.Image = fmt.Sprintf("images/%05d%s", i+1, imageExt(raw))
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: s.Image, Method: zip.Store, Modified: s.ConfirmedAt})
+		if err != nil {
+			log.Printf("导出训练数据失败: %v", err)
+			return
+		}
+		if _, err := w.Write(raw); err != nil {
+			log.Printf("导出训练数据失败: %v", err)
+			return
+		}
+		line, err := datasetLine(s, opts.Format)
+		if err != nil {
+			log.Printf("导出训练数据失败: %v", err)
+			return
+		}
+		lines = append(append(lines, line...), '\n')
+		counts[s.Source]++
+		written++
+	}
+
+	w, err := zw.Create("dataset.jsonl")
+	if err != nil {
+		log.Printf("导出训练数据失败: %v", err)
+		return
+	}
+	w.Write(lines)
+	manifest, _ := json.MarshalIndent(gin.H{
+		"exported_at": time.Now(), "options": opts, "samples": written, "by_source": counts,
+		"skipped_without_image": skipped, "prompt_version": promptVersion(),
+	}, "", "  ")
+	if w, err := zw.Create("manifest.json"); err == nil {
+		w.Write(manifest)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDatasetLabel(t *testing.T) {
+	list := []LotteryData{{
+		Type: "双色球", Issue: "2025107", Serial: "SN-1", Station: "44010001", SaleTime: "2025-09-10 12:00",
+		Uncertain: []string{"tickets[1].blue"}, Enhanced: true,
+		Sections: []LotteryData{{Type: "双色球", Serial: "SN-2", Uncertain: []string{"issue"}}},
+	}}
+	tests := []struct {
+		name  string
+		strip bool
+		want  string // 序列号/站点/销售时间/分段序列号
+	}{
+		{"保留票面信息", false, "SN-1/44010001/2025-09-10 12:00/SN-2"},
+		{"去掉个人信息", true, "///"},
+	}
+	for _, tt := range tests {
+		got := datasetLabel(list, tt.strip)
+		l := got[0]
+		if s := l.Serial + "/" + l.Station + "/" + l.SaleTime + "/" + l.Sections[0].Serial; s != tt.want {
+			t.Errorf("%s: label = %s, want %s", tt.name, s, tt.want)
+		}
+		if l.Uncertain != nil || l.Enhanced || l.Sections[0].Uncertain != nil {
+			t.Errorf("%s: internal fields kept: %+v", tt.name, l)
+		}
+	}
+	if list[0].Serial != "SN-1" || list[0].Uncertain == nil {
+		t.Error("datasetLabel modified its input")
+	}
+	if datasetLabel(nil, true) != nil {
+		t.Error("datasetLabel(nil) != nil")
+	}
+}
+
+func TestImageExt(t *testing.T) {
+	tests := []struct {
+		raw  []byte
+		want string
+	}{
+		{testPNG(t, 4, 4), ".png"},
+		{[]byte("\xff\xd8\xff\xe0jpeg"), ".jpg"},
+		{[]byte("GIF89a"), ".gif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), ".webp"},
+		{[]byte("unknown"), ".jpg"},
+	}
+	for _, tt := range tests {
+		if got := imageExt(tt.raw); got != tt.want {
+			t.Errorf("imageExt(%q) = %s, want %s", tt.raw[:4], got, tt.want)
+		}
+	}
+}
+
+// useTestDataset 准备各种来源的确认样本：
+//
+//	r1 用户确认正确；r2 更正后产生版本 r2v；r3 确认正确但没有原图；
+//	r4 认错但没有更正；r5 只确认了两张票中的一张；r6 与 r1 是同一张图片、确认得更晚（shop-b）；
+//	另有一张已完成的人工复核单
+func useTestDataset(t *testing.T) time.Time {
+	t.Helper()
+	useTestAtRest(t, "")
+	useTestFeedback(t)
+	useTestReviews(t)
+	oldHistory, oldImages := scanHistory, images
+	t.Cleanup(func() { scanHistory, images = oldHistory, oldImages })
+	images = &imageStore{dir: t.TempDir(), enabled: true}
+	for _, hash := range []string{"aa01", "bb02", "dd04", "ee05"} {
+		writeTestFile(t, images.dir, filepath.Join(hash[:2], hash+".img"), []byte("\xff\xd8\xff\xe0"+hash))
+	}
+
+	ssq := func(issue string) []LotteryData {
+		return []LotteryData{{Type: "双色球", Issue: issue, Serial: "SN-" + issue}}
+	}
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "shop-a", UserID: "u1", ImageHash: "aa01", Lotteries: ssq("1"), OCREngine: "gemini/flash"},
+		{ID: "r2", Tenant: "shop-a", UserID: "u1", ImageHash: "bb02", Lotteries: ssq("2")},
+		{ID: "r2v", Tenant: "shop-a", UserID: "u1", ImageHash: "bb02", Lotteries: ssq("22"), OriginalID: "r2", Version: 2},
+		{ID: "r3", Tenant: "shop-a", UserID: "u1", Lotteries: ssq("3")},
+		{ID: "r4", Tenant: "shop-a", UserID: "u1", ImageHash: "dd04", Lotteries: ssq("4")},
+		{ID: "r5", Tenant: "shop-a", UserID: "u1", ImageHash: "ee05", Lotteries: append(ssq("5"), ssq("55")...)},
+		{ID: "r6", Tenant: "shop-b", UserID: "u2", ImageHash: "aa01", Lotteries: ssq("6")},
+	}}
+	day := time.Date(2025, 9, 10, 12, 0, 0, 0, chinaTime)
+	first := 0
+	scanFeedback.items = []ScanFeedback{
+		{ScanID: "r1", Tenant: "shop-a", UserID: "u1", Correct: true, CreatedAt: day},
+		{ScanID: "r2", Tenant: "shop-a", UserID: "u1", VersionID: "r2v", CreatedAt: day.Add(time.Hour)},
+		{ScanID: "r3", Tenant: "shop-a", UserID: "u1", Correct: true, CreatedAt: day},
+		{ScanID: "r4", Tenant: "shop-a", UserID: "u1", CreatedAt: day},
+		{ScanID: "r5", Tenant: "shop-a", UserID: "u1", Correct: true, LotteryIndex: &first, CreatedAt: day},
+		{ScanID: "r6", Tenant: "shop-b", UserID: "u2", Correct: true, CreatedAt: day.AddDate(0, 0, 2)},
+	}
+
+	item := &ReviewItem{ScanOrigin: ScanOrigin{Tenant: "shop-a", UserID: "u3"}}
+	if err := reviewQueue.Add(item, testPNG(t, 4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	reviewQueue.resolve(item.ID, "复核员", []VerificationResult{{OCRData: ssq("7")[0]}})
+	return day
+}
+
+func TestCollectDataset(t *testing.T) {
+	day := useTestDataset(t)
+
+	tests := []struct {
+		name        string
+		opts        datasetOptions
+		want        string // 各样本的来源:期号，按确认时间先后
+		wantSkipped int
+	}{
+		{"全部", datasetOptions{}, "correction:22,feedback:6,review:7", 1},
+		{"按租户", datasetOptions{Tenant: "shop-a"}, "feedback:1,correction:22,review:7", 1},
+		{"按时间", datasetOptions{Since: day.AddDate(0, 0, 1)}, "feedback:6,review:7", 0},
+		{"去掉个人信息", datasetOptions{Tenant: "shop-b", StripPII: true}, "feedback:6", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, skipped := collectDataset(tt.opts)
+			var got []string
+			for _, s := range samples {
+				got = append(got, s.Source+":"+s.Label[0].Issue)
+				if s.imagePath == "" {
+					t.Errorf("sample %s has no image", s.Source)
+				}
+				if tt.opts.StripPII && (s.Tenant != "" || s.UserID != "" || s.ScanID != "" || s.Label[0].Serial != "") {
+					t.Errorf("sample kept personal data: %+v", s)
+				}
+			}
+			if strings.Join(got, ",") != tt.want || skipped != tt.wantSkipped {
+				t.Errorf("collectDataset() = %s, skipped %d, want %s, %d", strings.Join(got, ","), skipped, tt.want, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestAdminDatasetHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDataset(t)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantFiles  string
+		wantLine   string // dataset.jsonl 第一行应包含
+	}{
+		{"?tenant=shop-a", 200, "images/00001.jpg,images/00002.jpg,images/00003.png,dataset.jsonl,manifest.json", `"source":"feedback"`},
+		{"?tenant=shop-a&format=chat", 200, "images/00001.jpg,images/00002.jpg,images/00003.png,dataset.jsonl,manifest.json", `"role":"assistant"`},
+		{"?tenant=shop-c", 200, "dataset.jsonl,manifest.json", ""},
+		{"?format=csv", 400, "", ""},
+		{"?since=2025/09/01", 400, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/admin/dataset"+tt.query, nil)
+			adminDatasetHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			files := map[string]string{}
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+				rc, _ := f.Open()
+				raw, _ := io.ReadAll(rc)
+				rc.Close()
+				files[f.Name] = string(raw)
+			}
+			if got := strings.Join(names, ","); got != tt.wantFiles {
+				t.Fatalf("files = %s, want %s", got, tt.wantFiles)
+			}
+			lines := strings.Split(strings.TrimSpace(files["dataset.jsonl"]), "\n")
+			if !strings.Contains(lines[0], tt.wantLine) {
+				t.Errorf("dataset.jsonl = %s", files["dataset.jsonl"])
+			}
+			var manifest struct {
+				Samples int            `json:"samples"`
+				Sources map[string]int `json:"by_source"`
+			}
+			json.Unmarshal([]byte(files["manifest.json"]), &manifest)
+			if manifest.Samples != len(names)-2 || manifest.Sources["review"] != manifest.Samples/3 {
+				t.Errorf("manifest = %s", files["manifest.json"])
+			}
+		})
+	}
+}
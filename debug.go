@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// DEBUG: 单请求调试记录（?debug=1）
+// ==========================================
+
+// 用户反馈“认错了我的票”时，需要看到当时模型到底收到了什么、回了什么。
+// 带 ?debug=1 的扫描请求会跳过识别缓存，把每次模型调用的提示词、原始输出、清洗后的结构和耗时
+// 连同图片存到 data/debug，响应头 X-Debug-ID 给出记录编号，管理员通过 /api/v1/admin/debug 查看
+
+// debugExchange 一次模型调用
+type debugExchange struct {
+	Provider    string        `json:"provider"`
+	Model       string        `json:"model"`
+	Prompt      string        `json:"prompt"`
+	Temperature *float32      `json:"temperature,omitempty"`
+	MIMEType    string        `json:"mime_type"`
+	RawOutput   string        `json:"raw_output"`
+	Cleaned     []LotteryData `json:"cleaned,omitempty"` // parseOCRText 宽松解析、清洗后的结果
+	Error       string        `json:"error,omitempty"`
+	StartedAt   time.Time     `json:"started_at"`
+	DurationMs  int64         `json:"duration_ms"`
+}
+
+// DebugTrace 一次扫描请求的完整调试记录
+type DebugTrace struct {
+	ID         string               `json:"debug_id"`
+	Tenant     string               `json:"tenant"`
+	CreatedAt  time.Time            `json:"created_at"`
+	Path       string               `json:"path"`
+	ImageHash  string               `json:"image_hash"`
+	StatusCode int                  `json:"status_code"`
+	Timings    map[string]int64     `json:"timings_ms"` // 阶段 → 耗时（毫秒），total 为整个请求
+	Exchanges  []debugExchange      `json:"exchanges"`
+	Results    []VerificationResult `json:"results,omitempty"`
+
+	mu    sync.Mutex
+	start time.Time
+}
+
+type debugTraceKey struct{}
+
+func withDebugTrace(ctx context.Context, t *DebugTrace) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, debugTraceKey{}, t)
+}
+
+func debugTraceFrom(ctx context.Context) *DebugTrace {
+	t, _ := ctx.Value(debugTraceKey{}).(*DebugTrace)
+	return t
+}
+
+// debugTraceOf ?debug=1 时开始记录并在响应头给出编号，否则返回 nil；nil 上的方法都是空操作
+func debugTraceOf(c *gin.Context) *DebugTrace {
+	if v := c.Query("debug"); v != "1" && v != "true" {
+		return nil
+	}
+	t := &DebugTrace{
+		ID: newJobID(), Tenant: tenantOf(c), CreatedAt: time.Now(), Path: c.Request.URL.RequestURI(),
+		Timings: map[string]int64{}, start: time.Now(),
+	}
+	c.Header("X-Debug-ID", t.ID)
+	return t
+}
+
+// parseErrorDetail 识别结果无法解析时的细节：出错位置、前后片段（已脱敏）和做过的修复。
+// 只在 ?debug=1 且请求带 operator 及以上的管理员令牌时返回；DEBUG_PARSE_ERRORS=1 时对所有 ?debug=1 请求返回，
+// 供对接方在测试环境排查。其他情况返回 nil，响应与以前相同
+func parseErrorDetail(c *gin.Context, err error) gin.H {
+	var pe *ocrParseError
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	if v := c.Query("debug"); v != "1" && v != "true" {
+		return nil
+	}
+	if !envBool("DEBUG_PARSE_ERRORS", false) {
+		token := c.GetHeader("X-Admin-Token")
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		cred, ok := admins.Lookup(token)
+		if token == "" || !ok || roleRank[cred.Role] < roleRank[roleOperator] || !ipAccess.AdminAllowed(c) {
+			return nil
+		}
+	}
+	detail := gin.H{"message": pe.Message, "steps": pe.Steps}
+	if pe.Offset >= 0 {
+		detail["offset"] = pe.Offset
+		detail["snippet"] = redaction.Text(pe.Snippet)
+	}
+	if id := c.Writer.Header().Get("X-Debug-ID"); id != "" {
+		detail["debug_id"] = id
+	}
+	return detail
+}
+
+// Record 追加一次模型调用
+func (t *DebugTrace) Record(ex debugExchange) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.Exchanges = append(t.Exchanges, ex)
+	t.mu.Unlock()
+}
+
+// Mark 记录某个阶段从 since 到现在的耗时
+func (t *DebugTrace) Mark(stage string, since time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.Timings[stage] = time.Since(since).Milliseconds()
+	t.mu.Unlock()
+}
+
+// SetResults 记录返回给客户端的验奖结果
+func (t *DebugTrace) SetResults(results []VerificationResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.Results = results
+	t.mu.Unlock()
+}
+
+// Save 请求结束时落盘；写失败只记日志，不影响请求本身
+func (t *DebugTrace) Save(c *gin.Context, fileBytes []byte) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.StatusCode = c.Writer.Status()
+	t.Timings["total"] = time.Since(t.start).Milliseconds()
+	t.ImageHash = imageHash(fileBytes)
+	t.mu.Unlock()
+	if err := debugTraces.Save(t, fileBytes); err != nil {
+		log.Printf("保存调试记录 %s 失败: %v", t.ID, err)
+	}
+}
+
+// debugStore 调试记录目录，超过 DEBUG_TRACE_RETENTION（默认 72h）的记录在写入新记录时清理
+type debugStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var debugTraces *debugStore
+
+func (s *debugStore) metaPath(id string) string  { return filepath.Join(s.dir, id+".json") }
+func (s *debugStore) imagePath(id string) string { return filepath.Join(s.dir, id+".img") }
+
+func (s *debugStore) Save(t *DebugTrace, fileBytes []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	// 模型原始输出和识别结果带着序列号等票面信息，落盘前脱敏
+	t.mu.Lock()
+	raw, err := redaction.Marshal(t, "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := writeSealedFile(s.imagePath(t.ID), fileBytes); err != nil {
+		return err
+	}
+	p := s.metaPath(t.ID)
+	if err := writeSealedFile(p+".tmp", raw); err != nil {
+		return err
+	}
+	if err := os.Rename(p+".tmp", p); err != nil {
+		return err
+	}
+	s.prune(time.Now().Add(-envDuration("DEBUG_TRACE_RETENTION", 72*time.Hour)))
+	return nil
+}
+
+// prune 删除早于 before 的记录；调用方需持有锁
+func (s *debugStore) prune(before time.Time) {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*"))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && info.ModTime().Before(before) {
+			os.Remove(f)
+		}
+	}
+}
+
+func (s *debugStore) Get(id string) (*DebugTrace, bool) {
+	if strings.ContainsAny(id, `/\.`) {
+		return nil, false
+	}
+	raw, err := readSealedFile(s.metaPath(id))
+	if err != nil {
+		return nil, false
+	}
+	var t DebugTrace
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// List 按时间倒序列出记录；列表里不带提示词和原始输出
+func (s *debugStore) List() []gin.H {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	var traces []*DebugTrace
+	for _, f := range files {
+		if t, ok := s.Get(strings.TrimSuffix(filepath.Base(f), ".json")); ok {
+			traces = append(traces, t)
+		}
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].CreatedAt.After(traces[j].CreatedAt) })
+	out := make([]gin.H, 0, len(traces))
+	for _, t := range traces {
+		out = append(out, gin.H{
+			"debug_id": t.ID, "tenant": t.Tenant, "created_at": t.CreatedAt, "path": t.Path,
+			"status_code": t.StatusCode, "exchanges": len(t.Exchanges), "timings_ms": t.Timings,
+		})
+	}
+	return out
+}
+
+func debugListHandler(c *gin.Context) {
+	c.JSON(200, gin.H{"traces": debugTraces.List()})
+}
+
+func debugGetHandler(c *gin.Context) {
+	t, ok := debugTraces.Get(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("调试记录 %s 不存在", c.Param("id"))})
+		return
+	}
+	c.JSON(200, t)
+}
+
+func debugImageHandler(c *gin.Context) {
+	t, ok := debugTraces.Get(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("调试记录 %s 不存在", c.Param("id"))})
+		return
+	}
+	raw, err := readSealedFile(debugTraces.imagePath(t.ID))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "图片已清理"})
+		return
+	}
+	c.Data(200, http.DetectContentType(raw), raw)
+}
+
+// debugReplayHandler 用当前的解析和验奖逻辑重放记录中的模型原始输出，不重新调用模型，
+// 用于确认解析或规则修复后同一份输出的结果
+func debugReplayHandler(c *gin.Context) {
+	t, ok := debugTraces.Get(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("调试记录 %s 不存在", c.Param("id"))})
+		return
+	}
+	budget := newRequestBudget(c.Request.Context())
+	defer budget.Done()
+	replays := make([]gin.H, 0, len(t.Exchanges))
+	for i, ex := range t.Exchanges {
+		item := gin.H{"exchange": i, "provider": ex.Provider}
+		data, err := parseOCRText(ex.RawOutput)
+		if err != nil {
+			item["error"] = "解析失败: " + err.Error()
+			replays = append(replays, item)
+			continue
+		}
+		item["cleaned"] = data
+		if results, err := verifyAll(budget, data); err != nil {
+			item["error"] = "验奖失败: " + err.Error()
+		} else {
+			item["results"] = results
+		}
+		replays = append(replays, item)
+	}
+	c.JSON(200, gin.H{"debug_id": t.ID, "original": t.Results, "replays": replays})
+}
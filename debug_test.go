@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestDebugTraces 换成临时目录里的调试记录
+func useTestDebugTraces(t *testing.T) {
+	t.Helper()
+	old := debugTraces
+	t.Cleanup(func() { debugTraces = old })
+	debugTraces = &debugStore{dir: filepath.Join(t.TempDir(), "debug")}
+}
+
+func TestDebugTraceOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"?debug=1", true},
+		{"?debug=true", true},
+		{"?debug=0", false},
+		{"?debug=yes", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, nil)
+			c.Request.Header.Set("X-Tenant-ID", "shop-a")
+			trace := debugTraceOf(c)
+			if (trace != nil) != tt.want {
+				t.Fatalf("debugTraceOf() = %v, want trace %v", trace, tt.want)
+			}
+			if got := w.Header().Get("X-Debug-ID"); (got != "") != tt.want {
+				t.Errorf("X-Debug-ID = %q", got)
+			}
+			// 不记录时各方法都是空操作
+			trace.Record(debugExchange{Provider: "gemini"})
+			trace.Mark("ocr", time.Now())
+			trace.SetResults(nil)
+			if trace != nil && (trace.Tenant != "shop-a" || len(trace.Exchanges) != 1 || trace.Timings["ocr"] < 0) {
+				t.Errorf("trace = %+v", trace)
+			}
+		})
+	}
+}
+
+func TestDebugStore(t *testing.T) {
+	useTestDebugTraces(t)
+	now := time.Now()
+	older := &DebugTrace{ID: "a1", Tenant: "shop-a", CreatedAt: now.Add(-time.Minute), Timings: map[string]int64{},
+		Exchanges: []debugExchange{{Provider: "gemini", RawOutput: "[]"}}}
+	newer := &DebugTrace{ID: "b2", Tenant: "shop-b", CreatedAt: now, Timings: map[string]int64{}}
+	for _, tr := range []*DebugTrace{older, newer} {
+		if err := debugTraces.Save(tr, []byte("img-"+tr.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		wantOK bool
+	}{
+		{"已保存", "a1", true},
+		{"不存在", "c3", false},
+		{"拒绝路径穿越", "../a1", false},
+		{"拒绝带扩展名", "a1.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := debugTraces.Get(tt.id)
+			if ok != tt.wantOK {
+				t.Fatalf("Get(%q) ok = %v, want %v", tt.id, ok, tt.wantOK)
+			}
+			if ok && (got.Tenant != "shop-a" || len(got.Exchanges) != 1 || got.Exchanges[0].RawOutput != "[]") {
+				t.Errorf("Get(%q) = %+v", tt.id, got)
+			}
+		})
+	}
+
+	list := debugTraces.List()
+	if len(list) != 2 || list[0]["debug_id"] != "b2" || list[1]["exchanges"] != 1 {
+		t.Errorf("List() = %v", list)
+	}
+
+	// 超过保留时间的记录在写入新记录时清理
+	t.Setenv("DEBUG_TRACE_RETENTION", "1h")
+	stale := now.Add(-2 * time.Hour)
+	for _, f := range []string{debugTraces.metaPath("a1"), debugTraces.imagePath("a1")} {
+		if err := os.Chtimes(f, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := debugTraces.Save(&DebugTrace{ID: "c3", CreatedAt: now, Timings: map[string]int64{}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := debugTraces.Get("a1"); ok {
+		t.Error("stale trace not pruned")
+	}
+	if _, ok := debugTraces.Get("b2"); !ok {
+		t.Error("recent trace pruned")
+	}
+}
+
+func TestDebugReplayHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDebugTraces(t)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+
+	trace := &DebugTrace{ID: "r1", Timings: map[string]int64{}, Exchanges: []debugExchange{
+		{Provider: "gemini", RawOutput: `[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21","28","33"],"blue":["07"],"multiplier":1}]}]`},
+		{Provider: "openai", RawOutput: "看不清"},
+	}}
+	if err := debugTraces.Save(trace, []byte("img")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantErrors []bool // 每次调用是否重放失败
+	}{
+		{"重放原始输出", "r1", 200, []bool{false, true}},
+		{"记录不存在", "missing", 404, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/admin/debug/"+tt.id+"/replay", nil)
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			debugReplayHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Replays []struct {
+					Error   string               `json:"error"`
+					Results []VerificationResult `json:"results"`
+				} `json:"replays"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Replays) != len(tt.wantErrors) {
+				t.Fatalf("replays = %d, want %d", len(resp.Replays), len(tt.wantErrors))
+			}
+			for i, r := range resp.Replays {
+				if (r.Error != "") != tt.wantErrors[i] {
+					t.Errorf("replay %d error = %q", i, r.Error)
+				}
+			}
+			if len(resp.Replays) > 0 && (len(resp.Replays[0].Results) != 1 || resp.Replays[0].Results[0].TotalPrize != 5000000*Yuan) {
+				t.Errorf("replay results = %+v", resp.Replays[0].Results)
+			}
+		})
+	}
+}
+
+// 解析失败的细节只返回给带 operator 以上令牌的 ?debug=1 请求
+func TestRespondStageErrorParseDetail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestIPAccess(t, `{"admin_allow": ["10.0.0.0/8"]}`)
+	prev := admins.creds
+	t.Cleanup(func() { admins.creds = prev })
+	admins.creds = []AdminCredential{
+		{Token: "v", Name: "前台", Role: roleViewer},
+		{Token: "o", Name: "复核员", Role: roleOperator},
+	}
+	_, parseErr := parseOCRText(`[{"issue": "1", "tickets": 1}]`)
+
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		bearer     bool
+		remote     string
+		openAll    bool // DEBUG_PARSE_ERRORS=1
+		err        error
+		wantDetail bool
+	}{
+		{"operator 开启调试", "?debug=1", "o", false, "10.0.0.5:1234", false, parseErr, true},
+		{"Bearer 令牌", "?debug=true", "o", true, "10.0.0.5:1234", false, parseErr, true},
+		{"未开启调试", "", "o", false, "10.0.0.5:1234", false, parseErr, false},
+		{"没有令牌", "?debug=1", "", false, "10.0.0.5:1234", false, parseErr, false},
+		{"viewer 权限不够", "?debug=1", "v", false, "10.0.0.5:1234", false, parseErr, false},
+		{"不在管理白名单", "?debug=1", "o", false, "203.0.113.9:1234", false, parseErr, false},
+		{"测试环境对所有调试请求返回", "?debug=1", "", false, "203.0.113.9:1234", true, parseErr, true},
+		{"不是解析错误", "?debug=1", "o", false, "10.0.0.5:1234", false, errors.New("网络错误"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEBUG_PARSE_ERRORS", map[bool]string{true: "1"}[tt.openAll])
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, nil)
+			c.Request.RemoteAddr = tt.remote
+			if tt.bearer {
+				c.Request.Header.Set("Authorization", "Bearer "+tt.token)
+			} else if tt.token != "" {
+				c.Request.Header.Set("X-Admin-Token", tt.token)
+			}
+			c.Writer.Header().Set("X-Debug-ID", "d1")
+			respondStageError(c, "识别失败: ", tt.err)
+
+			var resp struct {
+				Error      string         `json:"error"`
+				Code       string         `json:"code"`
+				ParseError map[string]any `json:"parse_error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != 500 || !strings.HasPrefix(resp.Error, "识别失败: ") {
+				t.Fatalf("response = %d %s", w.Code, w.Body)
+			}
+			if (resp.ParseError != nil) != tt.wantDetail || (resp.Code == "OCR_PARSE_FAILED") != tt.wantDetail {
+				t.Fatalf("parse_error = %v, want detail %v", resp.ParseError, tt.wantDetail)
+			}
+			if tt.wantDetail {
+				d := resp.ParseError
+				if d["offset"] == nil || !strings.Contains(fmt.Sprint(d["snippet"]), "⟨!⟩") || d["debug_id"] != "d1" || len(d["steps"].([]any)) == 0 {
+					t.Errorf("parse_error = %v", d)
+				}
+			}
+		})
+	}
+}
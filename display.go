@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// DISPLAY: 统一的验奖单展示结构 (?format=slip)
+// ==========================================
+
+// 卡片（?format=card）按小程序的版式组织，其他渠道还得自己决定先画什么、哪行加粗、用什么颜色。
+// 验奖单把这些都定好：按顺序排好的行，每行是标签/值，带强调标记和颜色提示，
+// 小程序、聊天机器人、打印小票或 PDF 回执逐行画出来就是同一张单子，文案已按 lang 翻译。
+//
+//	kind     heading 票头 / field 字段 / numbers 号码行 / warning 提示 / divider 分隔 / total 合计
+//	color    win 中奖 / lose 未中奖 / pending 待开奖 / warn 提示或不支持 / normal 普通
+//	emphasis 加粗或放大显示
+
+// DisplaySlip 一次验奖的完整展示单
+type DisplaySlip struct {
+	Title    string        `json:"title"`
+	Color    string        `json:"color"`
+	Currency string        `json:"currency,omitempty"`
+	Lines    []DisplayLine `json:"lines"`
+}
+
+// DisplayLine 展示单上的一行
+type DisplayLine struct {
+	Kind     string     `json:"kind"`
+	Icon     string     `json:"icon,omitempty"`
+	Label    string     `json:"label,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	Balls    []CardBall `json:"balls,omitempty"` // numbers 行和开奖号码
+	Emphasis bool       `json:"emphasis,omitempty"`
+	Color    string     `json:"color"`
+}
+
+// themeColor 卡片主题 → 颜色提示
+func themeColor(theme string) string {
+	switch theme {
+	case "win", "lose", "pending":
+		return theme
+	case "unsupported":
+		return "warn"
+	}
+	return "normal"
+}
+
+// buildSlip 由（已翻译的）卡片响应生成展示单，cat 只用于翻译展示单新增的字段名
+func buildSlip(resp CardResponse, cat *messageCatalog) DisplaySlip {
+	slip := DisplaySlip{Title: resp.Summary, Color: "lose", Currency: resp.Currency, Lines: []DisplayLine{}}
+	if resp.TotalPrizeFen > 0 {
+		slip.Color = "win"
+	}
+	for i, card := range resp.Cards {
+		if i > 0 {
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "divider", Color: "normal"})
+		}
+		color := themeColor(card.Theme)
+		slip.Lines = append(slip.Lines,
+			DisplayLine{Kind: "heading", Icon: card.Icon, Label: card.Title, Value: card.Headline, Emphasis: true, Color: color},
+			DisplayLine{Kind: "field", Value: card.Subtitle, Color: "normal"})
+		if len(card.Winning) > 0 {
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "field", Label: cat.T("开奖号码"), Balls: card.Winning, Color: "normal"})
+		}
+		for _, row := range card.Rows {
+			value := strings.TrimSpace(row.Multiplier + " " + row.Status)
+			rowColor := "lose"
+			switch {
+			case row.Highlight:
+				rowColor = "win"
+			case row.Icon == iconPending:
+				rowColor = "pending"
+			case row.Icon == iconUnsupported:
+				rowColor = "warn"
+			}
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "numbers", Icon: row.Icon, Label: row.Label, Value: value,
+				Balls: row.Balls, Emphasis: row.Highlight, Color: rowColor})
+		}
+		if card.Jackpot != "" {
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "field", Value: card.Jackpot, Color: "normal"})
+		}
+		if card.Claim != nil {
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "field", Label: cat.T("兑奖地点"), Value: card.Claim.Where, Color: "win"})
+			for i, doc := range card.Claim.Documents {
+				// 材料逐条一行，标签只写在第一行
+				line := DisplayLine{Kind: "field", Value: doc, Color: "normal"}
+				if i == 0 {
+					line.Label = cat.T("兑奖材料")
+				}
+				slip.Lines = append(slip.Lines, line)
+			}
+			if card.Claim.Tax != "" {
+				slip.Lines = append(slip.Lines, DisplayLine{Kind: "field", Label: cat.T("税费"), Value: card.Claim.Tax, Color: "normal"})
+			}
+			if card.Claim.Deadline != "" {
+				slip.Lines = append(slip.Lines, DisplayLine{Kind: "field", Label: cat.T("兑奖截止"), Value: card.Claim.Deadline, Color: "normal"})
+			}
+		}
+		for _, w := range card.Warnings {
+			slip.Lines = append(slip.Lines, DisplayLine{Kind: "warning", Icon: iconUnsupported, Value: w, Color: "warn"})
+		}
+	}
+	slip.Lines = append(slip.Lines, DisplayLine{Kind: "total", Label: cat.T("合计中奖"), Value: resp.TotalText,
+		Emphasis: resp.TotalPrizeFen > 0, Color: slip.Color})
+	return slip
+}
+
+// presentSlip 按请求的语言和 API 模式生成展示单
+func presentSlip(c *gin.Context, results []VerificationResult) DisplaySlip {
+	return buildSlip(presentCards(c, buildCardResponse(results)), catalogOf(c))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestThemeColor(t *testing.T) {
+	tests := []struct {
+		theme string
+		want  string
+	}{
+		{"win", "win"},
+		{"lose", "lose"},
+		{"pending", "pending"},
+		{"unsupported", "warn"},
+		{"", "normal"},
+	}
+	for _, tt := range tests {
+		if got := themeColor(tt.theme); got != tt.want {
+			t.Errorf("themeColor(%q) = %s, want %s", tt.theme, got, tt.want)
+		}
+	}
+}
+
+// slipLines 每行写成 kind:label:color，强调的行加 *
+func slipLines(slip DisplaySlip) string {
+	var out []string
+	for _, l := range slip.Lines {
+		s := l.Kind + ":" + l.Label + ":" + l.Color
+		if l.Emphasis {
+			s += "*"
+		}
+		out = append(out, s)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestBuildSlip(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	balls := []CardBall{{Number: "02", Color: "red", Hit: true}, {Number: "07", Color: "blue"}}
+	won := ResultCard{
+		Title: "双色球 第2025107期", Icon: iconWin, Theme: "win", Headline: "恭喜中奖 3,000元", Subtitle: "共 2 行 · 中奖 1 行",
+		Winning: balls,
+		Rows: []CardRow{
+			{Label: "第1行", Balls: balls, Multiplier: "×2", Icon: iconWin, Status: "三等奖 3,000元", Highlight: true},
+			{Label: "第2行", Balls: balls, Icon: iconLose, Status: "未中奖"},
+		},
+		Jackpot:  "当前奖池 12.35亿元",
+		Claim:    &ClaimGuide{Where: "本省任意福彩销售网点", Documents: []string{"彩票原件", "身份证"}, Tax: "免征个人所得税", Deadline: "2025-11-08"},
+		Warnings: []string{"倍数与票面金额不符"},
+	}
+	pending := ResultCard{
+		Title: "大乐透 第25108期", Icon: iconPending, Theme: "pending", Headline: "等待开奖",
+		Rows: []CardRow{
+			{Label: "第1行", Balls: balls, Icon: iconPending, Status: "待开奖"},
+			{Label: "第2行", Balls: balls, Icon: iconUnsupported, Status: "号码无效"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		resp      CardResponse
+		cat       *messageCatalog
+		wantColor string
+		want      string
+	}{
+		{"中奖票与待开奖票", CardResponse{Summary: "合计中奖 3,000元", TotalPrizeFen: 3000 * Yuan, TotalText: "3,000元", Cards: []ResultCard{won, pending}}, nil, "win",
+			"heading:双色球 第2025107期:win*,field::normal,field:开奖号码:normal,numbers:第1行:win*,numbers:第2行:lose," +
+				"field::normal,field:兑奖地点:win,field:兑奖材料:normal,field::normal,field:税费:normal,field:兑奖截止:normal,warning::warn," +
+				"divider::normal,heading:大乐透 第25108期:pending*,field::normal,numbers:第1行:pending,numbers:第2行:warn,total:合计中奖:win*"},
+		{"没有卡片", CardResponse{Summary: "本次未中奖", TotalText: "0元"}, nil, "lose", "total:合计中奖:lose"},
+		{"字段名按语言翻译", CardResponse{TotalText: "0 CNY", Cards: []ResultCard{{Title: "ssq", Theme: "lose", Winning: balls}}}, catalogFor("en"), "lose",
+			"heading:ssq:lose*,field::normal,field:Winning numbers:normal,total:Total prize:lose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slip := buildSlip(tt.resp, tt.cat)
+			if slip.Title != tt.resp.Summary || slip.Color != tt.wantColor {
+				t.Errorf("slip = %q %s, want %s", slip.Title, slip.Color, tt.wantColor)
+			}
+			if got := slipLines(slip); got != tt.want {
+				t.Errorf("lines =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	// 号码行的值是倍数和状态，材料逐条一行
+	slip := buildSlip(CardResponse{Cards: []ResultCard{won}}, nil)
+	if l := slip.Lines[3]; l.Value != "×2 三等奖 3,000元" || len(l.Balls) != 2 || l.Icon != iconWin {
+		t.Errorf("numbers line = %+v", l)
+	}
+	if slip.Lines[8].Value != "身份证" {
+		t.Errorf("documents line = %+v", slip.Lines[8])
+	}
+}
+
+func TestRespondResultsSlip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestCatalogs(t, t.TempDir())
+	draw := &DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	results := []VerificationResult{{
+		OCRData:      LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: draw.Red, Blue: []string{"01"}, Multiplier: 1}}},
+		SuppliedDraw: draw,
+		TotalPrize:   3000 * Yuan,
+		Details:      []ResultDetail{{Level: 3, Prize: 3000 * Yuan}},
+	}}
+	tests := []struct {
+		query     string
+		wantTotal string
+		wantTitle string
+	}{
+		{"?format=slip", "合计中奖", "双色球 第2025107期"},
+		{"?format=slip&lang=en", "Total prize", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, nil)
+		respondResults(c, results)
+		var slip DisplaySlip
+		if err := json.Unmarshal(w.Body.Bytes(), &slip); err != nil || w.Code != 200 {
+			t.Fatalf("%s: %d %s", tt.query, w.Code, w.Body)
+		}
+		last := slip.Lines[len(slip.Lines)-1]
+		if slip.Color != "win" || last.Kind != "total" || last.Label != tt.wantTotal || !last.Emphasis {
+			t.Errorf("%s: slip = %s", tt.query, w.Body)
+		}
+		if tt.wantTitle != "" && slip.Lines[0].Label != tt.wantTitle {
+			t.Errorf("%s: heading = %+v", tt.query, slip.Lines[0])
+		}
+	}
+}
+
+func TestFeishuResultCard(t *testing.T) {
+	slip := DisplaySlip{Title: "合计中奖 3,000元", Color: "win", Lines: []DisplayLine{
+		{Kind: "heading", Icon: iconWin, Label: "双色球 第2025107期", Value: "恭喜中奖", Emphasis: true},
+		{Kind: "numbers", Icon: iconWin, Label: "第1行", Value: "三等奖", Emphasis: true, Balls: []CardBall{{Number: "02", Hit: true}, {Number: "07"}}},
+		{Kind: "numbers", Icon: iconLose, Label: "第2行", Value: "未中奖", Balls: []CardBall{{Number: "02", Hit: true}, {Number: "09"}}},
+		{Kind: "divider"},
+		{Kind: "field", Value: "共 2 行"},
+	}}
+	card := feishuResultCard(slip)
+	header := card["header"].(map[string]interface{})
+	if header["template"] != "red" || header["title"].(map[string]string)["content"] != slip.Title {
+		t.Errorf("header = %v", header)
+	}
+	var lines []string
+	for _, e := range card["elements"].([]map[string]interface{}) {
+		lines = append(lines, e["content"].(string))
+	}
+	want := []string{
+		"**🎉 双色球 第2025107期 恭喜中奖**",
+		"**🎉 第1行 02 07 三等奖**",
+		"😢 第2行 **02** 09 未中奖",
+		"共 2 行",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if feishuResultCard(DisplaySlip{Color: "lose"})["header"].(map[string]interface{})["template"] != "grey" {
+		t.Error("losing slip not grey")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// DRAWAPI: 开奖结果查询与条件请求（ETag / If-None-Match）
+// ==========================================
+
+// 开奖结果确认后不再变化，客户端却会定时轮询。开奖结果、号码统计和奖池历史的 GET 接口都带强 ETag
+// （响应体的 SHA-256），请求带上 If-None-Match 且内容未变时返回 304，不再重复下载：
+//
+//	GET /api/v1/draws/:game           最近一期（可带 ?issue=）
+//	GET /api/v1/draws/:game/:issue    指定期次
+//
+// 浮动奖级奖金通常开奖当晚稍晚才公布，记录补全后 ETag 随之变化
+
+// respondCacheable 序列化后按内容计算 ETag，与 If-None-Match 匹配时返回 304
+func respondCacheable(c *gin.Context, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache") // 可以缓存，但每次使用前先条件请求确认
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(304)
+		return
+	}
+	c.Data(200, "application/json; charset=utf-8", body)
+}
+
+// etagMatches If-None-Match 可以是 *、单个或逗号分隔的多个 ETag；按 RFC 9110 弱比较，忽略 W/ 前缀
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// presentDraw en 模式下彩种输出为代码
+func presentDraw(c *gin.Context, d DrawRecord) DrawRecord {
+	if apiModeOf(c) == apiModeEN {
+		d.Game = gameCode(d.Game)
+	}
+	return d
+}
+
+// drawHandler GET /api/v1/draws/:game 与 /api/v1/draws/:game/:issue
+func drawHandler(c *gin.Context) {
+	spec, ok := specOf(c.Param("game"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "不支持的彩种: " + c.Param("game")})
+		return
+	}
+	issue := strings.TrimSpace(c.Param("issue"))
+	if issue == "" {
+		issue = strings.TrimSpace(c.Query("issue"))
+	}
+	if issue == "" {
+		history := draws.History(spec.Name)
+		if len(history) == 0 {
+			c.JSON(404, gin.H{"error": "暂无开奖数据"})
+			return
+		}
+		respondCacheable(c, presentDraw(c, history[0]))
+		return
+	}
+	d, ok := draws.Get(spec.Name, issue)
+	if !ok {
+		c.JSON(404, gin.H{"error": "第" + issue + "期尚未开奖或暂无数据"})
+		return
+	}
+	respondCacheable(c, presentDraw(c, d))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{"*", true},
+		{`"abcd"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestDrawHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	older := DrawRecord{Game: "双色球", Issue: "2025106", DrawDate: "2025-09-14", Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}}
+	latest := DrawRecord{Game: "双色球", Issue: "2025107", DrawDate: "2025-09-16", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	useTestDraws(t, older, latest)
+	r := gin.New()
+	r.GET("/api/v1/draws/:game", drawHandler)
+	r.GET("/api/v1/draws/:game/:issue", drawHandler)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	latestETag := get("/api/v1/draws/ssq", "").Header().Get("ETag")
+	if latestETag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		wantStatus  int
+		wantIssue   string
+		wantGame    string
+	}{
+		{"最近一期", "/api/v1/draws/双色球", "", 200, "2025107", "双色球"},
+		{"指定期次", "/api/v1/draws/ssq/2025106", "", 200, "2025106", "双色球"},
+		{"查询参数指定期次", "/api/v1/draws/ssq?issue=2025106", "", 200, "2025106", "双色球"},
+		{"en 模式输出彩种代码", "/api/v1/draws/ssq?api_mode=en", "", 200, "2025107", "ssq"},
+		{"内容未变返回 304", "/api/v1/draws/ssq", latestETag, 304, "", ""},
+		{"ETag 不同照常返回", "/api/v1/draws/ssq/2025106", latestETag, 200, "2025106", "双色球"},
+		{"未开奖", "/api/v1/draws/ssq/2025108", "", 404, "", ""},
+		{"不支持的彩种", "/api/v1/draws/快乐8", "", 404, "", ""},
+		{"暂无开奖数据", "/api/v1/draws/dlt", "", 404, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.path, tt.ifNoneMatch)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			switch w.Code {
+			case 304:
+				if w.Body.Len() != 0 || w.Header().Get("ETag") != latestETag {
+					t.Errorf("304 body = %q, ETag = %q", w.Body, w.Header().Get("ETag"))
+				}
+			case 200:
+				var d DrawRecord
+				if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+					t.Fatal(err)
+				}
+				if d.Issue != tt.wantIssue || d.Game != tt.wantGame {
+					t.Errorf("draw = %s %s, want %s %s", d.Game, d.Issue, tt.wantGame, tt.wantIssue)
+				}
+			}
+		})
+	}
+
+	// 补全奖金后记录内容变化，ETag 随之变化
+	latest.Prizes = map[int]int64{1: 5000000}
+	if _, err := draws.Upsert([]DrawRecord{latest}); err != nil {
+		t.Fatal(err)
+	}
+	if w := get("/api/v1/draws/ssq", latestETag); w.Code != 200 || w.Header().Get("ETag") == latestETag {
+		t.Errorf("after update: status = %d, ETag = %q", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// DRAWS: 开奖数据同步
+// ==========================================
+
+// DrawRecord 一期开奖结果
+type DrawRecord struct {
+	Game     string   `json:"game"`
+	Issue    string   `json:"issue"`
+	Red      []string `json:"red"`
+	Blue     []string `json:"blue"`
+	DrawDate string   `json:"draw_date"`      // 2025-09-16
+	Pool     int64    `json:"pool,omitempty"` // 开奖后滚存到下一期的奖池（元）
+	// 浮动奖级每注实际奖金（元），键为奖级；通常开奖当晚稍晚才公布
+	Prizes      map[int]int64 `json:"prizes,omitempty"`
+	AddOnPrizes map[int]int64 `json:"add_on_prizes,omitempty"` // 大乐透追加每注奖金（元）
+}
+
+// canonicalGame 把 OCR 识别出的彩种名称归一为标准名称
+func canonicalGame(lotteryType string) string {
+	// en 模式的客户端会直接传 ssq / dlt 这样的代码
+	if name, ok := gameAliases[strings.ToLower(strings.TrimSpace(lotteryType))]; ok {
+		return name
+	}
+	switch {
+	case strings.Contains(lotteryType, "双色球"):
+		return "双色球"
+	case strings.Contains(lotteryType, "大乐透"):
+		return "大乐透"
+	case strings.Contains(lotteryType, "排列5"):
+		return "排列5"
+	}
+	if name, ok := dslGameOf(lotteryType); ok {
+		return name
+	}
+	return strings.TrimSpace(lotteryType)
+}
+
+type drawStore struct {
+	mu       sync.RWMutex
+	path     string
+	draws    map[string]DrawRecord // key: game|issue
+	lastSync time.Time
+}
+
+var draws = &drawStore{draws: map[string]DrawRecord{}}
+
+func drawKey(game, issue string) string {
+	return canonicalGame(game) + "|" + strings.TrimSpace(issue)
+}
+
+// Load 从本地文件恢复已同步的开奖数据
+func (s *drawStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []DrawRecord
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for _, d := range list {
+		s.draws[drawKey(d.Game, d.Issue)] = d
+	}
+	return nil
+}
+
+// Get 查询某期开奖结果
+func (s *drawStore) Get(game, issue string) (DrawRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.draws[drawKey(game, issue)]
+	return d, ok
+}
+
+// Upsert 写入一批开奖结果并落盘，返回其中新增或号码有变化的记录
+func (s *drawStore) Upsert(list []DrawRecord) ([]DrawRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changed []DrawRecord
+	for _, d := range list {
+		d.Game = canonicalGame(d.Game)
+		d.Issue = strings.TrimSpace(d.Issue)
+		key := drawKey(d.Game, d.Issue)
+		if old, ok := s.draws[key]; !ok || !sameDraw(old, d) {
+			changed = append(changed, d)
+		}
+		s.draws[key] = d
+	}
+	s.lastSync = time.Now()
+	if s.path == "" || len(changed) == 0 {
+		return changed, nil
+	}
+	all := make([]DrawRecord, 0, len(s.draws))
+	for _, d := range s.draws {
+		all = append(all, d)
+	}
+	raw, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return nil, err
+	}
+	return changed, os.Rename(tmp, s.path)
+}
+
+func sameDraw(a, b DrawRecord) bool {
+	return strings.Join(a.Red, ",") == strings.Join(b.Red, ",") &&
+		strings.Join(a.Blue, ",") == strings.Join(b.Blue, ",") &&
+		a.DrawDate == b.DrawDate && a.Pool == b.Pool &&
+		maps.Equal(a.Prizes, b.Prizes) && maps.Equal(a.AddOnPrizes, b.AddOnPrizes)
+}
+
+// LastSync 上次成功写入开奖数据的时间
+func (s *drawStore) LastSync() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSync
+}
+
+// lookupWinningNumbers 优先使用同步到的开奖数据，没有时退回模拟数据；ctx 已结束时按未开奖返回，
+// 由调用方的阶段检查报告超时
+func lookupWinningNumbers(ctx context.Context, lotteryType, issue string) (WinningNumbers, bool) {
+	if ctx.Err() != nil {
+		return WinningNumbers{}, false
+	}
+	if d, ok := draws.Get(lotteryType, issue); ok {
+		return d.winning(), true
+	}
+	return getMockWinningNumber(lotteryType, issue)
+}
+
+// syncDraws 从 DRAW_FEED_URL 拉取开奖数据（JSON 数组，元素结构同 DrawRecord）
+func syncDraws(ctx context.Context, feedURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("开奖源返回 HTTP %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	var list []DrawRecord
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return fmt.Errorf("开奖数据格式错误: %v", err)
+	}
+	changed, err := draws.Upsert(list)
+	if err != nil {
+		return err
+	}
+	for _, d := range changed {
+		events.Emit(DomainDrawIngested, "", d)
+	}
+	drawFeed.Publish(changed)
+	pendingTickets.OnDraws(changed)
+	drawSubscriptions.OnDraws(changed)
+	broadcastDraws(changed)
+	return nil
+}
+
+// runDrawSync 定时同步开奖数据，失败时通知运维；多副本部署时只有 leader 同步，见 cluster.go
+func runDrawSync(feedURL string) {
+	interval := envDuration("DRAW_SYNC_INTERVAL", 10*time.Minute)
+	for {
+		if !acquireLeadership("draw-sync", 3*interval) {
+			time.Sleep(interval)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err := syncDraws(ctx, feedURL)
+		cancel()
+		if err != nil {
+			log.Printf("开奖数据同步失败: %v", err)
+			notifier.DrawSyncFailed(err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ENSEMBLE: 高额票据双识别服务交叉核对
+// ==========================================
+
+// 大额中奖一旦认错号码代价很高。奖金达到 ENSEMBLE_PRIZE_THRESHOLD（元）或请求带 ?ensemble=true 时，
+// 再用第二家识别服务（OpenAI 兼容的视觉接口）识别同一张图，号码完全一致才自动出结果，
+// 否则转入人工复核队列，等复核员确认号码后再发通知、记历史
+
+type ensembleKey struct{}
+
+// withEnsemble 在请求上下文里标记强制交叉核对，沿 runScan 传递
+func withEnsemble(ctx context.Context, forced bool) context.Context {
+	if !forced {
+		return ctx
+	}
+	return context.WithValue(ctx, ensembleKey{}, true)
+}
+
+func ensembleFrom(ctx context.Context) bool {
+	forced, _ := ctx.Value(ensembleKey{}).(bool)
+	return forced
+}
+
+// ensembleOf ?ensemble=true 或表单字段 ensemble=true
+func ensembleOf(c *gin.Context) bool {
+	v := c.Query("ensemble")
+	if v == "" {
+		v = c.PostForm("ensemble")
+	}
+	return v == "true" || v == "1"
+}
+
+// secondaryOCR 第二识别服务配置：OCR_SECONDARY_URL（如 https://api.openai.com/v1）、
+// OCR_SECONDARY_KEY、OCR_SECONDARY_MODEL（默认 gpt-4o-mini）
+type secondaryOCR struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+func loadSecondaryOCR() (secondaryOCR, bool) {
+	s := secondaryOCR{
+		BaseURL: strings.TrimRight(os.Getenv("OCR_SECONDARY_URL"), "/"),
+		APIKey:  os.Getenv("OCR_SECONDARY_KEY"),
+		Model:   os.Getenv("OCR_SECONDARY_MODEL"),
+	}
+	if s.Model == "" {
+		s.Model = "gpt-4o-mini"
+	}
+	return s, s.BaseURL != "" && s.APIKey != ""
+}
+
+// Recognize 调用 chat/completions，图片以 data URI 内联，返回文本交给 parseOCRText 解析
+func (s secondaryOCR) Recognize(ctx context.Context, fileBytes []byte) (data []LotteryData, err error) {
+	if budgetExceeded() && budgetAction() != "" {
+		// 第二识别服务没有更便宜的模型可降级，超出预算后不再调用，交给人工复核
+		return nil, errBudgetExceeded
+	}
+	mimeType := http.DetectContentType(fileBytes)
+	prompt := ocrPromptFor(imageSourceFrom(ctx, fileBytes)) + "\n只输出 JSON 数组，不要其他文字。"
+	if data, ok, err := replayOCR(debugExchange{Provider: "secondary", Model: s.Model, Prompt: prompt, MIMEType: mimeType}, debugTraceFrom(ctx), nil, fileBytes); ok {
+		return data, err
+	}
+	reqBody := map[string]interface{}{
+		"model":       s.Model,
+		"temperature": 0,
+		"messages": []map[string]interface{}{{
+			"role": "user",
+			"content": []map[string]interface{}{
+				{"type": "text", "text": prompt},
+				{"type": "image_url", "image_url": map[string]string{
+					"url": "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(fileBytes),
+				}},
+			},
+		}},
+	}
+	raw, _ := json.Marshal(reqBody)
+	trace := debugTraceFrom(ctx)
+	ex := debugExchange{Provider: "secondary", Model: s.Model, Prompt: prompt, MIMEType: mimeType, StartedAt: time.Now()}
+	defer func() {
+		ex.DurationMs, ex.Cleaned = time.Since(ex.StartedAt).Milliseconds(), data
+		if err != nil {
+			ex.Error = err.Error()
+		}
+		trace.Record(ex)
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.BaseURL+"/chat/completions", bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOCRProvider, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	ex.RawOutput = string(body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: 第二识别服务返回 %d: %s", errOCRProvider, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+			TotalTokens      int64 `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("解析第二识别服务响应失败: %v", err)
+	}
+	tokenUsage.Add(s.Model, out.Usage.PromptTokens, out.Usage.CompletionTokens, out.Usage.TotalTokens)
+	billing.AddTokens(billingTenantFrom(ctx), s.Model, out.Usage.PromptTokens, out.Usage.CompletionTokens)
+	if len(out.Choices) == 0 || strings.TrimSpace(out.Choices[0].Message.Content) == "" {
+		return nil, fmt.Errorf("第二识别服务无识别结果")
+	}
+	ex.RawOutput = out.Choices[0].Message.Content
+	recordOCR(ex, nil, fileBytes)
+	return parseOCRText(strings.TrimSpace(out.Choices[0].Message.Content))
+}
+
+// highStakes 显式要求，或本次扫描总奖金达到阈值（0 表示不按金额触发）
+func highStakes(ctx context.Context, results []VerificationResult) bool {
+	if ensembleFrom(ctx) {
+		return true
+	}
+	threshold := envInt("ENSEMBLE_PRIZE_THRESHOLD", 0)
+	if threshold <= 0 {
+		return false
+	}
+	var total Fen
+	for _, r := range results {
+		total += r.TotalPrize
+	}
+	return total >= Fen(threshold)*Yuan
+}
+
+// numbersKey 只保留影响中奖的字段：彩种、期号、号码、倍数；无序玩法号码排序
+func numbersKey(l LotteryData) LotteryData {
+	ordered := strings.Contains(l.Type, "排列")
+	norm := func(nums []string) []string {
+		out := make([]string, len(nums))
+		for i, n := range nums {
+			out[i] = strings.TrimSpace(n)
+		}
+		if !ordered {
+			sort.Strings(out)
+		}
+		return out
+	}
+	out := LotteryData{Type: canonicalGame(l.Type), Issue: strings.TrimSpace(l.Issue), Tickets: make([]UserTicket, len(l.Tickets))}
+	for i, t := range l.Tickets {
+		out.Tickets[i] = UserTicket{Red: norm(t.Red), Blue: norm(t.Blue), Dan: norm(t.Dan), BlueDan: norm(t.BlueDan), Multiplier: max(t.Multiplier, 1), AddOn: t.AddOn}
+	}
+	return out
+}
+
+// compareNumbers 两家识别结果的号码差异，空表示一致
+func compareNumbers(primary, secondary []LotteryData) []FieldChange {
+	var out []FieldChange
+	if len(primary) != len(secondary) {
+		out = append(out, FieldChange{Field: "lotteries.count", Before: fmt.Sprint(len(primary)), After: fmt.Sprint(len(secondary))})
+	}
+	for i := 0; i < min(len(primary), len(secondary)); i++ {
+		for _, ch := range diffLottery(numbersKey(primary[i]), numbersKey(secondary[i])) {
+			ch.Field = fmt.Sprintf("lotteries[%d].%s", i+1, ch.Field)
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// ensembleGate 高额票据交叉核对；返回非 nil 的复核单表示不能自动出结果，调用方不再调用 onScanCompleted
+func ensembleGate(ctx context.Context, origin ScanOrigin, fileBytes []byte, primary []LotteryData, results []VerificationResult) (*ReviewItem, error) {
+	if !highStakes(ctx, results) {
+		return nil, nil
+	}
+	sec, ok := loadSecondaryOCR()
+	if !ok {
+		for i := range results {
+			results[i].Warnings = append(results[i].Warnings, "未配置第二识别服务，未做交叉核对")
+		}
+		return nil, nil
+	}
+
+	item := &ReviewItem{ScanOrigin: origin, Primary: primary, Preliminary: results}
+	secCtx, cancel := context.WithTimeout(ctx, envDuration("ENSEMBLE_TIMEOUT", time.Minute))
+	secondary, err := sec.Recognize(secCtx, fileBytes)
+	cancel()
+	if err != nil {
+		// 第二家识别失败等于无法确认，同样交给人工
+		item.Reason = "第二识别服务失败: " + err.Error()
+	} else if item.Disagreements = compareNumbers(primary, secondary); len(item.Disagreements) > 0 {
+		item.Secondary = secondary
+		item.Reason = "两家识别服务号码不一致"
+	} else {
+		return nil, nil
+	}
+	if err := reviewQueue.Add(item, fileBytes); err != nil {
+		return nil, fmt.Errorf("转人工复核失败: %w", err)
+	}
+	events.Emit(DomainReviewRequired, origin.Tenant, item)
+	return item, nil
+}
+
+// reviewRequiredError runScan 转人工复核时返回，入口据此提示用户而不是按识别失败处理
+type reviewRequiredError struct{ ID string }
+
+func (e *reviewRequiredError) Error() string {
+	return fmt.Sprintf("大额票据已转人工复核（复核单 %s）", e.ID)
+}
+
+func asReviewRequired(err error) (*reviewRequiredError, bool) {
+	var rr *reviewRequiredError
+	ok := errors.As(err, &rr)
+	return rr, ok
+}
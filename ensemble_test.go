@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHighStakes(t *testing.T) {
+	results := []VerificationResult{{TotalPrize: 3000 * Yuan}, {TotalPrize: 2000 * Yuan}}
+	tests := []struct {
+		name      string
+		forced    bool
+		threshold string
+		want      bool
+	}{
+		{"未配置不触发", false, "", false},
+		{"显式要求", true, "", true},
+		{"合计达到阈值", false, "5000", true},
+		{"合计未达阈值", false, "5001", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENSEMBLE_PRIZE_THRESHOLD", tt.threshold)
+			if got := highStakes(withEnsemble(context.Background(), tt.forced), results); got != tt.want {
+				t.Errorf("highStakes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	ssq := func(issue string, red ...string) LotteryData {
+		return LotteryData{Type: "双色球", Issue: issue, Serial: "A1", Tickets: []UserTicket{{Red: red, Blue: []string{"07"}}}}
+	}
+	base := []LotteryData{ssq("2025107", "02", "11", "15", "21", "28", "33")}
+	tests := []struct {
+		name      string
+		secondary []LotteryData
+		want      []string
+	}{
+		{"完全一致", []LotteryData{ssq("2025107", "02", "11", "15", "21", "28", "33")}, nil},
+		{"无序玩法顺序不同", []LotteryData{ssq("2025107", "33", "28", "21", "15", "11", "02")}, nil},
+		{"倍数缺省按 1 倍", []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1}}}}, nil},
+		{"只比号码不比序列号", []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "B2", Tickets: base[0].Tickets}}, nil},
+		{"号码不同", []LotteryData{ssq("2025107", "02", "11", "16", "21", "28", "33")}, []string{"lotteries[1].tickets[1].red"}},
+		{"期号不同", []LotteryData{ssq("2025108", "02", "11", "15", "21", "28", "33")}, []string{"lotteries[1].issue"}},
+		{"票数不同", append(append([]LotteryData{}, base...), base...), []string{"lotteries.count"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, ch := range compareNumbers(base, tt.secondary) {
+				got = append(got, ch.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("compareNumbers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// secondaryServer 模拟 OpenAI 兼容的第二识别服务
+func secondaryServer(t *testing.T, status int, content string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEnsembleGate(t *testing.T) {
+	useTestReviews(t)
+	primary := []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1}}}}
+	const same = `[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21","28","33"],"blue":["07"],"multiplier":1}]}]`
+	const differ = `[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21","28","32"],"blue":["07"],"multiplier":1}]}]`
+
+	tests := []struct {
+		name        string
+		forced      bool
+		configured  bool
+		status      int
+		content     string
+		wantReview  string // 复核原因片段，空表示自动出结果
+		wantWarning string
+	}{
+		{"不是高额票据", false, true, 200, differ, "", ""},
+		{"未配置第二识别服务", true, false, 200, same, "", "未配置第二识别服务"},
+		{"两家一致", true, true, 200, same, "", ""},
+		{"号码不一致转人工", true, true, 200, differ, "号码不一致", ""},
+		{"第二识别服务失败转人工", true, true, 500, "", "第二识别服务失败", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := secondaryServer(t, tt.status, tt.content)
+			if tt.configured {
+				t.Setenv("OCR_SECONDARY_URL", srv.URL+"/")
+				t.Setenv("OCR_SECONDARY_KEY", "sk-test")
+			} else {
+				t.Setenv("OCR_SECONDARY_URL", "")
+			}
+			results := []VerificationResult{{OCRData: primary[0], TotalPrize: 5000000 * Yuan}}
+			origin := ScanOrigin{Tenant: "shop-a"}
+			item, err := ensembleGate(withEnsemble(context.Background(), tt.forced), origin, []byte("img"), primary, results)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReview == "" {
+				if item != nil {
+					t.Fatalf("review = %+v, want none", item)
+				}
+			} else {
+				if item == nil || !strings.Contains(item.Reason, tt.wantReview) {
+					t.Fatalf("review = %+v, want reason %q", item, tt.wantReview)
+				}
+				stored, ok := reviewQueue.Get(item.ID)
+				if !ok || stored.Status != ReviewPending || stored.Tenant != "shop-a" {
+					t.Errorf("stored review = %+v, %v", stored, ok)
+				}
+			}
+			if got := strings.Join(results[0].Warnings, "；"); !strings.Contains(got, tt.wantWarning) || (tt.wantWarning == "") != (got == "") {
+				t.Errorf("warnings = %q, want %q", got, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestAsReviewRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		wantID string
+	}{
+		{"复核错误", &reviewRequiredError{ID: "r1"}, "r1"},
+		{"包装后的复核错误", fmt.Errorf("排队任务: %w", &reviewRequiredError{ID: "r2"}), "r2"},
+		{"其他错误", errCircuitOpen, ""},
+		{"没有错误", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, ok := asReviewRequired(tt.err)
+			if ok != (tt.wantID != "") || (ok && rr.ID != tt.wantID) {
+				t.Errorf("asReviewRequired() = %+v, %v, want %q", rr, ok, tt.wantID)
+			}
+		})
+	}
+}
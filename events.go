@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// ==========================================
+// EVENTS: 领域事件发布 (Kafka / NATS)
+// ==========================================
+
+const (
+	DomainScanCompleted  = "scan.completed"
+	DomainScanFailed     = "scan.failed"
+	DomainDrawIngested   = "draw.ingested"
+	DomainTicketWon      = "ticket.won"
+	DomainTicketSettled  = "ticket.settled" // 扫描时待开奖的票在开奖后重新验奖
+	DomainReviewRequired = "scan.review_required"
+	DomainReviewResolved = "scan.review_resolved"
+)
+
+// DomainEvent 发往消息总线的事件信封
+type DomainEvent struct {
+	ID     string      `json:"id"`
+	Type   string      `json:"type"`
+	Time   time.Time   `json:"time"`
+	Tenant string      `json:"tenant,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
+// eventPublisher 消息总线后端
+type eventPublisher interface {
+	Publish(ctx context.Context, ev DomainEvent, payload []byte) error
+}
+
+// eventBus 事件先进内存缓冲，由后台协程发送，业务主流程永远不会被总线拖慢
+type eventBus struct {
+	pub   eventPublisher
+	queue chan DomainEvent
+}
+
+var events = &eventBus{}
+
+// newEventBus 环境变量：
+//
+//	EVENTS_BACKEND=kafka|nats
+//	EVENTS_BROKERS  Kafka 为逗号分隔的 host:port；NATS 为服务器 URL（如 nats://127.0.0.1:4222）
+//	EVENTS_TOPIC    Kafka topic / NATS subject，默认 lottery.events
+func newEventBus() (*eventBus, error) {
+	brokers := strings.TrimSpace(os.Getenv("EVENTS_BROKERS"))
+	topic := strings.TrimSpace(os.Getenv("EVENTS_TOPIC"))
+	if topic == "" {
+		topic = "lottery.events"
+	}
+
+	bus := &eventBus{}
+	switch strings.ToLower(os.Getenv("EVENTS_BACKEND")) {
+	case "":
+		return bus, nil
+	case "kafka":
+		if brokers == "" {
+			return nil, fmt.Errorf("EVENTS_BACKEND=kafka 需要配置 EVENTS_BROKERS")
+		}
+		bus.pub = newKafkaPublisher(strings.Split(brokers, ","), topic)
+	case "nats":
+		if brokers == "" {
+			brokers = nats.DefaultURL
+		}
+		pub, err := newNATSPublisher(brokers, topic)
+		if err != nil {
+			return nil, err
+		}
+		bus.pub = pub
+	default:
+		return nil, fmt.Errorf("未知的 EVENTS_BACKEND: %s", os.Getenv("EVENTS_BACKEND"))
+	}
+	bus.queue = make(chan DomainEvent, envInt("EVENTS_BUFFER", 10000))
+	go bus.run()
+	return bus, nil
+}
+
+// Emit 投递一个事件；未启用或缓冲已满时丢弃
+func (b *eventBus) Emit(eventType, tenant string, data interface{}) {
+	if b.queue == nil {
+		return
+	}
+	ev := DomainEvent{ID: newJobID(), Type: eventType, Time: time.Now(), Tenant: tenant, Data: data}
+	select {
+	case b.queue <- ev:
+	default:
+		log.Printf("事件缓冲已满，丢弃 %s", eventType)
+	}
+}
+
+func (b *eventBus) run() {
+	for ev := range b.queue {
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			log.Printf("事件序列化失败 %s: %v", ev.Type, err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := b.pub.Publish(ctx, ev, payload); err != nil {
+			log.Printf("事件发布失败 %s: %v", ev.Type, err)
+		}
+		cancel()
+	}
+}
+
+// --- Kafka ---
+
+type kafkaPublisher struct {
+	w *kafka.Writer
+}
+
+func newKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	for i := range brokers {
+		brokers[i] = strings.TrimSpace(brokers[i])
+	}
+	return &kafkaPublisher{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+		BatchTimeout: 50 * time.Millisecond,
+		// 异步写入：发送由 kafka-go 内部批量完成，失败通过回调记录
+		Async: true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				log.Printf("Kafka 写入失败 (%d 条): %v", len(messages), err)
+			}
+		},
+	}}
+}
+
+// Publish 以租户为消息 key，保证同一租户的事件落在同一分区、保持顺序
+func (p *kafkaPublisher) Publish(ctx context.Context, ev DomainEvent, payload []byte) error {
+	return p.w.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(ev.Tenant),
+		Value:   payload,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(ev.Type)}},
+		Time:    ev.Time,
+	})
+}
+
+// --- NATS ---
+
+type natsPublisher struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func newNATSPublisher(url, subject string) (*natsPublisher, error) {
+	nc, err := nats.Connect(url, nats.Name("lottery-server"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("连接 NATS 失败: %v", err)
+	}
+	return &natsPublisher{nc: nc, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, ev DomainEvent, payload []byte) error {
+	msg := nats.NewMsg(p.subject)
+	msg.Header.Set("type", ev.Type)
+	msg.Data = payload
+	return p.nc.PublishMsg(msg)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingPublisher 把发布的事件交给 channel
+type recordingPublisher struct {
+	payloads chan []byte
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, ev DomainEvent, payload []byte) error {
+	p.payloads <- payload
+	return nil
+}
+
+func TestNewEventBus(t *testing.T) {
+	tests := []struct {
+		name      string
+		backend   string
+		brokers   string
+		wantErr   string
+		wantQueue bool
+	}{
+		{"未启用", "", "", "", false},
+		{"Kafka 缺少 brokers", "kafka", "", "EVENTS_BROKERS", false},
+		{"Kafka", "kafka", "127.0.0.1:9092", "", true},
+		{"未知后端", "rabbitmq", "", "未知的 EVENTS_BACKEND", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EVENTS_BACKEND", tt.backend)
+			t.Setenv("EVENTS_BROKERS", tt.brokers)
+			bus, err := newEventBus()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (bus.queue != nil) != tt.wantQueue {
+				t.Errorf("queue enabled = %v, want %v", bus.queue != nil, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestEventBusEmit(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		emits   int
+		want    int
+	}{
+		{"未启用时丢弃", false, 1, 0},
+		{"逐个发布", true, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recordingPublisher{payloads: make(chan []byte, tt.emits)}
+			bus := &eventBus{pub: pub}
+			if tt.enabled {
+				bus.queue = make(chan DomainEvent, tt.emits)
+				go bus.run()
+				defer close(bus.queue)
+			}
+			for i := 0; i < tt.emits; i++ {
+				bus.Emit(DomainTicketWon, "shop-a", map[string]int{"n": i})
+			}
+			for i := 0; i < tt.want; i++ {
+				select {
+				case raw := <-pub.payloads:
+					var ev DomainEvent
+					if err := json.Unmarshal(raw, &ev); err != nil {
+						t.Fatal(err)
+					}
+					if ev.Type != DomainTicketWon || ev.Tenant != "shop-a" || ev.ID == "" {
+						t.Errorf("unexpected event %+v", ev)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("received %d events, want %d", i, tt.want)
+				}
+			}
+			select {
+			case <-pub.payloads:
+				t.Errorf("received more than %d events", tt.want)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
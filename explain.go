@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ==========================================
+// EXPLAIN: 逐行验奖说明（命中号码、命中规则、奖金算式、税费）
+// ==========================================
+
+// 用户对结果有疑问时，最常见的是"明明中了几个号怎么没奖""奖金怎么和我算的不一样"。
+// 已开奖的每一行在 details[].explanation 里给出验奖过程：
+//
+//	red_hits / blue_hits  命中的号码（排列类为按位命中的号码，只用 red_hits）
+//	rule                  命中规则：红球中 5 个 + 蓝球中 1 个 → 三等奖；复式/胆拖为拆分后的注数和最高奖级
+//	formula               单倍奖金（含派奖）+ 追加 × 倍数 = 税前奖金
+//	tax_fen / tax_note    单注奖金超过 1 万元的部分按 20% 代扣的个人偶然所得税，after_tax_fen 为税后奖金
+//
+// 复式/胆拖行的注数超过 DETAIL_MAX_EXPAND 时不逐注计税，tax_note 中注明
+
+const (
+	// taxFreeLimit 单注奖金不超过 1 万元免税，超过的按全额 20% 计税
+	taxFreeLimit = 10000 * Yuan
+	taxRatePct   = 20
+)
+
+// RowExplanation 一行号码的验奖说明，金额均为分
+type RowExplanation struct {
+	RedHits     []string    `json:"red_hits"`
+	BlueHits    []string    `json:"blue_hits,omitempty"`
+	Rule        string      `json:"rule"`
+	Bets        int64       `json:"bets"`
+	WinningBets int         `json:"winning_bets,omitempty"`
+	ByLevel     map[int]int `json:"by_level,omitempty"` // 复式/胆拖：奖级 → 中奖注数
+	BasePrize   Fen         `json:"base_prize_fen"`     // 单倍奖金，含派奖，不含追加
+	AddOnPrize  Fen         `json:"add_on_prize_fen,omitempty"`
+	Multiplier  int         `json:"multiplier"`
+	Prize       Fen         `json:"prize_fen"`
+	Formula     string      `json:"formula,omitempty"`
+	Tax         Fen         `json:"tax_fen,omitempty"`
+	TaxNote     string      `json:"tax_note,omitempty"`
+	AfterTax    Fen         `json:"after_tax_fen"`
+}
+
+// explainRow 说明一行的验奖过程；single 为验奖器给出的单倍奖金
+func explainRow(game string, t UserTicket, win WinningNumbers, verifier Verifier, level int, single Fen) *RowExplanation {
+	spec, ok := specOf(game)
+	if !ok {
+		return nil
+	}
+	multiplier := max(1, t.Multiplier)
+	ex := &RowExplanation{Bets: betCount(spec, t), Multiplier: multiplier, Prize: single * Fen(multiplier)}
+	if spec.Ordered {
+		ex.Bets = 1
+	}
+	ex.RedHits, ex.BlueHits = hitNumbers(spec, t, win)
+
+	// 追加部分：去掉追加再验一次，差额即追加奖金
+	ex.BasePrize = single
+	if t.AddOn && single > 0 {
+		plain := t
+		plain.AddOn = false
+		_, base, _ := verifier.Verify(plain, win)
+		ex.BasePrize, ex.AddOnPrize = base, single-base
+	}
+
+	// 税按单注计：单式行就是这一注，复式/胆拖行逐注验奖
+	var taxPerMultiple Fen
+	taxKnown := true
+	if ex.Bets <= 1 {
+		ex.Rule = hitRule(spec, len(ex.RedHits), len(ex.BlueHits), level)
+		taxPerMultiple = taxOf(single)
+	} else {
+		ex.Rule = fmt.Sprintf("%s拆分为 %d 注，未中奖", betTypeOf(game, t), ex.Bets)
+		if single > 0 {
+			ex.ByLevel = map[int]int{}
+			if ex.Bets > int64(envInt("DETAIL_MAX_EXPAND", 100000)) {
+				taxKnown = false
+			} else {
+				for _, bet := range expandBets(game, t) {
+					if l, prize, _ := verifier.Verify(bet, win); prize > 0 {
+						ex.WinningBets++
+						ex.ByLevel[l]++
+						taxPerMultiple += taxOf(prize)
+					}
+				}
+			}
+			ex.Rule = fmt.Sprintf("%s拆分为 %d 注，最高%s", betTypeOf(game, t), ex.Bets, levelName(level))
+		}
+	}
+
+	if ex.Prize > 0 {
+		base := ex.BasePrize.String()
+		if ex.AddOnPrize > 0 {
+			base = fmt.Sprintf("(%s + 追加 %s)", ex.BasePrize, ex.AddOnPrize)
+		}
+		ex.Formula = fmt.Sprintf("%s × %d 倍 = %s", base, multiplier, ex.Prize)
+	}
+	ex.Tax = taxPerMultiple * Fen(multiplier)
+	switch {
+	case !taxKnown:
+		ex.TaxNote = "注数过多未逐注计税，单注奖金超过 1 万元的需缴纳 20% 个人偶然所得税"
+	case ex.Tax > 0:
+		ex.TaxNote = fmt.Sprintf("单注奖金超过 1 万元按 20%% 代扣个人偶然所得税 %s", ex.Tax)
+	}
+	ex.AfterTax = ex.Prize - ex.Tax
+	return ex
+}
+
+// taxOf 一注奖金应缴的个人偶然所得税
+func taxOf(prize Fen) Fen {
+	if prize <= taxFreeLimit {
+		return 0
+	}
+	return prize * taxRatePct / 100
+}
+
+// hitNumbers 命中的号码；胆码和拖码一起算，排列类按位比较
+func hitNumbers(spec gameSpec, t UserTicket, win WinningNumbers) (red, blue []string) {
+	red, blue = []string{}, nil
+	for _, z := range spec.Zones {
+		drawn := win.Red
+		if z.Field == "blue" {
+			drawn = win.Blue
+		}
+		if z.Index >= 0 {
+			if z.Index < len(t.Red) && z.Index < len(drawn) && t.Red[z.Index] == drawn[z.Index] {
+				red = append(red, t.Red[z.Index])
+			}
+			continue
+		}
+		dan, tuo := zoneSelection(z, t)
+		for _, n := range append(append([]string(nil), dan...), tuo...) {
+			if !slices.Contains(drawn, n) {
+				continue
+			}
+			if z.Field == "blue" {
+				blue = append(blue, n)
+			} else {
+				red = append(red, n)
+			}
+		}
+	}
+	return red, blue
+}
+
+// hitRule 单注的命中规则说明
+func hitRule(spec gameSpec, redHits, blueHits, level int) string {
+	if spec.Ordered {
+		return fmt.Sprintf("按位命中 %d/%d 位 → %s", redHits, len(spec.Zones), levelName(level))
+	}
+	parts := make([]string, 0, len(spec.Zones))
+	for _, z := range spec.Zones {
+		hits := redHits
+		if z.Field == "blue" {
+			hits = blueHits
+		}
+		parts = append(parts, fmt.Sprintf("%s中 %d 个", z.Label, hits))
+	}
+	return strings.Join(parts, " + ") + " → " + levelName(level)
+}
+
+// localizeExplanation 翻译说明里的文案；说明可能与验奖缓存共用，返回副本
+func localizeExplanation(cat *messageCatalog, ex *RowExplanation) *RowExplanation {
+	if cat == nil || ex == nil {
+		return ex
+	}
+	out := *ex
+	out.Rule, out.Formula, out.TaxNote = cat.T(ex.Rule), cat.T(ex.Formula), cat.T(ex.TaxNote)
+	return &out
+}
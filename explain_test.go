@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTaxOf(t *testing.T) {
+	tests := []struct {
+		prize Fen
+		want  Fen
+	}{
+		{0, 0},
+		{3000 * Yuan, 0},
+		{10000 * Yuan, 0},
+		{10000*Yuan + 1, 2000 * Yuan},
+		{5000000 * Yuan, 1000000 * Yuan},
+	}
+	for _, tt := range tests {
+		if got := taxOf(tt.prize); got != tt.want {
+			t.Errorf("taxOf(%s) = %s, want %s", tt.prize, got, tt.want)
+		}
+	}
+}
+
+func TestExplainRow(t *testing.T) {
+	ssqWin := WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	dltWin := WinningNumbers{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"},
+		Prizes: map[int]Fen{2: 100000 * Yuan}, AddOnPrizes: map[int]Fen{2: 80000 * Yuan}}
+	pl5Win := WinningNumbers{Red: []string{"1", "2", "3", "4", "6"}}
+
+	tests := []struct {
+		name        string
+		game        string
+		ticket      UserTicket
+		win         WinningNumbers
+		maxExpand   string
+		wantHits    string // 红球命中 | 蓝球命中
+		wantRule    string
+		wantFormula string
+		wantPrize   Fen
+		wantTax     Fen
+		wantNote    string
+	}{
+		{"单式三等奖两倍", "双色球", UserTicket{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"07"}, Multiplier: 2}, ssqWin, "",
+			"02,11,15,21,28|07", "红球中 5 个 + 蓝球中 1 个 → 三等奖", "3,000元 × 2 倍 = 6,000元", 6000 * Yuan, 0, ""},
+		{"未中奖没有算式", "双色球", UserTicket{Red: []string{"02", "03", "04", "05", "06", "08"}, Blue: []string{"09"}}, ssqWin, "",
+			"02|", "红球中 1 个 + 蓝球中 0 个 → 未中奖", "", 0, 0, ""},
+		{"复式拆分", "双色球", UserTicket{Red: []string{"02", "11", "15", "21", "28", "01", "03"}, Blue: []string{"07"}, Multiplier: 1}, ssqWin, "",
+			"02,11,15,21,28|07", "复式拆分为 7 注，最高三等奖", "7,000元 × 1 倍 = 7,000元", 7000 * Yuan, 0, ""},
+		{"注数过多不逐注计税", "双色球", UserTicket{Red: []string{"02", "11", "15", "21", "28", "01", "03"}, Blue: []string{"07"}, Multiplier: 1}, ssqWin, "3",
+			"02,11,15,21,28|07", "复式拆分为 7 注，最高三等奖", "7,000元 × 1 倍 = 7,000元", 7000 * Yuan, 0, "注数过多未逐注计税，单注奖金超过 1 万元的需缴纳 20% 个人偶然所得税"},
+		{"追加投注与税费", "大乐透", UserTicket{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "08"}, Multiplier: 1, AddOn: true}, dltWin, "",
+			"01,02,03,04,05|06", "前区中 5 个 + 后区中 1 个 → 二等奖", "(100,000元 + 追加 80,000元) × 1 倍 = 180,000元", 180000 * Yuan, 36000 * Yuan,
+			"单注奖金超过 1 万元按 20% 代扣个人偶然所得税 36,000元"},
+		{"排列类按位命中", "排列5", UserTicket{Red: []string{"1", "2", "3", "4", "5"}, Multiplier: 1}, pl5Win, "",
+			"1,2,3,4|", "按位命中 4/5 位 → 未中奖", "", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DETAIL_MAX_EXPAND", tt.maxExpand)
+			verifier := selectVerifier(tt.game)
+			level, single, _ := verifier.Verify(tt.ticket, tt.win)
+			ex := explainRow(tt.game, tt.ticket, tt.win, verifier, level, single)
+			if ex == nil {
+				t.Fatal("explainRow() = nil")
+			}
+			if got := strings.Join(ex.RedHits, ",") + "|" + strings.Join(ex.BlueHits, ","); got != tt.wantHits {
+				t.Errorf("hits = %s, want %s", got, tt.wantHits)
+			}
+			if ex.Rule != tt.wantRule || ex.Formula != tt.wantFormula {
+				t.Errorf("rule/formula = %q / %q, want %q / %q", ex.Rule, ex.Formula, tt.wantRule, tt.wantFormula)
+			}
+			if ex.Prize != tt.wantPrize || ex.Tax != tt.wantTax || ex.AfterTax != tt.wantPrize-tt.wantTax || ex.TaxNote != tt.wantNote {
+				t.Errorf("prize %s tax %s after %s note %q", ex.Prize, ex.Tax, ex.AfterTax, ex.TaxNote)
+			}
+		})
+	}
+
+	// 复式按奖级统计中奖注数：5 红 + 蓝 两注三等奖，4 红 + 蓝 五注四等奖
+	row := UserTicket{Red: []string{"02", "11", "15", "21", "28", "01", "03"}, Blue: []string{"07"}, Multiplier: 1}
+	v := selectVerifier("双色球")
+	level, single, _ := v.Verify(row, ssqWin)
+	ex := explainRow("双色球", row, ssqWin, v, level, single)
+	if ex.Bets != 7 || ex.WinningBets != 7 || ex.ByLevel[3] != 2 || ex.ByLevel[4] != 5 {
+		t.Errorf("bets = %d winning %d by level %v", ex.Bets, ex.WinningBets, ex.ByLevel)
+	}
+	if explainRow("未知", row, ssqWin, v, 0, 0) != nil {
+		t.Error("explainRow() for unknown game != nil")
+	}
+}
+
+func TestLocalizeExplanation(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	ex := &RowExplanation{Rule: "红球中 5 个 + 蓝球中 1 个 → 三等奖", Formula: "3,000元 × 2 倍 = 6,000元"}
+	got := localizeExplanation(catalogFor("en"), ex)
+	if got.Rule == ex.Rule || !strings.Contains(got.Rule, "5 matched") || strings.Contains(got.Formula, "倍") {
+		t.Errorf("localizeExplanation() = %+v", got)
+	}
+	if ex.Rule != "红球中 5 个 + 蓝球中 1 个 → 三等奖" {
+		t.Errorf("localizeExplanation modified its input: %+v", ex)
+	}
+	if localizeExplanation(nil, ex) != ex || localizeExplanation(catalogFor("en"), nil) != nil {
+		t.Error("nil catalog or explanation not passed through")
+	}
+}
+
+// 已开奖的行附带说明，未开奖的行没有
+func TestVerifyLotteryExplanation(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	row := UserTicket{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"07"}, Multiplier: 1}
+	tests := []struct {
+		issue string
+		want  bool
+	}{
+		{"2025107", true},
+		{"2099001", false},
+	}
+	for _, tt := range tests {
+		b := newRequestBudget(context.Background())
+		res, err := verifyLottery(b, 0, LotteryData{Type: "双色球", Issue: tt.issue, Tickets: []UserTicket{row}})
+		b.Done()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.Details[0].Explanation; (got != nil) != tt.want || got != nil && got.AfterTax != 3000*Yuan {
+			t.Errorf("%s: explanation = %+v", tt.issue, got)
+		}
+	}
+}
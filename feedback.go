@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// FEEDBACK: 用户对识别结果的反馈与准确率统计
+// ==========================================
+
+// 用户核对票面后可以告诉我们识别对不对，认错时顺便给出正确的号码：
+//
+//	POST /api/v1/scans/:id/feedback
+//	{"correct": false, "lottery_index": 0, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": [...]}],
+//	 "comment": "第二行蓝球是 09"}
+//
+// lottery_index 只针对记录中的某一张票（从 0 开始），corrections 此时只需一项；不填则针对整次扫描，
+// corrections 按顺序对应全部票。反馈与扫描记录一起保存识别服务、模型和提示词版本，
+// 管理员通过 GET /api/v1/admin/accuracy 按识别服务 / 提示词版本查看准确率与最常出错的字段。
+// 同一用户对同一条记录多次反馈时以最后一次为准。租户和用户校验同 reprocess。
+//
+// 更正与识别结果不同时，按更正后的号码用最新开奖数据重新验奖，结果作为记录的新版本保存
+// （reprocess 为 correction，原识别结果作为旧版本保留），响应的 corrected 给出新版本与验奖结果；
+// 更正同时计入准确率统计
+
+// ScanFeedback 一次反馈
+type ScanFeedback struct {
+	ID            string        `json:"id"`
+	ScanID        string        `json:"scan_id"`
+	Tenant        string        `json:"tenant"`
+	UserID        string        `json:"user_id,omitempty"`
+	Correct       bool          `json:"correct"`
+	LotteryIndex  *int          `json:"lottery_index,omitempty"`
+	Corrections   []LotteryData `json:"corrections,omitempty"`
+	Changes       []FieldChange `json:"changes,omitempty"`    // 识别结果与用户更正的差异
+	VersionID     string        `json:"version_id,omitempty"` // 按更正重新验奖后产生的记录版本
+	Comment       string        `json:"comment,omitempty"`
+	OCREngine     string        `json:"ocr_engine"`
+	PromptVersion string        `json:"prompt_version,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// FeedbackRequest 请求体
+type FeedbackRequest struct {
+	Correct      *bool         `json:"correct"`
+	LotteryIndex *int          `json:"lottery_index"`
+	Corrections  []LotteryData `json:"corrections"`
+	Comment      string        `json:"comment"`
+}
+
+// feedbackSchema POST /api/v1/scans/:id/feedback
+var feedbackSchema = &jsonSchema{
+	Type:     jsonObject,
+	Required: []string{"correct"},
+	Fields: map[string]*jsonSchema{
+		"correct":       {Type: jsonBool},
+		"lottery_index": {Type: jsonInteger, Min: floatPtr(0)},
+		"corrections":   {Type: jsonArray, Items: lotteryDataSchema, MaxItems: 50},
+		"comment":       {Type: jsonString},
+	},
+}
+
+// currentOCREngine 新扫描使用的识别服务与模型，如 gemini/gemini-2.5-flash；重新识别的版本见 AddVersion
+func currentOCREngine() string {
+	if mockOCREnabled() {
+		return providerMock
+	}
+	return providerGemini + "/" + ocrModel()
+}
+
+// promptVersion 识别提示词的短哈希，提示词改动后准确率分开统计
+func promptVersion() string {
+	sum := sha256.Sum256([]byte(ocrPrompt))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+type feedbackStore struct {
+	mu    sync.Mutex
+	path  string
+	items []ScanFeedback
+}
+
+var scanFeedback = &feedbackStore{}
+
+// Load 读取反馈文件，不存在时为空
+func (s *feedbackStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := readSealedFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &s.items)
+}
+
+// saveLocked 先写临时文件再改名；调用方需持有锁
+func (s *feedbackStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	if err := writeSealedFile(s.path+".tmp", raw); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+func (s *feedbackStore) Add(fb ScanFeedback) (ScanFeedback, error) {
+	fb.ID, fb.CreatedAt = newJobID(), time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, fb)
+	if err := s.saveLocked(); err != nil {
+		s.items = s.items[:len(s.items)-1]
+		return ScanFeedback{}, err
+	}
+	return fb, nil
+}
+
+// latest 每个用户对每条记录的最后一次反馈
+func (s *feedbackStore) latest() []ScanFeedback {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := map[string]int{}
+	for i, fb := range s.items {
+		last[fb.Tenant+"\x00"+fb.UserID+"\x00"+fb.ScanID] = i
+	}
+	out := make([]ScanFeedback, 0, len(last))
+	for _, i := range last {
+		out = append(out, s.items[i])
+	}
+	return out
+}
+
+// AccuracyLine 一个识别服务 + 提示词版本的统计
+type AccuracyLine struct {
+	OCREngine     string         `json:"ocr_engine"`
+	PromptVersion string         `json:"prompt_version"`
+	Feedback      int            `json:"feedback"`
+	Correct       int            `json:"correct"`
+	Incorrect     int            `json:"incorrect"`
+	Accuracy      float64        `json:"accuracy"`
+	FieldErrors   map[string]int `json:"field_errors,omitempty"` // 字段（不含票序号）→ 被更正的次数
+}
+
+var ticketIndexRe = regexp.MustCompile(`\[\d+\]`)
+
+// Accuracy 按识别服务与提示词版本汇总；tenant 为空时统计全部租户，since 为零值时不限时间
+func (s *feedbackStore) Accuracy(tenant string, since time.Time) []AccuracyLine {
+	lines := map[string]*AccuracyLine{}
+	for _, fb := range s.latest() {
+		if (tenant != "" && fb.Tenant != tenant) || fb.CreatedAt.Before(since) {
+			continue
+		}
+		engine := fb.OCREngine
+		if engine == "" {
+			engine = "unknown"
+		}
+		key := engine + "\x00" + fb.PromptVersion
+		l := lines[key]
+		if l == nil {
+			l = &AccuracyLine{OCREngine: engine, PromptVersion: fb.PromptVersion, FieldErrors: map[string]int{}}
+			lines[key] = l
+		}
+		l.Feedback++
+		if fb.Correct {
+			l.Correct++
+		} else {
+			l.Incorrect++
+		}
+		for _, ch := range fb.Changes {
+			l.FieldErrors[ticketIndexRe.ReplaceAllString(ch.Field, "")]++
+		}
+	}
+	out := make([]AccuracyLine, 0, len(lines))
+	for _, l := range lines {
+		l.Accuracy = float64(l.Correct) / float64(l.Feedback)
+		out = append(out, *l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].OCREngine != out[j].OCREngine {
+			return out[i].OCREngine < out[j].OCREngine
+		}
+		return out[i].PromptVersion < out[j].PromptVersion
+	})
+	return out
+}
+
+// normalizeCorrections 未填倍数按 1 倍，与识别结果的口径一致，避免比对时把倍数算作更正
+func normalizeCorrections(list []LotteryData) {
+	for i := range list {
+		for j := range list[i].Tickets {
+			list[i].Tickets[j].Multiplier = max(list[i].Tickets[j].Multiplier, 1)
+		}
+		normalizeCorrections(list[i].Sections)
+	}
+}
+
+// correctionChanges 识别结果与更正之间的差异；lotteries[i] 下标超出时视为漏识别的票
+func correctionChanges(rec ScanRecord, index *int, corrections []LotteryData) []FieldChange {
+	recognized := rec.Lotteries
+	if index != nil {
+		recognized = rec.Lotteries[*index : *index+1]
+	}
+	var out []FieldChange
+	for i, corr := range corrections {
+		var before LotteryData
+		if i < len(recognized) {
+			before = recognized[i]
+		}
+		out = append(out, diffLottery(before, corr)...)
+	}
+	return out
+}
+
+// scanFeedbackHandler POST /api/v1/scans/:id/feedback
+func scanFeedbackHandler(c *gin.Context) {
+	rec, ok := ownScan(c)
+	if !ok {
+		return
+	}
+	var req FeedbackRequest
+	if !bindValidJSON(c, feedbackSchema, &req) {
+		return
+	}
+	if req.LotteryIndex != nil && *req.LotteryIndex >= len(rec.Lotteries) {
+		c.JSON(400, gin.H{"error": "lottery_index 超出记录中的票数"})
+		return
+	}
+	if *req.Correct && len(req.Corrections) > 0 {
+		c.JSON(400, gin.H{"error": "识别正确时不需要 corrections"})
+		return
+	}
+	if req.LotteryIndex != nil && len(req.Corrections) > 1 {
+		c.JSON(400, gin.H{"error": "corrections 只能有一项"})
+		return
+	}
+
+	normalizeCorrections(req.Corrections)
+	fb := ScanFeedback{
+		ScanID: rec.ID, Tenant: rec.Tenant, UserID: rec.UserID,
+		Correct: *req.Correct, LotteryIndex: req.LotteryIndex, Corrections: req.Corrections,
+		Changes: correctionChanges(rec, req.LotteryIndex, req.Corrections), Comment: strings.TrimSpace(req.Comment),
+		OCREngine: rec.OCREngine, PromptVersion: rec.PromptVersion,
+	}
+	// 号码有改动时按更正后的号码重新验奖，结果作为记录的新版本，原识别结果保留
+	var version gin.H
+	if len(fb.Changes) > 0 {
+		budget := newRequestBudget(withBillingTenant(c.Request.Context(), rec.Tenant))
+		defer budget.Done()
+		lotteries := correctedLotteries(rec, req.LotteryIndex, req.Corrections)
+		results, err := verifyEach(budget, lotteries)
+		if err != nil {
+			respondStageError(c, "验奖失败: ", err)
+			return
+		}
+		corrected, err := scanHistory.AddVersion(rec, lotteries, "correction")
+		if err != nil {
+			c.JSON(500, gin.H{"error": "保存扫描记录失败: " + err.Error()})
+			return
+		}
+		fb.VersionID = corrected.ID
+		version = gin.H{
+			"id": corrected.ID, "original_id": corrected.OriginalID, "version": corrected.Version,
+			"results": presentResults(c, results),
+		}
+	}
+
+	fb, err := scanFeedback.Add(fb)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "保存反馈失败: " + err.Error()})
+		return
+	}
+	c.JSON(201, gin.H{"feedback": fb, "corrected": version})
+}
+
+// correctedLotteries 用更正替换记录中的票：指定 lottery_index 时只替换那一张，否则整体替换
+func correctedLotteries(rec ScanRecord, index *int, corrections []LotteryData) []LotteryData {
+	if index == nil {
+		return corrections
+	}
+	out := append([]LotteryData(nil), rec.Lotteries...)
+	out[*index] = corrections[0]
+	return out
+}
+
+// adminAccuracyHandler GET /api/v1/admin/accuracy?tenant=&since=2025-01-01
+func adminAccuracyHandler(c *gin.Context) {
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, chinaTime)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "since 格式应为 YYYY-MM-DD"})
+			return
+		}
+		since = t
+	}
+	c.JSON(200, gin.H{
+		"current": gin.H{"ocr_engine": currentOCREngine(), "prompt_version": promptVersion()},
+		"lines":   scanFeedback.Accuracy(c.Query("tenant"), since),
+	})
+}
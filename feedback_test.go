@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestFeedback 换成临时目录里的反馈记录
+func useTestFeedback(t *testing.T) string {
+	t.Helper()
+	old := scanFeedback
+	t.Cleanup(func() { scanFeedback = old })
+	path := filepath.Join(t.TempDir(), "feedback.json")
+	scanFeedback = &feedbackStore{path: path}
+	return path
+}
+
+func TestFeedbackStoreAccuracy(t *testing.T) {
+	day := time.Date(2025, 9, 10, 12, 0, 0, 0, chinaTime)
+	blue := []FieldChange{{Field: "tickets[1].blue", Before: "09", After: "07"}}
+	s := &feedbackStore{items: []ScanFeedback{
+		{ScanID: "a", Tenant: "shop-a", UserID: "u1", Correct: true, OCREngine: "gemini/flash", PromptVersion: "v1", CreatedAt: day},
+		{ScanID: "b", Tenant: "shop-a", UserID: "u1", Correct: true, OCREngine: "gemini/flash", PromptVersion: "v1", CreatedAt: day},
+		// 同一用户对 b 再次反馈，以最后一次为准
+		{ScanID: "b", Tenant: "shop-a", UserID: "u1", Correct: false, Changes: blue, OCREngine: "gemini/flash", PromptVersion: "v1", CreatedAt: day.Add(time.Hour)},
+		{ScanID: "c", Tenant: "shop-a", UserID: "u2", Correct: false, Changes: append(blue, FieldChange{Field: "tickets[2].blue"}, FieldChange{Field: "issue"}), OCREngine: "gemini/flash", PromptVersion: "v2", CreatedAt: day},
+		{ScanID: "d", Tenant: "shop-b", UserID: "u3", Correct: true, PromptVersion: "v1", CreatedAt: day.AddDate(0, 0, -10)},
+	}}
+
+	tests := []struct {
+		name   string
+		tenant string
+		since  time.Time
+		want   string // 识别服务/提示词版本 反馈数 正确数 出错字段
+	}{
+		{"全部租户", "", time.Time{}, "gemini/flash/v1 2 1 blue=1; gemini/flash/v2 1 0 blue=2,issue=1; unknown/v1 1 1 "},
+		{"按租户", "shop-b", time.Time{}, "unknown/v1 1 1 "},
+		{"按时间", "", day.AddDate(0, 0, -1), "gemini/flash/v1 2 1 blue=1; gemini/flash/v2 1 0 blue=2,issue=1"},
+		{"没有反馈", "shop-c", time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			for _, l := range s.Accuracy(tt.tenant, tt.since) {
+				if want := float64(l.Correct) / float64(l.Feedback); l.Accuracy != want || l.Correct+l.Incorrect != l.Feedback {
+					t.Errorf("line = %+v", l)
+				}
+				var fields []string
+				for _, f := range []string{"blue", "issue"} {
+					if n := l.FieldErrors["tickets."+f] + l.FieldErrors[f]; n > 0 {
+						fields = append(fields, f+"="+strconv.Itoa(n))
+					}
+				}
+				lines = append(lines, fmt.Sprintf("%s/%s %d %d %s", l.OCREngine, l.PromptVersion, l.Feedback, l.Correct, strings.Join(fields, ",")))
+			}
+			if got := strings.Join(lines, "; "); got != tt.want {
+				t.Errorf("Accuracy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedbackStorePersist(t *testing.T) {
+	useTestAtRest(t, testMasterKey(1))
+	path := filepath.Join(t.TempDir(), "feedback.json")
+	s := &feedbackStore{path: path}
+	fb, err := s.Add(ScanFeedback{ScanID: "a", Tenant: "shop-a", UserID: "user-1", Correct: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fb.ID == "" || fb.CreatedAt.IsZero() {
+		t.Errorf("Add() = %+v", fb)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCount int
+	}{
+		{"重新加载", path, 1},
+		{"文件不存在", filepath.Join(t.TempDir(), "missing.json"), 0},
+	}
+	for _, tt := range tests {
+		loaded := &feedbackStore{}
+		if err := loaded.Load(tt.path); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(loaded.items) != tt.wantCount || (tt.wantCount > 0 && loaded.items[0].UserID != "user-1") {
+			t.Errorf("%s: items = %+v", tt.name, loaded.items)
+		}
+	}
+}
+
+func TestCorrectionChanges(t *testing.T) {
+	rec := ScanRecord{Lotteries: []LotteryData{
+		{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"01"}, Blue: []string{"09"}, Multiplier: 1}}},
+		{Type: "大乐透", Issue: "25107"},
+	}}
+	fixed := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"01"}, Blue: []string{"07"}, Multiplier: 1}}}
+	one, two := 0, 1
+
+	tests := []struct {
+		name        string
+		index       *int
+		corrections []LotteryData
+		want        string
+	}{
+		{"整次扫描", nil, []LotteryData{fixed, rec.Lotteries[1]}, "tickets[1].blue"},
+		{"指定第一张票", &one, []LotteryData{fixed}, "tickets[1].blue"},
+		{"指定第二张票", &two, []LotteryData{rec.Lotteries[1]}, ""},
+		{"漏识别的票", nil, []LotteryData{rec.Lotteries[0], rec.Lotteries[1], {Type: "七星彩", Issue: "25100"}}, "type,issue"},
+	}
+	for _, tt := range tests {
+		var fields []string
+		for _, ch := range correctionChanges(rec, tt.index, tt.corrections) {
+			fields = append(fields, ch.Field)
+		}
+		if got := strings.Join(fields, ","); got != tt.want {
+			t.Errorf("%s: changes = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 新扫描记下当前识别服务与提示词版本，重新识别的版本记下新的识别服务
+func TestHistoryRecordsOCREngine(t *testing.T) {
+	t.Setenv("OCR_PROVIDER", "")
+	s := &historyStore{}
+	s.Add(ScanOrigin{Tenant: "shop-a", UserID: "u1"}, nil, []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107"}}})
+	if len(s.records) != 1 {
+		t.Fatalf("stored %d records", len(s.records))
+	}
+	rec := s.records[0]
+
+	tests := []struct {
+		how        string
+		wantEngine string
+	}{
+		{"verify", currentOCREngine()},
+		{"correction", currentOCREngine()},
+		{"ocr:secondary/gpt-4o-mini", "secondary/gpt-4o-mini"},
+	}
+	if rec.OCREngine != "gemini/"+ocrModel() || rec.PromptVersion != promptVersion() || len(rec.PromptVersion) != 8 {
+		t.Fatalf("record = %s %s", rec.OCREngine, rec.PromptVersion)
+	}
+	for _, tt := range tests {
+		v, err := s.AddVersion(rec, nil, tt.how)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.OCREngine != tt.wantEngine || v.PromptVersion != rec.PromptVersion {
+			t.Errorf("AddVersion(%s) engine = %s %s, want %s", tt.how, v.OCREngine, v.PromptVersion, tt.wantEngine)
+		}
+	}
+	t.Setenv("OCR_PROVIDER", providerMock)
+	if got := currentOCREngine(); got != providerMock {
+		t.Errorf("currentOCREngine() with mock = %s", got)
+	}
+}
+
+// feedbackTestRecord 识别出一张双色球，蓝球识别成了 09
+var feedbackTestRecord = ScanRecord{
+	ID: "r1", Tenant: "default", UserID: "u1", OCREngine: "gemini/flash", PromptVersion: "v1",
+	Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"09"}, Multiplier: 1},
+	}}},
+}
+
+// postFeedback 以 user 的身份提交反馈
+func postFeedback(id, user, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/scans/"+id+"/feedback", bytes.NewBufferString(body))
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("auth_session", "s1")
+	c.Set("auth_user", user)
+	scanFeedbackHandler(c)
+	return w
+}
+
+func TestScanFeedbackHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+
+	tests := []struct {
+		name       string
+		id         string
+		user       string
+		body       string
+		wantStatus int
+		wantSaved  bool
+	}{
+		{"识别正确", "r1", "u1", `{"correct": true, "comment": " 都对 "}`, 201, true},
+		{"认错但号码没有改", "r1", "u1", `{"correct": false, "lottery_index": 0, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", "11", "15", "21", "28", "33"], "blue": ["09"]}]}]}`, 201, true},
+		{"缺少 correct", "r1", "u1", `{"comment": "x"}`, 400, false},
+		{"正确时带更正", "r1", "u1", `{"correct": true, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": []}]}`, 400, false},
+		{"lottery_index 超出票数", "r1", "u1", `{"correct": false, "lottery_index": 1}`, 400, false},
+		{"lottery_index 为负数", "r1", "u1", `{"correct": false, "lottery_index": -1}`, 400, false},
+		{"指定票时多项更正", "r1", "u1", `{"correct": false, "lottery_index": 0, "corrections": [{"type": "双色球", "issue": "1", "tickets": []}, {"type": "双色球", "issue": "2", "tickets": []}]}`, 400, false},
+		{"其他用户的记录", "r1", "u2", `{"correct": true}`, 404, false},
+		{"记录不存在", "r9", "u1", `{"correct": true}`, 404, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestFeedback(t)
+			scanHistory = &historyStore{records: []ScanRecord{feedbackTestRecord}}
+			w := postFeedback(tt.id, tt.user, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := len(scanFeedback.items); got != map[bool]int{true: 1}[tt.wantSaved] {
+				t.Fatalf("saved %d feedback", got)
+			}
+			if !tt.wantSaved {
+				return
+			}
+			// 反馈带上记录的识别服务，备注去掉首尾空白，号码没改时不产生新版本
+			fb := scanFeedback.items[0]
+			if fb.ScanID != "r1" || fb.Tenant != "default" || fb.UserID != "u1" || fb.OCREngine != "gemini/flash" || fb.PromptVersion != "v1" {
+				t.Errorf("feedback = %+v", fb)
+			}
+			if len(fb.Changes) != 0 || fb.VersionID != "" || len(scanHistory.records) != 1 || strings.HasPrefix(fb.Comment, " ") {
+				t.Errorf("feedback = %+v, %d records", fb, len(scanHistory.records))
+			}
+		})
+	}
+}
+
+func TestAdminAccuracyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestFeedback(t)
+	scanFeedback.items = []ScanFeedback{
+		{ScanID: "a", Tenant: "shop-a", Correct: true, OCREngine: "gemini/flash", PromptVersion: "v1", CreatedAt: time.Now()},
+		{ScanID: "b", Tenant: "shop-b", Correct: false, OCREngine: "gemini/flash", PromptVersion: "v1", CreatedAt: time.Now()},
+	}
+
+	tests := []struct {
+		query        string
+		wantStatus   int
+		wantFeedback int
+	}{
+		{"", 200, 2},
+		{"?tenant=shop-a", 200, 1},
+		{"?since=2025-01-01", 200, 2},
+		{"?since=2999-01-01", 200, 0},
+		{"?since=2025/01/01", 400, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/admin/accuracy"+tt.query, nil)
+		adminAccuracyHandler(c)
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", tt.query, w.Code, tt.wantStatus)
+		}
+		if w.Code != 200 {
+			continue
+		}
+		var resp struct {
+			Current map[string]string `json:"current"`
+			Lines   []AccuracyLine    `json:"lines"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		total := 0
+		for _, l := range resp.Lines {
+			total += l.Feedback
+		}
+		if total != tt.wantFeedback || resp.Current["prompt_version"] != promptVersion() {
+			t.Errorf("%s: response = %s", tt.query, w.Body)
+		}
+	}
+}
+
+func TestCorrectedLotteries(t *testing.T) {
+	rec := ScanRecord{Lotteries: []LotteryData{{Issue: "1"}, {Issue: "2"}}}
+	second := 1
+	tests := []struct {
+		name  string
+		index *int
+		want  string
+	}{
+		{"整体替换", nil, "9"},
+		{"只替换指定的票", &second, "1,9"},
+	}
+	for _, tt := range tests {
+		var issues []string
+		for _, l := range correctedLotteries(rec, tt.index, []LotteryData{{Issue: "9"}}) {
+			issues = append(issues, l.Issue)
+		}
+		if got := strings.Join(issues, ","); got != tt.want {
+			t.Errorf("%s: issues = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if rec.Lotteries[1].Issue != "2" {
+		t.Error("original record modified")
+	}
+}
+
+// 更正号码后按更正重新验奖，结果作为新版本保存，原识别结果保留
+func TestScanFeedbackReverify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	useTestBilling(t)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantChanges string
+		wantPrize   Fen // 新版本的奖金，0 表示不产生新版本
+	}{
+		{"更正蓝球", `{"correct": false, "lottery_index": 0, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", "11", "15", "21", "28", "01"], "blue": ["07"]}]}]}`, "tickets[1].blue", 300000},
+		{"整次扫描更正", `{"correct": false, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", "11", "15", "21", "28", "01"], "blue": ["07"], "multiplier": 2}]}]}`, "tickets[1].blue,tickets[1].multiplier", 600000},
+		{"号码没有改", `{"correct": false, "corrections": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", "11", "15", "21", "28", "01"], "blue": ["09"]}]}]}`, "", 0},
+		{"识别正确", `{"correct": true}`, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestFeedback(t)
+			rec := feedbackTestRecord
+			rec.Lotteries = []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+				{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"09"}, Multiplier: 1},
+			}}}
+			scanHistory = &historyStore{records: []ScanRecord{rec}}
+			w := postFeedback("r1", "u1", tt.body)
+			if w.Code != 201 {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Feedback  ScanFeedback `json:"feedback"`
+				Corrected *struct {
+					ID         string               `json:"id"`
+					OriginalID string               `json:"original_id"`
+					Version    int                  `json:"version"`
+					Results    []VerificationResult `json:"results"`
+				} `json:"corrected"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, ch := range resp.Feedback.Changes {
+				fields = append(fields, ch.Field)
+			}
+			if got := strings.Join(fields, ","); got != tt.wantChanges {
+				t.Errorf("changes = %s, want %s", got, tt.wantChanges)
+			}
+			if tt.wantPrize == 0 {
+				if resp.Corrected != nil || resp.Feedback.VersionID != "" || len(scanHistory.records) != 1 {
+					t.Errorf("unexpected version: %s", w.Body)
+				}
+				return
+			}
+			v := resp.Corrected
+			if v == nil || v.OriginalID != "r1" || v.Version != 2 || v.ID != resp.Feedback.VersionID {
+				t.Fatalf("corrected = %s", w.Body)
+			}
+			if len(v.Results) != 1 || v.Results[0].TotalPrize != tt.wantPrize {
+				t.Errorf("results = %+v, want prize %d", v.Results, tt.wantPrize)
+			}
+			stored, ok := scanHistory.Get(v.ID)
+			if !ok || stored.Reprocess != "correction" || stored.Lotteries[0].Tickets[0].Blue[0] != "07" {
+				t.Errorf("stored version = %+v", stored)
+			}
+			if orig, _ := scanHistory.Get("r1"); orig.Lotteries[0].Tickets[0].Blue[0] != "09" {
+				t.Errorf("original record modified: %+v", orig)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// FEISHU: 飞书群机器人通知 + 应用机器人扫码验奖
+// ==========================================
+
+const feishuAPI = "https://open.feishu.cn/open-apis"
+
+// FeishuHook 飞书自定义机器人，Secret 为空表示未开启签名校验
+type FeishuHook struct {
+	Webhook string `json:"webhook"`
+	Secret  string `json:"secret"`
+}
+
+// feishuNotifier 通过自定义机器人 webhook 推送消息卡片
+// 文档: https://open.feishu.cn/document/client-docs/bot-v3/add-custom-bot
+type feishuNotifier struct {
+	hook FeishuHook
+}
+
+func (n *feishuNotifier) Name() string { return "feishu" }
+
+func (n *feishuNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	template := "blue"
+	if ev.Kind == EventScanWon {
+		template = "red"
+	} else if ev.Kind == EventDrawSyncFailed {
+		template = "orange"
+	}
+	body := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": feishuCard(ev.Title, template, append(ev.Lines,
+			fmt.Sprintf("租户: %s · %s", ev.Tenant, ev.Time.Format("2006-01-02 15:04:05")))),
+	}
+	if n.hook.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		// 飞书的签名以 "timestamp\nsecret" 作为 HMAC 密钥、对空串签名
+		mac := hmac.New(sha256.New, []byte(ts+"\n"+n.hook.Secret))
+		body["timestamp"] = ts
+		body["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := feishuPost(ctx, n.hook.Webhook, "", body, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("飞书返回错误 %d: %s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// feishuCard 标题 + 若干 markdown 行组成的消息卡片
+func feishuCard(title, template string, lines []string) map[string]interface{} {
+	elements := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		elements = append(elements, map[string]interface{}{"tag": "markdown", "content": line})
+	}
+	return map[string]interface{}{
+		"config": map[string]bool{"wide_screen_mode": true},
+		"header": map[string]interface{}{
+			"title":    map[string]string{"tag": "plain_text", "content": title},
+			"template": template,
+		},
+		"elements": elements,
+	}
+}
+
+// feishuResultCard 把验奖展示单转换为飞书消息卡片，强调的行整行加粗，其余行只加粗命中的号码
+func feishuResultCard(slip DisplaySlip) map[string]interface{} {
+	template := "grey"
+	if slip.Color == "win" {
+		template = "red"
+	}
+	lines := []string{}
+	for _, line := range slip.Lines {
+		nums := make([]string, len(line.Balls))
+		for i, b := range line.Balls {
+			nums[i] = b.Number
+			if b.Hit && !line.Emphasis {
+				nums[i] = "**" + b.Number + "**"
+			}
+		}
+		if line.Kind == "divider" {
+			continue
+		}
+		text := strings.Join(strings.Fields(strings.Join([]string{line.Icon, line.Label, strings.Join(nums, " "), line.Value}, " ")), " ")
+		if line.Emphasis {
+			text = "**" + text + "**"
+		}
+		lines = append(lines, text)
+	}
+	return feishuCard(slip.Title, template, lines)
+}
+
+// feishuPost 发送 JSON 请求，token 非空时带上 Authorization
+func feishuPost(ctx context.Context, endpoint, token string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("飞书响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// --- 应用机器人：群里发图片，机器人回复验奖卡片 ---
+
+// feishuApp 环境变量 FEISHU_APP_ID / FEISHU_APP_SECRET / FEISHU_VERIFICATION_TOKEN /
+// FEISHU_ENCRYPT_KEY / FEISHU_TENANT（飞书消息归属的租户，默认 default）
+type feishuApp struct {
+	appID, appSecret, verifyToken, encryptKey, tenant string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	seen        *sharedCache[bool] // 事件去重：飞书超时未收到 200 会重推
+}
+
+var feishuBot = loadFeishuApp()
+
+func loadFeishuApp() *feishuApp {
+	tenant := os.Getenv("FEISHU_TENANT")
+	if tenant == "" {
+		tenant = "default"
+	}
+	return &feishuApp{
+		appID:       os.Getenv("FEISHU_APP_ID"),
+		appSecret:   os.Getenv("FEISHU_APP_SECRET"),
+		verifyToken: os.Getenv("FEISHU_VERIFICATION_TOKEN"),
+		encryptKey:  os.Getenv("FEISHU_ENCRYPT_KEY"),
+		tenant:      tenant,
+		seen:        newSharedCache[bool]("feishu_seen", 10000, time.Hour),
+	}
+}
+
+func (a *feishuApp) enabled() bool { return a.appID != "" && a.appSecret != "" }
+
+// tenantToken 获取并缓存 tenant_access_token（有效期 2 小时，提前 5 分钟刷新）
+func (a *feishuApp) tenantToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Now().Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+	var result struct {
+		Code   int    `json:"code"`
+		Msg    string `json:"msg"`
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}
+	err := feishuPost(ctx, feishuAPI+"/auth/v3/tenant_access_token/internal", "",
+		map[string]string{"app_id": a.appID, "app_secret": a.appSecret}, &result)
+	if err != nil {
+		return "", err
+	}
+	if result.Code != 0 {
+		return "", fmt.Errorf("获取飞书 token 失败 %d: %s", result.Code, result.Msg)
+	}
+	a.token = result.Token
+	a.tokenExpiry = time.Now().Add(time.Duration(result.Expire)*time.Second - 5*time.Minute)
+	return a.token, nil
+}
+
+// decrypt 开启了 Encrypt Key 时事件体为 AES-256-CBC 密文
+func (a *feishuApp) decrypt(encrypted string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(a.encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aes.BlockSize || len(raw)%aes.BlockSize != 0 {
+		return nil, errors.New("密文长度错误")
+	}
+	iv, data := raw[:aes.BlockSize], raw[aes.BlockSize:]
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(data) {
+		return nil, errors.New("填充错误")
+	}
+	return data[:len(data)-pad], nil
+}
+
+// feishuEnvelope 事件回调（兼容 url_verification 与 2.0 schema）
+type feishuEnvelope struct {
+	Encrypt   string `json:"encrypt"`
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	Header    struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+		Token     string `json:"token"`
+	} `json:"header"`
+	Event struct {
+		Message struct {
+			MessageID   string `json:"message_id"`
+			MessageType string `json:"message_type"`
+			Content     string `json:"content"`
+		} `json:"message"`
+	} `json:"event"`
+}
+
+// feishuEventHandler POST /api/v1/feishu/events
+func feishuEventHandler(c *gin.Context) {
+	if !feishuBot.enabled() {
+		c.JSON(404, gin.H{"error": "未配置飞书应用"})
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "读取请求失败"})
+		return
+	}
+	var env feishuEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误"})
+		return
+	}
+	if env.Encrypt != "" {
+		plain, err := feishuBot.decrypt(env.Encrypt)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "解密失败: " + err.Error()})
+			return
+		}
+		env = feishuEnvelope{}
+		if err := json.Unmarshal(plain, &env); err != nil {
+			c.JSON(400, gin.H{"error": "请求格式错误"})
+			return
+		}
+	}
+
+	token := env.Token
+	if token == "" {
+		token = env.Header.Token
+	}
+	if feishuBot.verifyToken != "" && token != feishuBot.verifyToken {
+		c.JSON(403, gin.H{"error": "verification token 不匹配"})
+		return
+	}
+	if env.Type == "url_verification" {
+		c.JSON(200, gin.H{"challenge": env.Challenge})
+		return
+	}
+
+	// 飞书要求 3 秒内响应，实际处理放到后台
+	c.JSON(200, gin.H{})
+	if env.Header.EventType != "im.message.receive_v1" {
+		return
+	}
+	if _, dup := feishuBot.seen.Get(env.Header.EventID); dup {
+		return
+	}
+	feishuBot.seen.Set(env.Header.EventID, true)
+	go feishuBot.handleMessage(env.Event.Message.MessageID, env.Event.Message.MessageType, env.Event.Message.Content)
+}
+
+func (a *feishuApp) handleMessage(messageID, messageType, content string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var card map[string]interface{}
+	switch messageType {
+	case "image":
+		var body struct {
+			ImageKey string `json:"image_key"`
+		}
+		json.Unmarshal([]byte(content), &body)
+		card = a.scanImage(ctx, messageID, body.ImageKey)
+	case "text":
+		var body struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal([]byte(content), &body)
+		card = a.command(body.Text)
+	default:
+		return
+	}
+	if card == nil {
+		return
+	}
+	if err := a.reply(ctx, messageID, card); err != nil {
+		log.Printf("飞书回复失败 [%s]: %v", messageID, err)
+	}
+}
+
+// scanImage 下载消息中的图片并走完整扫描流程
+func (a *feishuApp) scanImage(ctx context.Context, messageID, imageKey string) map[string]interface{} {
+	fileBytes, err := a.download(ctx, messageID, imageKey)
+	if err != nil {
+		return feishuCard("图片下载失败", "orange", []string{err.Error()})
+	}
+	origin := ScanOrigin{Tenant: a.tenant, DeviceID: "feishu"}
+	results, job, err := runScan(ctx, origin, fileBytes, os.Getenv("GEMINI_API_KEY"))
+	rr, review := asReviewRequired(err)
+	switch {
+	case review:
+		return feishuCard("大额票据，已转人工复核", "blue", []string{"复核单编号: " + rr.ID})
+	case err != nil:
+		return feishuCard("识别失败", "orange", []string{err.Error()})
+	case job != nil:
+		return feishuCard("识别服务繁忙，已排队", "blue", []string{"任务编号: " + job.ID})
+	}
+	return feishuResultCard(buildSlip(buildCardResponse(results), nil))
+}
+
+// command 斜杠命令：/help、/draw <彩种> <期号>
+func (a *feishuApp) command(text string) map[string]interface{} {
+	// 群聊里 @机器人 的文本带 @_user_1 前缀
+	fields := strings.Fields(text)
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return nil
+	}
+	switch fields[0] {
+	case "/draw":
+		if len(fields) < 3 {
+			return feishuCard("用法", "blue", []string{"/draw 双色球 2025107"})
+		}
+		win, ok := lookupWinningNumbers(context.Background(), fields[1], fields[2])
+		if !ok {
+			return feishuCard(fmt.Sprintf("%s 第%s期", fields[1], fields[2]), "grey", []string{"尚未开奖或暂无数据"})
+		}
+		return feishuCard(fmt.Sprintf("%s 第%s期 开奖号码", fields[1], fields[2]), "red",
+			[]string{strings.Join(win.Red, " ") + " + " + strings.Join(win.Blue, " ")})
+	default:
+		return feishuCard("验奖机器人", "blue", []string{
+			"直接发送彩票照片即可验奖",
+			"/draw <彩种> <期号> 查询开奖号码",
+		})
+	}
+}
+
+func (a *feishuApp) download(ctx context.Context, messageID, imageKey string) ([]byte, error) {
+	token, err := a.tenantToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/im/v1/messages/%s/resources/%s?type=image", feishuAPI, messageID, imageKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败 HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+}
+
+func (a *feishuApp) reply(ctx context.Context, messageID string, card map[string]interface{}) error {
+	token, err := a.tenantToken(ctx)
+	if err != nil {
+		return err
+	}
+	content, _ := json.Marshal(card)
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	err = feishuPost(ctx, fmt.Sprintf("%s/im/v1/messages/%s/reply", feishuAPI, messageID), token,
+		map[string]string{"msg_type": "interactive", "content": string(content)}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("飞书返回错误 %d: %s", result.Code, result.Msg)
+	}
+	return nil
+}
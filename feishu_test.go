@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeishuNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		secret   string
+		code     int
+		template string
+		wantErr  bool
+	}{
+		{"中奖用红色卡片", EventScanWon, "", 0, "red", false},
+		{"同步失败用橙色卡片", EventDrawSyncFailed, "", 0, "orange", false},
+		{"开启签名", EventScanWon, "SEC1", 0, "red", false},
+		{"飞书返回错误码", EventScanWon, "", 19021, "red", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Timestamp string `json:"timestamp"`
+				Sign      string `json:"sign"`
+				Card      struct {
+					Header struct {
+						Template string `json:"template"`
+					} `json:"header"`
+				} `json:"card"`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&body)
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": tt.code, "msg": "sign match fail"})
+			}))
+			defer srv.Close()
+
+			n := &feishuNotifier{hook: FeishuHook{Webhook: srv.URL, Secret: tt.secret}}
+			err := n.Notify(context.Background(), NotifyEvent{Kind: tt.kind, Title: "中奖提醒", Lines: []string{"合计: 10元"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if body.Card.Header.Template != tt.template {
+				t.Errorf("template = %q, want %q", body.Card.Header.Template, tt.template)
+			}
+			if tt.secret == "" {
+				if body.Sign != "" {
+					t.Errorf("unexpected sign %q", body.Sign)
+				}
+				return
+			}
+			mac := hmac.New(sha256.New, []byte(body.Timestamp+"\n"+tt.secret))
+			if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); body.Sign != want {
+				t.Errorf("sign = %q, want %q", body.Sign, want)
+			}
+		})
+	}
+}
+
+// feishuEncrypt 按飞书的方式加密事件体：AES-256-CBC，PKCS7 填充，IV 放在密文前
+func feishuEncrypt(t *testing.T, key, plain string) string {
+	t.Helper()
+	k := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, aes.BlockSize+len(data))
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], data)
+	return base64.StdEncoding.EncodeToString(out)
+}
+
+func TestFeishuDecrypt(t *testing.T) {
+	a := &feishuApp{encryptKey: "test-key"}
+	tests := []struct {
+		name      string
+		encrypted string
+		want      string
+		wantErr   bool
+	}{
+		{"正常解密", feishuEncrypt(t, "test-key", `{"challenge":"abc"}`), `{"challenge":"abc"}`, false},
+		{"密钥不对", feishuEncrypt(t, "other-key", `{"challenge":"abc"}`), "", true},
+		{"不是 base64", "%%%", "", true},
+		{"长度不是块大小整数倍", base64.StdEncoding.EncodeToString(make([]byte, 20)), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.decrypt(tt.encrypted)
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatal(err)
+				}
+				return
+			}
+			if tt.wantErr && string(got) == `{"challenge":"abc"}` {
+				t.Fatal("decrypt succeeded with wrong input")
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeishuCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		reply  bool
+		header string
+	}{
+		{"普通聊天不回复", "你好", false, ""},
+		{"只 @ 机器人不回复", "@_user_1", false, ""},
+		{"帮助", "@_user_1 /help", true, "验奖机器人"},
+		{"参数不够提示用法", "/draw 双色球", true, "用法"},
+	}
+	a := &feishuApp{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := a.command(tt.text)
+			if (card != nil) != tt.reply {
+				t.Fatalf("reply = %v, want %v", card != nil, tt.reply)
+			}
+			if card == nil {
+				return
+			}
+			title := card["header"].(map[string]interface{})["title"].(map[string]string)["content"]
+			if title != tt.header {
+				t.Errorf("title = %q, want %q", title, tt.header)
+			}
+		})
+	}
+}
+
+func TestFeishuEventHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := feishuBot
+	t.Cleanup(func() { feishuBot = old })
+
+	tests := []struct {
+		name       string
+		app        *feishuApp
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"未配置", &feishuApp{}, `{}`, 404, ""},
+		{"URL 校验回显 challenge", &feishuApp{appID: "a", appSecret: "s", verifyToken: "tok"},
+			`{"type":"url_verification","challenge":"abc","token":"tok"}`, 200, `{"challenge":"abc"}`},
+		{"token 不匹配", &feishuApp{appID: "a", appSecret: "s", verifyToken: "tok"},
+			`{"type":"url_verification","challenge":"abc","token":"bad"}`, 403, ""},
+		{"加密事件", &feishuApp{appID: "a", appSecret: "s", encryptKey: "k"},
+			`{"encrypt":"` + feishuEncrypt(t, "k", `{"type":"url_verification","challenge":"xyz"}`) + `"}`, 200, `{"challenge":"xyz"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feishuBot = tt.app
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/feishu/events", bytes.NewBufferString(tt.body))
+			feishuEventHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
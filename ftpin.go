@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// FTPIN: 内置 FTP 收图服务（零售终端上传）
+// ==========================================
+
+// 部分 POS / 扫描终端只能把图片丢到 FTP 服务器上。这里内置一个只收不发的极简 FTP 服务：
+// 每台终端一个账号，上传的图片直接进入排队任务，结果按终端编号归属（ScanOrigin.DeviceID），
+// 通过租户通知渠道或 GET /api/v1/jobs/:id 获取。只支持被动模式
+
+// FTPTerminal 一台终端的登录账号，配置在 data/ftp_terminals.json
+type FTPTerminal struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+	Tenant   string `json:"tenant"`
+}
+
+type ftpServer struct {
+	terminals map[string]FTPTerminal
+	publicIP  net.IP // PASV 回复里的地址，NAT 后面部署时需要指定 FTP_PUBLIC_IP
+	portMin   int
+	portMax   int
+	maxBytes  int64
+}
+
+// loadFTPTerminals 读取终端账号列表
+func loadFTPTerminals(p string) (map[string]FTPTerminal, error) {
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var list []FTPTerminal
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", p, err)
+	}
+	out := map[string]FTPTerminal{}
+	for _, t := range list {
+		if t.ID == "" || t.Password == "" {
+			continue
+		}
+		if t.Tenant == "" {
+			t.Tenant = "default"
+		}
+		out[t.ID] = t
+	}
+	return out, nil
+}
+
+// runFTPServer FTP_ADDR 开启（如 :2121）；被动端口范围 FTP_PASV_PORTS，默认 30000-30009
+func runFTPServer(addr, terminalsPath string) {
+	terminals, err := loadFTPTerminals(terminalsPath)
+	if err != nil {
+		log.Printf("FTP 收图未启动: 加载终端账号失败: %v", err)
+		return
+	}
+	s := &ftpServer{terminals: terminals, portMin: 30000, portMax: 30009, maxBytes: int64(envInt("FTP_MAX_MB", 20)) << 20}
+	if r := strings.TrimSpace(os.Getenv("FTP_PASV_PORTS")); r != "" {
+		lo, hi, _ := strings.Cut(r, "-")
+		s.portMin, _ = strconv.Atoi(lo)
+		s.portMax, _ = strconv.Atoi(hi)
+		if s.portMax < s.portMin {
+			s.portMax = s.portMin
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(os.Getenv("FTP_PUBLIC_IP"))); ip != nil {
+		s.publicIP = ip.To4()
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("FTP 收图未启动: %v", err)
+		return
+	}
+	log.Printf("FTP 收图服务监听 %s，终端 %d 台", addr, len(terminals))
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("FTP 接受连接失败: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go s.serve(conn)
+	}
+}
+
+// ftpSession 一条控制连接的状态
+type ftpSession struct {
+	s        *ftpServer
+	conn     net.Conn
+	r        *bufio.Reader
+	user     string
+	terminal *FTPTerminal
+	pasv     net.Listener
+	cwd      string
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+	sess := &ftpSession{s: s, conn: conn, r: bufio.NewReader(conn), cwd: "/"}
+	defer func() {
+		sess.closePasv()
+		conn.Close()
+	}()
+	sess.reply(220, "lottery-server 收图服务")
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := sess.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		if !sess.handle(strings.ToUpper(cmd), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (sess *ftpSession) reply(code int, msg string) {
+	fmt.Fprintf(sess.conn, "%d %s\r\n", code, msg)
+}
+
+// handle 处理一条命令，返回 false 时关闭连接
+func (sess *ftpSession) handle(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		sess.user, sess.terminal = arg, nil
+		sess.reply(331, "请输入密码")
+		return true
+	case "PASS":
+		t, ok := sess.s.terminals[sess.user]
+		if !ok || subtle.ConstantTimeCompare([]byte(t.Password), []byte(arg)) != 1 {
+			time.Sleep(time.Second) // 拖慢暴力猜测
+			sess.reply(530, "账号或密码错误")
+			return true
+		}
+		sess.terminal = &t
+		sess.reply(230, "登录成功")
+		return true
+	case "QUIT":
+		sess.reply(221, "再见")
+		return false
+	case "NOOP":
+		sess.reply(200, "OK")
+		return true
+	case "FEAT":
+		sess.reply(211, "无扩展功能")
+		return true
+	case "SYST":
+		sess.reply(215, "UNIX Type: L8")
+		return true
+	case "OPTS", "TYPE", "MODE", "STRU":
+		sess.reply(200, "OK")
+		return true
+	}
+
+	if sess.terminal == nil {
+		sess.reply(530, "请先登录")
+		return true
+	}
+	switch cmd {
+	case "PWD", "XPWD":
+		sess.reply(257, strconv.Quote(sess.cwd))
+	case "CWD", "XCWD":
+		// 终端常在上传前切到固定目录；目录只是逻辑上的，全部接受
+		sess.cwd = path.Join(sess.cwd, arg)
+		sess.reply(250, "OK")
+	case "CDUP":
+		sess.cwd = path.Dir(sess.cwd)
+		sess.reply(250, "OK")
+	case "MKD", "XMKD":
+		sess.reply(257, strconv.Quote(path.Join(sess.cwd, arg)))
+	case "PASV":
+		sess.passive(false)
+	case "EPSV":
+		sess.passive(true)
+	case "LIST", "NLST":
+		// 不保留文件，目录永远为空
+		data, err := sess.acceptData()
+		if err != nil {
+			sess.reply(425, "无法建立数据连接")
+			return true
+		}
+		sess.reply(150, "目录列表")
+		data.Close()
+		sess.reply(226, "完成")
+	case "STOR":
+		sess.store(arg)
+	case "SIZE", "RETR", "DELE", "RNFR", "RNTO":
+		sess.reply(550, "不支持")
+	default:
+		sess.reply(502, "命令未实现")
+	}
+	return true
+}
+
+// passive 打开一个被动模式数据端口
+func (sess *ftpSession) passive(extended bool) {
+	sess.closePasv()
+	host, _, _ := net.SplitHostPort(sess.conn.LocalAddr().String())
+	for port := sess.s.portMin; port <= sess.s.portMax; port++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		sess.pasv = ln
+		if extended {
+			sess.reply(229, fmt.Sprintf("进入扩展被动模式 (|||%d|)", port))
+			return
+		}
+		ip := sess.s.publicIP
+		if ip == nil {
+			ip = net.ParseIP(host).To4()
+		}
+		if ip == nil {
+			ln.Close()
+			sess.pasv = nil
+			sess.reply(425, "IPv6 连接请使用 EPSV")
+			return
+		}
+		sess.reply(227, fmt.Sprintf("进入被动模式 (%d,%d,%d,%d,%d,%d)", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff))
+		return
+	}
+	sess.reply(425, "没有可用的被动端口")
+}
+
+func (sess *ftpSession) closePasv() {
+	if sess.pasv != nil {
+		sess.pasv.Close()
+		sess.pasv = nil
+	}
+}
+
+// acceptData 等待客户端连上被动端口；只接受来自控制连接同一 IP 的连接
+func (sess *ftpSession) acceptData() (net.Conn, error) {
+	if sess.pasv == nil {
+		return nil, fmt.Errorf("未进入被动模式")
+	}
+	defer sess.closePasv()
+	if tl, ok := sess.pasv.(*net.TCPListener); ok {
+		tl.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	data, err := sess.pasv.Accept()
+	if err != nil {
+		return nil, err
+	}
+	ctrlHost, _, _ := net.SplitHostPort(sess.conn.RemoteAddr().String())
+	dataHost, _, _ := net.SplitHostPort(data.RemoteAddr().String())
+	if ctrlHost != dataHost {
+		data.Close()
+		return nil, fmt.Errorf("数据连接来源不一致")
+	}
+	return data, nil
+}
+
+// store 接收一张图片并放入排队任务
+func (sess *ftpSession) store(name string) {
+	if !zipImageExts[strings.ToLower(path.Ext(name))] {
+		sess.reply(553, "只接收图片文件")
+		return
+	}
+	data, err := sess.acceptData()
+	if err != nil {
+		sess.reply(425, "无法建立数据连接")
+		return
+	}
+	sess.reply(150, "开始接收")
+	data.SetReadDeadline(time.Now().Add(5 * time.Minute))
+	fileBytes, err := io.ReadAll(io.LimitReader(data, sess.s.maxBytes+1))
+	data.Close()
+	switch {
+	case err != nil:
+		sess.reply(426, "传输中断")
+		return
+	case int64(len(fileBytes)) > sess.s.maxBytes:
+		sess.reply(552, "文件过大")
+		return
+	case len(fileBytes) == 0:
+		sess.reply(550, "空文件")
+		return
+	}
+	origin := ScanOrigin{Tenant: sess.terminal.Tenant, DeviceID: sess.terminal.ID}
+	job, err := jobQueue.Enqueue(fileBytes, origin)
+	if err != nil {
+		sess.reply(451, "排队失败")
+		log.Printf("FTP 收图排队失败 [%s]: %v", sess.terminal.ID, err)
+		return
+	}
+	log.Printf("FTP 收图: 终端 %s 上传 %s，任务 %s", sess.terminal.ID, path.Base(name), job.ID)
+	sess.reply(226, "已接收，任务编号 "+job.ID)
+}
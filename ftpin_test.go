@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadFTPTerminals(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "ftp_terminals.json", []byte(`[
+{"id": "pos-1", "password": "p1", "tenant": "shop-a"},
+{"id": "pos-2", "password": "p2"},
+{"id": "pos-3"},
+{"password": "p4"}
+]`))
+	got, err := loadFTPTerminals(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id     string
+		ok     bool
+		tenant string
+	}{
+		{"pos-1", true, "shop-a"},
+		{"pos-2", true, "default"},
+		{"pos-3", false, ""},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		term, ok := got[tt.id]
+		if ok != tt.ok || term.Tenant != tt.tenant {
+			t.Errorf("terminals[%q] = %+v, %v, want tenant %q, %v", tt.id, term, ok, tt.tenant, tt.ok)
+		}
+	}
+
+	bad := writeTestFile(t, dir, "bad.json", []byte(`{`))
+	if _, err := loadFTPTerminals(bad); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+// freePort 找一个当前空闲的本地端口作为被动端口
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestFTPSession(t *testing.T) {
+	useTestQueue(t)
+	port := freePort(t)
+	s := &ftpServer{
+		terminals: map[string]FTPTerminal{"pos-1": {ID: "pos-1", Password: "secret", Tenant: "shop-a"}},
+		portMin:   port, portMax: port, maxBytes: 8,
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	dial := func(t *testing.T) *textproto.Conn {
+		c, err := textproto.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if _, _, err := c.ReadResponse(220); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	cmd := func(c *textproto.Conn, format string, args ...interface{}) (int, string) {
+		c.PrintfLine(format, args...)
+		code, msg, _ := c.ReadResponse(0)
+		return code, msg
+	}
+	login := func(t *testing.T) *textproto.Conn {
+		c := dial(t)
+		cmd(c, "USER pos-1")
+		if code, msg := cmd(c, "PASS secret"); code != 230 {
+			t.Fatalf("PASS = %d %s", code, msg)
+		}
+		return c
+	}
+	// upload 走 EPSV 上传一个文件，返回最终回复
+	upload := func(t *testing.T, c *textproto.Conn, name string, data []byte) (int, string) {
+		code, msg := cmd(c, "EPSV")
+		if code != 229 {
+			t.Fatalf("EPSV = %d %s", code, msg)
+		}
+		dataConn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, msg := cmd(c, "STOR %s", name); code != 150 {
+			dataConn.Close()
+			return code, msg
+		}
+		dataConn.Write(data)
+		dataConn.Close()
+		code, msg, _ = c.ReadResponse(0)
+		return code, msg
+	}
+
+	t.Run("登录", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			user     string
+			pass     string
+			wantCode int
+		}{
+			{"密码正确", "pos-1", "secret", 230},
+			{"密码错误", "pos-1", "wrong", 530},
+			{"没有这台终端", "pos-9", "secret", 530},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c := dial(t)
+				if code, _ := cmd(c, "USER %s", tt.user); code != 331 {
+					t.Fatalf("USER = %d", code)
+				}
+				if code, msg := cmd(c, "PASS %s", tt.pass); code != tt.wantCode {
+					t.Errorf("PASS = %d %s, want %d", code, msg, tt.wantCode)
+				}
+			})
+		}
+	})
+
+	t.Run("命令", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			login    bool
+			line     string
+			wantCode int
+			wantMsg  string
+		}{
+			{"未登录不能上传", false, "STOR a.jpg", 530, ""},
+			{"未登录可以 NOOP", false, "NOOP", 200, ""},
+			{"当前目录", true, "PWD", 257, `"/"`},
+			{"不支持下载", true, "RETR a.jpg", 550, ""},
+			{"未知命令", true, "SITE CHMOD", 502, ""},
+			{"只收图片", true, "STOR notes.txt", 553, ""},
+			{"没进被动模式", true, "STOR a.jpg", 425, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var c *textproto.Conn
+				if tt.login {
+					c = login(t)
+				} else {
+					c = dial(t)
+				}
+				code, msg := cmd(c, "%s", tt.line)
+				if code != tt.wantCode || !strings.Contains(msg, tt.wantMsg) {
+					t.Errorf("%s = %d %s, want %d %s", tt.line, code, msg, tt.wantCode, tt.wantMsg)
+				}
+			})
+		}
+	})
+
+	t.Run("切换目录", func(t *testing.T) {
+		c := login(t)
+		cmd(c, "CWD upload/today")
+		cmd(c, "CDUP")
+		if _, msg := cmd(c, "PWD"); msg != `"/upload"` {
+			t.Errorf("PWD = %s, want \"/upload\"", msg)
+		}
+	})
+
+	t.Run("上传", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			file     string
+			data     []byte
+			wantCode int
+		}{
+			{"图片进入排队", "a.jpg", []byte{1, 2, 3}, 226},
+			{"空文件", "b.jpg", nil, 550},
+			{"超过大小上限", "c.jpg", []byte("123456789"), 552},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c := login(t)
+				code, msg := upload(t, c, tt.file, tt.data)
+				if code != tt.wantCode {
+					t.Fatalf("STOR = %d %s, want %d", code, msg, tt.wantCode)
+				}
+				if code != 226 {
+					return
+				}
+				id := msg[strings.LastIndex(msg, " ")+1:]
+				job, ok := jobQueue.Get(id)
+				if !ok || job.Tenant != "shop-a" || job.DeviceID != "pos-1" {
+					t.Errorf("job %s = %+v, %v", id, job, ok)
+				}
+			})
+		}
+	})
+}
+
+func TestFTPPassiveReply(t *testing.T) {
+	port := freePort(t)
+	tests := []struct {
+		name     string
+		publicIP net.IP
+		extended bool
+		want     string
+	}{
+		{"EPSV 只给端口", nil, true, fmt.Sprintf("229 进入扩展被动模式 (|||%d|)", port)},
+		{"PASV 用本机地址", nil, false, fmt.Sprintf("227 进入被动模式 (127,0,0,1,%d,%d)", port>>8, port&0xff)},
+		{"PASV 用公网地址", net.ParseIP("203.0.113.5").To4(), false, fmt.Sprintf("227 进入被动模式 (203,0,113,5,%d,%d)", port>>8, port&0xff)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			sess := &ftpSession{s: &ftpServer{publicIP: tt.publicIP, portMin: port, portMax: port}, conn: conn}
+			defer sess.closePasv()
+			sess.passive(tt.extended)
+			line, _ := bufio.NewReader(client).ReadString('\n')
+			if got := strings.TrimRight(line, "\r\n"); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if sess.pasv == nil {
+				t.Error("passive listener not opened")
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ==========================================
+// GAMEDSL: 声明式彩种定义（data/games/*.yaml）
+// ==========================================
+
+// 号码规则简单的新彩种不必写 Go 代码：在 data/games 下放一个 YAML 文件，启动时生成通用验奖器，
+// 并注册到 gameSpecs，统计、选号、概率等接口同样可用。示例：
+//
+//	name: 七乐彩
+//	aliases: [qlc]
+//	zones:
+//	  - {field: red, label: 基本号, min: 1, max: 30, pick: 7, width: 2}
+//	prizes:
+//	  - {level: 4, prize: 200, match: [{red: 6}]}
+//	  - {level: 5, prize: 50, match: [{red: 5}]}
+//
+// match 中每一项是一组条件（各区命中个数），满足任一组即中该奖级；未列出的区不限。
+// 奖级按 level 从小到大依次判断，取第一个满足的。ordered 为 true 时按位比较，命中数为位置相同的个数
+
+// GameDefinition 一个 YAML 彩种定义
+type GameDefinition struct {
+	Name    string   `yaml:"name"`
+	Code    string   `yaml:"code"` // 对外代码，如 qxc；en 模式下代替 name 输出，也可在请求中代替名称使用
+	Aliases []string `yaml:"aliases"`
+	Ordered bool     `yaml:"ordered"`
+	// MaxMultiplier 单行倍数上限，0 表示使用 MULTIPLIER_MAX
+	MaxMultiplier int              `yaml:"max_multiplier"`
+	Zones         []GameZoneDef    `yaml:"zones"`
+	Prizes        []GamePrizeLevel `yaml:"prizes"`
+}
+
+// GameZoneDef 号码区；field 为 red 或 blue，对应票面与开奖数据中的 red / blue
+type GameZoneDef struct {
+	Field string `yaml:"field"`
+	Label string `yaml:"label"`
+	Min   int    `yaml:"min"`
+	Max   int    `yaml:"max"`
+	Pick  int    `yaml:"pick"`
+	Width int    `yaml:"width"`
+}
+
+// GamePrizeLevel 一个奖级，prize 为每注固定奖金（元）
+type GamePrizeLevel struct {
+	Level int              `yaml:"level"`
+	Prize int64            `yaml:"prize"`
+	Match []map[string]int `yaml:"match"`
+}
+
+// dslVerifiers 按标准彩种名称索引；只在启动时写入
+var dslVerifiers = map[string]*DSLVerifier{}
+
+// loadGameDefinitions 加载目录下全部 .yaml/.yml 定义，目录不存在时跳过；已有彩种不允许覆盖
+func loadGameDefinitions(dir string) error {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.y*ml"))
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var def GameDefinition
+		if err := yaml.Unmarshal(raw, &def); err != nil {
+			return fmt.Errorf("解析 %s 失败: %v", f, err)
+		}
+		v, err := newDSLVerifier(def)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(f), err)
+		}
+		name := v.def.Name
+		if _, exists := gameSpecs[name]; exists {
+			return fmt.Errorf("%s: 彩种 %s 已存在，不能重复定义", filepath.Base(f), name)
+		}
+		dslVerifiers[name] = v
+		gameSpecs[name] = v.spec
+		for _, a := range def.Aliases {
+			gameAliases[strings.ToLower(a)] = name
+		}
+		if code := strings.ToLower(strings.TrimSpace(def.Code)); code != "" {
+			gameAliases[code], gameCodes[name] = name, code
+		}
+		log.Printf("已加载彩种定义 %s（%d 个奖级）", name, len(def.Prizes))
+	}
+	return nil
+}
+
+// newDSLVerifier 校验定义并生成验奖器
+func newDSLVerifier(def GameDefinition) (*DSLVerifier, error) {
+	def.Name = strings.TrimSpace(def.Name)
+	if def.Name == "" {
+		return nil, fmt.Errorf("缺少 name")
+	}
+	if len(def.Zones) == 0 || len(def.Prizes) == 0 {
+		return nil, fmt.Errorf("彩种 %s 缺少 zones 或 prizes", def.Name)
+	}
+	spec := gameSpec{Name: def.Name, Ordered: def.Ordered}
+	seen := map[string]bool{}
+	for _, z := range def.Zones {
+		if z.Field != "red" && z.Field != "blue" {
+			return nil, fmt.Errorf("彩种 %s 号码区 field 只能是 red 或 blue", def.Name)
+		}
+		if seen[z.Field] {
+			return nil, fmt.Errorf("彩种 %s 号码区 %s 重复", def.Name, z.Field)
+		}
+		seen[z.Field] = true
+		if z.Pick <= 0 || z.Max < z.Min || z.Max-z.Min+1 < z.Pick && !def.Ordered {
+			return nil, fmt.Errorf("彩种 %s 号码区 %s 的 min/max/pick 不合理", def.Name, z.Field)
+		}
+		if z.Width <= 0 {
+			z.Width = len(fmt.Sprint(z.Max))
+		}
+		if def.Ordered {
+			// 排列类按位展开，与内置排列5 一致
+			for i := 0; i < z.Pick; i++ {
+				spec.Zones = append(spec.Zones, gameZone{
+					Name: fmt.Sprintf("pos%d", i+1), Label: fmt.Sprintf("第%d位", i+1), Field: z.Field, Index: i,
+					Min: z.Min, Max: z.Max, Pick: 1, Width: z.Width,
+				})
+			}
+			continue
+		}
+		spec.Zones = append(spec.Zones, gameZone{
+			Name: z.Field, Label: z.Label, Field: z.Field, Index: -1, Min: z.Min, Max: z.Max, Pick: z.Pick, Width: z.Width,
+		})
+	}
+	for _, p := range def.Prizes {
+		if p.Level <= 0 || len(p.Match) == 0 {
+			return nil, fmt.Errorf("彩种 %s 奖级定义缺少 level 或 match", def.Name)
+		}
+		for _, cond := range p.Match {
+			for field := range cond {
+				if !seen[field] {
+					return nil, fmt.Errorf("彩种 %s 第%d奖级条件引用了不存在的号码区 %s", def.Name, p.Level, field)
+				}
+			}
+		}
+	}
+	return &DSLVerifier{def: def, spec: spec}, nil
+}
+
+// DSLVerifier 由 YAML 定义生成的通用验奖器，无序彩种支持复式与胆拖
+type DSLVerifier struct {
+	def  GameDefinition
+	spec gameSpec
+}
+
+func (v *DSLVerifier) Verify(t UserTicket, win WinningNumbers) (int, Fen, string) {
+	bestLevel, totalMoney := 0, Fen(0)
+	promoNames := ""
+	for _, bet := range v.bets(t) {
+		hits := map[string]int{}
+		for _, z := range v.def.Zones {
+			mine, drawn := bet.Red, win.Red
+			if z.Field == "blue" {
+				mine, drawn = bet.Blue, win.Blue
+			}
+			if v.def.Ordered {
+				for i := 0; i < len(mine) && i < len(drawn); i++ {
+					if mine[i] == drawn[i] {
+						hits[z.Field]++
+					}
+				}
+			} else {
+				hits[z.Field] = intersect(mine, drawn)
+			}
+		}
+		level, money := v.prizeOf(hits)
+		if money == 0 {
+			continue
+		}
+		var promo string
+		if money, promo = win.promoPrize(bet, level, money); promo != "" {
+			promoNames = promo
+		}
+		totalMoney += money
+		if bestLevel == 0 || level < bestLevel {
+			bestLevel = level
+		}
+	}
+	status := "未中奖"
+	if totalMoney > 0 {
+		status = "中奖: " + totalMoney.String() + promoSuffix(promoNames)
+	}
+	return bestLevel, totalMoney, status
+}
+
+// bets 把一行展开为单式；排列类不展开，号码个数不对时不中奖
+func (v *DSLVerifier) bets(t UserTicket) []UserTicket {
+	if v.def.Ordered {
+		for _, z := range v.def.Zones {
+			nums := t.Red
+			if z.Field == "blue" {
+				nums = t.Blue
+			}
+			if len(nums) != z.Pick {
+				return nil
+			}
+		}
+		return []UserTicket{t}
+	}
+	out := []UserTicket{{}}
+	for _, z := range v.def.Zones {
+		dan, tuo := t.Dan, t.Red
+		if z.Field == "blue" {
+			dan, tuo = t.BlueDan, t.Blue
+		}
+		var next []UserTicket
+		for _, comb := range zoneCombinations(dan, tuo, z.Pick) {
+			for _, b := range out {
+				if z.Field == "blue" {
+					b.Blue = comb
+				} else {
+					b.Red = comb
+				}
+				next = append(next, b)
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+// prizeOf 依次匹配奖级条件
+func (v *DSLVerifier) prizeOf(hits map[string]int) (int, Fen) {
+	best, money := 0, Fen(0)
+	for _, p := range v.def.Prizes {
+		if best != 0 && p.Level >= best {
+			continue
+		}
+		for _, cond := range p.Match {
+			ok := true
+			for field, want := range cond {
+				if hits[field] != want {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				best, money = p.Level, Fen(p.Prize)*Yuan
+				break
+			}
+		}
+	}
+	return best, money
+}
+
+// dslGameOf OCR 识别出的彩种名称包含某个自定义彩种名称时归为该彩种
+func dslGameOf(lotteryType string) (string, bool) {
+	for name := range dslVerifiers {
+		if strings.Contains(lotteryType, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewDSLVerifier(t *testing.T) {
+	red := GameZoneDef{Field: "red", Min: 1, Max: 20, Pick: 5}
+	prize := GamePrizeLevel{Level: 1, Prize: 100, Match: []map[string]int{{"red": 5}}}
+	tests := []struct {
+		name    string
+		def     GameDefinition
+		wantErr string
+	}{
+		{"合法定义", GameDefinition{Name: " 测试彩 ", Zones: []GameZoneDef{red}, Prizes: []GamePrizeLevel{prize}}, ""},
+		{"缺少名称", GameDefinition{Zones: []GameZoneDef{red}, Prizes: []GamePrizeLevel{prize}}, "缺少 name"},
+		{"缺少号码区", GameDefinition{Name: "测试彩", Prizes: []GamePrizeLevel{prize}}, "缺少 zones 或 prizes"},
+		{"缺少奖级", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{red}}, "缺少 zones 或 prizes"},
+		{"号码区名称不对", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{{Field: "green", Min: 1, Max: 9, Pick: 1}}, Prizes: []GamePrizeLevel{prize}}, "只能是 red 或 blue"},
+		{"号码区重复", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{red, red}, Prizes: []GamePrizeLevel{prize}}, "重复"},
+		{"选号个数超过号码范围", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{{Field: "red", Min: 1, Max: 3, Pick: 5}}, Prizes: []GamePrizeLevel{prize}}, "不合理"},
+		{"按位玩法号码可以重复", GameDefinition{Name: "测试彩", Ordered: true, Zones: []GameZoneDef{{Field: "red", Min: 0, Max: 1, Pick: 5}}, Prizes: []GamePrizeLevel{prize}}, ""},
+		{"max 小于 min", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{{Field: "red", Min: 9, Max: 1, Pick: 1}}, Prizes: []GamePrizeLevel{prize}}, "不合理"},
+		{"奖级缺少 level", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{red}, Prizes: []GamePrizeLevel{{Prize: 100, Match: prize.Match}}}, "缺少 level 或 match"},
+		{"奖级缺少 match", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{red}, Prizes: []GamePrizeLevel{{Level: 1, Prize: 100}}}, "缺少 level 或 match"},
+		{"条件引用不存在的号码区", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{red}, Prizes: []GamePrizeLevel{{Level: 1, Prize: 100, Match: []map[string]int{{"blue": 1}}}}}, "不存在的号码区 blue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newDSLVerifier(tt.def)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.spec.Name != "测试彩" {
+				t.Errorf("spec.Name = %q, want trimmed name", v.spec.Name)
+			}
+		})
+	}
+}
+
+func TestDSLVerifierSpec(t *testing.T) {
+	tests := []struct {
+		name      string
+		def       GameDefinition
+		wantZones []string
+		wantWidth int
+	}{
+		{"无序一区一个号码区，宽度按 max 推算", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{{Field: "red", Min: 1, Max: 30, Pick: 7}}}, []string{"red"}, 2},
+		{"两区", GameDefinition{Name: "测试彩", Zones: []GameZoneDef{{Field: "red", Min: 1, Max: 20, Pick: 5, Width: 2}, {Field: "blue", Min: 1, Max: 5, Pick: 1, Width: 2}}}, []string{"red", "blue"}, 2},
+		{"按位玩法逐位展开", GameDefinition{Name: "测试彩", Ordered: true, Zones: []GameZoneDef{{Field: "red", Min: 0, Max: 9, Pick: 3}}}, []string{"pos1", "pos2", "pos3"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.def.Prizes = []GamePrizeLevel{{Level: 1, Prize: 1, Match: []map[string]int{{"red": 1}}}}
+			v, err := newDSLVerifier(tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, z := range v.spec.Zones {
+				names = append(names, z.Name)
+				if z.Width != tt.wantWidth {
+					t.Errorf("zone %s width = %d, want %d", z.Name, z.Width, tt.wantWidth)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantZones, ",") {
+				t.Errorf("zones = %v, want %v", names, tt.wantZones)
+			}
+		})
+	}
+}
+
+func TestDSLVerifierVerify(t *testing.T) {
+	// 两区无序：5+1 一等奖，5+0 或 4+1 二等奖，4+0 或 3+1 三等奖
+	twoZone, err := newDSLVerifier(GameDefinition{
+		Name: "测试彩",
+		Zones: []GameZoneDef{
+			{Field: "red", Min: 1, Max: 20, Pick: 5, Width: 2},
+			{Field: "blue", Min: 1, Max: 5, Pick: 1, Width: 2},
+		},
+		Prizes: []GamePrizeLevel{
+			{Level: 3, Prize: 10, Match: []map[string]int{{"red": 4, "blue": 0}, {"red": 3, "blue": 1}}},
+			{Level: 1, Prize: 1000, Match: []map[string]int{{"red": 5, "blue": 1}}},
+			{Level: 2, Prize: 100, Match: []map[string]int{{"red": 5}, {"red": 4, "blue": 1}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered, err := newDSLVerifier(GameDefinition{
+		Name: "测试3D", Ordered: true,
+		Zones:  []GameZoneDef{{Field: "red", Min: 0, Max: 9, Pick: 3}},
+		Prizes: []GamePrizeLevel{{Level: 1, Prize: 1040, Match: []map[string]int{{"red": 3}}}, {Level: 2, Prize: 10, Match: []map[string]int{{"red": 2}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoZoneWin := WinningNumbers{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"01"}}
+	orderedWin := WinningNumbers{Red: []string{"1", "2", "3"}}
+
+	tests := []struct {
+		name   string
+		v      *DSLVerifier
+		win    WinningNumbers
+		ticket UserTicket
+		level  int
+		money  Fen
+	}{
+		{"5+1 一等奖", twoZone, twoZoneWin, UserTicket{Red: []string{"05", "04", "03", "02", "01"}, Blue: []string{"01"}}, 1, 1000 * Yuan},
+		{"5+0 二等奖（未列出的区不限）", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"02"}}, 2, 100 * Yuan},
+		{"4+1 二等奖", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "04", "09"}, Blue: []string{"01"}}, 2, 100 * Yuan},
+		{"4+0 三等奖", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "04", "09"}, Blue: []string{"02"}}, 3, 10 * Yuan},
+		{"3+0 未中奖", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "08", "09"}, Blue: []string{"02"}}, 0, 0},
+		{"红区复式 6+1", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"01"}}, 1, 1000*Yuan + 5*100*Yuan},
+		{"蓝区复式 5+2", twoZone, twoZoneWin, UserTicket{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"01", "02"}}, 1, 1100 * Yuan},
+		{"胆拖 2 胆 4 拖", twoZone, twoZoneWin, UserTicket{Dan: []string{"01", "02"}, Red: []string{"03", "04", "06", "07"}, Blue: []string{"01"}}, 2, 2*100*Yuan + 2*10*Yuan},
+		{"按位全中", ordered, orderedWin, UserTicket{Red: []string{"1", "2", "3"}}, 1, 1040 * Yuan},
+		{"按位中两位", ordered, orderedWin, UserTicket{Red: []string{"1", "2", "4"}}, 2, 10 * Yuan},
+		{"号码相同顺序不同", ordered, orderedWin, UserTicket{Red: []string{"3", "2", "1"}}, 0, 0},
+		{"位数不够", ordered, orderedWin, UserTicket{Red: []string{"1", "2"}}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, money, status := tt.v.Verify(tt.ticket, tt.win)
+			if level != tt.level || money != tt.money {
+				t.Errorf("Verify() = %d/%s, want %d/%s", level, money, tt.level, tt.money)
+			}
+			if (money > 0) != strings.HasPrefix(status, "中奖") {
+				t.Errorf("status = %q", status)
+			}
+		})
+	}
+}
+
+func TestLoadGameDefinitions(t *testing.T) {
+	loadTestGames(t)
+	t.Run("注册后可按名称、代码、别名使用", func(t *testing.T) {
+		tests := []struct {
+			in   string
+			want string
+		}{
+			{"七乐彩", "七乐彩"},
+			{"中国福利彩票七乐彩", "七乐彩"},
+			{"排列3", "排列3"},
+		}
+		for _, tt := range tests {
+			if got := canonicalGame(tt.in); got != tt.want {
+				t.Errorf("canonicalGame(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if _, ok := selectVerifier(tt.in).(*DSLVerifier); !ok {
+				t.Errorf("selectVerifier(%q) is not a DSL verifier", tt.in)
+			}
+			if _, ok := specOf(tt.in); !ok {
+				t.Errorf("specOf(%q) missing", tt.in)
+			}
+		}
+		for alias, want := range map[string]string{"qlc": "七乐彩", "p3": "排列3", "pl3": "排列3"} {
+			if got := gameAliases[alias]; got != want {
+				t.Errorf("alias %s = %q, want %q", alias, got, want)
+			}
+		}
+		if gameCodes["排列3"] != "pl3" {
+			t.Errorf("code of 排列3 = %q, want pl3", gameCodes["排列3"])
+		}
+	})
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"目录为空", nil, ""},
+		{"YAML 格式错误", map[string]string{"bad.yaml": "name: [x"}, "解析"},
+		{"定义不合法", map[string]string{"bad.yml": "name: 测试彩\n"}, "bad.yml"},
+		{"不能覆盖内置彩种", map[string]string{"ssq.yaml": "name: 双色球\nzones:\n  - {field: red, min: 1, max: 33, pick: 6}\nprizes:\n  - {level: 1, prize: 5, match: [{red: 6}]}\n"}, "已存在"},
+		{"不能重复定义", map[string]string{"qlc.yaml": "name: 七乐彩\nzones:\n  - {field: red, min: 1, max: 30, pick: 7}\nprizes:\n  - {level: 1, prize: 5, match: [{red: 7}]}\n"}, "已存在"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				writeTestFile(t, dir, name, []byte(data))
+			}
+			err := loadGameDefinitions(dir)
+			if (tt.wantErr == "") != (err == nil) || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadGameDefinitions() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if err := loadGameDefinitions(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("missing dir: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// GAMES: 彩种号码规则
+// ==========================================
+
+// gameZone 一个号码区，如双色球红球 01-33 选 6 个
+type gameZone struct {
+	Name  string // red / blue，排列类为 pos1..pos5
+	Label string // 红球 / 前区 / 第1位
+	Field string // 取 DrawRecord 的 Red 还是 Blue
+	Index int    // 排列类按位取号，-1 表示整区
+	Min   int
+	Max   int
+	Pick  int
+	Width int // 号码位数，双色球 "07" 为 2，排列 "7" 为 1
+}
+
+// gameSpec 彩种号码规则
+type gameSpec struct {
+	Name    string
+	Ordered bool // 排列类：按位置比较
+	Zones   []gameZone
+}
+
+var gameSpecs = map[string]gameSpec{
+	"双色球": {Name: "双色球", Zones: []gameZone{
+		{Name: "red", Label: "红球", Field: "red", Index: -1, Min: 1, Max: 33, Pick: 6, Width: 2},
+		{Name: "blue", Label: "蓝球", Field: "blue", Index: -1, Min: 1, Max: 16, Pick: 1, Width: 2},
+	}},
+	"大乐透": {Name: "大乐透", Zones: []gameZone{
+		{Name: "red", Label: "前区", Field: "red", Index: -1, Min: 1, Max: 35, Pick: 5, Width: 2},
+		{Name: "blue", Label: "后区", Field: "blue", Index: -1, Min: 1, Max: 12, Pick: 2, Width: 2},
+	}},
+	"排列5": {Name: "排列5", Ordered: true, Zones: []gameZone{
+		{Name: "pos1", Label: "第1位", Field: "red", Index: 0, Min: 0, Max: 9, Pick: 1, Width: 1},
+		{Name: "pos2", Label: "第2位", Field: "red", Index: 1, Min: 0, Max: 9, Pick: 1, Width: 1},
+		{Name: "pos3", Label: "第3位", Field: "red", Index: 2, Min: 0, Max: 9, Pick: 1, Width: 1},
+		{Name: "pos4", Label: "第4位", Field: "red", Index: 3, Min: 0, Max: 9, Pick: 1, Width: 1},
+		{Name: "pos5", Label: "第5位", Field: "red", Index: 4, Min: 0, Max: 9, Pick: 1, Width: 1},
+	}},
+}
+
+// gameAliases 路径参数里允许使用拼音缩写
+var gameAliases = map[string]string{"ssq": "双色球", "dlt": "大乐透", "pl5": "排列5"}
+
+// gameCodes 标准彩种名 → 对外代码，en 模式的响应里以代码代替中文名
+var gameCodes = map[string]string{"双色球": "ssq", "大乐透": "dlt", "排列5": "pl5"}
+
+// gameCode 彩种的对外代码，没有配置代码的彩种返回标准名称
+func gameCode(game string) string {
+	name := canonicalGame(game)
+	if code, ok := gameCodes[name]; ok {
+		return code
+	}
+	return name
+}
+
+// specOf 按彩种名称或缩写查找规则
+func specOf(game string) (gameSpec, bool) {
+	if alias, ok := gameAliases[strings.ToLower(strings.TrimSpace(game))]; ok {
+		game = alias
+	}
+	spec, ok := gameSpecs[canonicalGame(game)]
+	return spec, ok
+}
+
+// format 把整数格式化为该区的号码写法
+func (z gameZone) format(n int) string {
+	return fmt.Sprintf("%0*d", z.Width, n)
+}
+
+// numbers 取出某期开奖在该区的号码
+func (z gameZone) numbers(d DrawRecord) []int {
+	src := d.Red
+	if z.Field == "blue" {
+		src = d.Blue
+	}
+	if z.Index >= 0 {
+		if z.Index >= len(src) {
+			return nil
+		}
+		src = src[z.Index : z.Index+1]
+	}
+	out := make([]int, 0, len(src))
+	for _, s := range src {
+		if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// GAMETYPE: 彩种名称识别错误时的纠正
+// ==========================================
+
+// OCR 偶尔把彩种名称认错字（"大乐秀"、"双色求"），这时按原样找不到验奖器，只能返回不支持。
+// 验奖前先做一次纠正，依次参考：
+//
+//  1. 常见误识别写法表和名称相近程度（与标准名称只差一个字）
+//  2. 号码结构：各区个数和取值范围，如 6+1、5+2、5 位数字
+//  3. 期号格式：位数与该彩种已同步的开奖数据（或内置默认）一致
+//
+// 名称相近的候选仍需号码结构吻合；名称完全认不出时，只有号码结构和期号能唯一确定一个彩种才纠正。
+// 纠正后 OCRData.Type 为标准名称，并在 warnings 中注明原文，便于人工核对
+
+// gameMisreadings 常见的残缺、简写写法 → 标准名称，按包含匹配；只错一个字的由 nameResembles 判断
+var gameMisreadings = map[string]string{
+	"双色": "双色球", "色球": "双色球",
+	"大乐": "大乐透", "乐透": "大乐透",
+	"排列五": "排列5", "排列伍": "排列5", "排五": "排列5",
+}
+
+// defaultIssueDigits 没有开奖数据时各彩种期号的位数
+var defaultIssueDigits = map[string]int{"双色球": 7, "大乐透": 5, "排列5": 5}
+
+// resolveGameType 彩种名称认不出时尝试纠正，返回纠正后的票和提示
+func resolveGameType(l LotteryData) (LotteryData, []string) {
+	if strings.TrimSpace(l.Type) == "" && len(l.Tickets) == 0 || selectVerifier(l.Type) != nil {
+		return l, nil
+	}
+	games := make([]string, 0, len(gameSpecs))
+	for name := range gameSpecs {
+		games = append(games, name)
+	}
+	sort.Strings(games)
+
+	var named, fits []string
+	for _, game := range games {
+		if !ticketsFitGame(l.Tickets, gameSpecs[game]) {
+			continue
+		}
+		fits = append(fits, game)
+		if nameResembles(l.Type, game) {
+			named = append(named, game)
+		}
+	}
+
+	game, reason := "", "名称相近"
+	switch pick := filterByIssue(named, l.Issue); {
+	case len(named) == 1:
+		game = named[0]
+	case len(pick) == 1:
+		game = pick[0]
+	case len(l.Tickets) > 0:
+		// 名称认不出，只看号码结构和期号
+		reason = "号码结构"
+		if l.Issue != "" {
+			reason = "号码结构和期号"
+		}
+		if pick := filterByIssue(fits, l.Issue); len(pick) == 1 {
+			game = pick[0]
+		}
+	}
+	if game == "" {
+		return l, nil
+	}
+	warning := fmt.Sprintf("票面彩种“%s”无法识别，已按%s判定为%s", strings.TrimSpace(l.Type), reason, game)
+	l.Type = game
+	return l, []string{warning}
+}
+
+// nameResembles 票面名称是否为该彩种的常见误识别写法，或其中有一段与标准名称只差一个字
+func nameResembles(text, game string) bool {
+	for wrong, name := range gameMisreadings {
+		if name == game && strings.Contains(text, wrong) {
+			return true
+		}
+	}
+	target := []rune(game)
+	if len(target) < 3 {
+		return false
+	}
+	src := []rune(text)
+	for i := 0; i+len(target) <= len(src); i++ {
+		diff := 0
+		for j, r := range target {
+			if src[i+j] != r {
+				diff++
+			}
+		}
+		if diff <= 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ticketsFitGame 每一行的号码个数和取值范围是否都符合该彩种；没有号码行时不作判断
+func ticketsFitGame(tickets []UserTicket, spec gameSpec) bool {
+	hasBlue := false
+	for _, z := range spec.Zones {
+		hasBlue = hasBlue || z.Field == "blue"
+	}
+	for _, t := range tickets {
+		if !hasBlue && len(t.Blue)+len(t.BlueDan) > 0 {
+			return false
+		}
+		for _, z := range spec.Zones {
+			nums := append(append([]string(nil), t.Red...), t.Dan...)
+			if z.Field == "blue" {
+				nums = append(append([]string(nil), t.Blue...), t.BlueDan...)
+			}
+			if z.Index >= 0 {
+				// 排列类按位，区内的号码个数等于位数
+				if len(nums) != positionsOf(spec, z.Field) || z.Index >= len(nums) {
+					return false
+				}
+				nums = nums[z.Index : z.Index+1]
+			} else if len(nums) < z.Pick {
+				return false
+			}
+			for _, s := range nums {
+				n, err := strconv.Atoi(strings.TrimSpace(s))
+				if err != nil || n < z.Min || n > z.Max {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// positionsOf 排列类某个号码区的位数
+func positionsOf(spec gameSpec, field string) int {
+	n := 0
+	for _, z := range spec.Zones {
+		if z.Field == field {
+			n++
+		}
+	}
+	return n
+}
+
+// filterByIssue 留下期号位数与彩种一致的候选；期号为空时不过滤
+func filterByIssue(games []string, issue string) []string {
+	issue = strings.TrimSpace(issue)
+	if issue == "" {
+		return games
+	}
+	if _, err := strconv.Atoi(issue); err != nil {
+		return nil
+	}
+	var out []string
+	for _, game := range games {
+		digits := defaultIssueDigits[game]
+		if history := draws.History(game); len(history) > 0 {
+			digits = len(history[0].Issue)
+		}
+		if digits == 0 || digits == len(issue) {
+			out = append(out, game)
+		}
+	}
+	return out
+}
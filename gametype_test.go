@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNameResembles(t *testing.T) {
+	tests := []struct {
+		text string
+		game string
+		want bool
+	}{
+		{"双色求", "双色球", true},
+		{"中国福利彩票 双邑球 单式", "双色球", true},
+		{"大乐秀", "大乐透", true},
+		{"体彩大乐", "大乐透", true},
+		{"排五", "排列5", true},
+		{"排列伍", "排列5", true},
+		{"双包求", "双色球", false},
+		{"大乐透", "双色球", false},
+		{"", "双色球", false},
+	}
+	for _, tt := range tests {
+		if got := nameResembles(tt.text, tt.game); got != tt.want {
+			t.Errorf("nameResembles(%q, %s) = %v, want %v", tt.text, tt.game, got, tt.want)
+		}
+	}
+}
+
+func TestTicketsFitGame(t *testing.T) {
+	tests := []struct {
+		name    string
+		game    string
+		tickets []UserTicket
+		want    bool
+	}{
+		{"双色球单式", "双色球", []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}}, true},
+		{"双色球复式", "双色球", []UserTicket{{Red: []string{"01", "02", "03", "04", "05", "06", "07"}, Blue: []string{"07", "16"}}}, true},
+		{"双色球胆拖", "双色球", []UserTicket{{Dan: []string{"01", "02"}, Red: []string{"03", "04", "05", "06"}, Blue: []string{"07"}}}, true},
+		{"红球超出范围", "双色球", []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "34"}, Blue: []string{"07"}}}, false},
+		{"红球不够", "双色球", []UserTicket{{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"07", "08"}}}, false},
+		{"大乐透单式", "大乐透", []UserTicket{{Red: []string{"01", "02", "03", "04", "35"}, Blue: []string{"06", "12"}}}, true},
+		{"后区超出范围", "大乐透", []UserTicket{{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "13"}}}, false},
+		{"排列5", "排列5", []UserTicket{{Red: []string{"0", "9", "3", "3", "1"}}}, true},
+		{"排列5位数不对", "排列5", []UserTicket{{Red: []string{"0", "9", "3"}}}, false},
+		{"排列5不含蓝球", "排列5", []UserTicket{{Red: []string{"0", "9", "3", "3", "1"}, Blue: []string{"1"}}}, false},
+		{"号码不是数字", "排列5", []UserTicket{{Red: []string{"0", "9", "3", "3", "?"}}}, false},
+		{"任何一行不符合", "双色球", []UserTicket{
+			{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}},
+			{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"17"}},
+		}, false},
+		{"没有号码行", "双色球", nil, true},
+	}
+	for _, tt := range tests {
+		if got := ticketsFitGame(tt.tickets, gameSpecs[tt.game]); got != tt.want {
+			t.Errorf("%s: ticketsFitGame() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByIssue(t *testing.T) {
+	loadTestGames(t)
+	// 已同步的开奖数据优先于内置位数
+	useTestDraws(t, DrawRecord{Game: "大乐透", Issue: "2025100"})
+	games := []string{"双色球", "大乐透", "排列5", "排列3"}
+	tests := []struct {
+		issue string
+		want  string
+	}{
+		{"", "双色球,大乐透,排列5,排列3"},
+		{"2025107", "双色球,大乐透,排列3"},
+		{"25107", "排列5,排列3"},
+		{"2025-107", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(filterByIssue(games, tt.issue), ","); got != tt.want {
+			t.Errorf("filterByIssue(%q) = %s, want %s", tt.issue, got, tt.want)
+		}
+	}
+}
+
+func TestResolveGameType(t *testing.T) {
+	loadTestGames(t)
+	useTestDraws(t)
+	ssq := []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}}
+	dlt := []UserTicket{{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}}}
+	pl5 := []UserTicket{{Red: []string{"0", "9", "3", "3", "1"}}}
+	// 6+2 既可能是双色球复式，也可能是大乐透复式
+	both := []UserTicket{{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07", "08"}}}
+
+	tests := []struct {
+		name        string
+		l           LotteryData
+		want        string
+		wantWarning string
+	}{
+		{"能识别的名称不变", LotteryData{Type: "双色球", Tickets: dlt}, "双色球", ""},
+		{"名称相近", LotteryData{Type: "双色求", Issue: "2025107", Tickets: ssq}, "双色球", "票面彩种“双色求”无法识别，已按名称相近判定为双色球"},
+		{"常见误识别写法", LotteryData{Type: "体彩排五", Tickets: pl5}, "排列5", "票面彩种“体彩排五”无法识别，已按名称相近判定为排列5"},
+		{"名称相近但号码不符，按号码结构", LotteryData{Type: "双色求", Tickets: dlt}, "大乐透", "票面彩种“双色求”无法识别，已按号码结构判定为大乐透"},
+		{"号码结构和期号", LotteryData{Type: "看不清", Issue: "2025107", Tickets: ssq}, "双色球", "票面彩种“看不清”无法识别，已按号码结构和期号判定为双色球"},
+		{"没有名称", LotteryData{Issue: "25107", Tickets: pl5}, "排列5", "票面彩种“”无法识别，已按号码结构和期号判定为排列5"},
+		{"期号区分号码结构相同的彩种", LotteryData{Type: "看不清", Issue: "25107", Tickets: both}, "大乐透", "票面彩种“看不清”无法识别，已按号码结构和期号判定为大乐透"},
+		{"无法唯一确定", LotteryData{Type: "看不清", Tickets: both}, "看不清", ""},
+		{"期号与号码结构矛盾", LotteryData{Type: "看不清", Issue: "25107", Tickets: ssq}, "看不清", ""},
+		{"期号不是数字", LotteryData{Type: "看不清", Issue: "第107期", Tickets: ssq}, "看不清", ""},
+		{"名称相近的候选号码都不符", LotteryData{Type: "大乐秀", Issue: "25107", Tickets: []UserTicket{{Red: []string{"99"}}}}, "大乐秀", ""},
+		{"没有名称也没有号码", LotteryData{Issue: "2025107"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := resolveGameType(tt.l)
+			if got.Type != tt.want {
+				t.Errorf("Type = %q, want %q", got.Type, tt.want)
+			}
+			if strings.Join(warnings, "|") != tt.wantWarning {
+				t.Errorf("warnings = %v, want %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// 纠正后的彩种正常验奖，附加玩法同样纠正
+func TestVerifyLotteryResolvesGameType(t *testing.T) {
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}},
+		DrawRecord{Game: "大乐透", Issue: "25107", Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}},
+	)
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	lottery := LotteryData{Type: "双色求", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"07"}, Multiplier: 1},
+	}, Sections: []LotteryData{{Type: "大乐秀", Issue: "25107", Tickets: []UserTicket{
+		{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}, Multiplier: 1},
+	}}}}
+	b := newRequestBudget(context.Background())
+	res, err := verifyLottery(b, 0, lottery)
+	b.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OCRData.Type != "双色球" || !strings.Contains(strings.Join(res.Warnings, "|"), "判定为双色球") {
+		t.Errorf("main = %s, warnings %v", res.OCRData.Type, res.Warnings)
+	}
+	if len(res.Sections) != 1 || res.Sections[0].OCRData.Type != "大乐透" || !strings.Contains(strings.Join(res.Sections[0].Warnings, "|"), "判定为大乐透") {
+		t.Fatalf("sections = %+v", res.Sections)
+	}
+	if res.Sections[0].TotalPrize == 0 || res.TotalPrize != 3000*Yuan+res.Sections[0].TotalPrize {
+		t.Errorf("total = %s, section %s", res.TotalPrize, res.Sections[0].TotalPrize)
+	}
+}
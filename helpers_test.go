@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeTestFile 在 dir 下写一个测试文件，失败时终止测试
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// 测试用的 YAML 彩种：一个按位比较（排列3），一个无序（七乐彩）
+const testGameDefs = `name: 排列3
+code: pl3
+aliases: [p3]
+ordered: true
+zones:
+  - {field: red, label: 号码, min: 0, max: 9, pick: 3, width: 1}
+prizes:
+  - {level: 1, prize: 1040, match: [{red: 3}]}
+---
+name: 七乐彩
+aliases: [qlc]
+zones:
+  - {field: red, label: 基本号, min: 1, max: 30, pick: 7, width: 2}
+prizes:
+  - {level: 4, prize: 200, match: [{red: 6}]}
+  - {level: 5, prize: 50, match: [{red: 5}]}
+`
+
+var testGamesOnce sync.Once
+
+// loadTestGames 注册测试用的 YAML 彩种，整个测试进程只注册一次
+func loadTestGames(t *testing.T) {
+	t.Helper()
+	testGamesOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "games")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		for i, def := range strings.Split(testGameDefs, "---\n") {
+			if err := os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".yaml"), []byte(def), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := loadGameDefinitions(dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// useTestDraws 用只含 list 的开奖数据替换全局 draws，测试结束后恢复
+func useTestDraws(t *testing.T, list ...DrawRecord) {
+	t.Helper()
+	old := draws
+	t.Cleanup(func() { draws = old })
+	draws = &drawStore{draws: map[string]DrawRecord{}}
+	if _, err := draws.Upsert(list); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestQueue 换成临时目录里的任务队列和批次存储，不启动 worker，任务停留在排队状态
+func useTestQueue(t *testing.T) {
+	t.Helper()
+	oldQueue, oldBatches := jobQueue, scanBatches
+	t.Cleanup(func() { jobQueue, scanBatches = oldQueue, oldBatches })
+	var err error
+	if jobQueue, err = newScanJobQueue(filepath.Join(t.TempDir(), "jobs")); err != nil {
+		t.Fatal(err)
+	}
+	if scanBatches, err = newBatchStore(filepath.Join(t.TempDir(), "batches")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestReviews 换成临时目录里的人工复核队列
+func useTestReviews(t *testing.T) {
+	t.Helper()
+	old := reviewQueue
+	t.Cleanup(func() { reviewQueue = old })
+	var err error
+	if reviewQueue, err = newReviewStore(filepath.Join(t.TempDir(), "review")); err != nil {
+		t.Fatal(err)
+	}
+}
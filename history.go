@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// HISTORY: 扫描记录（data/history.jsonl，一行一次扫描）
+// ==========================================
+
+// ScanRecord 一次成功的扫描；只保存识别结果，中奖情况查询时按最新开奖数据重新计算
+type ScanRecord struct {
+	ID        string        `json:"id"`
+	Tenant    string        `json:"tenant"`
+	UserID    string        `json:"user_id,omitempty"`
+	DeviceID  string        `json:"device_id,omitempty"`
+	ImageHash string        `json:"image_hash,omitempty"`
+	DHash     uint64        `json:"dhash,omitempty"` // 感知哈希，用于识别同一张票的重复拍照
+	Time      time.Time     `json:"time"`
+	Station   string        `json:"station,omitempty"` // 票面销售站点编号，按站点统计用
+	Lotteries []LotteryData `json:"lotteries"`
+	// 重新识别 / 验奖产生的新版本：OriginalID 指向最初的记录，Version 从 2 开始，Reprocess 说明产生方式
+	OriginalID string `json:"original_id,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	Reprocess  string `json:"reprocess,omitempty"`
+	// 产生这份识别结果的识别服务 / 模型与提示词版本，用于按服务统计准确率，见 feedback.go
+	OCREngine     string `json:"ocr_engine,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+}
+
+// rootID 同一张票各版本共用的编号
+func (r ScanRecord) rootID() string {
+	if r.OriginalID != "" {
+		return r.OriginalID
+	}
+	return r.ID
+}
+
+type historyStore struct {
+	mu      sync.RWMutex
+	path    string
+	db      *sql.DB // HISTORY_STORE=sqlite 时使用，见 history_sqlite.go
+	records []ScanRecord
+	index   scanIndex // 按号码、序列号、图片查找，见 similar.go
+}
+
+var scanHistory = &historyStore{}
+
+// Load 读取历史记录，损坏的行跳过；加密字段解密失败时报错，避免因主密钥配错而丢掉全部记录
+func (s *historyStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasSuffix(path, ".db") {
+		return s.loadSQLite(path)
+	}
+	s.path = path
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 8<<20)
+	for sc.Scan() {
+		r, err := unmarshalRecord(sc.Bytes())
+		if errors.Is(err, errSealedRecord) {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		s.records = append(s.records, r)
+	}
+	return sc.Err()
+}
+
+// duplicateDistance 感知哈希汉明距离不超过该值（且彩种期号相同）视为同一张票重拍，0 表示关闭
+func duplicateDistance() int { return envInt("DUPLICATE_DISTANCE", 6) }
+
+// Add 记录一次扫描并追加写入文件，开启了图片存储时同时保存原图与缩略图（租户开启匿名化时除外）。
+// 同一用户重复拍摄同一张票时不新增记录，返回原记录编号；
+// rescans 与 results 一一对应，此前扫描过的票给出与上次识别结果的差异
+func (s *historyStore) Add(origin ScanOrigin, fileBytes []byte, results []VerificationResult) (duplicateOf string, rescans []*RescanDiff) {
+	if len(results) == 0 {
+		return "", nil
+	}
+	r := ScanRecord{
+		ID: newJobID(), Tenant: origin.Tenant, UserID: origin.UserID, DeviceID: origin.DeviceID,
+		Time: time.Now(), Lotteries: make([]LotteryData, 0, len(results)),
+		OCREngine: currentOCREngine(), PromptVersion: promptVersion(),
+	}
+	if len(fileBytes) > 0 {
+		r.ImageHash = imageHash(fileBytes)
+		r.DHash, _ = perceptualHash(fileBytes)
+	}
+	for _, res := range results {
+		r.Lotteries = append(r.Lotteries, res.OCRData)
+		if r.Station == "" {
+			r.Station = res.OCRData.Station
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orig, dup := s.findDuplicate(r)
+	var dupRecord *ScanRecord
+	if dup {
+		dupRecord = &orig
+	}
+	rescans = make([]*RescanDiff, len(r.Lotteries))
+	for i, l := range r.Lotteries {
+		if diff, before := s.previousScan(r.Tenant, dupRecord, l); diff != nil {
+			diff.Changes = diffLottery(before, l)
+			diff.Identical = len(diff.Changes) == 0
+			rescans[i] = diff
+		}
+	}
+	if dup {
+		log.Printf("重复扫描 [%s]，关联到原记录 %s", origin.Tenant, orig.ID)
+		return orig.ID, rescans
+	}
+	if id, ok := sharedDuplicateOf(r); ok {
+		log.Printf("重复扫描 [%s]，关联到其他副本的原记录 %s", origin.Tenant, id)
+		return id, rescans
+	}
+
+	// 匿名化的租户只保留统计字段，印有序列号的原图也不保存
+	if privacy.Anonymize(r.Tenant) {
+		r, fileBytes = anonymizeRecord(r), nil
+	}
+	line, err := marshalRecord(r)
+	if err != nil {
+		log.Printf("保存扫描记录失败: %v", err)
+		return "", rescans
+	}
+	images.Save(r.ImageHash, fileBytes)
+	if err := s.appendLocked(r, line); err != nil {
+		log.Printf("保存扫描记录失败: %v", err)
+	}
+	rememberScan(r)
+	return "", rescans
+}
+
+// appendLocked 追加一条已序列化的记录，调用方持有写锁
+func (s *historyStore) appendLocked(r ScanRecord, line []byte) error {
+	s.records = append(s.records, r)
+	if s.db != nil {
+		return insertRecord(s.db, r, line)
+	}
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// AddVersion 为已有记录追加一个新版本（重新识别或重新验奖的结果），原记录保留不变
+func (s *historyStore) AddVersion(orig ScanRecord, lotteries []LotteryData, how string) (ScanRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root := orig.rootID()
+	version := 1
+	for _, r := range s.records {
+		if r.rootID() == root {
+			version = max(version, r.Version)
+		}
+	}
+	r := ScanRecord{
+		ID: newJobID(), Tenant: orig.Tenant, UserID: orig.UserID, DeviceID: orig.DeviceID,
+		ImageHash: orig.ImageHash, DHash: orig.DHash, Time: time.Now(), Lotteries: lotteries,
+		OriginalID: root, Version: version + 1, Reprocess: how,
+		OCREngine: orig.OCREngine, PromptVersion: orig.PromptVersion,
+	}
+	if engine, ok := strings.CutPrefix(how, "ocr:"); ok {
+		r.OCREngine, r.PromptVersion = engine, promptVersion()
+	}
+	for _, l := range lotteries {
+		if r.Station == "" {
+			r.Station = l.Station
+		}
+	}
+	if privacy.Anonymize(r.Tenant) {
+		r = anonymizeRecord(r)
+	}
+	line, err := marshalRecord(r)
+	if err != nil {
+		return ScanRecord{}, err
+	}
+	return r, s.appendLocked(r, line)
+}
+
+// findDuplicate 在同一用户的记录里找同一张票：图片完全相同，
+// 或感知哈希相近且识别出的彩种期号一致（不同彩票版式相同，只看哈希容易误判）
+func (s *historyStore) findDuplicate(r ScanRecord) (ScanRecord, bool) {
+	limit := duplicateDistance()
+	if r.ImageHash == "" || limit <= 0 {
+		return ScanRecord{}, false
+	}
+	for i := len(s.records) - 1; i >= 0; i-- {
+		old := s.records[i]
+		if old.Tenant != r.Tenant || old.UserID != r.UserID || old.ImageHash == "" {
+			continue
+		}
+		if old.ImageHash == r.ImageHash {
+			return old, true
+		}
+		if old.DHash == 0 || r.DHash == 0 || bits.OnesCount64(old.DHash^r.DHash) > limit {
+			continue
+		}
+		if sameDraws(old.Lotteries, r.Lotteries) {
+			return old, true
+		}
+	}
+	return ScanRecord{}, false
+}
+
+// sameDraws 两次识别结果的彩种与期号集合相同
+func sameDraws(a, b []LotteryData) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	key := func(l LotteryData) string { return canonicalGame(l.Type) + "|" + l.Issue }
+	seen := map[string]int{}
+	for _, l := range a {
+		seen[key(l)]++
+	}
+	for _, l := range b {
+		if seen[key(l)] == 0 {
+			return false
+		}
+		seen[key(l)]--
+	}
+	return true
+}
+
+// Find 某租户下某用户的全部记录，按时间从早到晚；有多个版本的票只返回最新版本
+func (s *historyStore) Find(tenant, userID string) []ScanRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	latest := map[string]string{}
+	for _, r := range s.records {
+		if r.Tenant == tenant && r.UserID == userID {
+			latest[r.rootID()] = r.ID
+		}
+	}
+	var out []ScanRecord
+	for _, r := range s.records {
+		if r.Tenant == tenant && r.UserID == userID && latest[r.rootID()] == r.ID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Get 按编号查询
+func (s *historyStore) Get(id string) (ScanRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.records {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return ScanRecord{}, false
+}
+
+// HistoryItem 列表接口的一条记录，图片以限时签名链接给出
+type HistoryItem struct {
+	ID           string        `json:"id"`
+	Time         time.Time     `json:"time"`
+	DeviceID     string        `json:"device_id,omitempty"`
+	Station      string        `json:"station,omitempty"`
+	Lotteries    []LotteryData `json:"lotteries"`
+	ThumbnailURL string        `json:"thumbnail_url,omitempty"`
+	ImageURL     string        `json:"image_url,omitempty"`
+	OriginalID   string        `json:"original_id,omitempty"`
+	Version      int           `json:"version,omitempty"`
+}
+
+// historyListHandler GET /api/v1/history?limit=20&offset=0，按时间从新到旧；用户规则同 portfolio
+func historyListHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	limit, offset := 20, 0
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+
+	records := scanHistory.Find(tenantOf(c), userID)
+	items := []HistoryItem{}
+	for i := len(records) - 1 - offset; i >= 0 && len(items) < limit; i-- {
+		r := records[i]
+		item := HistoryItem{ID: r.ID, Time: r.Time, DeviceID: r.DeviceID, Station: r.Station, Lotteries: r.Lotteries,
+			OriginalID: r.OriginalID, Version: r.Version}
+		if images.ThumbnailPath(r.ImageHash) != "" {
+			item.ThumbnailURL = signedImageURL(r.ID, imageKindThumbnail)
+		}
+		if images.OriginalPath(r.ImageHash) != "" {
+			item.ImageURL = signedImageURL(r.ID, imageKindOriginal)
+		}
+		items = append(items, item)
+	}
+	c.JSON(200, gin.H{"total": len(records), "items": items})
+}
+
+// historyImageHandler GET /api/v1/history/:id/thumbnail 与 /api/v1/history/:id/image，
+// 校验租户和用户后跳转到签名链接
+func historyImageHandler(thumbnail bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := scanHistory.Get(c.Param("id"))
+		if !ok || r.Tenant != tenantOf(c) || r.UserID != userOf(c) {
+			c.JSON(404, gin.H{"error": "记录不存在"})
+			return
+		}
+		path, kind := images.OriginalPath(r.ImageHash), imageKindOriginal
+		if thumbnail {
+			path, kind = images.ThumbnailPath(r.ImageHash), imageKindThumbnail
+		}
+		if path == "" {
+			c.JSON(404, gin.H{"error": "图片未保存"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(302, signedImageURL(r.ID, kind))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// ==========================================
+// HISTORY (SQLite): 扫描记录保存在嵌入式 SQLite
+// ==========================================
+
+// HISTORY_STORE=sqlite（单机模式默认开启，见 standalone.go）时扫描记录写入 data/history.db，
+// 不需要单独安装数据库；每条记录的内容与 history.jsonl 中的一行相同（同样按主密钥加密）。
+// 首次启用时 history.db 为空、而 history.jsonl 存在，会先把已有记录导入
+
+// historyPath 扫描记录的位置
+func historyPath() string {
+	if strings.EqualFold(os.Getenv("HISTORY_STORE"), "sqlite") {
+		return filepath.Join(dataDir(), "history.db")
+	}
+	return filepath.Join(dataDir(), "history.jsonl")
+}
+
+const historySchema = `CREATE TABLE IF NOT EXISTS scans (
+	seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+	id     TEXT NOT NULL,
+	tenant TEXT NOT NULL,
+	time   TEXT NOT NULL,
+	record BLOB NOT NULL
+)`
+
+// loadSQLite 打开数据库并读取全部记录；调用方持有写锁
+func (s *historyStore) loadSQLite(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return err
+	}
+	// 单进程写入，一个连接即可避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return fmt.Errorf("初始化扫描记录库失败: %v", err)
+	}
+	s.db = db
+	if err := s.importJSONL(strings.TrimSuffix(path, ".db") + ".jsonl"); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT record FROM scans ORDER BY seq`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return err
+		}
+		r, err := unmarshalRecord(raw)
+		if errors.Is(err, errSealedRecord) {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		s.records = append(s.records, r)
+	}
+	return rows.Err()
+}
+
+// importJSONL 数据库为空时导入原有的 history.jsonl，原文件保留不动
+func (s *historyStore) importJSONL(path string) error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM scans`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	old := &historyStore{}
+	if err := old.Load(path); err != nil {
+		return fmt.Errorf("导入 %s 失败: %v", path, err)
+	}
+	if len(old.records) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range old.records {
+		line, err := marshalRecord(r)
+		if err != nil {
+			return err
+		}
+		if err := insertRecord(tx, r, line); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("已从 %s 导入 %d 条扫描记录", path, len(old.records))
+	return nil
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertRecord(db sqlExecer, r ScanRecord, line []byte) error {
+	_, err := db.Exec(`INSERT INTO scans (id, tenant, time, record) VALUES (?, ?, ?, ?)`,
+		r.ID, r.Tenant, r.Time.UTC().Format("2006-01-02T15:04:05.000Z"), line)
+	return err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestSQLite 打开 path 处的扫描记录库，测试结束时关闭
+func openTestSQLite(t *testing.T, path string) *historyStore {
+	t.Helper()
+	s := &historyStore{}
+	if err := s.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func recordIDs(records []ScanRecord) string {
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestHistoryPath(t *testing.T) {
+	t.Setenv("DATA_DIR", "/srv/lottery")
+	tests := []struct {
+		store string
+		want  string
+	}{
+		{"", "/srv/lottery/history.jsonl"},
+		{"jsonl", "/srv/lottery/history.jsonl"},
+		{"sqlite", "/srv/lottery/history.db"},
+		{"SQLite", "/srv/lottery/history.db"},
+	}
+	for _, tt := range tests {
+		t.Setenv("HISTORY_STORE", tt.store)
+		if got := historyPath(); got != tt.want {
+			t.Errorf("historyPath() with %q = %s, want %s", tt.store, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryStoreSQLite(t *testing.T) {
+	useTestAtRest(t, "")
+	lottery := []LotteryData{{Type: "双色球", Issue: "2025107"}}
+	records := []ScanRecord{
+		{ID: "a", Tenant: "shop-a", UserID: "u1", Time: time.Now(), Lotteries: lottery},
+		{ID: "b", Tenant: "shop-a", UserID: "u1", Time: time.Now(), Lotteries: lottery},
+	}
+	old := ScanRecord{ID: "old", Tenant: "shop-a", UserID: "u1", Time: time.Now().Add(-time.Hour), Lotteries: lottery}
+
+	tests := []struct {
+		name     string
+		jsonl    []ScanRecord // 启用前已有的 history.jsonl
+		reopen   int          // 写入后重新打开的次数
+		wantIDs  string       // c 为 b 的新版本
+		wantFind string       // 同一张票只返回最新版本
+	}{
+		{"新建数据库", nil, 1, "a,b,c", "a,c"},
+		{"导入已有的 history.jsonl", []ScanRecord{old}, 1, "old,a,b,c", "old,a,c"},
+		{"只在数据库为空时导入一次", []ScanRecord{old}, 2, "old,a,b,c", "old,a,c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.jsonl != nil {
+				jsonl := &historyStore{path: filepath.Join(dir, "history.jsonl")}
+				for _, r := range tt.jsonl {
+					line, _ := marshalRecord(r)
+					if err := jsonl.appendLocked(r, line); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			path := filepath.Join(dir, "history.db")
+			s := openTestSQLite(t, path)
+			for _, r := range records {
+				line, _ := marshalRecord(r)
+				if err := s.appendLocked(r, line); err != nil {
+					t.Fatal(err)
+				}
+			}
+			orig, _ := s.Get("b")
+			v2, err := s.AddVersion(orig, lottery, "verify")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Replace(tt.wantIDs, "c", v2.ID, 1)
+
+			for i := 0; i < tt.reopen; i++ {
+				s = openTestSQLite(t, path)
+			}
+			if got := recordIDs(s.records); got != want {
+				t.Errorf("records = %s, want %s", got, want)
+			}
+			if got, _ := s.Get(v2.ID); got.OriginalID != "b" || got.Version != 2 {
+				t.Errorf("version = %+v", got)
+			}
+			if got, want := recordIDs(s.Find("shop-a", "u1")), strings.Replace(tt.wantFind, "c", v2.ID, 1); got != want {
+				t.Errorf("Find() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// 加密的记录在数据库里同样是密文，没有主密钥时拒绝加载
+func TestHistoryStoreSQLiteSealed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	useTestAtRest(t, testMasterKey(1))
+	s := openTestSQLite(t, path)
+	r := ScanRecord{ID: "a", Tenant: "shop-a", UserID: "user-1", Time: time.Now()}
+	line, err := marshalRecord(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.appendLocked(r, line); err != nil {
+		t.Fatal(err)
+	}
+	var raw []byte
+	if err := s.db.QueryRow(`SELECT record FROM scans`).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "user-1") {
+		t.Errorf("record stored in plain text: %s", raw)
+	}
+	s.db.Close()
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"同一主密钥", testMasterKey(1), false},
+		{"未配置主密钥", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAtRest(t, tt.key)
+			s := &historyStore{}
+			err := s.Load(path)
+			if s.db != nil {
+				defer s.db.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(s.records) != 1 || s.records[0].UserID != "user-1") {
+				t.Errorf("records = %+v", s.records)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHistoryStoreFind(t *testing.T) {
+	s := &historyStore{}
+	lottery := []LotteryData{{Type: "双色球", Issue: "2025107"}}
+	for _, r := range []ScanRecord{
+		{ID: "a", Tenant: "shop-a", UserID: "u1", Lotteries: lottery},
+		{ID: "b", Tenant: "shop-a", UserID: "u1", Lotteries: lottery},
+		{ID: "c", Tenant: "shop-a", UserID: "u2", Lotteries: lottery},
+		{ID: "d", Tenant: "shop-b", UserID: "u1", Lotteries: lottery},
+	} {
+		if err := s.appendLocked(r, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig, _ := s.Get("a")
+	v2, err := s.AddVersion(orig, lottery, "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.OriginalID != "a" || v2.Version != 2 {
+		t.Fatalf("version = %s/%d, want a/2", v2.OriginalID, v2.Version)
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		user   string
+		want   []string
+	}{
+		{"多个版本只返回最新", "shop-a", "u1", []string{"b", v2.ID}},
+		{"按用户隔离", "shop-a", "u2", []string{"c"}},
+		{"按租户隔离", "shop-b", "u1", []string{"d"}},
+		{"没有记录", "shop-b", "u2", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Find(tt.tenant, tt.user)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Find() = %d records, want %v", len(got), tt.want)
+			}
+			for i, id := range tt.want {
+				if got[i].ID != id {
+					t.Errorf("record %d = %s, want %s", i, got[i].ID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryStoreLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := &historyStore{path: path}
+	origin := ScanOrigin{Tenant: "shop-a", UserID: "u1", DeviceID: "k1"}
+	results := []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107", Station: "44010001"}}}
+	if dup, _ := s.Add(origin, nil, results); dup != "" {
+		t.Fatalf("first scan reported duplicate of %s", dup)
+	}
+
+	loaded := &historyStore{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.records) != 1 {
+		t.Fatalf("loaded %d records, want 1", len(loaded.records))
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"设备", loaded.records[0].DeviceID, "k1"},
+		{"站点取自票面", loaded.records[0].Station, "44010001"},
+		{"期号", loaded.records[0].Lotteries[0].Issue, "2025107"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestHistoryImageHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldHistory, oldImages := scanHistory, images
+	t.Cleanup(func() { scanHistory, images = oldHistory, oldImages })
+
+	const hash = "abcdef0123456789"
+	images = &imageStore{dir: t.TempDir(), enabled: true}
+	writeTestFile(t, images.dir, filepath.Join("ab", hash+".img"), []byte("img"))
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "default", UserID: "u1", ImageHash: hash},
+		{ID: "r2", Tenant: "default", UserID: "u1"},
+	}}
+
+	tests := []struct {
+		name       string
+		id         string
+		user       string
+		thumbnail  bool
+		wantStatus int
+	}{
+		{"本人原图跳转到签名链接", "r1", "u1", false, 302},
+		{"缩略图未生成", "r1", "u1", true, 404},
+		{"其他用户的记录", "r1", "u2", false, 404},
+		{"没有保存图片", "r2", "u1", false, 404},
+		{"记录不存在", "r9", "u1", false, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/history/"+tt.id+"/image", nil)
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			c.Set("auth_session", "s1")
+			c.Set("auth_user", tt.user)
+			historyImageHandler(tt.thumbnail)(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == 302 && !strings.HasPrefix(w.Header().Get("Location"), "/api/v1/images/"+tt.id+"/") {
+				t.Errorf("Location = %q", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	lottery := []LotteryData{{Type: "双色球", Issue: "2025107"}}
+	other := []LotteryData{{Type: "双色球", Issue: "2025108"}}
+	s := &historyStore{records: []ScanRecord{
+		{ID: "same-image", Tenant: "shop-a", UserID: "u1", ImageHash: "h1", DHash: 0xff00, Lotteries: other},
+		{ID: "near", Tenant: "shop-a", UserID: "u1", ImageHash: "h2", DHash: 0xf0f0f0f0, Lotteries: lottery},
+	}}
+	tests := []struct {
+		name     string
+		distance string
+		r        ScanRecord
+		want     string
+	}{
+		{"图片完全相同", "", ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "h1", Lotteries: lottery}, "same-image"},
+		{"哈希相近且期号相同", "", ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "h3", DHash: 0xf0f0f0f3, Lotteries: lottery}, "near"},
+		{"哈希相近但期号不同", "", ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "h3", DHash: 0xf0f0f0f3, Lotteries: other}, ""},
+		{"哈希相差太多", "", ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "h3", DHash: 0x0f0f0f0f, Lotteries: lottery}, ""},
+		{"其他用户", "", ScanRecord{Tenant: "shop-a", UserID: "u2", ImageHash: "h1", Lotteries: lottery}, ""},
+		{"关闭后不判重", "0", ScanRecord{Tenant: "shop-a", UserID: "u1", ImageHash: "h1", Lotteries: lottery}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DUPLICATE_DISTANCE", tt.distance)
+			got, ok := s.findDuplicate(tt.r)
+			if ok != (tt.want != "") || got.ID != tt.want {
+				t.Errorf("findDuplicate() = %q, %v, want %q", got.ID, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ==========================================
+// HOOKS: 识别后 / 验奖后的扩展钩子
+// ==========================================
+
+// 定制逻辑（补充字段、推送到内部 CRM 等）不必改主流程，按顺序挂在两个位置：
+//
+//	识别后  AfterOCR     拿到（或从缓存取到）识别结果、验奖之前，可以修改识别结果
+//	验奖后  AfterVerify  每次批量验奖完成后，可以修改验奖结果，也可以只做推送；流式输出时每验完一张票调用一次
+//
+// 注册方式二选一：
+//
+//  1. 编译进来：在本包新建文件，init() 里调用 RegisterOCRHook / RegisterVerifyHook；
+//  2. Go 插件：PLUGIN_DIR 下的每个 .so 可以导出以下函数（任选其一或都导出），入参和返回值都是 JSON，
+//     返回空表示不修改：
+//
+//	func AfterOCR(ctx context.Context, lotteries []byte) ([]byte, error)    // []LotteryData
+//	func AfterVerify(ctx context.Context, results []byte) ([]byte, error)   // []VerificationResult
+//
+// 插件需用与服务端相同的 Go 版本和依赖、以 go build -buildmode=plugin 编译，服务端需开启 cgo。
+// 钩子出错只记日志，丢弃该钩子的修改，不影响本次扫描；钩子不要原地修改入参（识别结果可能来自缓存）
+
+// OCRHook 识别后钩子，返回修改后的识别结果
+type OCRHook func(ctx context.Context, lotteries []LotteryData) ([]LotteryData, error)
+
+// VerifyHook 验奖后钩子，返回修改后的验奖结果
+type VerifyHook func(ctx context.Context, results []VerificationResult) ([]VerificationResult, error)
+
+type namedOCRHook struct {
+	name string
+	fn   OCRHook
+}
+
+type namedVerifyHook struct {
+	name string
+	fn   VerifyHook
+}
+
+type hookRegistry struct {
+	mu     sync.RWMutex
+	ocr    []namedOCRHook
+	verify []namedVerifyHook
+}
+
+var hooks = &hookRegistry{}
+
+// RegisterOCRHook 注册识别后钩子，按注册顺序执行
+func RegisterOCRHook(name string, fn OCRHook) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.ocr = append(hooks.ocr, namedOCRHook{name, fn})
+}
+
+// RegisterVerifyHook 注册验奖后钩子，按注册顺序执行
+func RegisterVerifyHook(name string, fn VerifyHook) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.verify = append(hooks.verify, namedVerifyHook{name, fn})
+}
+
+// AfterOCR 依次执行识别后钩子
+func (h *hookRegistry) AfterOCR(ctx context.Context, lotteries []LotteryData) []LotteryData {
+	h.mu.RLock()
+	list := h.ocr
+	h.mu.RUnlock()
+	if len(list) == 0 {
+		return lotteries
+	}
+	lotteries = append([]LotteryData(nil), lotteries...)
+	for _, hk := range list {
+		out, err := runHook(func() ([]LotteryData, error) { return hk.fn(ctx, lotteries) })
+		if err != nil {
+			log.Printf("识别后钩子 %s 失败: %v", hk.name, err)
+			continue
+		}
+		lotteries = out
+	}
+	return lotteries
+}
+
+// AfterVerify 依次执行验奖后钩子
+func (h *hookRegistry) AfterVerify(ctx context.Context, results []VerificationResult) []VerificationResult {
+	h.mu.RLock()
+	list := h.verify
+	h.mu.RUnlock()
+	for _, hk := range list {
+		out, err := runHook(func() ([]VerificationResult, error) { return hk.fn(ctx, results) })
+		if err != nil {
+			log.Printf("验奖后钩子 %s 失败: %v", hk.name, err)
+			continue
+		}
+		results = out
+	}
+	return results
+}
+
+// runHook 钩子 panic 时按出错处理，不拖垮请求
+func runHook[T any](fn func() (T, error)) (out T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// jsonHook 把插件导出的 JSON 函数包装成钩子；返回空时沿用入参
+func jsonHook[T any](fn func(context.Context, []byte) ([]byte, error)) func(context.Context, T) (T, error) {
+	return func(ctx context.Context, in T) (T, error) {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return in, err
+		}
+		out, err := fn(ctx, raw)
+		if err != nil || len(out) == 0 {
+			return in, err
+		}
+		var v T
+		if err := json.Unmarshal(out, &v); err != nil {
+			return in, fmt.Errorf("返回值解析失败: %v", err)
+		}
+		return v, nil
+	}
+}
+
+// loadPlugins 加载 dir 下的全部 .so，按文件名顺序注册；目录为空或不存在时跳过
+func loadPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		p, err := plugin.Open(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(f), err)
+		}
+		name := strings.TrimSuffix(filepath.Base(f), ".so")
+		found := false
+		if sym, err := p.Lookup("AfterOCR"); err == nil {
+			fn, ok := sym.(func(context.Context, []byte) ([]byte, error))
+			if !ok {
+				return fmt.Errorf("插件 %s 的 AfterOCR 签名不对", name)
+			}
+			RegisterOCRHook(name, jsonHook[[]LotteryData](fn))
+			found = true
+		}
+		if sym, err := p.Lookup("AfterVerify"); err == nil {
+			fn, ok := sym.(func(context.Context, []byte) ([]byte, error))
+			if !ok {
+				return fmt.Errorf("插件 %s 的 AfterVerify 签名不对", name)
+			}
+			RegisterVerifyHook(name, jsonHook[[]VerificationResult](fn))
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("插件 %s 没有导出 AfterOCR 或 AfterVerify", name)
+		}
+		log.Printf("已加载插件 %s", name)
+	}
+	return nil
+}
+
+// pluginDir PLUGIN_DIR，未设置时不加载插件
+func pluginDir() string { return strings.TrimSpace(os.Getenv("PLUGIN_DIR")) }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 两张双色球，按调用方提供的开奖号码验奖，不记历史、不发通知
+const hookTestFixture = `[
+{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02","11","15","21","28","33"], "blue": ["07"], "multiplier": 1}]},
+{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["01","03","05","06","08","09"], "blue": ["16"], "multiplier": 1}]}
+]`
+
+func TestVerifyHandlerRunsVerifyHooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GEMINI_API_KEY", "test")
+	var fixture []LotteryData
+	if err := json.Unmarshal([]byte(hookTestFixture), &fixture); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ocrCache.Purge)
+
+	prev := hooks.verify
+	t.Cleanup(func() { hooks.verify = prev })
+	calls := 0
+	RegisterVerifyHook("test", func(ctx context.Context, results []VerificationResult) ([]VerificationResult, error) {
+		calls++
+		out := make([]VerificationResult, len(results))
+		for i, r := range results {
+			r.Warnings = append(append([]string(nil), r.Warnings...), "hooked")
+			out[i] = r
+		}
+		return out, nil
+	})
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCalls int
+	}{
+		{"整批返回时调用一次", "", 1},
+		{"ndjson 流式每张票调用一次", "?stream=ndjson", 2},
+		{"数组流式每张票调用一次", "?stream=1", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			// 识别结果预先放进缓存，不调用模型
+			image := []byte("\xff\xd8\xff\xe0hook-" + tt.name)
+			ocrCache.Set(imageHash(image), fixture)
+			fw, _ := mw.CreateFormFile("image", "ticket.jpg")
+			fw.Write(image)
+			mw.WriteField("winning", `{"game": "双色球", "red": ["02","11","15","21","28","33"], "blue": ["07"]}`)
+			mw.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			verifyHandler(c)
+
+			if w.Code != 200 {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("hook calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if n := strings.Count(w.Body.String(), "hooked"); n != 2 {
+				t.Errorf("hooked results = %d, want 2: %s", n, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// I18N: 按 Accept-Language / ?lang= 输出多语言文案
+// ==========================================
+
+// 服务内部所有文案都以简体中文生成，输出前再按语言目录翻译：
+// 目录以简体原文为键，支持 "第{#n}行" 这样的模板：{#x} 只匹配数字且原样保留，
+// {x} 匹配不含 "，" "；" 的一段文字并递归翻译，{*x} 匹配任意文字，只用于 "{a}，{*b}" 这类
+// 通用分隔模板，把长句拆成小句分别翻译。data/i18n/<语言>.json 中的条目覆盖内置目录，也可以新增语言
+
+const localeDefault = "zh-CN"
+
+// messageCatalog 一种语言的翻译目录
+type messageCatalog struct {
+	exact    map[string]string
+	patterns []catalogPattern
+	// fallback 目录里查不到时的兜底转换（繁体按字转换），nil 表示原样返回
+	fallback func(string) string
+}
+
+type catalogPattern struct {
+	source string
+	re     *regexp.Regexp
+	names  []string
+	target string
+}
+
+var placeholderRe = regexp.MustCompile(`\{([#*]?[A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// catalogs 语言 → 目录；简体中文是原文，不在其中
+var catalogs = map[string]*messageCatalog{}
+
+func newMessageCatalog(entries map[string]string, fallback func(string) string) *messageCatalog {
+	cat := &messageCatalog{exact: map[string]string{}, fallback: fallback}
+	cat.merge(entries)
+	return cat
+}
+
+// merge 加入条目，同名条目以后加入的为准
+func (cat *messageCatalog) merge(entries map[string]string) {
+	for src, dst := range entries {
+		if !placeholderRe.MatchString(src) {
+			cat.exact[src] = dst
+			continue
+		}
+		var names []string
+		var expr strings.Builder
+		expr.WriteString("^")
+		last := 0
+		for _, m := range placeholderRe.FindAllStringSubmatchIndex(src, -1) {
+			expr.WriteString(regexp.QuoteMeta(src[last:m[0]]))
+			name := src[m[2]:m[3]]
+			switch name[0] {
+			case '#':
+				expr.WriteString(`(-?[0-9][0-9,.]*)`)
+			case '*':
+				expr.WriteString(`(.+?)`)
+			default:
+				expr.WriteString(`([^，；]+?)`)
+			}
+			names = append(names, name)
+			last = m[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(src[last:]) + "$")
+		p := catalogPattern{source: src, re: regexp.MustCompile(expr.String()), names: names, target: dst}
+		replaced := false
+		for i := range cat.patterns {
+			if cat.patterns[i].source == src {
+				cat.patterns[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			cat.patterns = append(cat.patterns, p)
+		}
+	}
+	// 通用分隔模板排在最后，其余按固定文字多少排序，越具体越先匹配
+	sort.SliceStable(cat.patterns, func(i, j int) bool {
+		gi, gj := strings.Contains(cat.patterns[i].source, "{*"), strings.Contains(cat.patterns[j].source, "{*")
+		if gi != gj {
+			return gj
+		}
+		li, lj := literalLen(cat.patterns[i].source), literalLen(cat.patterns[j].source)
+		if li != lj {
+			return li > lj
+		}
+		return cat.patterns[i].source < cat.patterns[j].source
+	})
+}
+
+func literalLen(src string) int {
+	return len([]rune(placeholderRe.ReplaceAllString(src, "")))
+}
+
+// T 翻译一条文案；cat 为 nil（简体中文）时原样返回
+func (cat *messageCatalog) T(s string) string {
+	if cat == nil || s == "" {
+		return s
+	}
+	if dst, ok := cat.exact[s]; ok {
+		return dst
+	}
+	for _, p := range cat.patterns {
+		m := p.re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		out := p.target
+		for i, name := range p.names {
+			v := m[i+1]
+			if !strings.HasPrefix(name, "#") {
+				v = cat.T(v)
+			}
+			out = strings.ReplaceAll(out, "{"+name+"}", v)
+		}
+		return out
+	}
+	if cat.fallback != nil {
+		return cat.fallback(s)
+	}
+	return s
+}
+
+// all 翻译一组文案，返回新切片（原切片可能与缓存共用）
+func (cat *messageCatalog) all(list []string) []string {
+	if cat == nil || len(list) == 0 {
+		return list
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = cat.T(s)
+	}
+	return out
+}
+
+// loadCatalogs 读取 dir 下的 <语言>.json（{"简体原文": "译文"}），目录不存在时只用内置目录
+func loadCatalogs(dir string) error {
+	catalogs = map[string]*messageCatalog{
+		"en":    newMessageCatalog(builtinEnglish, nil),
+		"zh-TW": newMessageCatalog(nil, toTraditional),
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var entries map[string]string
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(f), err)
+		}
+		locale := strings.TrimSuffix(filepath.Base(f), ".json")
+		if strings.EqualFold(locale, localeDefault) {
+			continue
+		}
+		if cat := catalogFor(locale); cat != nil {
+			cat.merge(entries)
+		} else {
+			catalogs[locale] = newMessageCatalog(entries, nil)
+		}
+	}
+	return nil
+}
+
+// catalogFor 按语言标签（不区分大小写）取目录
+func catalogFor(locale string) *messageCatalog {
+	for k, cat := range catalogs {
+		if strings.EqualFold(k, locale) {
+			return cat
+		}
+	}
+	return nil
+}
+
+// matchLocale 把一个语言标签映射到已有目录：zh-HK / zh-Hant 归入繁体，其余 zh 归入简体，
+// 其他语言先精确匹配再按主语言匹配（en-US → en）
+func matchLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if tag == "" || tag == "*" {
+		return "", false
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	if primary == "zh" {
+		if strings.Contains(tag, "hant") || strings.HasSuffix(tag, "-tw") || strings.HasSuffix(tag, "-hk") || strings.HasSuffix(tag, "-mo") {
+			return "zh-TW", true
+		}
+		return localeDefault, true
+	}
+	for k := range catalogs {
+		if strings.EqualFold(k, tag) {
+			return k, true
+		}
+	}
+	for k := range catalogs {
+		if strings.EqualFold(k, primary) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// requestLocale ?lang= 优先，en 接口模式默认英文，其次按 Accept-Language 的 q 值从高到低取第一个支持的语言
+func requestLocale(c *gin.Context) string {
+	if l, ok := matchLocale(c.Query("lang")); ok {
+		return l
+	}
+	if apiModeOf(c) == apiModeEN {
+		return "en"
+	}
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if l, ok := matchLocale(t.tag); ok {
+			return l
+		}
+	}
+	return localeDefault
+}
+
+// catalogOf 当前请求的翻译目录，简体中文返回 nil
+func catalogOf(c *gin.Context) *messageCatalog {
+	if l := c.GetString("locale"); l != "" {
+		return catalogFor(l)
+	}
+	return catalogFor(requestLocale(c))
+}
+
+// localeMiddleware 确定请求语言；非简体时缓冲 4xx/5xx 的 JSON 响应，翻译其中的 error 字段
+func localeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := requestLocale(c)
+		c.Set("locale", locale)
+		c.Header("Content-Language", locale)
+		cat := catalogFor(locale)
+		if cat == nil {
+			c.Next()
+			return
+		}
+		w := &localeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if w.buf == nil {
+			return
+		}
+		body := w.buf.Bytes()
+		var payload map[string]interface{}
+		if json.Unmarshal(body, &payload) == nil {
+			if msg, ok := payload["error"].(string); ok {
+				payload["error"] = cat.T(msg)
+				if translated, err := json.Marshal(payload); err == nil {
+					body = translated
+				}
+			}
+		}
+		w.ResponseWriter.Write(body)
+	}
+}
+
+// localeWriter 只拦截错误响应，正常响应（含 SSE / NDJSON 流）直接写出
+type localeWriter struct {
+	gin.ResponseWriter
+	buf *bytes.Buffer
+}
+
+func (w *localeWriter) intercept() bool {
+	return w.Status() >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *localeWriter) Write(data []byte) (int, error) {
+	if !w.intercept() {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.buf == nil {
+		w.buf = &bytes.Buffer{}
+	}
+	return w.buf.Write(data)
+}
+
+func (w *localeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// localizeResults 翻译验奖结果中的逐行状态和提示
+func localizeResults(cat *messageCatalog, results []VerificationResult) []VerificationResult {
+	if cat == nil {
+		return results
+	}
+	out := make([]VerificationResult, len(results))
+	for i, res := range results {
+		res.Warnings = cat.all(res.Warnings)
+		res.Error = cat.T(res.Error)
+		res.Claim = localizeClaim(cat, res.Claim)
+		details := make([]ResultDetail, len(res.Details))
+		for j, d := range res.Details {
+			d.Status = cat.T(d.Status)
+			d.Explanation = localizeExplanation(cat, d.Explanation)
+			details[j] = d
+		}
+		res.Details = details
+		if len(res.Sections) > 0 {
+			res.Sections = localizeResults(cat, res.Sections)
+		}
+		out[i] = res
+	}
+	return out
+}
+
+// localizeCards 翻译卡片上的全部展示文案
+func localizeCards(cat *messageCatalog, resp CardResponse) CardResponse {
+	if cat == nil {
+		return resp
+	}
+	resp.Summary, resp.TotalText = cat.T(resp.Summary), cat.T(resp.TotalText)
+	cards := make([]ResultCard, len(resp.Cards))
+	for i, card := range resp.Cards {
+		card.Title, card.Headline, card.Subtitle = cat.T(card.Title), cat.T(card.Headline), cat.T(card.Subtitle)
+		card.Jackpot = cat.T(card.Jackpot)
+		card.Warnings = cat.all(card.Warnings)
+		card.Claim = localizeClaim(cat, card.Claim)
+		rows := make([]CardRow, len(card.Rows))
+		for j, row := range card.Rows {
+			row.Label, row.Status = cat.T(row.Label), cat.T(row.Status)
+			rows[j] = row
+		}
+		card.Rows = rows
+		cards[i] = card
+	}
+	resp.Cards = cards
+	return resp
+}
+
+// builtinEnglish 内置英文目录，覆盖验奖状态、奖级、卡片和常见错误
+var builtinEnglish = map[string]string{
+	// 通用分隔，把复合文案拆开逐段翻译
+	"{a}: {*b}": "{a}: {*b}",
+	"{a}，{*b}":  "{a}, {*b}",
+	"{a}；{*b}":  "{a}; {*b}",
+	"{a}（{*b}）": "{a} ({*b})",
+
+	// 金额、彩种
+	"{#n}元":    "¥{#n}",
+	"{#n}亿元":   "¥{#n} × 100M",
+	"{#n}万元":   "¥{#n} × 10K",
+	"双色球":      "Double Color Ball",
+	"大乐透":      "Super Lotto",
+	"排列5":      "Pick 5",
+	"排列五":      "Pick 5",
+	"含{names}": "incl. {names}",
+
+	// 奖级
+	"未中奖":    "No prize",
+	"一等奖":    "1st prize",
+	"二等奖":    "2nd prize",
+	"三等奖":    "3rd prize",
+	"四等奖":    "4th prize",
+	"五等奖":    "5th prize",
+	"六等奖":    "6th prize",
+	"七等奖":    "7th prize",
+	"八等奖":    "8th prize",
+	"九等奖":    "9th prize",
+	"{#n}等奖": "Prize level {#n}",
+
+	// 逐行状态
+	"{level} {#n}元":        "{level} ¥{#n}",
+	"中奖":                   "Won",
+	"中奖: {amount}":         "Won {amount}",
+	"待开奖":                  "Awaiting draw",
+	"已开奖，等待开奖数据同步":         "Drawn, waiting for results to sync",
+	"玩法校验未通过，未验奖":          "Bet type check failed, not verified",
+	"暂不支持该彩种验奖":            "Verification is not supported for this game",
+	"暂不支持":                 "Not supported",
+	"票据校验未通过":              "Ticket validation failed",
+	"浮动奖金为估算，以官方公布为准":      "floating prize is an estimate; the official amount prevails",
+	"第{#n}行":               "Row {#n}",
+	"{game} 第{issue}期":     "{game} draw {issue}",
+	"恭喜中奖 {amount}":        "Congratulations! You won {amount}",
+	"本期尚未开奖":               "This draw has not taken place yet",
+	"未中奖，下次好运":             "No prize this time. Better luck next time",
+	"共 {#n} 行 · 中奖 {#m} 行": "{#n} rows · {#m} winning",
+	"当前奖池 {pool}":          "Current jackpot {pool}",
+	"{icon} 共识别 {#n} 张彩票，合计中奖 {amount}": "{icon} {#n} tickets recognized, total winnings {amount}",
+	"共识别 {#n} 张彩票，本次未中奖":                "{#n} tickets recognized, no prize this time",
+
+	// 提示
+	"识别倍数与票面金额 {#a} 元不符，已按金额修正为 {#m} 倍":      "recognized multiplier does not match the printed amount of ¥{#a}; corrected to ×{#m}",
+	"按识别倍数应付 {amount}":                       "the recognized multipliers imply a cost of {amount}",
+	"票面金额为 {#a} 元":                           "but the printed amount is ¥{#a}",
+	"暂按 1 倍计算":                               "calculated at ×1 for now",
+	"请核对票面":                                  "please check the ticket",
+	"第{#n}行识别为 {#m} 倍":                       "row {#n} was recognized as ×{#m}",
+	"超过上限 {#l} 倍":                            "above the limit of ×{#l}",
+	"第{#n}行票面标注{printed}，识别出的号码却是{detected}": "row {#n} is marked {printed} but the numbers read as {detected}",
+	"第{#n}行为{mode}投注":                        "row {#n} is a {mode} bet",
+	"{game}验奖暂不支持":                           "which {game} verification does not support yet",
+	"单式":                                     "single",
+	"复式":                                     "multiple",
+	"胆拖":                                     "banker",
+	"机选":                                     "quick pick",
+	"自选":                                     "self-selected",
+	"销售时间 {t} 晚于当前时间":                        "sale time {t} is later than the current time",
+	"销售时间 {t} 晚于第{issue}期停售时间":               "sale time {t} is after sales closed for draw {issue}",
+	"票面期号 {a} 与销售时间对应的第{b}期不一致": "printed draw {a} does not match draw {b} implied by the sale time",
+	"拍摄时间 {t} 早于票面销售时间":         "photo was taken at {t}, before the printed sale time",
+	"兑奖前请核对实物彩票":                "check the physical ticket before claiming",
+	"与 {t} 的扫描结果有 {#n} 处不同":     "{#n} differences from the scan at {t}",
+	"该彩票此前已扫描过，本次结果已关联到原记录":     "This ticket was scanned before; the result has been linked to the original record",
+	"未配置第二识别服务，未做交叉核对":          "No secondary OCR service configured; cross-check skipped",
+
+	// 常见错误
+	"winning 格式错误":                        "Invalid winning numbers",
+	"站点编号无效":                              "Invalid station ID",
+	"{name} 格式应为 2006-01-02":              "{name} must be in 2006-01-02 format",
+	"to 不能早于 from":                        "to must not be earlier than from",
+	"查询范围最多 {#n} 天":                       "Query range is limited to {#n} days",
+	"bucket 应为 hour/day/week/month":       "bucket must be hour, day, week or month",
+	"source 只能是 ticket、screenshot 或 auto": "source must be ticket, screenshot or auto",
+	"未使用官方开奖数据：按调用方提供的开奖号码验奖": "Not official draw data: verified against caller-supplied winning numbers",
+	"第{#n}组开奖号码缺少 game":       "winning set {#n} is missing game",
+	"第{#n}组开奖号码缺少 red":        "winning set {#n} is missing red",
+	"请求参数校验失败":                "Request validation failed",
+	"不是有效的号码":                 "not a valid number",
+	"不是有效的期号":                 "not a valid issue number",
+	"不是有效的邮箱地址":               "not a valid email address",
+	"不是有效的手机号":                "not a valid phone number",
+	"不能为空":                    "must not be empty",
+	"必填":                      "is required",
+	"应为字符串":                   "must be a string",
+	"应为数字":                    "must be a number",
+	"应为整数":                    "must be an integer",
+	"应为布尔值":                   "must be a boolean",
+	"应为数组":                    "must be an array",
+	"应为对象":                    "must be an object",
+	"只能是 {*values}":           "must be one of {values}",
+	"不能小于 {#n}":               "must not be less than {#n}",
+	"至少 {#n} 项":               "must have at least {#n} items",
+	"最多 {#n} 项":               "must have at most {#n} items",
+	"请求格式错误":                  "Malformed request",
+	"不支持的彩种":                  "Unsupported game",
+	"lotteries 不能为空":          "lotteries must not be empty",
+	"text 不能为空":               "text must not be empty",
+	"号码格式错误":                  "Could not parse the numbers",
+	"没有找到号码":                  "no numbers found",
+	"第{#n}注":                  "bet {#n}",
+	"tickets 不能为空":            "tickets must not be empty",
+	"读取图片失败":                  "Failed to read the image",
+	"请上传名为 'image' 的文件":       "Please upload a file named 'image'",
+	"请上传名为 'image' 的文件，或用 upload_id 引用已完成的上传": "Please upload a file named 'image', or reference a completed upload with upload_id",
+	"image_hash 应为图片内容的 SHA-256（64 位十六进制）":    "image_hash must be the SHA-256 of the image content (64 hex digits)",
+	"今日 AI 识别预算已用完，请明天再试":                     "Today's AI recognition budget is used up; please try again tomorrow",
+	"请按从上到下的顺序上传名为 'images' 的 2~4 张照片":        "Upload 2–4 photos named 'images', in order from top to bottom",
+	"第{#n}张照片识别为{a}，与第1张的{b}不一致，请确认是同一张票":     "Photo {#n} was read as {a}, which differs from {b} in photo 1; make sure both show the same ticket",
+	"第{#n}张照片的期号 {a} 与第1张的 {b} 不一致，请确认是同一张票":  "Photo {#n} shows draw {a}, which differs from {b} in photo 1; make sure both show the same ticket",
+	"{a}中 {#n} 个 + {b}中 {#m} 个 → {c}":         "{a} {#n} matched + {b} {#m} matched → {c}",
+	"缺少用户身份（访问令牌或 API Key + X-User-ID）":       "Missing user identity (access token, or API key + X-User-ID)",
+	"仍有图片在排队，报告尚未最终确定":                        "Some images are still queued; this report is not final",
+	"按位命中 {#n}/{#m} 位 → {a}":                  "{#n}/{#m} positions matched → {a}",
+	"{a}拆分为 {#n} 注，未中奖":                       "{a} bet expanded into {#n} bets, no prize",
+	"{a}拆分为 {#n} 注，最高{b}":                     "{a} bet expanded into {#n} bets, top prize {b}",
+	"({a} + 追加 {b}) × {#n} 倍 = {c}":           "({a} + add-on {b}) × {#n} = {c}",
+	"单注奖金超过 1 万元按 20% 代扣个人偶然所得税 {a}":          "20% income tax withheld on bets over ¥10,000: {a}",
+	"注数过多未逐注计税，单注奖金超过 1 万元的需缴纳 20% 个人偶然所得税":   "Too many bets to compute tax per bet; bets over ¥10,000 are subject to 20% income tax",
+	"API Key 无效":                "Invalid API key",
+	"缺少 API Key (X-API-Key)":    "Missing API key (X-API-Key)",
+	"API Key 配额已用完":             "API key quota exhausted",
+	"配额计数繁忙，请稍后重试":              "Quota counter busy, please retry",
+	"month 格式应为 2006-01":        "month must be in the form 2006-01",
+	"图片不存在":                     "Image not found",
+	"图片链接已过期":                   "Image link has expired",
+	"图片链接签名无效":                  "Invalid image link signature",
+	"上传不存在或已过期":                 "Upload not found or expired",
+	"Upload-Offset 与已收到的字节数不一致": "Upload-Offset does not match the bytes received",
+	"数据超出 Upload-Length":        "Data exceeds Upload-Length",
+	"上传尚未完成（已收到 {#n}/{#m} 字节）":  "Upload incomplete ({#n}/{#m} bytes received)",
+	"缺少 Upload-Length":          "Missing Upload-Length",
+	"Upload-Length 必须大于 0":      "Upload-Length must be greater than 0",
+	"请求体过大，最多 {#n} 字节":          "Request body too large, at most {#n} bytes",
+	"文件过大，最多 {#n} 字节":           "File too large, at most {#n} bytes",
+	"创建上传会话失败":                  "Failed to create upload session",
+	"缺少 Upload-Offset":          "Missing Upload-Offset",
+	"上传中断，请从 Upload-Offset 继续":  "Upload interrupted; resume from Upload-Offset",
+	"请上传名为 'images' 的文件（可多个）":   "Please upload files named 'images' (multiple allowed)",
+	"请上传名为 'archive' 的 ZIP 文件":  "Please upload a ZIP file named 'archive'",
+	"服务端未配置 GEMINI_API_KEY":     "GEMINI_API_KEY is not configured on the server",
+	"读取文件失败":                    "Failed to read file",
+	"读取请求失败":                    "Failed to read request",
+	"不是有效的 ZIP 文件":              "Not a valid ZIP file",
+	"压缩包内没有图片":                  "No images in the archive",
+	"保存批次失败":                    "Failed to save batch",
+	"批次 {id} 不存在":               "Batch {id} not found",
+	"任务不存在":                     "Job not found",
+	"任务 {id} 不存在":               "Job {id} not found",
+	"复核单 {id} 不存在":              "Review {id} not found",
+	"该复核单已处理":                   "This review has already been resolved",
+	"记录不存在":                     "Record not found",
+	"调试记录 {id} 不存在":             "Debug trace {id} not found",
+	"管理员令牌无效":                   "Invalid admin token",
+	"未配置 ADMIN_TOKEN，管理接口未开放":   "ADMIN_TOKEN is not configured; admin endpoints are disabled",
+	"请求过于频繁，请稍后再试":              "Too many requests, please try again later",
+	"人机验证服务暂时不可用，请稍后重试":         "Human verification service is temporarily unavailable, please retry later",
+	"请先完成人机验证":                  "Please complete the human verification challenge first",
+	"访问令牌无效或已过期":                "Access token is invalid or expired",
+	"刷新令牌无效或已过期":                "Refresh token is invalid or expired",
+	"会话不存在":                     "Session not found",
+	"来源地址已被禁止访问":                "Access from this address is denied",
+	"来源地址不在管理接口白名单内":            "This address is not allowed to use the admin API",
+	"当前角色无权执行该操作":               "Your admin role is not allowed to perform this action",
+	"图片未保存":                     "Image was not stored",
+	"图片已清理":                     "Image has been purged",
+	"timeout 格式错误，如 30s":        "Invalid timeout, e.g. 30s",
+	"暂无开奖数据":                    "No draw data yet",
+	"第{#n}期尚未开奖或暂无数据":           "Issue {#n} has not been drawn or no data is available",
+	"暂无奖池数据":                    "No jackpot data yet",
+	"未配置该彩种的开奖日历":               "No draw calendar configured for this game",
+	"排队失败":                      "Failed to enqueue",
+	"AI 识别失败":                   "OCR failed",
+	"AI 识别服务暂时不可用，请稍后重试":        "OCR service is temporarily unavailable, please retry later",
+	"AI 识别服务熔断中":                "OCR service circuit breaker is open",
+	"验奖失败":                      "Verification failed",
+	"无识别结果":                     "No recognition result",
+	"机选失败":                      "Quick pick failed",
+	"处理超时（阶段: {stage}）":         "Request timed out (stage: {stage})",
+	"原图未保存，只能重新验奖":              "The original image was not stored; only re-verification is possible",
+	"未配置第二识别服务":                 "The secondary OCR provider is not configured",
+	"保存扫描记录失败":                  "Failed to save scan record",
+	"服务重启，重新排队":                 "Service restarted, requeued",
+	"AI 识别服务不可用，重新排队":           "OCR service unavailable, requeued",
+	"第 {#n} 次识别失败，稍后重试":         "OCR attempt {#n} failed, will retry later",
+	"人工复核通过":                    "Approved by manual review",
+	"已全部开奖":                     "All draws are settled",
+	"未知的处理阶段: {stage}":          "Unknown processing stage: {stage}",
+	"lottery_index 超出记录中的票数":    "lottery_index is out of range for this record",
+	"识别正确时不需要 corrections":      "corrections must be empty when correct is true",
+	"corrections 只能有一项":         "Only one correction is allowed when lottery_index is given",
+	"保存反馈失败":                    "Failed to save feedback",
+	"since 格式应为 YYYY-MM-DD":     "since must be in YYYY-MM-DD format",
+	"format 应为 plain 或 chat":    "format must be plain or chat",
+	"无法解析号码":                    "Could not parse the numbers",
+	"缺少查找条件":                    "Specify scan_id, serial, numbers or an image",
+	"分桶过多，请缩短时间范围":              "Too many buckets; shorten the time range",
+	"email、phone、webhook 至少填一个": "Provide at least one of email, phone or webhook",
+	"webhook 需要是 https 地址":      "webhook must be an https URL",
+	"每个用户最多 {#n} 条订阅":           "At most {#n} subscriptions per user",
+	"保存订阅失败":                    "Failed to save the subscription",
+	"订阅不存在":                     "Subscription not found",
+	"roi 格式错误":                  "Malformed roi",
+	"roi 最多 {#n} 个区域":           "roi accepts at most {#n} regions",
+	"roi 区域的 x、y 不能为负":          "roi x and y must not be negative",
+	"roi 区域的 w、h 必须大于 0":        "roi w and h must be positive",
+	"async 不支持 winning 和 roi":   "async does not support winning or roi",
+	"最多合并 {#n} 张照片":             "At most {#n} photos can be stitched",
+	"票面彩种“{a}”无法识别，已按{b}判定为{c}": "Unrecognized game “{a}” on the ticket; identified as {c} by {b}",
+	"名称相近":                      "similar name",
+	"号码结构":                      "number layout",
+	"号码结构和期号":                   "number layout and issue format",
+	"开奖号码":                      "Winning numbers",
+	"兑奖地点":                      "Claim at",
+	"兑奖截止":                      "Claim by",
+	"合计中奖":                      "Total prize",
+	"兑奖材料":                      "Bring",
+	"税费":                        "Tax",
+	"彩票原件":                      "the original ticket",
+	"中奖人有效身份证件原件":               "the winner's original valid ID",
+	"本省任意{a}销售网点":               "any {a} retailer in the province",
+	"福彩":                        "Welfare Lottery",
+	"体彩":                        "Sports Lottery",
+	"红球":                        "red",
+	"蓝球":                        "blue",
+	"前区":                        "front",
+	"后区":                        "back",
+	"批量验奖汇总报告":                  "Batch Scan Summary",
+	"生成报告失败":                    "Failed to generate the report",
+	"生成时间":                      "Generated at",
+	"图片":                        "Images",
+	"彩票":                        "Tickets",
+	"号码行":                       "Rows",
+	"中奖彩票":                      "Winning tickets",
+	"中奖行":                       "Winning rows",
+	"未开奖":                       "Not drawn yet",
+	"彩种":                        "Game",
+	"奖级":                        "Level",
+	"中奖行数":                      "Winning rows",
+	"金额":                        "Amount",
+	"本批没有中奖":                    "No winners in this batch",
+	"应付合计":                      "Total payable",
+	"其中本店兑付":                    "Payable at this shop",
+	"其中需到兑奖中心":                  "To be claimed at the center",
+	"需要处理的异常":                   "Exceptions to handle",
+	"类型":                        "Type",
+	"说明":                        "Detail",
+	"编号":                        "Reference",
+	"打印 / 保存为 PDF":              "Print / Save as PDF",
+	"识别失败":                      "OCR failed",
+	"人工复核":                      "Manual review",
+	"排队中":                       "Queued",
+	"校验未通过":                     "Failed validation",
+	"疑似篡改":                      "Possible tampering",
+	"重复扫描":                      "Duplicate scan",
+	"识别不确定":                     "Uncertain reading",
+	"大额票据等待人工复核":                "High-value ticket awaiting manual review",
+	"排队中，尚未识别":                  "Queued, not recognized yet",
+	"此前已扫描过":                    "Scanned before",
+	"{a} × {#n} 倍 = {b}":        "{a} × {#n} = {b}",
+	"省福利彩票发行中心兑奖大厅":             "the claim hall of the provincial Welfare Lottery center",
+	"省体育彩票管理中心兑奖大厅":             "the claim hall of the provincial Sports Lottery center",
+	"建议提前致电中心确认兑奖时间":            "Call the center ahead to confirm claiming hours",
+	"单注奖金 1 万元及以下免征个人所得税":       "Prizes of 10,000 yuan or less per bet are tax-free",
+	"单注奖金超过 1 万元需缴纳 20% 个人偶然所得税，由兑奖中心代扣": "Prizes over 10,000 yuan per bet are subject to 20% income tax, withheld by the claim center",
+}
+
+// traditionalChars 简→繁转换：先替换两岸用词不同的词，再逐字转换本服务文案中出现的字
+var traditionalChars = strings.NewReplacer(
+	"服务端", "伺服器端", "文件", "檔案", "默认", "預設", "信息", "資訊", "网络", "網路",
+	"奖", "獎", "开", "開", "号", "號", "码", "碼", "识", "識", "别", "別", "请", "請", "数", "數",
+	"据", "據", "过", "過", "验", "驗", "种", "種", "张", "張", "错", "錯", "误", "誤", "务", "務",
+	"暂", "暫", "时", "時", "后", "後", "试", "試", "处", "處", "阶", "階", "间", "間", "为", "為",
+	"无", "無", "没", "沒", "传", "傳", "压", "壓", "缩", "縮", "图", "圖", "设", "設", "读", "讀",
+	"记", "記", "录", "錄", "页", "頁", "户", "戶", "标", "標", "复", "複", "单", "單", "选", "選",
+	"机", "機", "胆", "膽", "额", "額", "应", "應", "该", "該", "当", "當", "亿", "億", "万", "萬",
+	"运", "運", "计", "計", "检", "檢", "实", "實", "际", "際", "点", "點", "兑", "兌", "门", "門",
+	"证", "證", "网", "網", "络", "絡", "类", "類", "级", "級", "线", "線", "总", "總", "输", "輸",
+	"参", "參", "须", "須", "长", "長", "这", "這", "个", "個", "对", "對", "来", "來", "发", "發",
+	"现", "現", "与", "與", "经", "經", "体", "體", "区", "區", "报", "報", "并", "並", "确", "確",
+	"认", "認", "态", "態", "获", "獲", "乐", "樂", "双", "雙", "买", "買", "卖", "賣", "销", "銷",
+	"组", "組", "红", "紅", "蓝", "藍", "联", "聯", "关", "關", "闭", "閉", "启", "啟", "书", "書",
+	"飞", "飛", "邮", "郵", "馈", "饋", "调", "調", "败", "敗", "断", "斷", "节", "節", "围", "圍",
+	"内", "內", "满", "滿", "摄", "攝", "审", "審", "队", "隊", "条", "條", "规", "規", "则", "則",
+	"项", "項", "写", "寫", "库", "庫", "达", "達", "变", "變", "换", "換", "称", "稱", "于", "於",
+	"补", "補", "签", "簽", "属", "屬", "饰", "飾", "归", "歸", "视", "視", "导", "導",
+)
+
+func toTraditional(s string) string { return traditionalChars.Replace(s) }
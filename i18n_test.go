@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestCatalogs 从 dir 重新加载语言目录，测试结束后恢复
+func useTestCatalogs(t *testing.T, dir string) {
+	t.Helper()
+	saved := catalogs
+	t.Cleanup(func() { catalogs = saved })
+	if err := loadCatalogs(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessageCatalogT(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	en, tw := catalogFor("en"), catalogFor("zh-TW")
+	tests := []struct {
+		name string
+		cat  *messageCatalog
+		in   string
+		want string
+	}{
+		{"简体原样返回", nil, "未中奖", "未中奖"},
+		{"精确匹配", en, "未中奖，下次好运", "No prize this time. Better luck next time"},
+		{"数字占位原样保留", en, "第3行", "Row 3"},
+		{"文字占位递归翻译", en, "一等奖 5000000元", "1st prize ¥5000000"},
+		{"字面更长的模板优先", en, "中奖: 5元", "Won ¥5"},
+		{"通用分隔逐段翻译", en, "待开奖，本期尚未开奖", "Awaiting draw, This draw has not taken place yet"},
+		{"查不到时原样返回", en, "没有这条文案", "没有这条文案"},
+		{"繁体按字转换", tw, "开奖号码识别错误", "開獎號碼識別錯誤"},
+		{"繁体替换用词", tw, "默认网络", "預設網路"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cat.T(tt.in); got != tt.want {
+				t.Errorf("T(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCatalogs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "en.json", []byte(`{"中奖": "Winner"}`))
+	writeTestFile(t, dir, "ja.json", []byte(`{"未中奖": "はずれ"}`))
+	writeTestFile(t, dir, "zh-CN.json", []byte(`{"未中奖": "不应加载"}`))
+	useTestCatalogs(t, dir)
+
+	tests := []struct {
+		name   string
+		locale string
+		in     string
+		want   string
+	}{
+		{"文件条目覆盖内置目录", "en", "中奖", "Winner"},
+		{"内置条目仍然可用", "en", "未中奖", "No prize"},
+		{"新增语言", "JA", "未中奖", "はずれ"},
+		{"简体文件不加载", "zh-CN", "未中奖", "未中奖"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalogFor(tt.locale).T(tt.in); got != tt.want {
+				t.Errorf("catalogFor(%s).T(%q) = %q, want %q", tt.locale, tt.in, got, tt.want)
+			}
+		})
+	}
+
+	bad := t.TempDir()
+	writeTestFile(t, bad, "fr.json", []byte(`{`))
+	if err := loadCatalogs(bad); err == nil {
+		t.Error("loadCatalogs() with bad JSON should fail")
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	tests := []struct {
+		tag    string
+		want   string
+		wantOK bool
+	}{
+		{"zh-CN", "zh-CN", true},
+		{"zh", "zh-CN", true},
+		{"zh-Hans-CN", "zh-CN", true},
+		{"zh-TW", "zh-TW", true},
+		{"zh_HK", "zh-TW", true},
+		{"zh-Hant", "zh-TW", true},
+		{"zh-MO", "zh-TW", true},
+		{"EN", "en", true},
+		{"en-US", "en", true},
+		{"fr", "", false},
+		{"*", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := matchLocale(tt.tag); got != tt.want || ok != tt.wantOK {
+			t.Errorf("matchLocale(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRequestLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestCatalogs(t, t.TempDir())
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   string
+	}{
+		{"默认简体", "", "", "zh-CN"},
+		{"按 Accept-Language", "", "en-US,en;q=0.9", "en"},
+		{"按 q 值从高到低", "", "en;q=0.5, zh-TW;q=0.8", "zh-TW"},
+		{"跳过不支持的语言", "", "fr-FR, en;q=0.3", "en"},
+		{"q=0 表示不接受", "", "en;q=0, fr", "zh-CN"},
+		{"lang 参数优先", "?lang=zh-HK", "en", "zh-TW"},
+		{"lang 不支持时看请求头", "?lang=fr", "en", "en"},
+		{"en 接口模式默认英文", "?api_mode=en", "zh-CN", "en"},
+		{"en 接口模式也可以指定语言", "?api_mode=en&lang=zh-TW", "", "zh-TW"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept-Language", tt.accept)
+			}
+			if got := requestLocale(c); got != tt.want {
+				t.Errorf("requestLocale() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestCatalogs(t, t.TempDir())
+	r := gin.New()
+	r.Use(localeMiddleware())
+	r.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "本期尚未开奖", "issue": "2025107"})
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"error": "本期尚未开奖"})
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		wantLang string
+		want     string
+	}{
+		{"错误响应按语言翻译", "/missing", "en", "en", "This draw has not taken place yet"},
+		{"繁体", "/missing", "zh-TW", "zh-TW", "本期尚未開獎"},
+		{"简体不翻译", "/missing", "", "zh-CN", "本期尚未开奖"},
+		{"正常响应不改写", "/ok", "en", "en", "本期尚未开奖"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Language", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Header().Get("Content-Language"); got != tt.wantLang {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLang)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", w.Body.String(), err)
+			}
+			if body["error"] != tt.want {
+				t.Errorf("error = %q, want %q", body["error"], tt.want)
+			}
+			if tt.path == "/missing" && body["issue"] != "2025107" {
+				t.Errorf("other fields lost: %v", body)
+			}
+		})
+	}
+}
+
+// 翻译结果和卡片时返回副本，缓存里的原结果不能被改写
+func TestLocalizeResults(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	en := catalogFor("en")
+	in := []VerificationResult{{
+		Details:  []ResultDetail{{RowIndex: 1, Status: "未中奖"}},
+		Warnings: []string{"票据校验未通过"},
+		Sections: []VerificationResult{{Details: []ResultDetail{{Status: "待开奖"}}}},
+	}}
+	out := localizeResults(en, in)
+	cards := localizeCards(en, CardResponse{Summary: "共识别 2 张彩票，本次未中奖", Cards: []ResultCard{{Rows: []CardRow{{Label: "第1行", Status: "未中奖"}}}}})
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"逐行状态", out[0].Details[0].Status, "No prize"},
+		{"提示", out[0].Warnings[0], "Ticket validation failed"},
+		{"附加玩法", out[0].Sections[0].Details[0].Status, "Awaiting draw"},
+		{"卡片摘要", cards.Summary, "2 tickets recognized, no prize this time"},
+		{"卡片行", cards.Cards[0].Rows[0].Label, "Row 1"},
+		{"原结果不变", in[0].Details[0].Status + in[0].Warnings[0], "未中奖票据校验未通过"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+	if got := localizeResults(nil, in); &got[0] != &in[0] {
+		t.Error("localizeResults(nil) should return the input")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ==========================================
+// IMAGES: 原图与缩略图存储
+// ==========================================
+
+// imageStore 按内容哈希存放图片：data/images/ab/abcdef....img 与 ..._thumb.jpg
+// STORE_IMAGES=true 时才保存原图（默认不保留用户照片）；THUMBNAIL_SIZE 为缩略图长边像素
+type imageStore struct {
+	dir       string
+	enabled   bool
+	thumbSize int
+}
+
+var images = &imageStore{}
+
+func newImageStore(dir string) *imageStore {
+	return &imageStore{
+		dir:       dir,
+		enabled:   envBool("STORE_IMAGES", false),
+		thumbSize: envInt("THUMBNAIL_SIZE", 320),
+	}
+}
+
+func (s *imageStore) path(hash, suffix string) string {
+	return filepath.Join(s.dir, hash[:2], hash+suffix)
+}
+
+// OriginalPath / ThumbnailPath 文件不存在时返回空字符串
+func (s *imageStore) OriginalPath(hash string) string { return s.existing(hash, ".img") }
+
+func (s *imageStore) ThumbnailPath(hash string) string { return s.existing(hash, "_thumb.jpg") }
+
+func (s *imageStore) existing(hash, suffix string) string {
+	if s.dir == "" || len(hash) < 2 {
+		return ""
+	}
+	p := s.path(hash, suffix)
+	if _, err := os.Stat(p); err != nil {
+		return ""
+	}
+	return p
+}
+
+// Save 保存原图并在后台生成缩略图；同一张图只存一份
+func (s *imageStore) Save(hash string, fileBytes []byte) {
+	if !s.enabled || s.dir == "" || len(hash) < 2 || len(fileBytes) == 0 {
+		return
+	}
+	if s.OriginalPath(hash) != "" {
+		return
+	}
+	p := s.path(hash, ".img")
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		log.Printf("保存图片失败: %v", err)
+		return
+	}
+	tmp := p + ".tmp"
+	if err := writeSealedFile(tmp, fileBytes); err != nil {
+		log.Printf("保存图片失败: %v", err)
+		return
+	}
+	os.Rename(tmp, p)
+
+	go func() {
+		thumb, err := makeThumbnail(fileBytes, s.thumbSize)
+		if err != nil {
+			log.Printf("生成缩略图失败 [%s]: %v", hash[:12], err)
+			return
+		}
+		tp := s.path(hash, "_thumb.jpg")
+		if err := writeSealedFile(tp+".tmp", thumb); err != nil {
+			log.Printf("保存缩略图失败: %v", err)
+			return
+		}
+		os.Rename(tp+".tmp", tp)
+	}()
+}
+
+// makeThumbnail 按长边缩放到 size 像素，输出 JPEG
+func makeThumbnail(fileBytes []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, err
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resample(src, w, h), &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resample 区域平均采样缩放到 w×h
+func resample(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0, sy1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx0, sx1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var r, g, bl, n uint64
+			for sy := sy0; sy < max(sy1, sy0+1); sy++ {
+				for sx := sx0; sx < max(sx1, sx0+1); sx++ {
+					cr, cg, cb, _ := src.At(sx, sy).RGBA()
+					r, g, bl, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), 0xffff})
+		}
+	}
+	return dst
+}
+
+// perceptualHash 64 位 dHash：缩到 9×8 灰度图，逐行比较相邻像素明暗。
+// 同一张彩票重新拍照（轻微角度、光线、压缩差异）时汉明距离很小，而 SHA 会完全不同
+func perceptualHash(fileBytes []byte) (uint64, bool) {
+	src, _, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return 0, false
+	}
+	small := resample(src, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luma(small, x, y) > luma(small, x+1, y) {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return hash, true
+}
+
+func luma(img *image.RGBA, x, y int) uint32 {
+	c := img.RGBAAt(x, y)
+	return 299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)
+}
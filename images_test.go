@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/bits"
+	"testing"
+	"time"
+)
+
+// testPNG 生成 w×h 的渐变 PNG，明暗随坐标变化，便于计算感知哈希
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) % 256), 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name       string
+		w, h, size int
+		wantW      int
+		wantH      int
+	}{
+		{"横图按宽缩放", 1000, 500, 320, 320, 160},
+		{"竖图按高缩放", 300, 1200, 320, 80, 320},
+		{"小图不放大", 200, 100, 320, 200, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, err := makeThumbnail(testPNG(t, tt.w, tt.h), tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantW, tt.wantH)
+			}
+		})
+	}
+	if _, err := makeThumbnail([]byte("not an image"), 320); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestImageStoreSave(t *testing.T) {
+	const hash = "abcdef0123456789"
+	tests := []struct {
+		name      string
+		enabled   bool
+		data      []byte
+		wantImage bool
+		wantThumb bool
+	}{
+		{"默认不保存", false, testPNG(t, 40, 30), false, false},
+		{"保存原图与缩略图", true, testPNG(t, 40, 30), true, true},
+		{"不是图片只存原图", true, []byte("raw bytes"), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &imageStore{dir: t.TempDir(), enabled: tt.enabled, thumbSize: 16}
+			s.Save(hash, tt.data)
+			if got := s.OriginalPath(hash) != ""; got != tt.wantImage {
+				t.Errorf("original saved = %v, want %v", got, tt.wantImage)
+			}
+			// 缩略图在后台生成
+			deadline := time.Now().Add(time.Second)
+			for s.ThumbnailPath(hash) == "" && tt.wantThumb && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if !tt.wantThumb {
+				time.Sleep(20 * time.Millisecond)
+			}
+			if got := s.ThumbnailPath(hash) != ""; got != tt.wantThumb {
+				t.Errorf("thumbnail saved = %v, want %v", got, tt.wantThumb)
+			}
+		})
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	orig := testPNG(t, 90, 80)
+	src, _, err := image.Decode(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recompressed bytes.Buffer
+	jpeg.Encode(&recompressed, src, &jpeg.Options{Quality: 60})
+
+	// 左右翻转后明暗方向相反
+	flipped := image.NewRGBA(src.Bounds())
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 90; x++ {
+			flipped.Set(89-x, y, src.At(x, y))
+		}
+	}
+	var flippedJPEG bytes.Buffer
+	jpeg.Encode(&flippedJPEG, flipped, &jpeg.Options{Quality: 90})
+
+	base, ok := perceptualHash(orig)
+	if !ok {
+		t.Fatal("hash failed")
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		maxDist int
+		minDist int
+		ok      bool
+	}{
+		{"同一张图", orig, 0, 0, true},
+		{"重新压缩", recompressed.Bytes(), 6, 0, true},
+		{"不同的图", flippedJPEG.Bytes(), 64, 20, true},
+		{"不是图片", []byte("raw"), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ok := perceptualHash(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if d := bits.OnesCount64(base ^ h); d > tt.maxDist || d < tt.minDist {
+				t.Errorf("distance = %d, want %d..%d", d, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
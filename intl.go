@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// INTL: 海外部署的 en 接口模式
+// ==========================================
+
+// en 模式在翻译之外还调整响应结构，便于同一套引擎对接其他市场的彩票：
+// 彩种以代码（ssq / dlt / pl5）代替中文名，金额附带币种，去掉只适用于中国体彩/福彩的兑奖指引。
+// 整个部署用 API_MODE=en 开启，也可以单个请求带 ?api_mode=en
+
+const apiModeEN = "en"
+
+// apiModeOf ?api_mode= 优先，其次是部署级的 API_MODE
+func apiModeOf(c *gin.Context) string {
+	if m := strings.ToLower(strings.TrimSpace(c.Query("api_mode"))); m != "" {
+		return m
+	}
+	return strings.ToLower(strings.TrimSpace(os.Getenv("API_MODE")))
+}
+
+// apiCurrency en 模式下金额的币种，默认 CNY，可用 API_CURRENCY 覆盖
+func apiCurrency() string {
+	if cur := strings.TrimSpace(os.Getenv("API_CURRENCY")); cur != "" {
+		return strings.ToUpper(cur)
+	}
+	return "CNY"
+}
+
+// internationalize 把验奖结果转换为 en 模式的结构，返回新切片（原结果可能与缓存共用）
+func internationalize(results []VerificationResult) []VerificationResult {
+	out := make([]VerificationResult, len(results))
+	currency := apiCurrency()
+	for i, res := range results {
+		res.OCRData.Type = gameCode(res.OCRData.Type)
+		res.Currency = currency
+		res.Claim = nil
+		if len(res.Sections) > 0 {
+			res.Sections = internationalize(res.Sections)
+		}
+		out[i] = res
+	}
+	return out
+}
+
+// presentResults 输出前按请求的语言和接口模式处理验奖结果
+func presentResults(c *gin.Context, results []VerificationResult) []VerificationResult {
+	results = localizeResults(catalogOf(c), results)
+	if apiModeOf(c) == apiModeEN {
+		results = internationalize(results)
+	}
+	return results
+}
+
+// presentCards 卡片的对应处理；卡片标题已翻译，这里只补币种、去掉兑奖指引
+func presentCards(c *gin.Context, resp CardResponse) CardResponse {
+	resp = localizeCards(catalogOf(c), resp)
+	if apiModeOf(c) != apiModeEN {
+		return resp
+	}
+	resp.Currency = apiCurrency()
+	cards := make([]ResultCard, len(resp.Cards))
+	for i, card := range resp.Cards {
+		card.Claim = nil
+		cards[i] = card
+	}
+	resp.Cards = cards
+	return resp
+}
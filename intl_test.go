@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGameCode(t *testing.T) {
+	loadTestGames(t)
+	tests := []struct {
+		game, want string
+	}{
+		{"双色球", "ssq"},
+		{"中国福利彩票双色球", "ssq"},
+		{"大乐透", "dlt"},
+		{"排列5", "pl5"},
+		{"排列3", "pl3"},
+		{"未知彩种", "未知彩种"},
+	}
+	for _, tt := range tests {
+		if got := gameCode(tt.game); got != tt.want {
+			t.Errorf("gameCode(%q) = %q, want %q", tt.game, got, tt.want)
+		}
+	}
+}
+
+func TestAPIModeOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name, query, env, want string
+	}{
+		{"默认", "", "", ""},
+		{"部署级", "", "en", apiModeEN},
+		{"请求参数优先", "?api_mode=EN", "cn", apiModeEN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_MODE", tt.env)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+			if got := apiModeOf(c); got != tt.want {
+				t.Errorf("apiModeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// en 模式换成彩种代码、附带币种、去掉兑奖指引，附加玩法同样处理，且不改动原结果
+func TestInternationalize(t *testing.T) {
+	t.Setenv("API_CURRENCY", "usd")
+	in := []VerificationResult{{
+		OCRData:  LotteryData{Type: "双色球"},
+		Claim:    &ClaimGuide{},
+		Sections: []VerificationResult{{OCRData: LotteryData{Type: "大乐透"}, Claim: &ClaimGuide{}}},
+	}}
+	out := internationalize(in)
+	tests := []struct {
+		name     string
+		res      VerificationResult
+		wantType string
+	}{
+		{"主玩法", out[0], "ssq"},
+		{"附加玩法", out[0].Sections[0], "dlt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.res.OCRData.Type != tt.wantType || tt.res.Currency != "USD" || tt.res.Claim != nil {
+				t.Errorf("got type=%q currency=%q claim=%v", tt.res.OCRData.Type, tt.res.Currency, tt.res.Claim)
+			}
+		})
+	}
+	if in[0].OCRData.Type != "双色球" || in[0].Claim == nil {
+		t.Error("internationalize 改动了原结果")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// IPACCESS: 按来源 IP 放行 / 拒绝
+// ==========================================
+
+// 直接暴露在公网的部署可以在 data/ip_access.json 里限制来源：
+//
+//	{"admin_allow": ["10.0.0.0/8", "203.0.113.7"], "deny": ["198.51.100.0/24"]}
+//
+// admin_allow 非空时，管理接口只接受列表内的地址；deny 中的地址访问任何接口都返回 403。
+// 单个地址可省略前缀长度。修改文件后调用 POST /api/v1/admin/reload 或向进程发送 SIGHUP 即可生效。
+// 客户端地址取 TCP 连接的对端地址；服务在反向代理之后时，把代理地址配置到 TRUSTED_PROXIES（逗号分隔），
+// 才会采用代理转发的 X-Forwarded-For，否则任何人都能伪造该请求头绕过限制
+
+// IPAccessConfig data/ip_access.json
+type IPAccessConfig struct {
+	AdminAllow []string `json:"admin_allow"`
+	Deny       []string `json:"deny"`
+}
+
+type ipAccessStore struct {
+	mu         sync.RWMutex
+	adminAllow []netip.Prefix
+	deny       []netip.Prefix
+}
+
+var ipAccess = &ipAccessStore{}
+
+// parsePrefixes 解析 CIDR 列表，单个地址按 /32（IPv6 为 /128）处理
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("地址 %q 格式错误", s)
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("网段 %q 格式错误", s)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// Load 读取访问控制名单，文件不存在时不做限制；重新加载时删掉文件即清空原有的两份名单
+func (s *ipAccessStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.mu.Lock()
+		s.adminAllow, s.deny = nil, nil
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cfg IPAccessConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return err
+	}
+	allow, err := parsePrefixes(cfg.AdminAllow)
+	if err != nil {
+		return err
+	}
+	deny, err := parsePrefixes(cfg.Deny)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.adminAllow, s.deny = allow, deny
+	s.mu.Unlock()
+	return nil
+}
+
+func prefixesContain(list []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range list {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func clientAddr(c *gin.Context) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(c.ClientIP())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// Denied 地址在拒绝名单内
+func (s *ipAccessStore) Denied(c *gin.Context) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.deny) == 0 {
+		return false
+	}
+	addr, ok := clientAddr(c)
+	return ok && prefixesContain(s.deny, addr)
+}
+
+// AdminAllowed 未配置管理接口白名单，或地址在白名单内
+func (s *ipAccessStore) AdminAllowed(c *gin.Context) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.adminAllow) == 0 {
+		return true
+	}
+	addr, ok := clientAddr(c)
+	return ok && prefixesContain(s.adminAllow, addr)
+}
+
+// ipDenyFilter 拒绝名单内的地址不能访问任何接口
+func ipDenyFilter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ipAccess.Denied(c) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "来源地址已被禁止访问"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// trustedProxies TRUSTED_PROXIES 配置的反向代理地址，未配置时不信任任何转发头
+func trustedProxies() []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestIPAccess 换成 raw 配置的访问控制名单
+func useTestIPAccess(t *testing.T, raw string) {
+	t.Helper()
+	old := ipAccess
+	t.Cleanup(func() { ipAccess = old })
+	ipAccess = &ipAccessStore{}
+	if err := ipAccess.Load(writeTestFile(t, t.TempDir(), "ip_access.json", []byte(raw))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []string
+		want    string
+		wantErr bool
+	}{
+		{"网段", []string{"10.0.0.0/8"}, "10.0.0.0/8", false},
+		{"单个地址", []string{" 203.0.113.7 "}, "203.0.113.7/32", false},
+		{"IPv6 地址", []string{"2001:db8::1"}, "2001:db8::1/128", false},
+		{"网段按掩码对齐", []string{"192.168.1.9/24"}, "192.168.1.0/24", false},
+		{"地址格式错误", []string{"10.0.0"}, "", true},
+		{"网段格式错误", []string{"10.0.0.0/33"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePrefixes(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrefixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var s []string
+			for _, p := range got {
+				s = append(s, p.String())
+			}
+			if strings.Join(s, ",") != tt.want {
+				t.Errorf("parsePrefixes() = %v, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAccessStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"文件不存在不限制", "", false},
+		{"正常配置", `{"admin_allow": ["10.0.0.0/8"], "deny": ["198.51.100.0/24"]}`, false},
+		{"白名单格式错误", `{"admin_allow": ["10.0.0.0/40"]}`, true},
+		{"拒绝名单格式错误", `{"deny": ["x"]}`, true},
+		{"JSON 格式错误", `{`, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "missing.json")
+			if tt.file != "" {
+				path = writeTestFile(t, dir, string(rune('a'+i))+".json", []byte(tt.file))
+			}
+			if err := (&ipAccessStore{}).Load(path); (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIPAccessFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "root")
+	useTestIPAccess(t, `{"admin_allow": ["10.0.0.0/8", "2001:db8::/32"], "deny": ["198.51.100.0/24"]}`)
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"192.0.2.10"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Use(ipDenyFilter())
+	r.GET("/api/v1/draws", func(c *gin.Context) { c.Status(200) })
+	r.GET("/api/v1/admin/usage", adminOnly(), func(c *gin.Context) { c.Status(200) })
+
+	tests := []struct {
+		name       string
+		path       string
+		remote     string
+		forwarded  string
+		wantStatus int
+	}{
+		{"公开接口不受白名单限制", "/api/v1/draws", "203.0.113.7:5000", "", 200},
+		{"拒绝名单内的地址", "/api/v1/draws", "198.51.100.9:5000", "", 403},
+		{"白名单内访问管理接口", "/api/v1/admin/usage", "10.1.2.3:5000", "", 200},
+		{"IPv6 白名单", "/api/v1/admin/usage", "[2001:db8::5]:5000", "", 200},
+		{"白名单外访问管理接口", "/api/v1/admin/usage", "203.0.113.7:5000", "", 403},
+		{"拒绝名单优先于白名单", "/api/v1/admin/usage", "198.51.100.9:5000", "", 403},
+		{"可信代理转发的地址", "/api/v1/admin/usage", "192.0.2.10:5000", "10.1.2.3", 200},
+		{"可信代理转发的拒绝地址", "/api/v1/draws", "192.0.2.10:5000", "198.51.100.9", 403},
+		{"不可信来源伪造转发头", "/api/v1/admin/usage", "203.0.113.7:5000", "10.1.2.3", 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Admin-Token", "root")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"", ""},
+		{"10.0.0.1", "10.0.0.1"},
+		{" 10.0.0.1 , 10.0.0.0/24,", "10.0.0.1,10.0.0.0/24"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TRUSTED_PROXIES", tt.env)
+		if got := strings.Join(trustedProxies(), ","); got != tt.want {
+			t.Errorf("trustedProxies(%q) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+// 修改名单后重新加载即可生效
+func TestReloadConfigIPAccess(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	useTestIPAccess(t, `{}`)
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "198.51.100.9:5000"
+	if ipAccess.Denied(c) {
+		t.Fatal("denied before reload")
+	}
+	writeTestFile(t, dataDir(), "ip_access.json", []byte(`{"deny": ["198.51.100.9"]}`))
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if !ipAccess.Denied(c) {
+		t.Error("not denied after reload")
+	}
+}
+
+// 删掉配置文件再重新加载，管理员令牌、API Key 和访问名单都不再生效
+func TestReloadConfigRemovedFiles(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_TOKEN", "")
+	useTestIPAccess(t, `{}`)
+	oldAdmins, oldKeys := admins, apiKeys
+	t.Cleanup(func() { admins, apiKeys = oldAdmins, oldKeys })
+	admins, apiKeys = &adminStore{}, &apiKeyStore{keys: map[string]APIKey{}}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "198.51.100.9:5000"
+
+	tests := []struct {
+		name   string
+		file   string
+		raw    string
+		active func() bool
+	}{
+		{"管理员令牌", "admin_tokens.json", `[{"token": "v1", "name": "前台", "role": "viewer"}]`, func() bool {
+			_, ok := admins.Lookup("v1")
+			return ok
+		}},
+		{"API Key", "api_keys.json", `[{"key": "sk_reload", "name": "门店"}]`, func() bool {
+			_, ok := apiKeys.Get("sk_reload")
+			return ok && apiKeys.Enabled()
+		}},
+		{"拒绝名单", "ip_access.json", `{"admin_allow": ["10.0.0.0/8"], "deny": ["198.51.100.9"]}`, func() bool {
+			return ipAccess.Denied(c) || !ipAccess.AdminAllowed(c)
+		}},
+	}
+	for _, tt := range tests {
+		writeTestFile(t, dataDir(), tt.file, []byte(tt.raw))
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if !tt.active() {
+			t.Errorf("%s: not loaded", tt.name)
+		}
+		if err := os.Remove(filepath.Join(dataDir(), tt.file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if tt.active() {
+			t.Errorf("%s: still active after the file was removed", tt.name)
+		}
+	}
+}
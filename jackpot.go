@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// JACKPOT: 奖池滚存
+// ==========================================
+
+// JackpotPoint 某期开奖后的奖池
+type JackpotPoint struct {
+	Issue    string `json:"issue"`
+	DrawDate string `json:"draw_date"`
+	Pool     int64  `json:"pool"`
+	Change   int64  `json:"change"` // 与上一期相比的变化，负数一般表示开出了头奖
+}
+
+// Jackpot 某彩种当前奖池
+type Jackpot struct {
+	Game     string `json:"game"`
+	Issue    string `json:"issue"` // 奖池对应的最近一期
+	DrawDate string `json:"draw_date"`
+	Pool     int64  `json:"pool"`
+	PoolText string `json:"pool_text"`
+	Next     string `json:"next_issue,omitempty"`
+}
+
+// currentJackpot 最近一期带奖池数据的开奖记录
+func currentJackpot(game string) (Jackpot, bool) {
+	for _, d := range draws.History(game) {
+		if d.Pool <= 0 {
+			continue
+		}
+		j := Jackpot{Game: d.Game, Issue: d.Issue, DrawDate: d.DrawDate, Pool: d.Pool, PoolText: formatPool(d.Pool)}
+		if next, ok := calendar.OnSaleAt(game, time.Now()); ok {
+			j.Next = next.Issue
+		}
+		return j, true
+	}
+	return Jackpot{}, false
+}
+
+// formatPool 奖池金额按 "12.35亿元" / "8,650万元" 展示
+func formatPool(n int64) string {
+	switch {
+	case n >= 100000000:
+		return strconv.FormatFloat(float64(n)/1e8, 'f', 2, 64) + "亿元"
+	case n >= 10000:
+		return strings.TrimSuffix(formatYuan(n/10000), "元") + "万元"
+	}
+	return formatYuan(n)
+}
+
+// jackpotHandler GET /api/v1/jackpot?game=ssq，不带 game 时返回全部彩种
+func jackpotHandler(c *gin.Context) {
+	if game := c.Query("game"); game != "" {
+		spec, ok := specOf(game)
+		if !ok {
+			c.JSON(400, gin.H{"error": "不支持的彩种: " + game})
+			return
+		}
+		j, ok := currentJackpot(spec.Name)
+		if !ok {
+			c.JSON(404, gin.H{"error": "暂无奖池数据"})
+			return
+		}
+		c.JSON(200, j)
+		return
+	}
+	names := make([]string, 0, len(gameSpecs))
+	for name := range gameSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []Jackpot{}
+	for _, name := range names {
+		if j, ok := currentJackpot(name); ok {
+			list = append(list, j)
+		}
+	}
+	c.JSON(200, list)
+}
+
+// jackpotHistoryHandler GET /api/v1/jackpot/:game/history?last=100，按期号从新到旧
+func jackpotHistoryHandler(c *gin.Context) {
+	spec, history, ok := statsContext(c)
+	if !ok {
+		return
+	}
+	points := []JackpotPoint{}
+	for _, d := range history {
+		if d.Pool > 0 {
+			points = append(points, JackpotPoint{Issue: d.Issue, DrawDate: d.DrawDate, Pool: d.Pool})
+		}
+	}
+	for i := range points {
+		if i+1 < len(points) {
+			points[i].Change = points[i].Pool - points[i+1].Pool
+		}
+	}
+	respondCacheable(c, gin.H{"game": spec.Name, "points": points})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFormatPool(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{1235000000, "12.35亿元"},
+		{100000000, "1.00亿元"},
+		{86500000, "8,650万元"},
+		{10000, "1万元"},
+		{9999, "9,999元"},
+	}
+	for _, tt := range tests {
+		if got := formatPool(tt.in); got != tt.want {
+			t.Errorf("formatPool(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentJackpot(t *testing.T) {
+	tests := []struct {
+		name  string
+		draws []DrawRecord
+		issue string
+		ok    bool
+	}{
+		{"取最近一期", []DrawRecord{
+			{Game: "双色球", Issue: "2025106", Pool: 1500000000},
+			{Game: "双色球", Issue: "2025107", Pool: 1600000000},
+		}, "2025107", true},
+		{"最近一期没有奖池数据时往前找", []DrawRecord{
+			{Game: "双色球", Issue: "2025106", Pool: 1500000000},
+			{Game: "双色球", Issue: "2025107"},
+		}, "2025106", true},
+		{"没有奖池数据", []DrawRecord{{Game: "双色球", Issue: "2025107"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDraws(t, tt.draws...)
+			j, ok := currentJackpot("双色球")
+			if ok != tt.ok || j.Issue != tt.issue {
+				t.Errorf("currentJackpot() = %+v, %v, want issue %q, %v", j, ok, tt.issue, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJackpotHistoryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025105", Pool: 1500000000},
+		DrawRecord{Game: "双色球", Issue: "2025106"},
+		DrawRecord{Game: "双色球", Issue: "2025107", Pool: 1200000000},
+		DrawRecord{Game: "双色球", Issue: "2025108", Pool: 1300000000},
+	)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/jackpot/ssq/history", nil)
+	c.Params = gin.Params{{Key: "game", Value: "ssq"}}
+	jackpotHistoryHandler(c)
+	if w.Code != 200 {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Points []JackpotPoint `json:"points"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		issue  string
+		change int64
+	}{
+		{"2025108", 100000000},
+		{"2025107", -300000000},
+		{"2025105", 0},
+	}
+	if len(resp.Points) != len(tests) {
+		t.Fatalf("points = %+v, want %d", resp.Points, len(tests))
+	}
+	for i, tt := range tests {
+		if p := resp.Points[i]; p.Issue != tt.issue || p.Change != tt.change {
+			t.Errorf("point %d = %+v, want %s change %d", i, p, tt.issue, tt.change)
+		}
+	}
+}
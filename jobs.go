@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// JOBS: 排队延后处理 (熔断降级)
+// ==========================================
+
+const (
+	JobQueued     = "QUEUED"
+	JobProcessing = "PROCESSING"
+	JobDone       = "DONE"
+	JobFailed     = "FAILED"
+	JobReview     = "REVIEW" // 高额票据交叉核对不一致，等待人工复核
+)
+
+// ScanJob 一次排队中的扫描任务，图片与状态都持久化（见 jobstore.go），重启后继续处理
+type ScanJob struct {
+	ID     string `json:"job_id"`
+	Status string `json:"status"`
+	ScanOrigin
+	Attempts  int                  `json:"attempts"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Results   []VerificationResult `json:"results,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	ReviewID  string               `json:"review_id,omitempty"`
+	// 识别失败后下次重试的时间，见 retryDelay
+	NextAttemptAt time.Time `json:"next_attempt_at,omitempty"`
+	// 处理阶段与切换记录，见 lifecycle.go
+	Stage       string           `json:"stage"`
+	Transitions []ScanTransition `json:"transitions,omitempty"`
+}
+
+type scanJobQueue struct {
+	mu    sync.Mutex // 串行化本进程内对同一任务的读改写
+	store jobStore
+	wake  chan struct{} // 有新任务时提前唤醒处理循环
+}
+
+var jobQueue *scanJobQueue
+
+func newScanJobQueue(dir string) (*scanJobQueue, error) {
+	store, err := newJobStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	q := &scanJobQueue{store: store, wake: make(chan struct{}, 1)}
+
+	// 恢复上次未处理完的任务；仍被其他 worker 领取着的任务不动
+	jobs, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		changed := false
+		if job.Stage == "" {
+			job.advance(stageOfStatus(job), "")
+			changed = true
+		}
+		if job.Status == JobProcessing && store.Claim(job.ID) {
+			job.Status = JobQueued
+			job.advance(ScanReceived, "服务重启，重新排队")
+			changed = true
+			store.Release(job.ID)
+		}
+		if changed {
+			if err := store.Put(job); err != nil {
+				log.Printf("保存任务 %s 失败: %v", job.ID, err)
+			}
+		}
+	}
+	return q, nil
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Enqueue 保存图片并登记一个 QUEUED 任务
+func (q *scanJobQueue) Enqueue(fileBytes []byte, origin ScanOrigin) (*ScanJob, error) {
+	now := time.Now()
+	job := &ScanJob{ID: newJobID(), Status: JobQueued, ScanOrigin: origin, CreatedAt: now, UpdatedAt: now}
+	job.advance(ScanReceived, "")
+	if err := q.store.PutImage(job.ID, fileBytes); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.store.Put(job); err != nil {
+		return nil, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// Get 返回任务快照
+func (q *scanJobQueue) Get(id string) (ScanJob, bool) {
+	job, err := q.store.Get(id)
+	if err != nil {
+		if !errors.Is(err, errJobNotFound) {
+			log.Printf("读取任务 %s 失败: %v", id, err)
+		}
+		return ScanJob{}, false
+	}
+	return *job, true
+}
+
+// pending 已到处理时间的排队任务 ID，按创建时间排序
+func (q *scanJobQueue) pending() []string {
+	ids, err := q.store.Due(time.Now())
+	if err != nil {
+		log.Printf("读取排队任务失败: %v", err)
+	}
+	return ids
+}
+
+func (q *scanJobQueue) update(id string, fn func(job *ScanJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, err := q.store.Get(id)
+	if err != nil {
+		return
+	}
+	fn(job)
+	job.UpdatedAt = time.Now()
+	if err := q.store.Put(job); err != nil {
+		log.Printf("保存任务 %s 失败: %v", id, err)
+	}
+}
+
+// jobMaxAttempts 识别失败的任务最多尝试的次数（熔断期间放回队列不计入）
+func jobMaxAttempts() int { return envInt("JOB_MAX_ATTEMPTS", 5) }
+
+// retryDelay 第 attempts 次失败后的等待时间：JOB_RETRY_BACKOFF（默认 30s）起按 2 倍递增，最长 30 分钟
+func retryDelay(attempts int) time.Duration {
+	d := envDuration("JOB_RETRY_BACKOFF", 30*time.Second)
+	for i := 1; i < attempts && d < 30*time.Minute; i++ {
+		d *= 2
+	}
+	return min(d, 30*time.Minute)
+}
+
+// Run 后台循环：熔断器放行时依次处理排队任务，AI 服务再次失败就停下等下一轮；
+// 新任务入队时立即开始处理，不必等到下一个轮询周期
+func (q *scanJobQueue) Run(apiKey string) {
+	ticker := time.NewTicker(envDuration("OCR_QUEUE_POLL_INTERVAL", 10*time.Second))
+	defer ticker.Stop()
+	for {
+		for _, id := range q.pending() {
+			if !q.claim(id) {
+				continue
+			}
+			err := q.process(id, apiKey)
+			q.store.Release(id)
+			if errors.Is(err, errCircuitOpen) || errors.Is(err, errOCRProvider) {
+				break
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claim 领取任务后重新读取：pending 返回的是快照，其间任务可能已被其他进程处理完并释放锁，
+// 或者失败后推迟了重试，这时放弃领取
+func (q *scanJobQueue) claim(id string) bool {
+	if !q.store.Claim(id) {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, err := q.store.Get(id)
+	if err == nil && job.Status == JobQueued && !readyAt(job).After(time.Now()) {
+		return true
+	}
+	q.store.Release(id)
+	if err == nil && job.Status == JobQueued {
+		// Redis 领取时已把任务移出排队集合，重新保存才能在退避结束后被取出
+		if err := q.store.Put(job); err != nil {
+			log.Printf("保存任务 %s 失败: %v", id, err)
+		}
+	}
+	return false
+}
+
+// fail 标记任务失败并发布 scan.failed
+func (q *scanJobQueue) fail(id, reason string) {
+	var tenant string
+	q.update(id, func(job *ScanJob) {
+		job.Status, job.Error = JobFailed, reason
+		job.advance(ScanFailed, reason)
+		tenant = job.Tenant
+	})
+	onScanFailed(tenant, reason)
+}
+
+func (q *scanJobQueue) process(id, apiKey string) error {
+	fileBytes, err := q.store.Image(id)
+	if err != nil {
+		q.fail(id, "图片丢失: "+err.Error())
+		return err
+	}
+
+	var tenant string
+	q.update(id, func(job *ScanJob) {
+		job.Status = JobProcessing
+		job.Attempts++
+		job.advance(ScanPreprocessing, "")
+		tenant = job.Tenant
+	})
+	ctx := withBillingTenant(context.Background(), tenant)
+	budget := newRequestBudget(ctx)
+	defer budget.Done()
+
+	q.update(id, func(job *ScanJob) { job.advance(ScanOCR, "") })
+	ocrCtx, cancelOCR := budget.Stage(stageOCR)
+	ocrResults, err := recognizeCached(ocrCtx, fileBytes, apiKey)
+	cancelOCR()
+	if errors.Is(err, errCircuitOpen) {
+		// 熔断中，等熔断器放行后再试，不计入尝试次数
+		q.update(id, func(job *ScanJob) {
+			job.Status, job.Attempts = JobQueued, job.Attempts-1
+			job.advance(ScanReceived, "AI 识别服务不可用，重新排队")
+		})
+		return err
+	}
+	if err != nil {
+		reason := "AI 识别失败: " + err.Error()
+		if job, _ := q.Get(id); job.Attempts < jobMaxAttempts() {
+			q.update(id, func(job *ScanJob) {
+				job.Status, job.Error = JobQueued, reason
+				job.NextAttemptAt = time.Now().Add(retryDelay(job.Attempts))
+				job.advance(ScanReceived, fmt.Sprintf("第 %d 次识别失败，稍后重试", job.Attempts))
+			})
+			return err
+		}
+		q.fail(id, reason)
+		return err
+	}
+
+	q.update(id, func(job *ScanJob) { job.advance(ScanVerifying, "") })
+	results, err := verifyAll(budget, ocrResults)
+	if err != nil {
+		q.fail(id, "验奖失败: "+err.Error())
+		return err
+	}
+	inspectImage(fileBytes).applyAll(results)
+	job, _ := q.Get(id)
+	review, err := ensembleGate(ctx, job.ScanOrigin, fileBytes, ocrResults, results)
+	if err != nil {
+		q.fail(id, err.Error())
+		return err
+	}
+	if review != nil {
+		reviewQueue.SetJob(review.ID, id)
+		q.update(id, func(job *ScanJob) {
+			job.Status, job.ReviewID = JobReview, review.ID
+			job.advance(ScanNeedsReview, "")
+		})
+		q.store.DeleteImage(id)
+		return nil
+	}
+	var finished ScanJob
+	q.update(id, func(job *ScanJob) {
+		job.Status, job.Results, job.Error, job.NextAttemptAt = JobDone, results, "", time.Time{}
+		job.advance(finishStage(results), "")
+		finished = *job
+	})
+	onScanCompleted(finished.ScanOrigin, fileBytes, results)
+	q.store.DeleteImage(id)
+	return nil
+}
+
+// Settle 开奖后用重新验奖的结果替换已完成任务中对应的待开奖结果，返回更新的任务数
+func (q *scanJobQueue) Settle(tenant string, res VerificationResult) int {
+	if q == nil {
+		return 0
+	}
+	key := verifyCacheKey(res.OCRData)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs, err := q.store.List()
+	if err != nil {
+		log.Printf("读取任务失败: %v", err)
+		return 0
+	}
+	updated := 0
+	for _, job := range jobs {
+		if job.Tenant != tenant || job.Status != JobDone {
+			continue
+		}
+		changed := false
+		for i, old := range job.Results {
+			if !old.Pending || verifyCacheKey(old.OCRData) != key {
+				continue
+			}
+			settled := res
+			settled.TicketIndex, settled.OCRData = old.TicketIndex, old.OCRData
+			settled.Warnings, settled.DuplicateOf = old.Warnings, old.DuplicateOf
+			job.Results[i] = settled
+			changed = true
+		}
+		if changed {
+			if job.Stage == ScanPendingDraw && finishStage(job.Results) == ScanDone {
+				job.advance(ScanDone, "已全部开奖")
+			}
+			job.UpdatedAt = time.Now()
+			if err := q.store.Put(job); err != nil {
+				log.Printf("保存任务 %s 失败: %v", job.ID, err)
+			}
+			updated++
+		}
+	}
+	return updated
+}
+
+// asyncOf ?async=true 或表单字段 async：/api/v1/scan 收下图片即返回 202 与 job_id，
+// 识别与验奖在排队任务里完成，客户端轮询 GET /api/v1/jobs/:id 取结果（客户端 SDK 的 ScanFile 即如此）
+func asyncOf(c *gin.Context) bool {
+	v := c.Query("async")
+	if v == "" {
+		v = c.PostForm("async")
+	}
+	return v == "true" || v == "1"
+}
+
+func jobStatusHandler(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	job, ok := jobQueue.Get(id)
+	if !ok {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("任务 %s 不存在", id)})
+		return
+	}
+	if c.Query("format") == "card" && job.Status == JobDone {
+		c.JSON(200, gin.H{"job_id": job.ID, "status": job.Status, "card": presentCards(c, buildCardResponse(job.Results))})
+		return
+	}
+	if c.Query("format") == "slip" && job.Status == JobDone {
+		c.JSON(200, gin.H{"job_id": job.ID, "status": job.Status, "slip": presentSlip(c, job.Results)})
+		return
+	}
+	job.Results, job.Error = presentResults(c, job.Results), catalogOf(c).T(job.Error)
+	job.Transitions = slices.Clone(job.Transitions)
+	for i := range job.Transitions {
+		job.Transitions[i].Note = catalogOf(c).T(job.Transitions[i].Note)
+	}
+	c.JSON(200, job)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ?async=true 收下图片即返回 job_id，识别与验奖留给排队任务
+func TestVerifyHandlerAsync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GEMINI_API_KEY", "test")
+	useTestQueue(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		fields     map[string]string
+		wantStatus int
+	}{
+		{"查询参数", "?async=true", nil, 202},
+		{"表单字段", "", map[string]string{"async": "1"}, 202},
+		{"带 winning", "?async=true", map[string]string{"winning": `{"game": "双色球", "red": ["02","11","15","21","28","33"], "blue": ["07"]}`}, 400},
+		{"带 roi", "?async=true", map[string]string{"roi": `[{"x":0,"y":0,"w":0.5,"h":1}]`}, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("image", "ticket.jpg")
+			fw.Write([]byte("\xff\xd8\xff\xe0async-" + tt.name))
+			for k, v := range tt.fields {
+				mw.WriteField(k, v)
+			}
+			mw.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			c.Request.Header.Set("X-Tenant-ID", "shop-a")
+			verifyHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 202 {
+				return
+			}
+			var resp struct {
+				JobID  string `json:"job_id"`
+				Status string `json:"status"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			job, ok := jobQueue.Get(resp.JobID)
+			if !ok || resp.Status != JobQueued || job.Status != JobQueued || job.Tenant != "shop-a" {
+				t.Errorf("response = %s, job = %+v", w.Body, job)
+			}
+		})
+	}
+}
+
+// 任务完成后 ?format=slip 附带展示单，未完成时照常返回任务状态
+func TestJobStatusHandlerSlip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestQueue(t)
+	queued, err := jobQueue.Enqueue([]byte{1}, ScanOrigin{Tenant: "shop-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := jobQueue.Enqueue([]byte{2}, ScanOrigin{Tenant: "shop-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobQueue.update(done.ID, func(job *ScanJob) {
+		job.Status = JobDone
+		job.Results = []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107"}, TotalPrize: 5 * Yuan}}
+	})
+
+	tests := []struct {
+		name      string
+		id        string
+		wantSlip  bool
+		wantTotal string
+	}{
+		{"已完成", done.ID, true, "5元"},
+		{"排队中", queued.ID, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/jobs/"+tt.id+"?format=slip", nil)
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			jobStatusHandler(c)
+			var resp struct {
+				Status string       `json:"status"`
+				Slip   *DisplaySlip `json:"slip"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != 200 || (resp.Slip != nil) != tt.wantSlip {
+				t.Fatalf("response = %d %s", w.Code, w.Body)
+			}
+			if tt.wantSlip && (resp.Slip.Color != "win" || resp.Slip.Lines[len(resp.Slip.Lines)-1].Value != tt.wantTotal) {
+				t.Errorf("slip = %+v", resp.Slip)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// JOBSTORE: 排队任务的存储后端（本地目录 / Redis）
+// ==========================================
+
+// 默认任务与图片保存在 data/jobs，API 与 worker 进程共用同一个目录时即可分开部署。
+// 多台机器时改用 Redis：
+//
+//	JOBS_BACKEND=file|redis  默认 file
+//	REDIS_URL                如 redis://:password@10.0.0.5:6379/0
+//	REDIS_PREFIX             键前缀，默认 lottery:
+//
+// 任务元数据与图片照常按 DATA_ENCRYPTION_KEY 加密。同一个任务同时只会被一个 worker 领取：
+// 目录后端用 <id>.lock 文件，Redis 后端用有序集合原子领取；领取后超过 JOB_LEASE（默认 10m）
+// 仍未完成的任务视为 worker 已崩溃，重新放回队列
+
+var errJobNotFound = errors.New("任务不存在")
+
+// jobStore 任务存储后端
+type jobStore interface {
+	Get(id string) (*ScanJob, error)
+	// Put 保存任务；状态为 QUEUED 的任务在 NextAttemptAt 之后可被领取
+	Put(job *ScanJob) error
+	List() ([]*ScanJob, error)
+	PutImage(id string, data []byte) error
+	Image(id string) ([]byte, error)
+	DeleteImage(id string)
+	// Due 已到处理时间的排队任务，按创建时间排序
+	Due(now time.Time) ([]string, error)
+	// Claim 领取任务，返回 false 表示已被其他 worker 领取
+	Claim(id string) bool
+	Release(id string)
+}
+
+func jobLease() time.Duration { return envDuration("JOB_LEASE", 10*time.Minute) }
+
+// newJobStore 按 JOBS_BACKEND 选择后端
+func newJobStore(dir string) (jobStore, error) {
+	switch strings.ToLower(os.Getenv("JOBS_BACKEND")) {
+	case "", "file":
+		return newFileJobStore(dir)
+	case "redis":
+		client, err := redisClient()
+		if err != nil {
+			return nil, err
+		}
+		return &redisJobStore{rdb: client, prefix: redisPrefix()}, nil
+	default:
+		return nil, fmt.Errorf("未知的 JOBS_BACKEND: %s", os.Getenv("JOBS_BACKEND"))
+	}
+}
+
+// redisClient 按 REDIS_URL 连接 Redis
+func redisClient() (*redis.Client, error) {
+	url := strings.TrimSpace(os.Getenv("REDIS_URL"))
+	if url == "" {
+		return nil, fmt.Errorf("使用 Redis 需要配置 REDIS_URL")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_URL 格式错误: %v", err)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("连接 Redis 失败: %v", err)
+	}
+	return client, nil
+}
+
+func redisPrefix() string {
+	if p := os.Getenv("REDIS_PREFIX"); p != "" {
+		return p
+	}
+	return "lottery:"
+}
+
+// readyAt 排队任务可以被领取的时间
+func readyAt(job *ScanJob) time.Time {
+	if job.NextAttemptAt.IsZero() {
+		return job.CreatedAt
+	}
+	return job.NextAttemptAt
+}
+
+// ------------------------------------------
+// 本地目录
+// ------------------------------------------
+
+type fileJobStore struct {
+	dir string
+}
+
+func newFileJobStore(dir string) (*fileJobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileJobStore{dir: dir}, nil
+}
+
+func (s *fileJobStore) imagePath(id string) string { return filepath.Join(s.dir, id+".img") }
+func (s *fileJobStore) metaPath(id string) string  { return filepath.Join(s.dir, id+".json") }
+func (s *fileJobStore) lockPath(id string) string  { return filepath.Join(s.dir, id+".lock") }
+
+func (s *fileJobStore) Get(id string) (*ScanJob, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, errJobNotFound
+	}
+	raw, err := readSealedFile(s.metaPath(id))
+	if os.IsNotExist(err) {
+		return nil, errJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job ScanJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Put 先写临时文件再改名，避免崩溃时留下半截 JSON
+func (s *fileJobStore) Put(job *ScanJob) error {
+	raw, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.metaPath(job.ID) + ".tmp"
+	if err := writeSealedFile(tmp, raw); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.metaPath(job.ID))
+}
+
+func (s *fileJobStore) List() ([]*ScanJob, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ScanJob, 0, len(files))
+	for _, f := range files {
+		job, err := s.Get(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			log.Printf("跳过损坏的任务文件 %s: %v", f, err)
+			continue
+		}
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+func (s *fileJobStore) PutImage(id string, data []byte) error {
+	return writeSealedFile(s.imagePath(id), data)
+}
+
+func (s *fileJobStore) Image(id string) ([]byte, error) { return readSealedFile(s.imagePath(id)) }
+func (s *fileJobStore) DeleteImage(id string)           { os.Remove(s.imagePath(id)) }
+
+func (s *fileJobStore) Due(now time.Time) ([]string, error) {
+	jobs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var list []*ScanJob
+	for _, job := range jobs {
+		if job.Status == JobQueued && !readyAt(job).After(now) {
+			list = append(list, job)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	ids := make([]string, len(list))
+	for i, job := range list {
+		ids[i] = job.ID
+	}
+	return ids, nil
+}
+
+// Claim 以独占方式创建锁文件；锁文件超过租期视为持有者已退出
+func (s *fileJobStore) Claim(id string) bool {
+	path := s.lockPath(id)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > jobLease() {
+		os.Remove(path)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+	return true
+}
+
+func (s *fileJobStore) Release(id string) { os.Remove(s.lockPath(id)) }
+
+// ------------------------------------------
+// Redis
+// ------------------------------------------
+
+// redisJobStore 键：
+//
+//	<prefix>job:<id>         任务 JSON
+//	<prefix>job:<id>:image   图片
+//	<prefix>jobs             全部任务编号（集合）
+//	<prefix>jobs:ready       排队任务，分数为可领取时间（毫秒）
+//	<prefix>jobs:processing  已领取的任务，分数为租期截止时间（毫秒）
+type redisJobStore struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func (s *redisJobStore) key(parts ...string) string { return s.prefix + strings.Join(parts, ":") }
+
+func redisCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func (s *redisJobStore) Get(id string) (*ScanJob, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	raw, err := s.rdb.Get(ctx, s.key("job", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := atRest.Open(raw)
+	if err != nil {
+		return nil, err
+	}
+	var job ScanJob
+	if err := json.Unmarshal(plain, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *redisJobStore) Put(job *ScanJob) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	sealed, err := atRest.Seal(raw)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	pipe := s.rdb.TxPipeline()
+	pipe.Set(ctx, s.key("job", job.ID), sealed, 0)
+	pipe.SAdd(ctx, s.key("jobs"), job.ID)
+	if job.Status == JobQueued {
+		pipe.ZAdd(ctx, s.key("jobs", "ready"), redis.Z{Score: float64(readyAt(job).UnixMilli()), Member: job.ID})
+	} else {
+		pipe.ZRem(ctx, s.key("jobs", "ready"), job.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisJobStore) List() ([]*ScanJob, error) {
+	ctx, cancel := redisCtx()
+	ids, err := s.rdb.SMembers(ctx, s.key("jobs")).Result()
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ScanJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.Get(id)
+		if err != nil {
+			continue
+		}
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+func (s *redisJobStore) PutImage(id string, data []byte) error {
+	sealed, err := atRest.Seal(data)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := redisCtx()
+	defer cancel()
+	return s.rdb.Set(ctx, s.key("job", id, "image"), sealed, 0).Err()
+}
+
+func (s *redisJobStore) Image(id string) ([]byte, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	raw, err := s.rdb.Get(ctx, s.key("job", id, "image")).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return atRest.Open(raw)
+}
+
+func (s *redisJobStore) DeleteImage(id string) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	s.rdb.Del(ctx, s.key("job", id, "image"))
+}
+
+// Due 先把租期已过的任务放回排队集合，再取到期的任务
+func (s *redisJobStore) Due(now time.Time) ([]string, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	nowMs := fmt.Sprint(now.UnixMilli())
+	expired, err := s.rdb.ZRangeByScore(ctx, s.key("jobs", "processing"), &redis.ZRangeBy{Min: "-inf", Max: nowMs}).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range expired {
+		if s.rdb.ZRem(ctx, s.key("jobs", "processing"), id).Val() == 1 {
+			log.Printf("任务 %s 租期已过，重新排队", id)
+			s.rdb.ZAdd(ctx, s.key("jobs", "ready"), redis.Z{Score: float64(now.UnixMilli()), Member: id})
+		}
+	}
+	return s.rdb.ZRangeByScore(ctx, s.key("jobs", "ready"), &redis.ZRangeBy{Min: "-inf", Max: nowMs}).Result()
+}
+
+// Claim 从排队集合移除成功的 worker 获得任务
+func (s *redisJobStore) Claim(id string) bool {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	if s.rdb.ZRem(ctx, s.key("jobs", "ready"), id).Val() != 1 {
+		return false
+	}
+	deadline := time.Now().Add(jobLease()).UnixMilli()
+	s.rdb.ZAdd(ctx, s.key("jobs", "processing"), redis.Z{Score: float64(deadline), Member: id})
+	return true
+}
+
+func (s *redisJobStore) Release(id string) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	s.rdb.ZRem(ctx, s.key("jobs", "processing"), id)
+}
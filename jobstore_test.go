@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewJobStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		url     string
+		wantErr string
+	}{
+		{"默认目录", "", "", ""},
+		{"指定目录", "FILE", "", ""},
+		{"Redis 未配置地址", "redis", "", "REDIS_URL"},
+		{"Redis 地址格式错误", "redis", "http://localhost", "REDIS_URL 格式错误"},
+		{"未知后端", "mysql", "", "未知的 JOBS_BACKEND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JOBS_BACKEND", tt.backend)
+			t.Setenv("REDIS_URL", tt.url)
+			store, err := newJobStore(filepath.Join(t.TempDir(), "jobs"))
+			if tt.wantErr == "" {
+				if _, ok := store.(*fileJobStore); err != nil || !ok {
+					t.Errorf("newJobStore() = %T, %v, want file store", store, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newJobStore() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		backoff  string
+		attempts int
+		want     time.Duration
+	}{
+		{"", 1, 30 * time.Second},
+		{"", 2, time.Minute},
+		{"", 4, 4 * time.Minute},
+		{"", 20, 30 * time.Minute},
+		{"10s", 1, 10 * time.Second},
+		{"10s", 3, 40 * time.Second},
+		{"1h", 1, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv("JOB_RETRY_BACKOFF", tt.backoff)
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) with backoff %q = %v, want %v", tt.attempts, tt.backoff, got, tt.want)
+		}
+	}
+}
+
+func TestFileJobStore(t *testing.T) {
+	store, err := newFileJobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, job := range []*ScanJob{
+		{ID: "late", Status: JobQueued, CreatedAt: now.Add(-time.Minute)},
+		{ID: "early", Status: JobQueued, CreatedAt: now.Add(-time.Hour)},
+		{ID: "backoff", Status: JobQueued, CreatedAt: now.Add(-2 * time.Hour), NextAttemptAt: now.Add(time.Minute)},
+		{ID: "retry", Status: JobQueued, CreatedAt: now.Add(-30 * time.Minute), NextAttemptAt: now.Add(-time.Second)},
+		{ID: "done", Status: JobDone, CreatedAt: now.Add(-3 * time.Hour)},
+	} {
+		if err := store.Put(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("到期的排队任务按创建时间排序", func(t *testing.T) {
+		ids, err := store.Due(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(ids, ","); got != "early,retry,late" {
+			t.Errorf("Due() = %s", got)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		tests := []struct {
+			id      string
+			wantErr error
+		}{
+			{"early", nil},
+			{"missing", errJobNotFound},
+			{"", errJobNotFound},
+			{"../early", errJobNotFound},
+			{"early.json", errJobNotFound},
+		}
+		for _, tt := range tests {
+			job, err := store.Get(tt.id)
+			if err != tt.wantErr || (err == nil && job.ID != tt.id) {
+				t.Errorf("Get(%q) = %v, %v, want error %v", tt.id, job, err, tt.wantErr)
+			}
+		}
+	})
+
+	t.Run("同一任务只能被领取一次", func(t *testing.T) {
+		t.Setenv("JOB_LEASE", "1h")
+		steps := []struct {
+			name   string
+			before func()
+			want   bool
+		}{
+			{"第一次领取", nil, true},
+			{"已被领取", nil, false},
+			{"释放后可再次领取", func() { store.Release("early") }, true},
+			{"租期过后视为持有者已退出", func() {
+				old := time.Now().Add(-2 * time.Hour)
+				os.Chtimes(store.lockPath("early"), old, old)
+			}, true},
+		}
+		for _, st := range steps {
+			if st.before != nil {
+				st.before()
+			}
+			if got := store.Claim("early"); got != st.want {
+				t.Errorf("%s: Claim() = %v, want %v", st.name, got, st.want)
+			}
+		}
+		store.Release("early")
+	})
+
+	t.Run("图片", func(t *testing.T) {
+		if err := store.PutImage("early", []byte("img")); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Image("early"); err != nil || string(got) != "img" {
+			t.Errorf("Image() = %q, %v", got, err)
+		}
+		store.DeleteImage("early")
+		if _, err := store.Image("early"); !os.IsNotExist(err) {
+			t.Errorf("Image() after delete error = %v", err)
+		}
+	})
+
+	// 列表跳过损坏的任务文件
+	writeTestFile(t, store.dir, "broken.json", []byte("{"))
+	jobs, err := store.List()
+	if err != nil || len(jobs) != 5 {
+		t.Errorf("List() = %d jobs, %v, want 5", len(jobs), err)
+	}
+}
+
+// 识别失败按退避时间重新排队，达到 JOB_MAX_ATTEMPTS 后标记失败
+func TestScanJobRetry(t *testing.T) {
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", t.TempDir())
+	t.Setenv("JOB_MAX_ATTEMPTS", "3")
+	t.Setenv("JOB_RETRY_BACKOFF", "10s")
+	useTestQueue(t)
+	job, err := jobQueue.Enqueue([]byte("no-fixture"), ScanOrigin{Tenant: "shop-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		wantStatus string
+		wantDelay  time.Duration
+	}{
+		{"第一次失败", JobQueued, 10 * time.Second},
+		{"第二次失败", JobQueued, 20 * time.Second},
+		{"达到上限", JobFailed, 0},
+	}
+	for i, st := range steps {
+		ocrCache.Purge()
+		start := time.Now()
+		if err := jobQueue.process(job.ID, "test"); err == nil {
+			t.Fatalf("%s: process() succeeded", st.name)
+		}
+		got, _ := jobQueue.Get(job.ID)
+		if got.Status != st.wantStatus || got.Attempts != i+1 || !strings.HasPrefix(got.Error, "AI 识别失败: ") {
+			t.Errorf("%s: status %s attempts %d error %q", st.name, got.Status, got.Attempts, got.Error)
+		}
+		if st.wantDelay == 0 {
+			continue
+		}
+		if d := got.NextAttemptAt.Sub(start); d < st.wantDelay || d > st.wantDelay+time.Second {
+			t.Errorf("%s: next attempt in %v, want %v", st.name, d, st.wantDelay)
+		}
+		// 退避期间不会被取出，到期后才会
+		if due := jobQueue.pending(); len(due) != 0 {
+			t.Errorf("%s: pending() = %v during backoff", st.name, due)
+		}
+		if ids, _ := jobQueue.store.Due(got.NextAttemptAt); len(ids) != 1 {
+			t.Errorf("%s: Due(next attempt) = %v", st.name, ids)
+		}
+	}
+}
+
+// 领取时任务已被其他进程处理完，或者又推迟了重试，都不再处理
+func TestScanJobQueueClaim(t *testing.T) {
+	useTestQueue(t)
+	now := time.Now()
+	tests := []struct {
+		name string
+		job  ScanJob
+		want bool
+	}{
+		{"到期的排队任务", ScanJob{Status: JobQueued, CreatedAt: now.Add(-time.Minute)}, true},
+		{"已处理完", ScanJob{Status: JobDone, CreatedAt: now.Add(-time.Minute)}, false},
+		{"正在处理", ScanJob{Status: JobProcessing, CreatedAt: now.Add(-time.Minute)}, false},
+		{"退避中", ScanJob{Status: JobQueued, CreatedAt: now.Add(-time.Minute), NextAttemptAt: now.Add(time.Minute)}, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := tt.job
+			job.ID = "job" + string(rune('0'+i))
+			if err := jobQueue.store.Put(&job); err != nil {
+				t.Fatal(err)
+			}
+			if got := jobQueue.claim(job.ID); got != tt.want {
+				t.Fatalf("claim() = %v, want %v", got, tt.want)
+			}
+			// 放弃领取时释放锁，任务原样保留
+			if !tt.want {
+				if !jobQueue.store.Claim(job.ID) {
+					t.Error("lock not released")
+				}
+				if got, _ := jobQueue.Get(job.ID); got.Status != job.Status || !got.NextAttemptAt.Equal(job.NextAttemptAt) {
+					t.Errorf("job = %+v", got)
+				}
+			}
+			jobQueue.store.Release(job.ID)
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ==========================================
+// JSON REPAIR: 修复模型输出中常见的 JSON 格式问题
+// ==========================================
+
+// 模型偶尔输出不合法的 JSON，按出现频率依次是：数组末尾多余的逗号、单引号或中文引号、
+// 键名不带引号、中文全角标点（，：【】）、号码写成 01 这样的前导零数字，以及输出被截断。
+// parseOCRText 直接解析失败时先做一遍 repairJSON，仍失败再用 tolerantDecode 逐张票解码、
+// 按字段纠正类型（期号写成数字、倍数写成字符串等），只丢弃实在无法解析的票
+
+// ocrParseError 修复后仍无法解析的模型输出；?debug=1 且有权限时细节随响应返回，见 parseErrorDetail
+type ocrParseError struct {
+	Err     error
+	Message string
+	Offset  int64  // 出错位置在原始文本（去掉代码块标记后）中的字节偏移，未知时为 -1
+	Snippet string // 出错位置前后的片段，⟨!⟩ 标出位置
+	Steps   []string
+}
+
+func (e *ocrParseError) Error() string { return e.Message }
+func (e *ocrParseError) Unwrap() error { return e.Err }
+
+// newOCRParseError 从 encoding/json 的错误中取出偏移并截取前后各 40 字节
+func newOCRParseError(text string, err error, steps []string) *ocrParseError {
+	pe := &ocrParseError{Err: err, Message: err.Error(), Offset: -1, Steps: steps}
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		pe.Offset = syn.Offset
+	case errors.As(err, &typ):
+		// 默认的错误信息带整个结构体定义，对接方看不懂
+		pe.Offset = typ.Offset
+		pe.Message = fmt.Sprintf("字段 %s 应为%s，实际为 %s", typ.Field, jsonKindName(typ.Type.Kind()), typ.Value)
+	}
+	if pe.Offset < 0 || pe.Offset > int64(len(text)) {
+		return pe
+	}
+	at := int(pe.Offset)
+	from, to := max(at-40, 0), min(at+40, len(text))
+	// 落在多字节字符中间时向外扩到字符边界
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	for at > from && at < len(text) && !utf8.RuneStart(text[at]) {
+		at--
+	}
+	pe.Snippet = text[from:at] + "⟨!⟩" + text[at:to]
+	return pe
+}
+
+func jsonKindName(k reflect.Kind) string {
+	switch k {
+	case reflect.Slice, reflect.Array:
+		return "数组"
+	case reflect.Struct, reflect.Map:
+		return "对象"
+	case reflect.String:
+		return "字符串"
+	case reflect.Bool:
+		return "布尔值"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "数字"
+	}
+	return k.String()
+}
+
+// fullWidthPunct 字符串外的全角标点
+var fullWidthPunct = map[rune]rune{
+	'，': ',', '：': ':', '【': '[', '】': ']', '［': '[', '］': ']', '｛': '{', '｝': '}', '、': ',',
+}
+
+// stringQuotes 开引号到闭引号
+var stringQuotes = map[rune]rune{'"': '"', '\'': '\'', '“': '”', '‘': '’'}
+
+// extractJSON 去掉 ```json 包裹与前后的说明文字，只留第一个 [ 或 { 起的部分
+func extractJSON(s string) (string, []string) {
+	var steps []string
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 && !strings.ContainsAny(s[:i], "[{") {
+			s = s[i+1:] // ```json 语言标记
+		}
+		if i := strings.LastIndex(s, "```"); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+		steps = append(steps, "去掉代码块标记")
+	}
+	start := strings.IndexAny(s, "[{")
+	if start > 0 {
+		s = s[start:]
+		steps = append(steps, "去掉 JSON 前的文字")
+	}
+	// 末尾还有括号、引号、逗号的多半是被截断的 JSON 而不是说明文字，留给 repairJSON 处理
+	if end := strings.LastIndexAny(s, "]}"); end >= 0 && end < len(s)-1 && !strings.ContainsAny(s[end+1:], "[{\",'“") {
+		s = s[:end+1]
+		steps = append(steps, "去掉 JSON 后的文字")
+	}
+	return s, steps
+}
+
+// repairJSON 逐字符重写为合法 JSON，返回修复后的文本与做过的修复
+func repairJSON(s string) (string, []string) {
+	s, steps := extractJSON(s)
+	applied := map[string]bool{}
+	note := func(step string) {
+		if !applied[step] {
+			applied[step] = true
+			steps = append(steps, step)
+		}
+	}
+
+	var out bytes.Buffer
+	var stack []byte // 未闭合的 [ {
+	// 截断时回退到最后一个完整的对象之后
+	safeLen, safeDepth := -1, 0
+	pendingComma := false
+	afterValue := false // 上一个写出的是完整的值，紧跟着又是值说明中间缺了逗号
+	valueStart := func() {
+		if afterValue {
+			out.WriteByte(',')
+			note("补上缺失的逗号")
+		}
+	}
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if p, ok := fullWidthPunct[r]; ok {
+			r = p
+			note("全角标点转为半角")
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if pendingComma {
+			pendingComma = false
+			if r == ']' || r == '}' {
+				note("去掉多余的逗号")
+			} else {
+				out.WriteByte(',')
+				afterValue = false
+			}
+		}
+		switch {
+		case r == ',':
+			pendingComma = true
+		case r == '[' || r == '{':
+			valueStart()
+			stack = append(stack, byte(r))
+			out.WriteRune(r)
+			afterValue = false
+		case r == ']' || r == '}':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out.WriteRune(r)
+			afterValue = true
+			if r == '}' {
+				safeLen, safeDepth = out.Len(), len(stack)
+			}
+		case r == ':':
+			out.WriteByte(':')
+			afterValue = false
+		case stringQuotes[r] != 0:
+			valueStart()
+			afterValue = true
+			if r != '"' {
+				note("单引号或中文引号转为双引号")
+			}
+			var closed bool
+			i, closed = writeString(&out, rs, i+1, stringQuotes[r])
+			if !closed {
+				note("补全未闭合的字符串")
+			}
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(",:[]{}\"'“”‘’，：【】［］｛｝、", rs[j]) {
+				j++
+			}
+			if j == i {
+				continue // 落单的闭引号
+			}
+			word := string(rs[i:j])
+			i = j - 1
+			valueStart()
+			afterValue = true
+			switch {
+			case word == "true" || word == "false" || word == "null":
+				out.WriteString(word)
+			case isJSONNumber(word):
+				out.WriteString(word)
+			default:
+				if _, err := strconv.ParseFloat(word, 64); err == nil {
+					note("前导零数字转为字符串")
+				} else {
+					note("给未加引号的键名或取值加上引号")
+				}
+				b, _ := json.Marshal(word)
+				out.Write(b)
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		if safeLen < 0 {
+			note("补全未闭合的括号")
+		} else {
+			out.Truncate(safeLen)
+			stack = stack[:safeDepth]
+			note("输出被截断，丢弃末尾不完整的内容")
+		}
+		for k := len(stack) - 1; k >= 0; k-- {
+			if stack[k] == '[' {
+				out.WriteByte(']')
+			} else {
+				out.WriteByte('}')
+			}
+		}
+	}
+	return out.String(), steps
+}
+
+// writeString 从 rs[i] 起写出一个字符串直到闭引号，返回闭引号的下标与是否正常闭合
+func writeString(out *bytes.Buffer, rs []rune, i int, closing rune) (int, bool) {
+	var sb strings.Builder
+	for ; i < len(rs); i++ {
+		r := rs[i]
+		if r == '\\' && i+1 < len(rs) {
+			// 先还原转义，写出时再统一转义
+			i++
+			switch rs[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'u':
+				if i+4 < len(rs) {
+					if n, err := strconv.ParseUint(string(rs[i+1:i+5]), 16, 32); err == nil {
+						sb.WriteRune(rune(n))
+						i += 4
+						continue
+					}
+				}
+				sb.WriteRune('u')
+			default:
+				sb.WriteRune(rs[i])
+			}
+			continue
+		}
+		if r == closing {
+			b, _ := json.Marshal(sb.String())
+			out.Write(b)
+			return i, true
+		}
+		sb.WriteRune(r)
+	}
+	b, _ := json.Marshal(sb.String())
+	out.Write(b)
+	return len(rs) - 1, false
+}
+
+func isJSONNumber(s string) bool {
+	if !json.Valid([]byte(s)) {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// 字段的期望类型，模型输出的类型不符时按此纠正
+var (
+	rawStringFields = map[string]bool{"type": true, "issue": true, "sale_time": true, "serial": true, "mode": true, "pick_method": true}
+	rawIntFields    = map[string]bool{"multiplier": true}
+	rawBoolFields   = map[string]bool{"add_on": true}
+)
+
+// coerceRaw 递归纠正各字段的类型，返回是否改动过
+func coerceRaw(v any) bool {
+	changed := false
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			switch {
+			case rawStringFields[k]:
+				if _, ok := val.(string); !ok && val != nil {
+					x[k], changed = anyToString(val), true
+				}
+			case rawIntFields[k]:
+				if s, ok := val.(string); ok {
+					n, _ := strconv.Atoi(strings.Trim(strings.TrimSpace(s), "倍xX"))
+					x[k], changed = n, true
+				}
+			case rawBoolFields[k]:
+				if s, ok := val.(string); ok {
+					x[k], changed = s == "true" || s == "是" || s == "追加", true
+				}
+			default:
+				if coerceRaw(val) {
+					changed = true
+				}
+			}
+		}
+	case []any:
+		for _, e := range x {
+			if coerceRaw(e) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// tolerantDecode 逐张票解码：单张票类型不符时纠正后重试，语法错误之前已解出的票全部保留
+func tolerantDecode(s string) ([]RawLotteryData, []string, error) {
+	var steps []string
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var elems []any
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		for dec.More() {
+			var e any
+			if err := dec.Decode(&e); err != nil {
+				steps = append(steps, "跳过语法错误之后的内容")
+				break
+			}
+			elems = append(elems, e)
+		}
+	case json.Delim('{'):
+		var e any
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			return nil, nil, err
+		}
+		elems = append(elems, e)
+	default:
+		return nil, nil, errors.New("不是 JSON 数组或对象")
+	}
+
+	var out []RawLotteryData
+	skipped := 0
+	for _, e := range elems {
+		if coerceRaw(e) {
+			steps = appendOnce(steps, "纠正字段类型")
+		}
+		b, _ := json.Marshal(e)
+		var raw RawLotteryData
+		if err := json.Unmarshal(b, &raw); err != nil {
+			skipped++
+			continue
+		}
+		out = append(out, raw)
+	}
+	if skipped > 0 {
+		steps = append(steps, "丢弃 "+strconv.Itoa(skipped)+" 张无法解析的票")
+	}
+	if len(out) == 0 {
+		return nil, steps, errors.New("没有可解析的票")
+	}
+	return out, steps, nil
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		wantSteps string
+	}{
+		{"合法 JSON", `[{"a": 1}]`, `[{"a": 1}]`, ""},
+		{"代码块包裹", "```json\n[1]\n```", "[1]", "去掉代码块标记"},
+		{"代码块同一行", "```[1]```", "[1]", "去掉代码块标记"},
+		{"前后有说明文字", "识别结果如下：\n[1]\n以上是全部彩票。", "[1]", "去掉 JSON 前的文字,去掉 JSON 后的文字"},
+		{"被截断的输出保留末尾", `[{"a": 1}, {"b": "x`, `[{"a": 1}, {"b": "x`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, steps := extractJSON(tt.in)
+			if got != tt.want || strings.Join(steps, ",") != tt.wantSteps {
+				t.Errorf("extractJSON() = %q %v, want %q %s", got, steps, tt.want, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		want     string
+		wantStep string
+	}{
+		{"多余的逗号", `[{"red": ["01", "02",],},]`, `[{"red":["01","02"]}]`, "去掉多余的逗号"},
+		{"单引号", `[{'type': '双色球'}]`, `[{"type":"双色球"}]`, "单引号或中文引号转为双引号"},
+		{"中文引号", `[{“type”: “双色球”}]`, `[{"type":"双色球"}]`, "单引号或中文引号转为双引号"},
+		{"键名不带引号", `[{type: "双色球", add_on: true}]`, `[{"type":"双色球","add_on":true}]`, "给未加引号的键名或取值加上引号"},
+		{"全角标点", `[{"red"：【"01"，"02"】}]`, `[{"red":["01","02"]}]`, "全角标点转为半角"},
+		{"前导零数字", `[{"red": [01, 02, 33]}]`, `[{"red":["01","02",33]}]`, "前导零数字转为字符串"},
+		{"缺少逗号", `[{"a": 1} {"b": 2}]`, `[{"a":1},{"b":2}]`, "补上缺失的逗号"},
+		{"截断时丢弃不完整的票", `[{"issue": "2025107"}, {"issue": "20251`, `[{"issue":"2025107"}]`, "输出被截断，丢弃末尾不完整的内容"},
+		{"截断在第一张票内", `[{"issue": "2025107"`, `[{"issue":"2025107"}]`, "补全未闭合的括号"},
+		{"未闭合的字符串", `[{"issue": "2025107`, `[{"issue":"2025107"}]`, "补全未闭合的字符串"},
+		{"保留转义", `["a\"b中\n"]`, `["a\"b中\n"]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, steps := repairJSON(tt.in)
+			if got != tt.want {
+				t.Errorf("repairJSON() = %s, want %s", got, tt.want)
+			}
+			if tt.wantStep != "" && !strings.Contains(strings.Join(steps, ","), tt.wantStep) {
+				t.Errorf("steps = %v, want %s", steps, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestTolerantDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantIssue string // 解出的各张票的期号
+		wantStep  string
+		wantErr   bool
+	}{
+		{"期号写成数字", `[{"type": "双色球", "issue": 2025107}]`, "2025107", "纠正字段类型", false},
+		{"倍数与追加写成字符串", `[{"issue": "25107", "tickets": [{"multiplier": "5倍", "add_on": "追加"}]}]`, "25107", "纠正字段类型", false},
+		{"丢弃无法解析的票", `[{"issue": "1", "tickets": "x"}, {"issue": "2"}]`, "2", "丢弃 1 张无法解析的票", false},
+		{"保留语法错误前的票", `[{"issue": "1"}, {"issue": }]`, "1", "跳过语法错误之后的内容", false},
+		{"单个对象", `{"issue": "1"}`, "1", "", false},
+		{"没有可解析的票", `[{"tickets": 1}]`, "", "", true},
+		{"不是数组或对象", `"双色球"`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, steps, err := tolerantDecode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tolerantDecode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var issues []string
+			for _, raw := range list {
+				issues = append(issues, raw.Issue)
+			}
+			if got := strings.Join(issues, ","); got != tt.wantIssue {
+				t.Errorf("issues = %s, want %s", got, tt.wantIssue)
+			}
+			if tt.wantStep != "" && !strings.Contains(strings.Join(steps, ","), tt.wantStep) {
+				t.Errorf("steps = %v, want %s", steps, tt.wantStep)
+			}
+		})
+	}
+}
+
+// 修复后的输出与合法输出解析结果相同
+func TestParseOCRTextRepairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"合法 JSON", `[{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", "11"], "blue": ["07"], "multiplier": 2}]}]`, false},
+		{"代码块内格式错误", "```json\n[{type: '双色球', issue: 2025107, tickets: [{red: [02, 11,], blue: [07], multiplier: \"2倍\"},],},]\n```", false},
+		{"中文标点且被截断", `【{“type”：“双色球”，“issue”：“2025107”，“tickets”：[{“red”：[“02”，“11”]，“blue”：[“07”]，“multiplier”：2}]}，{“type”：“双`, false},
+		{"无法修复", `这张图片里没有彩票`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parseOCRText(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOCRText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(data) != 1 || data[0].Type != "双色球" || data[0].Issue != "2025107" || len(data[0].Tickets) != 1 {
+				t.Fatalf("parseOCRText() = %+v", data)
+			}
+			tk := data[0].Tickets[0]
+			if strings.Join(tk.Red, " ") != "02 11" || strings.Join(tk.Blue, " ") != "07" || tk.Multiplier != 2 {
+				t.Errorf("ticket = %+v", tk)
+			}
+		})
+	}
+}
+
+func TestNewOCRParseError(t *testing.T) {
+	text := `[{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02" "11"]}]}]`
+	tests := []struct {
+		name        string
+		text        string
+		wantOffset  int64
+		wantMessage string
+		wantSnippet string
+		err         error // 为空时取 json.Unmarshal 的错误
+	}{
+		{"语法错误", text, int64(strings.Index(text, `"11"`)) + 1, "invalid character", `["02" "⟨!⟩11"]`, nil},
+		{"类型错误", `[{"issue": "1", "tickets": "x"}]`, 30, "字段 0.tickets 应为数组，实际为 string", `"tickets": "x"⟨!⟩}]`, nil},
+		{"偏移落在中文中间", strings.Repeat("号", 30), 4, "", "号⟨!⟩号", &json.SyntaxError{Offset: 4}},
+		{"偏移超出文本", "[", 9, "", "", &json.SyntaxError{Offset: 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw []RawLotteryData
+			err := tt.err
+			if err == nil {
+				err = json.Unmarshal([]byte(tt.text), &raw)
+			}
+			pe := newOCRParseError(tt.text, err, []string{"去掉多余的逗号"})
+			if pe.Offset != tt.wantOffset || !strings.Contains(pe.Message, tt.wantMessage) {
+				t.Errorf("offset = %d, message = %s, want %d %s", pe.Offset, pe.Message, tt.wantOffset, tt.wantMessage)
+			}
+			if !strings.Contains(pe.Snippet, tt.wantSnippet) || !utf8.ValidString(pe.Snippet) {
+				t.Errorf("snippet = %q, want %q", pe.Snippet, tt.wantSnippet)
+			}
+			if !errors.Is(pe, err) || len(pe.Steps) != 1 {
+				t.Errorf("parse error = %+v", pe)
+			}
+		})
+	}
+
+	// 其他错误没有位置，不附带片段
+	pe := newOCRParseError(text, errors.New("x"), nil)
+	if pe.Offset != -1 || pe.Snippet != "" {
+		t.Errorf("unknown offset = %d, snippet %q", pe.Offset, pe.Snippet)
+	}
+}
+
+// 修复后仍失败时返回带位置的错误，片段取自去掉代码块标记后的文本
+func TestParseOCRTextError(t *testing.T) {
+	_, err := parseOCRText("```json\n[{\"issue\": \"1\", \"tickets\": 1}]\n```")
+	var pe *ocrParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("parseOCRText() error = %v, want *ocrParseError", err)
+	}
+	steps := strings.Join(pe.Steps, ",")
+	if pe.Offset < 0 || !strings.Contains(pe.Snippet, "⟨!⟩") || !strings.HasPrefix(steps, "去掉代码块标记") || !strings.Contains(steps, "修复后仍失败") {
+		t.Errorf("parse error = %+v", pe)
+	}
+}
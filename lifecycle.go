@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// LIFECYCLE: 排队扫描的处理阶段
+// ==========================================
+
+// Status 只区分排队 / 处理中 / 完成，看不出任务卡在哪一步。每个任务另外记录阶段和每次切换的时间：
+//
+//	received → preprocessing → ocr → verifying → done / failed / needs_review / pending_draw
+//
+// AI 服务不可用、识别失败等待重试或服务重启时任务从 ocr / preprocessing 退回 received 重新排队；needs_review 在人工复核后
+// 进入 done，pending_draw（票中有未开奖的期次）在全部开奖后进入 done。切换记录随任务落盘，
+// GET /api/v1/jobs/:id 返回 stage 与 transitions，管理接口 GET /api/v1/admin/jobs?stage=ocr 列出停在某阶段的任务
+
+const (
+	ScanReceived      = "received"
+	ScanPreprocessing = "preprocessing"
+	ScanOCR           = "ocr"
+	ScanVerifying     = "verifying"
+	ScanDone          = "done"
+	ScanFailed        = "failed"
+	ScanNeedsReview   = "needs_review"
+	ScanPendingDraw   = "pending_draw"
+)
+
+// scanTransitions 每个阶段允许进入的下一阶段
+var scanTransitions = map[string][]string{
+	ScanReceived:      {ScanPreprocessing, ScanFailed},
+	ScanPreprocessing: {ScanOCR, ScanReceived, ScanFailed},
+	ScanOCR:           {ScanVerifying, ScanReceived, ScanFailed},
+	ScanVerifying:     {ScanDone, ScanFailed, ScanNeedsReview, ScanPendingDraw},
+	ScanNeedsReview:   {ScanDone, ScanPendingDraw, ScanFailed},
+	ScanPendingDraw:   {ScanDone},
+}
+
+// ScanTransition 一次阶段切换
+type ScanTransition struct {
+	Stage string    `json:"stage"`
+	At    time.Time `json:"at"`
+	Note  string    `json:"note,omitempty"`
+}
+
+// advance 切换到下一阶段并记录时间；不合法的切换照样记录，但打日志便于排查流程遗漏
+func (job *ScanJob) advance(stage, note string) {
+	if job.Stage == stage {
+		return
+	}
+	if job.Stage != "" && !containsString(scanTransitions[job.Stage], stage) {
+		log.Printf("任务 %s 阶段切换异常: %s → %s", job.ID, job.Stage, stage)
+	}
+	job.Stage = stage
+	job.Transitions = append(job.Transitions, ScanTransition{Stage: stage, At: time.Now(), Note: note})
+}
+
+// finishStage 完成验奖后的阶段：还有未开奖的票时为 pending_draw
+func finishStage(results []VerificationResult) string {
+	for _, r := range results {
+		if r.Pending {
+			return ScanPendingDraw
+		}
+	}
+	return ScanDone
+}
+
+// stageOfStatus 升级前保存的任务没有阶段记录，按状态推断
+func stageOfStatus(job *ScanJob) string {
+	switch job.Status {
+	case JobDone:
+		return finishStage(job.Results)
+	case JobFailed:
+		return ScanFailed
+	case JobReview:
+		return ScanNeedsReview
+	}
+	return ScanReceived
+}
+
+// StageSince 任务进入当前阶段的时间
+func (job ScanJob) StageSince() time.Time {
+	if n := len(job.Transitions); n > 0 {
+		return job.Transitions[n-1].At
+	}
+	return job.CreatedAt
+}
+
+// ByStage 停在某阶段的任务，按进入该阶段的时间从早到晚；stage 为空时返回全部未结束的任务
+func (q *scanJobQueue) ByStage(stage string) []ScanJob {
+	jobs, err := q.store.List()
+	if err != nil {
+		log.Printf("读取任务失败: %v", err)
+	}
+	var out []ScanJob
+	for _, job := range jobs {
+		if stage == "" && (job.Stage == ScanDone || job.Stage == ScanFailed) {
+			continue
+		}
+		if stage != "" && job.Stage != stage {
+			continue
+		}
+		snapshot := *job
+		snapshot.Results = nil
+		out = append(out, snapshot)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StageSince().Before(out[j].StageSince()) })
+	return out
+}
+
+// adminJobsHandler GET /api/v1/admin/jobs?stage=ocr 列出任务及其停留时长，不含识别结果
+func adminJobsHandler(c *gin.Context) {
+	stage := c.Query("stage")
+	if stage != "" && stage != ScanDone && stage != ScanFailed && scanTransitions[stage] == nil {
+		c.JSON(400, gin.H{"error": "未知的处理阶段: " + stage})
+		return
+	}
+	jobs := jobQueue.ByStage(stage)
+	items := make([]gin.H, 0, len(jobs))
+	for _, job := range jobs {
+		items = append(items, gin.H{
+			"job_id": job.ID, "tenant": job.Tenant, "status": job.Status, "stage": job.Stage,
+			"since": job.StageSince(), "stuck_seconds": int(time.Since(job.StageSince()).Seconds()),
+			"attempts": job.Attempts, "error": job.Error, "transitions": job.Transitions,
+		})
+	}
+	c.JSON(200, gin.H{"total": len(items), "items": items})
+}
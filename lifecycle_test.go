@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stagesOf 任务经过的阶段，按切换顺序
+func stagesOf(job ScanJob) string {
+	var s []string
+	for _, tr := range job.Transitions {
+		s = append(s, tr.Stage)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestScanJobAdvance(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   string
+		to     []string
+		want   string
+		latest string
+	}{
+		{"正常流程", "", []string{ScanReceived, ScanPreprocessing, ScanOCR, ScanVerifying, ScanDone}, "received,preprocessing,ocr,verifying,done", ScanDone},
+		{"同一阶段不重复记录", "", []string{ScanReceived, ScanReceived, ScanPreprocessing}, "received,preprocessing", ScanPreprocessing},
+		{"重新排队", ScanOCR, []string{ScanReceived}, "received", ScanReceived},
+		{"不合法的切换照样记录", ScanDone, []string{ScanOCR}, "ocr", ScanOCR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := ScanJob{ID: "j1", Stage: tt.from}
+			for _, s := range tt.to {
+				job.advance(s, "")
+			}
+			if got := stagesOf(job); got != tt.want || job.Stage != tt.latest {
+				t.Errorf("stages = %s (current %s), want %s (current %s)", got, job.Stage, tt.want, tt.latest)
+			}
+		})
+	}
+}
+
+func TestStageOfStatus(t *testing.T) {
+	pending := []VerificationResult{{Pending: true}, {}}
+	tests := []struct {
+		status  string
+		results []VerificationResult
+		want    string
+	}{
+		{JobQueued, nil, ScanReceived},
+		{JobProcessing, nil, ScanReceived},
+		{JobDone, []VerificationResult{{}}, ScanDone},
+		{JobDone, pending, ScanPendingDraw},
+		{JobFailed, nil, ScanFailed},
+		{JobReview, nil, ScanNeedsReview},
+	}
+	for _, tt := range tests {
+		if got := stageOfStatus(&ScanJob{Status: tt.status, Results: tt.results}); got != tt.want {
+			t.Errorf("stageOfStatus(%s, %d results) = %s, want %s", tt.status, len(tt.results), got, tt.want)
+		}
+	}
+}
+
+// 升级前的任务按状态补上阶段，重启时处理中的任务退回 received
+func TestNewScanJobQueueRestoresStage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "jobs")
+	store, err := newFileJobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range []*ScanJob{
+		{ID: "done", Status: JobDone, Results: []VerificationResult{{Pending: true}}},
+		{ID: "failed", Status: JobFailed},
+		{ID: "running", Status: JobProcessing},
+		{ID: "staged", Status: JobProcessing, Stage: ScanOCR, Transitions: []ScanTransition{{Stage: ScanOCR}}},
+	} {
+		if err := store.Put(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q, err := newScanJobQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id         string
+		wantStatus string
+		wantStages string
+		wantNote   string
+	}{
+		{"done", JobDone, "pending_draw", ""},
+		{"failed", JobFailed, "failed", ""},
+		{"running", JobQueued, "received", ""},
+		{"staged", JobQueued, "ocr,received", "服务重启，重新排队"},
+	}
+	for _, tt := range tests {
+		job, ok := q.Get(tt.id)
+		if !ok {
+			t.Fatalf("job %s missing", tt.id)
+		}
+		if job.Status != tt.wantStatus || stagesOf(job) != tt.wantStages {
+			t.Errorf("%s: status %s stages %s, want %s %s", tt.id, job.Status, stagesOf(job), tt.wantStatus, tt.wantStages)
+		}
+		if note := job.Transitions[len(job.Transitions)-1].Note; note != tt.wantNote {
+			t.Errorf("%s: note = %q, want %q", tt.id, note, tt.wantNote)
+		}
+	}
+}
+
+func TestScanJobProcessStages(t *testing.T) {
+	const pendingFixture = `[{"type": "双色球", "issue": "2025108", "tickets": [{"red": ["01","02","03","04","05","06"], "blue": ["07"], "multiplier": 1}]}]`
+	dir := t.TempDir()
+	writeTestFile(t, dir, imageHash([]byte("drawn"))+".json", []byte(hookTestFixture))
+	writeTestFile(t, dir, imageHash([]byte("pending"))+".json", []byte(pendingFixture))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	t.Setenv("OCR_SECONDARY_URL", "")
+	useTestBilling(t)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+
+	tests := []struct {
+		name       string
+		image      string
+		wantStatus string
+		wantStages string
+		wantNote   string
+	}{
+		{"全部开奖", "drawn", JobDone, "received,preprocessing,ocr,verifying,done", ""},
+		{"有未开奖的期次", "pending", JobDone, "received,preprocessing,ocr,verifying,pending_draw", ""},
+		{"识别失败重新排队", "unknown", JobQueued, "received,preprocessing,ocr,received", "第 1 次识别失败，稍后重试"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestQueue(t)
+			ocrCache.Purge()
+			verifyCache.Purge()
+			scanHistory = &historyStore{}
+			job, err := jobQueue.Enqueue([]byte(tt.image), ScanOrigin{Tenant: "shop-a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobQueue.process(job.ID, "test")
+
+			got, _ := jobQueue.Get(job.ID)
+			if got.Status != tt.wantStatus || stagesOf(got) != tt.wantStages {
+				t.Errorf("status %s stages %s, want %s %s", got.Status, stagesOf(got), tt.wantStatus, tt.wantStages)
+			}
+			if note := got.Transitions[len(got.Transitions)-1].Note; note != tt.wantNote {
+				t.Errorf("note = %q, want %q", note, tt.wantNote)
+			}
+			for i := 1; i < len(got.Transitions); i++ {
+				if got.Transitions[i].At.Before(got.Transitions[i-1].At) {
+					t.Errorf("transition %d out of order", i)
+				}
+			}
+		})
+	}
+}
+
+// 开奖后待开奖的任务进入 done
+func TestScanJobSettleStage(t *testing.T) {
+	useTestQueue(t)
+	ticket := LotteryData{Type: "双色球", Issue: "2025108", Tickets: []UserTicket{{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}, Multiplier: 1}}}
+	other := LotteryData{Type: "双色球", Issue: "2025109", Tickets: ticket.Tickets}
+	job := &ScanJob{ID: "j1", Status: JobDone, ScanOrigin: ScanOrigin{Tenant: "shop-a"}, Results: []VerificationResult{
+		{TicketIndex: 1, OCRData: ticket, Pending: true},
+		{TicketIndex: 2, OCRData: other, Pending: true},
+	}}
+	job.advance(ScanPendingDraw, "")
+	if err := jobQueue.store.Put(job); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		settle    LotteryData
+		wantStage string
+	}{
+		{"部分开奖仍在等待", ticket, ScanPendingDraw},
+		{"全部开奖", other, ScanDone},
+	}
+	for _, st := range steps {
+		if n := jobQueue.Settle("shop-a", VerificationResult{OCRData: st.settle}); n != 1 {
+			t.Fatalf("%s: Settle() = %d, want 1", st.name, n)
+		}
+		got, _ := jobQueue.Get("j1")
+		if got.Stage != st.wantStage {
+			t.Errorf("%s: stage = %s, want %s", st.name, got.Stage, st.wantStage)
+		}
+	}
+	got, _ := jobQueue.Get("j1")
+	if note := got.Transitions[len(got.Transitions)-1].Note; note != "已全部开奖" {
+		t.Errorf("note = %q", note)
+	}
+}
+
+func TestAdminJobsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestQueue(t)
+	now := time.Now()
+	for _, job := range []*ScanJob{
+		{ID: "ocr-new", Status: JobProcessing, Stage: ScanOCR, Transitions: []ScanTransition{{Stage: ScanOCR, At: now.Add(-time.Minute)}}},
+		{ID: "ocr-old", Status: JobProcessing, Stage: ScanOCR, Transitions: []ScanTransition{{Stage: ScanOCR, At: now.Add(-time.Hour)}}},
+		{ID: "queued", Status: JobQueued, Stage: ScanReceived, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "done", Status: JobDone, Stage: ScanDone, CreatedAt: now},
+		{ID: "failed", Status: JobFailed, Stage: ScanFailed, CreatedAt: now},
+	} {
+		if err := jobQueue.store.Put(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		stage      string
+		wantStatus int
+		wantIDs    string
+	}{
+		{"停在识别阶段的任务按停留时间排序", "ocr", 200, "ocr-old,ocr-new"},
+		{"不指定阶段只列未结束的任务", "", 200, "queued,ocr-old,ocr-new"},
+		{"已结束的阶段", "failed", 200, "failed"},
+		{"没有任务的阶段", "verifying", 200, ""},
+		{"未知阶段", "stuck", 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/admin/jobs?stage="+tt.stage, nil)
+			adminJobsHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Items []struct {
+					JobID        string `json:"job_id"`
+					StuckSeconds int    `json:"stuck_seconds"`
+				} `json:"items"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			var ids []string
+			for _, item := range resp.Items {
+				ids = append(ids, item.JobID)
+				if item.JobID == "ocr-old" && item.StuckSeconds < 3599 {
+					t.Errorf("stuck_seconds = %d, want about an hour", item.StuckSeconds)
+				}
+			}
+			if got := strings.Join(ids, ","); got != tt.wantIDs {
+				t.Errorf("jobs = %s, want %s", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// 任务状态接口按请求语言翻译切换说明
+func TestJobStatusHandlerTransitions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestCatalogs(t, t.TempDir())
+	useTestQueue(t)
+	job := &ScanJob{ID: "j1", Status: JobQueued}
+	job.advance(ScanReceived, "")
+	job.advance(ScanPreprocessing, "")
+	job.advance(ScanReceived, "服务重启，重新排队")
+	if err := jobQueue.store.Put(job); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(localeMiddleware())
+	r.GET("/api/v1/jobs/:id", jobStatusHandler)
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"默认中文", "", "服务重启，重新排队"},
+		{"英文", "en", "Service restarted, requeued"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/jobs/j1", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Language", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			var resp ScanJob
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Stage != ScanReceived || len(resp.Transitions) != 3 || resp.Transitions[2].Note != tt.want {
+				t.Errorf("stage %s transitions %+v, want note %q", resp.Stage, resp.Transitions, tt.want)
+			}
+		})
+	}
+	// 翻译不改动保存的记录
+	if got, _ := jobQueue.Get("j1"); got.Transitions[2].Note != "服务重启，重新排队" {
+		t.Errorf("stored note = %q", got.Transitions[2].Note)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// LIMITS: 按路由配置超时与请求体上限
+// ==========================================
+
+// 不同接口的合理上限差别很大：识别接口要等 AI 返回、要收整张照片，开奖查询则应当很快、几乎没有请求体。
+// 内置默认值见 defaultRouteLimits，可用 data/route_limits.json 按路由覆盖或补充：
+//
+//	{"POST /api/v1/scan": {"timeout": "30s", "max_body": "10MB"},
+//	 "GET /api/v1/draws/:game": {"timeout": "2s", "max_body": "1KB"},
+//	 "*": {"timeout": "15s"}}
+//
+// 键为 "方法 路由模板"（与注册路由时的写法一致），"*" 作用于其余所有路由；未列出且没有 "*" 的路由不做限制
+// （开奖推送等长连接接口不要配置超时）。timeout 作为请求 context 的截止时间，识别与验奖各阶段的预算
+// 不会超过它；请求体超过 max_body 返回 413。修改后调用 POST /api/v1/admin/reload 生效
+
+// RouteLimit 一条路由的限制，零值表示不限
+type RouteLimit struct {
+	Timeout time.Duration
+	MaxBody int64
+}
+
+func (l *RouteLimit) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Timeout string          `json:"timeout"`
+		MaxBody json.RawMessage `json:"max_body"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.Timeout != "" {
+		d, err := time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return fmt.Errorf("timeout %q 格式错误，如 30s", raw.Timeout)
+		}
+		l.Timeout = d
+	}
+	if len(raw.MaxBody) > 0 {
+		var n int64
+		if err := json.Unmarshal(raw.MaxBody, &n); err == nil {
+			l.MaxBody = n
+			return nil
+		}
+		var s string
+		if err := json.Unmarshal(raw.MaxBody, &s); err != nil {
+			return fmt.Errorf("max_body 应为字节数或 \"10MB\" 这样的字符串")
+		}
+		n, err := parseByteSize(s)
+		if err != nil {
+			return err
+		}
+		l.MaxBody = n
+	}
+	return nil
+}
+
+// parseByteSize 解析 "512"、"1KB"、"10MB"、"1GB"（1024 进制）
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("大小 %q 格式错误，如 10MB", s)
+	}
+	return n * mult, nil
+}
+
+var defaultRouteLimits = map[string]RouteLimit{
+	"POST /api/v1/scan":                {Timeout: 30 * time.Second, MaxBody: 10 << 20},
+	"POST /api/v1/ocr":                 {Timeout: 30 * time.Second, MaxBody: 10 << 20},
+	"POST /api/v1/scan/precheck":       {Timeout: 30 * time.Second, MaxBody: 10 << 20},
+	"POST /api/v1/scan/batch":          {Timeout: 2 * time.Minute, MaxBody: 50 << 20},
+	"POST /api/v1/scan/stitch":         {Timeout: time.Minute, MaxBody: 40 << 20},
+	"POST /api/v1/scan/zip":            {Timeout: 10 * time.Minute, MaxBody: 500 << 20},
+	"POST /api/v1/scans/:id/reprocess": {Timeout: 30 * time.Second, MaxBody: 4 << 10},
+	"POST /api/v1/scans/:id/feedback":  {Timeout: 10 * time.Second, MaxBody: 64 << 10},
+	"POST /api/v1/verify":              {Timeout: 10 * time.Second, MaxBody: 256 << 10},
+	"POST /api/v1/verify/text":         {Timeout: 10 * time.Second, MaxBody: 64 << 10},
+	"POST /api/v1/subscriptions":       {Timeout: 5 * time.Second, MaxBody: 16 << 10},
+	"GET /api/v1/draws/next":           {Timeout: 2 * time.Second, MaxBody: 1 << 10},
+	"GET /api/v1/draws/:game":          {Timeout: 2 * time.Second, MaxBody: 1 << 10},
+	"GET /api/v1/draws/:game/:issue":   {Timeout: 2 * time.Second, MaxBody: 1 << 10},
+}
+
+type routeLimitStore struct {
+	mu     sync.RWMutex
+	limits map[string]RouteLimit
+}
+
+var routeLimits = &routeLimitStore{limits: defaultRouteLimits}
+
+// Load 读取路由限制，在内置默认值基础上覆盖；文件不存在时只用默认值
+func (s *routeLimitStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var overrides map[string]RouteLimit
+	if err := json.Unmarshal(raw, &overrides); err != nil {
+		return err
+	}
+	limits := make(map[string]RouteLimit, len(defaultRouteLimits)+len(overrides))
+	for k, v := range defaultRouteLimits {
+		limits[k] = v
+	}
+	for k, v := range overrides {
+		limits[k] = v
+	}
+	s.mu.Lock()
+	s.limits = limits
+	s.mu.Unlock()
+	return nil
+}
+
+// For 某条路由的限制，未配置时取 "*"
+func (s *routeLimitStore) For(method, route string) (RouteLimit, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if l, ok := s.limits[method+" "+route]; ok {
+		return l, true
+	}
+	l, ok := s.limits["*"]
+	return l, ok
+}
+
+// routeLimitMiddleware 按匹配到的路由模板设置截止时间与请求体上限
+func routeLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := routeLimits.For(c.Request.Method, c.FullPath())
+		if !ok || c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		if limit.MaxBody > 0 {
+			if c.Request.ContentLength > limit.MaxBody {
+				c.AbortWithStatusJSON(413, gin.H{"error": fmt.Sprintf("请求体过大，最多 %d 字节", limit.MaxBody)})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit.MaxBody)
+		}
+		if limit.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), limit.Timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestRouteLimits 换成 raw 覆盖后的路由限制
+func useTestRouteLimits(t *testing.T, raw string) {
+	t.Helper()
+	old := routeLimits
+	t.Cleanup(func() { routeLimits = old })
+	routeLimits = &routeLimitStore{limits: defaultRouteLimits}
+	if err := routeLimits.Load(writeTestFile(t, t.TempDir(), "route_limits.json", []byte(raw))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"1KB", 1 << 10, false},
+		{"10 mb", 10 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"1.5MB", 0, true},
+		{"-1KB", 0, true},
+		{"MB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestRouteLimitStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		route   string
+		want    RouteLimit
+		wantOK  bool
+		wantErr bool
+	}{
+		{"文件不存在用默认值", "", "POST /api/v1/scan", RouteLimit{30 * time.Second, 10 << 20}, true, false},
+		{"未列出的路由不限制", "", "GET /api/v1/stats", RouteLimit{}, false, false},
+		{"覆盖默认值", `{"POST /api/v1/scan": {"timeout": "45s", "max_body": "20MB"}}`, "POST /api/v1/scan", RouteLimit{45 * time.Second, 20 << 20}, true, false},
+		{"覆盖时保留其他默认值", `{"POST /api/v1/scan": {"timeout": "45s"}}`, "POST /api/v1/ocr", RouteLimit{30 * time.Second, 10 << 20}, true, false},
+		{"字节数写成数字", `{"GET /api/v1/stats": {"max_body": 2048}}`, "GET /api/v1/stats", RouteLimit{0, 2048}, true, false},
+		{"通配其余路由", `{"*": {"timeout": "15s"}}`, "GET /api/v1/stats", RouteLimit{15 * time.Second, 0}, true, false},
+		{"具体路由优先于通配", `{"*": {"timeout": "15s"}}`, "GET /api/v1/draws/:game", RouteLimit{2 * time.Second, 1 << 10}, true, false},
+		{"timeout 格式错误", `{"*": {"timeout": "15"}}`, "", RouteLimit{}, false, true},
+		{"max_body 格式错误", `{"*": {"max_body": "lots"}}`, "", RouteLimit{}, false, true},
+		{"max_body 类型错误", `{"*": {"max_body": true}}`, "", RouteLimit{}, false, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "missing.json")
+			if tt.file != "" {
+				path = writeTestFile(t, dir, string(rune('a'+i))+".json", []byte(tt.file))
+			}
+			s := &routeLimitStore{limits: defaultRouteLimits}
+			if err := s.Load(path); (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			method, route, _ := strings.Cut(tt.route, " ")
+			got, ok := s.For(method, route)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("For(%s) = %+v, %v, want %+v, %v", tt.route, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+	// 覆盖不会改动内置默认值
+	if defaultRouteLimits["POST /api/v1/scan"].Timeout != 30*time.Second {
+		t.Error("defaultRouteLimits modified by Load")
+	}
+}
+
+func TestRouteLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestRouteLimits(t, `{"POST /api/v1/scan": {"timeout": "5s", "max_body": "1KB"}}`)
+	r := gin.New()
+	r.Use(routeLimitMiddleware())
+	handler := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.JSON(413, gin.H{"error": err.Error()})
+			return
+		}
+		deadline, ok := c.Request.Context().Deadline()
+		c.JSON(200, gin.H{"deadline": ok, "remaining": time.Until(deadline).Seconds()})
+	}
+	r.POST("/api/v1/scan", handler)
+	r.GET("/api/v1/stats", handler)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		chunked      bool
+		wantStatus   int
+		wantDeadline bool
+	}{
+		{"限制内", "POST", "/api/v1/scan", "abc", false, 200, true},
+		{"声明的长度超限", "POST", "/api/v1/scan", strings.Repeat("x", 2048), false, 413, false},
+		{"未声明长度时读取超限", "POST", "/api/v1/scan", strings.Repeat("x", 2048), true, 413, false},
+		{"未配置的路由不限制", "GET", "/api/v1/stats", strings.Repeat("x", 2048), false, 200, false},
+		{"未匹配的路由", "GET", "/nope", "", false, 404, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == 200 && strings.Contains(w.Body.String(), `"deadline":true`) != tt.wantDeadline {
+				t.Errorf("body = %s, want deadline %v", w.Body, tt.wantDeadline)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// LOADTEST: 压测模式 (--loadtest)
+// ==========================================
+
+// 把 fixtures 目录里的图片轮流上传到一个正在运行的服务，统计延迟分位数：
+//   ./lottery-server --loadtest --target http://127.0.0.1:8080/api/v1/scan --fixtures images -n 200 -c 8
+
+type loadtestOptions struct {
+	Target      string
+	Fixtures    string
+	Requests    int
+	Concurrency int
+	Timeout     time.Duration
+}
+
+type fixture struct {
+	name string
+	data []byte
+}
+
+func loadFixtures(dir string) ([]fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []fixture
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".jpg", ".jpeg", ".png", ".webp":
+		default:
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fixture{name: e.Name(), data: data})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("目录 %s 下没有图片", dir)
+	}
+	return list, nil
+}
+
+func runLoadtest(opts loadtestOptions) error {
+	fixtures, err := loadFixtures(opts.Fixtures)
+	if err != nil {
+		return fmt.Errorf("加载 fixtures 失败: %v", err)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	// 预先构造好 multipart 请求体，避免把编码耗时算进延迟
+	type body struct {
+		contentType string
+		payload     []byte
+	}
+	bodies := make([]body, len(fixtures))
+	for i, f := range fixtures {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		part, err := w.CreateFormFile("image", f.name)
+		if err != nil {
+			return err
+		}
+		part.Write(f.data)
+		w.Close()
+		bodies[i] = body{contentType: w.FormDataContentType(), payload: buf.Bytes()}
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		failures  = map[string]int{}
+	)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b := bodies[i%len(bodies)]
+				start := time.Now()
+				resp, err := client.Post(opts.Target, b.contentType, bytes.NewReader(b.payload))
+				reason := ""
+				if err != nil {
+					reason = "transport"
+				} else {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode >= 300 {
+						reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+					}
+				}
+				elapsed := time.Since(start)
+				mu.Lock()
+				if reason != "" {
+					failures[reason]++
+				} else {
+					latencies = append(latencies, elapsed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	begin := time.Now()
+	for i := 0; i < opts.Requests; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	wall := time.Since(begin)
+
+	fmt.Printf("目标: %s  fixtures: %d 张  并发: %d\n", opts.Target, len(fixtures), opts.Concurrency)
+	fmt.Printf("请求: %d  成功: %d  耗时: %s  吞吐: %.2f req/s\n",
+		opts.Requests, len(latencies), wall.Round(time.Millisecond), float64(opts.Requests)/wall.Seconds())
+	for reason, n := range failures {
+		fmt.Printf("失败 %-12s %d\n", reason, n)
+	}
+	if len(latencies) == 0 {
+		return fmt.Errorf("没有成功的请求")
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Printf("p50: %s  p95: %s  p99: %s  max: %s\n",
+		percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99),
+		latencies[len(latencies)-1].Round(time.Millisecond))
+	return nil
+}
+
+// percentile 取已排序样本的第 p 百分位（最近秩法）
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p + 99) / 100
+	if idx < 1 {
+		idx = 1
+	}
+	if idx > len(sorted) {
+		idx = len(sorted)
+	}
+	return sorted[idx-1].Round(time.Millisecond)
+}
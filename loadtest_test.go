@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := func(n ...int) []time.Duration {
+		out := make([]time.Duration, len(n))
+		for i, v := range n {
+			out[i] = time.Duration(v) * time.Millisecond
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      int
+		want   time.Duration
+	}{
+		{"单个样本", ms(42), 99, 42 * time.Millisecond},
+		{"p50 取中位", ms(10, 20, 30, 40), 50, 20 * time.Millisecond},
+		{"p99 取最大", ms(10, 20, 30, 40), 99, 40 * time.Millisecond},
+		{"p0 取最小", ms(10, 20, 30), 0, 10 * time.Millisecond},
+		{"p90 最近秩", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 90, 9 * time.Millisecond},
+		{"四舍五入到毫秒", []time.Duration{1499 * time.Microsecond}, 50, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v, %d) = %v, want %v", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    int
+		wantErr bool
+	}{
+		{"只取图片", []string{"a.jpg", "b.PNG", "c.txt", "d.webp"}, 3, false},
+		{"没有图片", []string{"readme.md"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				writeTestFile(t, dir, f, []byte("x"))
+			}
+			list, err := loadFixtures(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadFixtures err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(list) != tt.want {
+				t.Errorf("loadFixtures 返回 %d 张，want %d", len(list), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// MAILIN: 邮件验奖（IMAP 收信 + SMTP 回信）
+// ==========================================
+
+// 用户把彩票照片发到指定邮箱，服务端定时收信识别并回信告知结果，
+// 适合不方便安装 App 的老年用户。回信走已有的 SMTP 配置
+
+// imapConfig 来自环境变量 IMAP_ADDR (host:993) / IMAP_USER / IMAP_PASSWORD / IMAP_MAILBOX
+type imapConfig struct {
+	Addr     string
+	User     string
+	Password string
+	Mailbox  string
+	Tenant   string
+}
+
+func loadIMAPConfig() (imapConfig, bool) {
+	cfg := imapConfig{
+		Addr:     strings.TrimSpace(os.Getenv("IMAP_ADDR")),
+		User:     os.Getenv("IMAP_USER"),
+		Password: os.Getenv("IMAP_PASSWORD"),
+		Mailbox:  strings.TrimSpace(os.Getenv("IMAP_MAILBOX")),
+		Tenant:   strings.TrimSpace(os.Getenv("MAILIN_TENANT")),
+	}
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = "INBOX"
+	}
+	if cfg.Tenant == "" {
+		cfg.Tenant = "default"
+	}
+	return cfg, cfg.Addr != "" && cfg.User != ""
+}
+
+// runMailIn 定时轮询未读邮件；处理完（无论成败）都标记已读，避免重复回信
+func runMailIn(cfg imapConfig, smtpCfg smtpConfig) {
+	interval := envDuration("IMAP_POLL_INTERVAL", time.Minute)
+	log.Printf("开始轮询邮箱 %s/%s", cfg.Addr, cfg.Mailbox)
+	for {
+		if err := pollMailbox(cfg, smtpCfg); err != nil {
+			log.Printf("收取邮件失败: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func pollMailbox(cfg imapConfig, smtpCfg smtpConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	c, err := dialIMAP(ctx, cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.Cmd("LOGIN %s %s", imapQuote(cfg.User), imapQuote(cfg.Password)); err != nil {
+		return fmt.Errorf("登录失败: %v", err)
+	}
+	defer c.Cmd("LOGOUT")
+	if _, err := c.Cmd("SELECT %s", imapQuote(cfg.Mailbox)); err != nil {
+		return fmt.Errorf("打开邮箱失败: %v", err)
+	}
+	resp, err := c.Cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, r := range resp {
+		if strings.HasPrefix(r.Line, "* SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(r.Line, "* SEARCH"))...)
+		}
+	}
+
+	c.literalLimit = int64(envInt("MAILIN_MAX_MB", 25)) << 20
+	for _, uid := range uids {
+		resp, err := c.Cmd("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return err
+		}
+		for _, r := range resp {
+			if r.Literal != nil {
+				handleMail(ctx, cfg, smtpCfg, r.Literal)
+			}
+		}
+		if _, err := c.Cmd("UID STORE %s +FLAGS.SILENT (\\Seen)", uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleMail 识别邮件里的彩票图片并回信
+func handleMail(ctx context.Context, cfg imapConfig, smtpCfg smtpConfig, raw []byte) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil || isAutoMail(msg.Header, from.Address, smtpCfg.From) {
+		return
+	}
+	subject := decodeHeader(msg.Header.Get("Subject"))
+	if subject == "" {
+		subject = "彩票验奖"
+	}
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+
+	photos := mailImages(msg, envInt("MAILIN_MAX_IMAGES", 5))
+	var body bytes.Buffer
+	if len(photos) == 0 {
+		body.WriteString(mailUsageHTML)
+	} else {
+		origin := ScanOrigin{Tenant: cfg.Tenant, UserID: "mail:" + strings.ToLower(from.Address),
+			Contact: UserContact{Email: from.Address}, DeviceID: "email"}
+		var all []VerificationResult
+		var notes []string
+		for _, p := range photos {
+			results, job, err := runScan(ctx, origin, p.Data, os.Getenv("GEMINI_API_KEY"))
+			rr, review := asReviewRequired(err)
+			switch {
+			case review:
+				notes = append(notes, fmt.Sprintf("%s：大额票据需人工复核号码，复核完成后通知您（复核单 %s）", p.Name, rr.ID))
+			case err != nil:
+				notes = append(notes, fmt.Sprintf("%s：识别失败，请换一张清晰的照片重试", p.Name))
+			case job != nil:
+				notes = append(notes, fmt.Sprintf("%s：识别服务繁忙，已排队处理（任务编号 %s）", p.Name, job.ID))
+			default:
+				all = append(all, results...)
+			}
+		}
+		data := struct {
+			CardResponse
+			Notes []string
+		}{buildCardResponse(all), notes}
+		if err := mailReplyTemplate.Execute(&body, data); err != nil {
+			log.Printf("生成回信失败: %v", err)
+			return
+		}
+	}
+	if err := sendMail(ctx, smtpCfg, from.Address, subject, body.String()); err != nil {
+		log.Printf("回信 %s 失败: %v", from.Address, err)
+	}
+}
+
+// isAutoMail 退信、自动回复和自己发出的邮件不处理，避免邮件循环
+func isAutoMail(h mail.Header, from, self string) bool {
+	if strings.EqualFold(from, self) {
+		return true
+	}
+	if v := strings.ToLower(h.Get("Auto-Submitted")); v != "" && v != "no" {
+		return true
+	}
+	local := strings.ToLower(from)
+	return strings.HasPrefix(local, "mailer-daemon@") || strings.HasPrefix(local, "postmaster@") ||
+		h.Get("X-Autoreply") != "" || strings.EqualFold(h.Get("Precedence"), "bulk")
+}
+
+func decodeHeader(s string) string {
+	dec := new(mime.WordDecoder)
+	if out, err := dec.DecodeHeader(s); err == nil {
+		return strings.TrimSpace(out)
+	}
+	return strings.TrimSpace(s)
+}
+
+type mailImage struct {
+	Name string
+	Data []byte
+}
+
+// mailImages 递归遍历 multipart，取出图片附件（包括正文内嵌图片）
+func mailImages(msg *mail.Message, limit int) []mailImage {
+	var out []mailImage
+	var walk func(header func(string) string, body io.Reader)
+	walk = func(header func(string) string, body io.Reader) {
+		if len(out) >= limit {
+			return
+		}
+		mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+		if err != nil {
+			mediaType = "text/plain"
+		}
+		if strings.HasPrefix(mediaType, "multipart/") {
+			mr := multipart.NewReader(body, params["boundary"])
+			for {
+				part, err := mr.NextRawPart()
+				if err != nil {
+					return
+				}
+				walk(part.Header.Get, part)
+			}
+		}
+		name := params["name"]
+		if _, dp, err := mime.ParseMediaType(header("Content-Disposition")); err == nil && dp["filename"] != "" {
+			name = dp["filename"]
+		}
+		name = decodeHeader(name)
+		if !strings.HasPrefix(mediaType, "image/") && !zipImageExts[strings.ToLower(filepath.Ext(name))] {
+			return
+		}
+		switch strings.ToLower(strings.TrimSpace(header("Content-Transfer-Encoding"))) {
+		case "base64":
+			body = base64.NewDecoder(base64.StdEncoding, body) // 解码器会忽略换行
+		case "quoted-printable":
+			body = quotedprintable.NewReader(body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil || len(data) == 0 {
+			return
+		}
+		if name == "" {
+			name = fmt.Sprintf("图片%d", len(out)+1)
+		}
+		out = append(out, mailImage{Name: name, Data: data})
+	}
+	walk(msg.Header.Get, msg.Body)
+	return out
+}
+
+const mailUsageHTML = `<p>您好，没有在邮件中找到彩票照片。</p>
+<p>请把彩票正面拍清楚（四角完整、不反光），作为附件发送到本邮箱，我们会自动回复验奖结果。</p>`
+
+var mailReplyTemplate = template.Must(template.New("reply").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+{{range .Cards}}
+<h3>{{.Icon}} {{.Title}}</h3>
+<p><b>{{.Headline}}</b>　{{.Subtitle}}</p>
+<table border="1" cellpadding="6" cellspacing="0" style="border-collapse:collapse">
+{{range .Rows}}<tr><td>{{.Label}}</td><td>{{range .Balls}}<span style="color:{{if eq .Color "blue"}}#1677ff{{else}}#d9363e{{end}}{{if .Hit}};font-weight:bold{{end}}">{{.Number}}</span> {{end}}{{.Multiplier}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+{{range .Warnings}}<p style="color:#d46b08">⚠️ {{.}}</p>{{end}}
+{{with .Claim}}<p>兑奖地点：{{.Where}}{{if .Deadline}}，请在 {{.Deadline}} 前兑奖{{end}}</p>{{if .Tax}}<p>{{.Tax}}</p>{{end}}{{end}}
+{{end}}
+{{range .Notes}}<p>{{.}}</p>{{end}}
+{{if .Cards}}<p>{{.Summary}}</p>{{end}}
+<p style="color:#999">本邮件由系统自动回复，结果以彩票销售机构为准。</p>
+</body></html>`))
+
+// ==========================================
+// 极简 IMAP 客户端：只实现收信用到的命令
+// ==========================================
+
+type imapClient struct {
+	conn         *tls.Conn
+	r            *bufio.Reader
+	seq          int
+	literalLimit int64
+}
+
+// imapResponse 一条未标记响应；FETCH 的邮件正文以字面量形式附带
+type imapResponse struct {
+	Line    string
+	Literal []byte
+}
+
+var imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+
+func dialIMAP(ctx context.Context, addr string) (*imapClient, error) {
+	host := addr
+	if i := strings.LastIndex(addr, ":"); i > 0 {
+		host = addr[:i]
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c := &imapClient{conn: conn.(*tls.Conn), r: bufio.NewReader(conn)}
+	// 服务器问候语
+	if _, err := c.r.ReadString('\n'); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *imapClient) Close() error { return c.conn.Close() }
+
+// Cmd 发送一条命令并读到对应的标记响应，NO / BAD 作为错误返回
+func (c *imapClient) Cmd(format string, args ...interface{}) ([]imapResponse, error) {
+	c.seq++
+	tag := fmt.Sprintf("A%03d", c.seq)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	var out []imapResponse
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return out, fmt.Errorf("%s", status)
+			}
+			return out, nil
+		}
+		resp := imapResponse{Line: line}
+		if m := imapLiteral.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			if c.literalLimit > 0 && n > c.literalLimit {
+				// 超大邮件直接跳过
+				if _, err := io.CopyN(io.Discard, c.r, n); err != nil {
+					return nil, err
+				}
+			} else {
+				resp.Literal = make([]byte, n)
+				if _, err := io.ReadFull(c.r, resp.Literal); err != nil {
+					return nil, err
+				}
+			}
+			// 字面量之后还有本条响应的剩余部分（如 ")"）
+			if _, err := c.r.ReadString('\n'); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, resp)
+	}
+}
+
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestIsAutoMail(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		from   string
+		want   bool
+	}{
+		{"普通来信", "", "user@example.com", false},
+		{"自己发出的", "", "Bot@Shop.com", true},
+		{"自动回复", "Auto-Submitted: auto-replied\r\n", "user@example.com", true},
+		{"Auto-Submitted: no", "Auto-Submitted: no\r\n", "user@example.com", false},
+		{"退信", "", "MAILER-DAEMON@example.com", true},
+		{"postmaster", "", "postmaster@example.com", true},
+		{"X-Autoreply", "X-Autoreply: yes\r\n", "user@example.com", true},
+		{"群发邮件", "Precedence: bulk\r\n", "user@example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(tt.header + "From: " + tt.from + "\r\n\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isAutoMail(msg.Header, tt.from, "bot@shop.com"); got != tt.want {
+				t.Errorf("isAutoMail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"彩票验奖", "彩票验奖"},
+		{" =?UTF-8?B?5b2p56Wo6aqM5aWW?= ", "彩票验奖"},
+		{"=?UTF-8?Q?IMG=5F01.jpg?=", "IMG_01.jpg"},
+		{"=?bogus?B?xx?=", "=?bogus?B?xx?="},
+	}
+	for _, tt := range tests {
+		if got := decodeHeader(tt.in); got != tt.want {
+			t.Errorf("decodeHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMailImages(t *testing.T) {
+	const multipartMail = "Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\nContent-Type: multipart/related; boundary=inner\r\n\r\n" +
+		"--inner\r\nContent-Type: text/html\r\n\r\n<p>hi</p>\r\n" +
+		"--inner\r\nContent-Type: image/png\r\nContent-Transfer-Encoding: base64\r\n\r\naGVs\r\nbG8=\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=\"ticket.JPG\"\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nab=3Dc\r\n" +
+		"--outer\r\nContent-Type: application/pdf; name=\"a.pdf\"\r\n\r\n%PDF\r\n" +
+		"--outer\r\nContent-Type: image/jpeg\r\n\r\n\r\n" +
+		"--outer--\r\n"
+
+	tests := []struct {
+		name  string
+		raw   string
+		limit int
+		want  []mailImage
+	}{
+		{"内嵌图片和附件", multipartMail, 5, []mailImage{{"图片1", []byte("hello")}, {"ticket.JPG", []byte("ab=c")}}},
+		{"超过张数只取前几张", multipartMail, 1, []mailImage{{"图片1", []byte("hello")}}},
+		{"纯文本邮件", "Content-Type: text/plain\r\n\r\nhello\r\n", 5, nil},
+		{"整封邮件就是图片", "Content-Type: image/jpeg; name=\"a.jpg\"\r\n\r\nxyz", 5, []mailImage{{"a.jpg", []byte("xyz")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := mail.ReadMessage(strings.NewReader(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := mailImages(msg, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("mailImages() = %d images, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || string(got[i].Data) != string(tt.want[i].Data) {
+					t.Errorf("[%d] = %s/%q, want %s/%q", i, got[i].Name, got[i].Data, tt.want[i].Name, tt.want[i].Data)
+				}
+			}
+		})
+	}
+}
+
+func TestIMAPQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"user@example.com", `"user@example.com"`},
+		{`pa"ss\word`, `"pa\"ss\\word"`},
+	}
+	for _, tt := range tests {
+		if got := imapQuote(tt.in); got != tt.want {
+			t.Errorf("imapQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadIMAPConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr, user  string
+		wantOK      bool
+		wantMailbox string
+	}{
+		{"未配置", "", "", false, "INBOX"},
+		{"缺用户名", "imap.example.com:993", "", false, "INBOX"},
+		{"默认收件箱", "imap.example.com:993", "bot@example.com", true, "INBOX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IMAP_ADDR", tt.addr)
+			t.Setenv("IMAP_USER", tt.user)
+			t.Setenv("IMAP_MAILBOX", "")
+			t.Setenv("MAILIN_TENANT", "")
+			cfg, ok := loadIMAPConfig()
+			if ok != tt.wantOK || cfg.Mailbox != tt.wantMailbox || cfg.Tenant != "default" {
+				t.Errorf("loadIMAPConfig() = %+v, %v", cfg, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genai"
+)
+
+// ==========================================
+// METRICS: 运行指标（错误率 / Token 用量）
+// ==========================================
+
+// requestWindow 按分钟分桶统计请求数与 5xx 数，保留最近一小时
+type requestWindow struct {
+	mu      sync.Mutex
+	buckets map[int64]*requestBucket
+}
+
+type requestBucket struct {
+	total  int
+	errors int
+}
+
+var requestStats = &requestWindow{buckets: map[int64]*requestBucket{}}
+
+func (w *requestWindow) record(failed bool) {
+	minute := time.Now().Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b, ok := w.buckets[minute]
+	if !ok {
+		b = &requestBucket{}
+		w.buckets[minute] = b
+		for m := range w.buckets {
+			if m < minute-60 {
+				delete(w.buckets, m)
+			}
+		}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+}
+
+// Snapshot 返回最近 window 时间内的请求总数与错误数
+func (w *requestWindow) Snapshot(window time.Duration) (total, errors int) {
+	since := time.Now().Add(-window).Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for m, b := range w.buckets {
+		if m > since {
+			total += b.total
+			errors += b.errors
+		}
+	}
+	return total, errors
+}
+
+// metricsMiddleware 统计 API 请求结果
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		requestStats.record(c.Writer.Status() >= 500)
+	}
+}
+
+// tokenCounter 按自然日累计模型 Token 用量与估算费用；设置了 path 时每次累计后落盘，重启不清零
+type tokenCounter struct {
+	mu     sync.Mutex
+	path   string
+	Day    string  `json:"day"`
+	Prompt int64   `json:"prompt_tokens"`
+	Output int64   `json:"output_tokens"`
+	Total  int64   `json:"total_tokens"`
+	Spend  float64 `json:"spend"` // 元，按 OCR_MODEL_PRICES 估算
+}
+
+var tokenUsage = &tokenCounter{}
+
+func (t *tokenCounter) rollover() {
+	today := time.Now().Format("2006-01-02")
+	if t.Day != today {
+		t.Day, t.Prompt, t.Output, t.Total, t.Spend = today, 0, 0, 0, 0
+	}
+}
+
+// Add 累计一次调用的用量
+func (t *tokenCounter) Add(model string, prompt, output, total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	t.Prompt += prompt
+	t.Output += output
+	t.Total += total
+	t.Spend += modelCost(model, prompt, output)
+	t.save()
+}
+
+// AddGemini 累计 Gemini 返回的用量
+func (t *tokenCounter) AddGemini(model string, u *genai.GenerateContentResponseUsageMetadata) {
+	if u == nil {
+		return
+	}
+	t.Add(model, int64(u.PromptTokenCount), int64(u.CandidatesTokenCount), int64(u.TotalTokenCount))
+}
+
+// Today 今日累计 Token 总数
+func (t *tokenCounter) Today() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	return t.Total
+}
+
+// SpendToday 今日估算费用（元）
+func (t *tokenCounter) SpendToday() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	return t.Spend
+}
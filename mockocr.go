@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// MOCK OCR: 离线开发用的固定识别结果
+// ==========================================
+
+// OCR_PROVIDER=mock 时不调用任何 AI 服务，识别结果取自样例目录 OCR_MOCK_DIR（默认 data/mock_ocr）：
+//
+//	<图片 SHA-256>.json   按图片内容匹配，与 OCR 缓存键相同
+//	<文件名>.json         按上传文件名（去掉扩展名）匹配，如上传 ssq_win.jpg 使用 ssq_win.json
+//	default.json          以上都没有时使用；也没有则识别失败
+//
+// 样例内容就是模型的原始输出（JSON 数组，可带 ```json 包裹），同样经过 parseOCRText 宽松解析和清洗，
+// 之后的验奖、复核、历史等流程与真实识别完全一致，也不消耗 token。
+// OCR_MOCK_DELAY 模拟识别耗时（如 2s），便于调试超时与排队。
+// 未设置 GEMINI_API_KEY 时服务照常启动
+
+const providerMock = "mock"
+
+func mockOCREnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("OCR_PROVIDER")), providerMock)
+}
+
+func mockOCRDir() string {
+	if dir := strings.TrimSpace(os.Getenv("OCR_MOCK_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(dataDir(), "mock_ocr")
+}
+
+type imageNameKey struct{}
+
+// withImageName 在上下文里记录上传的文件名，沿 recognizeCached 传递，供 mock 识别选择样例
+func withImageName(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, imageNameKey{}, name)
+}
+
+func imageNameFrom(ctx context.Context) string {
+	name, _ := ctx.Value(imageNameKey{}).(string)
+	return name
+}
+
+// imageNameOf scanImageOf 读取到的上传文件名
+func imageNameOf(c *gin.Context) string {
+	return c.GetString("image_name")
+}
+
+// mockFixture 依次按图片哈希、文件名、default 查找样例
+func mockFixture(ctx context.Context, fileBytes []byte) (string, []byte, error) {
+	dir := mockOCRDir()
+	candidates := []string{imageHash(fileBytes)}
+	if name := filepath.Base(imageNameFrom(ctx)); name != "." && name != "/" {
+		candidates = append(candidates, strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	candidates = append(candidates, "default")
+	for _, c := range candidates {
+		path := filepath.Join(dir, c+".json")
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return path, raw, nil
+	}
+	return "", nil, fmt.Errorf("mock 识别：%s 中没有匹配的样例（图片哈希 %s）", dir, candidates[0])
+}
+
+// mockRecognize 返回样例中的识别结果，调试记录与真实调用格式相同
+func mockRecognize(ctx context.Context, fileBytes []byte) (data []LotteryData, err error) {
+	trace := debugTraceFrom(ctx)
+	ex := debugExchange{Provider: providerMock, StartedAt: time.Now()}
+	defer func() {
+		ex.DurationMs, ex.Cleaned = time.Since(ex.StartedAt).Milliseconds(), data
+		if err != nil {
+			ex.Error = err.Error()
+		}
+		trace.Record(ex)
+	}()
+
+	if d := envDuration("OCR_MOCK_DELAY", 0); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	path, raw, err := mockFixture(ctx, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+	ex.Model = filepath.Base(path)
+	ex.RawOutput = string(raw)
+	return parseOCRText(strings.TrimSpace(ex.RawOutput))
+}
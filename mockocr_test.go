@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockOCRConfig(t *testing.T) {
+	t.Setenv("DATA_DIR", "/srv/lottery")
+	tests := []struct {
+		provider    string
+		dir         string
+		wantEnabled bool
+		wantDir     string
+	}{
+		{"", "", false, "/srv/lottery/mock_ocr"},
+		{"gemini", "", false, "/srv/lottery/mock_ocr"},
+		{" Mock ", "", true, "/srv/lottery/mock_ocr"},
+		{"mock", " fixtures ", true, "fixtures"},
+	}
+	for _, tt := range tests {
+		t.Setenv("OCR_PROVIDER", tt.provider)
+		t.Setenv("OCR_MOCK_DIR", tt.dir)
+		if got := mockOCREnabled(); got != tt.wantEnabled {
+			t.Errorf("mockOCREnabled() with %q = %v, want %v", tt.provider, got, tt.wantEnabled)
+		}
+		if got := mockOCRDir(); got != tt.wantDir {
+			t.Errorf("mockOCRDir() with %q = %s, want %s", tt.dir, got, tt.wantDir)
+		}
+	}
+}
+
+func TestMockFixture(t *testing.T) {
+	img := []byte("\xff\xd8\xff\xe0fixture")
+	tests := []struct {
+		name     string
+		files    []string
+		upload   string
+		wantFile string
+	}{
+		{"按图片哈希", []string{imageHash(img), "ssq_win", "default"}, "ssq_win.jpg", imageHash(img)},
+		{"按文件名", []string{"ssq_win", "default"}, "ssq_win.jpg", "ssq_win"},
+		{"文件名去掉目录", []string{"ssq_win", "default"}, "../uploads/ssq_win.jpg", "ssq_win"},
+		{"没有匹配时用 default", []string{"default"}, "other.jpg", "default"},
+		{"没有文件名", []string{"ssq_win", "default"}, "", "default"},
+		{"没有任何样例", []string{"ssq_win"}, "other.jpg", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				writeTestFile(t, dir, f+".json", []byte(f))
+			}
+			t.Setenv("OCR_MOCK_DIR", dir)
+			path, raw, err := mockFixture(withImageName(context.Background(), tt.upload), img)
+			if tt.wantFile == "" {
+				if err == nil || !strings.Contains(err.Error(), imageHash(img)) {
+					t.Errorf("mockFixture() error = %v, want no-fixture error naming the hash", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != filepath.Join(dir, tt.wantFile+".json") || string(raw) != tt.wantFile {
+				t.Errorf("mockFixture() = %s (%s), want %s", path, raw, tt.wantFile)
+			}
+		})
+	}
+}
+
+// errMockFailed 表示只要求 mock 识别出错
+var errMockFailed = errors.New("mock 识别失败")
+
+func TestMockRecognize(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "fenced.json", []byte("```json\n"+hookTestFixture+"\n```"))
+	writeTestFile(t, dir, "bad.json", []byte("not json"))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+
+	tests := []struct {
+		name      string
+		upload    string
+		delay     string
+		timeout   time.Duration
+		wantCount int
+		wantErr   error // 非 nil 时只要求出错；context.DeadlineExceeded 时要求是超时
+	}{
+		{"去掉代码块包裹后解析", "fenced.jpg", "", time.Second, 2, nil},
+		{"样例不是 JSON", "bad.jpg", "", time.Second, 0, errMockFailed},
+		{"没有样例", "missing.jpg", "", time.Second, 0, errMockFailed},
+		{"模拟识别耗时", "fenced.jpg", "20ms", time.Second, 2, nil},
+		{"模拟耗时超过请求期限", "fenced.jpg", "1s", 20 * time.Millisecond, 0, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OCR_MOCK_DELAY", tt.delay)
+			trace := &DebugTrace{}
+			ctx, cancel := context.WithTimeout(withDebugTrace(withImageName(context.Background(), tt.upload), trace), tt.timeout)
+			defer cancel()
+			data, err := mockRecognize(ctx, []byte("img-"+tt.name))
+			if (err != nil) != (tt.wantErr != nil) || (tt.wantErr == context.DeadlineExceeded && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("mockRecognize() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(data) != tt.wantCount {
+				t.Errorf("got %d lotteries, want %d", len(data), tt.wantCount)
+			}
+			// 调试记录与真实调用格式相同
+			if len(trace.Exchanges) != 1 {
+				t.Fatalf("recorded %d exchanges", len(trace.Exchanges))
+			}
+			ex := trace.Exchanges[0]
+			if ex.Provider != providerMock || (ex.Error != "") != (err != nil) {
+				t.Errorf("exchange = %+v", ex)
+			}
+			if err == nil && ex.Model != "fenced.json" {
+				t.Errorf("exchange model = %q, want fixture name", ex.Model)
+			}
+		})
+	}
+}
+
+// mock 识别不读识别缓存，改了样例立即生效
+func TestRecognizeCachedMockSkipsCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	ocrCache.Purge()
+	t.Cleanup(ocrCache.Purge)
+	img := []byte("\xff\xd8\xff\xe0mock-cache")
+
+	steps := []struct {
+		name    string
+		fixture string
+		want    string
+	}{
+		{"第一次识别", `[{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["01"]}]}]`, "2025107"},
+		{"改了样例", `[{"type": "双色球", "issue": "2025108", "tickets": [{"red": ["01"]}]}]`, "2025108"},
+	}
+	for _, st := range steps {
+		writeTestFile(t, dir, "default.json", []byte(st.fixture))
+		data, err := recognizeCached(context.Background(), img, "test")
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if len(data) != 1 || data[0].Issue != st.want {
+			t.Errorf("%s: got %+v, want issue %s", st.name, data, st.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// MONEY: 金额统一以分为单位
+// ==========================================
+
+// Fen 金额（分）。刮刮乐、竞彩等奖金有角分，内部一律用整数分计算，
+// 只在输出时转换；v1 响应里的元字段由此换算，v2 字段以 _fen 结尾
+type Fen int64
+
+// Yuan 1 元
+const Yuan Fen = 100
+
+// Yuan 折算为整元（向零取整），用于兼容旧的元字段
+func (f Fen) Yuan() int64 { return int64(f / Yuan) }
+
+// Decimal 不带单位和千分位的元金额："5000"、"5000.5"、"0.05"
+func (f Fen) Decimal() string {
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	s := strconv.FormatInt(int64(f/Yuan), 10)
+	if cents := int64(f % Yuan); cents != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%02d", cents), "0")
+	}
+	return sign + s
+}
+
+// String 展示用金额："5,000元"、"5,000.50元"
+func (f Fen) String() string {
+	s := formatYuan(f.Yuan())
+	if cents := int64(f % Yuan); cents != 0 {
+		if cents < 0 {
+			cents = -cents
+			if f > -Yuan {
+				s = "-" + s
+			}
+		}
+		s = strings.TrimSuffix(s, "元") + fmt.Sprintf(".%02d元", cents)
+	}
+	return s
+}
+
+// 验奖结果同时输出 v1 的元字段（total_prize / prize）和 v2 的分字段；
+// 读取时兼容只有元字段的旧数据（已落盘的任务、缓存）
+
+func (r VerificationResult) MarshalJSON() ([]byte, error) {
+	type plain VerificationResult
+	return json.Marshal(struct {
+		plain
+		TotalPrizeYuan int64 `json:"total_prize"`
+	}{plain(r), r.TotalPrize.Yuan()})
+}
+
+func (r *VerificationResult) UnmarshalJSON(data []byte) error {
+	type plain VerificationResult
+	aux := struct {
+		*plain
+		TotalPrizeYuan *int64 `json:"total_prize"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if r.TotalPrize == 0 && aux.TotalPrizeYuan != nil {
+		r.TotalPrize = Fen(*aux.TotalPrizeYuan) * Yuan
+	}
+	return nil
+}
+
+func (d ResultDetail) MarshalJSON() ([]byte, error) {
+	type plain ResultDetail
+	return json.Marshal(struct {
+		plain
+		PrizeYuan int64 `json:"prize"`
+	}{plain(d), d.Prize.Yuan()})
+}
+
+func (d *ResultDetail) UnmarshalJSON(data []byte) error {
+	type plain ResultDetail
+	aux := struct {
+		*plain
+		PrizeYuan *int64 `json:"prize"`
+	}{plain: (*plain)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if d.Prize == 0 && aux.PrizeYuan != nil {
+		d.Prize = Fen(*aux.PrizeYuan) * Yuan
+	}
+	return nil
+}
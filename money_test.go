@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFenFormat(t *testing.T) {
+	tests := []struct {
+		in      Fen
+		yuan    int64
+		decimal string
+		str     string
+	}{
+		{5000 * Yuan, 5000, "5000", "5,000元"},
+		{500050, 5000, "5000.5", "5,000.50元"},
+		{5, 0, "0.05", "0.05元"},
+		{0, 0, "0", "0元"},
+		{-150, -1, "-1.5", "-1.50元"},
+		{-5, 0, "-0.05", "-0.05元"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Yuan(); got != tt.yuan {
+			t.Errorf("Fen(%d).Yuan() = %d, want %d", tt.in, got, tt.yuan)
+		}
+		if got := tt.in.Decimal(); got != tt.decimal {
+			t.Errorf("Fen(%d).Decimal() = %q, want %q", tt.in, got, tt.decimal)
+		}
+		if got := tt.in.String(); got != tt.str {
+			t.Errorf("Fen(%d).String() = %q, want %q", tt.in, got, tt.str)
+		}
+	}
+}
+
+func TestVerificationResultJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		wantTotal Fen
+		wantPrize Fen
+	}{
+		{"v2 分字段", `{"total_prize_fen":500050,"details":[{"prize_fen":500050}]}`, 500050, 500050},
+		{"旧数据只有元字段", `{"total_prize":5000,"details":[{"prize":5000}]}`, 5000 * Yuan, 5000 * Yuan},
+		{"两种字段都有时以分为准", `{"total_prize":5000,"total_prize_fen":500050,"details":[{"prize":5000,"prize_fen":500050}]}`, 500050, 500050},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res VerificationResult
+			if err := json.Unmarshal([]byte(tt.raw), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.TotalPrize != tt.wantTotal || len(res.Details) != 1 || res.Details[0].Prize != tt.wantPrize {
+				t.Fatalf("decoded = %s / %+v, want %s / %s", res.TotalPrize, res.Details, tt.wantTotal, tt.wantPrize)
+			}
+			out, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []string{`"total_prize":5000`, `"total_prize_fen":`, `"prize":5000`, `"prize_fen":`} {
+				if !strings.Contains(string(out), field) {
+					t.Errorf("encoded %s, want field %s", out, field)
+				}
+			}
+		})
+	}
+}
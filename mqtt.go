@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ==========================================
+// MQTT: 门店自助机结果推送
+// ==========================================
+
+// kioskPublisher 把带设备编号的扫描结果发布到该设备的 topic，显示屏订阅即可
+type kioskPublisher struct {
+	client   mqtt.Client
+	qos      byte
+	retain   bool
+	template string
+}
+
+// KioskMessage 推送给设备的消息
+type KioskMessage struct {
+	DeviceID      string               `json:"device_id"`
+	Tenant        string               `json:"tenant"`
+	TotalPrize    int64                `json:"total_prize"` // v1，元
+	TotalPrizeFen Fen                  `json:"total_prize_fen"`
+	Results       []VerificationResult `json:"results"`
+	Time          time.Time            `json:"time"`
+}
+
+var kiosks = &kioskPublisher{}
+
+// newKioskPublisher 环境变量：
+//
+//	MQTT_BROKER          tcp://host:1883 或 ssl://host:8883，留空则不启用
+//	MQTT_CLIENT_ID       默认 lottery-server-<hostname>
+//	MQTT_USERNAME / MQTT_PASSWORD
+//	MQTT_QOS             0/1/2，默认 1
+//	MQTT_RETAIN          是否保留最后一条消息，默认 false
+//	MQTT_TOPIC_TEMPLATE  默认 lottery/{tenant}/kiosk/{device}/result
+func newKioskPublisher() (*kioskPublisher, error) {
+	broker := strings.TrimSpace(os.Getenv("MQTT_BROKER"))
+	if broker == "" {
+		return &kioskPublisher{}, nil
+	}
+	clientID := os.Getenv("MQTT_CLIENT_ID")
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "lottery-server-" + host
+	}
+	template := os.Getenv("MQTT_TOPIC_TEMPLATE")
+	if template == "" {
+		template = "lottery/{tenant}/kiosk/{device}/result"
+	}
+	qos := envInt("MQTT_QOS", 1)
+	if qos < 0 || qos > 2 {
+		return nil, fmt.Errorf("MQTT_QOS 只能是 0/1/2")
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(os.Getenv("MQTT_USERNAME")).
+		SetPassword(os.Getenv("MQTT_PASSWORD")).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT 连接断开: %v", err)
+		})
+	client := mqtt.NewClient(opts)
+	// SetConnectRetry 下 Connect 会在后台重试，这里只等首次结果用于启动日志
+	if tok := client.Connect(); tok.WaitTimeout(5*time.Second) && tok.Error() != nil {
+		return nil, tok.Error()
+	}
+	return &kioskPublisher{client: client, qos: byte(qos), retain: envBool("MQTT_RETAIN", false), template: template}, nil
+}
+
+// topicFor 填充 topic 模板；设备编号里的通配符/分隔符替换掉，避免串到别的 topic
+func (p *kioskPublisher) topicFor(origin ScanOrigin) string {
+	clean := strings.NewReplacer("/", "_", "+", "_", "#", "_")
+	return strings.NewReplacer(
+		"{device}", clean.Replace(origin.DeviceID),
+		"{tenant}", clean.Replace(origin.Tenant),
+	).Replace(p.template)
+}
+
+// Publish 仅对带设备编号的扫描推送
+func (p *kioskPublisher) Publish(origin ScanOrigin, results []VerificationResult) {
+	if p.client == nil || origin.DeviceID == "" {
+		return
+	}
+	msg := KioskMessage{DeviceID: origin.DeviceID, Tenant: origin.Tenant, Results: results, Time: time.Now()}
+	for _, r := range results {
+		msg.TotalPrizeFen += r.TotalPrize
+	}
+	msg.TotalPrize = msg.TotalPrizeFen.Yuan()
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	topic := p.topicFor(origin)
+	tok := p.client.Publish(topic, p.qos, p.retain, payload)
+	go func() {
+		if tok.WaitTimeout(10*time.Second) && tok.Error() != nil {
+			log.Printf("MQTT 发布失败 [%s]: %v", topic, tok.Error())
+		}
+	}()
+}
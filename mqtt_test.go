@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKioskTopicFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		origin   ScanOrigin
+		want     string
+	}{
+		{"默认模板", "lottery/{tenant}/kiosk/{device}/result", ScanOrigin{Tenant: "shop-a", DeviceID: "k1"}, "lottery/shop-a/kiosk/k1/result"},
+		{"设备编号里的分隔符和通配符替换掉", "lottery/{tenant}/kiosk/{device}/result", ScanOrigin{Tenant: "shop-a", DeviceID: "a/+/#"}, "lottery/shop-a/kiosk/a____/result"},
+		{"自定义模板", "kiosk/{device}", ScanOrigin{Tenant: "shop-a", DeviceID: "k1"}, "kiosk/k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&kioskPublisher{template: tt.template}).topicFor(tt.origin); got != tt.want {
+				t.Errorf("topicFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewKioskPublisher(t *testing.T) {
+	tests := []struct {
+		name    string
+		broker  string
+		qos     string
+		wantErr bool
+	}{
+		{"未配置时不启用", "", "", false},
+		{"QoS 越界", "tcp://127.0.0.1:1", "3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MQTT_BROKER", tt.broker)
+			t.Setenv("MQTT_QOS", tt.qos)
+			p, err := newKioskPublisher()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && p.client != nil {
+				t.Error("publisher enabled without broker")
+			}
+		})
+	}
+}
+
+func TestDeviceOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		header string
+		form   string
+		want   string
+	}{
+		{"请求头", "k1", "", "k1"},
+		{"表单字段", "", "k2", "k2"},
+		{"请求头优先", "k1", "k2", "k1"},
+		{"都没有", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			form := url.Values{}
+			if tt.form != "" {
+				form.Set("device_id", tt.form)
+			}
+			c.Request = httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header != "" {
+				c.Request.Header.Set("X-Device-ID", tt.header)
+			}
+			if got := deviceOf(c); got != tt.want {
+				t.Errorf("deviceOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
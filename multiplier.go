@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// MULTIPLIER: 倍数合理性校验
+// ==========================================
+
+// OCR 偶尔把 “1倍”“5倍” 认成 15 倍，奖金跟着放大十几倍。验奖前先检查倍数：
+// 超过该彩种上限的不信；票面印有金额时按 注数×单价×倍数 反算，对不上时优先用金额推出的倍数
+
+const (
+	multiplierWarning   = "MULTIPLIER_SUSPECT"
+	multiplierCorrected = "MULTIPLIER_CORRECTED"
+)
+
+// multiplierLimit 单行倍数上限：MULTIPLIER_LIMITS="双色球=50,大乐透=99" 按彩种配置，
+// 其次是 YAML 彩种定义的 max_multiplier，最后是 MULTIPLIER_MAX（默认 99）
+func multiplierLimit(game string) int {
+	game = canonicalGame(game)
+	for _, pair := range strings.Split(os.Getenv("MULTIPLIER_LIMITS"), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || canonicalGame(k) != game {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+			return n
+		}
+	}
+	if v, ok := dslVerifiers[game]; ok && v.def.MaxMultiplier > 0 {
+		return v.def.MaxMultiplier
+	}
+	return envInt("MULTIPLIER_MAX", 99)
+}
+
+// rowPrice 一注的单价，大乐透追加每注多 1 元
+func rowPrice(t UserTicket) Fen {
+	if t.AddOn {
+		return betPrice + Yuan
+	}
+	return betPrice
+}
+
+// checkMultipliers 校验并修正各行倍数，返回修正后的票面和提示。
+// withAmount 为 false 时不按票面金额反算（票上还有附加玩法，金额包含其他部分）
+func checkMultipliers(lottery LotteryData, withAmount bool) (LotteryData, []string) {
+	if len(lottery.Tickets) == 0 {
+		return lottery, nil
+	}
+	limit := multiplierLimit(lottery.Type)
+	tickets := append([]UserTicket(nil), lottery.Tickets...)
+	var warnings []string
+	for i := range tickets {
+		if tickets[i].Multiplier <= 0 {
+			// 票面没印倍数即为 1 倍
+			tickets[i].Multiplier = 1
+		}
+	}
+
+	spec, known := specOf(lottery.Type)
+	if withAmount && known && lottery.Amount > 0 {
+		var base, expected Fen
+		uniform := true
+		for _, t := range tickets {
+			cost := Fen(betCount(spec, t)) * rowPrice(t)
+			base += cost
+			expected += cost * Fen(t.Multiplier)
+			uniform = uniform && t.Multiplier == tickets[0].Multiplier
+		}
+		printed := Fen(lottery.Amount) * Yuan
+		if base > 0 && expected != printed {
+			// 所有行同一倍数（绝大多数票都是如此）时可以由金额反推倍数
+			if m := int(printed / base); uniform && printed%base == 0 && m >= 1 && m <= limit {
+				for i := range tickets {
+					tickets[i].Multiplier = m
+				}
+				warnings = append(warnings, fmt.Sprintf("%s: 识别倍数与票面金额 %d 元不符，已按金额修正为 %d 倍",
+					multiplierCorrected, lottery.Amount, m))
+				lottery.Tickets = tickets
+				return lottery, warnings
+			}
+			// 金额和倍数至少有一个认错了，奖金不按识别倍数放大
+			for i := range tickets {
+				tickets[i].Multiplier = 1
+			}
+			warnings = append(warnings, fmt.Sprintf("%s: 按识别倍数应付 %s，票面金额为 %d 元，暂按 1 倍计算，请核对票面",
+				multiplierWarning, expected, lottery.Amount))
+		}
+	}
+
+	for i, t := range tickets {
+		if t.Multiplier > limit {
+			warnings = append(warnings, fmt.Sprintf("%s: 第%d行识别为 %d 倍，超过上限 %d 倍，暂按 1 倍计算，请核对票面",
+				multiplierWarning, i+1, t.Multiplier, limit))
+			tickets[i].Multiplier = 1
+		}
+	}
+	lottery.Tickets = tickets
+	return lottery, warnings
+}
+
+// parseAmount 票面金额可能是 14、"14"、"14元"、"￥14.00"
+func parseAmount(v interface{}) int64 {
+	switch x := v.(type) {
+	case float64:
+		return int64(x)
+	case string:
+		s := strings.TrimSpace(x)
+		s = strings.TrimLeft(s, "￥¥金额：: ")
+		end := 0
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9') {
+			end++
+		}
+		n, _ := strconv.ParseInt(s[:end], 10, 64)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMultiplierLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits string
+		max    string
+		game   string
+		want   int
+	}{
+		{"默认 99", "", "", "双色球", 99},
+		{"全局上限", "", "50", "双色球", 50},
+		{"按彩种配置", "双色球=20, 大乐透=30", "50", "双色球", 20},
+		{"其他彩种用全局上限", "双色球=20", "", "大乐透", 99},
+		{"配置值无效时忽略", "双色球=x", "", "双色球", 99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MULTIPLIER_LIMITS", tt.limits)
+			t.Setenv("MULTIPLIER_MAX", tt.max)
+			if got := multiplierLimit(tt.game); got != tt.want {
+				t.Errorf("multiplierLimit(%s) = %d, want %d", tt.game, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMultipliers(t *testing.T) {
+	single := []string{"01", "02", "03", "04", "05", "06"}
+	ssq := func(amount int64, multipliers ...int) LotteryData {
+		l := LotteryData{Type: "双色球", Issue: "2025107", Amount: amount}
+		for _, m := range multipliers {
+			l.Tickets = append(l.Tickets, UserTicket{Red: single, Blue: []string{"07"}, Multiplier: m})
+		}
+		return l
+	}
+	compound := ssq(14, 1)
+	compound.Tickets[0].Red = append(append([]string{}, single...), "07")
+	dlt := LotteryData{Type: "大乐透", Amount: 6, Tickets: []UserTicket{
+		{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"01", "02"}, Multiplier: 2, AddOn: true},
+	}}
+
+	tests := []struct {
+		name       string
+		lottery    LotteryData
+		withAmount bool
+		limits     string
+		want       []int
+		warning    string
+	}{
+		{"未印倍数按 1 倍", ssq(0, 0), true, "", []int{1}, ""},
+		{"超过上限按 1 倍", ssq(0, 150), true, "", []int{1}, multiplierWarning},
+		{"与金额相符", ssq(10, 5), true, "", []int{5}, ""},
+		{"按金额修正倍数", ssq(10, 15), true, "", []int{5}, multiplierCorrected},
+		{"金额无法整除", ssq(7, 15), true, "", []int{1}, multiplierWarning},
+		{"各行倍数不同时不反推", ssq(20, 2, 3), true, "", []int{1, 1}, multiplierWarning},
+		{"反推倍数超过上限", ssq(200, 1), true, "双色球=50", []int{1}, multiplierWarning},
+		{"有附加玩法时不按金额核对", ssq(10, 15), false, "", []int{15}, ""},
+		{"复式按注数计算金额", compound, true, "", []int{1}, ""},
+		{"大乐透追加每注 3 元", dlt, true, "", []int{2}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MULTIPLIER_LIMITS", tt.limits)
+			before := tt.lottery.Tickets[0].Multiplier
+			got, warnings := checkMultipliers(tt.lottery, tt.withAmount)
+			for i, want := range tt.want {
+				if got.Tickets[i].Multiplier != want {
+					t.Errorf("row %d multiplier = %d, want %d", i+1, got.Tickets[i].Multiplier, want)
+				}
+			}
+			w := strings.Join(warnings, "；")
+			if (tt.warning == "") != (w == "") || !strings.HasPrefix(w, tt.warning) {
+				t.Errorf("warnings = %q, want %q", w, tt.warning)
+			}
+			if tt.lottery.Tickets[0].Multiplier != before {
+				t.Error("input tickets modified")
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want int64
+	}{
+		{float64(14), 14},
+		{"14", 14},
+		{"14元", 14},
+		{"￥14.00", 14},
+		{"金额：28", 28},
+		{"看不清", 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := parseAmount(tt.in); got != tt.want {
+			t.Errorf("parseAmount(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// NOTIFY: 通知子系统
+// ==========================================
+
+const (
+	EventScanWon        = "scan.won"
+	EventDrawSyncFailed = "draw.sync_failed"
+	EventTicketSettled  = "ticket.settled"
+	EventDrawPublished  = "draw.published" // 用户订阅的开奖推送，见 subscriptions.go
+)
+
+// NotifyEvent 与具体渠道无关的通知内容，由各渠道自行排版
+type NotifyEvent struct {
+	Kind   string
+	Tenant string
+	Title  string
+	Lines  []string // 一行一条 "标签: 值"
+	Amount Fen      // 中奖金额，非中奖事件为 0；模板里 {{.Amount}} 输出 "5,000元"
+	Time   time.Time
+
+	draw *DrawRecord // draw.published 的开奖数据，短信模板按字段填充
+}
+
+// Notifier 一个通知渠道（企业微信群机器人等）
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, ev NotifyEvent) error
+}
+
+// TenantNotifyConfig 单个租户的通知配置
+type TenantNotifyConfig struct {
+	WinThreshold int64        `json:"win_threshold"` // 单次扫描中奖总额达到该值(元)才通知
+	Events       []string     `json:"events"`        // 订阅的事件，留空表示全部
+	WeCom        []string     `json:"wecom"`         // 企业微信群机器人 webhook 地址
+	Slack        []string     `json:"slack"`         // Slack Incoming Webhook 地址
+	Discord      []string     `json:"discord"`       // Discord 频道 webhook 地址
+	Feishu       []FeishuHook `json:"feishu"`        // 飞书自定义机器人
+	Webhooks     []SignedHook `json:"webhooks"`      // 自有系统的回调地址，请求带 HMAC 签名
+
+	// Templates 按事件类型覆盖 Slack/Discord 的消息模板 (text/template)
+	Templates map[string]string `json:"templates"`
+}
+
+// NotifyConfig NOTIFY_CONFIG 指向的 JSON 文件结构
+type NotifyConfig struct {
+	Tenants map[string]TenantNotifyConfig `json:"tenants"`
+}
+
+func (t TenantNotifyConfig) wants(kind string) bool {
+	if len(t.Events) == 0 {
+		return true
+	}
+	for _, e := range t.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// notifiers 按配置构造该租户启用的所有渠道
+func (t TenantNotifyConfig) notifiers() []Notifier {
+	var list []Notifier
+	for _, hook := range t.WeCom {
+		list = append(list, &weComNotifier{webhook: hook})
+	}
+	for _, hook := range t.Slack {
+		list = append(list, &slackNotifier{webhook: hook, templates: t.Templates})
+	}
+	for _, hook := range t.Discord {
+		list = append(list, &discordNotifier{webhook: hook, templates: t.Templates})
+	}
+	for _, hook := range t.Feishu {
+		list = append(list, &feishuNotifier{hook: hook})
+	}
+	for _, hook := range t.Webhooks {
+		list = append(list, &signedWebhookNotifier{hook: hook})
+	}
+	return list
+}
+
+type notifyHub struct {
+	cfg NotifyConfig
+}
+
+var notifier = &notifyHub{}
+
+// loadNotifyConfig 读取 NOTIFY_CONFIG，未配置时通知功能静默关闭
+func loadNotifyConfig() (*notifyHub, error) {
+	hub := &notifyHub{cfg: NotifyConfig{Tenants: map[string]TenantNotifyConfig{}}}
+	path := strings.TrimSpace(os.Getenv("NOTIFY_CONFIG"))
+	if path == "" {
+		return hub, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &hub.cfg); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	for tenant, tc := range hub.cfg.Tenants {
+		for _, hook := range tc.Webhooks {
+			if hook.URL == "" || hook.Secret == "" {
+				return nil, fmt.Errorf("租户 %s 的 webhooks 需要同时配置 url 和 secret", tenant)
+			}
+		}
+	}
+	return hub, nil
+}
+
+// dispatch 异步发送到租户的所有渠道，发送失败只记日志，不影响主流程
+func (h *notifyHub) dispatch(tenant string, tc TenantNotifyConfig, ev NotifyEvent) {
+	h.send(tenant, tc.notifiers(), ev)
+}
+
+// send 异步发送到给定渠道，成功的计入租户的通知用量
+func (h *notifyHub) send(tenant string, list []Notifier, ev NotifyEvent) {
+	ev.Tenant = tenant
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for _, n := range list {
+		go func(n Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := n.Notify(ctx, ev); err != nil {
+				log.Printf("通知发送失败 [%s/%s/%s]: %v", tenant, n.Name(), ev.Kind, err)
+				return
+			}
+			billing.AddNotification(tenant, n.Name())
+		}(n)
+	}
+}
+
+// ScanWon 单次扫描中奖总额超过租户阈值时通知
+func (h *notifyHub) ScanWon(tenant string, results []VerificationResult) {
+	tc, ok := h.cfg.Tenants[tenant]
+	if !ok || !tc.wants(EventScanWon) {
+		return
+	}
+	var total Fen
+	lines := []string{}
+	for _, r := range results {
+		if r.TotalPrize <= 0 {
+			continue
+		}
+		total += r.TotalPrize
+		lines = append(lines, fmt.Sprintf("%s 第%s期: %s", r.OCRData.Type, r.OCRData.Issue, r.TotalPrize))
+	}
+	if total <= 0 || total < Fen(tc.WinThreshold)*Yuan {
+		return
+	}
+	lines = append(lines, "合计: "+total.String())
+	h.dispatch(tenant, tc, NotifyEvent{Kind: EventScanWon, Title: "中奖提醒", Lines: lines, Amount: total})
+}
+
+// DrawSyncFailed 开奖同步失败，通知所有订阅了该事件的租户
+func (h *notifyHub) DrawSyncFailed(err error) {
+	for tenant, tc := range h.cfg.Tenants {
+		if !tc.wants(EventDrawSyncFailed) {
+			continue
+		}
+		h.dispatch(tenant, tc, NotifyEvent{
+			Kind:  EventDrawSyncFailed,
+			Title: "开奖数据同步失败",
+			Lines: []string{"错误: " + err.Error()},
+		})
+	}
+}
+
+// DrawPublished 新一期开奖推送给订阅的用户，不经过租户的通知配置
+func (h *notifyHub) DrawPublished(sub DrawSubscription, d DrawRecord) {
+	lines := drawLines(d)
+	if d.DrawDate != "" {
+		lines = append(lines, "开奖日期: "+d.DrawDate)
+	}
+	if d.Pool > 0 {
+		lines = append(lines, "奖池: "+(Fen(d.Pool)*Yuan).String())
+	}
+	h.send(sub.Tenant, sub.notifiers(), NotifyEvent{
+		Kind: EventDrawPublished, Title: fmt.Sprintf("%s 第%s期开奖", d.Game, d.Issue), Lines: lines,
+		draw: &d,
+	})
+}
+
+// onScanCompleted 扫描完成后的统一出口：事件发布、租户群提醒、用户大奖短信、待开奖登记
+func onScanCompleted(origin ScanOrigin, fileBytes []byte, results []VerificationResult) {
+	tenant, contact := origin.Tenant, origin.Contact
+	billing.AddScan(tenant, len(results))
+	// 先查重：同一张票重拍只关联原记录，不再重复发中奖通知和开奖提醒
+	orig, rescans := scanHistory.Add(origin, fileBytes, results)
+	for i, diff := range rescans {
+		if diff == nil {
+			continue
+		}
+		results[i].Rescan = diff
+		if !diff.Identical {
+			results[i].Warnings = append(slices.Clip(results[i].Warnings),
+				fmt.Sprintf("与 %s 的扫描结果有 %d 处不同，请核对票面", diff.ScannedAt.In(chinaTime).Format("01-02 15:04"), len(diff.Changes)))
+		}
+	}
+	if orig != "" {
+		for i := range results {
+			results[i].DuplicateOf = orig
+			results[i].Warnings = append(slices.Clip(results[i].Warnings), "该彩票此前已扫描过，本次结果已关联到原记录")
+		}
+		events.Emit(DomainScanCompleted, tenant, results)
+		kiosks.Publish(origin, results)
+		return
+	}
+	events.Emit(DomainScanCompleted, tenant, results)
+	kiosks.Publish(origin, results)
+	h := notifier
+	h.ScanWon(tenant, results)
+	for _, r := range results {
+		if r.TotalPrize > 0 {
+			events.Emit(DomainTicketWon, tenant, r)
+			draw, _ := draws.Get(r.OCRData.Type, r.OCRData.Issue)
+			h.userWon(tenant, contact, r, draw)
+		}
+	}
+	pendingTickets.Watch(tenant, contact, results)
+}
+
+// onScanFailed 识别或验奖失败
+func onScanFailed(tenant, reason string) {
+	events.Emit(DomainScanFailed, tenant, map[string]string{"error": reason})
+}
+
+// TicketSettled 待开奖的票开奖后中奖，提醒租户（门店群等），金额门槛同 scan.won
+func (h *notifyHub) TicketSettled(tenant string, res VerificationResult) {
+	tc, ok := h.cfg.Tenants[tenant]
+	if !ok || !tc.wants(EventTicketSettled) || res.TotalPrize <= 0 || res.TotalPrize < Fen(tc.WinThreshold)*Yuan {
+		return
+	}
+	lines := []string{fmt.Sprintf("%s 第%s期: %s", res.OCRData.Type, res.OCRData.Issue, res.TotalPrize)}
+	h.dispatch(tenant, tc, NotifyEvent{Kind: EventTicketSettled, Title: "开奖后中奖提醒", Lines: lines, Amount: res.TotalPrize})
+}
+
+// PendingTicketWon 待开奖票据开奖后中奖
+func (h *notifyHub) PendingTicketWon(t PendingTicket, res VerificationResult, draw DrawRecord) {
+	h.emailWin(t.Contact.Email, t.Tenant, t.ID, res, draw)
+	h.userWon(t.Tenant, t.Contact, res, draw)
+}
+
+// userWon 大奖短信 + 登记兑奖截止提醒
+func (h *notifyHub) userWon(tenant string, contact UserContact, res VerificationResult, draw DrawRecord) {
+	if contact.Phone == "" {
+		return
+	}
+	deadline := claimDeadline(draw.DrawDate)
+	h.smsBigWin(tenant, contact.Phone, res, deadline)
+	claimReminders.Add(ClaimReminder{
+		Tenant: tenant, Phone: contact.Phone,
+		Game: res.OCRData.Type, Issue: res.OCRData.Issue,
+		Amount: res.TotalPrize, Deadline: deadline,
+	})
+}
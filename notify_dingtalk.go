@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// NOTIFY: 钉钉群机器人
+// ==========================================
+
+// dingTalkNotifier 自定义机器人 webhook，配置了加签密钥时附带 timestamp/sign
+// 文档: https://open.dingtalk.com/document/robots/custom-robot-access
+type dingTalkNotifier struct {
+	webhook string
+	secret  string
+}
+
+func (n *dingTalkNotifier) Name() string { return "dingtalk" }
+
+func (n *dingTalkNotifier) signedURL() string {
+	if n.secret == "" {
+		return n.webhook
+	}
+	ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(n.secret))
+	mac.Write([]byte(ts + "\n" + n.secret))
+	sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	sep := "?"
+	if strings.Contains(n.webhook, "?") {
+		sep = "&"
+	}
+	return n.webhook + sep + "timestamp=" + ts + "&sign=" + sign
+}
+
+func (n *dingTalkNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n\n", ev.Title)
+	for _, line := range ev.Lines {
+		fmt.Fprintf(&sb, "- %s\n", line)
+	}
+	fmt.Fprintf(&sb, "\n> %s", ev.Time.Format("2006-01-02 15:04:05"))
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": ev.Title, "text": sb.String()},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.signedURL(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("钉钉响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("钉钉返回错误 %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
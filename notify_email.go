@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// NOTIFY: 邮件 (SMTP)
+// ==========================================
+
+// 兑奖期限：自开奖之日起 60 个自然日
+const claimPeriodDays = 60
+
+// smtpConfig 来自环境变量 SMTP_HOST / SMTP_PORT / SMTP_USER / SMTP_PASSWORD / SMTP_FROM
+type smtpConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+func loadSMTPConfig() (smtpConfig, bool) {
+	cfg := smtpConfig{
+		Host:     strings.TrimSpace(os.Getenv("SMTP_HOST")),
+		Port:     strings.TrimSpace(os.Getenv("SMTP_PORT")),
+		User:     os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     strings.TrimSpace(os.Getenv("SMTP_FROM")),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "465"
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.User
+	}
+	return cfg, cfg.Host != "" && cfg.From != ""
+}
+
+// winEmailData 中奖邮件模板的数据
+type winEmailData struct {
+	Game          string
+	Issue         string
+	DrawDate      string
+	Winning       WinningNumbers
+	Rows          []winEmailRow
+	Total         string // 元，如 "5000" / "12.5"，模板中自行加单位
+	ClaimDeadline string
+}
+
+type winEmailRow struct {
+	Index  int
+	Red    string
+	Blue   string
+	Times  int
+	Prize  string
+	Status string
+}
+
+const defaultWinEmailTemplate = `<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+<h2 style="color:#d9363e">恭喜中奖！</h2>
+<p>您登记的 <b>{{.Game}}</b> 第 <b>{{.Issue}}</b> 期彩票已开奖{{if .DrawDate}}（{{.DrawDate}}）{{end}}。</p>
+<p>开奖号码：<span style="color:#d9363e">{{range .Winning.Red}}{{.}} {{end}}</span>
+<span style="color:#1677ff">{{range .Winning.Blue}}{{.}} {{end}}</span></p>
+<table border="1" cellpadding="6" cellspacing="0" style="border-collapse:collapse">
+<tr><th>行</th><th>红球/号码</th><th>蓝球</th><th>倍数</th><th>奖金</th><th>结果</th></tr>
+{{range .Rows}}<tr><td>{{.Index}}</td><td>{{.Red}}</td><td>{{.Blue}}</td><td>{{.Times}}</td><td>{{.Prize}}元</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+<p>合计奖金：<b style="color:#d9363e">{{.Total}}元</b></p>
+<p>请在 <b>{{.ClaimDeadline}}</b> 前携带彩票原件兑奖，逾期视为弃奖。</p>
+</body></html>`
+
+// winEmailTemplate 可用 EMAIL_TEMPLATE 指定自定义 HTML 模板文件
+func winEmailTemplate() (*template.Template, error) {
+	text := defaultWinEmailTemplate
+	if path := strings.TrimSpace(os.Getenv("EMAIL_TEMPLATE")); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(raw)
+	}
+	return template.New("win").Parse(text)
+}
+
+// claimDeadline 以开奖日期起算兑奖截止日，缺少开奖日期时按今天算
+func claimDeadline(drawDate string) string {
+	return claimDeadlineAfter(drawDate, claimPeriodDays)
+}
+
+// claimDeadlineAfter 开奖日期起 days 天的兑奖截止日
+func claimDeadlineAfter(drawDate string, days int) string {
+	start, err := time.ParseInLocation("2006-01-02", drawDate, time.Local)
+	if err != nil {
+		start = time.Now()
+	}
+	return start.AddDate(0, 0, days).Format("2006-01-02")
+}
+
+func buildWinEmail(res VerificationResult, draw DrawRecord) winEmailData {
+	data := winEmailData{
+		Game:          res.OCRData.Type,
+		Issue:         res.OCRData.Issue,
+		DrawDate:      draw.DrawDate,
+		Winning:       WinningNumbers{Red: draw.Red, Blue: draw.Blue},
+		Total:         res.TotalPrize.Decimal(),
+		ClaimDeadline: claimDeadline(draw.DrawDate),
+	}
+	for i, d := range res.Details {
+		row := winEmailRow{Index: d.RowIndex, Prize: d.Prize.Decimal(), Status: d.Status}
+		if i < len(res.OCRData.Tickets) {
+			t := res.OCRData.Tickets[i]
+			row.Red, row.Blue, row.Times = strings.Join(t.Red, " "), strings.Join(t.Blue, " "), t.Multiplier
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return data
+}
+
+// sendMail 465 端口走隐式 TLS，其余端口交给 smtp.SendMail（服务器支持时自动 STARTTLS）
+func sendMail(ctx context.Context, cfg smtpConfig, to, subject, htmlBody string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(htmlBody))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)
+	}
+	if cfg.Port != "465" {
+		return smtp.SendMail(addr, auth, cfg.From, []string{to}, msg.Bytes())
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: cfg.Host}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailWin 给用户发中奖邮件，ref 仅用于日志定位
+func (h *notifyHub) emailWin(to, tenant, ref string, res VerificationResult, draw DrawRecord) {
+	cfg, ok := loadSMTPConfig()
+	if !ok || to == "" {
+		return
+	}
+	tpl, err := winEmailTemplate()
+	if err != nil {
+		log.Printf("邮件模板加载失败: %v", err)
+		return
+	}
+	var body bytes.Buffer
+	if err := tpl.Execute(&body, buildWinEmail(res, draw)); err != nil {
+		log.Printf("邮件模板渲染失败: %v", err)
+		return
+	}
+	subject := fmt.Sprintf("【中奖通知】%s 第%s期 中奖 %s", res.OCRData.Type, res.OCRData.Issue, res.TotalPrize)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sendMail(ctx, cfg, to, subject, body.String()); err != nil {
+			log.Printf("中奖邮件发送失败 [%s/%s]: %v", tenant, ref, err)
+			return
+		}
+		billing.AddNotification(tenant, "email")
+	}()
+}
+
+// emailNotifier 把通知事件以纯文本排版发到一个邮箱，用于用户订阅的推送
+type emailNotifier struct {
+	to string
+}
+
+func (n *emailNotifier) Name() string { return "email" }
+
+func (n *emailNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	cfg, ok := loadSMTPConfig()
+	if !ok {
+		return fmt.Errorf("未配置 SMTP")
+	}
+	var body strings.Builder
+	for _, line := range ev.Lines {
+		body.WriteString(template.HTMLEscapeString(line) + "<br>\r\n")
+	}
+	return sendMail(ctx, cfg, n.to, ev.Title, body.String())
+}
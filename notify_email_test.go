@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadSMTPConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantOK   bool
+		wantPort string
+		wantFrom string
+	}{
+		{"未配置", nil, false, "465", ""},
+		{"发件人默认取用户名", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_USER": "bot@example.com"}, true, "465", "bot@example.com"},
+		{"显式端口与发件人", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "587", "SMTP_FROM": "noreply@example.com"}, true, "587", "noreply@example.com"},
+		{"缺少发件人", map[string]string{"SMTP_HOST": "smtp.example.com"}, false, "465", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_USER", "SMTP_PASSWORD", "SMTP_FROM"} {
+				t.Setenv(k, tt.env[k])
+			}
+			cfg, ok := loadSMTPConfig()
+			if ok != tt.wantOK || cfg.Port != tt.wantPort || cfg.From != tt.wantFrom {
+				t.Errorf("loadSMTPConfig() = %+v, %v; want port %q from %q ok %v", cfg, ok, tt.wantPort, tt.wantFrom, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClaimDeadlineAfter(t *testing.T) {
+	tests := []struct {
+		drawDate string
+		days     int
+		want     string
+	}{
+		{"2025-01-01", 60, "2025-03-02"},
+		{"2024-12-31", 1, "2025-01-01"},
+		{"2024-02-28", 1, "2024-02-29"},
+	}
+	for _, tt := range tests {
+		if got := claimDeadlineAfter(tt.drawDate, tt.days); got != tt.want {
+			t.Errorf("claimDeadlineAfter(%q, %d) = %q, want %q", tt.drawDate, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestBuildWinEmail(t *testing.T) {
+	res := VerificationResult{
+		OCRData: LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+			{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 2},
+			{Red: []string{"01", "03", "05", "06", "08", "09"}, Blue: []string{"16"}, Multiplier: 1},
+		}},
+		TotalPrize: 10000000*Yuan + 50,
+		Details: []ResultDetail{
+			{RowIndex: 1, Prize: 10000000 * Yuan, Status: "中奖"},
+			{RowIndex: 2, Prize: 50, Status: "未中奖"},
+		},
+	}
+	draw := DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, DrawDate: "2025-09-16"}
+	data := buildWinEmail(res, draw)
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"合计按元", data.Total, "10000000.5"},
+		{"兑奖截止日", data.ClaimDeadline, claimDeadlineAfter("2025-09-16", claimPeriodDays)},
+		{"第一行号码", data.Rows[0].Red + " + " + data.Rows[0].Blue, "02 11 15 21 28 33 + 07"},
+		{"第二行奖金", data.Rows[1].Prize, "0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("EMAIL_TEMPLATE", "")
+	tmpl, err := winEmailTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "10000000.5元") {
+		t.Errorf("rendered email missing total: %s", buf.String())
+	}
+}
+
+func TestPendingWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []VerificationResult
+		want    int
+	}{
+		{"只登记未开奖的票", []VerificationResult{{Pending: true}, {Pending: false}, {Pending: true}}, 2},
+		{"全部已开奖", []VerificationResult{{}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &pendingStore{tickets: map[string]PendingTicket{}}
+			s.Watch("shop-a", UserContact{Email: "a@example.com"}, tt.results)
+			if len(s.tickets) != tt.want {
+				t.Errorf("watched %d tickets, want %d", len(s.tickets), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// NOTIFY: 短信 (阿里云 / 腾讯云)
+// ==========================================
+
+// smsParam 模板变量；阿里云按名称填充，腾讯云按顺序填充
+type smsParam struct {
+	Key   string
+	Value string
+}
+
+// smsProvider 短信服务商
+type smsProvider interface {
+	Name() string
+	Send(ctx context.Context, phone, templateCode string, params []smsParam) error
+}
+
+// smsConfig 环境变量：
+//
+//	SMS_PROVIDER=aliyun|tencent
+//	SMS_ACCESS_KEY_ID / SMS_ACCESS_KEY_SECRET / SMS_SIGN_NAME / SMS_REGION
+//	SMS_SDK_APP_ID                    腾讯云短信应用 ID
+//	SMS_TEMPLATE_BIG_WIN              大奖提醒模板，变量: game, issue, amount, deadline
+//	SMS_TEMPLATE_CLAIM_REMINDER       兑奖截止提醒模板，变量: game, issue, amount, deadline, days
+//	SMS_TEMPLATE_DRAW_RESULT          开奖推送模板（用户订阅），变量: game, issue, numbers
+//	SMS_BIG_WIN_THRESHOLD             大奖阈值(元)，默认 10000
+type smsConfig struct {
+	TemplateBigWin        string
+	TemplateClaimReminder string
+	TemplateDrawResult    string
+	BigWinThreshold       int64
+}
+
+func loadSMSProvider() (smsProvider, smsConfig) {
+	cfg := smsConfig{
+		TemplateBigWin:        strings.TrimSpace(os.Getenv("SMS_TEMPLATE_BIG_WIN")),
+		TemplateClaimReminder: strings.TrimSpace(os.Getenv("SMS_TEMPLATE_CLAIM_REMINDER")),
+		TemplateDrawResult:    strings.TrimSpace(os.Getenv("SMS_TEMPLATE_DRAW_RESULT")),
+		BigWinThreshold:       int64(envInt("SMS_BIG_WIN_THRESHOLD", 10000)),
+	}
+	keyID, secret := os.Getenv("SMS_ACCESS_KEY_ID"), os.Getenv("SMS_ACCESS_KEY_SECRET")
+	sign, region := os.Getenv("SMS_SIGN_NAME"), os.Getenv("SMS_REGION")
+	switch strings.ToLower(os.Getenv("SMS_PROVIDER")) {
+	case "aliyun":
+		if region == "" {
+			region = "cn-hangzhou"
+		}
+		return &aliyunSMS{keyID: keyID, secret: secret, signName: sign, region: region}, cfg
+	case "tencent":
+		if region == "" {
+			region = "ap-guangzhou"
+		}
+		return &tencentSMS{secretID: keyID, secretKey: secret, signName: sign, region: region, appID: os.Getenv("SMS_SDK_APP_ID")}, cfg
+	}
+	return nil, cfg
+}
+
+// --- 限流：每个号码每小时、全局每分钟 ---
+
+type smsRateLimiter struct {
+	mu       sync.Mutex
+	perPhone int
+	global   int
+	phones   map[string][]time.Time
+	recent   []time.Time
+}
+
+var smsLimiter = &smsRateLimiter{
+	perPhone: envInt("SMS_RATE_PER_PHONE_HOUR", 5),
+	global:   envInt("SMS_RATE_GLOBAL_MINUTE", 60),
+	phones:   map[string][]time.Time{},
+}
+
+func pruneBefore(list []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(list) && list[i].Before(cutoff) {
+		i++
+	}
+	return list[i:]
+}
+
+// Allow 滑动窗口计数，放行时同时记账
+func (l *smsRateLimiter) Allow(phone string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.recent = pruneBefore(l.recent, now.Add(-time.Minute))
+	sent := pruneBefore(l.phones[phone], now.Add(-time.Hour))
+	if len(l.recent) >= l.global || len(sent) >= l.perPhone {
+		l.phones[phone] = sent
+		return false
+	}
+	l.recent = append(l.recent, now)
+	l.phones[phone] = append(sent, now)
+	return true
+}
+
+// sendSMS 统一入口：检查配置与限流后异步发送
+func sendSMS(tenant, phone, templateCode string, params []smsParam) {
+	provider, _ := loadSMSProvider()
+	if provider == nil || templateCode == "" || phone == "" {
+		return
+	}
+	if !smsLimiter.Allow(phone) {
+		log.Printf("短信限流，丢弃 [%s/%s]", tenant, templateCode)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := provider.Send(ctx, phone, templateCode, params); err != nil {
+			log.Printf("短信发送失败 [%s/%s/%s]: %v", tenant, provider.Name(), templateCode, err)
+			return
+		}
+		billing.AddNotification(tenant, "sms")
+	}()
+}
+
+// smsBigWin 中奖金额超过阈值时发大奖短信
+func (h *notifyHub) smsBigWin(tenant, phone string, res VerificationResult, deadline string) {
+	_, cfg := loadSMSProvider()
+	if res.TotalPrize < Fen(cfg.BigWinThreshold)*Yuan {
+		return
+	}
+	sendSMS(tenant, phone, cfg.TemplateBigWin, []smsParam{
+		{"game", res.OCRData.Type},
+		{"issue", res.OCRData.Issue},
+		{"amount", res.TotalPrize.Decimal()},
+		{"deadline", deadline},
+	})
+}
+
+// smsNotifier 用户订阅的开奖推送短信，只处理带开奖数据的事件
+type smsNotifier struct {
+	phone string
+}
+
+func (n *smsNotifier) Name() string { return "sms" }
+
+func (n *smsNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	provider, cfg := loadSMSProvider()
+	if provider == nil || cfg.TemplateDrawResult == "" || ev.draw == nil {
+		return fmt.Errorf("未配置开奖短信模板")
+	}
+	if !smsLimiter.Allow(n.phone) {
+		return fmt.Errorf("短信限流")
+	}
+	d := ev.draw
+	return provider.Send(ctx, n.phone, cfg.TemplateDrawResult, []smsParam{
+		{"game", d.Game},
+		{"issue", d.Issue},
+		{"numbers", strings.Join(append(append([]string(nil), d.Red...), d.Blue...), " ")},
+	})
+}
+
+// --- 阿里云 (RPC 签名 v1, HMAC-SHA1) ---
+
+type aliyunSMS struct {
+	keyID, secret, signName, region string
+}
+
+func (p *aliyunSMS) Name() string { return "aliyun" }
+
+// aliyunEncode 阿里云要求的 RFC3986 编码
+func aliyunEncode(s string) string {
+	e := url.QueryEscape(s)
+	e = strings.ReplaceAll(e, "+", "%20")
+	e = strings.ReplaceAll(e, "*", "%2A")
+	return strings.ReplaceAll(e, "%7E", "~")
+}
+
+func (p *aliyunSMS) Send(ctx context.Context, phone, templateCode string, params []smsParam) error {
+	tplParams := map[string]string{}
+	for _, kv := range params {
+		tplParams[kv.Key] = kv.Value
+	}
+	tplJSON, _ := json.Marshal(tplParams)
+
+	query := map[string]string{
+		"AccessKeyId":      p.keyID,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"PhoneNumbers":     phone,
+		"RegionId":         p.region,
+		"SignName":         p.signName,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   newJobID(),
+		"SignatureVersion": "1.0",
+		"TemplateCode":     templateCode,
+		"TemplateParam":    string(tplJSON),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = aliyunEncode(k) + "=" + aliyunEncode(query[k])
+	}
+	canonical := strings.Join(pairs, "&")
+	mac := hmac.New(sha1.New, []byte(p.secret+"&"))
+	mac.Write([]byte("GET&%2F&" + aliyunEncode(canonical)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	endpoint := "https://dysmsapi.aliyuncs.com/?Signature=" + aliyunEncode(signature) + "&" + canonical
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("阿里云响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if result.Code != "OK" {
+		return fmt.Errorf("阿里云返回错误 %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// --- 腾讯云 (TC3-HMAC-SHA256) ---
+
+type tencentSMS struct {
+	secretID, secretKey, signName, region, appID string
+}
+
+func (p *tencentSMS) Name() string { return "tencent" }
+
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *tencentSMS) Send(ctx context.Context, phone, templateCode string, params []smsParam) error {
+	const host, service, action, version = "sms.tencentcloudapi.com", "sms", "SendSms", "2021-01-11"
+	if !strings.HasPrefix(phone, "+") {
+		phone = "+86" + phone
+	}
+	values := make([]string, len(params))
+	for i, kv := range params {
+		values[i] = kv.Value
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"PhoneNumberSet":   []string{phone},
+		"SmsSdkAppId":      p.appID,
+		"SignName":         p.signName,
+		"TemplateId":       templateCode,
+		"TemplateParamSet": values,
+	})
+
+	now := time.Now().UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	date := now.Format("2006-01-02")
+	contentType := "application/json; charset=utf-8"
+	canonicalRequest := strings.Join([]string{
+		"POST", "/", "",
+		"content-type:" + contentType + "\nhost:" + host + "\nx-tc-action:" + strings.ToLower(action) + "\n",
+		"content-type;host;x-tc-action",
+		sha256Hex(payload),
+	}, "\n")
+	scope := date + "/" + service + "/tc3_request"
+	stringToSign := "TC3-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	secretDate := hmacSHA256([]byte("TC3"+p.secretKey), date)
+	secretService := hmacSHA256(secretDate, service)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Host", host)
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Timestamp", timestamp)
+	req.Header.Set("X-TC-Version", version)
+	req.Header.Set("X-TC-Region", p.region)
+	req.Header.Set("Authorization", fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host;x-tc-action, Signature=%s",
+		p.secretID, scope, signature))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Response struct {
+			Error *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error"`
+			SendStatusSet []struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"SendStatusSet"`
+		} `json:"Response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("腾讯云响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if e := result.Response.Error; e != nil {
+		return fmt.Errorf("腾讯云返回错误 %s: %s", e.Code, e.Message)
+	}
+	for _, st := range result.Response.SendStatusSet {
+		if st.Code != "Ok" {
+			return fmt.Errorf("腾讯云发送失败 %s: %s", st.Code, st.Message)
+		}
+	}
+	return nil
+}
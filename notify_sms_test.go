@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSMSRateLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		perPhone int
+		global   int
+		phones   []string
+		want     []bool
+	}{
+		{"单个号码每小时上限", 2, 10, []string{"a", "a", "a", "b"}, []bool{true, true, false, true}},
+		{"全局每分钟上限", 5, 2, []string{"a", "b", "c"}, []bool{true, true, false}},
+		{"被拒绝的不记账", 1, 10, []string{"a", "a", "b", "b"}, []bool{true, false, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &smsRateLimiter{perPhone: tt.perPhone, global: tt.global, phones: map[string][]time.Time{}}
+			for i, phone := range tt.phones {
+				if got := l.Allow(phone); got != tt.want[i] {
+					t.Errorf("Allow(%q) #%d = %v, want %v", phone, i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSMSProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		region     string
+		wantName   string
+		wantRegion string
+	}{
+		{"未配置", "", "", "", ""},
+		{"阿里云默认地域", "aliyun", "", "aliyun", "cn-hangzhou"},
+		{"腾讯云默认地域", "Tencent", "", "tencent", "ap-guangzhou"},
+		{"显式地域", "aliyun", "cn-shanghai", "aliyun", "cn-shanghai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SMS_PROVIDER", tt.provider)
+			t.Setenv("SMS_REGION", tt.region)
+			p, _ := loadSMSProvider()
+			var name, region string
+			switch p := p.(type) {
+			case *aliyunSMS:
+				name, region = p.Name(), p.region
+			case *tencentSMS:
+				name, region = p.Name(), p.region
+			}
+			if name != tt.wantName || region != tt.wantRegion {
+				t.Errorf("provider = %q/%q, want %q/%q", name, region, tt.wantName, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestAliyunEncode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a b", "a%20b"},
+		{"a*b", "a%2Ab"},
+		{"a~b", "a~b"},
+		{"双色球", "%E5%8F%8C%E8%89%B2%E7%90%83"},
+	}
+	for _, tt := range tests {
+		if got := aliyunEncode(tt.in); got != tt.want {
+			t.Errorf("aliyunEncode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReminderCheck(t *testing.T) {
+	t.Setenv("SMS_PROVIDER", "")
+	t.Setenv("SMS_CLAIM_REMIND_DAYS", "7")
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		deadline string
+		sent     bool
+		wantKept bool
+		wantSent bool
+	}{
+		{"进入提醒窗口", "2025-03-05", false, true, true},
+		{"还没到提醒窗口", "2025-04-30", false, true, false},
+		{"已经提醒过", "2025-03-05", true, true, true},
+		{"过了截止日清理", "2025-02-27", false, false, false},
+		{"截止日无法解析", "soon", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ClaimReminder{Phone: "13800000000", Game: "双色球", Issue: "2025107", Deadline: tt.deadline, Sent: tt.sent}
+			s := &reminderStore{items: map[string]ClaimReminder{r.key(): r}}
+			s.check(now)
+			got, kept := s.items[r.key()]
+			if kept != tt.wantKept || got.Sent != tt.wantSent {
+				t.Errorf("kept = %v sent = %v, want %v %v", kept, got.Sent, tt.wantKept, tt.wantSent)
+			}
+		})
+	}
+}
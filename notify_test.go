@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantNotifyWants(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		kind   string
+		want   bool
+	}{
+		{"未配置事件表示全部", nil, EventScanWon, true},
+		{"订阅了该事件", []string{EventDrawSyncFailed, EventScanWon}, EventScanWon, true},
+		{"未订阅", []string{EventDrawSyncFailed}, EventScanWon, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (TenantNotifyConfig{Events: tt.events}).wants(tt.kind); got != tt.want {
+				t.Errorf("wants(%q) = %v, want %v", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeComNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		response string
+		wantErr  string
+		color    string
+	}{
+		{"中奖提醒用 warning 颜色", EventScanWon, `{"errcode":0,"errmsg":"ok"}`, "", "warning"},
+		{"其他事件用 info 颜色", EventDrawSyncFailed, `{"errcode":0,"errmsg":"ok"}`, "", "info"},
+		{"企业微信返回错误", EventScanWon, `{"errcode":93000,"errmsg":"invalid webhook url"}`, "93000", "warning"},
+		{"响应无法解析", EventScanWon, `<html>`, "解析失败", "warning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				MsgType  string            `json:"msgtype"`
+				Markdown map[string]string `json:"markdown"`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			n := &weComNotifier{webhook: srv.URL}
+			err := n.Notify(context.Background(), NotifyEvent{
+				Kind: tt.kind, Tenant: "shop-a", Title: "中奖提醒", Lines: []string{"合计: 10元"}, Time: time.Now(),
+			})
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			content := got.Markdown["content"]
+			if got.MsgType != "markdown" || !strings.Contains(content, `color="`+tt.color+`"`) || !strings.Contains(content, "> 合计: 10元") {
+				t.Errorf("unexpected payload %q: %s", got.MsgType, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ==========================================
+// NOTIFY: Slack / Discord webhook
+// ==========================================
+
+// 默认消息模板，租户可在 NOTIFY_CONFIG 的 templates 中按事件类型覆盖，
+// 模板数据即 NotifyEvent（.Kind .Tenant .Title .Lines .Amount .Time）
+var defaultEventTemplates = map[string]string{
+	EventScanWon: "*{{.Title}}* ({{.Tenant}})\n{{range .Lines}}• {{.}}\n{{end}}",
+	EventDrawSyncFailed: "*{{.Title}}*\n{{range .Lines}}{{.}}\n{{end}}" +
+		"_{{.Time.Format \"2006-01-02 15:04:05\"}}_",
+}
+
+const fallbackEventTemplate = "*{{.Title}}*\n{{range .Lines}}{{.}}\n{{end}}"
+
+// renderEvent 按事件类型选模板渲染纯文本消息
+func renderEvent(templates map[string]string, ev NotifyEvent) (string, error) {
+	text, ok := templates[ev.Kind]
+	if !ok {
+		text, ok = defaultEventTemplates[ev.Kind]
+	}
+	if !ok {
+		text = fallbackEventTemplate
+	}
+	tpl, err := template.New(ev.Kind).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("模板 %s 解析失败: %v", ev.Kind, err)
+	}
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, ev); err != nil {
+		return "", fmt.Errorf("模板 %s 渲染失败: %v", ev.Kind, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// postJSON 发送 JSON 并把非 2xx 响应转换为错误
+func postJSON(ctx context.Context, endpoint string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return postPayload(ctx, endpoint, payload, nil)
+}
+
+// postPayload 发送已序列化的 JSON，可附加请求头
+func postPayload(ctx context.Context, endpoint string, payload []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// slackNotifier Incoming Webhook，消息体 {"text": ...}
+type slackNotifier struct {
+	webhook   string
+	templates map[string]string
+}
+
+func (n *slackNotifier) Name() string { return "slack" }
+
+func (n *slackNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	text, err := renderEvent(n.templates, ev)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.webhook, map[string]string{"text": text})
+}
+
+// discordNotifier 频道 webhook，消息体 {"content": ...}，单条上限 2000 字符
+type discordNotifier struct {
+	webhook   string
+	templates map[string]string
+}
+
+func (n *discordNotifier) Name() string { return "discord" }
+
+func (n *discordNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	text, err := renderEvent(n.templates, ev)
+	if err != nil {
+		return err
+	}
+	if r := []rune(text); len(r) > 2000 {
+		text = string(r[:1997]) + "..."
+	}
+	return postJSON(ctx, n.webhook, map[string]string{"content": text})
+}
+
+// ==========================================
+// NOTIFY: 通用回调（HMAC 签名）
+// ==========================================
+
+// 接入方自己的系统通过 webhooks 接收结构化的通知（JSON，见 webhookPayload）。每个地址单独配置密钥，
+// 每次投递都带以下请求头，接收方据此确认回调确实来自本服务、且内容未被篡改：
+//
+//	X-Lottery-Delivery   投递编号，可用于去重
+//	X-Lottery-Timestamp  发送时的 Unix 秒
+//	X-Lottery-Signature  v1=hex(HMAC-SHA256(密钥, 时间戳 + "." + 原始请求体))
+//
+// 接收方校验步骤：读出原始请求体（不要先解析再序列化），按同样方式计算签名，
+// 用常量时间比较与 v1= 后的值比对；再检查时间戳与当前时间相差不超过 5 分钟，防止重放。
+// 更换密钥时可以暂时配置多个密钥（secrets），签名头里会依次给出每个密钥的 v1= 值，以逗号分隔
+
+// SignedHook 一个回调地址；secret 必填
+type SignedHook struct {
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Secrets []string `json:"secrets,omitempty"` // 轮换期间的额外密钥
+}
+
+// webhookPayload 回调请求体
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Tenant    string    `json:"tenant"`
+	Title     string    `json:"title"`
+	Lines     []string  `json:"lines"`
+	AmountFen Fen       `json:"amount_fen,omitempty"`
+	Amount    string    `json:"amount,omitempty"` // 展示用，如 "3,000元"
+	Time      time.Time `json:"time"`
+}
+
+type signedWebhookNotifier struct {
+	hook SignedHook
+}
+
+func (n *signedWebhookNotifier) Name() string { return "webhook" }
+
+// webhookSignature 计算签名头的值
+func webhookSignature(secrets []string, timestamp string, body []byte) string {
+	var sigs []string
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		sigs = append(sigs, "v1="+hex.EncodeToString(hmacSHA256([]byte(s), timestamp+"."+string(body))))
+	}
+	return strings.Join(sigs, ",")
+}
+
+func (n *signedWebhookNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	p := webhookPayload{ID: newJobID(), Kind: ev.Kind, Tenant: ev.Tenant, Title: ev.Title, Lines: ev.Lines, Time: ev.Time}
+	if ev.Amount > 0 {
+		p.AmountFen, p.Amount = ev.Amount, ev.Amount.String()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return postPayload(ctx, n.hook.URL, body, map[string]string{
+		"X-Lottery-Delivery":  p.ID,
+		"X-Lottery-Timestamp": ts,
+		"X-Lottery-Signature": webhookSignature(append([]string{n.hook.Secret}, n.hook.Secrets...), ts, body),
+	})
+}
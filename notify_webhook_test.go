@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderEvent(t *testing.T) {
+	ev := NotifyEvent{Kind: EventScanWon, Tenant: "shop-a", Title: "中奖提醒", Lines: []string{"合计: 10元"}, Amount: 10 * Yuan,
+		Time: time.Date(2025, 9, 16, 21, 30, 0, 0, time.Local)}
+	tests := []struct {
+		name      string
+		templates map[string]string
+		kind      string
+		want      string
+		wantErr   bool
+	}{
+		{"默认中奖模板", nil, EventScanWon, "*中奖提醒* (shop-a)\n• 合计: 10元", false},
+		{"租户覆盖模板", map[string]string{EventScanWon: "{{.Tenant}} 中了 {{.Amount}}"}, EventScanWon, "shop-a 中了 10元", false},
+		{"未知事件用通用模板", nil, "custom.event", "*中奖提醒*\n合计: 10元", false},
+		{"模板语法错误", map[string]string{EventScanWon: "{{.Title"}, EventScanWon, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ev
+			e.Kind = tt.kind
+			got, err := renderEvent(tt.templates, e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderEvent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackDiscordNotifiers(t *testing.T) {
+	long := strings.Repeat("号", 2100)
+	tests := []struct {
+		name     string
+		notifier func(url string) Notifier
+		lines    []string
+		status   int
+		field    string
+		wantLen  int
+		wantErr  bool
+	}{
+		{"Slack 用 text 字段", func(u string) Notifier { return &slackNotifier{webhook: u} }, []string{"a"}, 200, "text", 0, false},
+		{"Discord 用 content 字段", func(u string) Notifier { return &discordNotifier{webhook: u} }, []string{"a"}, 204, "content", 0, false},
+		{"Discord 超长截断到 2000 字", func(u string) Notifier { return &discordNotifier{webhook: u} }, []string{long}, 204, "content", 2000, false},
+		{"非 2xx 返回错误", func(u string) Notifier { return &slackNotifier{webhook: u} }, []string{"a"}, 404, "text", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := tt.notifier(srv.URL).Notify(context.Background(), NotifyEvent{Kind: EventScanWon, Title: "中奖提醒", Lines: tt.lines})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			text, ok := body[tt.field]
+			if !ok || !strings.Contains(text, "中奖提醒") {
+				t.Errorf("payload = %v, want %s field with title", body, tt.field)
+			}
+			if tt.wantLen > 0 && len([]rune(text)) != tt.wantLen {
+				t.Errorf("content length = %d, want %d", len([]rune(text)), tt.wantLen)
+			}
+		})
+	}
+}
+
+// 按文档的步骤在接收方重新计算签名
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"d1"}`)
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("1760000000." + string(body)))
+		return "v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	tests := []struct {
+		name    string
+		secrets []string
+		want    string
+	}{
+		{"单个密钥", []string{"s1"}, sign("s1")},
+		{"轮换期间多个密钥", []string{"s1", "s2"}, sign("s1") + "," + sign("s2")},
+		{"空密钥跳过", []string{"s1", ""}, sign("s1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhookSignature(tt.secrets, "1760000000", body); got != tt.want {
+				t.Errorf("webhookSignature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedWebhookNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    SignedHook
+		status  int
+		wantSig int
+		wantErr bool
+	}{
+		{"带签名投递", SignedHook{Secret: "s1"}, 200, 1, false},
+		{"轮换期间带两个签名", SignedHook{Secret: "s1", Secrets: []string{"s0"}}, 204, 2, false},
+		{"非 2xx 返回错误", SignedHook{Secret: "s1"}, 500, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			tt.hook.URL = srv.URL
+			n := &signedWebhookNotifier{hook: tt.hook}
+			err := n.Notify(context.Background(), NotifyEvent{Kind: EventScanWon, Tenant: "shop-a", Title: "中奖提醒", Lines: []string{"合计: 3,000元"}, Amount: 3000 * Yuan})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			ts := header.Get("X-Lottery-Timestamp")
+			if sec, _ := strconv.ParseInt(ts, 10, 64); time.Since(time.Unix(sec, 0)).Abs() > time.Minute {
+				t.Errorf("X-Lottery-Timestamp = %q", ts)
+			}
+			sig := header.Get("X-Lottery-Signature")
+			if len(strings.Split(sig, ",")) != tt.wantSig || !strings.HasPrefix(sig, webhookSignature([]string{"s1"}, ts, body)) {
+				t.Errorf("X-Lottery-Signature = %q", sig)
+			}
+			var p webhookPayload
+			if err := json.Unmarshal(body, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.ID == "" || p.ID != header.Get("X-Lottery-Delivery") || p.Tenant != "shop-a" || p.AmountFen != 3000*Yuan || p.Amount != "3,000元" {
+				t.Errorf("payload = %+v, delivery = %q", p, header.Get("X-Lottery-Delivery"))
+			}
+		})
+	}
+}
+
+func TestLoadNotifyConfigWebhooks(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"地址和密钥齐全", `{"tenants": {"shop-a": {"webhooks": [{"url": "https://example.com/hook", "secret": "s1"}]}}}`, false},
+		{"缺少密钥", `{"tenants": {"shop-a": {"webhooks": [{"url": "https://example.com/hook"}]}}}`, true},
+		{"缺少地址", `{"tenants": {"shop-a": {"webhooks": [{"secret": "s1"}]}}}`, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_CONFIG", writeTestFile(t, dir, strconv.Itoa(i)+".json", []byte(tt.config)))
+			hub, err := loadNotifyConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadNotifyConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(hub.cfg.Tenants["shop-a"].notifiers()) != 1 {
+				t.Errorf("notifiers = %v", hub.cfg.Tenants["shop-a"].notifiers())
+			}
+		})
+	}
+}
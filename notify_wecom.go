@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ==========================================
+// NOTIFY: 企业微信群机器人
+// ==========================================
+
+// weComNotifier 通过群机器人 webhook 发送 markdown 消息
+// 文档: https://developer.work.weixin.qq.com/document/path/91770
+type weComNotifier struct {
+	webhook string
+}
+
+func (n *weComNotifier) Name() string { return "wecom" }
+
+func (n *weComNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	var sb strings.Builder
+	color := "info"
+	if ev.Kind == EventScanWon {
+		color = "warning"
+	}
+	fmt.Fprintf(&sb, "**<font color=\"%s\">%s</font>**\n", color, ev.Title)
+	for _, line := range ev.Lines {
+		fmt.Fprintf(&sb, "> %s\n", line)
+	}
+	fmt.Fprintf(&sb, "> 租户: %s\n> 时间: %s", ev.Tenant, ev.Time.Format("2006-01-02 15:04:05"))
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": sb.String()},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("企业微信响应解析失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("企业微信返回错误 %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
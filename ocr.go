@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// OCR: 只识别不验奖（POST /api/v1/ocr）
+// ==========================================
+
+// 前端可以先展示识别出的号码让用户确认或修改，再把确认后的 lotteries 提交到 POST /api/v1/verify；
+// 其他系统也可以只复用识别这一步。识别结果与 /api/v1/scan 共用缓存和熔断
+
+// ocrOnlyHandler 返回清洗后的 LotteryData，不查开奖、不验奖、不记录扫描历史
+func ocrOnlyHandler(c *gin.Context) {
+	source, err := imageSourceOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	roi, err := roiOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	budget := newRequestBudget(c.Request.Context())
+	defer budget.Done()
+
+	prepCtx, cancelPrep := budget.Stage(stagePreprocess)
+	fileBytes, ok := scanImageOf(prepCtx, c)
+	cancelPrep()
+	if !ok {
+		return
+	}
+	if err := budget.Check(prepCtx, stagePreprocess); err != nil {
+		respondStageError(c, "", err)
+		return
+	}
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
+		return
+	}
+
+	trace := debugTraceOf(c)
+	defer func() { trace.Save(c, fileBytes) }()
+	ocrCtx, cancelOCR := budget.Stage(stageOCR)
+	lotteries, err := recognizeCached(withROI(withImageName(withImageSource(withDebugTrace(withOCRPasses(ocrCtx, ocrPassesOf(c)), trace), source), imageNameOf(c)), roi), fileBytes, apiKey)
+	if err != nil {
+		if stageErr := budget.Check(ocrCtx, stageOCR); stageErr != nil {
+			err = stageErr
+		}
+	}
+	cancelOCR()
+	if errors.Is(err, errCircuitOpen) {
+		c.JSON(503, gin.H{"error": "AI 识别服务暂时不可用，请稍后重试"})
+		return
+	}
+	if err != nil {
+		respondStageError(c, "AI 识别失败: ", err)
+		return
+	}
+	if lotteries == nil {
+		lotteries = []LotteryData{}
+	}
+	c.JSON(200, gin.H{"image_hash": imageHash(fileBytes), "lotteries": lotteries})
+}
+
+// confirmedVerifyHandler 对用户确认过的号码验奖，结果与 /api/v1/scan 相同并同样记入扫描历史；
+// 可以带 winning 按指定开奖号码验奖（见 supplied.go）
+func confirmedVerifyHandler(c *gin.Context) {
+	var req struct {
+		Lotteries []LotteryData   `json:"lotteries"`
+		Winning   json.RawMessage `json:"winning"`
+	}
+	if !bindValidJSON(c, verifyRequestSchema, &req) {
+		return
+	}
+	supplied, err := parseSuppliedDraws(req.Winning)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "winning 格式错误: " + err.Error()})
+		return
+	}
+	budget := newRequestBudget(withSuppliedDraws(c.Request.Context(), supplied))
+	defer budget.Done()
+	results, err := verifyAll(budget, req.Lotteries)
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	if len(supplied) == 0 {
+		onScanCompleted(originOf(c), nil, results)
+	}
+	respondResults(c, results)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOCROnlyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "default.json", []byte(hookTestFixture))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	tests := []struct {
+		name       string
+		image      []byte
+		apiKey     string
+		wantStatus int
+		wantCount  int
+	}{
+		{"只返回识别结果", []byte("\xff\xd8\xff\xe0ocr-only"), "test", 200, 2},
+		{"未上传图片", nil, "test", 400, 0},
+		{"未配置识别服务", []byte("\xff\xd8\xff\xe0ocr-no-key"), "", 500, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GEMINI_API_KEY", tt.apiKey)
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			if tt.image != nil {
+				fw, _ := mw.CreateFormFile("image", "ticket.jpg")
+				fw.Write(tt.image)
+			}
+			mw.Close()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/ocr", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			ocrOnlyHandler(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				ImageHash string          `json:"image_hash"`
+				Lotteries []LotteryData   `json:"lotteries"`
+				Results   json.RawMessage `json:"results"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if len(resp.Lotteries) != tt.wantCount || resp.Results != nil {
+				t.Errorf("response = %s", w.Body)
+			}
+			if tt.wantStatus == 200 && resp.ImageHash != imageHash(tt.image) {
+				t.Errorf("image_hash = %q", resp.ImageHash)
+			}
+		})
+	}
+	if len(scanHistory.records) != 0 {
+		t.Errorf("ocr-only recorded %d history entries", len(scanHistory.records))
+	}
+}
+
+func TestConfirmedVerifyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+
+	const lottery = `{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02","11","15","21","28","33"], "blue": ["07"], "multiplier": 1}]}`
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantPrize   Fen
+		wantHistory int
+		wantError   string
+	}{
+		{"按开奖数据验奖并记历史", `{"lotteries": [` + lottery + `]}`, 200, 5000000 * Yuan, 1, ""},
+		{"按提供的号码验奖不记历史", `{"lotteries": [` + lottery + `], "winning": {"red": ["01","03","05","06","08","09"], "blue": ["07"]}}`, 200, 5 * Yuan, 0, ""},
+		{"JSON 格式错误", `{"lotteries": [`, 400, 0, 0, ""},
+		{"提供的号码个数不对", `{"lotteries": [` + lottery + `], "winning": {"game": "双色球", "red": ["01"], "blue": ["07"]}}`, 400, 0, 0, "winning 格式错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanHistory = &historyStore{}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/verify", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			confirmedVerifyHandler(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantError != "" && !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want error %q", w.Body, tt.wantError)
+			}
+			if tt.wantStatus == 200 {
+				var results []VerificationResult
+				if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 {
+					t.Fatalf("body = %s: %v", w.Body, err)
+				}
+				if results[0].TotalPrize != tt.wantPrize {
+					t.Errorf("TotalPrize = %s, want %s", results[0].TotalPrize, tt.wantPrize)
+				}
+			}
+			if n := len(scanHistory.records); n != tt.wantHistory {
+				t.Errorf("history = %d records, want %d", n, tt.wantHistory)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// OCRMERGE: 多次识别、逐字段投票合并（?accuracy=high）
+// ==========================================
+
+// 光线差、模糊的照片单次识别偶尔会“编”出号码。高精度模式下对同一张图并发识别多次
+// （OCR_PASS_TEMPERATURES 依次指定每次的温度），逐字段按加权多数合并：
+// 温度越低权重越高，平票时以低温结果为准；没有形成多数的字段记入 uncertain
+
+type ocrPassesKey struct{}
+
+// withOCRPasses 在请求上下文里记录识别次数，沿 recognizeCached 传递
+func withOCRPasses(ctx context.Context, passes int) context.Context {
+	if passes <= 1 {
+		return ctx
+	}
+	return context.WithValue(ctx, ocrPassesKey{}, passes)
+}
+
+func ocrPassesFrom(ctx context.Context) int {
+	if n, ok := ctx.Value(ocrPassesKey{}).(int); ok {
+		return n
+	}
+	return 1
+}
+
+// ocrPassesOf ?accuracy=high 或表单字段 accuracy=high 时返回 OCR_HIGH_ACCURACY_PASSES（默认 3，最多 5）
+func ocrPassesOf(c *gin.Context) int {
+	accuracy := c.Query("accuracy")
+	if accuracy == "" {
+		accuracy = c.PostForm("accuracy")
+	}
+	if accuracy != "high" {
+		return 1
+	}
+	return min(max(envInt("OCR_HIGH_ACCURACY_PASSES", 3), 2), 5)
+}
+
+// passTemperatures 每次识别使用的温度，次数多于配置时沿用最后一个
+func passTemperatures(passes int) []float32 {
+	var temps []float32
+	spec := os.Getenv("OCR_PASS_TEMPERATURES")
+	if spec == "" {
+		spec = "0,0.4,0.8"
+	}
+	for _, s := range strings.Split(spec, ",") {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(s), 32); err == nil {
+			temps = append(temps, float32(v))
+		}
+	}
+	if len(temps) == 0 {
+		temps = []float32{0}
+	}
+	out := make([]float32, passes)
+	for i := range out {
+		out[i] = temps[min(i, len(temps)-1)]
+	}
+	return out
+}
+
+// multiPassOCR 并发识别多次后合并；部分失败时用成功的结果合并，全部失败返回第一个错误
+func multiPassOCR(ctx context.Context, fileBytes []byte, apiKey string, passes int) ([]LotteryData, error) {
+	temps := passTemperatures(passes)
+	outputs := make([][]LotteryData, passes)
+	errs := make([]error, passes)
+	var wg sync.WaitGroup
+	for i := range temps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = callOCRWithBreaker(ctx, fileBytes, apiKey, &temps[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var ok []ocrPass
+	for i := range outputs {
+		if errs[i] == nil {
+			ok = append(ok, ocrPass{data: outputs[i], weight: 1 / (1 + float64(temps[i]))})
+		}
+	}
+	if len(ok) == 0 {
+		return nil, errs[0]
+	}
+	return mergeOCRPasses(ok), nil
+}
+
+type ocrPass struct {
+	data   []LotteryData
+	weight float64
+}
+
+// vote 加权多数投票：返回得票最高的值以及它是否过半；平票时取先出现的（温度更低的）值
+type vote struct {
+	order  []string
+	scores map[string]float64
+	total  float64
+}
+
+func (v *vote) add(value string, weight float64) {
+	if v.scores == nil {
+		v.scores = map[string]float64{}
+	}
+	if _, seen := v.scores[value]; !seen {
+		v.order = append(v.order, value)
+	}
+	v.scores[value] += weight
+	v.total += weight
+}
+
+func (v *vote) winner() (string, bool) {
+	best := ""
+	for _, val := range v.order {
+		if v.scores[val] > v.scores[best] || best == "" {
+			best = val
+		}
+	}
+	return best, v.scores[best]*2 > v.total
+}
+
+// mergeOCRPasses 先按多数确定票数和每张票的行数，只用结构一致的结果参与逐字段投票
+func mergeOCRPasses(passes []ocrPass) []LotteryData {
+	if len(passes) == 1 {
+		return passes[0].data
+	}
+	var count vote
+	for _, p := range passes {
+		count.add(strconv.Itoa(len(p.data)), p.weight)
+	}
+	n, _ := count.winner()
+	lotteries, _ := strconv.Atoi(n)
+
+	out := make([]LotteryData, 0, lotteries)
+	for li := 0; li < lotteries; li++ {
+		var candidates []ocrPass
+		for _, p := range passes {
+			if len(p.data) == lotteries {
+				candidates = append(candidates, ocrPass{data: p.data[li : li+1], weight: p.weight})
+			}
+		}
+		out = append(out, mergeLottery(candidates))
+	}
+	return out
+}
+
+func mergeLottery(passes []ocrPass) LotteryData {
+	var uncertain []string
+	pick := func(field string, get func(l LotteryData) string) string {
+		var v vote
+		for _, p := range passes {
+			v.add(get(p.data[0]), p.weight)
+		}
+		val, majority := v.winner()
+		if !majority {
+			uncertain = append(uncertain, field)
+		}
+		return val
+	}
+
+	merged := LotteryData{
+		Type:     pick("type", func(l LotteryData) string { return l.Type }),
+		Issue:    pick("issue", func(l LotteryData) string { return l.Issue }),
+		SaleTime: pick("sale_time", func(l LotteryData) string { return l.SaleTime }),
+		Serial:   pick("serial", func(l LotteryData) string { return l.Serial }),
+		Station:  pick("station", func(l LotteryData) string { return l.Station }),
+	}
+	rows, _ := strconv.Atoi(pick("tickets.count", func(l LotteryData) string { return strconv.Itoa(len(l.Tickets)) }))
+	var same []ocrPass
+	for _, p := range passes {
+		if len(p.data[0].Tickets) == rows {
+			same = append(same, p)
+		}
+	}
+	passes = same
+
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, " ")
+	}
+	for ri := 0; ri < rows; ri++ {
+		prefix := fmt.Sprintf("tickets[%d].", ri+1)
+		row := func(get func(t UserTicket) string) func(l LotteryData) string {
+			return func(l LotteryData) string { return get(l.Tickets[ri]) }
+		}
+		t := UserTicket{
+			Red:     split(pick(prefix+"red", row(func(t UserTicket) string { return strings.Join(t.Red, " ") }))),
+			Blue:    split(pick(prefix+"blue", row(func(t UserTicket) string { return strings.Join(t.Blue, " ") }))),
+			Dan:     split(pick(prefix+"dan", row(func(t UserTicket) string { return strings.Join(t.Dan, " ") }))),
+			BlueDan: split(pick(prefix+"blue_dan", row(func(t UserTicket) string { return strings.Join(t.BlueDan, " ") }))),
+			Mode:    pick(prefix+"mode", row(func(t UserTicket) string { return t.Mode })),
+		}
+		t.Multiplier, _ = strconv.Atoi(pick(prefix+"multiplier", row(func(t UserTicket) string { return strconv.Itoa(t.Multiplier) })))
+		if t.Red == nil {
+			t.Red = []string{}
+		}
+		if t.Blue == nil {
+			t.Blue = []string{}
+		}
+		merged.Tickets = append(merged.Tickets, t)
+	}
+	if merged.Tickets == nil {
+		merged.Tickets = []UserTicket{}
+	}
+	merged.Uncertain = uncertain
+	return merged
+}
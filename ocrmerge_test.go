@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOCRPassesOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		url    string
+		form   string
+		passes string
+		want   int
+	}{
+		{"默认单次", "/api/v1/verify", "", "", 1},
+		{"查询参数", "/api/v1/verify?accuracy=high", "", "", 3},
+		{"表单字段", "/api/v1/verify", "accuracy=high", "", 3},
+		{"其他取值", "/api/v1/verify?accuracy=low", "", "", 1},
+		{"配置次数", "/api/v1/verify?accuracy=high", "", "4", 4},
+		{"最少两次", "/api/v1/verify?accuracy=high", "", "1", 2},
+		{"最多五次", "/api/v1/verify?accuracy=high", "", "9", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OCR_HIGH_ACCURACY_PASSES", tt.passes)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", tt.url, strings.NewReader(tt.form))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if got := ocrPassesOf(c); got != tt.want {
+				t.Errorf("ocrPassesOf() = %d, want %d", got, tt.want)
+			}
+			if got := ocrPassesFrom(withOCRPasses(context.Background(), tt.want)); got != tt.want {
+				t.Errorf("ocrPassesFrom() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassTemperatures(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		passes int
+		want   []float32
+	}{
+		{"默认", "", 3, []float32{0, 0.4, 0.8}},
+		{"次数多于配置沿用最后一个", "", 5, []float32{0, 0.4, 0.8, 0.8, 0.8}},
+		{"自定义", "0.2, 1", 2, []float32{0.2, 1}},
+		{"无法解析的跳过", "x,0.5", 2, []float32{0.5, 0.5}},
+		{"全部无法解析", "x", 2, []float32{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OCR_PASS_TEMPERATURES", tt.spec)
+			if got := passTemperatures(tt.passes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("passTemperatures(%d) = %v, want %v", tt.passes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVoteWinner(t *testing.T) {
+	type ballot struct {
+		value  string
+		weight float64
+	}
+	tests := []struct {
+		name     string
+		ballots  []ballot
+		want     string
+		majority bool
+	}{
+		{"一致", []ballot{{"a", 1}, {"a", 0.5}}, "a", true},
+		{"多数", []ballot{{"a", 1}, {"b", 0.7}, {"b", 0.6}}, "b", true},
+		{"低温权重更高", []ballot{{"a", 1}, {"b", 0.5}}, "a", true},
+		{"平票取先出现的", []ballot{{"a", 0.5}, {"b", 0.5}}, "a", false},
+		{"三方各不相同", []ballot{{"a", 1}, {"b", 0.7}, {"c", 0.6}}, "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v vote
+			for _, b := range tt.ballots {
+				v.add(b.value, b.weight)
+			}
+			got, majority := v.winner()
+			if got != tt.want || majority != tt.majority {
+				t.Errorf("winner() = %q, %v, want %q, %v", got, majority, tt.want, tt.majority)
+			}
+		})
+	}
+}
+
+func TestMergeOCRPasses(t *testing.T) {
+	ticket := func(red string, blue string) UserTicket {
+		return UserTicket{Red: strings.Split(red, " "), Blue: []string{blue}, Multiplier: 1}
+	}
+	lottery := func(issue string, tickets ...UserTicket) []LotteryData {
+		return []LotteryData{{Type: "双色球", Issue: issue, Tickets: tickets}}
+	}
+	right := ticket("02 11 15 21 28 33", "07")
+	wrong := ticket("02 11 16 21 28 33", "07")
+
+	tests := []struct {
+		name          string
+		passes        []ocrPass
+		wantIssue     string
+		wantRed       string
+		wantUncertain []string
+	}{
+		{"只有一次直接返回", []ocrPass{{lottery("2025107", wrong), 1}}, "2025107", "02 11 16 21 28 33", nil},
+		{"两次对一次", []ocrPass{{lottery("2025107", wrong), 1}, {lottery("2025107", right), 0.7}, {lottery("2025107", right), 0.6}}, "2025107", "02 11 15 21 28 33", nil},
+		{"各不相同记入 uncertain", []ocrPass{{lottery("2025107", right), 1}, {lottery("2025108", wrong), 0.7}, {lottery("2025109", right), 0.6}}, "2025107", "02 11 15 21 28 33", []string{"issue"}},
+		{"行数不同的结果不参与号码投票", []ocrPass{{lottery("2025107", right), 1}, {lottery("2025107", right), 0.7}, {lottery("2025107", wrong, wrong), 0.6}}, "2025107", "02 11 15 21 28 33", nil},
+		{"票数按多数", []ocrPass{{append(lottery("2025107", right), lottery("2025107", right)...), 1}, {lottery("2025107", wrong), 0.7}, {lottery("2025107", wrong), 0.6}}, "2025107", "02 11 16 21 28 33", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeOCRPasses(tt.passes)
+			if len(got) != 1 || len(got[0].Tickets) != 1 {
+				t.Fatalf("mergeOCRPasses() = %+v", got)
+			}
+			if got[0].Issue != tt.wantIssue || strings.Join(got[0].Tickets[0].Red, " ") != tt.wantRed {
+				t.Errorf("merged = %s %v, want %s %s", got[0].Issue, got[0].Tickets[0].Red, tt.wantIssue, tt.wantRed)
+			}
+			if !reflect.DeepEqual(got[0].Uncertain, tt.wantUncertain) {
+				t.Errorf("uncertain = %v, want %v", got[0].Uncertain, tt.wantUncertain)
+			}
+		})
+	}
+}
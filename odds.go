@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ODDS: 中奖概率与期望收益计算
+// ==========================================
+
+// betPrice 每注 2 元
+const betPrice = 2 * Yuan
+
+// LevelOdds 某个奖级的概率
+type LevelOdds struct {
+	Level        int     `json:"level"`
+	Name         string  `json:"name"`
+	Prize        int64   `json:"prize"` // 单注奖金（元，已乘倍数），一二等奖为浮动奖金的估算值
+	PrizeFen     Fen     `json:"prize_fen"`
+	Probability  float64 `json:"probability"`   // 该票至少中一注该奖级的概率
+	ExpectedWins float64 `json:"expected_wins"` // 该奖级的期望中奖注数
+}
+
+// TicketOdds 一行投注的计算结果
+type TicketOdds struct {
+	RowIndex       int         `json:"row_index"`
+	Mode           string      `json:"mode"`
+	Bets           int64       `json:"bets"`
+	Multiplier     int         `json:"multiplier"`
+	Stake          int64       `json:"stake"` // 元
+	StakeFen       Fen         `json:"stake_fen"`
+	Levels         []LevelOdds `json:"levels"`
+	WinProbability float64     `json:"win_probability"` // 至少中一注任意奖级
+	ExpectedValue  float64     `json:"expected_value"`  // 期望奖金（元）
+	ReturnRate     float64     `json:"return_rate"`     // 期望奖金 / 投注金额
+	Summary        string      `json:"summary"`
+}
+
+// binom 组合数 C(n, k)
+func binom(n, k int) float64 {
+	if k < 0 || n < 0 || k > n {
+		return 0
+	}
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+// zoneSelection 一行投注在某个号码区选的号码
+func zoneSelection(z gameZone, t UserTicket) (dan, tuo []string) {
+	switch {
+	case z.Index >= 0:
+		if z.Index < len(t.Red) {
+			return nil, t.Red[z.Index : z.Index+1]
+		}
+		return nil, nil
+	case z.Field == "blue":
+		return t.BlueDan, t.Blue
+	default:
+		return t.Dan, t.Red
+	}
+}
+
+// validateSelection 号码必须在范围内且不重复
+func validateSelection(z gameZone, dan, tuo []string) error {
+	if err := z.checkCount(len(dan), len(tuo)); err != nil {
+		return err
+	}
+	seen := map[int]bool{}
+	for _, s := range append(append([]string{}, dan...), tuo...) {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < z.Min || n > z.Max {
+			return fmt.Errorf("%s号码 %s 超出范围", z.Label, s)
+		}
+		if seen[n] && z.Index < 0 {
+			return fmt.Errorf("%s号码 %s 重复", z.Label, s)
+		}
+		seen[n] = true
+	}
+	return nil
+}
+
+// zoneOutcome 开奖号码落在某个号码区的一类情况：
+// ways 为这类开奖的组合数，bets[h] 为命中 h 个号码的单式注数
+type zoneOutcome struct {
+	ways float64
+	bets map[int]float64
+}
+
+// zoneOutcomes 枚举开奖号码命中 a 个胆码、b 个拖码的全部情况
+func zoneOutcomes(z gameZone, d, t int) []zoneOutcome {
+	n, k := z.Max-z.Min+1, z.Pick
+	var out []zoneOutcome
+	for a := 0; a <= d && a <= k; a++ {
+		for b := 0; b <= t && a+b <= k; b++ {
+			ways := binom(d, a) * binom(t, b) * binom(n-d-t, k-a-b)
+			if ways == 0 {
+				continue
+			}
+			// 每注包含全部胆码，再从拖码中选 k-d 个，其中 j 个命中
+			bets := map[int]float64{}
+			for j := 0; j <= b && j <= k-d; j++ {
+				if c := binom(b, j) * binom(t-b, k-d-j); c > 0 {
+					bets[a+j] = c
+				}
+			}
+			out = append(out, zoneOutcome{ways: ways, bets: bets})
+		}
+	}
+	return out
+}
+
+// prizeFor 用验奖器计算各区命中数对应的奖级，保证与实际验奖规则一致
+func prizeFor(spec gameSpec, v Verifier, hits []int) (int, Fen) {
+	var t UserTicket
+	var win WinningNumbers
+	for i, z := range spec.Zones {
+		var w, mine []string
+		for n := 0; n < z.Pick; n++ {
+			w = append(w, z.format(z.Min+n))
+		}
+		mine = append(mine, w[:hits[i]]...)
+		for n := 0; len(mine) < z.Pick; n++ {
+			mine = append(mine, z.format(z.Min+z.Pick+n))
+		}
+		if z.Field == "blue" {
+			win.Blue, t.Blue = append(win.Blue, w...), append(t.Blue, mine...)
+		} else {
+			win.Red, t.Red = append(win.Red, w...), append(t.Red, mine...)
+		}
+	}
+	if t.Blue == nil {
+		t.Blue = []string{}
+	}
+	level, money, _ := v.Verify(t, win)
+	return level, money
+}
+
+// betCount 一行投注拆成单式后的注数
+func betCount(spec gameSpec, t UserTicket) int64 {
+	bets := 1.0
+	for _, z := range spec.Zones {
+		dan, tuo := zoneSelection(z, t)
+		bets *= binom(len(tuo), z.Pick-len(dan))
+	}
+	return int64(bets)
+}
+
+// calcOdds 精确计算一行投注的概率分布
+func calcOdds(spec gameSpec, t UserTicket) (TicketOdds, error) {
+	v := selectVerifier(spec.Name)
+	if v == nil {
+		return TicketOdds{}, fmt.Errorf("%s 暂不支持", spec.Name)
+	}
+	multiplier := t.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	perZone := make([][]zoneOutcome, len(spec.Zones))
+	total := 1.0
+	for i, z := range spec.Zones {
+		dan, tuo := zoneSelection(z, t)
+		if err := validateSelection(z, dan, tuo); err != nil {
+			return TicketOdds{}, err
+		}
+		perZone[i] = zoneOutcomes(z, len(dan), len(tuo))
+		total *= binom(z.Max-z.Min+1, z.Pick)
+	}
+	bets := float64(betCount(spec, t))
+
+	type levelAcc struct {
+		prize       Fen
+		probability float64
+		wins        float64
+	}
+	levels := map[int]*levelAcc{}
+	type levelPrize struct {
+		level int
+		prize Fen
+	}
+	prizes := map[string]levelPrize{}
+	winProb, ev := 0.0, 0.0
+
+	// 各区情况做笛卡尔积；每种开奖情况下统计各奖级的中奖注数
+	type hitCombo struct {
+		hits []int
+		bets float64
+	}
+	var walk func(zone int, ways float64, combos []hitCombo)
+	walk = func(zone int, ways float64, combos []hitCombo) {
+		if zone == len(spec.Zones) {
+			p := ways / total
+			won := map[int]float64{}
+			for _, c := range combos {
+				key := fmt.Sprint(c.hits)
+				pz, ok := prizes[key]
+				if !ok {
+					level, money := prizeFor(spec, v, c.hits)
+					pz = levelPrize{level: level, prize: money * Fen(multiplier)}
+					prizes[key] = pz
+				}
+				if pz.prize <= 0 {
+					continue
+				}
+				won[pz.level] += c.bets
+				ev += p * c.bets * float64(pz.prize) / float64(Yuan)
+				if levels[pz.level] == nil {
+					levels[pz.level] = &levelAcc{prize: pz.prize}
+				}
+			}
+			for level, n := range won {
+				levels[level].probability += p
+				levels[level].wins += p * n
+			}
+			if len(won) > 0 {
+				winProb += p
+			}
+			return
+		}
+		for _, o := range perZone[zone] {
+			next := make([]hitCombo, 0, len(combos)*len(o.bets))
+			for _, c := range combos {
+				for h, n := range o.bets {
+					hits := append(append([]int{}, c.hits...), h)
+					next = append(next, hitCombo{hits: hits, bets: c.bets * n})
+				}
+			}
+			walk(zone+1, ways*o.ways, next)
+		}
+	}
+	walk(0, 1, []hitCombo{{bets: 1}})
+
+	mode := t.Mode
+	if mode == "" {
+		switch {
+		case len(t.Dan) > 0 || len(t.BlueDan) > 0:
+			mode = "胆拖"
+		case bets > 1:
+			mode = "复式"
+		default:
+			mode = "单式"
+		}
+	}
+	res := TicketOdds{
+		Mode:           mode,
+		Bets:           int64(bets),
+		Multiplier:     multiplier,
+		StakeFen:       Fen(bets) * betPrice * Fen(multiplier),
+		Levels:         []LevelOdds{},
+		WinProbability: winProb,
+		ExpectedValue:  ev,
+	}
+	for level, acc := range levels {
+		res.Levels = append(res.Levels, LevelOdds{
+			Level: level, Name: levelName(level), Prize: acc.prize.Yuan(), PrizeFen: acc.prize,
+			Probability: acc.probability, ExpectedWins: acc.wins,
+		})
+	}
+	sort.Slice(res.Levels, func(i, j int) bool { return res.Levels[i].Level < res.Levels[j].Level })
+	res.Stake = res.StakeFen.Yuan()
+	if res.StakeFen > 0 {
+		res.ReturnRate = ev * float64(Yuan) / float64(res.StakeFen)
+	}
+	res.Summary = fmt.Sprintf("这张票 %s，共 %d 注", res.StakeFen, res.Bets)
+	return res, nil
+}
+
+// oddsHandler POST /api/v1/odds，请求体与识别结果结构相同：
+//
+//	{"type": "双色球", "tickets": [{"red": [...], "blue": [...], "dan": [...], "multiplier": 2}]}
+func oddsHandler(c *gin.Context) {
+	var req LotteryData
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
+		return
+	}
+	spec, ok := specOf(req.Type)
+	if !ok {
+		c.JSON(400, gin.H{"error": "不支持的彩种: " + req.Type})
+		return
+	}
+	if len(req.Tickets) == 0 {
+		c.JSON(400, gin.H{"error": "tickets 不能为空"})
+		return
+	}
+
+	rows := make([]TicketOdds, 0, len(req.Tickets))
+	var stake Fen
+	var bets int64
+	ev := 0.0
+	for i, t := range req.Tickets {
+		odds, err := calcOdds(spec, t)
+		if err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("第%d行: %v", i+1, err)})
+			return
+		}
+		odds.RowIndex = i + 1
+		rows = append(rows, odds)
+		stake += odds.StakeFen
+		bets += odds.Bets
+		ev += odds.ExpectedValue
+	}
+	c.JSON(200, gin.H{
+		"type":            spec.Name,
+		"tickets":         rows,
+		"total_stake":     stake.Yuan(),
+		"total_stake_fen": stake,
+		"total_bets":      bets,
+		"expected_value":  ev,
+		"summary":         fmt.Sprintf("这张票 %s，共 %d 注", stake, bets),
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBinom(t *testing.T) {
+	tests := []struct {
+		n, k int
+		want float64
+	}{
+		{33, 6, 1107568},
+		{35, 5, 324632},
+		{12, 2, 66},
+		{5, 0, 1},
+		{3, 4, 0},
+		{-1, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := binom(tt.n, tt.k); got != tt.want {
+			t.Errorf("binom(%d, %d) = %v, want %v", tt.n, tt.k, got, tt.want)
+		}
+	}
+}
+
+func TestCalcOdds(t *testing.T) {
+	ssq6 := []string{"01", "02", "03", "04", "05", "06"}
+	tests := []struct {
+		name    string
+		game    string
+		ticket  UserTicket
+		mode    string
+		bets    int64
+		stake   Fen
+		first   float64 // 一等奖概率
+		winProb float64
+		wantErr bool
+	}{
+		{"双色球单式", "双色球", UserTicket{Red: ssq6, Blue: []string{"01"}}, "单式", 1, 2 * Yuan, 1.0 / 17721088, 0.0670945, false},
+		{"双色球复式 8+1", "双色球", UserTicket{Red: append(ssq6, "07", "08"), Blue: []string{"01"}}, "复式", 28, 56 * Yuan, 28.0 / 17721088, 0, false},
+		{"双色球胆拖 2 胆 5 拖", "双色球", UserTicket{Dan: []string{"01", "02"}, Red: []string{"03", "04", "05", "06", "07"}, Blue: []string{"01"}}, "胆拖", 5, 10 * Yuan, 5.0 / 17721088, 0, false},
+		{"倍投", "双色球", UserTicket{Red: ssq6, Blue: []string{"01"}, Multiplier: 3}, "单式", 1, 6 * Yuan, 1.0 / 17721088, 0.0670945, false},
+		{"排列5单式", "排列5", UserTicket{Red: []string{"1", "2", "3", "4", "5"}}, "单式", 1, 2 * Yuan, 1.0 / 100000, 1.0 / 100000, false},
+		{"号码重复", "双色球", UserTicket{Red: []string{"01", "01", "02", "03", "04", "05"}, Blue: []string{"01"}}, "", 0, 0, 0, 0, true},
+		{"号码超出范围", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "34"}, Blue: []string{"01"}}, "", 0, 0, 0, 0, true},
+		{"号码不够", "大乐透", UserTicket{Red: []string{"01", "02"}, Blue: []string{"01", "02"}}, "", 0, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, _ := specOf(tt.game)
+			got, err := calcOdds(spec, tt.ticket)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Mode != tt.mode || got.Bets != tt.bets || got.StakeFen != tt.stake {
+				t.Errorf("mode/bets/stake = %s/%d/%s, want %s/%d/%s", got.Mode, got.Bets, got.StakeFen, tt.mode, tt.bets, tt.stake)
+			}
+			if len(got.Levels) == 0 || got.Levels[0].Level != 1 || math.Abs(got.Levels[0].Probability-tt.first) > 1e-12 {
+				t.Errorf("levels = %+v, want first prize probability %v", got.Levels, tt.first)
+			}
+			if tt.winProb > 0 && math.Abs(got.WinProbability-tt.winProb) > 1e-5 {
+				t.Errorf("win probability = %v, want %v", got.WinProbability, tt.winProb)
+			}
+		})
+	}
+}
+
+func TestOddsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantRows   []int // 各行的 row_index，与验奖结果一样从 1 开始
+	}{
+		{"两行", `{"type": "双色球", "tickets": [{"red": ["01","02","03","04","05","06"], "blue": ["07"]}, {"red": ["01","02","03","04","05","06","07"], "blue": ["07"]}]}`, 200, []int{1, 2}},
+		{"不支持的彩种", `{"type": "快乐8", "tickets": [{"red": ["01"]}]}`, 400, nil},
+		{"没有号码", `{"type": "双色球", "tickets": []}`, 400, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/odds", strings.NewReader(tt.body))
+			oddsHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var got struct {
+				Tickets []TicketOdds `json:"tickets"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if len(got.Tickets) != len(tt.wantRows) {
+				t.Fatalf("tickets = %+v", got.Tickets)
+			}
+			for i, row := range got.Tickets {
+				if row.RowIndex != tt.wantRows[i] {
+					t.Errorf("tickets[%d].row_index = %d, want %d", i, row.RowIndex, tt.wantRows[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ORIGIN: 请求来源（租户 / 用户 / 联系方式 / 终端设备）
+// ==========================================
+
+// ScanOrigin 一次扫描的来源信息，随异步任务一起落盘
+type ScanOrigin struct {
+	Tenant   string      `json:"tenant"`
+	UserID   string      `json:"user_id,omitempty"`
+	Contact  UserContact `json:"contact"`
+	DeviceID string      `json:"device_id,omitempty"`
+}
+
+// originOf 汇总请求中的来源信息
+func originOf(c *gin.Context) ScanOrigin {
+	return ScanOrigin{Tenant: tenantOf(c), UserID: userOf(c), Contact: contactOf(c), DeviceID: deviceOf(c)}
+}
+
+// userOf 业务方的用户标识，用于汇总个人扫描记录。只认访问令牌里的用户，
+// 或凭 API Key 调用的接入方后台给出的请求头 X-User-ID / 表单字段 user_id；其他请求都是匿名的
+func userOf(c *gin.Context) string {
+	if sessionOf(c) != "" {
+		return c.GetString("auth_user")
+	}
+	if _, ok := apiKeyOf(c); !ok {
+		return ""
+	}
+	if u := strings.TrimSpace(c.GetHeader("X-User-ID")); u != "" {
+		return u
+	}
+	return strings.TrimSpace(c.PostForm("user_id"))
+}
+
+// requireUser 按用户汇总的接口（历史、收益、订阅）需要用户身份，没有时已写好 401
+func requireUser(c *gin.Context) (string, bool) {
+	userID := userOf(c)
+	if userID == "" {
+		c.JSON(401, gin.H{"error": "缺少用户身份（访问令牌或 API Key + X-User-ID）"})
+		return "", false
+	}
+	return userID, true
+}
+
+// deviceOf 终端设备编号：请求头 X-Device-ID 或表单字段 device_id（门店自助机等）
+func deviceOf(c *gin.Context) string {
+	if d := strings.TrimSpace(c.GetHeader("X-Device-ID")); d != "" {
+		return d
+	}
+	return strings.TrimSpace(c.PostForm("device_id"))
+}
+
+// tenantOf 从请求头 X-Tenant-ID 识别租户
+func tenantOf(c *gin.Context) string {
+	if t := strings.TrimSpace(c.GetHeader("X-Tenant-ID")); t != "" {
+		return t
+	}
+	return "default"
+}
+
+// UserContact 用户在上传时留下的联系方式（表单字段 notify_email / notify_phone）
+type UserContact struct {
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+func (u UserContact) Empty() bool { return u.Email == "" && u.Phone == "" }
+
+func contactOf(c *gin.Context) UserContact {
+	return UserContact{
+		Email: strings.TrimSpace(c.PostForm("notify_email")),
+		Phone: strings.TrimSpace(c.PostForm("notify_phone")),
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// PENDING: 待开奖票据自动验奖
+// ==========================================
+
+// PendingTicket 扫描时尚未开奖、等开奖后自动验奖的票
+type PendingTicket struct {
+	ID        string      `json:"id"`
+	Tenant    string      `json:"tenant"`
+	Contact   UserContact `json:"contact"`
+	Lottery   LotteryData `json:"lottery"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type pendingStore struct {
+	mu      sync.Mutex
+	path    string
+	tickets map[string]PendingTicket
+}
+
+var pendingTickets = &pendingStore{tickets: map[string]PendingTicket{}}
+
+func (s *pendingStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := readSealedFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []PendingTicket
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for _, t := range list {
+		s.tickets[t.ID] = t
+	}
+	return nil
+}
+
+// persist 调用方需持有锁
+func (s *pendingStore) persist() {
+	if s.path == "" {
+		return
+	}
+	list := make([]PendingTicket, 0, len(s.tickets))
+	for _, t := range s.tickets {
+		list = append(list, t)
+	}
+	raw, _ := json.MarshalIndent(list, "", "  ")
+	tmp := s.path + ".tmp"
+	if err := writeSealedFile(tmp, raw); err != nil {
+		log.Printf("保存待开奖票据失败: %v", err)
+		return
+	}
+	os.Rename(tmp, s.path)
+}
+
+// Watch 把结果里尚未开奖的票登记下来；没留联系方式的也登记，开奖后照常发事件、更新任务结果
+func (s *pendingStore) Watch(tenant string, contact UserContact, results []VerificationResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added := false
+	for _, r := range results {
+		if !r.Pending {
+			continue
+		}
+		id := newJobID()
+		s.tickets[id] = PendingTicket{ID: id, Tenant: tenant, Contact: contact, Lottery: r.OCRData, CreatedAt: time.Now()}
+		added = true
+	}
+	if added {
+		s.persist()
+	}
+}
+
+// OnDraws 新开奖数据入库后，对匹配的待开奖票重新验奖：更新排队任务里保存的结果、
+// 发布 ticket.settled 事件，中奖则再发 ticket.won 并通知租户和用户
+func (s *pendingStore) OnDraws(list []DrawRecord) {
+	released := map[string]DrawRecord{}
+	for _, d := range list {
+		released[drawKey(d.Game, d.Issue)] = d
+	}
+
+	s.mu.Lock()
+	var due []PendingTicket
+	for id, t := range s.tickets {
+		if drawReleased(t.Lottery, released) {
+			due = append(due, t)
+			delete(s.tickets, id)
+		}
+	}
+	if len(due) > 0 {
+		s.persist()
+	}
+	s.mu.Unlock()
+
+	for _, t := range due {
+		budget := newRequestBudget(context.Background())
+		res, err := verifyLottery(budget, 0, t.Lottery)
+		budget.Done()
+		if err != nil {
+			log.Printf("待开奖票据 %s 验奖失败: %v", t.ID, err)
+			continue
+		}
+		if res.Pending {
+			// 主玩法与附加玩法分开开奖，还有没开奖的部分时继续等待
+			s.Watch(t.Tenant, t.Contact, []VerificationResult{res})
+			continue
+		}
+		jobQueue.Settle(t.Tenant, res)
+		events.Emit(DomainTicketSettled, t.Tenant, res)
+		if res.TotalPrize > 0 {
+			events.Emit(DomainTicketWon, t.Tenant, res)
+			notifier.TicketSettled(t.Tenant, res)
+			notifier.PendingTicketWon(t, res, released[drawKey(t.Lottery.Type, t.Lottery.Issue)])
+		}
+	}
+}
+
+// drawReleased 票上主玩法或任一附加玩法的开奖在本批数据中
+func drawReleased(l LotteryData, released map[string]DrawRecord) bool {
+	if _, ok := released[drawKey(l.Type, l.Issue)]; ok {
+		return true
+	}
+	for _, sec := range l.Sections {
+		issue := sec.Issue
+		if issue == "" {
+			issue = l.Issue
+		}
+		if _, ok := released[drawKey(sec.Type, issue)]; ok {
+			return true
+		}
+	}
+	return false
+}
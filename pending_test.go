@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrawReleased(t *testing.T) {
+	released := map[string]DrawRecord{
+		drawKey("双色球", "2025107"): {},
+		drawKey("生肖乐", "2025090"): {},
+	}
+	tests := []struct {
+		name    string
+		lottery LotteryData
+		want    bool
+	}{
+		{"主玩法已开奖", LotteryData{Type: "双色球", Issue: "2025107"}, true},
+		{"主玩法未开奖", LotteryData{Type: "双色球", Issue: "2025108"}, false},
+		{"附加玩法已开奖", LotteryData{Type: "七星彩", Issue: "2025090", Sections: []LotteryData{{Type: "生肖乐"}}}, true},
+		{"附加玩法期号不同", LotteryData{Type: "七星彩", Issue: "2025091", Sections: []LotteryData{{Type: "生肖乐"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := drawReleased(tt.lottery, released); got != tt.want {
+				t.Errorf("drawReleased() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 开奖后只替换同一租户、已完成任务里号码相同的待开奖结果
+func TestScanJobQueueSettle(t *testing.T) {
+	ticket := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "01", "03"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	other := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"04", "05", "06", "07", "08", "09"}, Blue: []string{"10"}, Multiplier: 1},
+	}}
+	pending := func(l LotteryData) []VerificationResult {
+		return []VerificationResult{{TicketIndex: 1, OCRData: l, Pending: true}}
+	}
+	tests := []struct {
+		name        string
+		job         ScanJob
+		wantUpdated int
+		wantPrize   Fen
+	}{
+		{"号码相同", ScanJob{Status: JobDone, ScanOrigin: ScanOrigin{Tenant: "a"}, Results: pending(ticket), Stage: ScanPendingDraw}, 1, 200 * Yuan},
+		{"其他租户", ScanJob{Status: JobDone, ScanOrigin: ScanOrigin{Tenant: "b"}, Results: pending(ticket)}, 0, 0},
+		{"任务未完成", ScanJob{Status: JobQueued, ScanOrigin: ScanOrigin{Tenant: "a"}, Results: pending(ticket)}, 0, 0},
+		{"号码不同", ScanJob{Status: JobDone, ScanOrigin: ScanOrigin{Tenant: "a"}, Results: pending(other)}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newScanJobQueue(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			job := tt.job
+			job.ID, job.CreatedAt = newJobID(), time.Now()
+			if err := q.store.Put(&job); err != nil {
+				t.Fatal(err)
+			}
+			settled := VerificationResult{OCRData: ticket, TotalPrize: 200 * Yuan}
+			if got := q.Settle("a", settled); got != tt.wantUpdated {
+				t.Fatalf("Settle() = %d, want %d", got, tt.wantUpdated)
+			}
+			saved, _ := q.Get(job.ID)
+			if got := saved.Results[0].TotalPrize; got != tt.wantPrize {
+				t.Errorf("TotalPrize = %v, want %v", got, tt.wantPrize)
+			}
+			if tt.wantUpdated > 0 && saved.Stage != ScanDone {
+				t.Errorf("Stage = %s, want %s", saved.Stage, ScanDone)
+			}
+		})
+	}
+}
+
+func TestSettleOnNilQueue(t *testing.T) {
+	var q *scanJobQueue
+	if got := q.Settle("a", VerificationResult{}); got != 0 {
+		t.Errorf("nil 队列 Settle() = %d", got)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// PICK: 机选号码
+// ==========================================
+
+const maxPickCount = 100
+
+// pickShape 一注机选的形态；Red/Blue 为各区选号个数（胆拖时为拖码个数）
+type pickShape struct {
+	Red, Blue    int
+	Dan, BlueDan int
+	Multiplier   int
+	mode         string
+}
+
+// randIntn 用 crypto/rand 生成 [0, n) 的随机数
+func randIntn(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// randomNumbers 从 [min, max] 中不重复地抽取 k 个号码（部分 Fisher-Yates），排除 exclude
+func randomNumbers(z gameZone, k int, exclude []string) ([]string, error) {
+	skip := map[string]bool{}
+	for _, n := range exclude {
+		skip[n] = true
+	}
+	pool := make([]string, 0, z.Max-z.Min+1)
+	for n := z.Min; n <= z.Max; n++ {
+		if s := z.format(n); !skip[s] {
+			pool = append(pool, s)
+		}
+	}
+	if k > len(pool) {
+		return nil, fmt.Errorf("%s 可选号码不足 %d 个", z.Label, k)
+	}
+	for i := 0; i < k; i++ {
+		j, err := randIntn(len(pool) - i)
+		if err != nil {
+			return nil, err
+		}
+		pool[i], pool[i+j] = pool[i+j], pool[i]
+	}
+	out := pool[:k:k]
+	sort.Strings(out)
+	return out, nil
+}
+
+// checkCount 校验一个号码区的胆码/拖码个数
+func (z gameZone) checkCount(dan, tuo int) error {
+	size := z.Max - z.Min + 1
+	if dan >= z.Pick && dan > 0 {
+		return fmt.Errorf("%s胆码最多 %d 个", z.Label, z.Pick-1)
+	}
+	if dan+tuo < z.Pick {
+		return fmt.Errorf("%s至少选 %d 个号码", z.Label, z.Pick)
+	}
+	if dan+tuo > size {
+		return fmt.Errorf("%s最多选 %d 个号码", z.Label, size)
+	}
+	return nil
+}
+
+// pickParams 号码区对应的个数参数和胆码参数
+func pickParams(field string) (count, dan string) {
+	if field == "blue" {
+		return "blue", "blue_dan"
+	}
+	return "red", "dan"
+}
+
+// parsePickShape 读取并校验 red/blue/dan/blue_dan/multiplier 参数
+func parsePickShape(c *gin.Context, spec gameSpec) (pickShape, error) {
+	intParam := func(name string, def int) (int, error) {
+		v := c.Query(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s 参数必须是非负整数", name)
+		}
+		return n, nil
+	}
+
+	shape := pickShape{}
+	var err error
+	if shape.Multiplier, err = intParam("multiplier", 1); err != nil {
+		return shape, err
+	}
+	if shape.Multiplier < 1 {
+		return shape, fmt.Errorf("multiplier 至少为 1")
+	}
+	if spec.Ordered {
+		for _, name := range []string{"red", "blue", "dan", "blue_dan"} {
+			if c.Query(name) != "" {
+				return shape, fmt.Errorf("%s 不支持复式/胆拖机选", spec.Name)
+			}
+		}
+		shape.mode = "单式"
+		return shape, nil
+	}
+
+	fields := map[string]bool{}
+	compound := false
+	for _, z := range spec.Zones {
+		countName, danName := pickParams(z.Field)
+		fields[z.Field] = true
+		dan, err := intParam(danName, 0)
+		if err != nil {
+			return shape, err
+		}
+		n, err := intParam(countName, z.Pick-dan)
+		if err != nil {
+			return shape, err
+		}
+		if err := z.checkCount(dan, n); err != nil {
+			return shape, err
+		}
+		if z.Field == "blue" {
+			shape.Blue, shape.BlueDan = n, dan
+		} else {
+			shape.Red, shape.Dan = n, dan
+		}
+		compound = compound || n > z.Pick
+	}
+	// 只有一个号码区的彩种（如七乐彩）不接受另一区的参数
+	for _, field := range []string{"red", "blue"} {
+		countName, danName := pickParams(field)
+		for _, name := range []string{countName, danName} {
+			if !fields[field] && c.Query(name) != "" {
+				return shape, fmt.Errorf("%s 不支持 %s 参数", spec.Name, name)
+			}
+		}
+	}
+	if shape.BlueDan > 0 && spec.Name != "大乐透" {
+		return shape, fmt.Errorf("%s 蓝球不支持胆拖", spec.Name)
+	}
+
+	switch {
+	case shape.Dan > 0 || shape.BlueDan > 0:
+		shape.mode = "胆拖"
+	case compound:
+		shape.mode = "复式"
+	default:
+		shape.mode = "单式"
+	}
+	return shape, nil
+}
+
+// randomTicket 按形态生成一注；各号码区按 Field 填入红球或蓝球
+func randomTicket(spec gameSpec, shape pickShape) (UserTicket, error) {
+	t := UserTicket{Multiplier: shape.Multiplier, Mode: shape.mode}
+	if spec.Ordered {
+		for _, z := range spec.Zones {
+			nums, err := randomNumbers(z, 1, nil)
+			if err != nil {
+				return t, err
+			}
+			t.Red = append(t.Red, nums...)
+		}
+		t.Blue = []string{}
+		return t, nil
+	}
+
+	t.Blue = []string{}
+	for _, z := range spec.Zones {
+		count, danCount := shape.Red, shape.Dan
+		if z.Field == "blue" {
+			count, danCount = shape.Blue, shape.BlueDan
+		}
+		var dan, nums []string
+		var err error
+		if danCount > 0 {
+			if dan, err = randomNumbers(z, danCount, nil); err != nil {
+				return t, err
+			}
+		}
+		if nums, err = randomNumbers(z, count, dan); err != nil {
+			return t, err
+		}
+		if z.Field == "blue" {
+			t.Blue, t.BlueDan = nums, dan
+		} else {
+			t.Red, t.Dan = nums, dan
+		}
+	}
+	return t, nil
+}
+
+// pickHandler GET /api/v1/pick?game=ssq&count=5
+// 复式：red=8&blue=2；胆拖：dan=2&red=6（2 胆 6 拖），大乐透后区胆拖用 blue_dan
+func pickHandler(c *gin.Context) {
+	spec, ok := specOf(c.Query("game"))
+	if !ok {
+		c.JSON(400, gin.H{"error": "不支持的彩种: " + c.Query("game")})
+		return
+	}
+	count := 1
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPickCount {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("count 必须在 1-%d 之间", maxPickCount)})
+			return
+		}
+		count = n
+	}
+	shape, err := parsePickShape(c, spec)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := LotteryData{Type: spec.Name, Tickets: make([]UserTicket, 0, count)}
+	for i := 0; i < count; i++ {
+		t, err := randomTicket(spec, shape)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "机选失败: " + err.Error()})
+			return
+		}
+		result.Tickets = append(result.Tickets, t)
+	}
+	c.JSON(200, result)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePickShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	loadTestGames(t)
+	tests := []struct {
+		name    string
+		game    string
+		query   string
+		want    pickShape
+		wantErr bool
+	}{
+		{"双色球单式", "ssq", "", pickShape{Red: 6, Blue: 1, Multiplier: 1, mode: "单式"}, false},
+		{"双色球复式", "ssq", "red=8&blue=2", pickShape{Red: 8, Blue: 2, Multiplier: 1, mode: "复式"}, false},
+		{"双色球胆拖默认拖码", "ssq", "dan=2", pickShape{Red: 4, Blue: 1, Dan: 2, Multiplier: 1, mode: "胆拖"}, false},
+		{"大乐透后区胆拖", "dlt", "blue_dan=1&blue=3&multiplier=2", pickShape{Red: 5, Blue: 3, BlueDan: 1, Multiplier: 2, mode: "胆拖"}, false},
+		{"排列5单式", "pl5", "", pickShape{Multiplier: 1, mode: "单式"}, false},
+		{"排列5不支持复式", "pl5", "red=6", pickShape{}, true},
+		{"双色球蓝球不支持胆拖", "ssq", "blue_dan=1&blue=2", pickShape{}, true},
+		{"胆码太多", "ssq", "dan=6&red=2", pickShape{}, true},
+		{"号码不够", "ssq", "red=5", pickShape{}, true},
+		{"超过号码总数", "ssq", "red=34", pickShape{}, true},
+		{"倍数为 0", "ssq", "multiplier=0", pickShape{}, true},
+		{"参数不是数字", "ssq", "red=x", pickShape{}, true},
+		{"七乐彩单式", "qlc", "", pickShape{Red: 7, Multiplier: 1, mode: "单式"}, false},
+		{"七乐彩复式", "qlc", "red=9", pickShape{Red: 9, Multiplier: 1, mode: "复式"}, false},
+		{"七乐彩胆拖", "qlc", "dan=2&red=6", pickShape{Red: 6, Dan: 2, Multiplier: 1, mode: "胆拖"}, false},
+		{"七乐彩没有蓝球", "qlc", "blue=1", pickShape{}, true},
+		{"七乐彩没有蓝球胆码", "qlc", "blue_dan=1", pickShape{}, true},
+		{"排列3单式", "p3", "multiplier=3", pickShape{Multiplier: 3, mode: "单式"}, false},
+		{"排列3不支持胆拖", "p3", "dan=1", pickShape{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, _ := specOf(tt.game)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+			got, err := parsePickShape(c, spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("shape = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	loadTestGames(t)
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		count      int
+		red, dan   int
+		blue       int
+		distinct   bool // 乐透型号码不重复且升序
+	}{
+		{"双色球机选 5 注", "game=ssq&count=5", 200, 5, 6, 0, 1, true},
+		{"大乐透胆拖", "game=dlt&dan=2&red=5", 200, 1, 5, 2, 2, true},
+		{"排列5", "game=pl5&count=3", 200, 3, 5, 0, 0, false},
+		{"只有一个号码区的 YAML 彩种", "game=qlc&count=3", 200, 3, 7, 0, 0, true},
+		{"YAML 彩种胆拖", "game=qlc&dan=2&red=6", 200, 1, 6, 2, 0, true},
+		{"按位比较的 YAML 彩种", "game=p3&count=2", 200, 2, 3, 0, 0, false},
+		{"YAML 彩种没有蓝球", "game=qlc&blue=2", 400, 0, 0, 0, 0, false},
+		{"不支持的彩种", "game=kl8", 400, 0, 0, 0, 0, false},
+		{"注数超过上限", "game=ssq&count=101", 400, 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/pick?"+tt.query, nil)
+			pickHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != 200 {
+				return
+			}
+			var got LotteryData
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Tickets) != tt.count {
+				t.Fatalf("tickets = %d, want %d", len(got.Tickets), tt.count)
+			}
+			for _, ticket := range got.Tickets {
+				if len(ticket.Red) != tt.red || len(ticket.Dan) != tt.dan || len(ticket.Blue) != tt.blue {
+					t.Errorf("ticket shape = %d/%d/%d, want %d/%d/%d", len(ticket.Red), len(ticket.Dan), len(ticket.Blue), tt.red, tt.dan, tt.blue)
+				}
+				for _, d := range ticket.Dan {
+					if slices.Contains(ticket.Red, d) {
+						t.Errorf("dan %s repeated in tuo %v", d, ticket.Red)
+					}
+				}
+				if tt.distinct && (!slices.IsSorted(ticket.Red) || len(slices.Compact(slices.Clone(ticket.Red))) != len(ticket.Red)) {
+					t.Errorf("red %v not sorted or repeated", ticket.Red)
+				}
+			}
+		})
+	}
+}
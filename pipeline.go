@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ==========================================
+// PIPELINE: 非 HTTP 入口共用的扫描流程
+// ==========================================
+
+// runScan 识别 + 验奖 + 结果分发的完整流程，供批量接口、IM 机器人等入口复用。
+// 熔断期间开启了排队降级时不报错，而是返回排队中的任务。
+// 一张图片上有多张票时，个别票验奖失败只在该票的结果里标注 error，全部失败才返回错误
+func runScan(parent context.Context, origin ScanOrigin, fileBytes []byte, apiKey string) ([]VerificationResult, *ScanJob, error) {
+	budget := newRequestBudget(withBillingTenant(parent, origin.Tenant))
+	defer budget.Done()
+
+	ocrCtx, cancelOCR := budget.Stage(stageOCR)
+	ocrResults, err := recognizeCached(ocrCtx, fileBytes, apiKey)
+	if err != nil {
+		if stageErr := budget.Check(ocrCtx, stageOCR); stageErr != nil {
+			err = stageErr
+		}
+	}
+	cancelOCR()
+	if errors.Is(err, errCircuitOpen) && queueOnOutage() {
+		job, qerr := jobQueue.Enqueue(fileBytes, origin)
+		if qerr != nil {
+			return nil, nil, fmt.Errorf("排队失败: %w", qerr)
+		}
+		return nil, job, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("AI 识别失败: %w", err)
+		onScanFailed(origin.Tenant, err.Error())
+		return nil, nil, err
+	}
+
+	results, err := verifyEach(budget, ocrResults)
+	if err != nil {
+		err = fmt.Errorf("验奖失败: %w", err)
+		onScanFailed(origin.Tenant, err.Error())
+		return nil, nil, err
+	}
+	inspectImage(fileBytes).applyAll(results)
+	review, err := ensembleGate(parent, origin, fileBytes, ocrResults, results)
+	if err != nil {
+		return nil, nil, err
+	}
+	if review != nil {
+		return nil, nil, &reviewRequiredError{ID: review.ID}
+	}
+	onScanCompleted(origin, fileBytes, results)
+	return results, nil, nil
+}
+
+// verifyEach 逐张验奖，单张失败记在该票结果的 Error 里继续验下一张；全部失败时返回最后一个错误
+func verifyEach(b *requestBudget, ocrResults []LotteryData) ([]VerificationResult, error) {
+	results := make([]VerificationResult, 0, len(ocrResults))
+	var lastErr error
+	for idx, lottery := range ocrResults {
+		res, err := verifyLottery(b, idx, lottery)
+		if err != nil {
+			lastErr = err
+			res = VerificationResult{TicketIndex: idx + 1, OCRData: lottery, Details: []ResultDetail{}, Error: "验奖失败: " + err.Error()}
+		}
+		results = append(results, res)
+	}
+	if lastErr != nil && failedCount(results) == len(results) {
+		return nil, lastErr
+	}
+	return hooks.AfterVerify(b.Context(), results), nil
+}
+
+// failedCount 验奖失败的票数
+func failedCount(results []VerificationResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			n++
+		}
+	}
+	return n
+}
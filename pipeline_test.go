@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 同一张图片上个别票验奖失败时只标注在该票的结果里，全部失败才整体报错
+func TestVerifyEach(t *testing.T) {
+	drawn := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1}}}
+	pending := LotteryData{Type: "双色球", Issue: "2025108", Tickets: []UserTicket{{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}, Multiplier: 1}}}
+
+	tests := []struct {
+		name       string
+		lotteries  []LotteryData
+		wantErr    bool
+		wantErrors []bool // 各票结果是否带 error
+	}{
+		{"全部成功", []LotteryData{pending, pending}, false, []bool{false, false}},
+		{"部分失败", []LotteryData{pending, drawn, pending}, false, []bool{false, true, false}},
+		{"全部失败", []LotteryData{drawn, drawn}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyCache.Purge()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			// 验奖阶段只有 1ns：已开奖的票验奖超时，未开奖的票不进入验奖阶段
+			b := &requestBudget{ctx: ctx, cancel: cancel, total: time.Minute, shares: map[string]float64{stageDraw: 1, stageVerify: 1e-12}}
+
+			results, err := verifyEach(b, tt.lotteries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyEach() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var be *budgetError
+				if !errors.As(err, &be) || be.Stage != stageVerify {
+					t.Errorf("error = %v, want verify budget error", err)
+				}
+				return
+			}
+			if len(results) != len(tt.wantErrors) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.wantErrors))
+			}
+			for i, res := range results {
+				if (res.Error != "") != tt.wantErrors[i] {
+					t.Errorf("results[%d].Error = %q, want error %v", i, res.Error, tt.wantErrors[i])
+				}
+				if res.TicketIndex != i+1 || res.OCRData.Issue != tt.lotteries[i].Issue {
+					t.Errorf("results[%d] = ticket %d issue %s", i, res.TicketIndex, res.OCRData.Issue)
+				}
+				if res.Error != "" && !strings.HasPrefix(res.Error, "验奖失败: ") {
+					t.Errorf("results[%d].Error = %q", i, res.Error)
+				}
+			}
+			want := 0
+			for _, e := range tt.wantErrors {
+				if e {
+					want++
+				}
+			}
+			if got := failedCount(results); got != want {
+				t.Errorf("failedCount() = %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// PORTFOLIO: 个人投注盈亏汇总
+// ==========================================
+
+// PortfolioLine 汇总中的一行（全部 / 某彩种 / 某月）
+type PortfolioLine struct {
+	Key     string `json:"key,omitempty"`
+	Tickets int    `json:"tickets"`
+	Spent   int64  `json:"spent"` // 元
+	Won     int64  `json:"won"`
+	Net     int64  `json:"net"`
+	Pending int    `json:"pending"` // 尚未开奖的票数
+
+	SpentFen Fen `json:"spent_fen"`
+	WonFen   Fen `json:"won_fen"`
+	NetFen   Fen `json:"net_fen"`
+}
+
+func (l *PortfolioLine) add(spent, won Fen, pending bool) {
+	l.Tickets++
+	l.SpentFen += spent
+	l.WonFen += won
+	l.NetFen = l.WonFen - l.SpentFen
+	l.Spent, l.Won, l.Net = l.SpentFen.Yuan(), l.WonFen.Yuan(), l.NetFen.Yuan()
+	if pending {
+		l.Pending++
+	}
+}
+
+// BestWin 单张票最高中奖
+type BestWin struct {
+	Game      string `json:"game"`
+	Issue     string `json:"issue"`
+	Amount    int64  `json:"amount"` // 元
+	AmountFen Fen    `json:"amount_fen"`
+	Month     string `json:"month"`
+}
+
+// ticketOutcome 按最新开奖数据计算一张票的投入与中奖金额
+func ticketOutcome(lottery LotteryData) (spent, won Fen, pending, ok bool) {
+	spec, known := specOf(lottery.Type)
+	verifier := selectVerifier(lottery.Type)
+	if !known || verifier == nil {
+		return 0, 0, false, false
+	}
+	win, drawn := lookupWinningNumbers(context.Background(), lottery.Type, lottery.Issue)
+	for _, t := range lottery.Tickets {
+		multiplier := Fen(max(t.Multiplier, 1))
+		spent += Fen(betCount(spec, t)) * betPrice * multiplier
+		if drawn {
+			_, prize, _ := verifier.Verify(t, win)
+			won += prize * multiplier
+		}
+	}
+	return spent, won, !drawn, true
+}
+
+// portfolioHandler GET /api/v1/portfolio/summary，用户取自访问令牌，或 API Key 调用时的 X-User-ID
+// 同一张票重复扫描只计一次；投入按 注数×2元×倍数 计算
+func portfolioHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	total := PortfolioLine{}
+	byGame := map[string]*PortfolioLine{}
+	byMonth := map[string]*PortfolioLine{}
+	var best *BestWin
+	seen := map[string]bool{}
+
+	for _, rec := range scanHistory.Find(tenantOf(c), userID) {
+		for _, lottery := range rec.Lotteries {
+			key := verifyCacheKey(lottery)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			spent, won, pending, ok := ticketOutcome(lottery)
+			if !ok {
+				continue
+			}
+
+			// 优先按票面销售时间归月，识别不到时按扫描时间
+			month := rec.Time.In(chinaTime).Format("2006-01")
+			if sold, ok := parseSaleTime(lottery.SaleTime); ok {
+				month = sold.Format("2006-01")
+			}
+			game := canonicalGame(lottery.Type)
+			if byGame[game] == nil {
+				byGame[game] = &PortfolioLine{Key: game}
+			}
+			if byMonth[month] == nil {
+				byMonth[month] = &PortfolioLine{Key: month}
+			}
+			total.add(spent, won, pending)
+			byGame[game].add(spent, won, pending)
+			byMonth[month].add(spent, won, pending)
+			if won > 0 && (best == nil || won > best.AmountFen) {
+				best = &BestWin{Game: game, Issue: lottery.Issue, Amount: won.Yuan(), AmountFen: won, Month: month}
+			}
+		}
+	}
+
+	flatten := func(m map[string]*PortfolioLine) []PortfolioLine {
+		out := make([]PortfolioLine, 0, len(m))
+		for _, l := range m {
+			out = append(out, *l)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+		return out
+	}
+	c.JSON(200, gin.H{
+		"user_id":  userID,
+		"total":    total,
+		"best_win": best,
+		"by_game":  flatten(byGame),
+		"by_month": flatten(byMonth),
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTicketOutcome(t *testing.T) {
+	blueOnly := UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}, Multiplier: 2}
+	complex := UserTicket{Red: []string{"01", "03", "04", "05", "06", "08", "09"}, Blue: []string{"01"}, Multiplier: 1}
+	tests := []struct {
+		name        string
+		lottery     LotteryData
+		spent, won  Fen
+		pending, ok bool
+	}{
+		{"蓝球中六等奖两倍", LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{blueOnly}}, 4 * Yuan, 10 * Yuan, false, true},
+		{"复式按注数计投入", LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{complex}}, 14 * Yuan, 0, false, true},
+		{"未开奖", LotteryData{Type: "双色球", Issue: "2099001", Tickets: []UserTicket{blueOnly}}, 4 * Yuan, 0, true, true},
+		{"不支持的彩种不计入", LotteryData{Type: "快乐8", Issue: "2025107", Tickets: []UserTicket{blueOnly}}, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spent, won, pending, ok := ticketOutcome(tt.lottery)
+			if spent != tt.spent || won != tt.won || pending != tt.pending || ok != tt.ok {
+				t.Errorf("ticketOutcome() = %s/%s/%v/%v, want %s/%s/%v/%v", spent, won, pending, ok, tt.spent, tt.won, tt.pending, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPortfolioHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := scanHistory
+	t.Cleanup(func() { scanHistory = old })
+
+	win := LotteryData{Type: "双色球", Issue: "2025107", SaleTime: "2025-09-16 10:00:00",
+		Tickets: []UserTicket{{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}, Multiplier: 1}}}
+	pending := LotteryData{Type: "双色球", Issue: "2099001",
+		Tickets: []UserTicket{{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}, Multiplier: 1}}}
+	scanned := time.Date(2025, 10, 2, 12, 0, 0, 0, chinaTime)
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "default", UserID: "u1", Time: scanned, Lotteries: []LotteryData{win}},
+		{ID: "r2", Tenant: "default", UserID: "u1", Time: scanned, Lotteries: []LotteryData{win}}, // 同一张票重复扫描
+		{ID: "r3", Tenant: "default", UserID: "u1", Time: scanned, Lotteries: []LotteryData{pending}},
+		{ID: "r4", Tenant: "default", UserID: "u2", Time: scanned, Lotteries: []LotteryData{win}},
+		{ID: "r5", Tenant: "other", UserID: "u1", Time: scanned, Lotteries: []LotteryData{win}},
+	}}
+
+	tests := []struct {
+		name       string
+		user       string
+		wantStatus int
+		total      PortfolioLine
+		months     []string
+	}{
+		{"汇总本人本租户", "u1", 200, PortfolioLine{Tickets: 2, SpentFen: 4 * Yuan, WonFen: 5 * Yuan, NetFen: Yuan, Pending: 1}, []string{"2025-09", "2025-10"}},
+		{"没有记录", "u3", 200, PortfolioLine{}, []string{}},
+		{"缺少用户身份", "", 401, PortfolioLine{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/portfolio/summary", nil)
+			if tt.user != "" {
+				c.Set("auth_session", "s1")
+				c.Set("auth_user", tt.user)
+			}
+			portfolioHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Total   PortfolioLine   `json:"total"`
+				ByMonth []PortfolioLine `json:"by_month"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := resp.Total
+			if got.Tickets != tt.total.Tickets || got.SpentFen != tt.total.SpentFen || got.WonFen != tt.total.WonFen ||
+				got.NetFen != tt.total.NetFen || got.Pending != tt.total.Pending {
+				t.Errorf("total = %+v, want %+v", got, tt.total)
+			}
+			if len(resp.ByMonth) != len(tt.months) {
+				t.Fatalf("by_month = %+v, want %v", resp.ByMonth, tt.months)
+			}
+			for i, m := range tt.months {
+				if resp.ByMonth[i].Key != m {
+					t.Errorf("month %d = %s, want %s", i, resp.ByMonth[i].Key, m)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// PRECHECK: 上传前按图片哈希查询已有结果（POST /api/v1/scan/precheck）
+// ==========================================
+
+// 同一张照片经常被重复提交（重试、多人转发）。客户端先算图片的 SHA-256 发过来：
+//
+//	POST /api/v1/scan/precheck  {"image_hash": "9f86d0…", "winning": …}
+//
+// 服务端已有该图片的识别结果（识别缓存，或扫描历史里同租户的记录）时直接验奖并返回，
+// 响应与 /api/v1/scan 相同，客户端不必再上传图片、也不消耗一次 AI 调用；没有时返回 204，客户端照常上传。
+// ?accuracy=high、?source= 与 /api/v1/scan 含义相同；要求交叉核对（?ensemble=true）需要原图，总是返回 204
+
+// precheckLotteries 按哈希查已有识别结果：先查识别缓存，高精度模式只认缓存里的多次合并结果，
+// 普通模式再退回到扫描历史
+func precheckLotteries(tenant, hash string, passes int, source string) ([]LotteryData, bool) {
+	key := hash
+	if passes > 1 {
+		key += fmt.Sprintf("#x%d", passes)
+	}
+	// 没有原图无法自动判断是否截图，两种都查
+	var suffixes []string
+	switch source {
+	case imageSourceTicket:
+		suffixes = []string{""}
+	case imageSourceScreenshot:
+		suffixes = []string{"#screenshot"}
+	default:
+		suffixes = []string{"", "#screenshot"}
+	}
+	for _, s := range suffixes {
+		if cached, ok := ocrCache.Get(key + s); ok {
+			return cached, true
+		}
+	}
+	if passes > 1 {
+		return nil, false
+	}
+	if r, ok := scanHistory.FindImage(tenant, hash); ok && len(r.Lotteries) > 0 {
+		return r.Lotteries, true
+	}
+	return nil, false
+}
+
+// FindImage 某租户下同一图片最近的一条记录
+func (s *historyStore) FindImage(tenant, hash string) (ScanRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if r := s.records[i]; r.Tenant == tenant && r.ImageHash == hash {
+			return r, true
+		}
+	}
+	return ScanRecord{}, false
+}
+
+func precheckHandler(c *gin.Context) {
+	var req struct {
+		ImageHash string          `json:"image_hash"`
+		Winning   json.RawMessage `json:"winning"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
+		return
+	}
+	hash := strings.ToLower(strings.TrimSpace(req.ImageHash))
+	if raw, err := hex.DecodeString(hash); err != nil || len(raw) != 32 {
+		c.JSON(400, gin.H{"error": "image_hash 应为图片内容的 SHA-256（64 位十六进制）"})
+		return
+	}
+	supplied, err := parseSuppliedDraws(req.Winning)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "winning 格式错误: " + err.Error()})
+		return
+	}
+	source, err := imageSourceOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if ensembleOf(c) {
+		c.Status(204)
+		return
+	}
+	lotteries, ok := precheckLotteries(tenantOf(c), hash, ocrPassesOf(c), source)
+	if !ok {
+		c.Status(204)
+		return
+	}
+
+	budget := newRequestBudget(withSuppliedDraws(c.Request.Context(), supplied))
+	defer budget.Done()
+	results, err := verifyAll(budget, lotteries)
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	// 开启了图片存储时取回原图，扫描历史照常查重、篡改检查照常进行
+	var fileBytes []byte
+	if p := images.OriginalPath(hash); p != "" {
+		fileBytes, _ = readSealedFile(p)
+	}
+	if len(fileBytes) > 0 {
+		inspectImage(fileBytes).applyAll(results)
+	}
+	if len(supplied) == 0 {
+		onScanCompleted(originOf(c), fileBytes, results)
+	}
+	c.Header("X-Precheck", "hit")
+	respondResults(c, results)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPrecheckLotteries(t *testing.T) {
+	ocrCache.Purge()
+	t.Cleanup(ocrCache.Purge)
+	old := scanHistory
+	t.Cleanup(func() { scanHistory = old })
+
+	ssq := []LotteryData{{Type: "双色球", Issue: "2025107"}}
+	dlt := []LotteryData{{Type: "大乐透", Issue: "25100"}}
+	ocrCache.Set("cached", ssq)
+	ocrCache.Set("shot#screenshot", dlt)
+	ocrCache.Set("merged#x3", dlt)
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "shop-a", ImageHash: "scanned", Lotteries: ssq},
+		{ID: "r2", Tenant: "shop-a", ImageHash: "scanned", Lotteries: dlt},
+	}}
+
+	tests := []struct {
+		name     string
+		tenant   string
+		hash     string
+		passes   int
+		source   string
+		wantGame string
+	}{
+		{"识别缓存", "shop-a", "cached", 1, imageSourceAuto, "双色球"},
+		{"未指定来源时也查截图缓存", "shop-a", "shot", 1, imageSourceAuto, "大乐透"},
+		{"指定纸票不查截图缓存", "shop-a", "shot", 1, imageSourceTicket, ""},
+		{"扫描历史取最近一条", "shop-a", "scanned", 1, imageSourceAuto, "大乐透"},
+		{"其他租户的历史不可见", "shop-b", "scanned", 1, imageSourceAuto, ""},
+		{"高精度只认合并结果", "shop-a", "merged", 3, imageSourceAuto, "大乐透"},
+		{"高精度不退回普通缓存和历史", "shop-a", "scanned", 3, imageSourceAuto, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := precheckLotteries(tt.tenant, tt.hash, tt.passes, tt.source)
+			if ok != (tt.wantGame != "") {
+				t.Fatalf("precheckLotteries() ok = %v", ok)
+			}
+			if ok && got[0].Type != tt.wantGame {
+				t.Errorf("precheckLotteries() = %+v, want %s", got, tt.wantGame)
+			}
+		})
+	}
+}
+
+func TestPrecheckHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	ocrCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	t.Cleanup(ocrCache.Purge)
+	old := scanHistory
+	t.Cleanup(func() { scanHistory = old })
+
+	known := imageHash([]byte("known"))
+	ocrCache.Set(known, []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1},
+	}}})
+	unknown := imageHash([]byte("unknown"))
+
+	tests := []struct {
+		name        string
+		query       string
+		body        string
+		wantStatus  int
+		wantHistory int
+	}{
+		{"已有识别结果直接验奖", "", `{"image_hash": "` + strings.ToUpper(known) + `"}`, 200, 1},
+		{"没有结果时照常上传", "", `{"image_hash": "` + unknown + `"}`, 204, 0},
+		{"交叉核对需要原图", "?ensemble=true", `{"image_hash": "` + known + `"}`, 204, 0},
+		{"提供开奖号码不记历史", "", `{"image_hash": "` + known + `", "winning": {"red": ["02","11","15","21","28","33"], "blue": ["07"]}}`, 200, 0},
+		{"哈希格式错误", "", `{"image_hash": "abc"}`, 400, 0},
+		{"来源错误", "?source=photo", `{"image_hash": "` + known + `"}`, 400, 0},
+		{"JSON 格式错误", "", `{`, 400, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanHistory = &historyStore{}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan/precheck"+tt.query, strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			precheckHandler(c)
+			if c.Writer.Status() != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", c.Writer.Status(), tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == 200 {
+				var results []VerificationResult
+				if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 || results[0].TotalPrize != 5000000*Yuan {
+					t.Errorf("body = %s: %v", w.Body, err)
+				}
+				if w.Header().Get("X-Precheck") != "hit" {
+					t.Error("missing X-Precheck: hit")
+				}
+			}
+			if n := len(scanHistory.records); n != tt.wantHistory {
+				t.Errorf("history = %d records, want %d", n, tt.wantHistory)
+			}
+		})
+	}
+}
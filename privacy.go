@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// ==========================================
+// PRIVACY: 扫描记录匿名化（按租户开启）
+// ==========================================
+
+// 对隐私要求高的部署（如面向个人用户的 App），可以让扫描记录在验奖完成后只保留统计所需的字段。
+// 配置在 data/privacy.json：
+//
+//	{"tenants": {"shop-a": {"anonymize_history": true}}}
+//
+// 开启后该租户新增的扫描记录不保存票面序列号和销售站点编号（含附加玩法），也不保存原图和缩略图
+// （图片上印着序列号）；彩种、期号、号码、倍数、销售时间等仍然保留，个人记录、资产汇总和中奖统计照常可用。
+// 验奖响应本身不受影响。因为不再保存序列号，这些租户的重复扫描只能按图片比对；
+// 已有的记录不会被改写，站点报表也统计不到匿名化之后的扫描
+
+// TenantPrivacy 单个租户的隐私设置
+type TenantPrivacy struct {
+	AnonymizeHistory bool `json:"anonymize_history"`
+}
+
+// PrivacyConfig data/privacy.json
+type PrivacyConfig struct {
+	Tenants map[string]TenantPrivacy `json:"tenants"`
+}
+
+type privacyStore struct {
+	mu  sync.RWMutex
+	cfg PrivacyConfig
+}
+
+var privacy = &privacyStore{}
+
+// Load 读取隐私设置，文件不存在时所有租户照常保存
+func (s *privacyStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cfg PrivacyConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+	return nil
+}
+
+// Anonymize 该租户的扫描记录是否需要匿名化
+func (s *privacyStore) Anonymize(tenant string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Tenants[tenant].AnonymizeHistory
+}
+
+// anonymizeLottery 去掉票面序列号与站点编号，附加玩法一并处理
+func anonymizeLottery(l LotteryData) LotteryData {
+	l.Serial, l.Station = "", ""
+	if len(l.Sections) > 0 {
+		sections := make([]LotteryData, len(l.Sections))
+		for i, sec := range l.Sections {
+			sections[i] = anonymizeLottery(sec)
+		}
+		l.Sections = sections
+	}
+	return l
+}
+
+// anonymizeRecord 匿名化一条待保存的扫描记录
+func anonymizeRecord(r ScanRecord) ScanRecord {
+	r.Station = ""
+	lotteries := make([]LotteryData, len(r.Lotteries))
+	for i, l := range r.Lotteries {
+		lotteries[i] = anonymizeLottery(l)
+	}
+	r.Lotteries = lotteries
+	return r
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTestPrivacy 换成只对 tenants 开启匿名化的设置
+func useTestPrivacy(t *testing.T, tenants ...string) {
+	t.Helper()
+	old := privacy
+	t.Cleanup(func() { privacy = old })
+	privacy = &privacyStore{cfg: PrivacyConfig{Tenants: map[string]TenantPrivacy{}}}
+	for _, tenant := range tenants {
+		privacy.cfg.Tenants[tenant] = TenantPrivacy{AnonymizeHistory: true}
+	}
+}
+
+func TestPrivacyStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		tenant  string
+		want    bool
+		wantErr bool
+	}{
+		{"文件不存在不匿名", "", "shop-a", false, false},
+		{"开启的租户", `{"tenants": {"shop-a": {"anonymize_history": true}}}`, "shop-a", true, false},
+		{"其他租户不受影响", `{"tenants": {"shop-a": {"anonymize_history": true}}}`, "shop-b", false, false},
+		{"JSON 格式错误", `{`, "shop-a", false, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "missing.json")
+			if tt.file != "" {
+				path = writeTestFile(t, dir, string(rune('a'+i))+".json", []byte(tt.file))
+			}
+			s := &privacyStore{}
+			if err := s.Load(path); (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := s.Anonymize(tt.tenant); got != tt.want {
+				t.Errorf("Anonymize(%s) = %v, want %v", tt.tenant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymizeRecord(t *testing.T) {
+	r := ScanRecord{ID: "r1", Tenant: "shop-a", Station: "44010001", Lotteries: []LotteryData{{
+		Type: "大乐透", Issue: "25107", Serial: "SN-1", Station: "44010001", Amount: 6,
+		Tickets:  []UserTicket{{Red: []string{"01"}, Multiplier: 2}},
+		Sections: []LotteryData{{Type: "排列三", Serial: "SN-1", Station: "44010001", Tickets: []UserTicket{{Red: []string{"1", "2", "3"}}}}},
+	}}}
+	got := anonymizeRecord(r)
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"记录站点", got.Station, ""},
+		{"票面序列号", got.Lotteries[0].Serial, ""},
+		{"票面站点", got.Lotteries[0].Station, ""},
+		{"附加玩法序列号", got.Lotteries[0].Sections[0].Serial, ""},
+		{"附加玩法站点", got.Lotteries[0].Sections[0].Station, ""},
+		{"期号保留", got.Lotteries[0].Issue, "25107"},
+		{"号码保留", got.Lotteries[0].Sections[0].Tickets[0].Red[2], "3"},
+		{"原记录不变", r.Lotteries[0].Serial + r.Lotteries[0].Sections[0].Station, "SN-144010001"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if got.Lotteries[0].Amount != 6 || got.Lotteries[0].Tickets[0].Multiplier != 2 {
+		t.Errorf("lottery = %+v, want amount and multiplier kept", got.Lotteries[0])
+	}
+}
+
+// 匿名化的租户落盘时去掉序列号和站点，也不保存图片
+func TestHistoryAddAnonymized(t *testing.T) {
+	useTestPrivacy(t, "shop-a")
+	oldImages := images
+	t.Cleanup(func() { images = oldImages })
+	images = &imageStore{dir: t.TempDir(), enabled: true, thumbSize: 16}
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := &historyStore{path: path}
+
+	tests := []struct {
+		name      string
+		tenant    string
+		image     []byte
+		wantKept  bool
+		wantImage bool
+	}{
+		{"开启匿名化的租户", "shop-a", testPNG(t, 40, 30), false, false},
+		{"其他租户照常保存", "shop-b", testPNG(t, 41, 30), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []VerificationResult{{OCRData: LotteryData{Type: "双色球", Issue: "2025107", Serial: "SN-" + tt.tenant, Station: "44010001"}}}
+			s.Add(ScanOrigin{Tenant: tt.tenant, UserID: "u1"}, tt.image, results)
+
+			loaded := &historyStore{}
+			if err := loaded.Load(path); err != nil {
+				t.Fatal(err)
+			}
+			var r ScanRecord
+			for _, rec := range loaded.records {
+				if rec.Tenant == tt.tenant {
+					r = rec
+				}
+			}
+			if r.ID == "" || r.Lotteries[0].Issue != "2025107" {
+				t.Fatalf("record = %+v", r)
+			}
+			if kept := r.Station != "" && r.Lotteries[0].Serial != "" && r.Lotteries[0].Station != ""; kept != tt.wantKept {
+				t.Errorf("station %q serial %q, want kept %v", r.Station, r.Lotteries[0].Serial, tt.wantKept)
+			}
+			time.Sleep(20 * time.Millisecond) // 缩略图在后台生成
+			if saved := images.OriginalPath(imageHash(tt.image)) != ""; saved != tt.wantImage {
+				t.Errorf("image saved = %v, want %v", saved, tt.wantImage)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+// ==========================================
+// PRIZES: 浮动奖级实际奖金
+// ==========================================
+
+// 大乐透一、二等奖为浮动奖金，每期按销量和中奖注数计算，追加投注另得基本奖金的 80%。
+// 开奖数据带有官方公布的每注奖金时按实际值计，否则用验奖器的估算值并在结果里标记 estimated
+
+// dltFloatingLevels 大乐透浮动奖级，追加投注也只在这两个奖级有追加奖金
+var dltFloatingLevels = map[int]bool{1: true, 2: true}
+
+// dltAddOnRate 官方未公布追加奖金时按基本奖金的 80% 估算
+const dltAddOnRate = 0.8
+
+func yuanMap(m map[int]int64) map[int]Fen {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[int]Fen, len(m))
+	for level, v := range m {
+		out[level] = Fen(v) * Yuan
+	}
+	return out
+}
+
+// floatingPrize 大乐透浮动奖级的每注奖金，第二个返回值表示采用了估算值
+func (win WinningNumbers) floatingPrize(level int, estimate Fen) (Fen, bool) {
+	if !dltFloatingLevels[level] {
+		return estimate, false
+	}
+	if actual, ok := win.Prizes[level]; ok && actual > 0 {
+		return actual, false
+	}
+	return estimate, true
+}
+
+// addOnPrize 追加投注在 base（该奖级每注基本奖金）之外另得的奖金
+func (win WinningNumbers) addOnPrize(level int, base Fen) (Fen, bool) {
+	if !dltFloatingLevels[level] {
+		return 0, false
+	}
+	if actual, ok := win.AddOnPrizes[level]; ok && actual > 0 {
+		return actual, false
+	}
+	return Fen(float64(base) * dltAddOnRate), true
+}
+
+// prizeEstimated 某一注的奖金是否含估算部分
+func (win WinningNumbers) prizeEstimated(lotteryType string, level int, addOn bool) bool {
+	if canonicalGame(lotteryType) != "大乐透" || !dltFloatingLevels[level] {
+		return false
+	}
+	if _, ok := win.Prizes[level]; !ok {
+		return true
+	}
+	_, ok := win.AddOnPrizes[level]
+	return addOn && !ok
+}
@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestFloatingPrize(t *testing.T) {
+	published := WinningNumbers{
+		Prizes:      map[int]Fen{1: 8000000 * Yuan, 2: 150000 * Yuan},
+		AddOnPrizes: map[int]Fen{1: 6400000 * Yuan},
+	}
+	tests := []struct {
+		name          string
+		win           WinningNumbers
+		level         int
+		base          Fen
+		want          Fen
+		wantEstimated bool
+		addOn         Fen
+		addOnEstimate bool
+	}{
+		{"未公布按估算", WinningNumbers{}, 1, 10000000 * Yuan, 10000000 * Yuan, true, 8000000 * Yuan, true},
+		{"已公布按实际", published, 1, 10000000 * Yuan, 8000000 * Yuan, false, 6400000 * Yuan, false},
+		{"基本奖已公布追加未公布", published, 2, 200000 * Yuan, 150000 * Yuan, false, 120000 * Yuan, true},
+		{"固定奖级不浮动", published, 3, 10000 * Yuan, 10000 * Yuan, false, 0, false},
+		{"公布值为 0 视为未公布", WinningNumbers{Prizes: map[int]Fen{1: 0}}, 1, 100 * Yuan, 100 * Yuan, true, 80 * Yuan, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, estimated := tt.win.floatingPrize(tt.level, tt.base)
+			if got != tt.want || estimated != tt.wantEstimated {
+				t.Errorf("floatingPrize() = %s, %v, want %s, %v", got, estimated, tt.want, tt.wantEstimated)
+			}
+			addOn, addOnEstimated := tt.win.addOnPrize(tt.level, got)
+			if addOn != tt.addOn || addOnEstimated != tt.addOnEstimate {
+				t.Errorf("addOnPrize() = %s, %v, want %s, %v", addOn, addOnEstimated, tt.addOn, tt.addOnEstimate)
+			}
+		})
+	}
+}
+
+func TestPrizeEstimated(t *testing.T) {
+	published := WinningNumbers{Prizes: map[int]Fen{1: 8000000 * Yuan}, AddOnPrizes: map[int]Fen{1: 6400000 * Yuan}}
+	baseOnly := WinningNumbers{Prizes: map[int]Fen{1: 8000000 * Yuan}}
+	tests := []struct {
+		name  string
+		win   WinningNumbers
+		game  string
+		level int
+		addOn bool
+		want  bool
+	}{
+		{"未公布", WinningNumbers{}, "大乐透", 1, false, true},
+		{"已公布", published, "dlt", 1, true, false},
+		{"追加未公布", baseOnly, "大乐透", 1, true, true},
+		{"不追加只看基本奖", baseOnly, "大乐透", 1, false, false},
+		{"固定奖级", WinningNumbers{}, "大乐透", 3, false, false},
+		{"其他彩种", WinningNumbers{}, "双色球", 1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.win.prizeEstimated(tt.game, tt.level, tt.addOn); got != tt.want {
+				t.Errorf("prizeEstimated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrawRecordWinningPrizes(t *testing.T) {
+	tests := []struct {
+		name      string
+		d         DrawRecord
+		wantPrize Fen
+		wantAddOn Fen
+	}{
+		{"带公布奖金", DrawRecord{Game: "大乐透", Prizes: map[int]int64{1: 8000000}, AddOnPrizes: map[int]int64{1: 6400000}}, 8000000 * Yuan, 6400000 * Yuan},
+		{"未公布", DrawRecord{Game: "大乐透"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			win := tt.d.winning()
+			if win.Prizes[1] != tt.wantPrize || win.AddOnPrizes[1] != tt.wantAddOn {
+				t.Errorf("winning() prizes = %v / %v", win.Prizes, win.AddOnPrizes)
+			}
+		})
+	}
+	// 公布奖金也算开奖数据变化，同步时需要覆盖
+	a := DrawRecord{Game: "大乐透", Issue: "25100", Red: []string{"01"}}
+	b := a
+	b.Prizes = map[int]int64{1: 8000000}
+	if sameDraw(a, b) {
+		t.Error("sameDraw() should report published prizes as a change")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ==========================================
+// PROFILE: 配置文件中的命名环境（dev / staging / prod）
+// ==========================================
+
+// 服务的配置全部来自环境变量。多套环境共用一个配置文件（默认 lottery.yaml，可用 -config 或 CONFIG_FILE 指定），
+// 启动时以 -profile（或 LOTTERY_PROFILE）选择其中一套：
+//
+//	env:                      # 所有 profile 共用
+//	  TZ: Asia/Shanghai
+//	profiles:
+//	  prod:
+//	    env:
+//	      STATE_BACKEND: redis
+//	      REDIS_URL: redis://cache:6379/0
+//	  staging:
+//	    extends: prod           # 继承 prod，只写不同的部分
+//	    env:
+//	      REDIS_PREFIX: "staging:"
+//	  dev:
+//	    env:
+//	      HISTORY_STORE: sqlite
+//	      STORE_IMAGES: true
+//
+// 取值顺序：顶层 env → 最上层的祖先 profile → … → 所选 profile，后者覆盖前者；
+// 进程启动时已设置的环境变量优先于配置文件，便于临时覆盖单项。
+// 未指定 profile 时只应用顶层 env；配置文件不存在时不做任何事
+
+// profileDef 一个命名环境
+type profileDef struct {
+	Extends string         `yaml:"extends"`
+	Env     map[string]any `yaml:"env"`
+}
+
+type profileFile struct {
+	Env      map[string]any        `yaml:"env"`
+	Profiles map[string]profileDef `yaml:"profiles"`
+}
+
+// configFilePath 配置文件位置
+func configFilePath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := strings.TrimSpace(os.Getenv("CONFIG_FILE")); v != "" {
+		return v
+	}
+	return "lottery.yaml"
+}
+
+// resolveProfile 按继承链合并出所选 profile 的全部变量
+func (f *profileFile) resolveProfile(name string) (map[string]string, error) {
+	out := map[string]string{}
+	merge := func(env map[string]any) {
+		for k, v := range env {
+			if v == nil {
+				out[k] = ""
+				continue
+			}
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	merge(f.Env)
+	if name == "" {
+		return out, nil
+	}
+
+	var chain []profileDef
+	seen := map[string]bool{}
+	for cur := name; cur != ""; {
+		if seen[cur] {
+			return nil, fmt.Errorf("profile %q 的 extends 形成循环", cur)
+		}
+		seen[cur] = true
+		p, ok := f.Profiles[cur]
+		if !ok {
+			if cur == name {
+				return nil, fmt.Errorf("配置文件中没有 profile %q", name)
+			}
+			return nil, fmt.Errorf("profile %q 继承的 %q 不存在", name, cur)
+		}
+		chain = append(chain, p)
+		cur = p.Extends
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		merge(chain[i].Env)
+	}
+	return out, nil
+}
+
+// applyProfile 读取配置文件并把所选 profile 的变量写入环境（已设置的环境变量不覆盖），需在读取其他配置之前调用
+func applyProfile(path, name string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if name != "" {
+			return fmt.Errorf("指定了 profile %q，但配置文件 %s 不存在", name, path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f profileFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	env, err := f.resolveProfile(name)
+	if err != nil {
+		return err
+	}
+	applied := 0
+	for k, v := range env {
+		if _, ok := os.LookupEnv(k); ok {
+			continue
+		}
+		os.Setenv(k, v)
+		applied++
+	}
+	if name != "" {
+		log.Printf("已加载 %s 中的 profile %s（%d 项配置）", path, name, applied)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+const testProfileFile = `
+env:
+  TZ: Asia/Shanghai
+  STORE_IMAGES: false
+profiles:
+  prod:
+    env:
+      STATE_BACKEND: redis
+      REDIS_URL: redis://cache:6379/0
+      SHUTDOWN_TIMEOUT: 90s
+  staging:
+    extends: prod
+    env:
+      REDIS_PREFIX: "staging:"
+      SHUTDOWN_TIMEOUT: 30s
+  dev:
+    env:
+      HISTORY_STORE: sqlite
+      STORE_IMAGES: true
+      REDIS_URL:
+  loop-a:
+    extends: loop-b
+  loop-b:
+    extends: loop-a
+  orphan:
+    extends: missing
+`
+
+func TestResolveProfile(t *testing.T) {
+	f := &profileFile{}
+	if err := yaml.Unmarshal([]byte(testProfileFile), f); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		profile string
+		want    string
+		wantErr string
+	}{
+		{"只用顶层配置", "", "STORE_IMAGES=false TZ=Asia/Shanghai", ""},
+		{"prod", "prod", "REDIS_URL=redis://cache:6379/0 SHUTDOWN_TIMEOUT=90s STATE_BACKEND=redis STORE_IMAGES=false TZ=Asia/Shanghai", ""},
+		{"继承后覆盖", "staging", "REDIS_PREFIX=staging: REDIS_URL=redis://cache:6379/0 SHUTDOWN_TIMEOUT=30s STATE_BACKEND=redis STORE_IMAGES=false TZ=Asia/Shanghai", ""},
+		{"覆盖顶层配置与空值", "dev", "HISTORY_STORE=sqlite REDIS_URL= STORE_IMAGES=true TZ=Asia/Shanghai", ""},
+		{"不存在的 profile", "qa", "", `没有 profile "qa"`},
+		{"继承的 profile 不存在", "orphan", "", `继承的 "missing" 不存在`},
+		{"循环继承", "loop-a", "", "形成循环"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := f.resolveProfile(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveProfile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pairs []string
+			for k, v := range env {
+				pairs = append(pairs, k+"="+v)
+			}
+			sort.Strings(pairs)
+			if got := strings.Join(pairs, " "); got != tt.want {
+				t.Errorf("resolveProfile() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "lottery.yaml", []byte(testProfileFile))
+	tests := []struct {
+		name    string
+		path    string
+		profile string
+		preset  map[string]string // 启动时已设置的环境变量
+		want    map[string]string
+		wantErr string
+	}{
+		{"写入所选 profile", path, "staging", nil,
+			map[string]string{"STATE_BACKEND": "redis", "REDIS_PREFIX": "staging:", "TZ": "Asia/Shanghai"}, ""},
+		{"已设置的环境变量优先", path, "staging", map[string]string{"REDIS_PREFIX": "mine:", "STATE_BACKEND": ""},
+			map[string]string{"STATE_BACKEND": "", "REDIS_PREFIX": "mine:", "SHUTDOWN_TIMEOUT": "30s"}, ""},
+		{"配置文件不存在时不做任何事", filepath.Join(dir, "missing.yaml"), "", nil, map[string]string{"TZ": ""}, ""},
+		{"指定了 profile 但配置文件不存在", filepath.Join(dir, "missing.yaml"), "prod", nil, nil, "不存在"},
+		{"YAML 格式错误", writeTestFile(t, dir, "bad.yaml", []byte("env: [")), "", nil, nil, "解析"},
+		{"profile 不存在", path, "qa", nil, nil, `没有 profile "qa"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"TZ", "STORE_IMAGES", "STATE_BACKEND", "REDIS_URL", "REDIS_PREFIX", "SHUTDOWN_TIMEOUT", "HISTORY_STORE"} {
+				t.Setenv(k, "")
+				os.Unsetenv(k)
+			}
+			for k, v := range tt.preset {
+				t.Setenv(k, v)
+			}
+			err := applyProfile(tt.path, tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("applyProfile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.want {
+				if got := os.Getenv(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	tests := []struct {
+		flag string
+		env  string
+		want string
+	}{
+		{"", "", "lottery.yaml"},
+		{"", " /etc/lottery.yaml ", "/etc/lottery.yaml"},
+		{"prod.yaml", "/etc/lottery.yaml", "prod.yaml"},
+	}
+	for _, tt := range tests {
+		t.Setenv("CONFIG_FILE", tt.env)
+		if got := configFilePath(tt.flag); got != tt.want {
+			t.Errorf("configFilePath(%q) with CONFIG_FILE=%q = %s, want %s", tt.flag, tt.env, got, tt.want)
+		}
+	}
+}
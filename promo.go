@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// PROMO: 派奖活动（限时调整固定奖金）
+// ==========================================
+
+// PrizeAdjustment 派奖期间某一奖级的调整，金额单位为元；
+// Bonus 为每注在原奖金基础上追加，Prize 不为 0 时直接替换原奖金。Modes 为空表示所有投注方式
+type PrizeAdjustment struct {
+	Level int      `json:"level"`
+	Bonus int64    `json:"bonus,omitempty"`
+	Prize int64    `json:"prize,omitempty"`
+	Modes []string `json:"modes,omitempty"` // 单式 / 复式 / 胆拖
+}
+
+// PrizePromotion data/promotions.json 中的一次派奖活动，按开奖日期（含首尾）和可选的期号范围生效
+type PrizePromotion struct {
+	Name        string            `json:"name"`
+	Game        string            `json:"game"`
+	Start       string            `json:"start"` // 2025-02-01
+	End         string            `json:"end"`
+	FirstIssue  string            `json:"first_issue,omitempty"`
+	LastIssue   string            `json:"last_issue,omitempty"`
+	Adjustments []PrizeAdjustment `json:"adjustments"`
+}
+
+type promotionStore struct {
+	mu    sync.RWMutex
+	promo []PrizePromotion
+}
+
+var promotions = &promotionStore{}
+
+// Load 读取派奖配置，文件不存在时不启用
+func (s *promotionStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []PrizePromotion
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for i, p := range list {
+		for _, d := range []string{p.Start, p.End} {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return fmt.Errorf("派奖活动 %q 日期格式错误: %q", p.Name, d)
+			}
+		}
+		list[i].Game = canonicalGame(p.Game)
+	}
+	s.mu.Lock()
+	s.promo = list
+	s.mu.Unlock()
+	return nil
+}
+
+// For 某一期适用的派奖活动；开奖日期未知时只按期号范围判断，两者都没有则不适用
+func (s *promotionStore) For(game, issue, drawDate string) []PrizePromotion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	game = canonicalGame(game)
+	var out []PrizePromotion
+	for _, p := range s.promo {
+		if p.Game != game {
+			continue
+		}
+		if p.FirstIssue != "" && issue < p.FirstIssue || p.LastIssue != "" && issue > p.LastIssue {
+			continue
+		}
+		if drawDate != "" {
+			// 日期统一为 2006-01-02 格式，按字符串比较即可
+			if drawDate < p.Start || drawDate > p.End {
+				continue
+			}
+		} else if p.FirstIssue == "" && p.LastIssue == "" {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// betTypeOf 判断投注方式：有胆码为胆拖，号码多于该彩种所需个数为复式，否则单式
+func betTypeOf(game string, t UserTicket) string {
+	if len(t.Dan) > 0 || len(t.BlueDan) > 0 {
+		return "胆拖"
+	}
+	if spec, ok := specOf(game); ok && !spec.Ordered {
+		for _, z := range spec.Zones {
+			nums := t.Red
+			if z.Field == "blue" {
+				nums = t.Blue
+			}
+			if len(nums) > z.Pick {
+				return "复式"
+			}
+		}
+	}
+	return "单式"
+}
+
+// promoPrize 对一注奖金套用派奖调整和奖金规则，返回调整后的金额和命中的活动名称
+func (win WinningNumbers) promoPrize(t UserTicket, level int, money Fen) (Fen, string) {
+	if level == 0 {
+		return money, ""
+	}
+	var names []string
+	bet := betTypeOf(win.Game, t)
+	for _, p := range win.Promotions {
+		for _, adj := range p.Adjustments {
+			if adj.Level != level || len(adj.Modes) > 0 && !slices.Contains(adj.Modes, bet) {
+				continue
+			}
+			if adj.Prize > 0 {
+				money = Fen(adj.Prize) * Yuan
+			}
+			money += Fen(adj.Bonus) * Yuan
+			names = append(names, p.Name)
+		}
+	}
+	money, ruled := prizeRules.Apply(win, t, level, money)
+	names = append(names, ruled...)
+	return money, strings.Join(names, "、")
+}
+
+// promoSuffix 中奖状态后注明派奖活动
+func promoSuffix(names string) string {
+	if names == "" {
+		return ""
+	}
+	return "（含" + names + "）"
+}
+
+// winning 开奖号码连同该期适用的派奖活动
+func (d DrawRecord) winning() WinningNumbers {
+	return WinningNumbers{
+		Red: d.Red, Blue: d.Blue, Game: d.Game, Issue: d.Issue, DrawDate: d.DrawDate,
+		Promotions:  promotions.For(d.Game, d.Issue, d.DrawDate),
+		Prizes:      yuanMap(d.Prizes),
+		AddOnPrizes: yuanMap(d.AddOnPrizes),
+	}
+}
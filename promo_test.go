@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPromotions = `[
+{"name": "双色球 10 亿派奖", "game": "双色球", "start": "2025-02-01", "end": "2025-02-28",
+ "adjustments": [{"level": 6, "bonus": 5}, {"level": 4, "prize": 300, "modes": ["复式"]}]},
+{"name": "大乐透按期派奖", "game": "大乐透", "start": "2025-01-01", "end": "2025-12-31", "first_issue": "25010", "last_issue": "25020",
+ "adjustments": [{"level": 9, "bonus": 2}]}
+]`
+
+func TestPromotionStoreFor(t *testing.T) {
+	s := &promotionStore{}
+	if err := s.Load(writeTestFile(t, t.TempDir(), "promotions.json", []byte(testPromotions))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		game     string
+		issue    string
+		drawDate string
+		want     string
+	}{
+		{"活动首日", "双色球", "2025015", "2025-02-01", "双色球 10 亿派奖"},
+		{"活动末日", "双色球", "2025030", "2025-02-28", "双色球 10 亿派奖"},
+		{"活动结束后", "双色球", "2025031", "2025-03-02", ""},
+		{"彩种不同", "大乐透", "25015", "2025-02-10", "大乐透按期派奖"},
+		{"期号范围之外", "大乐透", "25021", "2025-02-10", ""},
+		{"开奖日期未知按期号", "大乐透", "25012", "", "大乐透按期派奖"},
+		{"开奖日期未知也没有期号范围", "双色球", "2025015", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, p := range s.For(tt.game, tt.issue, tt.drawDate) {
+				names = append(names, p.Name)
+			}
+			if got := strings.Join(names, "、"); got != tt.want {
+				t.Errorf("For() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromotionStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"文件不存在不启用", dir + "/missing.json", false},
+		{"日期格式错误", writeTestFile(t, dir, "bad-date.json", []byte(`[{"name":"x","game":"ssq","start":"2025/02/01","end":"2025-02-28"}]`)), true},
+		{"JSON 格式错误", writeTestFile(t, dir, "bad.json", []byte(`[{`)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&promotionStore{}).Load(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBetTypeOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		game   string
+		ticket UserTicket
+		want   string
+	}{
+		{"单式", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}}, "单式"},
+		{"红球复式", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06", "07"}, Blue: []string{"07"}}, "复式"},
+		{"蓝球复式", "双色球", UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07", "08"}}, "复式"},
+		{"胆拖", "双色球", UserTicket{Dan: []string{"01"}, Red: []string{"02", "03", "04", "05", "06", "07"}, Blue: []string{"07"}}, "胆拖"},
+		{"按位玩法没有复式", "排列5", UserTicket{Red: []string{"1", "2", "3", "4", "5", "6"}}, "单式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := betTypeOf(tt.game, tt.ticket); got != tt.want {
+				t.Errorf("betTypeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoPrize(t *testing.T) {
+	s := &promotionStore{}
+	if err := s.Load(writeTestFile(t, t.TempDir(), "promotions.json", []byte(testPromotions))); err != nil {
+		t.Fatal(err)
+	}
+	win := WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Game: "双色球"}
+	promo := win
+	promo.Promotions = s.For("双色球", "2025015", "2025-02-10")
+
+	tests := []struct {
+		name   string
+		win    WinningNumbers
+		ticket UserTicket
+		money  Fen
+		status string
+	}{
+		{"没有活动", win, UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}, 5 * Yuan, "中奖: 5元"},
+		{"六等奖每注加奖", promo, UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}, 10 * Yuan, "（含双色球 10 亿派奖）"},
+		{"四等奖单式不调整", promo, UserTicket{Red: []string{"02", "11", "15", "21", "01", "03"}, Blue: []string{"07"}}, 200 * Yuan, "中奖: 200元"},
+		{"四等奖复式替换奖金", promo, UserTicket{Red: []string{"02", "11", "15", "21", "01", "03", "04"}, Blue: []string{"07"}}, 3*300*Yuan + 4*10*Yuan, "（含双色球 10 亿派奖）"},
+		{"未中奖不加奖", promo, UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"09"}}, 0, "未中奖"},
+	}
+	v := &DoubleColorVerifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, money, status := v.Verify(tt.ticket, tt.win)
+			if money != tt.money || !strings.Contains(status, tt.status) {
+				t.Errorf("Verify() = %s %q, want %s %q", money, status, tt.money, tt.status)
+			}
+		})
+	}
+}
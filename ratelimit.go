@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// ==========================================
+// RATELIMIT: 按 IP / API Key 的请求频率限制（滑动窗口）
+// ==========================================
+
+// 带 API Key 的请求按 Key 限流，频率在 data/api_keys.json 的 rate_per_minute 中配置；
+// 其余请求按来源 IP 限流，频率为 RATE_LIMIT_PER_IP（每分钟请求数）。两者为 0 或未配置时不限制。
+// 计数按最近一分钟滑动，不会在整分钟边界放过两倍的突发。
+//
+// STATE_BACKEND=redis 时计数保存在 Redis，所有副本共同执行同一个限额；Redis 暂时不可用时退回
+// 各副本的本地计数（此时整体允许的频率最多为限额乘以副本数），恢复后自动改回共享计数。
+// 超限返回 429 {"code": "RATE_LIMITED"}，并带 Retry-After 与 X-RateLimit-* 响应头
+
+const rateLimitWindow = time.Minute
+
+func rateLimitPerIP() int { return envInt("RATE_LIMIT_PER_IP", 0) }
+
+// slidingWindowScript 清掉窗口外的请求后计数，未超限时登记本次请求；
+// 返回 {是否放行, 窗口内请求数, 最早一次请求的时间（毫秒）}
+var slidingWindowScript = redis.NewScript(`
+local now, window, limit = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {allowed, count, tonumber(oldest[2] or now)}`)
+
+// rateDecision 一次限流判断的结果
+type rateDecision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type localRateLimiter struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+var localRates = &localRateLimiter{hits: map[string][]time.Time{}}
+
+// Allow 本地滑动窗口：保留窗口内每次请求的时间
+func (l *localRateLimiter) Allow(subject string, limit int, window time.Duration, now time.Time) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hits := l.hits[subject]
+	cut := 0
+	for cut < len(hits) && !hits[cut].After(now.Add(-window)) {
+		cut++
+	}
+	hits = hits[cut:]
+	d := rateDecision{Allowed: len(hits) < limit}
+	if d.Allowed {
+		hits = append(hits, now)
+	}
+	d.Remaining = max(limit-len(hits), 0)
+	if !d.Allowed && len(hits) > 0 {
+		d.RetryAfter = hits[0].Add(window).Sub(now)
+	}
+	if len(hits) == 0 {
+		delete(l.hits, subject)
+	} else {
+		l.hits[subject] = hits
+	}
+	if len(l.hits) > 100000 {
+		l.prune(now, window)
+	}
+	return d
+}
+
+// prune 清理窗口内已无请求的主体；调用方需持有锁
+func (l *localRateLimiter) prune(now time.Time, window time.Duration) {
+	for subject, hits := range l.hits {
+		if len(hits) == 0 || !hits[len(hits)-1].After(now.Add(-window)) {
+			delete(l.hits, subject)
+		}
+	}
+}
+
+// allowShared Redis 滑动窗口
+func allowShared(subject string, limit int, window time.Duration, now time.Time) (rateDecision, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+	member := fmt.Sprintf("%d-%s", now.UnixNano(), newJobID()[:8])
+	res, err := slidingWindowScript.Run(ctx, sharedRedis, []string{sharedKey("rate", subject)},
+		now.UnixMilli(), window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return rateDecision{}, err
+	}
+	d := rateDecision{Allowed: res[0] == 1, Remaining: max(limit-int(res[1]), 0)}
+	if !d.Allowed {
+		d.RetryAfter = time.UnixMilli(res[2]).Add(window).Sub(now)
+	}
+	return d, nil
+}
+
+// allowRequest 优先使用共享计数，Redis 出错时退回本地计数
+func allowRequest(subject string, limit int) rateDecision {
+	now, window := time.Now(), rateLimitWindow
+	if sharedRedis != nil {
+		d, err := allowShared(subject, limit, window, now)
+		if !logSharedErr("限流计数", err) {
+			return d
+		}
+	}
+	return localRates.Allow(subject, limit, window, now)
+}
+
+// rateLimitMiddleware 放在 apiKeyAuth 之后，按 Key 或来源 IP 限流
+func rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject, limit := "ip:"+c.ClientIP(), rateLimitPerIP()
+		if k, ok := apiKeyOf(c); ok {
+			subject, limit = "key:"+k.id(), k.RatePerMinute
+		}
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		d := allowRequest(subject, limit)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		if !d.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(int(d.RetryAfter.Seconds()+0.999), 1)))
+			c.AbortWithStatusJSON(429, gin.H{"error": "请求过于频繁，请稍后再试", "code": "RATE_LIMITED"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestLocalRates 换成空的本地限流计数
+func useTestLocalRates(t *testing.T) {
+	t.Helper()
+	old := localRates
+	t.Cleanup(func() { localRates = old })
+	localRates = &localRateLimiter{hits: map[string][]time.Time{}}
+}
+
+func TestLocalRateLimiterAllow(t *testing.T) {
+	l := &localRateLimiter{hits: map[string][]time.Time{}}
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	steps := []struct {
+		name          string
+		subject       string
+		now           time.Time
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"第一次", "ip:a", at(0), true, 1, 0},
+		{"第二次", "ip:a", at(10 * time.Second), true, 0, 0},
+		{"超限", "ip:a", at(20 * time.Second), false, 0, 40 * time.Second},
+		{"其他主体不受影响", "ip:b", at(20 * time.Second), true, 1, 0},
+		{"整分钟边界不重置", "ip:a", at(59 * time.Second), false, 0, time.Second},
+		{"最早一次滑出窗口", "ip:a", at(61 * time.Second), true, 0, 0},
+		{"再次超限", "ip:a", at(62 * time.Second), false, 0, 8 * time.Second},
+		{"窗口内全部滑出", "ip:a", at(3 * time.Minute), true, 1, 0},
+	}
+	for _, st := range steps {
+		d := l.Allow(st.subject, 2, time.Minute, st.now)
+		if d.Allowed != st.wantAllowed || d.Remaining != st.wantRemaining || d.RetryAfter != st.wantRetry {
+			t.Errorf("%s: Allow() = %+v, want allowed %v remaining %d retry %v", st.name, d, st.wantAllowed, st.wantRemaining, st.wantRetry)
+		}
+	}
+
+	l.prune(at(10*time.Minute), time.Minute)
+	if len(l.hits) != 0 {
+		t.Errorf("prune() kept %d subjects", len(l.hits))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type step struct {
+		name          string
+		apiKey        string
+		remote        string
+		wantStatus    int
+		wantRemaining string
+	}
+	tests := []struct {
+		name   string
+		perIP  string
+		shared bool
+		steps  []step
+	}{
+		{"按来源 IP 限流", "2", false, []step{
+			{"第一次", "", "192.0.2.1:1000", 200, "1"},
+			{"第二次", "", "192.0.2.1:2000", 200, "0"},
+			{"超限", "", "192.0.2.1:3000", 429, "0"},
+			{"其他 IP", "", "192.0.2.2:1000", 200, "1"},
+		}},
+		{"带 API Key 按 Key 限流", "1", false, []step{
+			{"Key 的额度", "sk-a", "192.0.2.1:1000", 200, "2"},
+			{"同一 IP 的匿名请求单独计数", "", "192.0.2.1:1000", 200, "0"},
+			{"换 IP 仍按 Key 计数", "sk-a", "192.0.2.9:1000", 200, "1"},
+			{"Key 未配置频率时不限制", "sk-b", "192.0.2.1:1000", 200, ""},
+			{"匿名请求超限", "", "192.0.2.1:1000", 429, "0"},
+		}},
+		{"未配置时不限制", "", false, []step{
+			{"第一次", "", "192.0.2.1:1000", 200, ""},
+			{"第二次", "", "192.0.2.1:1000", 200, ""},
+		}},
+		{"Redis 不可用时退回本地计数", "1", true, []step{
+			{"第一次", "", "192.0.2.1:1000", 200, "0"},
+			{"超限", "", "192.0.2.1:1000", 429, "0"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestLocalRates(t)
+			useTestAPIKeys(t, APIKey{Key: "sk-a", Tenant: "shop-a", RatePerMinute: 3}, APIKey{Key: "sk-b", Tenant: "shop-b"})
+			if tt.shared {
+				useTestSharedRedis(t)
+			}
+			t.Setenv("RATE_LIMIT_PER_IP", tt.perIP)
+			r := gin.New()
+			r.Use(apiKeyAuth(), rateLimitMiddleware())
+			r.GET("/api/v1/draws", func(c *gin.Context) { c.Status(200) })
+
+			for _, st := range tt.steps {
+				req := httptest.NewRequest("GET", "/api/v1/draws", nil)
+				req.RemoteAddr = st.remote
+				if st.apiKey != "" {
+					req.Header.Set("X-API-Key", st.apiKey)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != st.wantStatus {
+					t.Fatalf("%s: status = %d, want %d: %s", st.name, w.Code, st.wantStatus, w.Body)
+				}
+				if got := w.Header().Get("X-RateLimit-Remaining"); got != st.wantRemaining {
+					t.Errorf("%s: X-RateLimit-Remaining = %q, want %q", st.name, got, st.wantRemaining)
+				}
+				if w.Code == 429 {
+					if w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), `"code":"RATE_LIMITED"`) {
+						t.Errorf("%s: Retry-After = %q body = %s", st.name, w.Header().Get("Retry-After"), w.Body)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ==========================================
+// REDACT: 日志与失败样本脱敏
+// ==========================================
+
+// 票面序列号、条码和用户标识不应以明文出现在日志里（解析失败时会打印模型原始输出）。
+// 所有 log 输出和 gin 访问日志都经过 redactWriter，落盘的结构化数据经过 Marshal；
+// 规则可用 data/redact.json 覆盖：
+//
+//	{"fields": {"serial": "mask", "user_id": "hash", "barcode": "drop"},
+//	 "patterns": [{"name": "phone", "regex": "1[3-9]\\d{9}", "action": "mask"}]}
+//
+// fields 按字段名匹配 JSON（"serial": "..."）和 key=value 两种写法，patterns 匹配任意文本
+
+const (
+	redactMask = "mask" // 只保留末 4 位
+	redactHash = "hash" // 替换为加盐摘要，同一个值脱敏后仍相同，便于关联排查
+	redactDrop = "drop" // 整体替换为 [REDACTED]
+)
+
+// RedactPattern 按正则匹配的敏感信息
+type RedactPattern struct {
+	Name   string `json:"name"`
+	Regex  string `json:"regex"`
+	Action string `json:"action"`
+}
+
+// RedactRules 脱敏规则
+type RedactRules struct {
+	Fields   map[string]string `json:"fields"`
+	Patterns []RedactPattern   `json:"patterns"`
+}
+
+var defaultRedactRules = RedactRules{
+	Fields: map[string]string{
+		"serial":    redactMask,
+		"barcode":   redactMask,
+		"qrcode":    redactDrop,
+		"user_id":   redactHash,
+		"x-user-id": redactHash,
+		"open_id":   redactHash,
+		"openid":    redactHash,
+		"phone":     redactMask,
+		"mobile":    redactMask,
+		"email":     redactHash,
+		"id_card":   redactDrop,
+	},
+	Patterns: []RedactPattern{
+		{Name: "id_card", Regex: `\b\d{17}[\dXx]\b`, Action: redactDrop},
+		{Name: "phone", Regex: `\b1[3-9]\d{9}\b`, Action: redactMask},
+		{Name: "email", Regex: `[\w.+-]+@[\w-]+(\.[\w-]+)+`, Action: redactHash},
+	},
+}
+
+type compiledRedaction struct {
+	re     *regexp.Regexp
+	action string
+	// group 需要替换的子匹配序号；0 表示整体替换
+	group int
+}
+
+type redactor struct {
+	mu     sync.RWMutex
+	rules  []compiledRedaction
+	fields map[string]string // 小写字段名 → 脱敏方式，Marshal 按 JSON 结构匹配
+}
+
+var redaction = newRedactor()
+
+func newRedactor() *redactor {
+	r := &redactor{}
+	if err := r.set(defaultRedactRules); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Load 读取自定义规则，文件不存在时使用默认规则；文件中的字段规则与默认规则合并，action 为空表示不脱敏该字段
+func (r *redactor) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var custom RedactRules
+	if err := json.Unmarshal(raw, &custom); err != nil {
+		return err
+	}
+	rules := RedactRules{Fields: map[string]string{}, Patterns: defaultRedactRules.Patterns}
+	for k, v := range defaultRedactRules.Fields {
+		rules.Fields[k] = v
+	}
+	for k, v := range custom.Fields {
+		rules.Fields[strings.ToLower(k)] = v
+	}
+	if custom.Patterns != nil {
+		rules.Patterns = custom.Patterns
+	}
+	return r.set(rules)
+}
+
+func (r *redactor) set(rules RedactRules) error {
+	var compiled []compiledRedaction
+	actions := map[string]string{}
+	fields := make([]string, 0, len(rules.Fields))
+	for f := range rules.Fields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		action := rules.Fields[f]
+		if action == "" {
+			continue
+		}
+		if err := checkRedactAction(action); err != nil {
+			return fmt.Errorf("字段 %s: %v", f, err)
+		}
+		actions[strings.ToLower(f)] = action
+		name := regexp.QuoteMeta(f)
+		compiled = append(compiled,
+			compiledRedaction{re: regexp.MustCompile(`(?i)("` + name + `"\s*:\s*")([^"]*)(")`), action: action, group: 2},
+			compiledRedaction{re: regexp.MustCompile(`(?i)(\b` + name + `=)([^&\s,;]+)`), action: action, group: 2},
+		)
+	}
+	for _, p := range rules.Patterns {
+		if err := checkRedactAction(p.Action); err != nil {
+			return fmt.Errorf("规则 %s: %v", p.Name, err)
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return fmt.Errorf("规则 %s: %v", p.Name, err)
+		}
+		compiled = append(compiled, compiledRedaction{re: re, action: p.Action})
+	}
+	r.mu.Lock()
+	r.rules, r.fields = compiled, actions
+	r.mu.Unlock()
+	return nil
+}
+
+func checkRedactAction(action string) error {
+	switch action {
+	case redactMask, redactHash, redactDrop:
+		return nil
+	}
+	return fmt.Errorf("未知的脱敏方式 %q（可选 mask / hash / drop）", action)
+}
+
+// Text 对任意文本脱敏
+func (r *redactor) Text(s string) string {
+	r.mu.RLock()
+	rules := r.rules
+	r.mu.RUnlock()
+	for _, rule := range rules {
+		if rule.group == 0 {
+			s = rule.re.ReplaceAllStringFunc(s, func(v string) string { return redactValue(v, rule.action) })
+			continue
+		}
+		s = rule.re.ReplaceAllStringFunc(s, func(m string) string {
+			sub := rule.re.FindStringSubmatchIndex(m)
+			start, end := sub[2*rule.group], sub[2*rule.group+1]
+			return m[:start] + redactValue(m[start:end], rule.action) + m[end:]
+		})
+	}
+	return s
+}
+
+// Marshal 序列化并脱敏：对象中命中字段规则的字符串值按字段处理，其余字符串值按 Text 处理。
+// 字符串里可能嵌着模型原始输出这类 JSON 文本，其中的引号已被转义，只对整段序列化结果做 Text 匹配不到
+func (r *redactor) Marshal(v any, indent string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	fields := r.fields
+	r.mu.RUnlock()
+	doc = r.scrub(doc, fields)
+	if indent == "" {
+		return json.Marshal(doc)
+	}
+	return json.MarshalIndent(doc, "", indent)
+}
+
+func (r *redactor) scrub(v any, fields map[string]string) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			if s, ok := val.(string); ok {
+				if action, ok := fields[strings.ToLower(k)]; ok {
+					x[k] = redactValue(s, action)
+					continue
+				}
+			}
+			x[k] = r.scrub(val, fields)
+		}
+	case []any:
+		for i := range x {
+			x[i] = r.scrub(x[i], fields)
+		}
+	case string:
+		return r.Text(x)
+	}
+	return v
+}
+
+func redactValue(v, action string) string {
+	if v == "" {
+		return v
+	}
+	switch action {
+	case redactMask:
+		runes := []rune(v)
+		if len(runes) <= 4 {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+	case redactHash:
+		sum := sha256.Sum256([]byte(os.Getenv("REDACT_SALT") + v))
+		return "#" + hex.EncodeToString(sum[:6])
+	}
+	return "[REDACTED]"
+}
+
+// redactWriter 包装日志输出；log 和 gin 每条日志都是一次 Write
+type redactWriter struct {
+	w io.Writer
+}
+
+func (rw redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, redaction.Text(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactText(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		hidden  string
+		visible string
+	}{
+		{"JSON 序列号保留末 4 位", `{"serial": "1234567890AB"}`, "12345678", `"********90AB"`},
+		{"key=value 用户标识", "scan user_id=u-42 ok", "u-42", "user_id=#"},
+		{"手机号", "联系 13812345678", "13812345678", "*******5678"},
+		{"身份证", "证件 11010519491231002X", "11010519491231002X", "[REDACTED]"},
+		{"无关内容不变", `{"type": "双色球"}`, "", `{"type": "双色球"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redaction.Text(tt.in)
+			if tt.hidden != "" && strings.Contains(got, tt.hidden) {
+				t.Errorf("Text(%q) = %q, still contains %q", tt.in, got, tt.hidden)
+			}
+			if !strings.Contains(got, tt.visible) {
+				t.Errorf("Text(%q) = %q, want it to contain %q", tt.in, got, tt.visible)
+			}
+		})
+	}
+}
+
+func TestRedactMarshal(t *testing.T) {
+	lottery := LotteryData{Type: "双色球", Issue: "2025107", Serial: "8801234567890123"}
+	tests := []struct {
+		name   string
+		v      any
+		hidden []string
+	}{
+		{"字符串里嵌着的 JSON 与结构化字段", &struct {
+			RawOutput string        `json:"raw_output"`
+			Lotteries []LotteryData `json:"lotteries"`
+			UserID    string        `json:"user_id"`
+		}{
+			RawOutput: `[{"type": "双色球", "serial": "8801234567890123", "user_id": "u-42"}]`,
+			Lotteries: []LotteryData{lottery},
+			UserID:    "u-42",
+		}, []string{"8801234567890123", `"u-42"`}},
+		{"调试记录的原始输出与识别结果", &DebugTrace{
+			ID: "t1", CreatedAt: time.Now(), Timings: map[string]int64{"total": 1},
+			Exchanges: []debugExchange{{
+				RawOutput: `[{"type": "双色球", "serial": "8801234567890123", "user_id": "u-42"}]`,
+				Cleaned:   []LotteryData{lottery},
+			}},
+		}, []string{"8801234567890123", "u-42"}},
+		{"训练样本", &datasetSample{Image: "images/00001.jpg", Label: []LotteryData{lottery}, UserID: "u-42"},
+			[]string{"8801234567890123", `"u-42"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := redaction.Marshal(tt.v, "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range tt.hidden {
+				if strings.Contains(string(raw), h) {
+					t.Errorf("output still contains %q:\n%s", h, raw)
+				}
+			}
+			// 脱敏后仍能按原结构读回
+			if err := json.Unmarshal(raw, tt.v); err != nil {
+				t.Errorf("unmarshal redacted output: %v", err)
+			}
+		})
+	}
+}
+
+func TestDatasetLineRedacted(t *testing.T) {
+	s := &datasetSample{
+		Image: "images/00001.jpg", Source: "review", UserID: "u-42",
+		Label: []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "8801234567890123"}},
+	}
+	for _, format := range []string{"plain", "chat"} {
+		t.Run(format, func(t *testing.T) {
+			line, err := datasetLine(s, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(line), "8801234567890123") {
+				t.Errorf("serial not redacted: %s", line)
+			}
+			if !strings.Contains(string(line), "0123") {
+				t.Errorf("masked serial should keep last 4 digits: %s", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// REMINDERS: 兑奖截止提醒
+// ==========================================
+
+// ClaimReminder 一笔未过期的中奖记录，截止前 N 天发一次短信提醒
+type ClaimReminder struct {
+	Tenant   string `json:"tenant"`
+	Phone    string `json:"phone"`
+	Game     string `json:"game"`
+	Issue    string `json:"issue"`
+	Amount   Fen    `json:"amount_fen"`
+	Deadline string `json:"deadline"` // 2025-11-15
+	Sent     bool   `json:"sent"`
+}
+
+func (r ClaimReminder) key() string { return r.Phone + "|" + r.Game + "|" + r.Issue }
+
+type reminderStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]ClaimReminder
+}
+
+var claimReminders = &reminderStore{items: map[string]ClaimReminder{}}
+
+func (s *reminderStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := readSealedFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 旧版本以元保存在 amount 字段
+	var list []struct {
+		ClaimReminder
+		AmountYuan int64 `json:"amount"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for _, item := range list {
+		r := item.ClaimReminder
+		if r.Amount == 0 && item.AmountYuan > 0 {
+			r.Amount = Fen(item.AmountYuan) * Yuan
+		}
+		s.items[r.key()] = r
+	}
+	return nil
+}
+
+// persist 调用方需持有锁
+func (s *reminderStore) persist() {
+	if s.path == "" {
+		return
+	}
+	list := make([]ClaimReminder, 0, len(s.items))
+	for _, r := range s.items {
+		list = append(list, r)
+	}
+	raw, _ := json.MarshalIndent(list, "", "  ")
+	tmp := s.path + ".tmp"
+	if err := writeSealedFile(tmp, raw); err != nil {
+		log.Printf("保存兑奖提醒失败: %v", err)
+		return
+	}
+	os.Rename(tmp, s.path)
+}
+
+// Add 登记提醒；同一号码同一期重复扫描只保留一条
+func (s *reminderStore) Add(r ClaimReminder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.items[r.key()]; ok {
+		r.Sent = old.Sent
+	}
+	s.items[r.key()] = r
+	s.persist()
+}
+
+// Run 每小时检查一次：进入提醒窗口的发短信，已过期的清理掉
+func (s *reminderStore) Run() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		s.check(time.Now())
+		<-ticker.C
+	}
+}
+
+func (s *reminderStore) check(now time.Time) {
+	_, cfg := loadSMSProvider()
+	days := envInt("SMS_CLAIM_REMIND_DAYS", 7)
+
+	s.mu.Lock()
+	var due []ClaimReminder
+	changed := false
+	for k, r := range s.items {
+		deadline, err := time.ParseInLocation("2006-01-02", r.Deadline, time.Local)
+		if err != nil || now.After(deadline.AddDate(0, 0, 1)) {
+			delete(s.items, k)
+			changed = true
+			continue
+		}
+		if !r.Sent && now.After(deadline.AddDate(0, 0, -days)) {
+			r.Sent = true
+			s.items[k] = r
+			due = append(due, r)
+			changed = true
+		}
+	}
+	if changed {
+		s.persist()
+	}
+	s.mu.Unlock()
+
+	for _, r := range due {
+		deadline, _ := time.ParseInLocation("2006-01-02", r.Deadline, time.Local)
+		left := int(time.Until(deadline).Hours()/24) + 1
+		sendSMS(r.Tenant, r.Phone, cfg.TemplateClaimReminder, []smsParam{
+			{"game", r.Game},
+			{"issue", r.Issue},
+			{"amount", r.Amount.Decimal()},
+			{"deadline", r.Deadline},
+			{"days", strconv.Itoa(left)},
+		})
+	}
+}
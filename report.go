@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// REPORT: 批量验奖汇总报告（门店日终结算）
+// ==========================================
+
+// 一批票处理完后汇总成一份报告：扫描张数、各彩种各奖级的中奖行数与金额、应付总额，
+// 以及需要人工处理的异常（识别失败、人工复核、排队未完成、票据校验未通过、疑似篡改等）。
+// 三种批量方式都可以生成，JSON 与可打印的 HTML（浏览器"打印为 PDF"即得 PDF）：
+//
+//	ZIP 批次    GET /api/v1/batches/:id/report[?format=html]
+//	多图上传    POST /api/v1/scan/batch?report=json 或 report=html，等全部图片处理完后返回
+//	目录监听    <监听目录>/reports/<日期>.json 与 .html，每处理完一张图片更新当天的报告
+//
+// 应付总额按兑奖规则拆成本店可兑付（单票奖金不超过兑奖规则第一档上限，默认 1 万元）与需到兑奖中心兑取两部分
+
+// BatchReport 一批票的汇总报告
+type BatchReport struct {
+	BatchID        string            `json:"batch_id,omitempty"`
+	Source         string            `json:"source"` // zip / upload / watch
+	Title          string            `json:"title"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+	Complete       bool              `json:"complete"` // 还有排队中的图片时为 false，稍后再取报告会变化
+	Images         int               `json:"images"`
+	Tickets        int               `json:"tickets"`
+	Rows           int               `json:"rows"`
+	WinningTickets int               `json:"winning_tickets"`
+	WinningRows    int               `json:"winning_rows"`
+	PendingTickets int               `json:"pending_tickets"` // 尚未开奖
+	Levels         []ReportLevel     `json:"levels"`
+	TotalPrize     Fen               `json:"total_prize_fen"`
+	ShopPayable    Fen               `json:"shop_payable_fen"`
+	CenterClaim    Fen               `json:"center_claim_fen"`
+	Exceptions     []ReportException `json:"exceptions"`
+}
+
+// ReportLevel 某彩种某奖级的中奖汇总
+type ReportLevel struct {
+	Game  string `json:"game"`
+	Level int    `json:"level"`
+	Name  string `json:"name"`
+	Rows  int    `json:"rows"`
+	Prize Fen    `json:"prize_fen"`
+}
+
+// ReportException 需要人工处理的一张图片或一张票
+type ReportException struct {
+	FileName string `json:"file_name"`
+	Kind     string `json:"kind"`             // failed / review / queued / rejected / error / tamper / duplicate / uncertain
+	Ticket   string `json:"ticket,omitempty"` // 图片中具体哪张票
+	Detail   string `json:"detail"`
+	Ref      string `json:"ref,omitempty"` // 任务或复核单编号
+}
+
+// shopPayLimit 门店可直接兑付的单票奖金上限：兑奖规则第一档（有多档时）的上限
+func (s *claimRuleStore) shopPayLimit() Fen {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.rules.Tiers) > 1 && s.rules.Tiers[0].MaxAmount > 0 {
+		return Fen(s.rules.Tiers[0].MaxAmount) * Yuan
+	}
+	return 0
+}
+
+// buildBatchReport 汇总各图片的处理结果
+func buildBatchReport(source, title string, items []BatchItem) BatchReport {
+	report := BatchReport{
+		Source: source, Title: title, GeneratedAt: time.Now(), Complete: true,
+		Images: len(items), Levels: []ReportLevel{}, Exceptions: []ReportException{},
+	}
+	limit := claimRules.shopPayLimit()
+	levels := map[string]*ReportLevel{}
+	except := func(name, kind, ticket, detail, ref string) {
+		report.Exceptions = append(report.Exceptions, ReportException{FileName: name, Kind: kind, Ticket: ticket, Detail: detail, Ref: ref})
+	}
+	for _, item := range items {
+		switch item.Status {
+		case JobFailed:
+			except(item.FileName, "failed", "", cmp.Or(item.Error, "识别失败"), item.JobID)
+			continue
+		case JobReview:
+			except(item.FileName, "review", "", "大额票据等待人工复核", item.ReviewID)
+			continue
+		case JobQueued, JobProcessing:
+			report.Complete = false
+			except(item.FileName, "queued", "", "排队中，尚未识别", item.JobID)
+			continue
+		}
+		for _, res := range item.Results {
+			report.Tickets++
+			report.TotalPrize += res.TotalPrize
+			switch {
+			case res.TotalPrize > 0 && (limit == 0 || res.TotalPrize > limit):
+				report.CenterClaim += res.TotalPrize
+			case res.TotalPrize > 0:
+				report.ShopPayable += res.TotalPrize
+			}
+			if res.TotalPrize > 0 {
+				report.WinningTickets++
+			}
+			if res.Pending {
+				report.PendingTickets++
+			}
+			ticket := fmt.Sprintf("%s 第%s期", res.OCRData.Type, res.OCRData.Issue)
+			switch {
+			case res.Error != "":
+				except(item.FileName, "error", ticket, res.Error, "")
+			case res.Rejected:
+				except(item.FileName, "rejected", ticket, "票据校验未通过", "")
+			}
+			if res.DuplicateOf != "" {
+				except(item.FileName, "duplicate", ticket, "此前已扫描过", res.DuplicateOf)
+			}
+			for _, w := range res.Warnings {
+				if strings.HasPrefix(w, "TAMPER") {
+					except(item.FileName, "tamper", ticket, w, "")
+				}
+			}
+			if len(res.OCRData.Uncertain) > 0 {
+				except(item.FileName, "uncertain", ticket, strings.Join(res.OCRData.Uncertain, "、"), "")
+			}
+			for _, r := range append([]VerificationResult{res}, res.Sections...) {
+				game := canonicalGame(r.OCRData.Type)
+				for _, d := range r.Details {
+					report.Rows++
+					if d.Prize <= 0 {
+						continue
+					}
+					report.WinningRows++
+					key := fmt.Sprintf("%s/%d", game, d.Level)
+					lv, ok := levels[key]
+					if !ok {
+						lv = &ReportLevel{Game: game, Level: d.Level, Name: levelName(d.Level)}
+						levels[key] = lv
+					}
+					lv.Rows++
+					lv.Prize += d.Prize
+				}
+			}
+		}
+	}
+	for _, lv := range levels {
+		report.Levels = append(report.Levels, *lv)
+	}
+	sort.Slice(report.Levels, func(i, j int) bool {
+		a, b := report.Levels[i], report.Levels[j]
+		if a.Game != b.Game {
+			return a.Game < b.Game
+		}
+		return a.Level < b.Level
+	})
+	return report
+}
+
+// localizeReport 翻译报告里的奖级名称和异常说明
+func localizeReport(cat *messageCatalog, report BatchReport) BatchReport {
+	if cat == nil {
+		return report
+	}
+	report.Title = cat.T(report.Title)
+	levels := make([]ReportLevel, len(report.Levels))
+	for i, lv := range report.Levels {
+		lv.Name = cat.T(lv.Name)
+		levels[i] = lv
+	}
+	exceptions := make([]ReportException, len(report.Exceptions))
+	for i, e := range report.Exceptions {
+		e.Ticket, e.Detail = cat.T(e.Ticket), cat.T(e.Detail)
+		exceptions[i] = e
+	}
+	report.Levels, report.Exceptions = levels, exceptions
+	return report
+}
+
+// renderReportHTML 可打印的 HTML 报告（A4），表头与金额按 cat 翻译
+func renderReportHTML(cat *messageCatalog, report BatchReport) ([]byte, error) {
+	tmpl, err := reportTemplate.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{"t": cat.T})
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, localizeReport(cat, report)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// respondReport format 为 html 时输出可打印页面，否则输出 JSON
+func respondReport(c *gin.Context, report BatchReport, format string) {
+	cat := catalogOf(c)
+	if format != "html" {
+		c.JSON(200, localizeReport(cat, report))
+		return
+	}
+	page, err := renderReportHTML(cat, report)
+	if err != nil {
+		c.JSON(500, gin.H{"error": cat.T("生成报告失败")})
+		return
+	}
+	c.Data(200, "text/html; charset=utf-8", page)
+}
+
+// exceptionKinds 异常类型在报告里的名称
+var exceptionKinds = map[string]string{
+	"failed": "识别失败", "review": "人工复核", "queued": "排队中", "rejected": "校验未通过",
+	"error": "验奖失败", "tamper": "疑似篡改", "duplicate": "重复扫描", "uncertain": "识别不确定",
+}
+
+// reportTemplate 执行前按请求语言 Clone 并替换 t
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"t":    func(s string) string { return s },
+	"kind": func(k string) string { return cmp.Or(exceptionKinds[k], k) },
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	"fen":  func(f Fen) string { return f.String() },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+@page { size: A4; margin: 16mm }
+body { font-family: sans-serif; color: #222; font-size: 13px }
+h1 { font-size: 20px; margin: 0 0 4px }
+.meta { color: #888; margin-bottom: 16px }
+table { border-collapse: collapse; width: 100%; margin-bottom: 18px }
+th, td { border: 1px solid #ccc; padding: 5px 8px; text-align: left }
+th { background: #f5f5f5 }
+td.num { text-align: right }
+.total td { font-weight: bold }
+.warn { color: #d46b08 }
+@media print { .noprint { display: none } }
+</style></head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{t "生成时间"}} {{time .GeneratedAt}}{{if .BatchID}} · {{.BatchID}}{{end}}{{if not .Complete}} · <span class="warn">{{t "仍有图片在排队，报告尚未最终确定"}}</span>{{end}}</div>
+<table>
+<tr><th>{{t "图片"}}</th><th>{{t "彩票"}}</th><th>{{t "号码行"}}</th><th>{{t "中奖彩票"}}</th><th>{{t "中奖行"}}</th><th>{{t "未开奖"}}</th></tr>
+<tr><td class="num">{{.Images}}</td><td class="num">{{.Tickets}}</td><td class="num">{{.Rows}}</td><td class="num">{{.WinningTickets}}</td><td class="num">{{.WinningRows}}</td><td class="num">{{.PendingTickets}}</td></tr>
+</table>
+<table>
+<tr><th>{{t "彩种"}}</th><th>{{t "奖级"}}</th><th>{{t "中奖行数"}}</th><th>{{t "金额"}}</th></tr>
+{{range .Levels}}<tr><td>{{t .Game}}</td><td>{{.Name}}</td><td class="num">{{.Rows}}</td><td class="num">{{t (fen .Prize)}}</td></tr>
+{{else}}<tr><td colspan="4">{{t "本批没有中奖"}}</td></tr>
+{{end}}<tr class="total"><td colspan="3">{{t "应付合计"}}</td><td class="num">{{t (fen .TotalPrize)}}</td></tr>
+<tr><td colspan="3">{{t "其中本店兑付"}}</td><td class="num">{{t (fen .ShopPayable)}}</td></tr>
+<tr><td colspan="3">{{t "其中需到兑奖中心"}}</td><td class="num">{{t (fen .CenterClaim)}}</td></tr>
+</table>
+{{if .Exceptions}}<h2>{{t "需要处理的异常"}}</h2>
+<table>
+<tr><th>{{t "图片"}}</th><th>{{t "类型"}}</th><th>{{t "彩票"}}</th><th>{{t "说明"}}</th><th>{{t "编号"}}</th></tr>
+{{range .Exceptions}}<tr><td>{{.FileName}}</td><td class="warn">{{t (kind .Kind)}}</td><td>{{.Ticket}}</td><td>{{.Detail}}</td><td>{{.Ref}}</td></tr>
+{{end}}</table>{{end}}
+<p class="noprint"><button onclick="window.print()">{{t "打印 / 保存为 PDF"}}</button></p>
+</body></html>`))
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShopPayLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []ClaimTier
+		want  Fen
+	}{
+		{"默认分档", defaultClaimRules.Tiers, 10000 * Yuan},
+		{"只有一档", []ClaimTier{{MaxAmount: 10000}}, 0},
+		{"第一档不设上限", []ClaimTier{{MaxAmount: 0}, {MaxAmount: 10000}}, 0},
+		{"自定义上限", []ClaimTier{{MaxAmount: 5000}, {MaxAmount: 0}}, 5000 * Yuan},
+	}
+	for _, tt := range tests {
+		s := &claimRuleStore{rules: ClaimRules{Tiers: tt.tiers}}
+		if got := s.shopPayLimit(); got != tt.want {
+			t.Errorf("%s: shopPayLimit() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// reportItems 各类处理结果各一张：中奖、大额、失败、复核、排队、校验未通过
+func reportItems() []BatchItem {
+	ssq := LotteryData{Type: "双色球", Issue: "2025107"}
+	return []BatchItem{
+		{FileName: "a.jpg", Status: JobDone, Results: []VerificationResult{
+			{OCRData: ssq, TotalPrize: 3005 * Yuan, Details: []ResultDetail{{Level: 3, Prize: 3000 * Yuan}, {Level: 0}, {Level: 6, Prize: 5 * Yuan}}},
+			{OCRData: LotteryData{Type: "大乐透", Issue: "25108", Uncertain: []string{"tickets[0].red", "issue"}}, Pending: true, Details: []ResultDetail{{Level: 0}}},
+		}},
+		{FileName: "b.jpg", Status: JobDone, Results: []VerificationResult{
+			{OCRData: ssq, TotalPrize: 50010 * Yuan, DuplicateOf: "h1", Warnings: []string{"TAMPER: 号码区域有涂改痕迹", "倍数与票面金额不符"},
+				Details:  []ResultDetail{{Level: 3, Prize: 50000 * Yuan}},
+				Sections: []VerificationResult{{OCRData: LotteryData{Type: "ssq"}, Details: []ResultDetail{{Level: 6, Prize: 10 * Yuan}}}}},
+		}},
+		{FileName: "c.jpg", Status: JobFailed, JobID: "j1"},
+		{FileName: "d.jpg", Status: JobReview, ReviewID: "r1"},
+		{FileName: "e.jpg", Status: JobQueued, JobID: "j2"},
+		{FileName: "f.jpg", Status: JobDone, Results: []VerificationResult{
+			{OCRData: ssq, Rejected: true},
+			{OCRData: ssq, Error: "开奖数据获取失败"},
+		}},
+	}
+}
+
+func TestBuildBatchReport(t *testing.T) {
+	r := buildBatchReport("zip", "批量验奖汇总报告", reportItems())
+	counts := []struct {
+		name      string
+		got, want int
+	}{
+		{"images", r.Images, 6},
+		{"tickets", r.Tickets, 5},
+		{"rows", r.Rows, 6},
+		{"winning tickets", r.WinningTickets, 2},
+		{"winning rows", r.WinningRows, 4},
+		{"pending tickets", r.PendingTickets, 1},
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if r.Complete || r.Source != "zip" || r.TotalPrize != 53015*Yuan || r.ShopPayable != 3005*Yuan || r.CenterClaim != 50010*Yuan {
+		t.Errorf("report = complete %v total %s shop %s center %s", r.Complete, r.TotalPrize, r.ShopPayable, r.CenterClaim)
+	}
+
+	// 奖级按彩种、奖级排序，附加玩法计入对应彩种
+	var levels []string
+	for _, lv := range r.Levels {
+		levels = append(levels, lv.Game+"/"+lv.Name+"/"+string(rune('0'+lv.Rows))+"/"+lv.Prize.String())
+	}
+	if got := strings.Join(levels, ","); got != "双色球/三等奖/2/53,000元,双色球/六等奖/2/15元" {
+		t.Errorf("levels = %s", got)
+	}
+
+	want := []struct{ file, kind, ref string }{
+		{"a.jpg", "uncertain", ""},
+		{"b.jpg", "duplicate", "h1"},
+		{"b.jpg", "tamper", ""},
+		{"c.jpg", "failed", "j1"},
+		{"d.jpg", "review", "r1"},
+		{"e.jpg", "queued", "j2"},
+		{"f.jpg", "rejected", ""},
+		{"f.jpg", "error", ""},
+	}
+	if len(r.Exceptions) != len(want) {
+		t.Fatalf("exceptions = %+v", r.Exceptions)
+	}
+	for i, e := range r.Exceptions {
+		if e.FileName != want[i].file || e.Kind != want[i].kind || e.Ref != want[i].ref {
+			t.Errorf("exceptions[%d] = %+v, want %v", i, e, want[i])
+		}
+	}
+	if e := r.Exceptions[0]; e.Ticket != "大乐透 第25108期" || e.Detail != "tickets[0].red、issue" {
+		t.Errorf("uncertain exception = %+v", e)
+	}
+	if r.Exceptions[3].Detail != "识别失败" {
+		t.Errorf("failed exception without error = %+v", r.Exceptions[3])
+	}
+
+	// 没有图片时各列表为空数组而不是 null
+	empty := buildBatchReport("upload", "", nil)
+	if !empty.Complete || empty.Levels == nil || empty.Exceptions == nil {
+		t.Errorf("empty report = %+v", empty)
+	}
+}
+
+// 兑奖规则只有一档时没有门店兑付上限，全部计入兑奖中心
+func TestBuildBatchReportSingleTier(t *testing.T) {
+	old := claimRules
+	t.Cleanup(func() { claimRules = old })
+	claimRules = &claimRuleStore{rules: ClaimRules{Tiers: []ClaimTier{{Where: "{center}"}}}}
+	r := buildBatchReport("watch", "", reportItems())
+	if r.ShopPayable != 0 || r.CenterClaim != r.TotalPrize {
+		t.Errorf("shop %s center %s total %s", r.ShopPayable, r.CenterClaim, r.TotalPrize)
+	}
+}
+
+func TestLocalizeReport(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	r := buildBatchReport("zip", "批量验奖汇总报告", reportItems())
+	got := localizeReport(catalogFor("en"), r)
+	if got.Title != "Batch Scan Summary" || got.Levels[0].Name != "3rd prize" {
+		t.Errorf("localized = %q %q", got.Title, got.Levels[0].Name)
+	}
+	if e := got.Exceptions[4]; e.Detail != "High-value ticket awaiting manual review" {
+		t.Errorf("localized exception = %+v", e)
+	}
+	if r.Levels[0].Name != "三等奖" || r.Exceptions[4].Detail != "大额票据等待人工复核" {
+		t.Error("localizeReport modified its input")
+	}
+	if localizeReport(nil, r).Title != r.Title {
+		t.Error("nil catalog not passed through")
+	}
+}
+
+func TestRenderReportHTML(t *testing.T) {
+	useTestCatalogs(t, t.TempDir())
+	r := buildBatchReport("zip", "批量验奖汇总报告", reportItems())
+	r.BatchID = "b<1>"
+	tests := []struct {
+		name   string
+		cat    *messageCatalog
+		report BatchReport
+		want   []string
+	}{
+		{"中文", nil, r, []string{"<title>批量验奖汇总报告</title>", "b&lt;1&gt;", "仍有图片在排队", "<td>三等奖</td>", "53,015元", "疑似篡改", "打印 / 保存为 PDF"}},
+		{"英文", catalogFor("en"), r, []string{"Batch Scan Summary", "Some images are still queued", "3rd prize", "Possible tampering", "Total payable"}},
+		{"没有中奖", nil, buildBatchReport("upload", "日终", nil), []string{"本批没有中奖"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := renderReportHTML(tt.cat, tt.report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(string(page), s) {
+					t.Errorf("page missing %q", s)
+				}
+			}
+		})
+	}
+	// 渲染不影响模板本身，之后的中文报告不会带上英文
+	page, _ := renderReportHTML(nil, r)
+	if strings.Contains(string(page), "Total payable") {
+		t.Error("template kept the English translator")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// REPROCESS: 对已保存的扫描重新识别 / 重新验奖
+// ==========================================
+
+// 识别规则或模型升级后，用户可以对历史扫描重新处理，不必重新拍照：
+//
+//	POST /api/v1/scans/:id/reprocess
+//	{"ocr": true, "provider": "secondary", "model": "gpt-4o", "passes": 3}
+//
+// ocr 默认为 true，用保存的原图重新识别（不读识别缓存）后验奖；为 false 时只对记录中的号码按
+// 最新开奖数据和奖金规则重新验奖。provider 为 gemini（默认）或 secondary，model 不填时用该服务的
+// 默认模型，passes 仅对 gemini 有效。结果作为原记录的新版本保存，原记录保留不变，历史列表只显示最新版本。
+// 租户和用户校验同 /api/v1/history/:id/image；未保存原图的记录只能重新验奖
+
+const (
+	providerGemini    = "gemini"
+	providerSecondary = "secondary"
+)
+
+// ReprocessRequest 请求体，全部字段可省略
+type ReprocessRequest struct {
+	OCR      *bool  `json:"ocr"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Passes   int    `json:"passes"`
+}
+
+// reprocessSchema POST /api/v1/scans/:id/reprocess
+var reprocessSchema = &jsonSchema{
+	Type: jsonObject,
+	Fields: map[string]*jsonSchema{
+		"ocr":      {Type: jsonBool},
+		"provider": {Type: jsonString, Enum: []string{providerGemini, providerSecondary}},
+		"model":    {Type: jsonString},
+		"passes":   {Type: jsonInteger, Min: floatPtr(1)},
+	},
+}
+
+// rerunOCR 用指定的识别服务重新识别，绕过识别缓存；返回本次实际使用的模型。多次识别最多 5 次，同 accuracy=high
+func rerunOCR(c *gin.Context, budget *requestBudget, req ReprocessRequest, fileBytes []byte) ([]LotteryData, string, error) {
+	ocrCtx, cancel := budget.Stage(stageOCR)
+	defer cancel()
+	ctx := withDebugTrace(ocrCtx, debugTraceOf(c))
+	var (
+		data  []LotteryData
+		model string
+		err   error
+	)
+	if req.Provider == providerSecondary {
+		sec, _ := loadSecondaryOCR()
+		if req.Model != "" {
+			sec.Model = req.Model
+		}
+		model = sec.Model
+		data, err = sec.Recognize(ctx, fileBytes)
+	} else {
+		apiKey := os.Getenv("GEMINI_API_KEY")
+		ctx = withOCRModel(ctx, req.Model)
+		model = ocrModelFor(ctx)
+		if req.Passes > 1 {
+			data, err = multiPassOCR(ctx, fileBytes, apiKey, min(req.Passes, 5))
+		} else {
+			data, err = callOCRWithBreaker(ctx, fileBytes, apiKey, nil)
+		}
+	}
+	if err != nil {
+		if stageErr := budget.Check(ocrCtx, stageOCR); stageErr != nil {
+			err = stageErr
+		}
+		return nil, model, err
+	}
+	return hooks.AfterOCR(ctx, data), model, nil
+}
+
+// ownScan 路径中 :id 指定的、属于当前租户和用户的扫描记录；不存在时已写好 404
+func ownScan(c *gin.Context) (ScanRecord, bool) {
+	rec, ok := scanHistory.Get(c.Param("id"))
+	if !ok || rec.Tenant != tenantOf(c) || rec.UserID != userOf(c) {
+		c.JSON(404, gin.H{"error": "记录不存在"})
+		return ScanRecord{}, false
+	}
+	return rec, true
+}
+
+// reprocessHandler POST /api/v1/scans/:id/reprocess
+func reprocessHandler(c *gin.Context) {
+	orig, ok := ownScan(c)
+	if !ok {
+		return
+	}
+	var req ReprocessRequest
+	if c.Request.ContentLength != 0 && !bindValidJSON(c, reprocessSchema, &req) {
+		return
+	}
+	if req.Provider == "" {
+		req.Provider = providerGemini
+	}
+	rerun := req.OCR == nil || *req.OCR
+
+	budget := newRequestBudget(withBillingTenant(c.Request.Context(), orig.Tenant))
+	defer budget.Done()
+	lotteries, how := orig.Lotteries, "verify"
+	var fileBytes []byte
+	if rerun {
+		if _, ok := loadSecondaryOCR(); req.Provider == providerSecondary && !ok {
+			c.JSON(400, gin.H{"error": "未配置第二识别服务"})
+			return
+		}
+		if req.Provider == providerGemini && os.Getenv("GEMINI_API_KEY") == "" {
+			c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
+			return
+		}
+		path := images.OriginalPath(orig.ImageHash)
+		if path == "" {
+			c.JSON(409, gin.H{"error": "原图未保存，只能重新验奖"})
+			return
+		}
+		raw, err := readSealedFile(path)
+		if err != nil {
+			c.JSON(409, gin.H{"error": "原图未保存，只能重新验奖"})
+			return
+		}
+		fileBytes = raw
+		data, model, err := rerunOCR(c, budget, req, fileBytes)
+		if errors.Is(err, errCircuitOpen) {
+			c.JSON(503, gin.H{"error": "AI 识别服务暂时不可用，请稍后重试"})
+			return
+		}
+		if err != nil {
+			respondStageError(c, "AI 识别失败: ", err)
+			return
+		}
+		lotteries, how = data, fmt.Sprintf("ocr:%s/%s", req.Provider, model)
+	}
+	if len(lotteries) == 0 {
+		c.JSON(422, gin.H{"error": "无识别结果"})
+		return
+	}
+
+	results, err := verifyEach(budget, lotteries)
+	if err != nil {
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	if fileBytes != nil {
+		inspectImage(fileBytes).applyAll(results)
+	}
+	rec, err := scanHistory.AddVersion(orig, lotteries, how)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "保存扫描记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"id":          rec.ID,
+		"original_id": rec.OriginalID,
+		"version":     rec.Version,
+		"reprocess":   rec.Reprocess,
+		"results":     presentResults(c, results),
+	})
+}
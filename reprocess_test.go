@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 对任一版本再次处理都挂在最初的记录下，版本号接着最大的往下编，并写入记录文件
+func TestHistoryStoreAddVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := &historyStore{path: path}
+	orig := ScanRecord{ID: "a", Tenant: "shop-a", UserID: "u1", DeviceID: "k1", ImageHash: "abcdef0123456789"}
+	if err := s.appendLocked(orig, nil); err != nil {
+		t.Fatal(err)
+	}
+	lottery := []LotteryData{{Type: "双色球", Issue: "2025107", Station: "44010001"}}
+
+	steps := []struct {
+		name        string
+		from        func() ScanRecord
+		how         string
+		wantVersion int
+	}{
+		{"原记录的第一个新版本", func() ScanRecord { return orig }, "verify", 2},
+		{"从新版本再次处理", func() ScanRecord { return s.records[1] }, "ocr:gemini/gemini-x", 3},
+		{"从原记录再次处理", func() ScanRecord { return orig }, "verify", 4},
+	}
+	for _, st := range steps {
+		got, err := s.AddVersion(st.from(), lottery, st.how)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if got.OriginalID != "a" || got.Version != st.wantVersion || got.Reprocess != st.how {
+			t.Errorf("%s: got %s/%d/%s, want a/%d/%s", st.name, got.OriginalID, got.Version, got.Reprocess, st.wantVersion, st.how)
+		}
+		if got.Tenant != "shop-a" || got.UserID != "u1" || got.DeviceID != "k1" || got.ImageHash != orig.ImageHash || got.Station != "44010001" {
+			t.Errorf("%s: fields not carried over: %+v", st.name, got)
+		}
+	}
+
+	loaded := &historyStore{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.records) != 3 || loaded.records[2].Version != 4 {
+		t.Errorf("loaded %d records: %+v", len(loaded.records), loaded.records)
+	}
+	if got, _ := s.Get("a"); got.Version != 0 || got.OriginalID != "" {
+		t.Errorf("original record modified: %+v", got)
+	}
+}
+
+func TestReprocessHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "default.json", []byte(hookTestFixture))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	t.Setenv("OCR_SECONDARY_URL", "")
+	oldHistory, oldImages := scanHistory, images
+	t.Cleanup(func() { scanHistory, images = oldHistory, oldImages })
+
+	const hash = "abcdef0123456789"
+	stored := []LotteryData{{Type: "双色球", Issue: "2025108", Tickets: []UserTicket{{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}, Multiplier: 1}}}}
+	images = &imageStore{dir: t.TempDir(), enabled: true}
+	writeTestFile(t, images.dir, filepath.Join("ab", hash+".img"), []byte("\xff\xd8\xff\xe0reprocess"))
+
+	tests := []struct {
+		name          string
+		id            string
+		user          string
+		body          string
+		apiKey        string
+		wantStatus    int
+		wantVersion   int
+		wantReprocess string
+		wantTickets   int
+	}{
+		{"只重新验奖", "r1", "u1", `{"ocr": false}`, "", 200, 2, "verify", 1},
+		{"没有原图也能重新验奖", "r2", "u1", `{"ocr": false}`, "", 200, 2, "verify", 1},
+		{"重新识别", "r1", "u1", "", "test", 200, 2, "ocr:gemini/" + ocrModel(), 2},
+		{"指定模型", "r1", "u1", `{"model": "gemini-x", "passes": 1}`, "test", 200, 2, "ocr:gemini/gemini-x", 2},
+		{"没有原图不能重新识别", "r2", "u1", `{}`, "test", 409, 0, "", 0},
+		{"未配置第二识别服务", "r1", "u1", `{"provider": "secondary"}`, "test", 400, 0, "", 0},
+		{"未配置识别服务", "r1", "u1", `{"ocr": true}`, "", 500, 0, "", 0},
+		{"参数校验失败", "r1", "u1", `{"provider": "other", "passes": 0}`, "test", 400, 0, "", 0},
+		{"其他用户的记录", "r1", "u2", `{"ocr": false}`, "", 404, 0, "", 0},
+		{"记录不存在", "r9", "u1", `{"ocr": false}`, "", 404, 0, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GEMINI_API_KEY", tt.apiKey)
+			verifyCache.Purge()
+			scanHistory = &historyStore{records: []ScanRecord{
+				{ID: "r1", Tenant: "default", UserID: "u1", ImageHash: hash, Lotteries: stored},
+				{ID: "r2", Tenant: "default", UserID: "u1", Lotteries: stored},
+			}}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scans/"+tt.id+"/reprocess", bytes.NewBufferString(tt.body))
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			c.Set("auth_session", "s1")
+			c.Set("auth_user", tt.user)
+			reprocessHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				if n := len(scanHistory.records); n != 2 {
+					t.Errorf("failed request stored a version: %d records", n)
+				}
+				return
+			}
+			var resp struct {
+				ID         string               `json:"id"`
+				OriginalID string               `json:"original_id"`
+				Version    int                  `json:"version"`
+				Reprocess  string               `json:"reprocess"`
+				Results    []VerificationResult `json:"results"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.OriginalID != tt.id || resp.Version != tt.wantVersion || resp.Reprocess != tt.wantReprocess {
+				t.Errorf("got %s/%d/%s, want %s/%d/%s", resp.OriginalID, resp.Version, resp.Reprocess, tt.id, tt.wantVersion, tt.wantReprocess)
+			}
+			if len(resp.Results) != tt.wantTickets {
+				t.Errorf("got %d results, want %d", len(resp.Results), tt.wantTickets)
+			}
+			// 原记录保留，列表里只剩新版本
+			if _, ok := scanHistory.Get(tt.id); !ok {
+				t.Error("original record removed")
+			}
+			var ids []string
+			for _, rec := range scanHistory.Find("default", "u1") {
+				ids = append(ids, rec.ID)
+			}
+			if !strings.Contains(strings.Join(ids, ","), resp.ID) || strings.Contains(strings.Join(ids, ","), tt.id) {
+				t.Errorf("Find() = %v, want new version %s instead of %s", ids, resp.ID, tt.id)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// RESCAN: 同一张票再次扫描时比对识别结果
+// ==========================================
+
+// RescanDiff 与上次扫描的逐字段差异；两次结果不同时说明其中一次识别有误，需人工核对票面
+type RescanDiff struct {
+	ScanID    string        `json:"scan_id"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	MatchedBy string        `json:"matched_by"` // serial / image
+	Identical bool          `json:"identical"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange 一处差异；Field 形如 issue、tickets[2].red
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// previousScan 在历史中找同一张票上次的识别结果：优先按票面序列号（同租户内），
+// 其次是 findDuplicate 判定的同一张图片。调用方需持有锁
+func (s *historyStore) previousScan(tenant string, dup *ScanRecord, l LotteryData) (*RescanDiff, LotteryData) {
+	if l.Serial != "" {
+		for i := len(s.records) - 1; i >= 0; i-- {
+			r := s.records[i]
+			if r.Tenant != tenant {
+				continue
+			}
+			for _, old := range r.Lotteries {
+				if old.Serial == l.Serial && canonicalGame(old.Type) == canonicalGame(l.Type) {
+					return &RescanDiff{ScanID: r.ID, ScannedAt: r.Time, MatchedBy: "serial"}, old
+				}
+			}
+		}
+	}
+	if dup != nil {
+		for _, old := range dup.Lotteries {
+			if canonicalGame(old.Type) == canonicalGame(l.Type) && old.Issue == l.Issue {
+				return &RescanDiff{ScanID: dup.ID, ScannedAt: dup.Time, MatchedBy: "image"}, old
+			}
+		}
+	}
+	return nil, LotteryData{}
+}
+
+// diffLottery 逐字段比较两次识别结果，号码按票面顺序比较
+func diffLottery(before, after LotteryData) []FieldChange {
+	var out []FieldChange
+	field := func(name, a, b string) {
+		if strings.TrimSpace(a) != strings.TrimSpace(b) {
+			out = append(out, FieldChange{Field: name, Before: a, After: b})
+		}
+	}
+	field("type", before.Type, after.Type)
+	field("issue", before.Issue, after.Issue)
+	field("sale_time", before.SaleTime, after.SaleTime)
+	field("serial", before.Serial, after.Serial)
+	field("station", before.Station, after.Station)
+	field("tickets.count", fmt.Sprint(len(before.Tickets)), fmt.Sprint(len(after.Tickets)))
+
+	for i := 0; i < max(len(before.Tickets), len(after.Tickets)); i++ {
+		var a, b UserTicket
+		if i < len(before.Tickets) {
+			a = before.Tickets[i]
+		}
+		if i < len(after.Tickets) {
+			b = after.Tickets[i]
+		}
+		prefix := fmt.Sprintf("tickets[%d].", i+1)
+		field(prefix+"red", strings.Join(a.Red, " "), strings.Join(b.Red, " "))
+		field(prefix+"blue", strings.Join(a.Blue, " "), strings.Join(b.Blue, " "))
+		field(prefix+"dan", strings.Join(a.Dan, " "), strings.Join(b.Dan, " "))
+		field(prefix+"blue_dan", strings.Join(a.BlueDan, " "), strings.Join(b.BlueDan, " "))
+		field(prefix+"multiplier", fmt.Sprint(a.Multiplier), fmt.Sprint(b.Multiplier))
+		field(prefix+"mode", a.Mode, b.Mode)
+		field(prefix+"add_on", fmt.Sprint(a.AddOn), fmt.Sprint(b.AddOn))
+	}
+	return out
+}
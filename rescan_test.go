@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffLottery(t *testing.T) {
+	base := LotteryData{Type: "双色球", Issue: "2025107", Serial: "A1", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	with := func(edit func(*LotteryData)) LotteryData {
+		l := base
+		l.Tickets = append([]UserTicket{}, base.Tickets...)
+		edit(&l)
+		return l
+	}
+	tests := []struct {
+		name  string
+		after LotteryData
+		want  []FieldChange
+	}{
+		{"完全相同", base, nil},
+		{"首尾空白不算差异", with(func(l *LotteryData) { l.Issue = " 2025107 " }), nil},
+		{"期号不同", with(func(l *LotteryData) { l.Issue = "2025108" }), []FieldChange{{"issue", "2025107", "2025108"}}},
+		{"号码识别不同", with(func(l *LotteryData) {
+			l.Tickets[0] = UserTicket{Red: []string{"02", "11", "16", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 2}
+		}), []FieldChange{
+			{"tickets[1].red", "02 11 15 21 28 33", "02 11 16 21 28 33"},
+			{"tickets[1].multiplier", "1", "2"},
+		}},
+		{"多识别出一注", with(func(l *LotteryData) {
+			l.Tickets = append(l.Tickets, UserTicket{Red: []string{"01"}, Blue: []string{"02"}, Multiplier: 1})
+		}), []FieldChange{
+			{"tickets.count", "1", "2"},
+			{"tickets[2].red", "", "01"},
+			{"tickets[2].blue", "", "02"},
+			{"tickets[2].multiplier", "0", "1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLottery(base, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLottery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousScan(t *testing.T) {
+	at := time.Date(2025, 9, 16, 20, 0, 0, 0, chinaTime)
+	s := &historyStore{records: []ScanRecord{
+		{ID: "old", Tenant: "shop-a", Time: at, Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "A1"}}},
+		{ID: "new", Tenant: "shop-a", Time: at.Add(time.Hour), Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "A1"}}},
+		{ID: "other-tenant", Tenant: "shop-b", Time: at, Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Serial: "B1"}}},
+	}}
+	dup := &ScanRecord{ID: "dup", Time: at, Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107"}}}
+
+	tests := []struct {
+		name      string
+		tenant    string
+		dup       *ScanRecord
+		l         LotteryData
+		wantID    string
+		matchedBy string
+	}{
+		{"按序列号取最近一次", "shop-a", nil, LotteryData{Type: "双色球", Issue: "2025107", Serial: "A1"}, "new", "serial"},
+		{"序列号优先于图片", "shop-a", dup, LotteryData{Type: "双色球", Issue: "2025107", Serial: "A1"}, "new", "serial"},
+		{"其他租户的序列号不算", "shop-a", nil, LotteryData{Type: "双色球", Issue: "2025107", Serial: "B1"}, "", ""},
+		{"彩种不同", "shop-a", nil, LotteryData{Type: "大乐透", Issue: "2025107", Serial: "A1"}, "", ""},
+		{"没有序列号按同一张图片", "shop-a", dup, LotteryData{Type: "双色球", Issue: "2025107"}, "dup", "image"},
+		{"同一张图片但期号不同", "shop-a", dup, LotteryData{Type: "双色球", Issue: "2025108"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, _ := s.previousScan(tt.tenant, tt.dup, tt.l)
+			if tt.wantID == "" {
+				if diff != nil {
+					t.Errorf("previousScan() = %+v, want nil", diff)
+				}
+				return
+			}
+			if diff == nil || diff.ScanID != tt.wantID || diff.MatchedBy != tt.matchedBy {
+				t.Errorf("previousScan() = %+v, want %s/%s", diff, tt.wantID, tt.matchedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ==========================================
+// RESTART: 不中断上传的重启与优雅退出
+// ==========================================
+
+// 单机部署升级时直接杀掉进程会中断正在上传、正在识别的请求。两种做法：
+//
+//  1. 监听套接字交接：替换二进制后向进程发送 SIGUSR2，进程启动新版本并把监听套接字传给它，
+//     新进程就绪后旧进程停止接受新连接，等手上的请求处理完再退出。新进程启动失败时旧进程继续服务。
+//     配置 PID_FILE 时新进程启动后会改写其中的进程号，供 systemd（PIDFile=）或部署脚本找到新进程。
+//  2. REUSE_PORT=1：以 SO_REUSEPORT 监听，新旧两个进程可以同时绑定同一端口，由部署脚本先启动新进程
+//     再向旧进程发送 SIGTERM。
+//
+// 收到 SIGTERM / SIGINT 时同样先停止接受新连接，等待进行中的请求最多 SHUTDOWN_TIMEOUT（默认 60s）。
+// 监听套接字交接与 SO_REUSEPORT 仅支持类 Unix 系统
+
+const listenAddr = ":8080"
+
+// 新进程通过这两个环境变量拿到继承的监听套接字和就绪通知管道（ExtraFiles 中的下标 + 3）
+const (
+	envListenerFD = "LOTTERY_LISTENER_FD"
+	envReadyFD    = "LOTTERY_READY_FD"
+)
+
+func shutdownTimeout() time.Duration { return envDuration("SHUTDOWN_TIMEOUT", 60*time.Second) }
+
+// listen 优先使用父进程交接过来的监听套接字
+func listen() (net.Listener, error) {
+	if v := os.Getenv(envListenerFD); v != "" {
+		fd, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s 格式错误: %v", envListenerFD, err)
+		}
+		f := os.NewFile(uintptr(fd), "listener")
+		defer f.Close()
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("接管监听套接字失败: %v", err)
+		}
+		log.Printf("已接管父进程的监听套接字")
+		return ln, nil
+	}
+	if envBool("REUSE_PORT", false) {
+		return listenReusePort(listenAddr)
+	}
+	return net.Listen("tcp", listenAddr)
+}
+
+// notifyReady 通知父进程新进程已开始服务，并改写 PID_FILE
+func notifyReady() {
+	if path := os.Getenv("PID_FILE"); path != "" {
+		if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+			log.Printf("写入 PID_FILE 失败: %v", err)
+		}
+	}
+	v := os.Getenv(envReadyFD)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(envReadyFD)
+	os.Unsetenv(envListenerFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// serveHTTP 监听 :8080 并处理退出与升级信号，直到服务退出
+func serveHTTP(handler http.Handler) error {
+	ln, err := listen()
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+	notifyReady()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, append([]os.Signal{syscall.SIGTERM, os.Interrupt}, upgradeSignals()...)...)
+	for {
+		select {
+		case err := <-serveErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		case sig := <-sigs:
+			if sig == syscall.SIGTERM || sig == os.Interrupt {
+				log.Printf("收到 %v，停止接受新连接，等待进行中的请求完成", sig)
+				return shutdown(srv)
+			}
+			if err := startUpgrade(ln); err != nil {
+				log.Printf("升级失败，继续由当前进程服务: %v", err)
+				continue
+			}
+			log.Printf("新进程已就绪，当前进程处理完进行中的请求后退出")
+			return shutdown(srv)
+		}
+	}
+}
+
+func shutdown(srv *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("等待请求完成超时: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+var errUpgradeUnsupported = errors.New("当前系统不支持监听套接字交接与 SO_REUSEPORT")
+
+func upgradeSignals() []os.Signal { return nil }
+
+func listenReusePort(string) (net.Listener, error) { return nil, errUpgradeUnsupported }
+
+func startUpgrade(net.Listener) error { return errUpgradeUnsupported }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 退出时等进行中的请求处理完，超过 SHUTDOWN_TIMEOUT 返回错误
+func TestShutdownDrains(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeout   string
+		handleFor time.Duration
+		wantErr   bool
+	}{
+		{"请求在超时前完成", "5s", 200 * time.Millisecond, false},
+		{"等待超时", "50ms", 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHUTDOWN_TIMEOUT", tt.timeout)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			started := make(chan struct{})
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				time.Sleep(tt.handleFor)
+				w.Write([]byte("ok"))
+			})}
+			go srv.Serve(ln)
+			defer srv.Close()
+
+			resp := make(chan string, 1)
+			go func() {
+				res, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					resp <- err.Error()
+					return
+				}
+				defer res.Body.Close()
+				body, _ := io.ReadAll(res.Body)
+				resp <- string(body)
+			}()
+			<-started
+			if err := shutdown(srv); (err != nil) != tt.wantErr {
+				t.Fatalf("shutdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := <-resp; got != "ok" {
+				t.Errorf("in-flight request = %q, want ok", got)
+			}
+			if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+				t.Error("still accepting connections after shutdown")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func upgradeSignals() []os.Signal { return []os.Signal{syscall.SIGUSR2} }
+
+// listenReusePort 以 SO_REUSEPORT 监听，允许新旧进程同时绑定同一端口
+func listenReusePort(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// startUpgrade 以相同参数启动当前可执行文件，交出监听套接字，等待新进程就绪（最多 30 秒）
+func startUpgrade(ln net.Listener) error {
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("监听套接字不支持交接")
+	}
+	lf, err := tl.File()
+	if err != nil {
+		return err
+	}
+	defer lf.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// ExtraFiles[i] 在子进程中的文件描述符为 3+i
+	cmd.ExtraFiles = []*os.File{lf, readyW}
+	cmd.Env = append(os.Environ(), envListenerFD+"="+strconv.Itoa(3), envReadyFD+"="+strconv.Itoa(4))
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		return err
+	}
+	readyW.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyR.Read(buf)
+		ready <- err
+	}()
+	select {
+	case err := <-ready:
+		if err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("新进程未就绪即退出: %v", err)
+		}
+		return nil
+	case err := <-exited:
+		return fmt.Errorf("新进程启动失败: %v", err)
+	case <-time.After(30 * time.Second):
+		cmd.Process.Kill()
+		return fmt.Errorf("新进程 30 秒内未就绪")
+	}
+}
@@ -0,0 +1,138 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestListenInherited(t *testing.T) {
+	parent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	f, err := parent.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// listen 会关闭传入的描述符，这里交出一份副本
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fd       string
+		wantAddr string
+		wantErr  string
+	}{
+		{"接管父进程的监听套接字", strconv.Itoa(fd), parent.Addr().String(), ""},
+		{"文件描述符格式错误", "x", "", envListenerFD + " 格式错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envListenerFD, tt.fd)
+			ln, err := listen()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("listen() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			if ln.Addr().String() != tt.wantAddr {
+				t.Errorf("Addr() = %s, want %s", ln.Addr(), tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestNotifyReady(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "lottery.pid")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	t.Setenv("PID_FILE", pidFile)
+	t.Setenv(envReadyFD, strconv.Itoa(fd))
+	t.Setenv(envListenerFD, "3")
+
+	notifyReady()
+
+	buf := make([]byte, 2)
+	if n, err := r.Read(buf); err != nil || n != 1 || buf[0] != 1 {
+		t.Errorf("ready pipe = %v, %v", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Errorf("ready pipe not closed: %v", err)
+	}
+	if raw, _ := os.ReadFile(pidFile); strings.TrimSpace(string(raw)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("PID_FILE = %q", raw)
+	}
+	// 子进程再启动子进程时不应继承已用过的描述符
+	if os.Getenv(envReadyFD) != "" || os.Getenv(envListenerFD) != "" {
+		t.Error("inherited fd variables not cleared")
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	first, err := listenReusePort("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	addr := first.Addr().String()
+
+	tests := []struct {
+		name    string
+		listen  func() (net.Listener, error)
+		wantErr bool
+	}{
+		{"新进程同样以 SO_REUSEPORT 绑定同一端口", func() (net.Listener, error) { return listenReusePort(addr) }, false},
+		{"普通监听无法绑定", func() (net.Listener, error) { return net.Listen("tcp", addr) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := tt.listen()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listen error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ln != nil {
+				ln.Close()
+			}
+		})
+	}
+}
+
+func TestStartUpgradeUnsupportedListener(t *testing.T) {
+	ln, err := net.Listen("unix", t.TempDir()+"/lottery.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := startUpgrade(ln); err == nil {
+		t.Error("startUpgrade() on a unix socket succeeded")
+	}
+	if got := upgradeSignals(); len(got) != 1 || got[0] != os.Signal(syscall.SIGUSR2) {
+		t.Errorf("upgradeSignals() = %v", got)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// REVIEW: 人工复核队列
+// ==========================================
+
+const (
+	ReviewPending  = "PENDING"
+	ReviewResolved = "RESOLVED"
+)
+
+// ReviewItem 一张等待人工确认号码的票据。图片与状态落盘，复核员确认后才走 onScanCompleted
+type ReviewItem struct {
+	ID     string `json:"review_id"`
+	Status string `json:"status"`
+	ScanOrigin
+	JobID         string               `json:"job_id,omitempty"` // 来自排队任务时，复核完成后回写任务结果
+	CreatedAt     time.Time            `json:"created_at"`
+	Reason        string               `json:"reason"`
+	Primary       []LotteryData        `json:"primary"`
+	Secondary     []LotteryData        `json:"secondary,omitempty"`
+	Disagreements []FieldChange        `json:"disagreements,omitempty"` // before 为主识别，after 为第二识别
+	Preliminary   []VerificationResult `json:"preliminary"`             // 按主识别结果的验奖，仅供参考
+	ResolvedAt    *time.Time           `json:"resolved_at,omitempty"`
+	Reviewer      string               `json:"reviewer,omitempty"`
+	Results       []VerificationResult `json:"results,omitempty"`
+}
+
+type reviewStore struct {
+	mu    sync.Mutex
+	dir   string
+	items map[string]*ReviewItem
+}
+
+var reviewQueue *reviewStore
+
+func newReviewStore(dir string) (*reviewStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &reviewStore{dir: dir, items: map[string]*ReviewItem{}}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, f := range files {
+		raw, err := readSealedFile(f)
+		if err != nil {
+			continue
+		}
+		var item ReviewItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			log.Printf("跳过损坏的复核文件 %s: %v", f, err)
+			continue
+		}
+		s.items[item.ID] = &item
+	}
+	return s, nil
+}
+
+func (s *reviewStore) imagePath(id string) string { return filepath.Join(s.dir, id+".img") }
+
+// save 先写临时文件再改名；调用方需持有锁
+func (s *reviewStore) save(item *ReviewItem) error {
+	raw, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(s.dir, item.ID+".json")
+	if err := writeSealedFile(p+".tmp", raw); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// Add 保存图片并登记一张 PENDING 复核单
+func (s *reviewStore) Add(item *ReviewItem, fileBytes []byte) error {
+	item.ID, item.Status, item.CreatedAt = newJobID(), ReviewPending, time.Now()
+	if err := writeSealedFile(s.imagePath(item.ID), fileBytes); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(item); err != nil {
+		return err
+	}
+	s.items[item.ID] = item
+	return nil
+}
+
+// Get 返回复核单快照
+func (s *reviewStore) Get(id string) (ReviewItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return ReviewItem{}, false
+	}
+	return *item, true
+}
+
+// List 租户的复核单，按创建时间先后；status 为空时返回全部
+func (s *reviewStore) List(tenant, status string) []ReviewItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []ReviewItem
+	for _, item := range s.items {
+		if item.Tenant == tenant && (status == "" || item.Status == status) {
+			out = append(out, *item)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Resolved 全部租户已完成的复核单，按完成时间先后
+func (s *reviewStore) Resolved() []ReviewItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []ReviewItem
+	for _, item := range s.items {
+		if item.Status == ReviewResolved && item.ResolvedAt != nil {
+			out = append(out, *item)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ResolvedAt.Before(*out[j].ResolvedAt) })
+	return out
+}
+
+// SetJob 排队任务转人工时记下任务编号
+func (s *reviewStore) SetJob(id, jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.items[id]; ok {
+		item.JobID = jobID
+		if err := s.save(item); err != nil {
+			log.Printf("保存复核单 %s 失败: %v", id, err)
+		}
+	}
+}
+
+// resolve 标记完成；已经处理过的复核单返回 false
+func (s *reviewStore) resolve(id, reviewer string, results []VerificationResult) (ReviewItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok || item.Status != ReviewPending {
+		return ReviewItem{}, false
+	}
+	now := time.Now()
+	item.Status, item.ResolvedAt, item.Reviewer, item.Results = ReviewResolved, &now, reviewer, results
+	if err := s.save(item); err != nil {
+		log.Printf("保存复核单 %s 失败: %v", id, err)
+	}
+	return *item, true
+}
+
+// reviewListHandler GET /api/v1/reviews?status=PENDING，默认只列待复核
+func reviewListHandler(c *gin.Context) {
+	status := strings.ToUpper(c.DefaultQuery("status", ReviewPending))
+	if status == "ALL" {
+		status = ""
+	}
+	items := reviewQueue.List(tenantOf(c), status)
+	c.JSON(200, gin.H{"count": len(items), "items": items})
+}
+
+// reviewGetHandler GET /api/v1/reviews/:id
+func reviewGetHandler(c *gin.Context) {
+	item, ok := reviewOf(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, item)
+}
+
+// reviewImageHandler GET /api/v1/reviews/:id/image，复核员对照原图确认号码
+func reviewImageHandler(c *gin.Context) {
+	item, ok := reviewOf(c)
+	if !ok {
+		return
+	}
+	raw, err := readSealedFile(reviewQueue.imagePath(item.ID))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "图片已清理"})
+		return
+	}
+	c.Data(200, http.DetectContentType(raw), raw)
+}
+
+func reviewOf(c *gin.Context) (ReviewItem, bool) {
+	id := strings.TrimSpace(c.Param("id"))
+	item, ok := reviewQueue.Get(id)
+	if !ok || item.Tenant != tenantOf(c) {
+		c.JSON(404, gin.H{"error": fmt.Sprintf("复核单 %s 不存在", id)})
+		return ReviewItem{}, false
+	}
+	return item, true
+}
+
+// reviewResolveHandler POST /api/v1/reviews/:id/resolve
+// 请求体 {"accept":"primary|secondary"} 采用某一家的识别结果，或 {"lotteries":[...]} 提交人工更正后的票面；
+// 按确认的号码重新验奖后才发通知、记历史，来自排队任务的同时回写任务结果
+func reviewResolveHandler(c *gin.Context) {
+	item, ok := reviewOf(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Accept    string        `json:"accept"`
+		Lotteries []LotteryData `json:"lotteries"`
+		Reviewer  string        `json:"reviewer"`
+	}
+	if !bindValidJSON(c, reviewResolveSchema, &req) {
+		return
+	}
+	// 经管理接口处理时，未填复核人则记为令牌名称
+	if cred, ok := adminOf(c); ok && req.Reviewer == "" {
+		req.Reviewer = cred.Name
+	}
+	confirmed := req.Lotteries
+	switch {
+	case len(confirmed) > 0:
+	case req.Accept == "primary":
+		confirmed = item.Primary
+	case req.Accept == "secondary" && len(item.Secondary) > 0:
+		confirmed = item.Secondary
+	default:
+		c.JSON(400, gin.H{"error": "请指定 accept（primary / secondary）或提交更正后的 lotteries"})
+		return
+	}
+	if item.Status != ReviewPending {
+		c.JSON(409, gin.H{"error": "该复核单已处理"})
+		return
+	}
+
+	budget := newRequestBudget(c.Request.Context())
+	defer budget.Done()
+	results, err := verifyAll(budget, confirmed)
+	if err != nil {
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	fileBytes, _ := readSealedFile(reviewQueue.imagePath(item.ID))
+	inspectImage(fileBytes).applyAll(results)
+	resolved, ok := reviewQueue.resolve(item.ID, req.Reviewer, results)
+	if !ok {
+		c.JSON(409, gin.H{"error": "该复核单已处理"})
+		return
+	}
+	if resolved.JobID != "" {
+		jobQueue.update(resolved.JobID, func(job *ScanJob) {
+			job.Status, job.Results, job.Error = JobDone, results, ""
+			job.advance(finishStage(results), "人工复核通过")
+		})
+	}
+	onScanCompleted(resolved.ScanOrigin, fileBytes, results)
+	events.Emit(DomainReviewResolved, resolved.Tenant, resolved)
+	os.Remove(reviewQueue.imagePath(item.ID))
+	respondResults(c, results)
+}
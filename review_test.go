@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReviewStore(t *testing.T) {
+	useTestReviews(t)
+	a := &ReviewItem{ScanOrigin: ScanOrigin{Tenant: "shop-a"}, Reason: "号码不一致"}
+	b := &ReviewItem{ScanOrigin: ScanOrigin{Tenant: "shop-a"}, Reason: "第二识别服务失败"}
+	c := &ReviewItem{ScanOrigin: ScanOrigin{Tenant: "shop-b"}}
+	for _, item := range []*ReviewItem{a, b, c} {
+		if err := reviewQueue.Add(item, []byte("img")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := reviewQueue.resolve(b.ID, "alice", nil); !ok {
+		t.Fatal("first resolve failed")
+	}
+	if _, ok := reviewQueue.resolve(b.ID, "bob", nil); ok {
+		t.Error("second resolve should be rejected")
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		status string
+		want   []string
+	}{
+		{"待复核", "shop-a", ReviewPending, []string{a.ID}},
+		{"已完成", "shop-a", ReviewResolved, []string{b.ID}},
+		{"全部按创建先后", "shop-a", "", []string{a.ID, b.ID}},
+		{"其他租户", "shop-b", "", []string{c.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, item := range reviewQueue.List(tt.tenant, tt.status) {
+				got = append(got, item.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("List(%s, %s) = %v, want %v", tt.tenant, tt.status, got, tt.want)
+			}
+		})
+	}
+
+	// 重启后从磁盘恢复，复核人一并保留
+	reloaded, err := newReviewStore(reviewQueue.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reloaded.Get(b.ID); !ok || got.Status != ReviewResolved || got.Reviewer != "alice" {
+		t.Errorf("reloaded = %+v, %v", got, ok)
+	}
+}
+
+func TestReviewResolveHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestReviews(t)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	ticket := func(red ...string) []LotteryData {
+		return []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: red, Blue: []string{"07"}, Multiplier: 1}}}}
+	}
+	newItem := func(t *testing.T, secondary []LotteryData) string {
+		item := &ReviewItem{
+			ScanOrigin: ScanOrigin{Tenant: "shop-a"},
+			Primary:    ticket("02", "11", "15", "21", "28", "33"),
+			Secondary:  secondary,
+		}
+		if err := reviewQueue.Add(item, []byte("img")); err != nil {
+			t.Fatal(err)
+		}
+		return item.ID
+	}
+	resolve := func(id, tenant, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/v1/reviews/"+id+"/resolve", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("X-Tenant-ID", tenant)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		reviewResolveHandler(c)
+		return w
+	}
+
+	corrected := `{"lotteries":[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21","28","01"],"blue":["07"],"multiplier":1}]}],"reviewer":"alice"}`
+	tests := []struct {
+		name       string
+		secondary  []LotteryData
+		tenant     string
+		body       string
+		wantStatus int
+		wantPrize  Fen
+	}{
+		{"采用主识别结果", nil, "shop-a", `{"accept":"primary"}`, 200, 5000000 * Yuan},
+		{"采用第二识别结果", ticket("02", "11", "15", "21", "01", "03"), "shop-a", `{"accept":"secondary"}`, 200, 200 * Yuan},
+		{"提交更正后的号码", nil, "shop-a", corrected, 200, 3000 * Yuan},
+		{"没有第二识别结果", nil, "shop-a", `{"accept":"secondary"}`, 400, 0},
+		{"未指定处理方式", nil, "shop-a", `{}`, 400, 0},
+		{"其他租户", nil, "shop-b", `{"accept":"primary"}`, 404, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newItem(t, tt.secondary)
+			w := resolve(id, tt.tenant, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			item, _ := reviewQueue.Get(id)
+			if w.Code != 200 {
+				if item.Status != ReviewPending {
+					t.Errorf("review status = %s, want %s", item.Status, ReviewPending)
+				}
+				return
+			}
+			var results []VerificationResult
+			json.Unmarshal(w.Body.Bytes(), &results)
+			if len(results) != 1 || results[0].TotalPrize != tt.wantPrize {
+				t.Errorf("results = %s, want prize %s", w.Body.String(), tt.wantPrize)
+			}
+			if item.Status != ReviewResolved || len(item.Results) != 1 {
+				t.Errorf("review = %+v", item)
+			}
+			if w := resolve(id, tt.tenant, tt.body); w.Code != 409 {
+				t.Errorf("resolve again = %d, want 409", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// ROI: 客户端提供的裁剪区域（region of interest）
+// ==========================================
+
+// 客户端在端上已经检测出彩票位置时，可以随图片带上区域，服务端先裁剪再识别，
+// 比识别整张场景照片更快、更省 Token，也更准确。/api/v1/scan 与 /api/v1/ocr 的表单字段或查询参数 roi：
+//
+//	roi=[{"x":120,"y":80,"w":900,"h":1400}]                       像素坐标，原点在左上角
+//	roi=[{"x":0.05,"y":0.1,"w":0.4,"h":0.8},{"x":0.5,...}]        数值都不超过 1 时按图片宽高的比例
+//
+// 一个区域就是只识别这一块；多个区域视为同一张照片里的多张票，分别裁剪识别后按顺序合并。
+// 区域四周各留 ROI_PADDING_PERCENT（默认 3，按区域边长的百分比）的余量，端上检测框偏紧时不会切掉号码。
+// 扫描记录、缩略图、防篡改检查仍然使用原图；区域超出图片或图片无法解码时忽略 roi，按整张图识别
+
+// maxROIBoxes 单张图片最多的区域数
+const maxROIBoxes = 10
+
+// roiBox 一个裁剪区域
+type roiBox struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+type roiKey struct{}
+
+// withROI 在请求上下文里记录裁剪区域，沿 recognizeCached 传递
+func withROI(ctx context.Context, boxes []roiBox) context.Context {
+	if len(boxes) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, roiKey{}, boxes)
+}
+
+func roiFrom(ctx context.Context) []roiBox {
+	boxes, _ := ctx.Value(roiKey{}).([]roiBox)
+	return boxes
+}
+
+// roiOf 读取 ?roi= 或表单字段 roi
+func roiOf(c *gin.Context) ([]roiBox, error) {
+	raw := c.Query("roi")
+	if raw == "" {
+		raw = c.PostForm("roi")
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	var boxes []roiBox
+	if err := json.Unmarshal([]byte(raw), &boxes); err != nil {
+		return nil, fmt.Errorf("roi 格式错误: %v", err)
+	}
+	if len(boxes) > maxROIBoxes {
+		return nil, fmt.Errorf("roi 最多 %d 个区域", maxROIBoxes)
+	}
+	for _, b := range boxes {
+		if b.X < 0 || b.Y < 0 {
+			return nil, fmt.Errorf("roi 区域的 x、y 不能为负")
+		}
+		if b.W <= 0 || b.H <= 0 {
+			return nil, fmt.Errorf("roi 区域的 w、h 必须大于 0")
+		}
+	}
+	return boxes, nil
+}
+
+// rect 换算成像素矩形并加上余量，裁到图片范围内；与图片没有交集时返回空矩形
+func (b roiBox) rect(bounds image.Rectangle, padding float64) image.Rectangle {
+	x, y, w, h := b.X, b.Y, b.W, b.H
+	if x <= 1 && y <= 1 && w <= 1 && h <= 1 {
+		x, y = x*float64(bounds.Dx()), y*float64(bounds.Dy())
+		w, h = w*float64(bounds.Dx()), h*float64(bounds.Dy())
+	}
+	px, py := w*padding, h*padding
+	r := image.Rect(int(x-px), int(y-py), int(x+w+px), int(y+h+py)).Add(bounds.Min)
+	return r.Intersect(bounds)
+}
+
+// cropRegions 按区域裁剪，输出 JPEG
+func cropRegions(fileBytes []byte, boxes []roiBox) ([][]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, err
+	}
+	sub, ok := src.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("不支持裁剪的图片格式")
+	}
+	padding := float64(envInt("ROI_PADDING_PERCENT", 3)) / 100
+	crops := make([][]byte, 0, len(boxes))
+	for i, b := range boxes {
+		r := b.rect(src.Bounds(), padding)
+		if r.Dx() < 16 || r.Dy() < 16 {
+			return nil, fmt.Errorf("第%d个区域超出图片范围或过小", i+1)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, sub.SubImage(r), &jpeg.Options{Quality: 92}); err != nil {
+			return nil, err
+		}
+		crops = append(crops, buf.Bytes())
+	}
+	return crops, nil
+}
+
+// recognizeRegions 逐个区域识别后按顺序合并；任一区域失败即返回错误，避免漏掉一张票
+func recognizeRegions(ctx context.Context, fileBytes []byte, apiKey string, boxes []roiBox) ([]LotteryData, error) {
+	ctx = context.WithValue(ctx, roiKey{}, []roiBox(nil))
+	crops, err := cropRegions(fileBytes, boxes)
+	if err != nil {
+		log.Printf("按 roi 裁剪失败，识别整张图: %v", err)
+		return recognizeCached(ctx, fileBytes, apiKey)
+	}
+	outputs := make([][]LotteryData, len(crops))
+	errs := make([]error, len(crops))
+	var wg sync.WaitGroup
+	for i := range crops {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = recognizeCached(ctx, crops[i], apiKey)
+		}(i)
+	}
+	wg.Wait()
+	var merged []LotteryData
+	for i := range outputs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		merged = append(merged, outputs[i]...)
+	}
+	return merged, nil
+}
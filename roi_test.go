@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestROIOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		query   string
+		form    string
+		want    int
+		wantErr bool
+	}{
+		{"没有 roi", "", "", 0, false},
+		{"查询参数", `[{"x":120,"y":80,"w":900,"h":1400}]`, "", 1, false},
+		{"表单字段", "", `[{"x":0.05,"y":0.1,"w":0.4,"h":0.8},{"x":0.5,"y":0.1,"w":0.4,"h":0.8}]`, 2, false},
+		{"查询参数优先", `[{"x":0,"y":0,"w":1,"h":1}]`, `not json`, 1, false},
+		{"格式错误", `{"x":0}`, "", 0, true},
+		{"区域过多", "[" + strings.TrimSuffix(strings.Repeat(`{"x":0,"y":0,"w":1,"h":1},`, maxROIBoxes+1), ",") + "]", "", 0, true},
+		{"坐标为负", `[{"x":-1,"y":0,"w":1,"h":1}]`, "", 0, true},
+		{"宽高为 0", `[{"x":0,"y":0,"w":0,"h":1}]`, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			if tt.form != "" {
+				mw.WriteField("roi", tt.form)
+			}
+			mw.Close()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			if tt.query != "" {
+				q := c.Request.URL.Query()
+				q.Set("roi", tt.query)
+				c.Request.URL.RawQuery = q.Encode()
+			}
+			boxes, err := roiOf(c)
+			if (err != nil) != tt.wantErr || len(boxes) != tt.want {
+				t.Errorf("roiOf() = %v, %v, want %d boxes (error %v)", boxes, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestROIBoxRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 500)
+	tests := []struct {
+		name    string
+		box     roiBox
+		bounds  image.Rectangle
+		padding float64
+		want    image.Rectangle
+	}{
+		{"像素坐标", roiBox{100, 50, 200, 100}, bounds, 0, image.Rect(100, 50, 300, 150)},
+		{"按比例", roiBox{0.1, 0.2, 0.5, 0.5}, bounds, 0, image.Rect(100, 100, 600, 350)},
+		{"四周留余量", roiBox{100, 50, 200, 100}, bounds, 0.1, image.Rect(80, 40, 320, 160)},
+		{"裁到图片范围内", roiBox{900, 400, 300, 300}, bounds, 0, image.Rect(900, 400, 1000, 500)},
+		{"与图片没有交集", roiBox{2000, 0, 100, 100}, bounds, 0, image.Rectangle{}},
+		{"图片原点不在 0,0", roiBox{10, 10, 20, 20}, image.Rect(5, 5, 105, 105), 0, image.Rect(15, 15, 35, 35)},
+	}
+	for _, tt := range tests {
+		if got := tt.box.rect(tt.bounds, tt.padding); got != tt.want {
+			t.Errorf("%s: rect() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCropRegions(t *testing.T) {
+	t.Setenv("ROI_PADDING_PERCENT", "0")
+	img := testPNG(t, 200, 100)
+	tests := []struct {
+		name    string
+		image   []byte
+		boxes   []roiBox
+		want    []image.Point // 各区域裁剪后的尺寸
+		wantErr bool
+	}{
+		{"单个区域", img, []roiBox{{10, 10, 50, 40}}, []image.Point{{50, 40}}, false},
+		{"多个区域按顺序", img, []roiBox{{0, 0, 0.5, 1}, {100, 0, 100, 30}}, []image.Point{{100, 100}, {100, 30}}, false},
+		{"区域超出图片", img, []roiBox{{10, 10, 50, 40}, {300, 0, 50, 50}}, nil, true},
+		{"区域过小", img, []roiBox{{10, 10, 10, 40}}, nil, true},
+		{"图片无法解码", []byte("not an image"), []roiBox{{10, 10, 50, 40}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crops, err := cropRegions(tt.image, tt.boxes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cropRegions() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(crops) != len(tt.want) {
+				t.Fatalf("got %d crops, want %d", len(crops), len(tt.want))
+			}
+			for i, raw := range crops {
+				cfg, err := jpeg.DecodeConfig(bytes.NewReader(raw))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (image.Point{cfg.Width, cfg.Height}) != tt.want[i] {
+					t.Errorf("crop %d = %dx%d, want %v", i, cfg.Width, cfg.Height, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// useTestROIFixtures mock 识别：两个区域各识别出一张票，整张图识别为 whole
+func useTestROIFixtures(t *testing.T, img []byte, boxes []roiBox) {
+	t.Helper()
+	crops, err := cropRegions(img, boxes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	lottery := func(issue string) []byte {
+		return []byte(`[{"type":"双色球","issue":"` + issue + `","tickets":[{"red":["02","11","15","21","28","33"],"blue":["07"]}]}]`)
+	}
+	writeTestFile(t, dir, imageHash(crops[0])+".json", lottery("1"))
+	writeTestFile(t, dir, imageHash(crops[1])+".json", lottery("2"))
+	writeTestFile(t, dir, imageHash(img)+".json", lottery("whole"))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+}
+
+func TestRecognizeRegions(t *testing.T) {
+	img := testPNG(t, 200, 100)
+	boxes := []roiBox{{0, 0, 100, 100}, {100, 0, 100, 100}}
+	useTestROIFixtures(t, img, boxes)
+
+	tests := []struct {
+		name    string
+		boxes   []roiBox
+		want    string // 各票期号
+		wantErr bool
+	}{
+		{"不带 roi 识别整张图", nil, "whole", false},
+		{"按区域识别后按顺序合并", boxes, "1,2", false},
+		{"区域超出图片时识别整张图", []roiBox{{0, 0, 100, 100}, {500, 0, 100, 100}}, "whole", false},
+		{"任一区域识别失败", []roiBox{boxes[0], {50, 0, 100, 100}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := recognizeCached(withROI(context.Background(), tt.boxes), img, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("recognizeCached() error = %v, want error %v", err, tt.wantErr)
+			}
+			var issues []string
+			for _, l := range data {
+				issues = append(issues, l.Issue)
+			}
+			if got := strings.Join(issues, ","); got != tt.want {
+				t.Errorf("issues = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCROnlyHandlerROI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GEMINI_API_KEY", "test")
+	img := testPNG(t, 200, 100)
+	useTestROIFixtures(t, img, []roiBox{{0, 0, 0.5, 1}, {0.5, 0, 0.5, 1}})
+
+	tests := []struct {
+		name       string
+		roi        string
+		wantStatus int
+		want       string
+	}{
+		{"按比例的两个区域", `[{"x":0,"y":0,"w":0.5,"h":1},{"x":0.5,"y":0,"w":0.5,"h":1}]`, 200, "1,2"},
+		{"不带 roi", "", 200, "whole"},
+		{"roi 格式错误", `[{"x":0,"y":0}]`, 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("image", "ticket.png")
+			fw.Write(img)
+			if tt.roi != "" {
+				mw.WriteField("roi", tt.roi)
+			}
+			mw.Close()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/ocr", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			ocrOnlyHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				ImageHash string        `json:"image_hash"`
+				Lotteries []LotteryData `json:"lotteries"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			var issues []string
+			for _, l := range resp.Lotteries {
+				issues = append(issues, l.Issue)
+			}
+			// 记录里的图片哈希仍是原图
+			if strings.Join(issues, ",") != tt.want || resp.ImageHash != imageHash(img) {
+				t.Errorf("response = %s", w.Body)
+			}
+		})
+	}
+}
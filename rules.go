@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// ==========================================
+// RULES: 可编程奖金规则（data/prize_rules.json）
+// ==========================================
+
+// 派奖活动（promotions.json）只能按奖级加固定金额。更灵活的规则写成表达式（expr 语法），
+// 验奖时对每一注中奖逐条判断，在派奖调整之后生效：
+//
+//	[{"name": "国庆加奖", "game": "双色球",
+//	  "when": "level <= 3 and issue >= \"2025110\" and issue <= \"2025120\"",
+//	  "bonus": "prize * 0.2"}]
+//
+// when 为条件，结果须为 true / false；bonus 为每注追加金额，prize 为替换后的每注奖金，两者任选，单位元。
+// 可用变量：game 彩种、issue 期号、draw_date 开奖日期、level 奖级、prize 当前每注奖金（元，含派奖调整）、
+// bet_type 投注方式（单式 / 复式 / 胆拖）、red / blue 本注号码、add_on 是否追加。
+// 规则启动时编译，写错直接启动失败；运行时出错的规则记日志后跳过
+
+// PrizeRule 一条奖金规则；Game 为空表示所有彩种
+type PrizeRule struct {
+	Name  string `json:"name"`
+	Game  string `json:"game,omitempty"`
+	When  string `json:"when"`
+	Bonus string `json:"bonus,omitempty"`
+	Prize string `json:"prize,omitempty"`
+
+	when, bonus, prize *vm.Program
+}
+
+// ruleEnv 表达式里可用的变量
+type ruleEnv struct {
+	Game     string   `expr:"game"`
+	Issue    string   `expr:"issue"`
+	DrawDate string   `expr:"draw_date"`
+	Level    int      `expr:"level"`
+	Prize    float64  `expr:"prize"`
+	BetType  string   `expr:"bet_type"`
+	Red      []string `expr:"red"`
+	Blue     []string `expr:"blue"`
+	AddOn    bool     `expr:"add_on"`
+}
+
+type ruleStore struct {
+	mu    sync.RWMutex
+	rules []PrizeRule
+}
+
+var prizeRules = &ruleStore{}
+
+// Load 读取并编译规则，文件不存在时不启用
+func (s *ruleStore) Load(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []PrizeRule
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for i := range list {
+		if err := list[i].compile(); err != nil {
+			return fmt.Errorf("规则 %q: %v", list[i].Name, err)
+		}
+	}
+	s.mu.Lock()
+	s.rules = list
+	s.mu.Unlock()
+	return nil
+}
+
+func (r *PrizeRule) compile() error {
+	if r.When == "" {
+		return fmt.Errorf("缺少 when")
+	}
+	if (r.Bonus == "") == (r.Prize == "") {
+		return fmt.Errorf("bonus 与 prize 须且只能设置一个")
+	}
+	r.Game = canonicalGame(r.Game)
+	var err error
+	if r.when, err = expr.Compile(r.When, expr.Env(ruleEnv{}), expr.AsBool()); err != nil {
+		return fmt.Errorf("when: %v", err)
+	}
+	if r.Bonus != "" {
+		if r.bonus, err = expr.Compile(r.Bonus, expr.Env(ruleEnv{}), expr.AsFloat64()); err != nil {
+			return fmt.Errorf("bonus: %v", err)
+		}
+	}
+	if r.Prize != "" {
+		if r.prize, err = expr.Compile(r.Prize, expr.Env(ruleEnv{}), expr.AsFloat64()); err != nil {
+			return fmt.Errorf("prize: %v", err)
+		}
+	}
+	return nil
+}
+
+// Apply 对一注中奖依次套用规则，返回调整后的金额和生效的规则名称
+func (s *ruleStore) Apply(win WinningNumbers, t UserTicket, level int, money Fen) (Fen, []string) {
+	s.mu.RLock()
+	rules := s.rules
+	s.mu.RUnlock()
+	if len(rules) == 0 || level == 0 {
+		return money, nil
+	}
+	game := canonicalGame(win.Game)
+	env := ruleEnv{
+		Game: game, Issue: win.Issue, DrawDate: win.DrawDate, Level: level,
+		BetType: betTypeOf(win.Game, t), Red: t.Red, Blue: t.Blue, AddOn: t.AddOn,
+	}
+	var names []string
+	for _, r := range rules {
+		if r.Game != "" && r.Game != game {
+			continue
+		}
+		env.Prize = float64(money) / float64(Yuan)
+		ok, err := expr.Run(r.when, env)
+		if err != nil {
+			log.Printf("奖金规则 %s 执行失败: %v", r.Name, err)
+			continue
+		}
+		if !ok.(bool) {
+			continue
+		}
+		prog := r.bonus
+		if prog == nil {
+			prog = r.prize
+		}
+		v, err := expr.Run(prog, env)
+		if err != nil {
+			log.Printf("奖金规则 %s 执行失败: %v", r.Name, err)
+			continue
+		}
+		amount := Fen(math.Round(v.(float64) * float64(Yuan)))
+		if r.bonus != nil {
+			money += amount
+		} else {
+			money = amount
+		}
+		money = max(money, 0)
+		names = append(names, r.Name)
+	}
+	return money, names
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPrizeRules = `[
+  {"name": "国庆加奖", "game": "ssq", "when": "level <= 4 and issue >= \"2025110\" and issue <= \"2025120\"", "bonus": "prize * 0.2"},
+  {"name": "复式六等奖翻倍", "game": "双色球", "when": "level == 6 and bet_type == \"复式\"", "prize": "prize * 2"},
+  {"name": "大乐透追加补贴", "game": "大乐透", "when": "add_on", "bonus": "1"},
+  {"name": "蓝球 16 扣减", "when": "len(blue) > 0 and blue[0] == \"16\"", "bonus": "-1000"}
+]`
+
+// useTestPrizeRules 换成 raw 里的规则，测试结束后恢复
+func useTestPrizeRules(t *testing.T, raw string) {
+	t.Helper()
+	old := prizeRules
+	t.Cleanup(func() { prizeRules = old })
+	prizeRules = &ruleStore{}
+	if err := prizeRules.Load(writeTestFile(t, t.TempDir(), "prize_rules.json", []byte(raw))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuleStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"正常配置", testPrizeRules, ""},
+		{"缺少 when", `[{"name": "x", "bonus": "1"}]`, "缺少 when"},
+		{"bonus 与 prize 都没有", `[{"name": "x", "when": "true"}]`, "只能设置一个"},
+		{"bonus 与 prize 同时设置", `[{"name": "x", "when": "true", "bonus": "1", "prize": "2"}]`, "只能设置一个"},
+		{"when 不是布尔", `[{"name": "x", "when": "level + 1", "bonus": "1"}]`, "when"},
+		{"未知变量", `[{"name": "x", "when": "colour == \"red\"", "bonus": "1"}]`, "when"},
+		{"bonus 语法错误", `[{"name": "x", "when": "true", "bonus": "prize *"}]`, "bonus"},
+		{"JSON 格式错误", `[{`, "unexpected"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, dir, string(rune('a'+i))+".json", []byte(tt.file))
+			err := (&ruleStore{}).Load(path)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	s := &ruleStore{}
+	if err := s.Load(filepath.Join(dir, "missing.json")); err != nil || len(s.rules) != 0 {
+		t.Errorf("missing file: %v, %d rules", err, len(s.rules))
+	}
+}
+
+func TestRuleStoreApply(t *testing.T) {
+	useTestPrizeRules(t, testPrizeRules)
+	ssq := WinningNumbers{Game: "双色球", Issue: "2025115", DrawDate: "2025-10-02"}
+	outside := WinningNumbers{Game: "双色球", Issue: "2025100"}
+	dlt := WinningNumbers{Game: "大乐透", Issue: "25115"}
+	single := UserTicket{Red: []string{"01", "02", "03", "04", "05", "06"}, Blue: []string{"07"}}
+	multiple := UserTicket{Red: []string{"01", "02", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}
+
+	tests := []struct {
+		name      string
+		win       WinningNumbers
+		ticket    UserTicket
+		level     int
+		money     Fen
+		want      Fen
+		wantNames string
+	}{
+		{"期号范围内按比例加奖", ssq, single, 4, 200 * Yuan, 240 * Yuan, "国庆加奖"},
+		{"期号范围外不加", outside, single, 4, 200 * Yuan, 200 * Yuan, ""},
+		{"奖级不满足不加", ssq, single, 5, 10 * Yuan, 10 * Yuan, ""},
+		{"prize 替换奖金", outside, multiple, 6, 5 * Yuan, 10 * Yuan, "复式六等奖翻倍"},
+		{"单式不替换", outside, single, 6, 5 * Yuan, 5 * Yuan, ""},
+		{"其他彩种的规则不生效", dlt, UserTicket{Red: []string{"01"}, Blue: []string{"02"}}, 4, 200 * Yuan, 200 * Yuan, ""},
+		{"追加", dlt, UserTicket{Red: []string{"01"}, Blue: []string{"02"}, AddOn: true}, 9, 5 * Yuan, 6 * Yuan, "大乐透追加补贴"},
+		{"扣减后不低于 0", outside, UserTicket{Red: single.Red, Blue: []string{"16"}}, 6, 5 * Yuan, 0, "蓝球 16 扣减"},
+		{"多条规则依次套用", ssq, UserTicket{Red: single.Red, Blue: []string{"16"}}, 1, 5000000 * Yuan, 5000000*Yuan*6/5 - 1000*Yuan, "国庆加奖,蓝球 16 扣减"},
+		{"未中奖不套用", ssq, single, 0, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			money, names := prizeRules.Apply(tt.win, tt.ticket, tt.level, tt.money)
+			if money != tt.want || strings.Join(names, ",") != tt.wantNames {
+				t.Errorf("Apply() = %s %v, want %s %s", money, names, tt.want, tt.wantNames)
+			}
+		})
+	}
+}
+
+// 规则在派奖调整之后生效，命中的规则名称写进验奖状态
+func TestPrizeRulesVerify(t *testing.T) {
+	useTestPrizeRules(t, testPrizeRules)
+	win := DrawRecord{Game: "双色球", Issue: "2025115", DrawDate: "2025-10-02", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}.winning()
+	tests := []struct {
+		name   string
+		ticket UserTicket
+		money  Fen
+		status string
+	}{
+		{"四等奖加奖", UserTicket{Red: []string{"02", "11", "15", "21", "01", "03"}, Blue: []string{"07"}}, 240 * Yuan, "国庆加奖"},
+		{"六等奖不满足条件", UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}, 5 * Yuan, "中奖: 5元"},
+		{"未中奖", UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"09"}}, 0, "未中奖"},
+	}
+	v := &DoubleColorVerifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, money, status := v.Verify(tt.ticket, win)
+			if money != tt.money || !strings.Contains(status, tt.status) {
+				t.Errorf("Verify() = %s %q, want %s %q", money, status, tt.money, tt.status)
+			}
+		})
+	}
+}
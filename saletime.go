@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// SALETIME: 销售时间与停售时间校验
+// ==========================================
+
+// 票面销售时间常见的几种写法
+var saleTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"06-01-02 15:04:05",
+	"2006年01月02日 15:04:05",
+}
+
+// parseSaleTime 按北京时间解析票面销售时间
+func parseSaleTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range saleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, chinaTime); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// strictSaleTime SALE_TIME_STRICT=true 时校验不通过的票直接拒绝验奖，默认只提示
+func strictSaleTime() bool {
+	return envBool("SALE_TIME_STRICT", false)
+}
+
+// checkSaleTime 比对票面销售时间与期号：停售后售出、或期号与销售时间对应的期号不一致，
+// 通常是 OCR 识别错了期号/时间，少数情况是图片被篡改
+func checkSaleTime(lottery LotteryData, now time.Time) []string {
+	if lottery.SaleTime == "" {
+		return nil
+	}
+	sold, ok := parseSaleTime(lottery.SaleTime)
+	if !ok {
+		return nil
+	}
+	var warnings []string
+	if sold.After(now.Add(10 * time.Minute)) {
+		warnings = append(warnings, fmt.Sprintf("销售时间 %s 晚于当前时间", lottery.SaleTime))
+	}
+
+	onSale, ok := calendar.OnSaleAt(lottery.Type, sold)
+	issue := strings.TrimSpace(lottery.Issue)
+	if !ok || onSale.Issue == "" || issue == "" || len(issue) != len(onSale.Issue) {
+		return warnings
+	}
+	switch {
+	case issue < onSale.Issue:
+		warnings = append(warnings, fmt.Sprintf("销售时间 %s 晚于第%s期停售时间", lottery.SaleTime, issue))
+	case issue > onSale.Issue:
+		warnings = append(warnings, fmt.Sprintf("票面期号 %s 与销售时间对应的第%s期不一致", issue, onSale.Issue))
+	}
+	return warnings
+}
+
+// rejectedResult 校验未通过时的结果，不给出中奖金额
+func rejectedResult(idx int, lottery LotteryData, warnings []string) VerificationResult {
+	res := VerificationResult{
+		TicketIndex: idx + 1,
+		OCRData:     lottery,
+		Details:     []ResultDetail{},
+		Warnings:    warnings,
+		Rejected:    true,
+	}
+	for rowIdx := range lottery.Tickets {
+		res.Details = append(res.Details, ResultDetail{RowIndex: rowIdx + 1, Status: "票据校验未通过"})
+	}
+	return res
+}
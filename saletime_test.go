@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSaleTime(t *testing.T) {
+	want := time.Date(2025, 9, 16, 18, 30, 5, 0, chinaTime)
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"2025-09-16 18:30:05", true},
+		{"2025/09/16 18:30:05", true},
+		{"25-09-16 18:30:05", true},
+		{"2025年09月16日 18:30:05", true},
+		{" 2025-09-16 18:30:05 ", true},
+		{"2025-09-16", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSaleTime(tt.in)
+		if ok != tt.ok || (ok && !got.Equal(want)) {
+			t.Errorf("parseSaleTime(%q) = %v, %v, want %v", tt.in, got, ok, tt.ok)
+		}
+	}
+}
+
+func TestCheckSaleTime(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025106", DrawDate: "2025-09-14"})
+	now := time.Date(2025, 9, 16, 23, 0, 0, 0, chinaTime)
+	tests := []struct {
+		name     string
+		issue    string
+		saleTime string
+		want     []string
+	}{
+		{"停售前售出", "2025107", "2025-09-16 10:00:00", nil},
+		{"停售后售出的是下一期", "2025107", "2025-09-16 20:30:00", []string{"晚于第2025107期停售时间"}},
+		{"期号比销售时间对应的期号大", "2025108", "2025-09-15 10:00:00", []string{"与销售时间对应的第2025107期不一致"}},
+		{"销售时间在未来", "2025108", "2025-09-17 10:00:00", []string{"晚于当前时间"}},
+		{"没有销售时间", "2025107", "", nil},
+		{"销售时间无法解析", "2025107", "昨天", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkSaleTime(LotteryData{Type: "双色球", Issue: tt.issue, SaleTime: tt.saleTime}, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("warnings = %v, want %v", got, tt.want)
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("warning %q, want containing %q", got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyLotterySaleTimeStrict(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025106", DrawDate: "2025-09-14"})
+	lottery := LotteryData{Type: "双色球", Issue: "2025107", SaleTime: "2025-09-16 20:30:00", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	tests := []struct {
+		name         string
+		strict       string
+		wantRejected bool
+	}{
+		{"默认只提示", "", false},
+		{"严格模式拒绝验奖", "true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SALE_TIME_STRICT", tt.strict)
+			b := newRequestBudget(context.Background())
+			res, err := verifyLottery(b, 0, lottery)
+			b.Done()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Rejected != tt.wantRejected || len(res.Warnings) == 0 {
+				t.Errorf("rejected = %v warnings = %v, want rejected %v with warnings", res.Rejected, res.Warnings, tt.wantRejected)
+			}
+			if tt.wantRejected && res.TotalPrize != 0 {
+				t.Errorf("rejected ticket has prize %s", res.TotalPrize)
+			}
+			if !tt.wantRejected && res.TotalPrize == 0 {
+				t.Error("ticket not verified in lenient mode")
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SCREENSHOT: 电子订单 / App 下单截图识别
+// ==========================================
+
+// 线上购彩 App 的订单详情截图与纸质票版式完全不同：号码按“红球 / 蓝球”分列排布，
+// 页面上还有状态栏、开奖公告、推荐号码等干扰，用纸票提示词识别常常把开奖号码当成投注号码。
+// 截图走单独的提示词；调用方可以用 ?source=（或表单字段 source）指定 ticket / screenshot，
+// 默认 auto：竖屏 PNG、或没有 EXIF 的手机竖屏尺寸 JPEG 视为截图
+
+const (
+	imageSourceAuto       = "auto"
+	imageSourceTicket     = "ticket"
+	imageSourceScreenshot = "screenshot"
+)
+
+// ocrScreenshotPrompt 截图识别提示词，输出结构与 ocrPrompt 相同
+const ocrScreenshotPrompt = `
+	你是一个专业OCR助手。这是一张彩票 App / 电子订单的手机截图，不是纸质彩票。
+	请识别截图中**用户购买**的所有订单，返回一个JSON数组（Array），每个元素代表一个订单。
+	字段说明：
+	- type: 彩种名称 (例如 "双色球")
+	- issue: 期号 (例如 "2025107")，截图上常写作“第2025107期”
+	- sale_time: 下单时间 / 投注时间，格式 "2006-01-02 15:04:05"，没有则留空
+	- serial: 订单号 / 方案编号，没有则留空
+	- amount: 订单金额 / 投注金额（元，只填数字），没有则留空
+	- station: 出票站点编号，没有则留空
+	- tickets: 投注内容里的每一注号码；“红球”“前区”放 red，“蓝球”“后区”放 blue；
+	  倍数写在订单信息里时填到每一注的 multiplier；标注“追加”时 add_on 为 true；
+	  mode 为投注方式（单式/复式/胆拖），胆拖的胆码放 dan、拖码放 red，大乐透后区胆码放 blue_dan
+
+	【重要】：
+	只识别用户自己的投注号码。页面上的“开奖号码”“开奖公告”“上期开奖”“推荐号码”“热门号码”、
+	广告、按钮、手机状态栏时间和电量都不是投注内容，不要输出。
+	订单状态（已出票、待开奖、未中奖、已中奖）不要写进任何字段。
+	号码请尽量输出为字符串(例如 "01")。
+	`
+
+// phoneScreenWidths 常见手机截图宽度（像素）
+var phoneScreenWidths = map[int]bool{
+	640: true, 720: true, 750: true, 828: true, 1080: true, 1125: true, 1170: true,
+	1179: true, 1242: true, 1284: true, 1290: true, 1440: true,
+}
+
+type imageSourceKey struct{}
+
+// withImageSource 在请求上下文里记录调用方指定的图片来源，沿 recognizeCached 传递；auto 不记录
+func withImageSource(ctx context.Context, source string) context.Context {
+	if source == "" || source == imageSourceAuto {
+		return ctx
+	}
+	return context.WithValue(ctx, imageSourceKey{}, source)
+}
+
+// imageSourceFrom 调用方指定的来源优先，否则按图片自动判断
+func imageSourceFrom(ctx context.Context, fileBytes []byte) string {
+	if s, ok := ctx.Value(imageSourceKey{}).(string); ok {
+		return s
+	}
+	return detectImageSource(fileBytes)
+}
+
+// imageSourceOf 读取 ?source= 或表单字段 source
+func imageSourceOf(c *gin.Context) (string, error) {
+	source := c.Query("source")
+	if source == "" {
+		source = c.PostForm("source")
+	}
+	switch source {
+	case "", imageSourceAuto:
+		return imageSourceAuto, nil
+	case imageSourceTicket, imageSourceScreenshot:
+		return source, nil
+	}
+	return "", fmt.Errorf("source 只能是 ticket、screenshot 或 auto")
+}
+
+// detectImageSource 按格式和尺寸粗判：相机照片几乎都是带 EXIF 的 JPEG，截图多为 PNG 或手机屏宽的竖图
+func detectImageSource(fileBytes []byte) string {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(fileBytes))
+	if err != nil || cfg.Width == 0 {
+		return imageSourceTicket
+	}
+	ratio := float64(cfg.Height) / float64(cfg.Width)
+	switch {
+	case format == "png" && ratio >= 1.5:
+		return imageSourceScreenshot
+	case format == "jpeg" && ratio >= 1.9 && phoneScreenWidths[cfg.Width] && !hasEXIF(fileBytes):
+		return imageSourceScreenshot
+	}
+	return imageSourceTicket
+}
+
+// hasEXIF JPEG 头部是否有 APP1 Exif 段
+func hasEXIF(fileBytes []byte) bool {
+	head := fileBytes[:min(len(fileBytes), 64<<10)]
+	return bytes.Contains(head, []byte("Exif\x00\x00"))
+}
+
+// ocrPromptFor 按图片来源选择提示词
+func ocrPromptFor(source string) string {
+	if source == imageSourceScreenshot {
+		return ocrScreenshotPrompt
+	}
+	return ocrPrompt
+}
+
+// ocrCacheSuffix 指定来源与自动判断结果不同会得到不同的识别结果，缓存键要区分
+func ocrCacheSuffix(ctx context.Context, fileBytes []byte) string {
+	if imageSourceFrom(ctx, fileBytes) == imageSourceScreenshot {
+		return "#screenshot"
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectImageSource(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"竖屏 PNG", testPNG(t, 60, 120), imageSourceScreenshot},
+		{"横屏 PNG", testPNG(t, 120, 60), imageSourceTicket},
+		{"手机屏宽的竖图 JPEG", testJPEG(t, 720, 1560), imageSourceScreenshot},
+		{"非手机屏宽", testJPEG(t, 700, 1560), imageSourceTicket},
+		{"不够长", testJPEG(t, 720, 1280), imageSourceTicket},
+		{"带 EXIF 的照片", jpegWithExif(t, image.NewGray(image.Rect(0, 0, 720, 1560)), "", "", ""), imageSourceTicket},
+		{"无法解码", []byte("not an image"), imageSourceTicket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectImageSource(tt.data); got != tt.want {
+				t.Errorf("detectImageSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageSourceOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		query   string
+		form    string
+		want    string
+		wantErr bool
+	}{
+		{"默认自动判断", "", "", imageSourceAuto, false},
+		{"参数指定", "?source=screenshot", "", imageSourceScreenshot, false},
+		{"表单字段", "", "ticket", imageSourceTicket, false},
+		{"参数优先", "?source=ticket", "screenshot", imageSourceTicket, false},
+		{"不支持的来源", "?source=photo", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, strings.NewReader(url.Values{"source": {tt.form}}.Encode()))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			got, err := imageSourceOf(c)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("imageSourceOf() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// 指定来源优先于自动判断，并决定提示词和缓存键
+func TestImageSourceFrom(t *testing.T) {
+	portrait := testPNG(t, 60, 120)
+	tests := []struct {
+		name       string
+		source     string
+		data       []byte
+		want       string
+		wantSuffix string
+	}{
+		{"自动判断为截图", imageSourceAuto, portrait, imageSourceScreenshot, "#screenshot"},
+		{"指定为纸票", imageSourceTicket, portrait, imageSourceTicket, ""},
+		{"指定为截图", imageSourceScreenshot, []byte("x"), imageSourceScreenshot, "#screenshot"},
+		{"未指定", "", []byte("x"), imageSourceTicket, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withImageSource(context.Background(), tt.source)
+			got := imageSourceFrom(ctx, tt.data)
+			if got != tt.want {
+				t.Errorf("imageSourceFrom() = %q, want %q", got, tt.want)
+			}
+			if suffix := ocrCacheSuffix(ctx, tt.data); suffix != tt.wantSuffix {
+				t.Errorf("ocrCacheSuffix() = %q, want %q", suffix, tt.wantSuffix)
+			}
+			if wantPrompt := tt.want == imageSourceScreenshot; (ocrPromptFor(got) == ocrScreenshotPrompt) != wantPrompt {
+				t.Errorf("ocrPromptFor(%q) picked the wrong prompt", got)
+			}
+		})
+	}
+}
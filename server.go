@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"google.golang.org/genai"
@@ -19,15 +22,31 @@ import (
 // ==========================================
 const GEMINI_MODEL = "gemini-2.5-flash"
 
+// errOCRProvider 标记 AI 服务本身的调用失败（网络/配额/服务端错误），
+// 与图片无法识别区分开，只有这类错误才会计入熔断器
+var errOCRProvider = errors.New("API调用错误")
+
+// serverStartedAt 进程启动时间
+var serverStartedAt = time.Now()
+
 // ==========================================
 // 1. 数据结构定义 (Data Models)
 // ==========================================
 
 // 标准结构体（逻辑层使用，保持严格 String）
 type LotteryData struct {
-	Type    string       `json:"type"`
-	Issue   string       `json:"issue"`
-	Tickets []UserTicket `json:"tickets"`
+	Type     string `json:"type"`
+	Issue    string `json:"issue"`
+	SaleTime string `json:"sale_time,omitempty"` // 票面销售时间 2025-09-16 18:30:05
+	Serial   string `json:"serial,omitempty"`    // 票面序列号，同一张票重复扫描时用来比对
+	Station  string `json:"station,omitempty"`   // 票面底部打印的销售站点编号
+	Amount   int64  `json:"amount,omitempty"`    // 票面印刷的投注金额（元），用来核对倍数
+	// 高精度模式下多次识别结果不一致、没有形成多数的字段，如 tickets[2].red
+	Uncertain []string     `json:"uncertain,omitempty"`
+	Enhanced  bool         `json:"enhanced,omitempty"` // 票面褪色，结果来自增强后的图片重新识别，见 thermal.go
+	Tickets   []UserTicket `json:"tickets"`
+	// 同一张票上的附加玩法（如七星彩票面附带的生肖乐），按各自彩种验奖
+	Sections []LotteryData `json:"sections,omitempty"`
 }
 
 type UserTicket struct {
@@ -35,38 +54,80 @@ type UserTicket struct {
 	Blue       []string `json:"blue"`
 	Multiplier int      `json:"multiplier"`
 	Mode       string   `json:"mode"`
+	Dan        []string `json:"dan,omitempty"`         // 胆拖投注的红球/前区胆码，此时 Red 为拖码
+	BlueDan    []string `json:"blue_dan,omitempty"`    // 大乐透后区胆码，此时 Blue 为拖码
+	AddOn      bool     `json:"add_on,omitempty"`      // 大乐透追加投注
+	PickMethod string   `json:"pick_method,omitempty"` // 票面标注的机选/自选
 }
 
 // ★★★ 新增：临时结构体，用于宽松解析 JSON (Middleware Struct) ★★★
 // 这里的 Red/Blue 使用 []interface{}，既能接数字，也能接字符串
 type RawLotteryData struct {
-	Type    string `json:"type"`
-	Issue   string `json:"issue"`
-	Tickets []struct {
+	Type     string      `json:"type"`
+	Issue    string      `json:"issue"`
+	SaleTime string      `json:"sale_time"`
+	Serial   string      `json:"serial"`
+	Station  interface{} `json:"station"` // 站点编号模型常输出为数字
+	Amount   interface{} `json:"amount"`
+	Tickets  []struct {
 		Red        []interface{} `json:"red"`  // 容错关键点
 		Blue       []interface{} `json:"blue"` // 容错关键点
 		Multiplier int           `json:"multiplier"`
 		Mode       string        `json:"mode"`
+		AddOn      bool          `json:"add_on"`
+		PickMethod string        `json:"pick_method"`
+		Dan        []interface{} `json:"dan"`
+		BlueDan    []interface{} `json:"blue_dan"`
 	} `json:"tickets"`
+	Sections []RawLotteryData `json:"sections"`
 }
 
 type VerificationResult struct {
 	TicketIndex int            `json:"ticket_index"`
 	OCRData     LotteryData    `json:"ocr_data"`
-	TotalPrize  int64          `json:"total_prize"`
+	TotalPrize  Fen            `json:"total_prize_fen"`
+	Currency    string         `json:"currency,omitempty"` // en 模式下金额的币种
 	Details     []ResultDetail `json:"details"`
+	Cached      bool           `json:"cached,omitempty"`
+	Pending     bool           `json:"pending,omitempty"` // 该期尚未开奖
+	Warnings    []string       `json:"warnings,omitempty"`
+	Rejected    bool           `json:"rejected,omitempty"`     // 票据校验未通过，未做验奖
+	Error       string         `json:"error,omitempty"`        // 批量处理时该票验奖失败，同一图片的其余票不受影响
+	Claim       *ClaimGuide    `json:"claim,omitempty"`        // 中奖时的兑奖指引
+	DuplicateOf string         `json:"duplicate_of,omitempty"` // 重复拍摄时关联的原扫描记录
+	Rescan      *RescanDiff    `json:"rescan,omitempty"`       // 同一张票此前扫描过时，与上次识别结果的差异
+	// 附加玩法各自的验奖结果，奖金已计入 total_prize_fen
+	Sections []VerificationResult `json:"sections,omitempty"`
+	// 按调用方在请求里提供的开奖号码验奖时，回显所用的号码
+	SuppliedDraw *DrawRecord `json:"supplied_draw,omitempty"`
 }
 
 type ResultDetail struct {
 	RowIndex int    `json:"row_index"`
 	Level    int    `json:"level"`
-	Prize    int64  `json:"prize"`
+	Prize    Fen    `json:"prize_fen"`
 	Status   string `json:"status"`
+	// 浮动奖级的官方单注奖金尚未公布，Prize 按估算值计算
+	Estimated  bool   `json:"estimated,omitempty"`
+	BetType    string `json:"bet_type,omitempty"`    // 单式 / 复式 / 胆拖，按号码结构判断
+	PickMethod string `json:"pick_method,omitempty"` // 机选 / 自选，票面未标注时为空
+	// ?detail=full 时复式/胆拖行的逐注明细
+	Breakdown *BetBreakdown `json:"breakdown,omitempty"`
+	// 已开奖行的验奖说明：命中号码、命中规则、奖金算式和税费
+	Explanation *RowExplanation `json:"explanation,omitempty"`
 }
 
 type WinningNumbers struct {
 	Red  []string
 	Blue []string
+	// 派奖活动期间的开奖附带调整规则，由 lookupWinningNumbers 按开奖日期填入
+	Game       string
+	Issue      string
+	DrawDate   string
+	Promotions []PrizePromotion
+	// 浮动奖级与追加的每注实际奖金，来自开奖数据；未公布时为空，按估算值计
+	Prizes      map[int]Fen
+	AddOnPrizes map[int]Fen
 }
 
 // ==========================================
@@ -103,16 +164,29 @@ func combinations(iterable []string, r int) [][]string {
 	return append(result, combinations(tail, r)...)
 }
 
+// zoneCombinations 展开一个号码区的所有单式组合：胆码必选，其余从拖码中补足
+func zoneCombinations(dan, tuo []string, r int) [][]string {
+	if len(dan) > r {
+		return nil
+	}
+	var result [][]string
+	for _, comb := range combinations(tuo, r-len(dan)) {
+		result = append(result, append(append([]string{}, dan...), comb...))
+	}
+	return result
+}
+
 type Verifier interface {
-	Verify(t UserTicket, win WinningNumbers) (int, int64, string)
+	Verify(t UserTicket, win WinningNumbers) (int, Fen, string)
 }
 
 // --- A. 双色球验奖器 ---
 type DoubleColorVerifier struct{}
 
-func (v *DoubleColorVerifier) Verify(t UserTicket, win WinningNumbers) (int, int64, string) {
-	redCombs := combinations(t.Red, 6)
-	bestLevel, totalMoney := 0, int64(0)
+func (v *DoubleColorVerifier) Verify(t UserTicket, win WinningNumbers) (int, Fen, string) {
+	redCombs := zoneCombinations(t.Dan, t.Red, 6)
+	bestLevel, totalMoney := 0, Fen(0)
+	promoNames := ""
 
 	for _, redComb := range redCombs {
 		for _, b := range t.Blue {
@@ -122,26 +196,30 @@ func (v *DoubleColorVerifier) Verify(t UserTicket, win WinningNumbers) (int, int
 				blueHits = 1
 			}
 
-			level, money := 0, int64(0)
+			level, money := 0, Fen(0)
 			if redHits == 6 && blueHits == 1 {
-				level, money = 1, 5000000
+				level, money = 1, 5000000*Yuan
 			} else if redHits == 6 && blueHits == 0 {
-				level, money = 2, 100000
+				level, money = 2, 100000*Yuan
 			} else if redHits == 5 && blueHits == 1 {
-				level, money = 3, 3000
+				level, money = 3, 3000*Yuan
 			} else if redHits == 5 && blueHits == 0 {
-				level, money = 4, 200
+				level, money = 4, 200*Yuan
 			} else if redHits == 4 && blueHits == 1 {
-				level, money = 4, 200
+				level, money = 4, 200*Yuan
 			} else if redHits == 4 && blueHits == 0 {
-				level, money = 5, 10
+				level, money = 5, 10*Yuan
 			} else if redHits == 3 && blueHits == 1 {
-				level, money = 5, 10
+				level, money = 5, 10*Yuan
 			} else if blueHits == 1 {
-				level, money = 6, 5
+				level, money = 6, 5*Yuan
 			}
 
 			if money > 0 {
+				var promo string
+				if money, promo = win.promoPrize(t, level, money); promo != "" {
+					promoNames = promo
+				}
 				totalMoney += money
 				if bestLevel == 0 || level < bestLevel {
 					bestLevel = level
@@ -151,7 +229,7 @@ func (v *DoubleColorVerifier) Verify(t UserTicket, win WinningNumbers) (int, int
 	}
 	status := "未中奖"
 	if totalMoney > 0 {
-		status = fmt.Sprintf("中奖: %d元", totalMoney)
+		status = "中奖: " + totalMoney.String() + promoSuffix(promoNames)
 	}
 	return bestLevel, totalMoney, status
 }
@@ -159,42 +237,52 @@ func (v *DoubleColorVerifier) Verify(t UserTicket, win WinningNumbers) (int, int
 // --- B. 大乐透验奖器 ---
 type LottoVerifier struct{}
 
-func (v *LottoVerifier) Verify(t UserTicket, win WinningNumbers) (int, int64, string) {
+func (v *LottoVerifier) Verify(t UserTicket, win WinningNumbers) (int, Fen, string) {
 	redHits := intersect(t.Red, win.Red)
 	blueHits := intersect(t.Blue, win.Blue)
-	level, money := 0, int64(0)
+	level, money := 0, Fen(0)
 
 	if redHits == 5 && blueHits == 2 {
-		level, money = 1, 10000000
+		level, money = 1, 10000000*Yuan
 	} else if redHits == 5 && blueHits == 1 {
-		level, money = 2, 200000
+		level, money = 2, 200000*Yuan
 	} else if redHits == 5 && blueHits == 0 {
-		level, money = 3, 10000
+		level, money = 3, 10000*Yuan
 	} else if redHits == 4 && blueHits == 2 {
-		level, money = 4, 3000
+		level, money = 4, 3000*Yuan
 	} else if redHits == 4 && blueHits == 1 {
-		level, money = 5, 300
+		level, money = 5, 300*Yuan
 	} else if redHits == 3 && blueHits == 2 {
-		level, money = 6, 200
+		level, money = 6, 200*Yuan
 	} else if redHits == 4 && blueHits == 0 {
-		level, money = 7, 100
+		level, money = 7, 100*Yuan
 	} else if redHits == 3 && blueHits == 1 {
-		level, money = 8, 15
+		level, money = 8, 15*Yuan
 	} else if redHits == 2 && blueHits == 2 {
-		level, money = 8, 15
+		level, money = 8, 15*Yuan
 	} else if redHits == 3 && blueHits == 0 {
-		level, money = 9, 5
+		level, money = 9, 5*Yuan
 	} else if redHits == 2 && blueHits == 1 {
-		level, money = 9, 5
+		level, money = 9, 5*Yuan
 	} else if redHits == 1 && blueHits == 2 {
-		level, money = 9, 5
+		level, money = 9, 5*Yuan
 	} else if redHits == 0 && blueHits == 2 {
-		level, money = 9, 5
+		level, money = 9, 5*Yuan
 	}
 
+	money, estimated := win.floatingPrize(level, money)
+	if t.AddOn {
+		addOn, addOnEstimated := win.addOnPrize(level, money)
+		money += addOn
+		estimated = estimated || addOnEstimated
+	}
+	money, promo := win.promoPrize(t, level, money)
 	status := "未中奖"
 	if money > 0 {
-		status = fmt.Sprintf("中奖: %d元", money)
+		status = "中奖: " + money.String() + promoSuffix(promo)
+		if estimated {
+			status += "（浮动奖金为估算，以官方公布为准）"
+		}
 	}
 	return level, money, status
 }
@@ -202,7 +290,7 @@ func (v *LottoVerifier) Verify(t UserTicket, win WinningNumbers) (int, int64, st
 // --- C. 排列5验奖器 ---
 type Permutation5Verifier struct{}
 
-func (v *Permutation5Verifier) Verify(t UserTicket, win WinningNumbers) (int, int64, string) {
+func (v *Permutation5Verifier) Verify(t UserTicket, win WinningNumbers) (int, Fen, string) {
 	match := true
 	if len(t.Red) != 5 || len(win.Red) != 5 {
 		match = false
@@ -215,7 +303,8 @@ func (v *Permutation5Verifier) Verify(t UserTicket, win WinningNumbers) (int, in
 		}
 	}
 	if match {
-		return 1, 100000, "一等奖"
+		money, promo := win.promoPrize(t, 1, 100000*Yuan)
+		return 1, money, "一等奖" + promoSuffix(promo)
 	}
 	return 0, 0, "未中奖"
 }
@@ -242,8 +331,37 @@ func anyToString(val interface{}) string {
 	}
 }
 
-func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
-	ctx := context.Background()
+// ocrPrompt 识别提示词：依然要求返回字符串，但我们会在代码层做兜底；第二识别服务共用
+const ocrPrompt = `
+	你是一个专业OCR助手。请分析图片，识别其中出现的**所有**彩票。
+	返回一个JSON数组（Array），每个元素代表一张票。
+	字段说明：
+	- type: 彩种名称 (例如 "双色球")
+	- issue: 期号 (例如 "2025107")
+	- sale_time: 票面打印的销售时间，格式 "2006-01-02 15:04:05"，看不清则留空
+	- serial: 票面序列号（一长串数字/字母，通常在票面顶部或底部），看不清则留空
+	- amount: 票面印刷的投注金额（元，只填数字），看不清则留空
+	- station: 票面底部打印的销售站点编号（“站号”“站点编号”“投注站”后的数字），看不清则留空
+	- tickets: 号码列表数组；大乐透票面印有“追加”时该行 add_on 为 true
+	  每行的 mode 为票面标注的投注方式（单式/复式/胆拖），pick_method 为票面标注的“机选”或“自选”；
+	  胆拖票的胆码放 dan、拖码放 red，大乐透后区胆码放 blue_dan
+	- sections: 同一张票上另有附加玩法（如生肖乐）时，每个玩法一个对象，字段同上（type、issue、tickets），没有则省略
+	
+	【重要】：
+	tickets 中的 "red" 和 "blue" 数组里的号码，请尽量输出为字符串(例如 "01")。
+	如果无法确定，输出数字也可以，我会自行处理。
+	`
+
+// callGeminiOCR temperature 为 nil 时使用模型默认温度
+func callGeminiOCR(ctx context.Context, fileBytes []byte, apiKey string, temperature *float32) ([]LotteryData, error) {
+	mimeType := http.DetectContentType(fileBytes)
+	prompt := ocrPromptFor(imageSourceFrom(ctx, fileBytes))
+	trace := debugTraceFrom(ctx)
+	model := ocrModelFor(ctx)
+	ex := debugExchange{Provider: "gemini", Model: model, Prompt: prompt, Temperature: temperature, MIMEType: mimeType}
+	if data, ok, err := replayOCR(ex, trace, temperature, fileBytes); ok {
+		return data, err
+	}
 
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:  apiKey,
@@ -258,24 +376,8 @@ func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
 		return nil, fmt.Errorf("创建客户端失败: %v", err)
 	}
 
-	// 提示词：依然要求返回字符串，但我们会在代码层做兜底
-	promptText := `
-	你是一个专业OCR助手。请分析图片，识别其中出现的**所有**彩票。
-	返回一个JSON数组（Array），每个元素代表一张票。
-	字段说明：
-	- type: 彩种名称 (例如 "双色球")
-	- issue: 期号 (例如 "2025107")
-	- tickets: 号码列表数组
-	
-	【重要】：
-	tickets 中的 "red" 和 "blue" 数组里的号码，请尽量输出为字符串(例如 "01")。
-	如果无法确定，输出数字也可以，我会自行处理。
-	`
-
-	mimeType := http.DetectContentType(fileBytes)
-
 	parts := []*genai.Part{
-		{Text: promptText},
+		{Text: prompt},
 		{
 			InlineData: &genai.Blob{
 				Data:     fileBytes,
@@ -293,18 +395,104 @@ func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
 
 	config := &genai.GenerateContentConfig{
 		ResponseMIMEType: "application/json",
+		Temperature:      temperature,
 	}
 
-	resp, err := client.Models.GenerateContent(ctx, GEMINI_MODEL, contents, config)
+	ex.StartedAt = time.Now()
+	resp, err := client.Models.GenerateContent(ctx, model, contents, config)
+	ex.DurationMs = time.Since(ex.StartedAt).Milliseconds()
 	if err != nil {
-		return nil, fmt.Errorf("API调用错误: %v (MIME: %s)", err, mimeType)
+		ex.Error = err.Error()
+		trace.Record(ex)
+		// 客户端主动断开不算 AI 服务故障
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v (MIME: %s)", errOCRProvider, err, mimeType)
+	}
+
+	tokenUsage.AddGemini(model, resp.UsageMetadata)
+	if u := resp.UsageMetadata; u != nil {
+		billing.AddTokens(billingTenantFrom(ctx), model, int64(u.PromptTokenCount), int64(u.CandidatesTokenCount))
 	}
 
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		ex.Error = "无识别结果"
+		trace.Record(ex)
 		return nil, fmt.Errorf("无识别结果")
 	}
 
-	jsonStr := resp.Candidates[0].Content.Parts[0].Text
+	ex.RawOutput = resp.Candidates[0].Content.Parts[0].Text
+	recordOCR(ex, temperature, fileBytes)
+	data, err := parseOCRText(ex.RawOutput)
+	if err != nil {
+		ex.Error = err.Error()
+	}
+	ex.Cleaned = data
+	trace.Record(ex)
+	return data, err
+}
+
+// anyStrings 宽松解析的号码数组转为字符串，空数组返回 nil
+func anyStrings(vals []interface{}) []string {
+	if len(vals) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		out = append(out, anyToString(v))
+	}
+	return out
+}
+
+// clean 把宽松解析的结果转换为标准结构，附加玩法逐段转换
+func (raw RawLotteryData) clean() LotteryData {
+	cleanTickets := []UserTicket{}
+
+	for _, t := range raw.Tickets {
+		// 处理红球：遍历 interface{} 数组，转为 string 数组
+		cleanRed := []string{}
+		for _, r := range t.Red {
+			cleanRed = append(cleanRed, anyToString(r))
+		}
+
+		// 处理蓝球
+		cleanBlue := []string{}
+		for _, b := range t.Blue {
+			cleanBlue = append(cleanBlue, anyToString(b))
+		}
+
+		cleanTickets = append(cleanTickets, UserTicket{
+			Red:        cleanRed,
+			Blue:       cleanBlue,
+			Multiplier: t.Multiplier,
+			Mode:       t.Mode,
+			AddOn:      t.AddOn,
+			PickMethod: strings.TrimSpace(t.PickMethod),
+			Dan:        anyStrings(t.Dan),
+			BlueDan:    anyStrings(t.BlueDan),
+		})
+	}
+
+	var sections []LotteryData
+	for _, sec := range raw.Sections {
+		sections = append(sections, sec.clean())
+	}
+	return LotteryData{
+		Type:     raw.Type,
+		Issue:    raw.Issue,
+		SaleTime: strings.TrimSpace(raw.SaleTime),
+		Serial:   strings.TrimSpace(raw.Serial),
+		Station:  normalizeStation(raw.Station),
+		Amount:   parseAmount(raw.Amount),
+		Tickets:  cleanTickets,
+		Sections: sections,
+	}
+}
+
+// parseOCRText 清洗模型返回的文本并转换为标准结构
+func parseOCRText(jsonStr string) ([]LotteryData, error) {
+	fenced := jsonStr
 	jsonStr = strings.TrimPrefix(jsonStr, "```json")
 	jsonStr = strings.TrimPrefix(jsonStr, "```")
 	jsonStr = strings.TrimSuffix(jsonStr, "```")
@@ -319,8 +507,20 @@ func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
 		if err2 := json.Unmarshal([]byte(jsonStr), &singleRaw); err2 == nil {
 			rawDataList = []RawLotteryData{singleRaw}
 		} else {
-			fmt.Printf("JSON解析彻底失败: %v\n原始文本: %s\n", err, jsonStr)
-			return nil, err
+			// 3. 修复常见格式问题后逐张票容错解码，见 jsonrepair.go
+			repaired, steps := repairJSON(jsonStr)
+			if fenced != jsonStr {
+				steps = append([]string{"去掉代码块标记"}, steps...)
+			}
+			list, more, err3 := tolerantDecode(repaired)
+			steps = append(steps, more...)
+			if err3 != nil {
+				// 模型原始输出里有序列号、条码，经 log 输出才会脱敏
+				log.Printf("JSON解析彻底失败: %v（已尝试：%s）\n原始文本: %s", err, strings.Join(steps, "、"), jsonStr)
+				return nil, newOCRParseError(jsonStr, err, append(steps, "修复后仍失败: "+err3.Error()))
+			}
+			log.Printf("模型输出不是合法 JSON，修复后解析成功：%s", strings.Join(steps, "、"))
+			rawDataList = list
 		}
 	}
 
@@ -328,34 +528,7 @@ func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
 	var finalData []LotteryData
 
 	for _, raw := range rawDataList {
-		cleanTickets := []UserTicket{}
-
-		for _, t := range raw.Tickets {
-			// 处理红球：遍历 interface{} 数组，转为 string 数组
-			cleanRed := []string{}
-			for _, r := range t.Red {
-				cleanRed = append(cleanRed, anyToString(r))
-			}
-
-			// 处理蓝球
-			cleanBlue := []string{}
-			for _, b := range t.Blue {
-				cleanBlue = append(cleanBlue, anyToString(b))
-			}
-
-			cleanTickets = append(cleanTickets, UserTicket{
-				Red:        cleanRed,
-				Blue:       cleanBlue,
-				Multiplier: t.Multiplier,
-				Mode:       t.Mode,
-			})
-		}
-
-		finalData = append(finalData, LotteryData{
-			Type:    raw.Type,
-			Issue:   raw.Issue,
-			Tickets: cleanTickets,
-		})
+		finalData = append(finalData, raw.clean())
 	}
 
 	return finalData, nil
@@ -365,7 +538,8 @@ func callGeminiOCR(fileBytes []byte, apiKey string) ([]LotteryData, error) {
 // 4. 模拟数据库 (Mock DB)
 // ==========================================
 
-func getMockWinningNumber(lotteryType, issue string) WinningNumbers {
+// getMockWinningNumber 返回开奖号码，第二个返回值表示该期是否已有开奖结果
+func getMockWinningNumber(lotteryType, issue string) (WinningNumbers, bool) {
 	// 容错：去除 potential whitespace
 	issue = strings.TrimSpace(issue)
 
@@ -373,93 +547,677 @@ func getMockWinningNumber(lotteryType, issue string) WinningNumbers {
 		// 对应你的图片期号 2025107
 		// 这里我随机填了一组中奖号码用于测试，你可以改成图片上的号码测试是否中奖
 		// 假设开奖号码就是第一行的号码: 02 11 15 21 28 33 + 07
-		return WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+		return WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}, true
 	}
 
 	// 之前的 Mock 数据
 	if strings.Contains(lotteryType, "双色球") && issue == "2025145" {
-		return WinningNumbers{Red: []string{"02", "09", "15", "23", "28", "33"}, Blue: []string{"06"}}
+		return WinningNumbers{Red: []string{"02", "09", "15", "23", "28", "33"}, Blue: []string{"06"}}, true
 	}
 
-	return WinningNumbers{Red: []string{"00"}, Blue: []string{"00"}}
+	return WinningNumbers{Red: []string{"00"}, Blue: []string{"00"}}, false
 }
 
 // ==========================================
 // 5. API 控制器
 // ==========================================
 
+// selectVerifier 根据彩种名称选择验奖器，不支持的彩种返回 nil
+func selectVerifier(lotteryType string) Verifier {
+	game := canonicalGame(lotteryType)
+	switch game {
+	case "双色球":
+		return &DoubleColorVerifier{}
+	case "大乐透":
+		return &LottoVerifier{}
+	case "排列5":
+		return &Permutation5Verifier{}
+	}
+	if v, ok := dslVerifiers[game]; ok {
+		return v
+	}
+	return nil
+}
+
+// verifyLottery 验一张彩票；票面上的附加玩法逐段验奖，奖金计入整张票
+func verifyLottery(b *requestBudget, idx int, lottery LotteryData) (VerificationResult, error) {
+	lottery, gw := resolveGameType(lottery)
+	main, mw := checkMultipliers(lottery, len(lottery.Sections) == 0)
+	main.Sections = nil
+	res, err := verifySection(b, idx, main)
+	if err != nil {
+		return res, err
+	}
+	res.Warnings = append(res.Warnings, gw...)
+	res.Warnings = append(res.Warnings, mw...)
+	if len(lottery.Sections) == 0 || res.Rejected {
+		return res, nil
+	}
+	for i, sec := range lottery.Sections {
+		// 附加玩法一般不单独打印期号和销售时间，沿用主玩法的
+		if sec.Issue == "" {
+			sec.Issue = lottery.Issue
+		}
+		if sec.SaleTime == "" {
+			sec.SaleTime = lottery.SaleTime
+		}
+		if sec.Station == "" {
+			sec.Station = lottery.Station
+		}
+		sec, gw := resolveGameType(sec)
+		sec, sw := checkMultipliers(sec, false)
+		r, err := verifySection(b, i, sec)
+		if err != nil {
+			return VerificationResult{}, err
+		}
+		r.Warnings = append(r.Warnings, gw...)
+		r.Warnings = append(r.Warnings, sw...)
+		r.Claim = nil
+		res.Sections = append(res.Sections, r)
+		res.TotalPrize += r.TotalPrize
+		res.Pending = res.Pending || r.Pending
+	}
+	lottery.Tickets = main.Tickets
+	res.OCRData = lottery
+	res.Claim = claimRules.Guide(lottery.Type, lottery.Issue, res.TotalPrize)
+	return res, nil
+}
+
+// verifySection 对一个玩法的所有号码行进行验奖，开奖查询与验奖各自受预算约束
+func verifySection(b *requestBudget, idx int, lottery LotteryData) (VerificationResult, error) {
+	drawCtx, cancelDraw := b.Stage(stageDraw)
+	supplied, hasSupplied := suppliedDraw(drawCtx, lottery.Type, lottery.Issue)
+	var winNum WinningNumbers
+	var drawn bool
+	if hasSupplied {
+		winNum, drawn = supplied.winning(), true
+	} else {
+		winNum, drawn = lookupWinningNumbers(drawCtx, lottery.Type, lottery.Issue)
+	}
+	cancelDraw()
+	if err := b.Check(drawCtx, stageDraw); err != nil {
+		return VerificationResult{}, err
+	}
+	verifier := selectVerifier(lottery.Type)
+
+	warnings := checkSaleTime(lottery, time.Now())
+	if len(warnings) > 0 && strictSaleTime() {
+		return rejectedResult(idx, lottery, warnings), nil
+	}
+	warnings = append(warnings, betModeWarnings(lottery, verifier)...)
+	if hasSupplied {
+		warnings = append(warnings, suppliedDrawWarning)
+	}
+
+	// 已开奖的期次结果不会再变，相同号码直接复用缓存；调用方提供的开奖号码不进缓存
+	cacheKey := verifyCacheKey(lottery)
+	if drawn && verifier != nil && !hasSupplied {
+		if cached, ok := verifyCache.Get(cacheKey); ok {
+			cached.TicketIndex = idx + 1
+			cached.OCRData = lottery
+			cached.Cached = true
+			cached.Warnings = warnings
+			return cached, nil
+		}
+	}
+
+	provisional := false
+	res := VerificationResult{
+		TicketIndex: idx + 1,
+		OCRData:     lottery,
+		TotalPrize:  0,
+		Details:     []ResultDetail{},
+	}
+	if hasSupplied {
+		res.SuppliedDraw = &supplied
+	}
+
+	if verifier != nil && !drawn {
+		res.Pending = true
+		status := "待开奖"
+		if issueDrawn(lottery.Type, lottery.Issue, time.Now()) {
+			// 按开奖日历应当已开奖，只是开奖数据还没同步到
+			status = "已开奖，等待开奖数据同步"
+		}
+		for rowIdx, t := range lottery.Tickets {
+			res.Details = append(res.Details, ResultDetail{
+				RowIndex: rowIdx + 1, Status: status, BetType: betTypeOf(lottery.Type, t), PickMethod: pickMethodOf(t),
+			})
+		}
+	} else if verifier != nil {
+		verifyCtx, cancelVerify := b.Stage(stageVerify)
+		defer cancelVerify()
+		for rowIdx, t := range lottery.Tickets {
+			// 大复式单行就要拆上千注，每行之间检查一次是否超时
+			if err := b.Check(verifyCtx, stageVerify); err != nil {
+				return VerificationResult{}, err
+			}
+			betType, err := checkBetMode(lottery.Type, rowIdx+1, t, verifier)
+			if err != nil {
+				// 玩法不一致时宁可不给结果，也不按错误的方式验奖
+				res.Details = append(res.Details, ResultDetail{
+					RowIndex: rowIdx + 1, Status: "玩法校验未通过，未验奖", BetType: betType, PickMethod: pickMethodOf(t),
+				})
+				continue
+			}
+			level, prize, status := verifier.Verify(t, winNum)
+			total := prize * Fen(t.Multiplier)
+			estimated := winNum.prizeEstimated(lottery.Type, level, t.AddOn)
+			provisional = provisional || estimated
+
+			res.TotalPrize += total
+			res.Details = append(res.Details, ResultDetail{
+				RowIndex: rowIdx + 1, Level: level, Prize: total, Status: status, Estimated: estimated,
+				BetType: betType, PickMethod: pickMethodOf(t),
+				Explanation: explainRow(lottery.Type, t, winNum, verifier, level, prize),
+			})
+		}
+	} else {
+		res.Details = append(res.Details, ResultDetail{Status: "暂不支持该彩种验奖"})
+	}
+	res.Claim = claimRules.Guide(lottery.Type, lottery.Issue, res.TotalPrize)
+
+	// 含估算奖金的结果在官方公布后会变，不缓存
+	if drawn && verifier != nil && !provisional && !hasSupplied {
+		verifyCache.Set(cacheKey, res)
+	}
+	res.Warnings = warnings
+	return res, nil
+}
+
+// verifyAll 依次验奖，任一张超时即整体返回错误
+func verifyAll(b *requestBudget, ocrResults []LotteryData) ([]VerificationResult, error) {
+	results := make([]VerificationResult, 0, len(ocrResults))
+	for idx, lottery := range ocrResults {
+		res, err := verifyLottery(b, idx, lottery)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return hooks.AfterVerify(b.Context(), results), nil
+}
+
+// respondStageError 把预算超时映射为 504、当日识别预算用完映射为 429，其余错误按 500 返回
+func respondStageError(c *gin.Context, prefix string, err error) {
+	if errors.Is(err, errBudgetExceeded) {
+		c.JSON(429, gin.H{"error": err.Error(), "code": "BUDGET_EXCEEDED"})
+		return
+	}
+	if isTimeout(err) {
+		c.JSON(504, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"error": prefix + err.Error()}
+	if detail := parseErrorDetail(c, err); detail != nil {
+		resp["code"], resp["parse_error"] = "OCR_PARSE_FAILED", detail
+	}
+	c.JSON(500, resp)
+}
+
 func verifyHandler(c *gin.Context) {
-	file, _, err := c.Request.FormFile("image")
+	supplied, err := suppliedDrawsOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "winning 格式错误: " + err.Error()})
+		return
+	}
+	source, err := imageSourceOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	roi, err := roiOf(c)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "请上传名为 'image' 的文件"})
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	budget := newRequestBudget(withSuppliedDraws(c.Request.Context(), supplied))
+	defer budget.Done()
+	trace := debugTraceOf(c)
+
+	started := time.Now()
+	prepCtx, cancelPrep := budget.Stage(stagePreprocess)
+	fileBytes, ok := scanImageOf(prepCtx, c)
+	cancelPrep()
+	if !ok {
+		return
+	}
+	defer func() { trace.Save(c, fileBytes) }()
+	trace.Mark(stagePreprocess, started)
+	if err := budget.Check(prepCtx, stagePreprocess); err != nil {
+		respondStageError(c, "", err)
 		return
 	}
-	fileBytes, _ := io.ReadAll(file)
 
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
 		c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
 		return
 	}
-
-	ocrResults, err := callGeminiOCR(fileBytes, apiKey)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "AI 识别失败: " + err.Error()})
+	if asyncOf(c) {
+		// 排队任务只按官方开奖数据识别整张图
+		if len(supplied) > 0 || len(roi) > 0 {
+			c.JSON(400, gin.H{"error": "async 不支持 winning 和 roi"})
+			return
+		}
+		job, err := jobQueue.Enqueue(fileBytes, originOf(c))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "排队失败: " + err.Error()})
+			return
+		}
+		c.JSON(202, gin.H{"job_id": job.ID, "status": job.Status})
 		return
 	}
 
-	finalResponse := []VerificationResult{}
-
-	for idx, lottery := range ocrResults {
-		winNum := getMockWinningNumber(lottery.Type, lottery.Issue)
-
-		var verifier Verifier
-		if strings.Contains(lottery.Type, "双色球") {
-			verifier = &DoubleColorVerifier{}
-		} else if strings.Contains(lottery.Type, "大乐透") {
-			verifier = &LottoVerifier{}
-		} else if strings.Contains(lottery.Type, "排列5") {
-			verifier = &Permutation5Verifier{}
+	started = time.Now()
+	ocrCtx, cancelOCR := budget.Stage(stageOCR)
+	ocrResults, err := recognizeCached(withROI(withImageName(withImageSource(withDebugTrace(withOCRPasses(ocrCtx, ocrPassesOf(c)), trace), source), imageNameOf(c)), roi), fileBytes, apiKey)
+	if err != nil {
+		if stageErr := budget.Check(ocrCtx, stageOCR); stageErr != nil {
+			err = stageErr
 		}
-
-		res := VerificationResult{
-			TicketIndex: idx + 1,
-			OCRData:     lottery,
-			TotalPrize:  0,
-			Details:     []ResultDetail{},
+	}
+	cancelOCR()
+	trace.Mark(stageOCR, started)
+	if errors.Is(err, errCircuitOpen) {
+		// AI 服务熔断期间：开启了排队降级就先收下图片，恢复后自动处理（排队任务只按官方开奖数据验奖）
+		if queueOnOutage() && len(supplied) == 0 {
+			job, qerr := jobQueue.Enqueue(fileBytes, originOf(c))
+			if qerr != nil {
+				c.JSON(500, gin.H{"error": "排队失败: " + qerr.Error()})
+				return
+			}
+			c.JSON(202, gin.H{"job_id": job.ID, "status": job.Status})
+			return
 		}
+		onScanFailed(tenantOf(c), errCircuitOpen.Error())
+		c.JSON(503, gin.H{"error": "AI 识别服务暂时不可用，请稍后重试"})
+		return
+	}
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "AI 识别失败: ", err)
+		return
+	}
 
-		if verifier != nil {
-			for rowIdx, t := range lottery.Tickets {
-				level, prize, status := verifier.Verify(t, winNum)
-				total := prize * int64(t.Multiplier)
-
-				res.TotalPrize += total
-				res.Details = append(res.Details, ResultDetail{
-					RowIndex: rowIdx + 1, Level: level, Prize: total, Status: status,
-				})
+	// 请求了流式输出时，每验完一张票就立即写出；要求交叉核对时需要完整结果，不走流式
+	started = time.Now()
+	if mode := streamModeOf(c); mode != "" && !ensembleOf(c) {
+		stream := newResultStream(c, mode)
+		streamed := make([]VerificationResult, 0, len(ocrResults))
+		tamper := inspectImage(fileBytes)
+		for idx, lottery := range ocrResults {
+			res, err := verifyLottery(budget, idx, lottery)
+			if err != nil {
+				// 响应头已发出，只能记录日志并截断输出
+				log.Printf("流式验奖中断: %v", err)
+				return
 			}
-		} else {
-			res.Details = append(res.Details, ResultDetail{Status: "暂不支持该彩种验奖"})
+			// 与 verifyAll 一样先执行验奖后钩子，只是每次只交给钩子一张票
+			for _, res := range hooks.AfterVerify(budget.Context(), []VerificationResult{res}) {
+				tamper.apply(&res)
+				streamed = append(streamed, res)
+				if detailFullOf(c) {
+					one := []VerificationResult{res}
+					addBetBreakdown(one)
+					res = one[0]
+				}
+				res = presentResults(c, []VerificationResult{res})[0]
+				if err := stream.Write(res); err != nil {
+					log.Printf("流式写出中断: %v", err)
+					return
+				}
+			}
+		}
+		stream.Close()
+		trace.Mark(stageVerify, started)
+		trace.SetResults(streamed)
+		if len(supplied) == 0 {
+			onScanCompleted(originOf(c), fileBytes, streamed)
 		}
+		return
+	}
 
-		finalResponse = append(finalResponse, res)
+	finalResponse, err := verifyAll(budget, ocrResults)
+	trace.Mark(stageVerify, started)
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "验奖失败: ", err)
+		return
 	}
+	inspectImage(fileBytes).applyAll(finalResponse)
+	if len(supplied) > 0 {
+		// 不是官方开奖结果，不做交叉核对，也不记入历史、不发通知
+		trace.SetResults(finalResponse)
+		respondResults(c, finalResponse)
+		return
+	}
+	review, err := ensembleGate(withDebugTrace(withEnsemble(c.Request.Context(), ensembleOf(c)), trace), originOf(c), fileBytes, ocrResults, finalResponse)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if review != nil {
+		c.JSON(202, gin.H{"review_id": review.ID, "status": review.Status, "reason": review.Reason, "disagreements": review.Disagreements})
+		return
+	}
+	trace.SetResults(finalResponse)
+	onScanCompleted(originOf(c), fileBytes, finalResponse)
 
-	c.JSON(200, finalResponse)
+	respondResults(c, finalResponse)
 }
 
 func main() {
+	loadtest := flag.Bool("loadtest", false, "压测模式：向运行中的服务回放 fixtures 图片")
+	target := flag.String("target", "http://127.0.0.1:8080/api/v1/scan", "压测目标地址")
+	fixtures := flag.String("fixtures", "images", "压测使用的图片目录")
+	requests := flag.Int("n", 100, "压测总请求数")
+	concurrency := flag.Int("c", 4, "压测并发数")
+	synth := flag.String("synth", "", "生成合成票面图片到该目录后退出，张数用 -n，见 synth.go")
+	synthCheck := flag.String("synth-check", "", "用该目录的合成票面测试运行中服务的识别+验奖准确率，见 synthcheck.go")
+	synthGames := flag.String("synth-games", "", "合成票面的彩种，逗号分隔，默认全部")
+	synthRows := flag.String("synth-rows", "1-5", "每张合成票面的号码行数范围")
+	synthFont := flag.String("synth-font", "dot", "合成票面字体：dot、block、bold 或 BDF 文件路径，逗号分隔时随机选用")
+	synthNoise := flag.Float64("synth-noise", 0.2, "合成票面的噪点与褪色程度，0~1")
+	synthRotate := flag.Float64("synth-rotate", 5, "合成票面的最大旋转角度（度）")
+	synthSeed := flag.Uint64("synth-seed", 1, "合成票面的随机种子，种子相同生成的图片相同")
+	synthLabel := flag.String("synth-label", "", "准确率报告的标签，通常为版本号，默认当前时间")
+	watchDir := flag.String("watch", "", "监听目录：自动处理扫描仪输出的图片，未设置时读取 WATCH_DIR")
+	roleFlag := flag.String("role", "", "进程角色：all（默认）、api、worker，未设置时读取 SERVICE_ROLE，见 worker.go")
+	standalone := flag.Bool("standalone", false, "单机模式：SQLite 保存记录、无需 Redis，也可设置 STANDALONE=1，见 standalone.go")
+	apiKey := flag.String("api-key", "", "Gemini API Key，未设置时读取 GEMINI_API_KEY")
+	configFile := flag.String("config", "", "配置文件，默认读取 CONFIG_FILE 或 lottery.yaml，见 profile.go")
+	profile := flag.String("profile", os.Getenv("LOTTERY_PROFILE"), "配置文件中的 profile，如 dev、staging、prod")
+	flag.Parse()
+
+	// profile 写入的环境变量要先于其他任何配置读取生效
+	if err := applyProfile(configFilePath(*configFile), *profile); err != nil {
+		log.Fatalf("加载配置文件失败: %v", err)
+	}
+	if *watchDir == "" {
+		*watchDir = os.Getenv("WATCH_DIR")
+	}
+	if *roleFlag == "" {
+		*roleFlag = os.Getenv("SERVICE_ROLE")
+	}
+	if *apiKey != "" {
+		os.Setenv("GEMINI_API_KEY", *apiKey)
+	}
+	if *standalone || envBool("STANDALONE", false) {
+		applyStandaloneDefaults()
+	}
+
+	if *loadtest {
+		err := runLoadtest(loadtestOptions{
+			Target: *target, Fixtures: *fixtures,
+			Requests: *requests, Concurrency: *concurrency, Timeout: 2 * time.Minute,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *synth != "" {
+		minRows, maxRows, err := parseSynthRows(*synthRows)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = runSynth(synthOptions{
+			Out: *synth, Count: *requests, Games: splitFlagList(*synthGames), MinRows: minRows, MaxRows: maxRows,
+			Fonts: splitFlagList(*synthFont), Noise: *synthNoise, Rotate: *synthRotate, Seed: *synthSeed,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *synthCheck != "" {
+		err := runSynthCheck(synthCheckOptions{
+			Dir: *synthCheck, Target: *target, Concurrency: *concurrency, Label: *synthLabel, Timeout: 2 * time.Minute,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if (mockOCREnabled() || cassetteMode() == cassetteReplay) && os.Getenv("GEMINI_API_KEY") == "" {
+		// mock 识别与回放都不需要真实的 Key，占位后各处的“未配置”检查照常通过
+		os.Setenv("GEMINI_API_KEY", providerMock)
+	}
 	if os.Getenv("GEMINI_API_KEY") == "" {
 		log.Fatal("请先设置环境变量 GEMINI_API_KEY")
 	}
+	role, err := parseServiceRole(*roleFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	background := role != serviceRoleWorker
+
+	// 脱敏要最先生效，之后的所有日志（含 gin 访问日志）都经过脱敏
+	log.SetOutput(redactWriter{os.Stderr})
+	gin.DefaultWriter, gin.DefaultErrorWriter = redactWriter{os.Stdout}, redactWriter{os.Stderr}
+	if err := redaction.Load(filepath.Join(dataDir(), "redact.json")); err != nil {
+		log.Fatalf("加载脱敏规则失败: %v", err)
+	}
+
+	if err := loadAtRestKeys(); err != nil {
+		log.Fatalf("加载数据加密主密钥失败: %v", err)
+	}
+	if err := initSharedState(); err != nil {
+		log.Fatalf("连接共享状态后端失败: %v", err)
+	}
+	// 自定义彩种要先于开奖数据加载，开奖记录按标准彩种名称索引
+	if err := loadPlugins(pluginDir()); err != nil {
+		log.Fatalf("加载插件失败: %v", err)
+	}
+	if err := loadGameDefinitions(filepath.Join(dataDir(), "games")); err != nil {
+		log.Fatalf("加载彩种定义失败: %v", err)
+	}
+	if err := draws.Load(filepath.Join(dataDir(), "draws.json")); err != nil {
+		log.Fatalf("加载开奖数据失败: %v", err)
+	}
+	if err := calendar.Load(filepath.Join(dataDir(), "calendar.json")); err != nil {
+		log.Fatalf("加载开奖日历失败: %v", err)
+	}
+	if err := claimRules.Load(filepath.Join(dataDir(), "claim_rules.json")); err != nil {
+		log.Fatalf("加载兑奖规则失败: %v", err)
+	}
+	if err := promotions.Load(filepath.Join(dataDir(), "promotions.json")); err != nil {
+		log.Fatalf("加载派奖配置失败: %v", err)
+	}
+	if err := prizeRules.Load(filepath.Join(dataDir(), "prize_rules.json")); err != nil {
+		log.Fatalf("加载奖金规则失败: %v", err)
+	}
+	if err := loadCatalogs(filepath.Join(dataDir(), "i18n")); err != nil {
+		log.Fatalf("加载语言目录失败: %v", err)
+	}
+	if err := tokenUsage.Load(filepath.Join(dataDir(), "usage.json")); err != nil {
+		log.Fatalf("加载 Token 用量失败: %v", err)
+	}
+	if err := billing.Load(filepath.Join(dataDir(), "billing.json")); err != nil {
+		log.Fatalf("加载计费用量失败: %v", err)
+	}
+	if err := ocrOutcomes.Load(filepath.Join(dataDir(), "ocr_outcomes.json")); err != nil {
+		log.Fatalf("加载识别统计失败: %v", err)
+	}
+	if err := privacy.Load(filepath.Join(dataDir(), "privacy.json")); err != nil {
+		log.Fatalf("加载隐私设置失败: %v", err)
+	}
+	if err := scanHistory.Load(historyPath()); err != nil {
+		log.Fatalf("加载扫描记录失败: %v", err)
+	}
+	if err := scanFeedback.Load(filepath.Join(dataDir(), "feedback.json")); err != nil {
+		log.Fatalf("加载识别反馈失败: %v", err)
+	}
+	if err := drawSubscriptions.Load(filepath.Join(dataDir(), "subscriptions.json")); err != nil {
+		log.Fatalf("加载开奖订阅失败: %v", err)
+	}
+	images = newImageStore(filepath.Join(dataDir(), "images"))
+	debugTraces = &debugStore{dir: filepath.Join(dataDir(), "debug")}
+	hub, err := loadNotifyConfig()
+	if err != nil {
+		log.Fatalf("加载通知配置失败: %v", err)
+	}
+	notifier = hub
+	if err := pendingTickets.Load(filepath.Join(dataDir(), "pending.json")); err != nil {
+		log.Fatalf("加载待开奖票据失败: %v", err)
+	}
+	if err := claimReminders.Load(filepath.Join(dataDir(), "reminders.json")); err != nil {
+		log.Fatalf("加载兑奖提醒失败: %v", err)
+	}
+	go followDraws()
+	if background {
+		go claimReminders.Run()
+		if feed := os.Getenv("DRAW_FEED_URL"); feed != "" {
+			go runDrawSync(feed)
+		}
+	}
+
+	if reviewQueue, err = newReviewStore(filepath.Join(dataDir(), "review")); err != nil {
+		log.Fatalf("初始化复核队列失败: %v", err)
+	}
+	q, err := newScanJobQueue(filepath.Join(dataDir(), "jobs"))
+	if err != nil {
+		log.Fatalf("初始化任务队列失败: %v", err)
+	}
+	jobQueue = q
+	if role == serviceRoleAll {
+		go jobQueue.Run(os.Getenv("GEMINI_API_KEY"))
+	}
+	if scanBatches, err = newBatchStore(filepath.Join(dataDir(), "batches")); err != nil {
+		log.Fatalf("初始化批次存储失败: %v", err)
+	}
+	if err := apiKeys.Load(filepath.Join(dataDir(), "api_keys.json")); err != nil {
+		log.Fatalf("加载 API Key 配置失败: %v", err)
+	}
+	if err := authSessions.Load(filepath.Join(dataDir(), "sessions.json")); err != nil {
+		log.Fatalf("加载登录会话失败: %v", err)
+	}
+	if err := admins.Load(filepath.Join(dataDir(), "admin_tokens.json")); err != nil {
+		log.Fatalf("加载管理员令牌失败: %v", err)
+	}
+	if quotas, err = newQuotaStore(filepath.Join(dataDir(), "quota")); err != nil {
+		log.Fatalf("初始化配额计数失败: %v", err)
+	}
+	if uploads, err = newUploadStore(filepath.Join(dataDir(), "uploads")); err != nil {
+		log.Fatalf("初始化上传目录失败: %v", err)
+	}
+	if *watchDir != "" && background {
+		go runDirWatch(*watchDir, os.Getenv("GEMINI_API_KEY"))
+	}
+	if addr := os.Getenv("FTP_ADDR"); addr != "" && background {
+		go runFTPServer(addr, filepath.Join(dataDir(), "ftp_terminals.json"))
+	}
+	if imapCfg, ok := loadIMAPConfig(); ok && background {
+		if smtpCfg, ok := loadSMTPConfig(); ok {
+			go runMailIn(imapCfg, smtpCfg)
+		} else {
+			log.Printf("已配置 IMAP_ADDR 但未配置 SMTP，邮件验奖无法回信，不启动")
+		}
+	}
+
+	bus, err := newEventBus()
+	if err != nil {
+		log.Fatalf("初始化事件总线失败: %v", err)
+	}
+	events = bus
+	kp, err := newKioskPublisher()
+	if err != nil {
+		log.Fatalf("连接 MQTT 失败: %v", err)
+	}
+	kiosks = kp
+	opsAlerts = loadOpsAlerter()
+	go opsAlerts.Run()
+	if role == serviceRoleWorker {
+		runWorker(os.Getenv("GEMINI_API_KEY"))
+		return
+	}
+
+	if err := routeLimits.Load(filepath.Join(dataDir(), "route_limits.json")); err != nil {
+		log.Fatalf("加载路由限制失败: %v", err)
+	}
+	if err := ipAccess.Load(filepath.Join(dataDir(), "ip_access.json")); err != nil {
+		log.Fatalf("加载 IP 访问控制失败: %v", err)
+	}
+	go reloadOnSIGHUP()
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("TRUSTED_PROXIES 配置错误: %v", err)
+	}
+	r.Use(ipDenyFilter())
+	r.Use(metricsMiddleware())
+	r.Use(routeLimitMiddleware())
+	r.Use(localeMiddleware())
+	r.Use(apiKeyAuth())
+	r.Use(rateLimitMiddleware())
+	r.Use(userTokenAuth())
+	r.Use(billingContext())
+	// 只决定上传文件在内存中缓冲的大小，请求体上限按路由配置，见 limits.go
 	r.MaxMultipartMemory = 8 << 20
 
-	r.POST("/api/v1/scan", verifyHandler)
+	registerWebUI(r)
+	r.POST("/api/v1/auth/token", authTokenHandler)
+	r.POST("/api/v1/auth/refresh", authRefreshHandler)
+	r.GET("/api/v1/auth/sessions", authSessionsHandler)
+	r.DELETE("/api/v1/auth/sessions/:id", authRevokeHandler)
+	r.POST("/api/v1/scan", abuseGuard(), scanQuota(perRequest), verifyHandler)
+	r.POST("/api/v1/scan/precheck", precheckHandler)
+	r.POST("/api/v1/ocr", abuseGuard(), scanQuota(perRequest), ocrOnlyHandler)
+	r.POST("/api/v1/verify", confirmedVerifyHandler)
+	r.POST("/api/v1/verify/text", typedVerifyHandler)
+	r.POST("/api/v1/uploads", uploadCreateHandler)
+	r.HEAD("/api/v1/uploads/:id", uploadHeadHandler)
+	r.GET("/api/v1/uploads/:id", uploadStatusHandler)
+	r.PATCH("/api/v1/uploads/:id", uploadPatchHandler)
+	r.DELETE("/api/v1/uploads/:id", uploadDeleteHandler)
+	r.POST("/api/v1/scan/batch", abuseGuard(), scanQuota(perImage), batchVerifyHandler)
+	r.POST("/api/v1/scan/stitch", abuseGuard(), scanQuota(perImage), stitchScanHandler)
+	r.POST("/api/v1/scan/zip", scanQuota(perZipImage), zipBatchHandler)
+	r.GET("/api/v1/batches/:id", batchStatusHandler)
+	r.GET("/api/v1/batches/:id/report", batchReportHandler)
+	r.GET("/api/v1/jobs/:id", jobStatusHandler)
+	r.GET("/api/v1/reviews", requireRole(roleOperator), reviewListHandler)
+	r.GET("/api/v1/reviews/:id", requireRole(roleOperator), reviewGetHandler)
+	r.GET("/api/v1/reviews/:id/image", requireRole(roleOperator), reviewImageHandler)
+	r.POST("/api/v1/reviews/:id/resolve", requireRole(roleOperator), reviewResolveHandler)
+	r.POST("/api/v1/feishu/events", feishuEventHandler)
+	r.GET("/api/v1/stats/:game/frequency", statsFrequencyHandler)
+	r.GET("/api/v1/stats/:game/overdue", statsOverdueHandler)
+	r.GET("/api/v1/stats/:game/distribution", statsDistributionHandler)
+	r.GET("/api/v1/pick", pickHandler)
+	r.POST("/api/v1/odds", oddsHandler)
+	r.GET("/api/v1/draws/next", nextDrawHandler)
+	r.GET("/api/v1/draws/:game", drawHandler)
+	r.GET("/api/v1/draws/:game/subscribe", drawSubscribeHandler)
+	r.GET("/api/v1/draws/:game/:issue", drawHandler)
+	r.POST("/api/v1/subscriptions", subscriptionCreateHandler)
+	r.GET("/api/v1/subscriptions", subscriptionListHandler)
+	r.DELETE("/api/v1/subscriptions/:id", subscriptionDeleteHandler)
+	r.GET("/api/v1/portfolio/summary", portfolioHandler)
+	r.POST("/api/v1/backtest", backtestHandler)
+	r.GET("/api/v1/jackpot", jackpotHandler)
+	r.GET("/api/v1/jackpot/:game/history", jackpotHistoryHandler)
+	r.GET("/api/v1/history", historyListHandler)
+	r.GET("/api/v1/quota", quotaHandler)
+	r.GET("/api/v1/history/:id/thumbnail", historyImageHandler(true))
+	r.GET("/api/v1/history/:id/image", historyImageHandler(false))
+	r.POST("/api/v1/scans/:id/reprocess", scanQuota(perRequest), reprocessHandler)
+	r.POST("/api/v1/scans/:id/feedback", scanFeedbackHandler)
+	r.GET("/api/v1/images/:id/:kind", signedImageHandler)
+	r.GET("/api/v1/stations/:id/report", requireRole(roleViewer), stationReportHandler)
+
+	// 管理接口按角色授权，见 admin.go
+	registerAdminRoutes(r.Group("/api/v1/admin"))
 
 	fmt.Printf("🚀 验奖机启动 (SDK: google.golang.org/genai | Model: %s)\n", GEMINI_MODEL)
+	if mockOCREnabled() {
+		fmt.Printf("⚠️ mock 识别：结果取自 %s，不调用 AI 服务\n", mockOCRDir())
+	}
 	fmt.Println("监听端口: 8080")
-	r.Run(":8080")
+	if err := serveHTTP(r); err != nil {
+		log.Fatal(err)
+	}
 }
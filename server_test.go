@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDoubleColorVerifier(t *testing.T) {
+	win := WinningNumbers{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	tests := []struct {
+		name   string
+		ticket UserTicket
+		level  int
+		money  Fen
+	}{
+		{"6+1 一等奖", UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}, 1, 5000000 * Yuan},
+		{"6+0 二等奖", UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"08"}}, 2, 100000 * Yuan},
+		{"5+1 三等奖", UserTicket{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"07"}}, 3, 3000 * Yuan},
+		{"5+0 四等奖", UserTicket{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{"08"}}, 4, 200 * Yuan},
+		{"4+1 四等奖", UserTicket{Red: []string{"02", "11", "15", "21", "01", "03"}, Blue: []string{"07"}}, 4, 200 * Yuan},
+		{"4+0 五等奖", UserTicket{Red: []string{"02", "11", "15", "21", "01", "03"}, Blue: []string{"08"}}, 5, 10 * Yuan},
+		{"3+1 五等奖", UserTicket{Red: []string{"02", "11", "15", "01", "03", "04"}, Blue: []string{"07"}}, 5, 10 * Yuan},
+		{"2+1 六等奖", UserTicket{Red: []string{"02", "11", "01", "03", "04", "05"}, Blue: []string{"07"}}, 6, 5 * Yuan},
+		{"0+1 六等奖", UserTicket{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}}, 6, 5 * Yuan},
+		{"3+0 未中奖", UserTicket{Red: []string{"02", "11", "15", "01", "03", "04"}, Blue: []string{"08"}}, 0, 0},
+		{"红球复式 7+1", UserTicket{Red: []string{"02", "11", "15", "21", "28", "33", "01"}, Blue: []string{"07"}}, 1, 5000000*Yuan + 6*3000*Yuan},
+		{"蓝球复式 6+2", UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07", "08"}}, 1, 5100000 * Yuan},
+		{"胆拖 2 胆 5 拖", UserTicket{Dan: []string{"02", "11"}, Red: []string{"15", "21", "28", "09", "10"}, Blue: []string{"07"}}, 3, 2*3000*Yuan + 3*200*Yuan},
+	}
+	v := &DoubleColorVerifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, money, status := v.Verify(tt.ticket, win)
+			if level != tt.level || money != tt.money {
+				t.Errorf("Verify() = %d/%s, want %d/%s", level, money, tt.level, tt.money)
+			}
+			if (money > 0) != strings.HasPrefix(status, "中奖") {
+				t.Errorf("status = %q", status)
+			}
+		})
+	}
+}
+
+func TestLottoVerifier(t *testing.T) {
+	win := WinningNumbers{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"01", "02"}}
+	published := win
+	published.Prizes, published.AddOnPrizes = map[int]Fen{1: 8000000 * Yuan}, map[int]Fen{1: 6400000 * Yuan}
+	ticket := func(red, blue int, addOn bool) UserTicket {
+		t := UserTicket{AddOn: addOn}
+		for i := 0; i < 5; i++ {
+			if i < red {
+				t.Red = append(t.Red, win.Red[i])
+			} else {
+				t.Red = append(t.Red, []string{"31", "32", "33", "34", "35"}[i])
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if i < blue {
+				t.Blue = append(t.Blue, win.Blue[i])
+			} else {
+				t.Blue = append(t.Blue, []string{"11", "12"}[i])
+			}
+		}
+		return t
+	}
+	tests := []struct {
+		name      string
+		win       WinningNumbers
+		ticket    UserTicket
+		level     int
+		money     Fen
+		estimated bool
+	}{
+		{"5+2 一等奖按估算", win, ticket(5, 2, false), 1, 10000000 * Yuan, true},
+		{"5+2 一等奖按公布值", published, ticket(5, 2, false), 1, 8000000 * Yuan, false},
+		{"5+2 追加按八成估算", win, ticket(5, 2, true), 1, 18000000 * Yuan, true},
+		{"5+2 追加按公布值", published, ticket(5, 2, true), 1, 14400000 * Yuan, false},
+		{"5+1 二等奖", win, ticket(5, 1, false), 2, 200000 * Yuan, true},
+		{"5+0 三等奖追加不加钱", win, ticket(5, 0, true), 3, 10000 * Yuan, false},
+		{"4+2 四等奖", win, ticket(4, 2, false), 4, 3000 * Yuan, false},
+		{"4+1 五等奖", win, ticket(4, 1, false), 5, 300 * Yuan, false},
+		{"3+2 六等奖", win, ticket(3, 2, false), 6, 200 * Yuan, false},
+		{"4+0 七等奖", win, ticket(4, 0, false), 7, 100 * Yuan, false},
+		{"3+1 八等奖", win, ticket(3, 1, false), 8, 15 * Yuan, false},
+		{"2+2 八等奖", win, ticket(2, 2, false), 8, 15 * Yuan, false},
+		{"3+0 九等奖", win, ticket(3, 0, false), 9, 5 * Yuan, false},
+		{"2+1 九等奖", win, ticket(2, 1, false), 9, 5 * Yuan, false},
+		{"1+2 九等奖", win, ticket(1, 2, false), 9, 5 * Yuan, false},
+		{"0+2 九等奖", win, ticket(0, 2, false), 9, 5 * Yuan, false},
+		{"2+0 未中奖", win, ticket(2, 0, false), 0, 0, false},
+	}
+	v := &LottoVerifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, money, status := v.Verify(tt.ticket, tt.win)
+			if level != tt.level || money != tt.money {
+				t.Errorf("Verify() = %d/%s, want %d/%s", level, money, tt.level, tt.money)
+			}
+			if got := strings.Contains(status, "估算"); got != tt.estimated {
+				t.Errorf("status = %q, want estimated %v", status, tt.estimated)
+			}
+		})
+	}
+}
+
+func TestPermutation5Verifier(t *testing.T) {
+	win := WinningNumbers{Red: []string{"1", "2", "3", "4", "5"}}
+	tests := []struct {
+		name  string
+		red   []string
+		level int
+		money Fen
+	}{
+		{"按位全中", []string{"1", "2", "3", "4", "5"}, 1, 100000 * Yuan},
+		{"号码相同顺序不同", []string{"5", "4", "3", "2", "1"}, 0, 0},
+		{"差一位", []string{"1", "2", "3", "4", "6"}, 0, 0},
+		{"位数不够", []string{"1", "2", "3", "4"}, 0, 0},
+	}
+	v := &Permutation5Verifier{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, money, _ := v.Verify(UserTicket{Red: tt.red}, win)
+			if level != tt.level || money != tt.money {
+				t.Errorf("Verify() = %d/%s, want %d/%s", level, money, tt.level, tt.money)
+			}
+		})
+	}
+}
+
+func TestParseOCRTextSections(t *testing.T) {
+	const text = "```json\n" + `[{"type":"七星彩","issue":"25090","sale_time":" 2025-08-01 10:00:00 ","tickets":[{"red":[1,2,3,4,5,6],"blue":["07"],"multiplier":2}],
+"sections":[{"type":"生肖乐","tickets":[{"red":["01","02"],"blue":[],"multiplier":1}]}]}]` + "\n```"
+	got, err := parseOCRText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Sections) != 1 {
+		t.Fatalf("parseOCRText() = %+v", got)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"数字号码转为两位字符串", strings.Join(got[0].Tickets[0].Red, " "), "01 02 03 04 05 06"},
+		{"销售时间去空白", got[0].SaleTime, "2025-08-01 10:00:00"},
+		{"附加玩法彩种", got[0].Sections[0].Type, "生肖乐"},
+		{"附加玩法号码", strings.Join(got[0].Sections[0].Tickets[0].Red, " "), "01 02"},
+		{"附加玩法未印期号", got[0].Sections[0].Issue, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestVerifyLotterySections(t *testing.T) {
+	loadTestGames(t)
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}},
+		DrawRecord{Game: "七乐彩", Issue: "2025107", Red: []string{"01", "02", "03", "04", "05", "06", "07"}},
+	)
+	main := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	section := func(issue string) LotteryData {
+		return LotteryData{Type: "七乐彩", Issue: issue, Tickets: []UserTicket{
+			{Red: []string{"01", "02", "03", "04", "05", "06", "30"}, Multiplier: 1},
+		}}
+	}
+	tests := []struct {
+		name        string
+		sections    []LotteryData
+		wantTotal   Fen
+		wantPending bool
+	}{
+		{"没有附加玩法", nil, 5 * Yuan, false},
+		{"附加玩法沿用主玩法期号", []LotteryData{section("")}, 5*Yuan + 200*Yuan, false},
+		{"附加玩法尚未开奖", []LotteryData{section("2025108")}, 5 * Yuan, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifyCache.Purge()
+			l := main
+			l.Sections = tt.sections
+			b := newRequestBudget(context.Background())
+			res, err := verifyLottery(b, 0, l)
+			b.Done()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.TotalPrize != tt.wantTotal || res.Pending != tt.wantPending {
+				t.Errorf("verifyLottery() = %s pending %v, want %s pending %v", res.TotalPrize, res.Pending, tt.wantTotal, tt.wantPending)
+			}
+			if len(res.Sections) != len(tt.sections) || len(res.OCRData.Sections) != len(tt.sections) {
+				t.Fatalf("sections = %d, want %d", len(res.Sections), len(tt.sections))
+			}
+			for _, sec := range res.Sections {
+				if sec.Claim != nil {
+					t.Error("section should not carry its own claim guide")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SIGNEDURL: 已保存图片的限时签名链接
+// ==========================================
+
+// 原图和缩略图不直接按记录 ID 公开访问。接口返回的图片链接都带过期时间和签名：
+//
+//	/api/v1/images/<记录ID>/thumbnail?expires=1760000000&sig=…
+//
+// 签名为 HMAC-SHA256(IMAGE_URL_SECRET, "记录ID|类型|过期时间")，有效期 IMAGE_URL_TTL（默认 10m），
+// 过期或签名不符返回 403。<img> 标签带不了请求头，持链接即可访问，所以有效期不宜过长。
+// 多个实例需配置相同的 IMAGE_URL_SECRET；未配置时每次启动随机生成，重启后旧链接失效
+
+const (
+	imageKindOriginal  = "image"
+	imageKindThumbnail = "thumbnail"
+)
+
+var (
+	imageURLSecretOnce sync.Once
+	imageURLSecretKey  []byte
+)
+
+func imageURLSecret() []byte {
+	imageURLSecretOnce.Do(func() {
+		if s := os.Getenv("IMAGE_URL_SECRET"); s != "" {
+			imageURLSecretKey = []byte(s)
+			return
+		}
+		imageURLSecretKey = make([]byte, 32)
+		rand.Read(imageURLSecretKey)
+	})
+	return imageURLSecretKey
+}
+
+func imageURLSignature(id, kind string, expires int64) string {
+	return hex.EncodeToString(hmacSHA256(imageURLSecret(), id+"|"+kind+"|"+strconv.FormatInt(expires, 10)))
+}
+
+// signedImageURL 生成记录图片的签名链接
+func signedImageURL(id, kind string) string {
+	expires := time.Now().Add(envDuration("IMAGE_URL_TTL", 10*time.Minute)).Unix()
+	return "/api/v1/images/" + id + "/" + kind + "?expires=" + strconv.FormatInt(expires, 10) +
+		"&sig=" + imageURLSignature(id, kind, expires)
+}
+
+// signedImageHandler GET /api/v1/images/:id/:kind，只校验签名，不需要租户和用户标识
+func signedImageHandler(c *gin.Context) {
+	id, kind := c.Param("id"), c.Param("kind")
+	if kind != imageKindOriginal && kind != imageKindThumbnail {
+		c.JSON(404, gin.H{"error": "图片不存在"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	remaining := time.Until(time.Unix(expires, 0))
+	if err != nil || remaining <= 0 {
+		c.JSON(403, gin.H{"error": "图片链接已过期"})
+		return
+	}
+	want := imageURLSignature(id, kind, expires)
+	if !hmac.Equal([]byte(c.Query("sig")), []byte(want)) {
+		c.JSON(403, gin.H{"error": "图片链接签名无效"})
+		return
+	}
+	r, ok := scanHistory.Get(id)
+	if !ok {
+		c.JSON(404, gin.H{"error": "记录不存在"})
+		return
+	}
+	path := images.OriginalPath(r.ImageHash)
+	if kind == imageKindThumbnail {
+		path = images.ThumbnailPath(r.ImageHash)
+	}
+	if path == "" {
+		c.JSON(404, gin.H{"error": "图片未保存"})
+		return
+	}
+	data, err := readSealedFile(path)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "读取图片失败: " + err.Error()})
+		return
+	}
+	// 浏览器缓存不超过链接剩余有效期
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(remaining.Seconds())))
+	c.Data(200, http.DetectContentType(data), data)
+}
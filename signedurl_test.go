@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignedImageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  string
+		want time.Duration
+	}{
+		{"默认 10 分钟", "", 10 * time.Minute},
+		{"配置有效期", "30s", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IMAGE_URL_TTL", tt.ttl)
+			u, err := url.Parse(signedImageURL("r1", imageKindThumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Path != "/api/v1/images/r1/thumbnail" {
+				t.Errorf("path = %q", u.Path)
+			}
+			expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+			if d := time.Until(time.Unix(expires, 0)); d > tt.want || d < tt.want-2*time.Second {
+				t.Errorf("expires in %v, want %v", d, tt.want)
+			}
+			if u.Query().Get("sig") != imageURLSignature("r1", imageKindThumbnail, expires) {
+				t.Errorf("sig = %q", u.Query().Get("sig"))
+			}
+		})
+	}
+}
+
+func TestSignedImageHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldHistory, oldImages := scanHistory, images
+	t.Cleanup(func() { scanHistory, images = oldHistory, oldImages })
+
+	const hash = "abcdef0123456789"
+	images = &imageStore{dir: t.TempDir(), enabled: true}
+	writeTestFile(t, images.dir, filepath.Join("ab", hash+".img"), testPNG(t, 4, 4))
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "default", UserID: "u1", ImageHash: hash},
+		{ID: "r2", Tenant: "default", UserID: "u1"},
+	}}
+	r := gin.New()
+	r.GET("/api/v1/images/:id/:kind", signedImageHandler)
+
+	future := time.Now().Add(time.Minute).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+	signed := func(id, kind string, expires int64) string {
+		return "/api/v1/images/" + id + "/" + kind + "?expires=" + strconv.FormatInt(expires, 10) +
+			"&sig=" + imageURLSignature(id, kind, expires)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"签名链接", signedImageURL("r1", imageKindOriginal), 200},
+		{"签名不符", signed("r1", imageKindOriginal, future) + "0", 403},
+		{"换成其他记录", "/api/v1/images/r2/image?expires=" + strconv.FormatInt(future, 10) + "&sig=" + imageURLSignature("r1", imageKindOriginal, future), 403},
+		{"改了过期时间", "/api/v1/images/r1/image?expires=" + strconv.FormatInt(future+3600, 10) + "&sig=" + imageURLSignature("r1", imageKindOriginal, future), 403},
+		{"已过期", signed("r1", imageKindOriginal, past), 403},
+		{"缺少过期时间", "/api/v1/images/r1/image", 403},
+		{"不支持的类型", signed("r1", "raw", future), 404},
+		{"缩略图未生成", signed("r1", imageKindThumbnail, future), 404},
+		{"没有保存图片", signed("r2", imageKindOriginal, future), 404},
+		{"记录不存在", signed("r9", imageKindOriginal, future), 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "image/png" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			maxAge, _ := strconv.Atoi(w.Header().Get("Cache-Control")[len("private, max-age="):])
+			if maxAge <= 0 || maxAge > 600 {
+				t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SIMILAR: 按号码、序列号、图片查找以前的扫描
+// ==========================================
+
+// 排查冒领、同一张票被多人拿来兑奖，或用户投诉“我扫过这张票”时，需要在全部扫描里找相关记录：
+//
+//	GET  /api/v1/admin/scans/similar?scan_id=...   以某条记录为样本，号码、序列号、图片任一相同或相近即返回
+//	GET  /api/v1/admin/scans/similar?serial=...    票面序列号相同
+//	GET  /api/v1/admin/scans/similar?numbers=双色球 02 11 15 21 28 33+07   某注号码相同（写法同 /api/v1/verify/text）
+//	POST /api/v1/admin/scans/similar               上传图片（image 或 upload_id），找同一张图片或外观相近的记录
+//
+// 只查当前租户，不限用户；号码相同不看期号（守号的用户每期都买同一注，结果里带期号以便区分）。
+// 外观相近按感知哈希（dHash）的汉明距离判断，distance 默认同 DUPLICATE_DISTANCE；
+// 版式相同的不同彩票距离也可能很近，需要结合号码人工判断。有多个版本的票只返回一次，以最新的版本为准。
+// 没有用图片向量：重复拍照、翻拍的识别靠 dHash 已经够用。索引在内存中随记录增长逐步补建，不单独落盘
+
+// scanIndex historyStore 记录的查找索引，值为 records 下标；记录只追加，upTo 之前的已建好
+type scanIndex struct {
+	mu      sync.Mutex
+	upTo    int
+	numbers map[string][]int // ticketNumbersKey → 记录
+	serials map[string][]int
+	images  map[string][]int // 图片 SHA256
+	dhashes []indexedDHash
+}
+
+type indexedDHash struct {
+	record int
+	hash   uint64
+}
+
+// ticketNumbersKey 一注号码的归一化写法，如 “双色球 02 11 15 21 28 33 + 07”：
+// 无序玩法的号码排序并补足两位，排列类保持原顺序；胆拖写作 “胆 … 拖 …”
+func ticketNumbersKey(game string, t UserTicket) string {
+	game = canonicalGame(game)
+	ordered := strings.Contains(game, "排列")
+	norm := func(dan, nums []string) string {
+		fmtNums := func(list []string) string {
+			out := make([]string, 0, len(list))
+			for _, n := range list {
+				n = strings.TrimSpace(n)
+				if v, err := strconv.Atoi(n); err == nil && !ordered {
+					n = fmt.Sprintf("%02d", v)
+				}
+				out = append(out, n)
+			}
+			if !ordered {
+				sort.Strings(out)
+			}
+			return strings.Join(out, " ")
+		}
+		if len(dan) > 0 {
+			return "胆 " + fmtNums(dan) + " 拖 " + fmtNums(nums)
+		}
+		return fmtNums(nums)
+	}
+	key := game + " " + norm(t.Dan, t.Red)
+	if len(t.Blue) > 0 || len(t.BlueDan) > 0 {
+		key += " + " + norm(t.BlueDan, t.Blue)
+	}
+	return key
+}
+
+// lotteryKeys 一张票（含附加玩法）的号码与序列号
+func lotteryKeys(l LotteryData, numbers, serials map[string]bool) {
+	for _, t := range l.Tickets {
+		numbers[ticketNumbersKey(l.Type, t)] = true
+	}
+	if s := normalizeSerial(l.Serial); s != "" {
+		serials[s] = true
+	}
+	for _, sec := range l.Sections {
+		lotteryKeys(sec, numbers, serials)
+	}
+}
+
+func normalizeSerial(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// refresh 补建 upTo 之后的记录；调用方持有 historyStore 的读锁
+func (x *scanIndex) refresh(records []ScanRecord) {
+	if x.numbers == nil || len(records) < x.upTo {
+		x.upTo, x.dhashes = 0, nil
+		x.numbers, x.serials, x.images = map[string][]int{}, map[string][]int{}, map[string][]int{}
+	}
+	for i := x.upTo; i < len(records); i++ {
+		r := records[i]
+		numbers, serials := map[string]bool{}, map[string]bool{}
+		for _, l := range r.Lotteries {
+			lotteryKeys(l, numbers, serials)
+		}
+		for k := range numbers {
+			x.numbers[k] = append(x.numbers[k], i)
+		}
+		for k := range serials {
+			x.serials[k] = append(x.serials[k], i)
+		}
+		if r.ImageHash != "" {
+			x.images[r.ImageHash] = append(x.images[r.ImageHash], i)
+		}
+		if r.DHash != 0 {
+			x.dhashes = append(x.dhashes, indexedDHash{record: i, hash: r.DHash})
+		}
+	}
+	x.upTo = len(records)
+}
+
+// similarQuery 查找条件，各项为“或”的关系
+type similarQuery struct {
+	Tenant    string
+	Exclude   string // 样本记录自身（rootID），不出现在结果中
+	Numbers   map[string]bool
+	Serials   map[string]bool
+	ImageHash string
+	DHash     uint64
+	Distance  int
+}
+
+// SimilarMatch 一条命中的记录
+type SimilarMatch struct {
+	ScanID     string        `json:"scan_id"`
+	UserID     string        `json:"user_id,omitempty"`
+	DeviceID   string        `json:"device_id,omitempty"`
+	Time       time.Time     `json:"time"`
+	MatchedBy  []string      `json:"matched_by"`         // numbers / serial / image / image_similar
+	Distance   *int          `json:"distance,omitempty"` // image_similar 时的汉明距离
+	Numbers    []string      `json:"numbers,omitempty"`  // 相同的号码（归一化写法）
+	Lotteries  []LotteryData `json:"lotteries"`
+	OriginalID string        `json:"original_id,omitempty"`
+	Version    int           `json:"version,omitempty"`
+	ImageURL   string        `json:"image_url,omitempty"`
+}
+
+// Similar 按时间从新到旧返回命中的记录，最多 limit 条
+func (s *historyStore) Similar(q similarQuery, limit int) []SimilarMatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.index.mu.Lock()
+	defer s.index.mu.Unlock()
+	s.index.refresh(s.records)
+
+	// 同一张票的多个版本合并为一条，命中条件累加，展示最新的版本
+	byRoot := map[string]*SimilarMatch{}
+	latest := map[string]int{}
+	hit := func(i int, by string) *SimilarMatch {
+		r := s.records[i]
+		if r.Tenant != q.Tenant || r.rootID() == q.Exclude {
+			return &SimilarMatch{}
+		}
+		m := byRoot[r.rootID()]
+		if m == nil {
+			m = &SimilarMatch{}
+			byRoot[r.rootID()] = m
+		}
+		if j, ok := latest[r.rootID()]; !ok || i > j {
+			latest[r.rootID()] = i
+		}
+		m.MatchedBy = appendOnce(m.MatchedBy, by)
+		return m
+	}
+	for k := range q.Numbers {
+		for _, i := range s.index.numbers[k] {
+			m := hit(i, "numbers")
+			m.Numbers = appendOnce(m.Numbers, k)
+		}
+	}
+	for k := range q.Serials {
+		for _, i := range s.index.serials[k] {
+			hit(i, "serial")
+		}
+	}
+	if q.ImageHash != "" {
+		for _, i := range s.index.images[q.ImageHash] {
+			hit(i, "image")
+		}
+	}
+	if q.DHash != 0 && q.Distance > 0 {
+		for _, d := range s.index.dhashes {
+			if s.records[d.record].ImageHash == q.ImageHash {
+				continue
+			}
+			if dist := bits.OnesCount64(d.hash ^ q.DHash); dist <= q.Distance {
+				if m := hit(d.record, "image_similar"); m.Distance == nil || dist < *m.Distance {
+					m.Distance = &dist
+				}
+			}
+		}
+	}
+
+	out := make([]SimilarMatch, 0, len(byRoot))
+	for root, m := range byRoot {
+		r := s.records[latest[root]]
+		m.ScanID, m.UserID, m.DeviceID, m.Time, m.Lotteries = r.ID, r.UserID, r.DeviceID, r.Time, r.Lotteries
+		m.OriginalID, m.Version = r.OriginalID, r.Version
+		sort.Strings(m.MatchedBy)
+		sort.Strings(m.Numbers)
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// similarHandler GET/POST /api/v1/admin/scans/similar，参数见文件开头
+func similarHandler(c *gin.Context) {
+	q := similarQuery{Tenant: tenantOf(c), Numbers: map[string]bool{}, Serials: map[string]bool{}, Distance: duplicateDistance()}
+	if v, err := strconv.Atoi(c.Query("distance")); err == nil && v >= 0 && v <= 64 {
+		q.Distance = v
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 200 {
+		limit = v
+	}
+
+	if id := c.Query("scan_id"); id != "" {
+		rec, ok := scanHistory.Get(id)
+		if !ok || rec.Tenant != q.Tenant {
+			c.JSON(404, gin.H{"error": "记录不存在"})
+			return
+		}
+		q.Exclude, q.ImageHash, q.DHash = rec.rootID(), rec.ImageHash, rec.DHash
+		for _, l := range rec.Lotteries {
+			lotteryKeys(l, q.Numbers, q.Serials)
+		}
+	}
+	if v := normalizeSerial(c.Query("serial")); v != "" {
+		q.Serials[v] = true
+	}
+	if v := strings.TrimSpace(c.Query("numbers")); v != "" {
+		l, err := parseTypedTicket(v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无法解析号码: " + err.Error()})
+			return
+		}
+		lotteryKeys(LotteryData{Type: l.Type, Tickets: l.Tickets}, q.Numbers, q.Serials)
+	}
+	if c.Request.Method == "POST" {
+		fileBytes, ok := scanImageOf(c.Request.Context(), c)
+		if !ok {
+			return
+		}
+		q.ImageHash = imageHash(fileBytes)
+		q.DHash, _ = perceptualHash(fileBytes)
+	}
+	if len(q.Numbers) == 0 && len(q.Serials) == 0 && q.ImageHash == "" {
+		c.JSON(400, gin.H{"error": "缺少查找条件"})
+		return
+	}
+
+	matches := scanHistory.Similar(q, limit)
+	for i := range matches {
+		if rec, ok := scanHistory.Get(matches[i].ScanID); ok && images.OriginalPath(rec.ImageHash) != "" {
+			matches[i].ImageURL = signedImageURL(rec.ID, imageKindOriginal)
+		}
+	}
+	c.JSON(200, gin.H{"count": len(matches), "matches": matches})
+}
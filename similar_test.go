@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTicketNumbersKey(t *testing.T) {
+	tests := []struct {
+		name string
+		game string
+		t    UserTicket
+		want string
+	}{
+		{"排序并补足两位", "双色球", UserTicket{Red: []string{"33", "2", "11", "15", "21", "28"}, Blue: []string{"7"}}, "双色球 02 11 15 21 28 33 + 07"},
+		{"玩法别名", "ssq", UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}, "双色球 02 11 15 21 28 33 + 07"},
+		{"排列类保持顺序", "排列3", UserTicket{Red: []string{"3", "0", "1"}}, "排列3 3 0 1"},
+		{"胆拖", "大乐透", UserTicket{Dan: []string{"5"}, Red: []string{"12", "3", "20", "31", "33"}, BlueDan: []string{"2"}, Blue: []string{"11", "9"}}, "大乐透 胆 05 拖 03 12 20 31 33 + 胆 02 拖 09 11"},
+		{"没有蓝球", "快乐8", UserTicket{Red: []string{"10", "1"}}, "快乐8 01 10"},
+	}
+	for _, tt := range tests {
+		if got := ticketNumbersKey(tt.game, tt.t); got != tt.want {
+			t.Errorf("%s: ticketNumbersKey() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// similarTestRecords a1 与 a2 号码相同（不同期），a3 序列号与 a1 相同（写法不同）且有新版本 a3v，
+// a4 图片与 a1 相同，a5 外观与 a1 相近，b1 属于其他租户
+func similarTestRecords(hash string, dhash uint64) []ScanRecord {
+	now := time.Now()
+	ssq := func(issue, serial, blue string) []LotteryData {
+		return []LotteryData{{Type: "双色球", Issue: issue, Serial: serial, Tickets: []UserTicket{
+			{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{blue}},
+		}}}
+	}
+	return []ScanRecord{
+		{ID: "a1", Tenant: "shop-a", UserID: "u1", Time: now.Add(-5 * time.Hour), ImageHash: hash, DHash: dhash, Lotteries: ssq("2025107", "SN 0001", "07")},
+		{ID: "a2", Tenant: "shop-a", UserID: "u2", Time: now.Add(-4 * time.Hour), Lotteries: ssq("2025108", "", "07")},
+		{ID: "a3", Tenant: "shop-a", UserID: "u3", Time: now.Add(-3 * time.Hour), Lotteries: ssq("2025107", "sn0001", "09")},
+		{ID: "a3v", Tenant: "shop-a", UserID: "u3", Time: now.Add(-2 * time.Hour), Lotteries: ssq("2025107", "sn0001", "10"), OriginalID: "a3", Version: 2},
+		{ID: "a4", Tenant: "shop-a", UserID: "u4", Time: now.Add(-time.Hour), ImageHash: hash, DHash: dhash, Lotteries: ssq("2025107", "", "12")},
+		{ID: "a5", Tenant: "shop-a", UserID: "u5", Time: now, ImageHash: "other", DHash: dhash ^ 0b111, Lotteries: ssq("2025107", "", "13")},
+		{ID: "b1", Tenant: "shop-b", UserID: "u6", Time: now, ImageHash: hash, Lotteries: ssq("2025107", "SN0001", "07")},
+	}
+}
+
+func TestHistoryStoreSimilar(t *testing.T) {
+	const dhash = uint64(0xf0f0)
+	s := &historyStore{records: similarTestRecords("h1", dhash)}
+	key := "双色球 02 11 15 21 28 33 + 07"
+
+	tests := []struct {
+		name  string
+		q     similarQuery
+		limit int
+		want  string // 记录:命中条件，按时间从新到旧
+	}{
+		{"号码相同不看期号", similarQuery{Numbers: map[string]bool{key: true}}, 50, "a2:numbers,a1:numbers"},
+		{"序列号写法不同", similarQuery{Serials: map[string]bool{"SN0001": true}}, 50, "a3v:serial,a1:serial"},
+		{"图片相同", similarQuery{ImageHash: "h1"}, 50, "a4:image,a1:image"},
+		{"外观相近", similarQuery{ImageHash: "h1", DHash: dhash, Distance: 3}, 50, "a5:image_similar,a4:image,a1:image"},
+		{"超出距离", similarQuery{ImageHash: "h1", DHash: dhash, Distance: 2}, 50, "a4:image,a1:image"},
+		{"排除样本自身，多个条件合并", similarQuery{Exclude: "a1", Numbers: map[string]bool{key: true}, Serials: map[string]bool{"SN0001": true}, ImageHash: "h1"}, 50, "a4:image,a3v:serial,a2:numbers"},
+		{"最多 limit 条", similarQuery{ImageHash: "h1", DHash: dhash, Distance: 3}, 1, "a5:image_similar"},
+		{"其他租户", similarQuery{Tenant: "shop-c", ImageHash: "h1"}, 50, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.q.Tenant == "" {
+				tt.q.Tenant = "shop-a"
+			}
+			var got []string
+			for _, m := range s.Similar(tt.q, tt.limit) {
+				got = append(got, m.ScanID+":"+strings.Join(m.MatchedBy, "+"))
+				if m.ScanID == "a5" && (m.Distance == nil || *m.Distance != 3) {
+					t.Errorf("a5 distance = %v", m.Distance)
+				}
+				if m.ScanID == "a3v" && (m.OriginalID != "a3" || m.Version != 2) {
+					t.Errorf("a3v = %+v", m)
+				}
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("Similar() = %s, want %s", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+
+	// 新增的记录补建进索引
+	s.records = append(s.records, ScanRecord{ID: "a6", Tenant: "shop-a", Time: time.Now().Add(time.Hour), ImageHash: "h1"})
+	if got := s.Similar(similarQuery{Tenant: "shop-a", ImageHash: "h1"}, 50); len(got) != 3 || got[0].ScanID != "a6" {
+		t.Errorf("after append: %+v", got)
+	}
+}
+
+func TestSimilarHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("DUPLICATE_DISTANCE", "3")
+	// 从左到右渐暗，dHash 不为 0
+	img := image.NewGray(image.Rect(0, 0, 36, 32))
+	for x := 0; x < 36; x++ {
+		for y := 0; y < 32; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(255 - x*7)})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	upload := buf.Bytes()
+	dhash, _ := perceptualHash(upload)
+	if dhash == 0 {
+		t.Fatal("dhash = 0")
+	}
+	oldHistory, oldImages := scanHistory, images
+	t.Cleanup(func() { scanHistory, images = oldHistory, oldImages })
+	scanHistory = &historyStore{records: similarTestRecords(imageHash(upload), dhash)}
+	images = &imageStore{}
+
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		tenant     string
+		wantStatus int
+		want       string
+	}{
+		{"以记录为样本", "GET", "?scan_id=a1", "", 200, "a5,a4,a3v,a2"},
+		{"样本的新版本同样排除原记录", "GET", "?scan_id=a3v&distance=0", "", 200, "a1"},
+		{"按序列号", "GET", "?serial=sn 0001", "", 200, "a3v,a1"},
+		{"按号码", "GET", "?numbers=双色球 33 28 21 15 11 02+07", "", 200, "a2,a1"},
+		{"限制条数", "GET", "?scan_id=a1&limit=2", "", 200, "a5,a4"},
+		{"上传图片", "POST", "", "", 200, "a5,a4,a1"},
+		{"上传图片并指定距离", "POST", "?distance=0", "", 200, "a4,a1"},
+		{"其他租户的记录", "GET", "?scan_id=a1", "shop-b", 404, ""},
+		{"号码无法解析", "GET", "?numbers=看不清", "", 400, ""},
+		{"缺少查找条件", "GET", "", "", 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			if tt.method == "POST" {
+				c.Request = scanUploadRequest(t, "", "", upload)
+				c.Request.URL.RawQuery = strings.TrimPrefix(tt.query, "?")
+			} else {
+				c.Request = httptest.NewRequest("GET", "/api/v1/admin/scans/similar", nil)
+				q := c.Request.URL.Query()
+				for _, kv := range strings.Split(strings.TrimPrefix(tt.query, "?"), "&") {
+					if k, v, ok := strings.Cut(kv, "="); ok {
+						q.Set(k, v)
+					}
+				}
+				c.Request.URL.RawQuery = q.Encode()
+			}
+			tenant := tt.tenant
+			if tenant == "" {
+				tenant = "shop-a"
+			}
+			c.Request.Header.Set("X-Tenant-ID", tenant)
+			similarHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Count   int            `json:"count"`
+				Matches []SimilarMatch `json:"matches"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, m := range resp.Matches {
+				ids = append(ids, m.ScanID)
+			}
+			if strings.Join(ids, ",") != tt.want || resp.Count != len(ids) {
+				t.Errorf("matches = %s (count %d), want %s", strings.Join(ids, ","), resp.Count, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SPEND: 每日 AI 识别预算
+// ==========================================
+
+// 按自然日控制识别服务的 Token 用量和费用，避免月底收到意外账单：
+//
+//	OCR_DAILY_TOKEN_BUDGET   每日 Token 上限，0 表示不限
+//	OCR_DAILY_SPEND_BUDGET   每日费用上限（元），0 表示不限；费用按 OCR_MODEL_PRICES 估算
+//	OCR_MODEL_PRICES         每百万 Token 的 输入/输出 单价（元），如 gemini-2.5-flash=2.2/18,gpt-4o-mini=1.1/4.3
+//	OCR_BUDGET_ACTION        超出后：reject 拒绝识别（返回 BUDGET_EXCEEDED），downgrade 改用 OCR_FALLBACK_MODEL；
+//	                         不设置时只发运维告警，与以前相同
+//	OCR_FALLBACK_MODEL       降级使用的模型，默认 gemini-2.5-flash-lite
+//
+// 命中识别缓存的请求不消耗预算，超出后仍然可用
+
+const (
+	budgetReject    = "reject"
+	budgetDowngrade = "downgrade"
+)
+
+// errBudgetExceeded 当日预算已用完且配置为拒绝
+var errBudgetExceeded = errors.New("今日 AI 识别预算已用完，请明天再试")
+
+// defaultModelPrices 每百万 Token 输入/输出单价（元），按官方美元价折算
+var defaultModelPrices = map[string][2]float64{
+	"gemini-2.5-flash":      {2.2, 18},
+	"gemini-2.5-flash-lite": {0.7, 2.9},
+	"gpt-4o-mini":           {1.1, 4.3},
+}
+
+func modelPrices() map[string][2]float64 {
+	prices := map[string][2]float64{}
+	for k, v := range defaultModelPrices {
+		prices[k] = v
+	}
+	for _, pair := range strings.Split(os.Getenv("OCR_MODEL_PRICES"), ",") {
+		model, price, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		in, out, _ := strings.Cut(price, "/")
+		pin, err1 := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		pout, err2 := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err1 != nil || err2 != nil {
+			log.Printf("OCR_MODEL_PRICES 中 %q 格式错误，应为 模型=输入/输出", pair)
+			continue
+		}
+		prices[strings.TrimSpace(model)] = [2]float64{pin, pout}
+	}
+	return prices
+}
+
+// modelCost 一次调用的估算费用（元）；没有配置单价的模型按 0 计
+func modelCost(model string, prompt, output int64) float64 {
+	p, ok := modelPrices()[model]
+	if !ok {
+		return 0
+	}
+	return (float64(prompt)*p[0] + float64(output)*p[1]) / 1e6
+}
+
+func dailySpendBudget() float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(os.Getenv("OCR_DAILY_SPEND_BUDGET")), 64)
+	return v
+}
+
+// budgetExceeded 今日 Token 或费用是否已达上限
+func budgetExceeded() bool {
+	if limit := int64(envInt("OCR_DAILY_TOKEN_BUDGET", 0)); limit > 0 && tokenUsage.Today() >= limit {
+		return true
+	}
+	if limit := dailySpendBudget(); limit > 0 && tokenUsage.SpendToday() >= limit {
+		return true
+	}
+	return false
+}
+
+func budgetAction() string { return strings.ToLower(strings.TrimSpace(os.Getenv("OCR_BUDGET_ACTION"))) }
+
+// checkSpendBudget 每次调用识别服务前检查；只有配置为 reject 时才拒绝
+func checkSpendBudget() error {
+	if budgetAction() == budgetReject && budgetExceeded() {
+		return errBudgetExceeded
+	}
+	return nil
+}
+
+// ocrModel 本次调用使用的模型：超出预算且配置为 downgrade 时改用便宜的模型
+func ocrModel() string {
+	if budgetAction() == budgetDowngrade && budgetExceeded() {
+		if m := strings.TrimSpace(os.Getenv("OCR_FALLBACK_MODEL")); m != "" {
+			return m
+		}
+		return "gemini-2.5-flash-lite"
+	}
+	return GEMINI_MODEL
+}
+
+type ocrModelKey struct{}
+
+// withOCRModel 指定本次识别使用的模型（重新识别时由调用方选择），沿 recognizeCached 传递
+func withOCRModel(ctx context.Context, model string) context.Context {
+	if model == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ocrModelKey{}, model)
+}
+
+// ocrModelFor 调用方指定的模型优先，否则同 ocrModel
+func ocrModelFor(ctx context.Context) string {
+	if m, ok := ctx.Value(ocrModelKey{}).(string); ok {
+		return m
+	}
+	return ocrModel()
+}
+
+// Load 读取当日累计用量，文件不存在时从零开始
+func (t *tokenCounter) Load(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.path = path
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, t); err != nil {
+		return err
+	}
+	t.rollover()
+	return nil
+}
+
+// save 调用方需持有锁；写失败只记日志
+func (t *tokenCounter) save() {
+	if t.path == "" {
+		return
+	}
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(t.path+".tmp", raw, 0o644); err != nil {
+		log.Printf("保存 Token 用量失败: %v", err)
+		return
+	}
+	if err := os.Rename(t.path+".tmp", t.path); err != nil {
+		log.Printf("保存 Token 用量失败: %v", err)
+	}
+}
+
+// usageHandler GET /api/v1/admin/usage 当日用量、预算与当前使用的模型
+func usageHandler(c *gin.Context) {
+	tokenUsage.mu.Lock()
+	tokenUsage.rollover()
+	usage := gin.H{
+		"day": tokenUsage.Day, "prompt_tokens": tokenUsage.Prompt, "output_tokens": tokenUsage.Output,
+		"total_tokens": tokenUsage.Total, "spend": tokenUsage.Spend,
+	}
+	tokenUsage.mu.Unlock()
+	c.JSON(200, gin.H{
+		"usage":         usage,
+		"token_budget":  envInt("OCR_DAILY_TOKEN_BUDGET", 0),
+		"spend_budget":  dailySpendBudget(),
+		"budget_action": budgetAction(),
+		"exceeded":      budgetExceeded(),
+		"model":         ocrModel(),
+	})
+}
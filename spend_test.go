@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestTokenUsage 换成空的用量计数，测试结束后恢复
+func useTestTokenUsage(t *testing.T) {
+	t.Helper()
+	old := tokenUsage
+	t.Cleanup(func() { tokenUsage = old })
+	tokenUsage = &tokenCounter{}
+}
+
+func TestModelCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices string
+		model  string
+		want   float64
+	}{
+		{"内置单价", "", "gemini-2.5-flash", 2.2 + 18},
+		{"配置覆盖内置单价", "gemini-2.5-flash=1/2", "gemini-2.5-flash", 3},
+		{"配置新增模型", "my-model = 10/20 ", "my-model", 30},
+		{"格式错误的配置忽略", "gemini-2.5-flash=x/2", "gemini-2.5-flash", 2.2 + 18},
+		{"没有单价按 0 计", "", "unknown", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OCR_MODEL_PRICES", tt.prices)
+			if got := modelCost(tt.model, 1e6, 1e6); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("modelCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpendBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokens       string
+		spend        string
+		action       string
+		fallback     string
+		wantExceeded bool
+		wantErr      bool
+		wantModel    string
+	}{
+		{"不限", "", "", budgetReject, "", false, false, GEMINI_MODEL},
+		{"Token 未到上限", "2000", "", budgetReject, "", false, false, GEMINI_MODEL},
+		{"Token 超出时拒绝", "1000", "", budgetReject, "", true, true, GEMINI_MODEL},
+		{"费用超出时拒绝", "", "0.5", budgetReject, "", true, true, GEMINI_MODEL},
+		{"超出后降级", "1000", "", budgetDowngrade, "", true, false, "gemini-2.5-flash-lite"},
+		{"降级到指定模型", "1000", "", budgetDowngrade, "gpt-4o-mini", true, false, "gpt-4o-mini"},
+		{"未配置动作只告警", "1000", "", "", "", true, false, GEMINI_MODEL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestTokenUsage(t)
+			t.Setenv("OCR_MODEL_PRICES", "")
+			t.Setenv("OCR_DAILY_TOKEN_BUDGET", tt.tokens)
+			t.Setenv("OCR_DAILY_SPEND_BUDGET", tt.spend)
+			t.Setenv("OCR_BUDGET_ACTION", tt.action)
+			t.Setenv("OCR_FALLBACK_MODEL", tt.fallback)
+			// 计 1000 Token，按 gemini-2.5-flash 单价约 10.1 元
+			tokenUsage.Add("gemini-2.5-flash", 500000, 500000, 1000)
+
+			if got := budgetExceeded(); got != tt.wantExceeded {
+				t.Errorf("budgetExceeded() = %v, want %v", got, tt.wantExceeded)
+			}
+			if err := checkSpendBudget(); (err != nil) != tt.wantErr {
+				t.Errorf("checkSpendBudget() = %v, want error %v", err, tt.wantErr)
+			}
+			if got := ocrModel(); got != tt.wantModel {
+				t.Errorf("ocrModel() = %q, want %q", got, tt.wantModel)
+			}
+			// 调用方指定的模型不受降级影响
+			if got := ocrModelFor(withOCRModel(context.Background(), "pinned")); got != "pinned" {
+				t.Errorf("ocrModelFor() = %q, want pinned", got)
+			}
+		})
+	}
+}
+
+func TestTokenCounterLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		file      string
+		wantTotal int64
+		wantErr   bool
+	}{
+		{"文件不存在从零开始", "", 0, false},
+		{"当日用量", fmt.Sprintf(`{"day": %q, "total_tokens": 1200, "spend": 1.5}`, time.Now().Format("2006-01-02")), 1200, false},
+		{"昨天的用量清零", `{"day": "2000-01-01", "total_tokens": 1200}`, 0, false},
+		{"格式错误", `{`, 0, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("usage-%d.json", i))
+			if tt.file != "" {
+				writeTestFile(t, dir, filepath.Base(path), []byte(tt.file))
+			}
+			c := &tokenCounter{}
+			err := c.Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && c.Today() != tt.wantTotal {
+				t.Errorf("Today() = %d, want %d", c.Today(), tt.wantTotal)
+			}
+		})
+	}
+
+	// 累计后落盘，重启后继续累计
+	path := filepath.Join(dir, "persist.json")
+	c := &tokenCounter{}
+	if err := c.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	c.Add("gemini-2.5-flash", 100, 50, 150)
+	reloaded := &tokenCounter{}
+	if err := reloaded.Load(path); err != nil || reloaded.Today() != 150 || reloaded.SpendToday() <= 0 {
+		t.Errorf("reloaded = %d tokens, %v spend, %v", reloaded.Today(), reloaded.SpendToday(), err)
+	}
+}
+
+// 预算用完返回 429 和 BUDGET_EXCEEDED，客户端据此提示明天再试
+func TestRespondStageErrorBudget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"预算用完", errBudgetExceeded, 429, "BUDGET_EXCEEDED"},
+		{"包装后的预算错误", fmt.Errorf("识别失败: %w", errBudgetExceeded), 429, "BUDGET_EXCEEDED"},
+		{"其他错误", fmt.Errorf("boom"), 500, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan", nil)
+			respondStageError(c, "AI 识别失败: ", tt.err)
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantCode) {
+				t.Errorf("status = %d body = %s, want %d %s", w.Code, w.Body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
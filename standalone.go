@@ -0,0 +1,58 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// STANDALONE: 单机模式与内置网页
+// ==========================================
+
+// 门店自己部署时只需要一个可执行文件和一个 Gemini API Key：
+//
+//	./lottery-server -standalone -api-key <GEMINI_API_KEY>
+//
+// 然后用浏览器打开 http://<本机地址>:8080/ 拍照验奖。单机模式在对应环境变量未设置时采用以下默认值：
+//
+//	HISTORY_STORE=sqlite   扫描记录写入 data/history.db（嵌入式 SQLite，无需安装数据库）
+//	STORE_IMAGES=true      原图与缩略图保存在 data/images
+//	STATE_BACKEND=memory   缓存、限流等状态留在进程内，不需要 Redis
+//	JOBS_BACKEND=file      排队任务保存在 data/jobs
+//
+// 已显式设置的环境变量不受影响。内置网页在任何模式下都由 / 提供，/admin 为运营看板（需管理员令牌）
+
+//go:embed web
+var webAssets embed.FS
+
+var standaloneDefaults = map[string]string{
+	"HISTORY_STORE": "sqlite",
+	"STORE_IMAGES":  "true",
+	"STATE_BACKEND": "memory",
+	"JOBS_BACKEND":  "file",
+}
+
+// applyStandaloneDefaults 为未设置的环境变量填入单机模式的默认值，需在读取配置之前调用
+func applyStandaloneDefaults() {
+	for k, v := range standaloneDefaults {
+		if _, ok := os.LookupEnv(k); !ok {
+			os.Setenv(k, v)
+		}
+	}
+	log.Printf("单机模式：数据保存在 %s，浏览器打开 http://localhost%s/ 即可使用", dataDir(), listenAddr)
+}
+
+// registerWebUI 内置网页：拍照上传并以卡片形式展示验奖结果；/admin 为运营看板
+func registerWebUI(r *gin.Engine) {
+	sub, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		log.Fatalf("加载内置网页失败: %v", err)
+	}
+	r.GET("/", func(c *gin.Context) { c.FileFromFS("/", http.FS(sub)) })
+	r.GET("/admin", func(c *gin.Context) { c.FileFromFS("admin.html", http.FS(sub)) })
+}
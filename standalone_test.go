@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单机模式只为未设置的环境变量填默认值
+func TestApplyStandaloneDefaults(t *testing.T) {
+	for k := range standaloneDefaults {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+	t.Setenv("STATE_BACKEND", "redis")
+	t.Setenv("STORE_IMAGES", "")
+
+	applyStandaloneDefaults()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"HISTORY_STORE", "sqlite"},
+		{"JOBS_BACKEND", "file"},
+		{"STATE_BACKEND", "redis"},
+		{"STORE_IMAGES", ""},
+	}
+	for _, tt := range tests {
+		if got := os.Getenv(tt.key); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterWebUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerWebUI(r)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/", 200, "<html"},
+		{"/admin", 200, "<html"},
+		{"/index.html", 404, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("GET %s = %d, want %d containing %q", tt.path, w.Code, tt.wantStatus, tt.wantBody)
+		}
+		if w.Code == 200 && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("GET %s Content-Type = %q", tt.path, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// STATION: 销售站点归属
+// ==========================================
+
+// 连锁投注站往往多家门店共用一个服务端。票面底部印有销售站点编号（如 “站号: 44010123”），
+// 识别后写入 LotteryData.Station，随验奖结果返回并记入扫描历史；
+// GET /api/v1/stations/:id/report 按天汇总该站点的扫描、中奖和派奖金额，可导出 CSV 用于结算
+
+// normalizeStation 清洗模型返回的站点编号：去掉“站号”等前缀和空格，只保留字母、数字和连字符
+func normalizeStation(v interface{}) string {
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case float64:
+		s = strconv.FormatInt(int64(x), 10)
+	default:
+		return ""
+	}
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || r == '-' {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Trim(sb.String(), "-")
+}
+
+// StationDay 某站点某天的汇总；同一张票重复扫描只计一次，中奖金额按最新开奖数据计算
+type StationDay struct {
+	Date           string `json:"date"`
+	Scans          int    `json:"scans"`
+	Tickets        int    `json:"tickets"`
+	WinningTickets int    `json:"winning_tickets"`
+	Pending        int    `json:"pending"` // 尚未开奖的票数
+	Spent          Fen    `json:"spent_fen"`
+	Payout         Fen    `json:"payout_fen"`
+}
+
+// StationRecords 某租户下含有该站点票据、扫描时间在 [from, to) 内的记录
+func (s *historyStore) StationRecords(tenant, station string, from, to time.Time) []ScanRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []ScanRecord
+	for _, r := range s.records {
+		if r.Tenant != tenant || r.Time.Before(from) || !r.Time.Before(to) {
+			continue
+		}
+		for _, l := range r.Lotteries {
+			if l.Station == station {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// stationReportRange 读取 ?date=2025-09-16 或 ?from=&to=（含两端，最多 92 天），默认今天（北京时间）
+func stationReportRange(c *gin.Context) (from, to time.Time, err error) {
+	parse := func(name, v string) (time.Time, error) {
+		t, err := time.ParseInLocation("2006-01-02", v, chinaTime)
+		if err != nil {
+			return t, fmt.Errorf("%s 格式应为 2006-01-02", name)
+		}
+		return t, nil
+	}
+	if v := c.Query("date"); v != "" {
+		if from, err = parse("date", v); err != nil {
+			return
+		}
+		return from, from.AddDate(0, 0, 1), nil
+	}
+	if c.Query("from") == "" && c.Query("to") == "" {
+		now := time.Now().In(chinaTime)
+		from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, chinaTime)
+		return from, from.AddDate(0, 0, 1), nil
+	}
+	if from, err = parse("from", c.Query("from")); err != nil {
+		return
+	}
+	if to, err = parse("to", c.Query("to")); err != nil {
+		return
+	}
+	to = to.AddDate(0, 0, 1)
+	if !to.After(from) {
+		return from, to, fmt.Errorf("to 不能早于 from")
+	}
+	if to.Sub(from) > 92*24*time.Hour {
+		return from, to, fmt.Errorf("查询范围最多 92 天")
+	}
+	return from, to, nil
+}
+
+// stationReport 按扫描日期汇总，没有扫描的日期也给出一行，便于对账
+func stationReport(tenant, station string, from, to time.Time) (days []StationDay, total StationDay) {
+	byDate := map[string]*StationDay{}
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, StationDay{Date: d.Format("2006-01-02")})
+	}
+	for i := range days {
+		byDate[days[i].Date] = &days[i]
+	}
+	seen := map[string]bool{}
+	for _, r := range scanHistory.StationRecords(tenant, station, from, to) {
+		day := byDate[r.Time.In(chinaTime).Format("2006-01-02")]
+		if day == nil {
+			continue
+		}
+		day.Scans++
+		for _, l := range r.Lotteries {
+			key := verifyCacheKey(l)
+			if l.Station != station || seen[key] {
+				continue
+			}
+			seen[key] = true
+			spent, won, pending, ok := ticketOutcome(l)
+			if !ok {
+				continue
+			}
+			day.Tickets++
+			day.Spent += spent
+			day.Payout += won
+			if won > 0 {
+				day.WinningTickets++
+			}
+			if pending {
+				day.Pending++
+			}
+		}
+	}
+	total.Date = "total"
+	for _, d := range days {
+		total.Scans += d.Scans
+		total.Tickets += d.Tickets
+		total.WinningTickets += d.WinningTickets
+		total.Pending += d.Pending
+		total.Spent += d.Spent
+		total.Payout += d.Payout
+	}
+	return days, total
+}
+
+// stationReportHandler GET /api/v1/stations/:id/report?date=2025-09-16，?format=csv 导出结算用的 CSV
+func stationReportHandler(c *gin.Context) {
+	station := normalizeStation(c.Param("id"))
+	if station == "" {
+		c.JSON(400, gin.H{"error": "站点编号无效"})
+		return
+	}
+	from, to, err := stationReportRange(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	days, total := stationReport(tenantOf(c), station, from, to)
+
+	if c.Query("format") != "csv" {
+		c.JSON(200, gin.H{"station": station, "days": days, "total": total})
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff") // Excel 按 UTF-8 打开
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"日期", "站点", "扫描次数", "票数", "中奖票数", "未开奖", "投注金额(元)", "中奖金额(元)"})
+	total.Date = "合计"
+	for _, d := range append(days, total) {
+		w.Write([]string{
+			d.Date, station, strconv.Itoa(d.Scans), strconv.Itoa(d.Tickets), strconv.Itoa(d.WinningTickets),
+			strconv.Itoa(d.Pending), d.Spent.Decimal(), d.Payout.Decimal(),
+		})
+	}
+	w.Flush()
+	name := fmt.Sprintf("station-%s-%s.csv", station, from.Format("20060102"))
+	if len(days) > 1 {
+		name = fmt.Sprintf("station-%s-%s-%s.csv", station, from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"))
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeStation(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"44010123", "44010123"},
+		{"站号: 4401 0123", "44010123"},
+		{"投注站 gd-0123", "GD-0123"},
+		{"-0123-", "0123"},
+		{float64(44010123), "44010123"},
+		{"看不清", ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeStation(tt.in); got != tt.want {
+			t.Errorf("normalizeStation(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 站点编号随识别结果进入扫描历史，一次扫描多张票时取第一个有编号的
+func TestHistoryStation(t *testing.T) {
+	tests := []struct {
+		name     string
+		stations []string
+		want     string
+	}{
+		{"单张票", []string{"44010123"}, "44010123"},
+		{"第一张没有编号", []string{"", "44010124"}, "44010124"},
+		{"都没有", []string{""}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &historyStore{}
+			var results []VerificationResult
+			for _, st := range tt.stations {
+				results = append(results, VerificationResult{OCRData: LotteryData{Type: "双色球", Issue: "2025107", Station: st}})
+			}
+			s.Add(ScanOrigin{Tenant: "shop-a"}, nil, results)
+			if len(s.records) != 1 || s.records[0].Station != tt.want {
+				t.Errorf("records = %+v, want station %q", s.records, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOCRTextStation(t *testing.T) {
+	tests := []struct {
+		name, raw, want string
+	}{
+		{"字符串", `"station": "站号 44010123"`, "44010123"},
+		{"数字", `"station": 44010123`, "44010123"},
+		{"未识别", `"station": ""`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOCRText(`[{"type": "双色球", "issue": "2025107", ` + tt.raw + `, "tickets": []}]`)
+			if err != nil || len(got) != 1 {
+				t.Fatalf("parseOCRText() = %+v, %v", got, err)
+			}
+			if got[0].Station != tt.want {
+				t.Errorf("Station = %q, want %q", got[0].Station, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationReportRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	today := time.Now().In(chinaTime).Format("2006-01-02")
+	tests := []struct {
+		name     string
+		query    string
+		wantFrom string
+		wantDays int
+		wantErr  string
+	}{
+		{"默认今天", "", today, 1, ""},
+		{"指定日期", "?date=2025-09-16", "2025-09-16", 1, ""},
+		{"日期区间含两端", "?from=2025-09-01&to=2025-09-30", "2025-09-01", 30, ""},
+		{"日期格式错误", "?date=2025/09/16", "", 0, "date 格式应为"},
+		{"缺少 to", "?from=2025-09-01", "", 0, "to 格式应为"},
+		{"to 早于 from", "?from=2025-09-02&to=2025-09-01", "", 0, "to 不能早于 from"},
+		{"超过 92 天", "?from=2025-01-01&to=2025-06-30", "", 0, "最多 92 天"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/v1/stations/1/report"+tt.query, nil)
+			from, to, err := stationReportRange(c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("stationReportRange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := from.Format("2006-01-02"); got != tt.wantFrom {
+				t.Errorf("from = %s, want %s", got, tt.wantFrom)
+			}
+			if days := int(to.Sub(from).Hours() / 24); days != tt.wantDays {
+				t.Errorf("range = %d days, want %d", days, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestStationReportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	old := scanHistory
+	t.Cleanup(func() { scanHistory = old })
+
+	ticket := func(station, issue string, blue string) LotteryData {
+		return LotteryData{Type: "双色球", Issue: issue, Station: station,
+			Tickets: []UserTicket{{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{blue}, Multiplier: 1}}}
+	}
+	day1 := time.Date(2025, 9, 16, 10, 0, 0, 0, chinaTime)
+	day2 := day1.AddDate(0, 0, 1)
+	win, lose, pending := ticket("44010123", "2025107", "07"), ticket("44010123", "2025107", "09"), ticket("44010123", "2099001", "07")
+	scanHistory = &historyStore{records: []ScanRecord{
+		{ID: "r1", Tenant: "shop-a", Time: day1, Lotteries: []LotteryData{win}},
+		{ID: "r2", Tenant: "shop-a", Time: day1, Lotteries: []LotteryData{win}}, // 重复扫描只计一张票
+		{ID: "r3", Tenant: "shop-a", Time: day1, Lotteries: []LotteryData{lose, ticket("44010999", "2025107", "07")}},
+		{ID: "r4", Tenant: "shop-a", Time: day2, Lotteries: []LotteryData{pending}},
+		{ID: "r5", Tenant: "shop-b", Time: day1, Lotteries: []LotteryData{win}},
+	}}
+
+	tests := []struct {
+		name       string
+		station    string
+		query      string
+		wantStatus int
+		wantDays   []StationDay
+		wantTotal  StationDay
+	}{
+		{"按天汇总", "44010123", "?from=2025-09-16&to=2025-09-18", 200,
+			[]StationDay{
+				{Date: "2025-09-16", Scans: 3, Tickets: 2, WinningTickets: 1, Spent: 4 * Yuan, Payout: 5 * Yuan},
+				{Date: "2025-09-17", Scans: 1, Tickets: 1, Pending: 1, Spent: 2 * Yuan},
+				{Date: "2025-09-18"},
+			},
+			StationDay{Date: "total", Scans: 4, Tickets: 3, WinningTickets: 1, Pending: 1, Spent: 6 * Yuan, Payout: 5 * Yuan}},
+		{"站点编号按票面格式清洗", "站号 4401 0123", "?date=2025-09-17", 200,
+			[]StationDay{{Date: "2025-09-17", Scans: 1, Tickets: 1, Pending: 1, Spent: 2 * Yuan}},
+			StationDay{Date: "total", Scans: 1, Tickets: 1, Pending: 1, Spent: 2 * Yuan}},
+		{"站点编号无效", "--", "", 400, nil, StationDay{}},
+		{"日期错误", "44010123", "?date=x", 400, nil, StationDay{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/stations/x/report"+tt.query, nil)
+			c.Request.Header.Set("X-Tenant-ID", "shop-a")
+			c.Params = gin.Params{{Key: "id", Value: tt.station}}
+			stationReportHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var resp struct {
+				Days  []StationDay `json:"days"`
+				Total StationDay   `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Days) != len(tt.wantDays) {
+				t.Fatalf("days = %+v", resp.Days)
+			}
+			for i, want := range tt.wantDays {
+				if resp.Days[i] != want {
+					t.Errorf("days[%d] = %+v, want %+v", i, resp.Days[i], want)
+				}
+			}
+			if resp.Total != tt.wantTotal {
+				t.Errorf("total = %+v, want %+v", resp.Total, tt.wantTotal)
+			}
+		})
+	}
+
+	// CSV 导出：BOM、表头、每天一行加合计，文件名带日期区间
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/stations/44010123/report?from=2025-09-16&to=2025-09-17&format=csv", nil)
+	c.Request.Header.Set("X-Tenant-ID", "shop-a")
+	c.Params = gin.Params{{Key: "id", Value: "44010123"}}
+	stationReportHandler(c)
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "\ufeff")), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "日期,站点") || lines[3] != "合计,44010123,4,3,1,1,6,5" {
+		t.Errorf("csv = %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "station-44010123-20250916-20250917.csv") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+}
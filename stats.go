@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// STATS: 历史开奖号码统计（冷热号、遗漏、和值/奇偶分布）
+// ==========================================
+
+// History 某彩种全部开奖记录，按期号从新到旧
+func (s *drawStore) History(game string) []DrawRecord {
+	game = canonicalGame(game)
+	s.mu.RLock()
+	list := make([]DrawRecord, 0)
+	for _, d := range s.draws {
+		if d.Game == game {
+			list = append(list, d)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return issueLess(list[j].Issue, list[i].Issue) })
+	return list
+}
+
+// issueLess 期号比较：位数不同时按数值，否则按字典序
+func issueLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// NumberStat 单个号码的统计
+type NumberStat struct {
+	Number    string  `json:"number"`
+	Count     int     `json:"count"`
+	Frequency float64 `json:"frequency"` // 出现期数 / 统计期数
+	Omission  int     `json:"omission"`  // 当前遗漏期数，从未出现则为统计期数
+	MaxOmit   int     `json:"max_omission"`
+	Heat      string  `json:"heat"` // hot / warm / cold
+}
+
+// ZoneStats 一个号码区的统计
+type ZoneStats struct {
+	Zone    string       `json:"zone"`
+	Numbers []NumberStat `json:"numbers"`
+}
+
+// statsWindow 读取 ?last=N，默认统计最近 100 期，0 表示全部
+func statsWindow(c *gin.Context, history []DrawRecord) ([]DrawRecord, error) {
+	last := 100
+	if v := c.Query("last"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("last 参数必须是非负整数")
+		}
+		last = n
+	}
+	if last > 0 && last < len(history) {
+		history = history[:last]
+	}
+	return history, nil
+}
+
+// zoneStats 统计一个号码区，history 按期号从新到旧
+func zoneStats(z gameZone, history []DrawRecord) ZoneStats {
+	total := len(history)
+	stats := make([]NumberStat, 0, z.Max-z.Min+1)
+	for n := z.Min; n <= z.Max; n++ {
+		stats = append(stats, NumberStat{Number: z.format(n), Omission: -1})
+	}
+	gap := make([]int, len(stats)) // 距上次出现的期数，用于计算最大遗漏
+	for i, d := range history {
+		seen := map[int]bool{}
+		for _, n := range z.numbers(d) {
+			if n >= z.Min && n <= z.Max {
+				seen[n-z.Min] = true
+			}
+		}
+		for k := range stats {
+			if !seen[k] {
+				gap[k]++
+				continue
+			}
+			stats[k].Count++
+			if stats[k].Omission < 0 {
+				stats[k].Omission = i
+			}
+			if gap[k] > stats[k].MaxOmit {
+				stats[k].MaxOmit = gap[k]
+			}
+			gap[k] = 0
+		}
+	}
+
+	// 期望出现次数为 total * pick / 号码个数，高出 20% 为热号，低 20% 为冷号
+	expected := float64(total*z.Pick) / float64(len(stats))
+	for k := range stats {
+		if stats[k].Omission < 0 {
+			stats[k].Omission = total
+		}
+		if gap[k] > stats[k].MaxOmit {
+			stats[k].MaxOmit = gap[k]
+		}
+		if total > 0 {
+			stats[k].Frequency = float64(stats[k].Count) / float64(total)
+		}
+		switch c := float64(stats[k].Count); {
+		case total == 0:
+			stats[k].Heat = "warm"
+		case c >= expected*1.2:
+			stats[k].Heat = "hot"
+		case c <= expected*0.8:
+			stats[k].Heat = "cold"
+		default:
+			stats[k].Heat = "warm"
+		}
+	}
+	return ZoneStats{Zone: z.Name, Numbers: stats}
+}
+
+// statsContext 解析彩种与统计窗口，失败时已写出错误响应
+func statsContext(c *gin.Context) (gameSpec, []DrawRecord, bool) {
+	spec, ok := specOf(c.Param("game"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "不支持的彩种: " + c.Param("game")})
+		return spec, nil, false
+	}
+	history, err := statsWindow(c, draws.History(spec.Name))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return spec, nil, false
+	}
+	return spec, history, true
+}
+
+// statsFrequencyHandler GET /api/v1/stats/:game/frequency?last=100
+func statsFrequencyHandler(c *gin.Context) {
+	spec, history, ok := statsContext(c)
+	if !ok {
+		return
+	}
+	zones := make([]ZoneStats, 0, len(spec.Zones))
+	for _, z := range spec.Zones {
+		zones = append(zones, zoneStats(z, history))
+	}
+	respondCacheable(c, gin.H{"game": spec.Name, "draws": len(history), "range": issueRange(history), "zones": zones})
+}
+
+// statsOverdueHandler GET /api/v1/stats/:game/overdue?last=100&top=10
+// 每个区按当前遗漏期数从大到小排列
+func statsOverdueHandler(c *gin.Context) {
+	spec, history, ok := statsContext(c)
+	if !ok {
+		return
+	}
+	top := 10
+	if v := c.Query("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(400, gin.H{"error": "top 参数必须是正整数"})
+			return
+		}
+		top = n
+	}
+	zones := make([]ZoneStats, 0, len(spec.Zones))
+	for _, z := range spec.Zones {
+		zs := zoneStats(z, history)
+		sort.SliceStable(zs.Numbers, func(i, j int) bool { return zs.Numbers[i].Omission > zs.Numbers[j].Omission })
+		if len(zs.Numbers) > top {
+			zs.Numbers = zs.Numbers[:top]
+		}
+		zones = append(zones, zs)
+	}
+	respondCacheable(c, gin.H{"game": spec.Name, "draws": len(history), "range": issueRange(history), "zones": zones})
+}
+
+// Bucket 分布中的一档
+type Bucket struct {
+	Label string  `json:"label"`
+	Count int     `json:"count"`
+	Ratio float64 `json:"ratio"`
+}
+
+// distribution 计数转为按 label 排序的分布
+func distribution(counts map[string]int, total int, less func(a, b string) bool) []Bucket {
+	out := make([]Bucket, 0, len(counts))
+	for label, n := range counts {
+		b := Bucket{Label: label, Count: n}
+		if total > 0 {
+			b.Ratio = float64(n) / float64(total)
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return less(out[i].Label, out[j].Label) })
+	return out
+}
+
+// statsDistributionHandler GET /api/v1/stats/:game/distribution?last=100&sum_step=10
+// 和值与奇偶比只统计第一个号码区（双色球红球 / 大乐透前区 / 排列5 全部位）
+func statsDistributionHandler(c *gin.Context) {
+	spec, history, ok := statsContext(c)
+	if !ok {
+		return
+	}
+	step := 10
+	if spec.Ordered {
+		step = 5
+	}
+	if v := c.Query("sum_step"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(400, gin.H{"error": "sum_step 参数必须是正整数"})
+			return
+		}
+		step = n
+	}
+
+	zones := spec.Zones[:1]
+	if spec.Ordered {
+		zones = spec.Zones
+	}
+	sums := map[string]int{}
+	oddEven := map[string]int{}
+	minSum, maxSum, totalSum := -1, 0, 0
+	for _, d := range history {
+		sum, odd, count := 0, 0, 0
+		for _, z := range zones {
+			for _, n := range z.numbers(d) {
+				sum += n
+				count++
+				if n%2 == 1 {
+					odd++
+				}
+			}
+		}
+		lo := sum / step * step
+		sums[fmt.Sprintf("%d-%d", lo, lo+step-1)]++
+		oddEven[fmt.Sprintf("%d:%d", odd, count-odd)]++
+		totalSum += sum
+		if minSum < 0 || sum < minSum {
+			minSum = sum
+		}
+		if sum > maxSum {
+			maxSum = sum
+		}
+	}
+
+	resp := gin.H{
+		"game":  spec.Name,
+		"draws": len(history),
+		"range": issueRange(history),
+		"sum": gin.H{
+			"buckets": distribution(sums, len(history), func(a, b string) bool { return leadingInt(a) < leadingInt(b) }),
+			"min":     max(minSum, 0),
+			"max":     maxSum,
+		},
+		// 奇数个数多的排前面，如 4:2 排在 3:3 之前
+		"odd_even": distribution(oddEven, len(history), func(a, b string) bool { return leadingInt(a) > leadingInt(b) }),
+	}
+	if len(history) > 0 {
+		resp["sum"].(gin.H)["avg"] = float64(totalSum) / float64(len(history))
+	}
+	respondCacheable(c, resp)
+}
+
+// leadingInt "120-129" -> 120，"4:2" -> 4
+func leadingInt(s string) int {
+	end := strings.IndexAny(s, "-:")
+	if end < 0 {
+		end = len(s)
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// issueRange 统计窗口覆盖的期号区间
+func issueRange(history []DrawRecord) gin.H {
+	if len(history) == 0 {
+		return gin.H{}
+	}
+	return gin.H{"from": history[len(history)-1].Issue, "to": history[0].Issue}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIssueLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2025106", "2025107", true},
+		{"2025107", "2025106", false},
+		{"25107", "2025001", true},
+		{"2025107", "2025107", false},
+	}
+	for _, tt := range tests {
+		if got := issueLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("issueLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestZoneStats(t *testing.T) {
+	z := gameZone{Name: "blue", Field: "blue", Index: -1, Min: 1, Max: 3, Pick: 1, Width: 2}
+	// 从新到旧
+	history := []DrawRecord{{Blue: []string{"01"}}, {Blue: []string{"02"}}, {Blue: []string{"01"}}}
+	tests := []struct {
+		number   string
+		count    int
+		omission int
+		maxOmit  int
+		heat     string
+	}{
+		{"01", 2, 0, 1, "hot"},
+		{"02", 1, 1, 1, "warm"},
+		{"03", 0, 3, 3, "cold"},
+	}
+	stats := zoneStats(z, history)
+	if len(stats.Numbers) != len(tests) {
+		t.Fatalf("numbers = %d, want %d", len(stats.Numbers), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			got := stats.Numbers[i]
+			if got.Number != tt.number || got.Count != tt.count || got.Omission != tt.omission || got.MaxOmit != tt.maxOmit || got.Heat != tt.heat {
+				t.Errorf("stat = %+v, want %+v", got, tt)
+			}
+		})
+	}
+}
+
+func TestStatsWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	history := make([]DrawRecord, 150)
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{"默认最近 100 期", "", 100, false},
+		{"指定期数", "?last=30", 30, false},
+		{"0 表示全部", "?last=0", 150, false},
+		{"超过总期数", "?last=500", 150, false},
+		{"负数", "?last=-1", 0, true},
+		{"不是数字", "?last=abc", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+			got, err := statsWindow(c, history)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("window = %d, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[string]int
+		less   func(a, b string) bool
+		want   []string
+	}{
+		{"和值区间按起点升序", map[string]int{"100-109": 1, "90-99": 2, "110-119": 1}, func(a, b string) bool { return leadingInt(a) < leadingInt(b) },
+			[]string{"90-99", "100-109", "110-119"}},
+		{"奇偶比奇数多的在前", map[string]int{"3:3": 1, "4:2": 1, "2:4": 1}, func(a, b string) bool { return leadingInt(a) > leadingInt(b) },
+			[]string{"4:2", "3:3", "2:4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distribution(tt.counts, 4, tt.less)
+			for i, b := range got {
+				if b.Label != tt.want[i] {
+					t.Fatalf("labels = %+v, want %v", got, tt.want)
+				}
+				if b.Ratio != float64(tt.counts[b.Label])/4 {
+					t.Errorf("%s ratio = %v", b.Label, b.Ratio)
+				}
+			}
+		})
+	}
+}
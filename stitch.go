@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// STITCH: 长票分段拍摄后合并识别（POST /api/v1/scan/stitch）
+// ==========================================
+
+// 十行的复式/多注票一张照片拍不清，可以从上到下分 2~4 张拍，相邻两张有部分重叠：
+//
+//	POST /api/v1/scan/stitch   images=<上半部分>  images=<下半部分>   （按从上到下的顺序）
+//
+// 每张照片分别识别后合并为一张票：彩种、期号、序列号等取第一张识别出的值，号码行按顺序拼接，
+// 前一张末尾与后一张开头重复的行（重叠区域）只保留一次。各张识别出的彩种或期号不一致时照常合并，
+// 在结果 warnings 里提示。合并后的验奖、扫描记录、通知与 /api/v1/scan 相同，扫描记录保存第一张照片。
+// 额度按照片张数计；其余参数（source、accuracy、lang 等）与 /api/v1/scan 含义相同
+
+const maxStitchParts = 4
+
+func stitchScanHandler(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) < 2 {
+		c.JSON(400, gin.H{"error": "请按从上到下的顺序上传名为 'images' 的 2~4 张照片"})
+		return
+	}
+	files := form.File["images"]
+	if len(files) > maxStitchParts {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("最多合并 %d 张照片", maxStitchParts)})
+		return
+	}
+	source, err := imageSourceOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
+		return
+	}
+
+	parts := make([][]byte, len(files))
+	for i, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "读取文件失败: " + err.Error()})
+			return
+		}
+		parts[i], err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "读取文件失败: " + err.Error()})
+			return
+		}
+	}
+
+	budget := newRequestBudget(c.Request.Context())
+	defer budget.Done()
+	ocrCtx, cancelOCR := budget.Stage(stageOCR)
+	ctx := withImageSource(withOCRPasses(ocrCtx, ocrPassesOf(c)), source)
+	outputs := make([][]LotteryData, len(parts))
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i := range parts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = recognizeCached(withImageName(ctx, files[i].Filename), parts[i], apiKey)
+		}(i)
+	}
+	wg.Wait()
+	err = errors.Join(errs...)
+	if err != nil {
+		if stageErr := budget.Check(ocrCtx, stageOCR); stageErr != nil {
+			err = stageErr
+		}
+	}
+	cancelOCR()
+	if errors.Is(err, errCircuitOpen) {
+		onScanFailed(tenantOf(c), errCircuitOpen.Error())
+		c.JSON(503, gin.H{"error": "AI 识别服务暂时不可用，请稍后重试"})
+		return
+	}
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "AI 识别失败: ", err)
+		return
+	}
+
+	merged, warnings := stitchLotteries(outputs)
+	if len(merged.Tickets) == 0 {
+		c.JSON(422, gin.H{"error": "无识别结果"})
+		return
+	}
+	results, err := verifyAll(budget, []LotteryData{merged})
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	results[0].Warnings = append(warnings, results[0].Warnings...)
+	// 各张照片的篡改检测合并为一条提示
+	tamper := inspectImage(parts[0])
+	for _, p := range parts[1:] {
+		for _, f := range inspectImage(p).Findings {
+			if !slices.Contains(tamper.Findings, f) {
+				tamper.Findings = append(tamper.Findings, f)
+			}
+		}
+	}
+	tamper.applyAll(results)
+	onScanCompleted(originOf(c), parts[0], results)
+	respondResults(c, results)
+}
+
+// stitchLotteries 把各张照片的识别结果按顺序合并为一张票
+func stitchLotteries(outputs [][]LotteryData) (LotteryData, []string) {
+	var merged LotteryData
+	var warnings []string
+	for i, out := range outputs {
+		for _, l := range out {
+			if i > 0 && l.Type != "" && merged.Type != "" && canonicalGame(l.Type) != canonicalGame(merged.Type) {
+				warnings = append(warnings, fmt.Sprintf("第%d张照片识别为%s，与第1张的%s不一致，请确认是同一张票", i+1, l.Type, merged.Type))
+			}
+			if i > 0 && l.Issue != "" && merged.Issue != "" && l.Issue != merged.Issue {
+				warnings = append(warnings, fmt.Sprintf("第%d张照片的期号 %s 与第1张的 %s 不一致，请确认是同一张票", i+1, l.Issue, merged.Issue))
+			}
+			merged.Type = cmp.Or(merged.Type, l.Type)
+			merged.Issue = cmp.Or(merged.Issue, l.Issue)
+			merged.SaleTime = cmp.Or(merged.SaleTime, l.SaleTime)
+			merged.Serial = cmp.Or(merged.Serial, l.Serial)
+			merged.Station = cmp.Or(merged.Station, l.Station)
+			if merged.Amount == 0 {
+				merged.Amount = l.Amount
+			}
+			merged.Enhanced = merged.Enhanced || l.Enhanced
+			merged.Uncertain = append(merged.Uncertain, l.Uncertain...)
+			merged.Tickets = appendOverlapping(merged.Tickets, l.Tickets)
+			for _, sec := range l.Sections {
+				// 附加玩法印在票面底部，可能在两张照片里都拍到
+				if !slices.ContainsFunc(merged.Sections, func(m LotteryData) bool { return canonicalGame(m.Type) == canonicalGame(sec.Type) }) {
+					merged.Sections = append(merged.Sections, sec)
+				}
+			}
+		}
+	}
+	return merged, warnings
+}
+
+// appendOverlapping 拼接号码行：next 开头与 rows 末尾相同的最长一段视为照片重叠部分，只保留一次
+func appendOverlapping(rows, next []UserTicket) []UserTicket {
+	for k := min(len(rows), len(next)); k > 0; k-- {
+		same := true
+		for j := 0; j < k && same; j++ {
+			same = rowKey(rows[len(rows)-k+j]) == rowKey(next[j])
+		}
+		if same {
+			return append(rows, next[k:]...)
+		}
+	}
+	return append(rows, next...)
+}
+
+// rowKey 比较号码行用的键，忽略空格与倍数（重叠处的倍数常被截掉）
+func rowKey(t UserTicket) string {
+	join := func(nums []string) string {
+		out := make([]string, len(nums))
+		for i, n := range nums {
+			out[i] = strings.TrimSpace(n)
+		}
+		return strings.Join(out, ",")
+	}
+	return join(t.Dan) + "|" + join(t.Red) + "|" + join(t.BlueDan) + "|" + join(t.Blue)
+}
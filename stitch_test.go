@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func stitchRow(reds ...string) UserTicket {
+	return UserTicket{Red: reds, Blue: []string{"07"}, Multiplier: 1}
+}
+
+// rowsOf 各行第一个红球，便于比较行的顺序
+func rowsOf(tickets []UserTicket) string {
+	var out []string
+	for _, t := range tickets {
+		out = append(out, t.Red[0])
+	}
+	return strings.Join(out, ",")
+}
+
+func TestAppendOverlapping(t *testing.T) {
+	a, b, c, d := stitchRow("01"), stitchRow("02"), stitchRow("03"), stitchRow("04")
+	tests := []struct {
+		name string
+		rows []UserTicket
+		next []UserTicket
+		want string
+	}{
+		{"没有重叠", []UserTicket{a, b}, []UserTicket{c, d}, "01,02,03,04"},
+		{"重叠一行", []UserTicket{a, b}, []UserTicket{b, c}, "01,02,03"},
+		{"重叠多行", []UserTicket{a, b, c}, []UserTicket{b, c, d}, "01,02,03,04"},
+		{"后一张完全在重叠区内", []UserTicket{a, b, c}, []UserTicket{b, c}, "01,02,03"},
+		{"只有开头相同不算重叠", []UserTicket{a, b, c}, []UserTicket{b, d}, "01,02,03,02,04"},
+		{"忽略空格和倍数", []UserTicket{a, {Red: []string{" 02"}, Blue: []string{"07 "}, Multiplier: 5}}, []UserTicket{b, c}, "01, 02,03"},
+		{"蓝球不同不算重叠", []UserTicket{a, b}, []UserTicket{{Red: []string{"02"}, Blue: []string{"08"}}}, "01,02,02"},
+		{"第一张", nil, []UserTicket{a, b}, "01,02"},
+	}
+	for _, tt := range tests {
+		if got := rowsOf(appendOverlapping(tt.rows, tt.next)); got != tt.want {
+			t.Errorf("%s: appendOverlapping() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStitchLotteries(t *testing.T) {
+	a, b, c := stitchRow("01"), stitchRow("02"), stitchRow("03")
+	top := LotteryData{Type: "双色球", Issue: "2025107", Serial: "SN-1", Uncertain: []string{"tickets[0].red"}, Tickets: []UserTicket{a, b}}
+	tests := []struct {
+		name         string
+		outputs      [][]LotteryData
+		wantRows     string
+		wantSerial   string
+		wantSections int
+		wantWarnings int
+	}{
+		{"按顺序合并", [][]LotteryData{{top}, {{Type: "ssq", Issue: "2025107", Tickets: []UserTicket{b, c}}}}, "01,02,03", "SN-1", 0, 0},
+		{"票面信息取第一张识别出的值", [][]LotteryData{{{Tickets: []UserTicket{a}}}, {{Type: "双色球", Serial: "SN-2", Tickets: []UserTicket{b}}}}, "01,02", "SN-2", 0, 0},
+		{"彩种和期号不一致", [][]LotteryData{{top}, {{Type: "大乐透", Issue: "25107", Tickets: []UserTicket{c}}}}, "01,02,03", "SN-1", 0, 2},
+		{"附加玩法只保留一次", [][]LotteryData{
+			{{Type: "七星彩", Tickets: []UserTicket{a}, Sections: []LotteryData{{Type: "生肖乐"}}}},
+			{{Type: "七星彩", Tickets: []UserTicket{b}, Sections: []LotteryData{{Type: "生肖乐"}}}},
+		}, "01,02", "", 1, 0},
+		{"某张没有识别结果", [][]LotteryData{{top}, nil}, "01,02", "SN-1", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, warnings := stitchLotteries(tt.outputs)
+			if got := rowsOf(merged.Tickets); got != tt.wantRows {
+				t.Errorf("rows = %s, want %s", got, tt.wantRows)
+			}
+			if merged.Serial != tt.wantSerial || len(merged.Sections) != tt.wantSections || len(warnings) != tt.wantWarnings {
+				t.Errorf("merged = %+v, warnings %v", merged, warnings)
+			}
+		})
+	}
+	if top.Tickets[1].Red[0] != "02" || len(top.Tickets) != 2 {
+		t.Error("stitchLotteries modified its input")
+	}
+}
+
+func TestStitchScanHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	useTestBilling(t)
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	// 上半张第一行中三等奖，下半张与上半张重叠一行
+	const (
+		win   = `{"red": ["02","11","15","21","28","01"], "blue": ["07"], "multiplier": 1}`
+		lose1 = `{"red": ["01","03","04","05","06","08"], "blue": ["09"], "multiplier": 1}`
+		lose2 = `{"red": ["01","03","04","05","06","10"], "blue": ["09"], "multiplier": 1}`
+	)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "top.json", []byte(`[{"type": "双色球", "issue": "2025107", "tickets": [`+win+`, `+lose1+`]}]`))
+	writeTestFile(t, dir, "bottom.json", []byte(`[{"type": "双色球", "issue": "2025107", "tickets": [`+lose1+`, `+lose2+`]}]`))
+	writeTestFile(t, dir, "other.json", []byte(`[{"type": "双色球", "issue": "2025108", "tickets": [`+lose2+`]}]`))
+	writeTestFile(t, dir, "empty.json", []byte(`[{"type": "双色球", "issue": "2025107", "tickets": []}]`))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	t.Setenv("GEMINI_API_KEY", "test")
+
+	tests := []struct {
+		name         string
+		files        []string
+		wantStatus   int
+		wantRows     int
+		wantPrize    Fen
+		wantWarnings string
+	}{
+		{"两张重叠的照片", []string{"top", "bottom"}, 200, 3, 3000 * Yuan, ""},
+		{"期号不一致照常合并", []string{"top", "other"}, 200, 3, 3000 * Yuan, "期号 2025108 与第1张的 2025107 不一致"},
+		{"只有一张", []string{"top"}, 400, 0, 0, ""},
+		{"超过 4 张", []string{"top", "bottom", "top", "bottom", "top"}, 400, 0, 0, ""},
+		{"某张识别失败", []string{"top", "missing"}, 500, 0, 0, ""},
+		{"都没有号码行", []string{"empty", "empty"}, 422, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanHistory.records = nil
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for i, name := range tt.files {
+				fw, _ := mw.CreateFormFile("images", name+".jpg")
+				fw.Write([]byte("\xff\xd8\xff\xe0stitch-" + tt.name + "-" + string(rune('0'+i))))
+			}
+			mw.Close()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan/stitch", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			stitchScanHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var results []VerificationResult
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || len(results[0].OCRData.Tickets) != tt.wantRows || results[0].TotalPrize != tt.wantPrize {
+				t.Fatalf("results = %s", w.Body)
+			}
+			if warnings := strings.Join(results[0].Warnings, "|"); tt.wantWarnings != "" && !strings.Contains(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %s, want %s", warnings, tt.wantWarnings)
+			}
+			if len(scanHistory.records) != 1 {
+				t.Errorf("history = %d records, want 1", len(scanHistory.records))
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// STREAM: 大批量结果流式输出
+// ==========================================
+
+const (
+	streamNDJSON = "ndjson" // 每行一个 JSON 对象
+	streamArray  = "array"  // 标准 JSON 数组，逐个元素写出
+)
+
+// BatchItem 批量接口中单张图片的处理结果。Status 总是给出：DONE 有 results，FAILED 有 error，
+// QUEUED 有 job_id（AI 服务熔断时排队），REVIEW 有 review_id；DONE 但部分票验奖失败时 partial 为 true，
+// 失败的票在各自结果的 error 里说明
+type BatchItem struct {
+	ImageIndex int                  `json:"image_index"`
+	FileName   string               `json:"file_name"`
+	Status     string               `json:"status"`
+	Partial    bool                 `json:"partial,omitempty"`
+	Results    []VerificationResult `json:"results,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	JobID      string               `json:"job_id,omitempty"`
+	ReviewID   string               `json:"review_id,omitempty"`
+}
+
+// BatchSummary 非流式批量响应的汇总，各状态的图片数
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Partial   int `json:"partial"`
+	Failed    int `json:"failed"`
+	Queued    int `json:"queued"`
+	Review    int `json:"review"`
+}
+
+func (s *BatchSummary) add(item BatchItem) {
+	s.Total++
+	switch item.Status {
+	case JobDone:
+		s.Succeeded++
+		if item.Partial {
+			s.Partial++
+		}
+	case JobFailed:
+		s.Failed++
+	case JobQueued, JobProcessing:
+		s.Queued++
+	case JobReview:
+		s.Review++
+	}
+}
+
+// batchStreamDisabled ?stream=false / 0 时批量接口等全部图片处理完后一次返回
+func batchStreamDisabled(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("stream")) {
+	case "false", "0", "off":
+		return true
+	}
+	return false
+}
+
+// streamModeOf 根据 ?stream= 参数或 Accept 头判断流式模式，空字符串表示不流式
+func streamModeOf(c *gin.Context) string {
+	switch strings.ToLower(c.Query("stream")) {
+	case streamNDJSON:
+		return streamNDJSON
+	case streamArray, "1", "true":
+		return streamArray
+	}
+	if strings.Contains(c.GetHeader("Accept"), "application/x-ndjson") {
+		return streamNDJSON
+	}
+	return ""
+}
+
+// resultStream 把结果逐个编码写入响应，每写一个就 Flush，避免整批结果堆在内存里
+type resultStream struct {
+	c     *gin.Context
+	mode  string
+	enc   *json.Encoder
+	count int
+}
+
+func newResultStream(c *gin.Context, mode string) *resultStream {
+	if mode == streamNDJSON {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(200)
+	return &resultStream{c: c, mode: mode, enc: json.NewEncoder(c.Writer)}
+}
+
+func (s *resultStream) Write(v interface{}) error {
+	if s.mode == streamArray {
+		sep := ","
+		if s.count == 0 {
+			sep = "["
+		}
+		if _, err := io.WriteString(s.c.Writer, sep); err != nil {
+			return err
+		}
+	}
+	// json.Encoder 每次 Encode 末尾自带换行，正好满足 NDJSON
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.count++
+	s.c.Writer.Flush()
+	return nil
+}
+
+func (s *resultStream) Close() error {
+	if s.mode == streamArray {
+		closing := "]"
+		if s.count == 0 {
+			closing = "[]"
+		}
+		if _, err := io.WriteString(s.c.Writer, closing); err != nil {
+			return err
+		}
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// batchVerifyHandler 一次上传多张图片（字段名 images），按完成顺序流式返回每张图片的结果。
+// ?stream=false 时全部处理完再按图片顺序一次返回 {"summary": BatchSummary, "items": [...]}：
+// 全部成功为 200，有图片失败、排队或转人工复核（或部分票验奖失败）时为 207，调用方按每项的 status 处理
+func batchVerifyHandler(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) == 0 {
+		c.JSON(400, gin.H{"error": "请上传名为 'images' 的文件（可多个）"})
+		return
+	}
+	files := form.File["images"]
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		c.JSON(500, gin.H{"error": "服务端未配置 GEMINI_API_KEY"})
+		return
+	}
+
+	mode := streamModeOf(c)
+	if mode == "" {
+		mode = streamArray
+	}
+	source, err := imageSourceOf(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 限制同时进行的 OCR 请求数，结果通过 channel 交给唯一的写出协程
+	workers := envInt("BATCH_CONCURRENCY", 4)
+	if workers < 1 {
+		workers = 1
+	}
+	ctx := withImageSource(withEnsemble(withOCRPasses(c.Request.Context(), ocrPassesOf(c)), ensembleOf(c)), source)
+	jobs := make(chan int)
+	items := make(chan BatchItem)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				items <- processBatchFile(ctx, originOf(c), i, files[i].Filename, func() ([]byte, error) {
+					f, err := files[i].Open()
+					if err != nil {
+						return nil, err
+					}
+					defer f.Close()
+					return io.ReadAll(f)
+				}, apiKey)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(items)
+	}()
+
+	cat := catalogOf(c)
+	report := c.Query("report")
+	if batchStreamDisabled(c) || report != "" {
+		collected := make([]BatchItem, len(files))
+		raw := make([]BatchItem, len(files))
+		var summary BatchSummary
+		for item := range items {
+			raw[item.ImageIndex-1] = item
+			item.Results, item.Error = presentResults(c, item.Results), cat.T(item.Error)
+			collected[item.ImageIndex-1] = item
+			summary.add(item)
+		}
+		if summary.Total < len(files) {
+			// 客户端已断开，未处理的图片不再返回
+			return
+		}
+		if report != "" {
+			// ?report=json|html 时等全部图片处理完，返回汇总报告而不是逐张结果
+			respondReport(c, buildBatchReport("upload", "批量验奖汇总报告", raw), report)
+			return
+		}
+		status := 200
+		if summary.Succeeded != summary.Total || summary.Partial > 0 {
+			status = 207
+		}
+		c.JSON(status, gin.H{"summary": summary, "items": collected})
+		return
+	}
+
+	stream := newResultStream(c, mode)
+	for item := range items {
+		item.Results, item.Error = presentResults(c, item.Results), cat.T(item.Error)
+		if err := stream.Write(item); err != nil {
+			log.Printf("批量流式写出中断: %v", err)
+			// 客户端已断开，继续消费 channel 让 worker 正常退出
+			for range items {
+			}
+			return
+		}
+	}
+	stream.Close()
+}
+
+// processBatchFile 处理批量中的一张图片，每张图片单独计算超时预算
+func processBatchFile(parent context.Context, origin ScanOrigin, idx int, name string, read func() ([]byte, error), apiKey string) BatchItem {
+	item := BatchItem{ImageIndex: idx + 1, FileName: name, Status: JobFailed}
+	fileBytes, err := read()
+	if err != nil {
+		item.Error = "读取文件失败: " + err.Error()
+		return item
+	}
+	results, job, err := runScan(withImageName(parent, name), origin, fileBytes, apiKey)
+	rr, review := asReviewRequired(err)
+	switch {
+	case review:
+		item.Status, item.ReviewID = JobReview, rr.ID
+	case err != nil:
+		item.Error = err.Error()
+	case job != nil:
+		item.JobID, item.Status = job.ID, job.Status
+	default:
+		item.Status, item.Results, item.Partial = JobDone, results, failedCount(results) > 0
+	}
+	return item
+}
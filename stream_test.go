@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamModeOf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   string
+	}{
+		{"默认不流式", "", "", ""},
+		{"ndjson 参数", "?stream=ndjson", "", streamNDJSON},
+		{"参数不区分大小写", "?stream=NDJSON", "", streamNDJSON},
+		{"stream=1 为数组", "?stream=1", "", streamArray},
+		{"stream=true 为数组", "?stream=true", "", streamArray},
+		{"Accept 头", "", "application/x-ndjson", streamNDJSON},
+		{"未知取值不流式", "?stream=xml", "application/json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+			if got := streamModeOf(c); got != tt.want {
+				t.Errorf("streamModeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		mode        string
+		items       []int
+		want        string
+		contentType string
+	}{
+		{"空数组", streamArray, nil, "[]", "application/json; charset=utf-8"},
+		{"数组逐个写出", streamArray, []int{1, 2, 3}, "[1\n,2\n,3\n]", "application/json; charset=utf-8"},
+		{"ndjson 每行一个", streamNDJSON, []int{1, 2}, "1\n2\n", "application/x-ndjson; charset=utf-8"},
+		{"空 ndjson", streamNDJSON, nil, "", "application/x-ndjson; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			s := newResultStream(c, tt.mode)
+			for _, v := range tt.items {
+				if err := s.Write(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestBatchSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []BatchItem
+		want  BatchSummary
+	}{
+		{"空批次", nil, BatchSummary{}},
+		{"各状态计数", []BatchItem{
+			{Status: JobDone}, {Status: JobDone, Partial: true}, {Status: JobFailed},
+			{Status: JobQueued}, {Status: JobProcessing}, {Status: JobReview},
+		}, BatchSummary{Total: 6, Succeeded: 2, Partial: 1, Failed: 1, Queued: 2, Review: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got BatchSummary
+			for _, item := range tt.items {
+				got.add(item)
+			}
+			if got != tt.want {
+				t.Errorf("summary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchStreamDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"?stream=false", true},
+		{"?stream=0", true},
+		{"?stream=OFF", true},
+		{"?stream=ndjson", false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/api/v1/scan/batch"+tt.query, nil)
+		if got := batchStreamDisabled(c); got != tt.want {
+			t.Errorf("batchStreamDisabled(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// ?stream=false 时按图片顺序一次返回，有图片失败时为 207
+func TestBatchVerifyHandlerCollected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "win.json", []byte(hookTestFixture))
+	writeTestFile(t, dir, "win2.json", []byte(hookTestFixture))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	t.Setenv("GEMINI_API_KEY", "test")
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	tests := []struct {
+		name        string
+		files       []string
+		wantStatus  int
+		wantSummary BatchSummary
+		wantItems   []string // 各项的 status，按图片顺序
+	}{
+		{"全部成功", []string{"win.jpg", "win2.jpg"}, 200, BatchSummary{Total: 2, Succeeded: 2}, []string{JobDone, JobDone}},
+		{"有图片识别失败", []string{"win.jpg", "blurry.jpg"}, 207, BatchSummary{Total: 2, Succeeded: 1, Failed: 1}, []string{JobDone, JobFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocrCache.Purge()
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for i, name := range tt.files {
+				fw, _ := mw.CreateFormFile("images", name)
+				fw.Write([]byte{0xff, 0xd8, 0xff, 0xe0, byte(i), byte(len(tt.name))})
+			}
+			mw.Close()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan/batch?stream=false", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			batchVerifyHandler(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Summary BatchSummary `json:"summary"`
+				Items   []BatchItem  `json:"items"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Summary != tt.wantSummary {
+				t.Errorf("summary = %+v, want %+v", resp.Summary, tt.wantSummary)
+			}
+			for i, item := range resp.Items {
+				if item.ImageIndex != i+1 || item.FileName != tt.files[i] || item.Status != tt.wantItems[i] {
+					t.Errorf("items[%d] = %d %s %s, want %s", i, item.ImageIndex, item.FileName, item.Status, tt.wantItems[i])
+				}
+				if (item.Status == JobDone) != (len(item.Results) == 2) || (item.Status == JobFailed) != (item.Error != "") {
+					t.Errorf("items[%d] = %d results, error %q", i, len(item.Results), item.Error)
+				}
+			}
+		})
+	}
+}
+
+// ?report=json|html 时等全部图片处理完，返回汇总报告
+func TestBatchVerifyHandlerReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	writeTestFile(t, dir, "win.json", []byte(hookTestFixture))
+	t.Setenv("OCR_PROVIDER", providerMock)
+	t.Setenv("OCR_MOCK_DIR", dir)
+	t.Setenv("GEMINI_API_KEY", "test")
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	tests := []struct {
+		report   string
+		wantType string
+	}{
+		{"json", "application/json"},
+		{"html", "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.report, func(t *testing.T) {
+			ocrCache.Purge()
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for i, name := range []string{"win.jpg", "blurry.jpg"} {
+				fw, _ := mw.CreateFormFile("images", name)
+				fw.Write([]byte{0xff, 0xd8, 0xff, 0xe0, byte(i), byte(len(tt.report))})
+			}
+			mw.Close()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan/batch?report="+tt.report, &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			batchVerifyHandler(c)
+
+			if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.wantType) {
+				t.Fatalf("response = %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+			}
+			if tt.report != "json" {
+				return
+			}
+			var report BatchReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Source != "upload" || report.Images != 2 || report.Tickets != 2 || !report.Complete ||
+				len(report.Exceptions) != 1 || report.Exceptions[0].FileName != "blurry.jpg" || report.Exceptions[0].Kind != "failed" {
+				t.Errorf("report = %s", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SUBSCRIBE: 新开奖结果长轮询 / SSE（GET /api/v1/draws/:game/subscribe）
+// ==========================================
+
+// 客户端等开奖不必每几秒轮询一次：
+//
+//	GET /api/v1/draws/ssq/subscribe?after=2025107&timeout=60s
+//
+// 已有比 after 更新的一期时立即返回，否则挂起直到新一期开奖数据写入（同一期补全奖金也算），
+// 超时返回 204，客户端带同样的 after 再次请求即可。Accept: text/event-stream（或 ?mode=sse）
+// 时改为 SSE 长连接，每写入一期推送一个 draw 事件，id 为期号，断线重连时 Last-Event-ID 等同 after
+
+// drawHub 把同步到的开奖结果广播给等待中的订阅者
+type drawHub struct {
+	mu   sync.Mutex
+	subs map[chan DrawRecord]string // 订阅者 → 彩种
+}
+
+var drawFeed = &drawHub{subs: map[chan DrawRecord]string{}}
+
+// Subscribe 订阅某彩种，返回的 cancel 必须调用
+func (h *drawHub) Subscribe(game string) (<-chan DrawRecord, func()) {
+	ch := make(chan DrawRecord, 8)
+	h.mu.Lock()
+	h.subs[ch] = game
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish 不阻塞：订阅者来不及读时丢弃，重新订阅时会按 after 补上
+func (h *drawHub) Publish(list []DrawRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, d := range list {
+		for ch, game := range h.subs {
+			if game != d.Game {
+				continue
+			}
+			select {
+			case ch <- d:
+			default:
+			}
+		}
+	}
+}
+
+// drawsAfter 比 after 更新的各期，按期号从旧到新；after 为空时没有
+func drawsAfter(game, after string) []DrawRecord {
+	if after == "" {
+		return nil
+	}
+	var out []DrawRecord
+	for _, d := range draws.History(game) {
+		if !issueLess(after, d.Issue) {
+			break
+		}
+		out = append([]DrawRecord{d}, out...)
+	}
+	return out
+}
+
+// subscribeTimeout ?timeout= 默认 30s，最长 DRAW_SUBSCRIBE_MAX_WAIT（默认 120s）
+func subscribeTimeout(c *gin.Context) (time.Duration, error) {
+	limit := envDuration("DRAW_SUBSCRIBE_MAX_WAIT", 120*time.Second)
+	v := c.Query("timeout")
+	if v == "" {
+		return min(30*time.Second, limit), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		if n, nerr := time.ParseDuration(v + "s"); nerr == nil {
+			d, err = n, nil
+		}
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("timeout 格式错误，如 30s")
+	}
+	return min(d, limit), nil
+}
+
+func drawSubscribeHandler(c *gin.Context) {
+	spec, ok := specOf(c.Param("game"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "不支持的彩种: " + c.Param("game")})
+		return
+	}
+	after := strings.TrimSpace(c.Query("after"))
+	if id := strings.TrimSpace(c.GetHeader("Last-Event-ID")); id != "" {
+		after = id
+	}
+	// 先订阅再查已有数据，避免两步之间写入的一期被漏掉
+	ch, cancel := drawFeed.Subscribe(spec.Name)
+	defer cancel()
+
+	if c.Query("mode") == "sse" || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		streamDraws(c, ch, drawsAfter(spec.Name, after))
+		return
+	}
+
+	timeout, err := subscribeTimeout(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if newer := drawsAfter(spec.Name, after); len(newer) > 0 {
+		c.JSON(200, presentDraw(c, newer[len(newer)-1]))
+		return
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case d := <-ch:
+		c.JSON(200, presentDraw(c, d))
+	case <-timer.C:
+		c.Status(204)
+	case <-c.Request.Context().Done():
+	}
+}
+
+// streamDraws SSE：先补发 backlog，之后每期推送一次，15 秒一次心跳防止代理断开空闲连接
+func streamDraws(c *gin.Context, ch <-chan DrawRecord, backlog []DrawRecord) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+	write := func(d DrawRecord) error {
+		raw, err := json.Marshal(presentDraw(c, d))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Writer, "event: draw\nid: %s\ndata: %s\n\n", d.Issue, raw); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+	for _, d := range backlog {
+		if write(d) != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case d := <-ch:
+			if write(d) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
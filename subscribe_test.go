@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubscribeTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		query   string
+		max     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"默认 30s", "", "", 30 * time.Second, false},
+		{"默认值不超过上限", "", "10s", 10 * time.Second, false},
+		{"时长格式", "?timeout=45s", "", 45 * time.Second, false},
+		{"纯数字按秒", "?timeout=60", "", 60 * time.Second, false},
+		{"超过上限按上限", "?timeout=10m", "", 120 * time.Second, false},
+		{"格式错误", "?timeout=abc", "", 0, true},
+		{"不能为负", "?timeout=-5s", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DRAW_SUBSCRIBE_MAX_WAIT", tt.max)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/"+tt.query, nil)
+			got, err := subscribeTimeout(c)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("subscribeTimeout() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrawsAfter(t *testing.T) {
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025105", Red: []string{"01"}},
+		DrawRecord{Game: "双色球", Issue: "2025106", Red: []string{"01"}},
+		DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"01"}},
+	)
+	tests := []struct {
+		after string
+		want  string
+	}{
+		{"", ""},
+		{"2025105", "2025106,2025107"},
+		{"2025107", ""},
+		{"2024150", "2025105,2025106,2025107"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, d := range drawsAfter("双色球", tt.after) {
+			got = append(got, d.Issue)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("drawsAfter(%q) = %v, want %s", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestDrawHubPublish(t *testing.T) {
+	hub := &drawHub{subs: map[chan DrawRecord]string{}}
+	ssq, cancelSSQ := hub.Subscribe("双色球")
+	dlt, cancelDLT := hub.Subscribe("大乐透")
+	defer cancelDLT()
+
+	hub.Publish([]DrawRecord{{Game: "双色球", Issue: "2025107"}})
+	select {
+	case d := <-ssq:
+		if d.Issue != "2025107" {
+			t.Errorf("received %+v", d)
+		}
+	default:
+		t.Error("subscriber did not receive the draw")
+	}
+	if len(dlt) != 0 {
+		t.Error("other game received the draw")
+	}
+
+	// 来不及读时丢弃，不阻塞写入
+	for i := 0; i < 20; i++ {
+		hub.Publish([]DrawRecord{{Game: "大乐透", Issue: "25100"}})
+	}
+	if len(dlt) != cap(dlt) {
+		t.Errorf("buffered %d, want %d", len(dlt), cap(dlt))
+	}
+
+	cancelSSQ()
+	if len(hub.subs) != 1 {
+		t.Errorf("subs after cancel = %d, want 1", len(hub.subs))
+	}
+}
+
+func TestDrawSubscribeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025106", Red: []string{"01"}},
+		DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02"}},
+	)
+	old := drawFeed
+	t.Cleanup(func() { drawFeed = old })
+	drawFeed = &drawHub{subs: map[chan DrawRecord]string{}}
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		publish     *DrawRecord // 挂起后写入的一期
+		wantStatus  int
+		wantIssue   string
+	}{
+		{"已有更新的一期立即返回", "?after=2025106", "", nil, 200, "2025107"},
+		{"Last-Event-ID 等同 after", "?after=2025100&timeout=10ms", "2025107", nil, 204, ""},
+		{"等到新一期写入", "?after=2025107&timeout=5s", "", &DrawRecord{Game: "双色球", Issue: "2025108"}, 200, "2025108"},
+		{"其他彩种的写入不唤醒", "?after=2025107&timeout=50ms", "", &DrawRecord{Game: "大乐透", Issue: "25100"}, 204, ""},
+		{"超时返回 204", "?after=2025107&timeout=10ms", "", nil, 204, ""},
+		{"timeout 格式错误", "?timeout=x", "", nil, 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/draws/ssq/subscribe"+tt.query, nil)
+			if tt.lastEventID != "" {
+				c.Request.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			c.Params = gin.Params{{Key: "game", Value: "ssq"}}
+			if tt.publish != nil {
+				go func() {
+					for {
+						drawFeed.mu.Lock()
+						n := len(drawFeed.subs)
+						drawFeed.mu.Unlock()
+						if n > 0 {
+							break
+						}
+						time.Sleep(time.Millisecond)
+					}
+					drawFeed.Publish([]DrawRecord{*tt.publish})
+				}()
+			}
+			drawSubscribeHandler(c)
+			if c.Writer.Status() != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", c.Writer.Status(), tt.wantStatus, w.Body)
+			}
+			if tt.wantIssue != "" {
+				var d DrawRecord
+				if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil || d.Issue != tt.wantIssue {
+					t.Errorf("draw = %+v, %v, want issue %s", d, err, tt.wantIssue)
+				}
+			}
+		})
+	}
+	if len(drawFeed.subs) != 0 {
+		t.Errorf("subscribers left behind: %d", len(drawFeed.subs))
+	}
+}
+
+// SSE 先补发 after 之后的各期，再推送新写入的一期
+func TestDrawSubscribeSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t,
+		DrawRecord{Game: "双色球", Issue: "2025106", Red: []string{"01"}},
+		DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02"}},
+	)
+	old := drawFeed
+	t.Cleanup(func() { drawFeed = old })
+	drawFeed = &drawHub{subs: map[chan DrawRecord]string{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/draws/ssq/subscribe?after=2025105", nil).WithContext(ctx)
+	c.Request.Header.Set("Accept", "text/event-stream")
+	c.Params = gin.Params{{Key: "game", Value: "ssq"}}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		drawFeed.Publish([]DrawRecord{{Game: "双色球", Issue: "2025108"}})
+	}()
+	drawSubscribeHandler(c)
+
+	var ids []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "2025106,2025107,2025108" {
+		t.Errorf("event ids = %s, body = %q", got, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SUBSCRIPTIONS: 用户订阅开奖结果推送
+// ==========================================
+
+// 用户（X-User-ID）可以订阅关心的彩种，每期开奖数据同步入库后立即收到开奖号码：
+//
+//	POST   /api/v1/subscriptions      {"games": ["双色球", "dlt"], "email": "...", "phone": "...", "webhook": "https://..."}
+//	GET    /api/v1/subscriptions      当前用户的订阅
+//	DELETE /api/v1/subscriptions/:id
+//
+// 渠道至少填一个：邮件走 SMTP_*，短信用 SMS_TEMPLATE_DRAW_RESULT 模板（变量 game, issue, numbers），
+// webhook 与租户回调相同格式和签名（X-Signature），密钥只在创建时返回一次。发送经过通知子系统，
+// 计入租户的通知用量。同一期只推送一次（之后补全奖金不再推送），订阅之前已开奖的期不补发。
+// 推送只由负责同步开奖数据的实例发出，多副本部署不会重复。订阅保存在 data/subscriptions.json（静态加密）
+
+// maxSubscriptionsPerUser 每个用户最多的订阅数
+const maxSubscriptionsPerUser = 10
+
+// DrawSubscription 一条订阅
+type DrawSubscription struct {
+	ID            string            `json:"id"`
+	Tenant        string            `json:"tenant"`
+	UserID        string            `json:"user_id"`
+	Games         []string          `json:"games"`
+	Email         string            `json:"email,omitempty"`
+	Phone         string            `json:"phone,omitempty"`
+	Webhook       string            `json:"webhook,omitempty"`
+	WebhookSecret string            `json:"-"`
+	Notified      map[string]string `json:"notified,omitempty"` // 彩种 → 已推送的最新期号
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// subscriptionFile 落盘格式，带上不对外返回的 webhook 密钥
+type subscriptionFile struct {
+	DrawSubscription
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// notifiers 该订阅启用的渠道
+func (s DrawSubscription) notifiers() []Notifier {
+	var list []Notifier
+	if s.Email != "" {
+		list = append(list, &emailNotifier{to: s.Email})
+	}
+	if s.Phone != "" {
+		list = append(list, &smsNotifier{phone: s.Phone})
+	}
+	if s.Webhook != "" {
+		list = append(list, &signedWebhookNotifier{hook: SignedHook{URL: s.Webhook, Secret: s.WebhookSecret}})
+	}
+	return list
+}
+
+type subscriptionStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*DrawSubscription
+}
+
+var drawSubscriptions = &subscriptionStore{items: map[string]*DrawSubscription{}}
+
+func (s *subscriptionStore) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	raw, err := readSealedFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []subscriptionFile
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+	for _, f := range list {
+		sub := f.DrawSubscription
+		sub.WebhookSecret = f.WebhookSecret
+		s.items[sub.ID] = &sub
+	}
+	return nil
+}
+
+// persist 调用方需持有锁
+func (s *subscriptionStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]subscriptionFile, 0, len(s.items))
+	for _, sub := range s.items {
+		list = append(list, subscriptionFile{DrawSubscription: *sub, WebhookSecret: sub.WebhookSecret})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	raw, _ := json.MarshalIndent(list, "", "  ")
+	tmp := s.path + ".tmp"
+	if err := writeSealedFile(tmp, raw); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// List 某用户的订阅，按创建时间
+func (s *subscriptionStore) List(tenant, userID string) []DrawSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []DrawSubscription{}
+	for _, sub := range s.items {
+		if sub.Tenant == tenant && sub.UserID == userID {
+			out = append(out, *sub)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+var errTooManySubscriptions = fmt.Errorf("每个用户最多 %d 条订阅", maxSubscriptionsPerUser)
+
+// Add 登记订阅；各彩种从当前最新一期之后开始推送
+func (s *subscriptionStore) Add(sub DrawSubscription) (DrawSubscription, error) {
+	sub.ID, sub.CreatedAt = newJobID(), time.Now()
+	sub.Notified = map[string]string{}
+	for _, game := range sub.Games {
+		if history := draws.History(game); len(history) > 0 {
+			sub.Notified[game] = history[0].Issue
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, old := range s.items {
+		if old.Tenant == sub.Tenant && old.UserID == sub.UserID {
+			n++
+		}
+	}
+	if n >= maxSubscriptionsPerUser {
+		return DrawSubscription{}, errTooManySubscriptions
+	}
+	s.items[sub.ID] = &sub
+	if err := s.persist(); err != nil {
+		delete(s.items, sub.ID)
+		return DrawSubscription{}, err
+	}
+	return sub, nil
+}
+
+// Remove 删除用户自己的订阅，不存在时返回 false
+func (s *subscriptionStore) Remove(tenant, userID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.items[id]
+	if !ok || sub.Tenant != tenant || sub.UserID != userID {
+		return false, nil
+	}
+	delete(s.items, id)
+	return true, s.persist()
+}
+
+// OnDraws 新开奖数据入库后推送给订阅了该彩种、还没收到这一期的用户
+func (s *subscriptionStore) OnDraws(list []DrawRecord) {
+	// 同一批里同一彩种有多期时从旧到新推送
+	sorted := append([]DrawRecord(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool { return issueLess(sorted[i].Issue, sorted[j].Issue) })
+
+	type delivery struct {
+		sub  DrawSubscription
+		draw DrawRecord
+	}
+	var due []delivery
+	s.mu.Lock()
+	for _, d := range sorted {
+		for _, sub := range s.items {
+			if !slices.Contains(sub.Games, d.Game) || !issueLess(sub.Notified[d.Game], d.Issue) {
+				continue
+			}
+			if sub.Notified == nil {
+				sub.Notified = map[string]string{}
+			}
+			sub.Notified[d.Game] = d.Issue
+			due = append(due, delivery{*sub, d})
+		}
+	}
+	if len(due) > 0 {
+		if err := s.persist(); err != nil {
+			log.Printf("保存开奖订阅失败: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, d := range due {
+		notifier.DrawPublished(d.sub, d.draw)
+	}
+}
+
+// drawLines 开奖号码按号码区分行，如 "红球: 01 02 03 04 05 06"
+func drawLines(d DrawRecord) []string {
+	spec, ok := specOf(d.Game)
+	if !ok || spec.Ordered {
+		return []string{"开奖号码: " + strings.Join(append(append([]string(nil), d.Red...), d.Blue...), " ")}
+	}
+	var lines []string
+	for _, z := range spec.Zones {
+		nums := d.Red
+		if z.Field == "blue" {
+			nums = d.Blue
+		}
+		lines = append(lines, z.Label+": "+strings.Join(nums, " "))
+	}
+	return lines
+}
+
+// --- 接口 ---
+
+// SubscriptionRequest POST /api/v1/subscriptions 请求体
+type SubscriptionRequest struct {
+	Games   []string `json:"games"`
+	Email   string   `json:"email"`
+	Phone   string   `json:"phone"`
+	Webhook string   `json:"webhook"`
+}
+
+var (
+	subscriptionEmailRe = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	subscriptionPhoneRe = regexp.MustCompile(`^\+?\d{6,15}$`)
+)
+
+var subscriptionSchema = &jsonSchema{
+	Type:     jsonObject,
+	Required: []string{"games"},
+	Fields: map[string]*jsonSchema{
+		"games":   {Type: jsonArray, Items: &jsonSchema{Type: jsonString}, MinItems: 1, MaxItems: 20},
+		"email":   {Type: jsonString, Pattern: subscriptionEmailRe, PatternMsg: "不是有效的邮箱地址"},
+		"phone":   {Type: jsonString, Pattern: subscriptionPhoneRe, PatternMsg: "不是有效的手机号"},
+		"webhook": {Type: jsonString},
+	},
+}
+
+func subscriptionCreateHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	var req SubscriptionRequest
+	if !bindValidJSON(c, subscriptionSchema, &req) {
+		return
+	}
+	sub := DrawSubscription{
+		Tenant: tenantOf(c), UserID: userID,
+		Email: strings.TrimSpace(req.Email), Phone: strings.TrimSpace(req.Phone), Webhook: strings.TrimSpace(req.Webhook),
+	}
+	for _, g := range req.Games {
+		spec, ok := specOf(g)
+		if !ok {
+			c.JSON(400, gin.H{"error": "不支持的彩种: " + g})
+			return
+		}
+		if !slices.Contains(sub.Games, spec.Name) {
+			sub.Games = append(sub.Games, spec.Name)
+		}
+	}
+	if sub.Email == "" && sub.Phone == "" && sub.Webhook == "" {
+		c.JSON(400, gin.H{"error": "email、phone、webhook 至少填一个"})
+		return
+	}
+	var secret string
+	if sub.Webhook != "" {
+		// 回调地址由用户填写，只允许 https，避免借推送访问内网的 http 服务
+		if u, err := url.Parse(sub.Webhook); err != nil || u.Scheme != "https" || u.Host == "" {
+			c.JSON(400, gin.H{"error": "webhook 需要是 https 地址"})
+			return
+		}
+		b := make([]byte, 24)
+		rand.Read(b)
+		secret = "whsec_" + hex.EncodeToString(b)
+		sub.WebhookSecret = secret
+	}
+	sub, err := drawSubscriptions.Add(sub)
+	if errors.Is(err, errTooManySubscriptions) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "保存订阅失败: " + err.Error()})
+		return
+	}
+	resp := gin.H{"subscription": sub}
+	if secret != "" {
+		resp["webhook_secret"] = secret
+	}
+	c.JSON(201, resp)
+}
+
+func subscriptionListHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{"subscriptions": drawSubscriptions.List(tenantOf(c), userID)})
+}
+
+func subscriptionDeleteHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	removed, err := drawSubscriptions.Remove(tenantOf(c), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "保存订阅失败: " + err.Error()})
+		return
+	}
+	if !removed {
+		c.JSON(404, gin.H{"error": "订阅不存在"})
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestSubscriptions 换成临时目录里的开奖订阅
+func useTestSubscriptions(t *testing.T) string {
+	t.Helper()
+	old := drawSubscriptions
+	t.Cleanup(func() { drawSubscriptions = old })
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	drawSubscriptions = &subscriptionStore{path: path, items: map[string]*DrawSubscription{}}
+	return path
+}
+
+func TestSubscriptionStore(t *testing.T) {
+	useTestAtRest(t, testMasterKey(1))
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025106"}, DrawRecord{Game: "双色球", Issue: "2025107"})
+	path := useTestSubscriptions(t)
+	s := drawSubscriptions
+
+	first, err := s.Add(DrawSubscription{Tenant: "shop-a", UserID: "u1", Games: []string{"双色球", "大乐透"}, Webhook: "https://example.com/hook", WebhookSecret: "whsec_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 从当前最新一期之后开始推送，还没有开奖数据的彩种从头开始
+	if first.ID == "" || first.Notified["双色球"] != "2025107" || first.Notified["大乐透"] != "" {
+		t.Errorf("Add() = %+v", first)
+	}
+	for i := 1; i < maxSubscriptionsPerUser; i++ {
+		if _, err := s.Add(DrawSubscription{Tenant: "shop-a", UserID: "u1", Games: []string{"双色球"}, Email: "u1@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		tenant  string
+		user    string
+		wantErr error
+	}{
+		{"超过每个用户的上限", "shop-a", "u1", errTooManySubscriptions},
+		{"其他用户不受影响", "shop-a", "u2", nil},
+		{"其他租户的同名用户不受影响", "shop-b", "u1", nil},
+	}
+	for _, st := range steps {
+		if _, err := s.Add(DrawSubscription{Tenant: st.tenant, UserID: st.user, Games: []string{"双色球"}, Phone: "13800000000"}); err != st.wantErr {
+			t.Errorf("%s: Add() error = %v, want %v", st.name, err, st.wantErr)
+		}
+	}
+
+	removals := []struct {
+		name   string
+		tenant string
+		user   string
+		want   bool
+	}{
+		{"其他用户不能删除", "shop-a", "u2", false},
+		{"其他租户不能删除", "shop-b", "u1", false},
+		{"删除自己的订阅", "shop-a", "u1", true},
+		{"已经删除", "shop-a", "u1", false},
+	}
+	for _, r := range removals {
+		if ok, err := s.Remove(r.tenant, r.user, first.ID); ok != r.want || err != nil {
+			t.Errorf("%s: Remove() = %v, %v, want %v", r.name, ok, err, r.want)
+		}
+	}
+	if got := len(s.List("shop-a", "u1")); got != maxSubscriptionsPerUser-1 {
+		t.Errorf("List() = %d subscriptions", got)
+	}
+
+	// 重新加载后 webhook 密钥仍在，但不随订阅返回
+	s.Add(DrawSubscription{Tenant: "shop-c", UserID: "u3", Games: []string{"双色球"}, Webhook: "https://example.com/hook", WebhookSecret: "whsec_3"})
+	loaded := &subscriptionStore{items: map[string]*DrawSubscription{}}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	list := loaded.List("shop-c", "u3")
+	if len(list) != 1 || list[0].WebhookSecret != "whsec_3" || list[0].Notified["双色球"] != "2025107" {
+		t.Fatalf("loaded = %+v", list)
+	}
+	if raw, _ := json.Marshal(list[0]); strings.Contains(string(raw), "whsec_3") {
+		t.Errorf("secret exposed: %s", raw)
+	}
+}
+
+func TestDrawLines(t *testing.T) {
+	tests := []struct {
+		d    DrawRecord
+		want string
+	}{
+		{DrawRecord{Game: "双色球", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}, "红球: 02 11 15 21 28 33|蓝球: 07"},
+		{DrawRecord{Game: "大乐透", Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}}, "前区: 01 02 03 04 05|后区: 06 07"},
+		{DrawRecord{Game: "排列5", Red: []string{"1", "2", "3", "4", "5"}}, "开奖号码: 1 2 3 4 5"},
+		{DrawRecord{Game: "未知", Red: []string{"1"}, Blue: []string{"2"}}, "开奖号码: 1 2"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(drawLines(tt.d), "|"); got != tt.want {
+			t.Errorf("drawLines(%s) = %s, want %s", tt.d.Game, got, tt.want)
+		}
+	}
+}
+
+// 每期只推送一次，同一批内多期从旧到新推送，订阅之前已开奖的期不补发
+func TestSubscriptionOnDraws(t *testing.T) {
+	useTestAtRest(t, "")
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107"})
+	useTestSubscriptions(t)
+	useTestBilling(t)
+
+	got := make(chan webhookPayload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &p)
+		got <- p
+	}))
+	defer srv.Close()
+	for _, user := range []string{"u1", "u2"} {
+		games := []string{"双色球"}
+		if user == "u2" {
+			games = []string{"大乐透"}
+		}
+		if _, err := drawSubscriptions.Add(DrawSubscription{Tenant: "shop-a", UserID: user, Games: games, Webhook: srv.URL, WebhookSecret: "s"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name  string
+		draws []DrawRecord
+		want  []string // 按推送顺序的标题
+	}{
+		{"订阅前已开奖的期不补发", []DrawRecord{{Game: "双色球", Issue: "2025107"}}, nil},
+		{"新一期", []DrawRecord{{Game: "双色球", Issue: "2025108", Red: []string{"01"}, Blue: []string{"02"}, Pool: 100}}, []string{"双色球 第2025108期开奖"}},
+		{"补全奖金不再推送", []DrawRecord{{Game: "双色球", Issue: "2025108"}}, nil},
+		{"没有订阅的彩种", []DrawRecord{{Game: "排列5", Issue: "25108"}}, nil},
+		{"同一批多期从旧到新", []DrawRecord{{Game: "双色球", Issue: "2025110"}, {Game: "双色球", Issue: "2025109"}, {Game: "大乐透", Issue: "25108"}}, []string{"双色球 第2025109期开奖", "双色球 第2025110期开奖", "大乐透 第25108期开奖"}},
+	}
+	for _, st := range steps {
+		drawSubscriptions.OnDraws(st.draws)
+		var titles []string
+		for range st.want {
+			select {
+			case p := <-got:
+				titles = append(titles, p.Title)
+				if p.Kind != EventDrawPublished || p.Tenant != "shop-a" {
+					t.Errorf("%s: payload = %+v", st.name, p)
+				}
+				if p.Title == "双色球 第2025108期开奖" && strings.Join(p.Lines, "|") != "红球: 01|蓝球: 02|奖池: 100元" {
+					t.Errorf("%s: lines = %v", st.name, p.Lines)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: got %v, want %v", st.name, titles, st.want)
+			}
+		}
+		select {
+		case p := <-got:
+			t.Errorf("%s: unexpected push %s", st.name, p.Title)
+		case <-time.After(50 * time.Millisecond):
+		}
+		// 同一批的推送并发发出，只比较集合
+		for _, w := range st.want {
+			if !strings.Contains(strings.Join(titles, ","), w) {
+				t.Errorf("%s: pushes = %v, want %v", st.name, titles, st.want)
+			}
+		}
+	}
+	if sub := drawSubscriptions.List("shop-a", "u1")[0]; sub.Notified["双色球"] != "2025110" {
+		t.Errorf("notified = %v", sub.Notified)
+	}
+}
+
+func TestSubscriptionHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestAtRest(t, "")
+	useTestDraws(t)
+	useTestSubscriptions(t)
+
+	tests := []struct {
+		name       string
+		user       string
+		body       string
+		wantStatus int
+		wantGames  string
+		wantSecret bool
+	}{
+		{"邮件订阅", "u1", `{"games": ["ssq", "双色球", "大乐透"], "email": "u1@example.com"}`, 201, "双色球,大乐透", false},
+		{"webhook 返回密钥", "u1", `{"games": ["双色球"], "webhook": "https://example.com/hook"}`, 201, "双色球", true},
+		{"短信订阅", "u1", `{"games": ["排列5"], "phone": "+8613800000000"}`, 201, "排列5", false},
+		{"没有用户身份", "", `{"games": ["双色球"], "email": "u1@example.com"}`, 401, "", false},
+		{"不支持的彩种", "u1", `{"games": ["彩票"], "email": "u1@example.com"}`, 400, "", false},
+		{"没有渠道", "u1", `{"games": ["双色球"]}`, 400, "", false},
+		{"缺少彩种", "u1", `{"games": [], "email": "u1@example.com"}`, 400, "", false},
+		{"邮箱格式错误", "u1", `{"games": ["双色球"], "email": "u1"}`, 400, "", false},
+		{"手机号格式错误", "u1", `{"games": ["双色球"], "phone": "138-0000"}`, 400, "", false},
+		{"webhook 不是 https", "u1", `{"games": ["双色球"], "webhook": "http://10.0.0.1/hook"}`, 400, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/subscriptions", bytes.NewBufferString(tt.body))
+			if tt.user != "" {
+				c.Set("auth_session", "s1")
+				c.Set("auth_user", tt.user)
+			}
+			subscriptionCreateHandler(c)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != 201 {
+				return
+			}
+			var resp struct {
+				Subscription  DrawSubscription `json:"subscription"`
+				WebhookSecret string           `json:"webhook_secret"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if got := strings.Join(resp.Subscription.Games, ","); got != tt.wantGames {
+				t.Errorf("games = %s, want %s", got, tt.wantGames)
+			}
+			if strings.HasPrefix(resp.WebhookSecret, "whsec_") != tt.wantSecret {
+				t.Errorf("response = %s", w.Body)
+			}
+		})
+	}
+
+	// 列出与删除只涉及自己的订阅
+	list := func(user string) []DrawSubscription {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/subscriptions", nil)
+		c.Set("auth_session", "s1")
+		c.Set("auth_user", user)
+		subscriptionListHandler(c)
+		var resp struct {
+			Subscriptions []DrawSubscription `json:"subscriptions"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Subscriptions
+	}
+	subs := list("u1")
+	if len(subs) != 3 || len(list("u2")) != 0 {
+		t.Fatalf("u1 has %d subscriptions", len(subs))
+	}
+	deletes := []struct {
+		user string
+		want int
+	}{
+		{"u2", 404},
+		{"u1", 204},
+		{"u1", 404},
+	}
+	for _, d := range deletes {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("DELETE", "/api/v1/subscriptions/"+subs[0].ID, nil)
+		c.Params = gin.Params{{Key: "id", Value: subs[0].ID}}
+		c.Set("auth_session", "s1")
+		c.Set("auth_user", d.user)
+		subscriptionDeleteHandler(c)
+		if c.Writer.Status() != d.want {
+			t.Errorf("DELETE by %s = %d, want %d", d.user, c.Writer.Status(), d.want)
+		}
+	}
+	if got := len(list("u1")); got != 2 {
+		t.Errorf("after delete: %d subscriptions", got)
+	}
+}
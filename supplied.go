@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// SUPPLIED: 调用方提供开奖号码
+// ==========================================
+
+// 测试、开奖数据尚未同步，或单位内部按同样规则开奖的合买池，可以在请求里直接给出开奖号码：
+// /api/v1/scan 的表单字段 winning、/api/v1/verify 的 JSON 字段 winning，结构同 DrawRecord，
+// 可以是单个对象或数组。提供的号码只对本次请求生效，不写入开奖数据、不进验奖缓存，
+// 结果不记入扫描历史、不发中奖通知
+
+const suppliedDrawWarning = "未使用官方开奖数据：按调用方提供的开奖号码验奖"
+
+type suppliedDrawsKey struct{}
+
+func withSuppliedDraws(ctx context.Context, list []DrawRecord) context.Context {
+	if len(list) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, suppliedDrawsKey{}, list)
+}
+
+// suppliedDraw 找本请求中与彩种、期号对应的开奖号码；未写 game 的唯一一条适用于所有彩种，未写 issue 的适用于所有期号
+func suppliedDraw(ctx context.Context, lotteryType, issue string) (DrawRecord, bool) {
+	list, _ := ctx.Value(suppliedDrawsKey{}).([]DrawRecord)
+	game := canonicalGame(lotteryType)
+	for _, d := range list {
+		if d.Game == "" && len(list) > 1 {
+			continue
+		}
+		if d.Game != "" && canonicalGame(d.Game) != game {
+			continue
+		}
+		if d.Issue != "" && strings.TrimSpace(d.Issue) != strings.TrimSpace(issue) {
+			continue
+		}
+		d.Game = game
+		if d.Issue == "" {
+			d.Issue = strings.TrimSpace(issue)
+		}
+		return d, true
+	}
+	return DrawRecord{}, false
+}
+
+// parseSuppliedDraws 解析单个对象或数组，并检查号码个数
+func parseSuppliedDraws(raw []byte) ([]DrawRecord, error) {
+	raw = []byte(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var list []DrawRecord
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+	} else {
+		var d DrawRecord
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return nil, err
+		}
+		list = []DrawRecord{d}
+	}
+	for i, d := range list {
+		if len(list) > 1 && d.Game == "" {
+			return nil, fmt.Errorf("第%d组开奖号码缺少 game", i+1)
+		}
+		if len(d.Red) == 0 {
+			return nil, fmt.Errorf("第%d组开奖号码缺少 red", i+1)
+		}
+		if d.Game == "" {
+			continue
+		}
+		spec, ok := specOf(d.Game)
+		if !ok {
+			return nil, fmt.Errorf("不支持的彩种: %s", d.Game)
+		}
+		for _, z := range spec.Zones {
+			if n, want := len(z.numbers(d)), z.Pick; n != want {
+				return nil, fmt.Errorf("第%d组开奖号码%s应为 %d 个，实际 %d 个", i+1, z.Label, want, n)
+			}
+		}
+	}
+	return list, nil
+}
+
+// suppliedDrawsOf 读取 /api/v1/scan 表单里的 winning 字段
+func suppliedDrawsOf(c *gin.Context) ([]DrawRecord, error) {
+	return parseSuppliedDraws([]byte(c.PostForm("winning")))
+}
+
+// winning 结果对应的开奖号码：请求里提供过的优先，否则查开奖数据
+func (res VerificationResult) winning() (WinningNumbers, bool) {
+	if res.SuppliedDraw != nil {
+		return res.SuppliedDraw.winning(), true
+	}
+	return lookupWinningNumbers(context.Background(), res.OCRData.Type, res.OCRData.Issue)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseSuppliedDraws(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr string
+	}{
+		{"未提供", "", 0, ""},
+		{"null", "null", 0, ""},
+		{"单个对象", `{"red": ["01","02","03","04","05","06"], "blue": ["07"]}`, 1, ""},
+		{"数组", `[{"game": "ssq", "red": ["01","02","03","04","05","06"], "blue": ["07"]}, {"game": "大乐透", "red": ["01","02","03","04","05"], "blue": ["01","02"]}]`, 2, ""},
+		{"多组时必须写彩种", `[{"red": ["01"]}, {"game": "ssq", "red": ["01"]}]`, 0, "第1组开奖号码缺少 game"},
+		{"缺少号码", `{"game": "双色球"}`, 0, "缺少 red"},
+		{"不支持的彩种", `{"game": "未知彩种", "red": ["01"]}`, 0, "不支持的彩种"},
+		{"号码个数不对", `{"game": "双色球", "red": ["01","02","03","04","05"], "blue": ["07"]}`, 0, "应为 6 个，实际 5 个"},
+		{"JSON 格式错误", `{"red": [`, 0, "unexpected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSuppliedDraws([]byte(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSuppliedDraws() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(got) != tt.want {
+				t.Errorf("parseSuppliedDraws() = %d draws, %v, want %d", len(got), err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppliedDraw(t *testing.T) {
+	ssq := DrawRecord{Game: "ssq", Issue: "2025107", Red: []string{"01"}}
+	dlt := DrawRecord{Game: "大乐透", Red: []string{"02"}}
+	anyGame := DrawRecord{Red: []string{"03"}}
+	tests := []struct {
+		name      string
+		list      []DrawRecord
+		game      string
+		issue     string
+		wantOK    bool
+		wantRed   string
+		wantIssue string
+	}{
+		{"未提供", nil, "双色球", "2025107", false, "", ""},
+		{"按彩种代码匹配", []DrawRecord{ssq, dlt}, "双色球", "2025107", true, "01", "2025107"},
+		{"期号不同", []DrawRecord{ssq, dlt}, "双色球", "2025108", false, "", ""},
+		{"未写期号适用于所有期号", []DrawRecord{ssq, dlt}, "大乐透", "25100", true, "02", "25100"},
+		{"唯一一条未写彩种的适用于所有彩种", []DrawRecord{anyGame}, "排列5", "25100", true, "03", "25100"},
+		{"彩种不同", []DrawRecord{dlt}, "双色球", "2025107", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := suppliedDraw(withSuppliedDraws(context.Background(), tt.list), tt.game, tt.issue)
+			if ok != tt.wantOK {
+				t.Fatalf("suppliedDraw() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (strings.Join(d.Red, " ") != tt.wantRed || d.Issue != tt.wantIssue || d.Game != canonicalGame(tt.game)) {
+				t.Errorf("suppliedDraw() = %+v", d)
+			}
+		})
+	}
+}
+
+// 按提供的号码验奖：带提示、回显号码、不读也不写验奖缓存
+func TestVerifySuppliedDraw(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	lottery := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+		{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}, Multiplier: 1},
+	}}
+	supplied := []DrawRecord{{Red: []string{"01", "03", "05", "06", "08", "09"}, Blue: []string{"07"}}}
+
+	tests := []struct {
+		name         string
+		supplied     []DrawRecord
+		wantPrize    Fen
+		wantSupplied bool
+	}{
+		{"官方开奖数据", nil, 5000000 * Yuan, false},
+		{"提供的号码不用缓存", supplied, 5 * Yuan, true},
+		{"之后仍按官方数据", nil, 5000000 * Yuan, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRequestBudget(withSuppliedDraws(context.Background(), tt.supplied))
+			defer b.Done()
+			res, err := verifyLottery(b, 0, lottery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.TotalPrize != tt.wantPrize || (res.SuppliedDraw != nil) != tt.wantSupplied {
+				t.Errorf("verifyLottery() prize = %s supplied = %v", res.TotalPrize, res.SuppliedDraw)
+			}
+			hasWarning := strings.Contains(strings.Join(res.Warnings, "；"), suppliedDrawWarning)
+			if hasWarning != tt.wantSupplied {
+				t.Errorf("warnings = %v", res.Warnings)
+			}
+			if win, _ := res.winning(); tt.wantSupplied && win.Red[0] != "01" {
+				t.Errorf("winning() = %+v, want supplied numbers", win)
+			}
+		})
+	}
+}
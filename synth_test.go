@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/jpeg"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSynthRows(t *testing.T) {
+	tests := []struct {
+		in      string
+		lo, hi  int
+		wantErr bool
+	}{
+		{"1-5", 1, 5, false},
+		{"3", 3, 3, false},
+		{"2-2", 2, 2, false},
+		{"0-3", 0, 0, true},
+		{"5-1", 0, 0, true},
+		{"a-3", 0, 0, true},
+		{"1-", 0, 0, true},
+	}
+	for _, tt := range tests {
+		lo, hi, err := parseSynthRows(tt.in)
+		if (err != nil) != tt.wantErr || lo != tt.lo || hi != tt.hi {
+			t.Errorf("parseSynthRows(%q) = %d, %d, %v", tt.in, lo, hi, err)
+		}
+	}
+}
+
+func TestSplitFlagList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"双色球,大乐透", "双色球|大乐透"},
+		{" dot , bold ,", "dot|bold"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(splitFlagList(tt.in), "|"); got != tt.want {
+			t.Errorf("splitFlagList(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 生成的票和开奖号码都符合彩种规则，金额与行、倍数、追加一致
+func TestSynthTicket(t *testing.T) {
+	tests := []struct {
+		game       string
+		rows       int
+		wantDigits int
+	}{
+		{"双色球", 1, 7},
+		{"大乐透", 5, 5},
+		{"排列5", 3, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.game, func(t *testing.T) {
+			spec := gameSpecs[tt.game]
+			rng := rand.New(rand.NewPCG(7, 7))
+			for n := 0; n < 20; n++ {
+				lottery, draw := synthTicket(rng, spec, tt.rows)
+				if lottery.Type != tt.game || draw.Game != tt.game || lottery.Issue != draw.Issue || len(draw.Issue) != tt.wantDigits {
+					t.Fatalf("lottery %s %s, draw %s %s", lottery.Type, lottery.Issue, draw.Game, draw.Issue)
+				}
+				if len(lottery.Tickets) != tt.rows || !ticketsFitGame(lottery.Tickets, spec) {
+					t.Fatalf("tickets = %+v", lottery.Tickets)
+				}
+				if !ticketsFitGame([]UserTicket{{Red: draw.Red, Blue: draw.Blue}}, spec) {
+					t.Fatalf("draw = %+v", draw)
+				}
+				var amount int64
+				for _, row := range lottery.Tickets {
+					price := int64(2)
+					if row.AddOn {
+						price = 3
+					}
+					amount += price * int64(row.Multiplier)
+					if row.AddOn && tt.game != "大乐透" {
+						t.Errorf("add-on on %s", tt.game)
+					}
+				}
+				if lottery.Amount != amount || len(lottery.Serial) != 18 || len(lottery.Station) != 8 {
+					t.Fatalf("lottery = %+v", lottery)
+				}
+			}
+		})
+	}
+}
+
+func TestSynthPick(t *testing.T) {
+	red := gameSpecs["双色球"].Zones[0]
+	pos := gameSpecs["排列5"].Zones[2]
+	drawn := []string{"02", "11", "15", "21", "28", "33"}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for n := 0; n < 50; n++ {
+		nums := synthPick(rng, red, drawn)
+		seen := map[string]bool{}
+		for i, num := range nums {
+			if seen[num] || i > 0 && nums[i-1] > num || len(num) != 2 || num < "01" || num > "33" {
+				t.Fatalf("synthPick(red) = %v", nums)
+			}
+			seen[num] = true
+		}
+		if len(nums) != red.Pick {
+			t.Fatalf("synthPick(red) = %v", nums)
+		}
+		if got := synthPick(rng, pos, []string{"1", "2", "3", "4", "5"}); len(got) != 1 || len(got[0]) != 1 {
+			t.Fatalf("synthPick(pos3) = %v", got)
+		}
+	}
+}
+
+func TestLoadSynthFont(t *testing.T) {
+	dir := t.TempDir()
+	// 一个 8×2 的字形“中”，一个缺少 ENCODING 的字形会被跳过
+	bdf := writeTestFile(t, dir, "tiny.bdf", []byte(strings.Join([]string{
+		"STARTFONT 2.1", "FONT_ASCENT 10", "FONT_DESCENT 2",
+		"STARTCHAR uni4E2D", "ENCODING 20013", "BBX 8 2 0 -1", "BITMAP", "81", "FF", "ENDCHAR",
+		"STARTCHAR none", "BBX 8 1 0 0", "BITMAP", "FF", "ENDCHAR",
+		"ENDFONT",
+	}, "\n")))
+	empty := writeTestFile(t, dir, "empty.bdf", []byte("STARTFONT 2.1\nENDFONT\n"))
+
+	tests := []struct {
+		name       string
+		font       string
+		wantErr    bool
+		wantGlyphs int
+		wantStyle  string
+	}{
+		{"圆点", "dot", false, len(builtinGlyphRows), "dot"},
+		{"加粗", "bold", false, len(builtinGlyphRows), "bold"},
+		{"BDF", bdf, false, 1, "block"},
+		{"没有字形", empty, true, 0, ""},
+		{"文件不存在", filepath.Join(dir, "missing.bdf"), true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := loadSynthFont(tt.font)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadSynthFont() err = %v", err)
+			}
+			if err == nil && (len(f.Glyphs) != tt.wantGlyphs || f.Style != tt.wantStyle) {
+				t.Errorf("font = %s %d glyphs", f.Style, len(f.Glyphs))
+			}
+		})
+	}
+
+	f, _ := loadSynthFont(bdf)
+	g := f.Glyphs['中']
+	if g.W != 8 || g.H != 2 || g.YOff != -1 || !g.Bits[0] || g.Bits[1] || !g.Bits[7] || !g.Bits[8] || !g.Bits[15] {
+		t.Errorf("glyph = %+v", g)
+	}
+	if f.Ascent != 10 || f.width("中中") != 18 || f.advance('x') != 5 || !f.has("中") || f.has("中国") {
+		t.Errorf("metrics: ascent %d width %d", f.Ascent, f.width("中中"))
+	}
+	dot, _ := loadSynthFont("dot")
+	if dot.width("AB") != 12 || dot.lineHeight() != 11 {
+		t.Errorf("builtin metrics: width %d line %d", dot.width("AB"), dot.lineHeight())
+	}
+}
+
+// 内置字体没有汉字，票面改用英文写法
+func TestSynthLines(t *testing.T) {
+	dot, _ := loadSynthFont("dot")
+	lottery := LotteryData{Type: "大乐透", Issue: "25107", SaleTime: "2025-09-15 10:00:00", Serial: "123", Station: "456", Amount: 9,
+		Tickets: []UserTicket{
+			{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}, Multiplier: 1},
+			{Red: []string{"01", "02", "03", "04", "05"}, Blue: []string{"06", "07"}, Multiplier: 2, AddOn: true},
+		}}
+	lines := synthLines(dot, lottery)
+	want := []string{"CHINA SPORTS LOTTERY", "DLT", "ISSUE: 25107", "SOLD: 2025-09-15 10:00:00", "SERIAL: 123", strings.Repeat("-", 30),
+		"A. 01 02 03 04 05 + 06 07", "B. 01 02 03 04 05 + 06 07 *2 ADD", strings.Repeat("-", 30), "TOTAL: 9", "STATION: 456"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	for _, l := range lines {
+		if !dot.has(l) {
+			t.Errorf("builtin font missing glyphs for %q", l)
+		}
+	}
+	pl5 := synthLines(dot, LotteryData{Type: "排列5", Tickets: []UserTicket{{Red: []string{"1", "2", "3", "4", "5"}, Multiplier: 1}}})
+	if pl5[6] != "A. 1  2  3  4  5" {
+		t.Errorf("ordered row = %q", pl5[6])
+	}
+}
+
+func TestRunSynth(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    synthOptions
+		wantErr string
+	}{
+		{"张数为 0", synthOptions{Count: 0, Fonts: []string{"dot"}}, "生成张数"},
+		{"不支持的彩种", synthOptions{Count: 1, Games: []string{"快乐8"}, Fonts: []string{"dot"}}, "不支持的彩种"},
+		{"没有字体", synthOptions{Count: 1, Games: []string{"ssq"}}, "至少需要一种字体"},
+		{"字体加载失败", synthOptions{Count: 1, Games: []string{"ssq"}, Fonts: []string{"missing.bdf"}}, "加载字体"},
+	}
+	for _, tt := range tests {
+		tt.opts.Out, tt.opts.MinRows, tt.opts.MaxRows = t.TempDir(), 1, 1
+		if err := runSynth(tt.opts); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// 同一个 seed 生成的图片和答案完全相同
+	opts := synthOptions{Count: 3, Games: []string{"ssq", "大乐透"}, MinRows: 1, MaxRows: 3, Fonts: []string{"dot", "bold"}, Noise: 0.3, Rotate: 5, Seed: 42}
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		opts.Out = dir
+		if err := runSynth(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= opts.Count; i++ {
+		for _, ext := range []string{".jpg", ".json"} {
+			name := "synth_000" + string(rune('0'+i)) + ext
+			x, err := os.ReadFile(filepath.Join(a, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			y, _ := os.ReadFile(filepath.Join(b, name))
+			if !bytes.Equal(x, y) {
+				t.Errorf("%s differs between runs with the same seed", name)
+			}
+		}
+		raw, _ := os.ReadFile(filepath.Join(a, "synth_000"+string(rune('0'+i))+".json"))
+		var truth synthTruth
+		if err := json.Unmarshal(raw, &truth); err != nil {
+			t.Fatal(err)
+		}
+		rows := len(truth.Lotteries[0].Tickets)
+		if truth.Image != "synth_000"+string(rune('0'+i))+".jpg" || truth.Draw.Game != truth.Lotteries[0].Type ||
+			rows < 1 || rows > 3 || truth.Rotation < -5 || truth.Rotation > 5 || truth.Noise != 0.3 {
+			t.Errorf("truth = %s", raw)
+		}
+		if truth.Draw.Game != "双色球" && truth.Draw.Game != "大乐透" {
+			t.Errorf("game = %s", truth.Draw.Game)
+		}
+		img, _ := os.ReadFile(filepath.Join(a, truth.Image))
+		if _, err := jpeg.Decode(bytes.NewReader(img)); err != nil {
+			t.Errorf("%s: %v", truth.Image, err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// SYNTHCHECK: 合成票面端到端准确率 (--synth-check)
+// ==========================================
+
+// 把 --synth 生成的图片逐张提交给运行中的服务，走完整的识别+验奖流程，与标准答案比对：
+//
+//	./lottery-server --synth-check testdata/synth --target http://127.0.0.1:8080/api/v1/scan -c 4 --synth-label v2.3.0
+//
+// 每张图片带上标准答案里的开奖号码（winning）提交 /api/v1/scan；同一份答案的号码再提交 /api/v1/verify，
+// 两边奖金一致才算验奖正确，这样派奖活动、浮动奖金等服务端配置不影响比对。统计项：
+//
+//	game / issue   彩种、期号识别正确的比例
+//	rows / numbers 号码行完全正确的比例、单个号码正确的比例（按位置比较）
+//	prize          奖金与答案一致的比例
+//	ticket         彩种、期号、全部号码行和倍数都正确的比例
+//
+// 报告保存在 <目录>/reports/<label>.json，并与此前各次报告对比，版本之间的准确率变化一目了然。
+// 服务开启了 API Key 鉴权时用 SYNTH_API_KEY 指定
+
+type synthCheckOptions struct {
+	Dir         string
+	Target      string
+	Concurrency int
+	Label       string
+	Timeout     time.Duration
+}
+
+// SynthReport 一次准确率测试的结果
+type SynthReport struct {
+	Label      string          `json:"label"`
+	Time       time.Time       `json:"time"`
+	Target     string          `json:"target"`
+	Images     int             `json:"images"`
+	Failed     int             `json:"failed"` // 请求失败或无识别结果
+	GameAcc    float64         `json:"game_acc"`
+	IssueAcc   float64         `json:"issue_acc"`
+	RowAcc     float64         `json:"row_acc"`
+	NumberAcc  float64         `json:"number_acc"`
+	PrizeAcc   float64         `json:"prize_acc"`
+	TicketAcc  float64         `json:"ticket_acc"`
+	P50        string          `json:"p50"`
+	P95        string          `json:"p95"`
+	Mismatches []SynthMismatch `json:"mismatches,omitempty"`
+}
+
+// SynthMismatch 一张没有完全识别正确的图片
+type SynthMismatch struct {
+	Image   string   `json:"image"`
+	Reasons []string `json:"reasons"`
+}
+
+// synthOutcome 一张图片的比对结果
+type synthOutcome struct {
+	image                         string
+	failed                        bool
+	game, issue, prize, ticket    bool
+	rows, rowsOK, numbers, numsOK int
+	reasons                       []string
+	latency                       time.Duration
+}
+
+func runSynthCheck(opts synthCheckOptions) error {
+	truths, err := loadSynthTruths(opts.Dir)
+	if err != nil {
+		return err
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	base := strings.TrimSuffix(strings.TrimRight(opts.Target, "/"), "/api/v1/scan")
+	client := &http.Client{Timeout: opts.Timeout}
+
+	outcomes := make([]synthOutcome, len(truths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcomes[i] = checkSynthImage(client, base, opts.Dir, truths[i])
+			}
+		}()
+	}
+	for i := range truths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := summarizeSynth(outcomes)
+	report.Label, report.Time, report.Target = opts.Label, time.Now(), base
+	if report.Label == "" {
+		report.Label = report.Time.Format("20060102-150405")
+	}
+	dir := filepath.Join(opts.Dir, "reports")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, report.Label+".json"), raw, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("目标: %s  图片: %d 张  失败: %d  p50: %s  p95: %s\n", base, report.Images, report.Failed, report.P50, report.P95)
+	for _, m := range report.Mismatches {
+		fmt.Printf("  %s: %s\n", m.Image, strings.Join(m.Reasons, "；"))
+	}
+	return printSynthTrend(dir)
+}
+
+// loadSynthTruths 读取目录下所有标准答案，按文件名排序
+func loadSynthTruths(dir string) ([]synthTruth, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var truths []synthTruth
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var t synthTruth
+		if err := json.Unmarshal(raw, &t); err != nil || t.Image == "" || len(t.Lotteries) == 0 {
+			continue
+		}
+		truths = append(truths, t)
+	}
+	if len(truths) == 0 {
+		return nil, fmt.Errorf("目录 %s 下没有合成票面的标准答案，先用 --synth 生成", dir)
+	}
+	return truths, nil
+}
+
+// checkSynthImage 提交一张图片并与答案比对
+func checkSynthImage(client *http.Client, base, dir string, truth synthTruth) synthOutcome {
+	out := synthOutcome{image: truth.Image}
+	fail := func(reason string) synthOutcome {
+		out.failed, out.reasons = true, []string{reason}
+		return out
+	}
+	data, err := os.ReadFile(filepath.Join(dir, truth.Image))
+	if err != nil {
+		return fail("读取图片失败: " + err.Error())
+	}
+	winning, _ := json.Marshal(truth.Draw)
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("image", truth.Image)
+	part.Write(data)
+	w.WriteField("winning", string(winning))
+	w.Close()
+	start := time.Now()
+	var got []VerificationResult
+	if err := synthPost(client, base+"/api/v1/scan", w.FormDataContentType(), body.Bytes(), &got); err != nil {
+		return fail("识别: " + err.Error())
+	}
+	out.latency = time.Since(start)
+	if len(got) == 0 {
+		return fail("无识别结果")
+	}
+
+	payload, _ := json.Marshal(map[string]any{"lotteries": truth.Lotteries, "winning": json.RawMessage(winning)})
+	var want []VerificationResult
+	if err := synthPost(client, base+"/api/v1/verify", "application/json", payload, &want); err != nil {
+		return fail("答案验奖: " + err.Error())
+	}
+
+	expect := truth.Lotteries[0]
+	actual := got[0].OCRData
+	out.game = canonicalGame(actual.Type) == canonicalGame(expect.Type)
+	out.issue = strings.TrimSpace(actual.Issue) == expect.Issue
+	if !out.game {
+		out.reasons = append(out.reasons, fmt.Sprintf("彩种 %s ≠ %s", actual.Type, expect.Type))
+	}
+	if !out.issue {
+		out.reasons = append(out.reasons, fmt.Sprintf("期号 %s ≠ %s", actual.Issue, expect.Issue))
+	}
+	if len(actual.Tickets) != len(expect.Tickets) {
+		out.reasons = append(out.reasons, fmt.Sprintf("识别出 %d 行，应为 %d 行", len(actual.Tickets), len(expect.Tickets)))
+	}
+	for i, t := range expect.Tickets {
+		out.rows++
+		nums := append(append([]string(nil), t.Red...), t.Blue...)
+		out.numbers += len(nums)
+		if i >= len(actual.Tickets) {
+			continue
+		}
+		a := actual.Tickets[i]
+		gotNums := append(append([]string(nil), a.Red...), a.Blue...)
+		for j, n := range nums {
+			if j < len(gotNums) && strings.TrimSpace(gotNums[j]) == n {
+				out.numsOK++
+			}
+		}
+		if rowKey(a) == rowKey(t) && max(1, a.Multiplier) == t.Multiplier && a.AddOn == t.AddOn {
+			out.rowsOK++
+		} else {
+			out.reasons = append(out.reasons, fmt.Sprintf("第%d行不一致", i+1))
+		}
+	}
+
+	var gotPrize, wantPrize Fen
+	for _, r := range got {
+		gotPrize += r.TotalPrize
+	}
+	for _, r := range want {
+		wantPrize += r.TotalPrize
+	}
+	out.prize = gotPrize == wantPrize
+	if !out.prize {
+		out.reasons = append(out.reasons, fmt.Sprintf("奖金 %s ≠ %s", gotPrize, wantPrize))
+	}
+	out.ticket = out.game && out.issue && out.rowsOK == out.rows && len(actual.Tickets) == len(expect.Tickets)
+	return out
+}
+
+// synthPost 发送请求并解析 JSON 响应
+func synthPost(client *http.Client, url, contentType string, body []byte, out any) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if key := os.Getenv("SYNTH_API_KEY"); key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// summarizeSynth 汇总各张图片的比对结果；失败的请求计入分母
+func summarizeSynth(outcomes []synthOutcome) SynthReport {
+	report := SynthReport{Images: len(outcomes)}
+	var game, issue, prize, ticket, rows, rowsOK, numbers, numsOK int
+	var latencies []time.Duration
+	for _, o := range outcomes {
+		if o.failed {
+			report.Failed++
+		} else {
+			latencies = append(latencies, o.latency)
+		}
+		game += boolInt(o.game)
+		issue += boolInt(o.issue)
+		prize += boolInt(o.prize)
+		ticket += boolInt(o.ticket)
+		rows, rowsOK, numbers, numsOK = rows+o.rows, rowsOK+o.rowsOK, numbers+o.numbers, numsOK+o.numsOK
+		if len(o.reasons) > 0 {
+			report.Mismatches = append(report.Mismatches, SynthMismatch{Image: o.image, Reasons: o.reasons})
+		}
+	}
+	ratio := func(n, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) / float64(total)
+	}
+	report.GameAcc, report.IssueAcc = ratio(game, len(outcomes)), ratio(issue, len(outcomes))
+	report.PrizeAcc, report.TicketAcc = ratio(prize, len(outcomes)), ratio(ticket, len(outcomes))
+	report.RowAcc, report.NumberAcc = ratio(rowsOK, rows), ratio(numsOK, numbers)
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		report.P50, report.P95 = percentile(latencies, 50).String(), percentile(latencies, 95).String()
+	}
+	return report
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// printSynthTrend 按时间列出各次报告，最后一列为与上一次相比的整票准确率变化
+func printSynthTrend(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	var reports []SynthReport
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var r SynthReport
+		if json.Unmarshal(raw, &r) == nil {
+			reports = append(reports, r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Time.Before(reports[j].Time) })
+	fmt.Printf("\n%-20s %7s %7s %7s %7s %7s %7s %8s\n", "label", "game", "issue", "rows", "numbers", "prize", "ticket", "Δticket")
+	pct := func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) }
+	for i, r := range reports {
+		delta := ""
+		if i > 0 {
+			delta = fmt.Sprintf("%+.1f", (r.TicketAcc-reports[i-1].TicketAcc)*100)
+		}
+		fmt.Printf("%-20s %7s %7s %7s %7s %7s %7s %8s\n", r.Label, pct(r.GameAcc), pct(r.IssueAcc),
+			pct(r.RowAcc), pct(r.NumberAcc), pct(r.PrizeAcc), pct(r.TicketAcc), delta)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSynthTruths(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "synth_0002.json", []byte(`{"image": "synth_0002.jpg", "lotteries": [{"type": "双色球"}]}`))
+	writeTestFile(t, dir, "synth_0001.json", []byte(`{"image": "synth_0001.jpg", "lotteries": [{"type": "大乐透"}]}`))
+	writeTestFile(t, dir, "no_image.json", []byte(`{"lotteries": [{"type": "双色球"}]}`))
+	writeTestFile(t, dir, "broken.json", []byte(`{`))
+
+	truths, err := loadSynthTruths(dir)
+	if err != nil || len(truths) != 2 || truths[0].Image != "synth_0001.jpg" || truths[1].Image != "synth_0002.jpg" {
+		t.Errorf("loadSynthTruths() = %+v, %v", truths, err)
+	}
+	if _, err := loadSynthTruths(t.TempDir()); err == nil {
+		t.Error("empty dir: err = nil")
+	}
+}
+
+// synthServer 模拟识别和验奖接口：scan 返回 scanRaw，verify 按答案返回 verifyPrize
+func synthServer(t *testing.T, scanStatus int, scanRaw string, verifyPrize Fen) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "sk_synth" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/scan":
+			if r.FormValue("winning") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(scanStatus)
+			w.Write([]byte(scanRaw))
+		case "/api/v1/verify":
+			json.NewEncoder(w).Encode([]VerificationResult{{TotalPrize: verifyPrize}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckSynthImage(t *testing.T) {
+	t.Setenv("SYNTH_API_KEY", "sk_synth")
+	dir := t.TempDir()
+	writeTestFile(t, dir, "synth_0001.jpg", []byte("\xff\xd8\xff\xe0synth"))
+	row := func(reds ...string) UserTicket {
+		return UserTicket{Red: reds, Blue: []string{"07"}, Multiplier: 1}
+	}
+	truth := synthTruth{
+		Image: "synth_0001.jpg",
+		Lotteries: []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{
+			row("02", "11", "15", "21", "28", "01"), row("01", "03", "04", "05", "06", "08"),
+		}}},
+		Draw: DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}},
+	}
+	result := func(game, issue string, prize Fen, tickets ...UserTicket) string {
+		raw, _ := json.Marshal([]VerificationResult{{OCRData: LotteryData{Type: game, Issue: issue, Tickets: tickets}, TotalPrize: prize}})
+		return string(raw)
+	}
+	good := truth.Lotteries[0].Tickets
+
+	tests := []struct {
+		name        string
+		status      int
+		raw         string
+		image       string
+		wantFailed  bool
+		wantFlags   string // game issue prize ticket
+		wantRowsOK  int
+		wantNumsOK  int
+		wantReasons string
+	}{
+		{"完全正确", 200, result("ssq", " 2025107", 3000*Yuan, good...), "", false, "1111", 2, 14, ""},
+		{"一个号码认错", 200, result("双色球", "2025107", 3000*Yuan, good[0], row("01", "03", "04", "05", "06", "09")), "", false, "1110", 1, 13, "第2行不一致"},
+		{"少一行且奖金不同", 200, result("双色球", "2025101", 0, row("02", "11", "15", "21", "28", "02")), "", false, "1000", 0, 6,
+			"期号 2025101 ≠ 2025107；识别出 1 行，应为 2 行；第1行不一致；奖金 0元 ≠ 3,000元"},
+		{"无识别结果", 200, `[]`, "", true, "0000", 0, 0, "无识别结果"},
+		{"请求失败", 500, `{"error": "识别失败"}`, "", true, "0000", 0, 0, `识别: HTTP 500: {"error": "识别失败"}`},
+		{"图片不存在", 200, `[]`, "missing.jpg", true, "0000", 0, 0, "读取图片失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := synthServer(t, tt.status, tt.raw, 3000*Yuan)
+			tr := truth
+			if tt.image != "" {
+				tr.Image = tt.image
+			}
+			out := checkSynthImage(srv.Client(), srv.URL, dir, tr)
+			flags := ""
+			for _, b := range []bool{out.game, out.issue, out.prize, out.ticket} {
+				flags += string(rune('0' + boolInt(b)))
+			}
+			if out.failed != tt.wantFailed || flags != tt.wantFlags || out.rowsOK != tt.wantRowsOK || out.numsOK != tt.wantNumsOK {
+				t.Errorf("outcome = %+v", out)
+			}
+			if !tt.wantFailed && (out.rows != 2 || out.numbers != 14) {
+				t.Errorf("rows %d numbers %d, want 2 14", out.rows, out.numbers)
+			}
+			if got := strings.Join(out.reasons, "；"); !strings.HasPrefix(got, tt.wantReasons) || tt.wantReasons == "" && got != "" {
+				t.Errorf("reasons = %s, want %s", got, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestSummarizeSynth(t *testing.T) {
+	outcomes := []synthOutcome{
+		{image: "a.jpg", game: true, issue: true, prize: true, ticket: true, rows: 2, rowsOK: 2, numbers: 14, numsOK: 14, latency: 100 * time.Millisecond},
+		{image: "b.jpg", game: true, issue: false, prize: true, rows: 2, rowsOK: 1, numbers: 14, numsOK: 13, latency: 300 * time.Millisecond, reasons: []string{"期号"}},
+		{image: "c.jpg", failed: true, reasons: []string{"无识别结果"}},
+		{image: "d.jpg", game: true, issue: true, prize: true, ticket: true, rows: 1, rowsOK: 1, numbers: 7, numsOK: 7, latency: 200 * time.Millisecond},
+	}
+	r := summarizeSynth(outcomes)
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"game", r.GameAcc, 0.75},
+		{"issue", r.IssueAcc, 0.5},
+		{"rows", r.RowAcc, 0.8},
+		{"numbers", r.NumberAcc, 34.0 / 35},
+		{"prize", r.PrizeAcc, 0.75},
+		{"ticket", r.TicketAcc, 0.5},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if r.Images != 4 || r.Failed != 1 || r.P50 != "200ms" || r.P95 != "300ms" || len(r.Mismatches) != 2 || r.Mismatches[1].Image != "c.jpg" {
+		t.Errorf("report = %+v", r)
+	}
+	if empty := summarizeSynth(nil); empty.GameAcc != 0 || empty.P50 != "" {
+		t.Errorf("empty report = %+v", empty)
+	}
+}
+
+// 完整跑一遍：报告写到 reports/<label>.json，目标地址去掉 /api/v1/scan
+func TestRunSynthCheck(t *testing.T) {
+	t.Setenv("SYNTH_API_KEY", "sk_synth")
+	dir := t.TempDir()
+	if err := runSynth(synthOptions{Out: dir, Count: 2, Games: []string{"排列5"}, MinRows: 1, MaxRows: 1, Fonts: []string{"block"}, Seed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	srv := synthServer(t, 200, `[{"ocr_data": {"type": "排列5"}, "total_prize_fen": 0}]`, 0)
+	err := runSynthCheck(synthCheckOptions{Dir: dir, Target: srv.URL + "/api/v1/scan/", Concurrency: 0, Label: "v1", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "reports", "v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report SynthReport
+	json.Unmarshal(raw, &report)
+	if report.Label != "v1" || report.Target != srv.URL || report.Images != 2 || report.Failed != 0 || report.GameAcc != 1 || report.IssueAcc != 0 {
+		t.Errorf("report = %s", raw)
+	}
+}
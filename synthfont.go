@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// SYNTHFONT: 合成票面用的点阵字体
+// ==========================================
+
+// 内置 5×7 点阵只有数字、大写字母和票面常见符号，模拟热敏打印机的三种效果：
+//
+//	dot    圆点（针式/热敏点阵）
+//	block  方块
+//	bold   方块加粗
+//
+// 汉字需要外部 BDF 点阵字体（如文泉驿点阵宋体、GNU Unifont），--synth-font 传 .bdf 文件路径；
+// 字体里缺字的行改用英文写法，见 synthLine
+
+// synthGlyph 一个字形；Bits 按行存放，YOff 为字形底部相对基线的偏移（向上为正）
+type synthGlyph struct {
+	W, H, YOff int
+	Bits       []bool
+}
+
+// synthFont 已加载的字体；Ascent 为基线以上的点数，Advance 为内置字体的字宽（含字间距）
+type synthFont struct {
+	Name    string
+	Style   string
+	Glyphs  map[rune]synthGlyph
+	Ascent  int
+	Descent int
+	Advance int
+	Scale   int // 每个点的像素边长
+}
+
+var builtinGlyphRows = map[rune][7]string{
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	':': {".....", ".##..", ".##..", ".....", ".##..", ".##..", "....."},
+	'-': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	'.': {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	'/': {"....#", "....#", "...#.", "..#..", ".#...", "#....", "#...."},
+	'*': {".....", "#.#.#", ".###.", "#####", ".###.", "#.#.#", "....."},
+	'(': {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')': {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'#': {".#.#.", ".#.#.", "#####", ".#.#.", "#####", ".#.#.", ".#.#."},
+	' ': {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+}
+
+// loadSynthFont dot / block / bold 为内置字体，其余按 BDF 文件路径加载
+func loadSynthFont(name string) (*synthFont, error) {
+	switch name {
+	case "dot", "block", "bold":
+		f := &synthFont{Name: name, Style: name, Glyphs: map[rune]synthGlyph{}, Ascent: 7, Descent: 2, Advance: 6, Scale: 3}
+		for r, rows := range builtinGlyphRows {
+			g := synthGlyph{W: 5, H: 7, Bits: make([]bool, 35)}
+			for y, row := range rows {
+				for x, ch := range row {
+					g.Bits[y*5+x] = ch == '#'
+				}
+			}
+			f.Glyphs[r] = g
+		}
+		return f, nil
+	}
+	return loadBDF(name)
+}
+
+// loadBDF 读取 BDF 点阵字体，只取 BBX 和 BITMAP，字宽按字形宽度计
+func loadBDF(path string) (*synthFont, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	f := &synthFont{Name: path, Style: "block", Glyphs: map[rune]synthGlyph{}, Scale: 2}
+	var (
+		g        synthGlyph
+		code     = -1
+		inBitmap bool
+		row      int
+	)
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBitmap && fields[0] == "ENDCHAR":
+			if code >= 0 {
+				f.Glyphs[rune(code)] = g
+			}
+			inBitmap, code = false, -1
+		case inBitmap:
+			bits, err := strconv.ParseUint(fields[0], 16, 64)
+			if err != nil || row >= g.H {
+				continue
+			}
+			width := len(fields[0]) * 4
+			for x := 0; x < g.W && x < width; x++ {
+				g.Bits[row*g.W+x] = bits&(1<<(width-1-x)) != 0
+			}
+			row++
+		case fields[0] == "FONT_ASCENT" && len(fields) > 1:
+			f.Ascent, _ = strconv.Atoi(fields[1])
+		case fields[0] == "FONT_DESCENT" && len(fields) > 1:
+			f.Descent, _ = strconv.Atoi(fields[1])
+		case fields[0] == "ENCODING" && len(fields) > 1:
+			code, _ = strconv.Atoi(fields[1])
+		case fields[0] == "BBX" && len(fields) > 4:
+			w, _ := strconv.Atoi(fields[1])
+			h, _ := strconv.Atoi(fields[2])
+			yOff, _ := strconv.Atoi(fields[4])
+			g = synthGlyph{W: w, H: h, YOff: yOff, Bits: make([]bool, w*h)}
+		case fields[0] == "BITMAP":
+			inBitmap, row = true, 0
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(f.Glyphs) == 0 {
+		return nil, fmt.Errorf("%s 不是有效的 BDF 字体", path)
+	}
+	if f.Ascent == 0 {
+		f.Ascent = 14
+	}
+	return f, nil
+}
+
+// has 字体里是否每个字都有字形
+func (f *synthFont) has(text string) bool {
+	for _, r := range text {
+		if _, ok := f.Glyphs[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// advance 一个字占的点数
+func (f *synthFont) advance(r rune) int {
+	if f.Advance > 0 {
+		return f.Advance
+	}
+	if g, ok := f.Glyphs[r]; ok {
+		return g.W + 1
+	}
+	return f.Ascent / 2
+}
+
+// width 一行文字的点数
+func (f *synthFont) width(text string) int {
+	n := 0
+	for _, r := range text {
+		n += f.advance(r)
+	}
+	return n
+}
+
+// lineHeight 行高（点数）
+func (f *synthFont) lineHeight() int { return f.Ascent + f.Descent + 2 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// TAMPER: 图片篡改启发式检测
+// ==========================================
+
+// 命中任意一项就给结果加上 TAMPER_SUSPECTED 警告，只提示不拒绝：
+// 这些检测都有误报，目的是让门店人员兑奖前再核对一遍实物彩票
+const tamperWarning = "TAMPER_SUSPECTED"
+
+// 常见修图软件在 EXIF / XMP 中留下的标记
+var editorMarkers = []string{"Photoshop", "GIMP", "PicsArt", "Snapseed", "Lightroom", "Meitu", "美图", "醒图", "Pixelmator"}
+
+// tamperReport 一张图片的检测结果；拍摄时间用于和票面销售时间比对
+type tamperReport struct {
+	Findings []string
+	ShotAt   time.Time
+}
+
+func tamperCheckEnabled() bool { return envBool("TAMPER_CHECK", true) }
+
+// inspectImage 对上传的图片做误差水平分析、复制粘贴检测和元数据检查
+func inspectImage(fileBytes []byte) tamperReport {
+	var rep tamperReport
+	if !tamperCheckEnabled() || len(fileBytes) == 0 {
+		return rep
+	}
+	meta := readJPEGMeta(fileBytes)
+	if meta.Software != "" {
+		for _, m := range editorMarkers {
+			if strings.Contains(strings.ToLower(meta.Software), strings.ToLower(m)) {
+				rep.Findings = append(rep.Findings, "图片经过修图软件处理 ("+meta.Software+")")
+				break
+			}
+		}
+	}
+	if !meta.Original.IsZero() && meta.Modified.Sub(meta.Original) > time.Minute {
+		rep.Findings = append(rep.Findings, fmt.Sprintf("图片在拍摄后被再次保存 (拍摄 %s，修改 %s)",
+			meta.Original.Format("2006-01-02 15:04"), meta.Modified.Format("2006-01-02 15:04")))
+	}
+	rep.ShotAt = meta.Original
+
+	img, format, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return rep
+	}
+	if format == "jpeg" {
+		if f := errorLevelAnalysis(img); f != "" {
+			rep.Findings = append(rep.Findings, f)
+		}
+	}
+	if f := copyMoveCheck(img); f != "" {
+		rep.Findings = append(rep.Findings, f)
+	}
+	return rep
+}
+
+// apply 把检测结果写入单张票的验奖结果，同时比对拍摄时间与销售时间
+func (rep tamperReport) apply(res *VerificationResult) {
+	findings := rep.Findings
+	if sold, ok := parseSaleTime(res.OCRData.SaleTime); ok && !rep.ShotAt.IsZero() && rep.ShotAt.Before(sold.Add(-time.Minute)) {
+		findings = append(slices.Clip(findings), fmt.Sprintf("拍摄时间 %s 早于票面销售时间", rep.ShotAt.Format("2006-01-02 15:04")))
+	}
+	if len(findings) == 0 {
+		return
+	}
+	res.Warnings = append(slices.Clip(res.Warnings), tamperWarning+": "+strings.Join(findings, "；")+"，兑奖前请核对实物彩票")
+}
+
+// applyAll 批量写入
+func (rep tamperReport) applyAll(results []VerificationResult) {
+	for i := range results {
+		rep.apply(&results[i])
+	}
+}
+
+// errorLevelAnalysis 以固定质量重新压缩，比较各块的压缩误差。
+// 整张照片压缩历史一致时误差与纹理复杂度成正比；后贴上去的区域压缩历史不同，误差明显偏离
+func errorLevelAnalysis(img image.Image) string {
+	src, ok := img.(*image.YCbCr)
+	if !ok || src.Rect.Dx()*src.Rect.Dy() > 30_000_000 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 90}); err != nil {
+		return ""
+	}
+	re, err := jpeg.Decode(&buf)
+	if err != nil {
+		return ""
+	}
+	dst, ok := re.(*image.YCbCr)
+	if !ok {
+		return ""
+	}
+
+	const block = 16
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	var ratios []float64
+	for by := 0; by+block <= h; by += block {
+		for bx := 0; bx+block <= w; bx += block {
+			var diff, texture float64
+			for y := by; y < by+block; y++ {
+				for x := bx; x < bx+block; x++ {
+					a := float64(src.Y[src.YOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)])
+					b := float64(dst.Y[dst.YOffset(dst.Rect.Min.X+x, dst.Rect.Min.Y+y)])
+					diff += math.Abs(a - b)
+					if x > bx {
+						texture += math.Abs(a - float64(src.Y[src.YOffset(src.Rect.Min.X+x-1, src.Rect.Min.Y+y)]))
+					}
+				}
+			}
+			// 纯色区域没有参考价值
+			if texture < block*block {
+				continue
+			}
+			ratios = append(ratios, diff/texture)
+		}
+	}
+	if len(ratios) < 64 {
+		return ""
+	}
+	sorted := slices.Clone(ratios)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	outliers := 0
+	for _, r := range ratios {
+		if r > 4*median && r > 0.2 {
+			outliers++
+		}
+	}
+	// 异常块太少是噪声，太多说明整张图本身就是这样（比如截图再拍）
+	share := float64(outliers) / float64(len(ratios))
+	if outliers >= 4 && share < 0.2 {
+		return fmt.Sprintf("局部压缩误差异常 (%d 个区块)", outliers)
+	}
+	return ""
+}
+
+// copyMoveCheck 在缩小后的灰度图上找内容相同、位移一致的成组图块，
+// 即图片内部某块区域被复制粘贴到了另一处（比如把号码复制到别的位置）
+func copyMoveCheck(img image.Image) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > 512 {
+		w, h = 512, max(1, h*512/w)
+	}
+	small := resample(img, w, h)
+	gray := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray[y*w+x] = uint8(luma(small, x, y) / 1000)
+		}
+	}
+
+	// 积分图，任意矩形求和 O(1)
+	integral := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		row := 0
+		for x := 0; x < w; x++ {
+			row += int(gray[y*w+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+	rect := func(x, y, size int) int {
+		return integral[(y+size)*(w+1)+x+size] - integral[y*(w+1)+x+size] - integral[(y+size)*(w+1)+x] + integral[y*(w+1)+x]
+	}
+
+	const block, cell, minShift, minPairs = 16, 4, 24, 20
+	type pos struct{ x, y int }
+	// 同样内容可能出现多次（印刷的相同数字），每种特征保留最近几个位置
+	seen := map[[16]uint8][]pos{}
+	shifts := map[pos]int{}
+	for y := 0; y+block <= h; y++ {
+		for x := 0; x+block <= w; x++ {
+			// 特征：4×4 个小格的均值，量化到 16 级
+			var key [16]uint8
+			var sum, sq float64
+			for i := 0; i < 16; i++ {
+				v := rect(x+(i%4)*cell, y+(i/4)*cell, cell) / (cell * cell)
+				key[i] = uint8(v >> 4)
+				sum += float64(v)
+				sq += float64(v * v)
+			}
+			// 空白纸面到处都一样，只看有内容的块
+			if mean := sum / 16; sq/16-mean*mean < 144 {
+				continue
+			}
+			prev := seen[key]
+			for _, p := range prev {
+				dx, dy := x-p.x, y-p.y
+				if dx*dx+dy*dy >= minShift*minShift {
+					shifts[pos{dx, dy}]++
+				}
+			}
+			if len(prev) >= 8 {
+				prev = prev[1:]
+			}
+			seen[key] = append(prev, pos{x, y})
+		}
+	}
+	// 重采样带来的亚像素误差会让同一位移散落到相邻位置，按 3×3 邻域合计
+	for sh := range shifts {
+		n := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				n += shifts[pos{sh.x + dx, sh.y + dy}]
+			}
+		}
+		if n >= minPairs {
+			return "图片中存在复制粘贴的区域"
+		}
+	}
+	return ""
+}
+
+// ==========================================
+// JPEG 元数据（EXIF）
+// ==========================================
+
+type jpegMeta struct {
+	Software string
+	Original time.Time // DateTimeOriginal，拍摄时间
+	Modified time.Time // DateTime，最后修改时间
+}
+
+// readJPEGMeta 解析 APP1 段里的 EXIF 与 XMP，只取篡改检测用到的几个字段
+func readJPEGMeta(data []byte) jpegMeta {
+	var meta jpegMeta
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return meta
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // 图像数据开始，元数据段到此为止
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 {
+			switch {
+			case bytes.HasPrefix(seg, []byte("Exif\x00\x00")):
+				parseExif(seg[6:], &meta)
+			case bytes.HasPrefix(seg, []byte("http://ns.adobe.com/xap/1.0/")) && meta.Software == "":
+				for _, m := range editorMarkers {
+					if bytes.Contains(seg, []byte(m)) {
+						meta.Software = m
+						break
+					}
+				}
+			}
+		}
+		i += 2 + size
+	}
+	return meta
+}
+
+// parseExif 读取 IFD0 的 Software / DateTime 和 Exif 子 IFD 的 DateTimeOriginal
+func parseExif(tiff []byte, meta *jpegMeta) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+	exifTime := func(s string) time.Time {
+		t, _ := time.ParseInLocation("2006:01:02 15:04:05", s, chinaTime)
+		return t
+	}
+	var walk func(offset uint32, depth int)
+	walk = func(offset uint32, depth int) {
+		if depth > 1 || int(offset)+2 > len(tiff) {
+			return
+		}
+		n := int(order.Uint16(tiff[offset:]))
+		for k := 0; k < n; k++ {
+			e := int(offset) + 2 + k*12
+			if e+12 > len(tiff) {
+				return
+			}
+			tag, typ := order.Uint16(tiff[e:]), order.Uint16(tiff[e+2:])
+			count, value := order.Uint32(tiff[e+4:]), order.Uint32(tiff[e+8:])
+			str := func() string {
+				if typ != 2 || count == 0 {
+					return ""
+				}
+				raw := tiff[e+8 : e+12]
+				if count > 4 {
+					if uint64(value)+uint64(count) > uint64(len(tiff)) {
+						return ""
+					}
+					raw = tiff[value : value+count]
+				}
+				return strings.TrimRight(string(raw[:min(int(count), len(raw))]), "\x00 ")
+			}
+			switch tag {
+			case 0x0131:
+				meta.Software = str()
+			case 0x0132:
+				meta.Modified = exifTime(str())
+			case 0x9003:
+				meta.Original = exifTime(str())
+			case 0x8769:
+				walk(value, depth+1)
+			}
+		}
+	}
+	walk(order.Uint32(tiff[4:]), 0)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noiseImage 固定种子的随机噪点图，各处内容互不相同
+func noiseImage(w, h int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(rng.Intn(256))
+			img.Set(x, y, color.RGBA{v, v, v, 0xff})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithExif 编码 JPEG 并在 SOI 之后插入带 Software / DateTime / DateTimeOriginal 的 EXIF 段
+func jpegWithExif(t *testing.T, img image.Image, software, modified, original string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	strs := [][]byte{append([]byte(software), 0), append([]byte(modified), 0), append([]byte(original), 0)}
+	const dataAt = 8 + 42 + 18 // TIFF 头 + IFD0（3 项）+ Exif IFD（1 项）
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+	tiff := le.AppendUint32([]byte("II*\x00"), 8)
+	tiff = le.AppendUint16(tiff, 3)
+	tiff = entry(tiff, 0x0131, 2, uint32(len(strs[0])), dataAt)
+	tiff = entry(tiff, 0x0132, 2, uint32(len(strs[1])), dataAt+uint32(len(strs[0])))
+	tiff = entry(tiff, 0x8769, 4, 1, 8+42)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = entry(tiff, 0x9003, 2, uint32(len(strs[2])), dataAt+uint32(len(strs[0])+len(strs[1])))
+	tiff = le.AppendUint32(tiff, 0)
+	for _, s := range strs {
+		tiff = append(tiff, s...)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(2+len(payload)))
+	app1 = append(app1, payload...)
+	raw := buf.Bytes()
+	return append(append(append([]byte{}, raw[:2]...), app1...), raw[2:]...)
+}
+
+func TestReadJPEGMeta(t *testing.T) {
+	img := noiseImage(32, 32)
+	data := jpegWithExif(t, img, "Adobe Photoshop 25.0", "2025:09:16 20:00:00", "2025:09:16 18:31:00")
+	meta := readJPEGMeta(data)
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Software", meta.Software, "Adobe Photoshop 25.0"},
+		{"DateTime", meta.Modified.Format("2006-01-02 15:04:05"), "2025-09-16 20:00:00"},
+		{"DateTimeOriginal", meta.Original.Format("2006-01-02 15:04:05"), "2025-09-16 18:31:00"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if got := readJPEGMeta(encodePNG(t, img)); got != (jpegMeta{}) {
+		t.Errorf("PNG meta = %+v, want empty", got)
+	}
+}
+
+func TestInspectImage(t *testing.T) {
+	clean := noiseImage(200, 200)
+	copied := noiseImage(200, 200)
+	draw.Draw(copied, image.Rect(120, 110, 180, 170), copied, image.Pt(20, 20), draw.Src)
+
+	tests := []struct {
+		name    string
+		enabled string
+		data    []byte
+		want    string // 期望的检测结果片段，空表示没有发现
+	}{
+		{"正常图片", "", encodePNG(t, clean), ""},
+		{"复制粘贴", "", encodePNG(t, copied), "复制粘贴"},
+		{"修图软件", "", jpegWithExif(t, clean, "Adobe Photoshop 25.0", "2025:09:16 18:31:00", "2025:09:16 18:31:00"), "修图软件"},
+		{"拍摄后再次保存", "", jpegWithExif(t, clean, "iOS 17.1.2", "2025:09:16 20:00:00", "2025:09:16 18:31:00"), "再次保存"},
+		{"相机原图", "", jpegWithExif(t, clean, "iOS 17.1.2", "2025:09:16 18:31:00", "2025:09:16 18:31:00"), ""},
+		{"关闭检测", "false", encodePNG(t, copied), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TAMPER_CHECK", tt.enabled)
+			rep := inspectImage(tt.data)
+			got := strings.Join(rep.Findings, "；")
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTamperReportApply(t *testing.T) {
+	shot := time.Date(2025, 9, 16, 18, 31, 0, 0, chinaTime)
+	tests := []struct {
+		name     string
+		rep      tamperReport
+		saleTime string
+		want     string
+	}{
+		{"没有发现", tamperReport{ShotAt: shot}, "2025-09-16 18:30:05", ""},
+		{"检测结果写入警告", tamperReport{Findings: []string{"图片中存在复制粘贴的区域"}}, "", "TAMPER_SUSPECTED: 图片中存在复制粘贴的区域"},
+		{"拍摄时间早于销售时间", tamperReport{ShotAt: shot}, "2025-09-16 19:00:00", "早于票面销售时间"},
+		{"拍摄时间误差一分钟内", tamperReport{ShotAt: shot}, "2025-09-16 18:31:50", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := VerificationResult{OCRData: LotteryData{SaleTime: tt.saleTime}}
+			tt.rep.apply(&res)
+			got := strings.Join(res.Warnings, "；")
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("warnings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
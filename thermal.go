@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ==========================================
+// THERMAL: 热敏票褪色增强后重新识别
+// ==========================================
+
+// 彩票是热敏纸打印的，放久了字迹发灰、局部泛黄，识别结果常缺号或把号码认成别的字符。
+// 识别结果里有不完整的号码行（个数不足、号码超出范围、读不出数字）时，对原图做一次增强后重新识别：
+//
+//  1. 灰度化后按 1% / 99% 分位做对比度拉伸，把发灰的字迹拉开
+//  2. 按局部均值自适应二值化，窗口约为短边的 1/24，纸面泛黄、光照不均也能分开字和底色
+//
+// 重新识别的不完整行更少时采用新结果，并标记 enhanced；否则保留原结果。
+// 增强图只用于这次识别，不保存。THERMAL_ENHANCE=false 关闭，THERMAL_ENHANCE_MAX_SIDE 为增强前缩放的长边上限（默认 2000）
+
+func thermalEnhanceEnabled() bool { return envBool("THERMAL_ENHANCE", true) }
+
+// retryEnhanced 结果不完整时用增强图重新识别，返回更好的一份
+func retryEnhanced(ctx context.Context, fileBytes []byte, apiKey string, data []LotteryData) []LotteryData {
+	missing := incompleteRows(data)
+	if missing == 0 || !thermalEnhanceEnabled() {
+		return data
+	}
+	enhanced, err := enhanceThermal(fileBytes)
+	if err != nil {
+		return data
+	}
+	retry, err := callOCRWithBreaker(ctx, enhanced, apiKey, nil)
+	ocrOutcomes.Record(billingTenantFrom(ctx), err != nil)
+	if err != nil {
+		log.Printf("褪色增强后重新识别失败: %v", err)
+		return data
+	}
+	if incompleteRows(retry) >= missing {
+		return data
+	}
+	for i := range retry {
+		retry[i].Enhanced = true
+	}
+	return retry
+}
+
+// incompleteRows 号码不完整的行数；没有识别出任何号码行的票按一行计
+func incompleteRows(data []LotteryData) int {
+	n := 0
+	for _, l := range data {
+		if len(l.Tickets) == 0 {
+			n++
+		}
+		resolved, _ := resolveGameType(l)
+		spec, known := specOf(resolved.Type)
+		for _, t := range l.Tickets {
+			if known && !ticketsFitGame([]UserTicket{t}, spec) || !known && !rowReadable(t) {
+				n++
+			}
+		}
+		n += incompleteRows(l.Sections)
+	}
+	return n
+}
+
+// rowReadable 不认识的彩种只检查号码行不为空且都是数字
+func rowReadable(t UserTicket) bool {
+	if len(t.Red) == 0 {
+		return false
+	}
+	for _, s := range append(append([]string(nil), t.Red...), t.Blue...) {
+		if _, err := strconv.Atoi(strings.TrimSpace(s)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// enhanceThermal 对比度拉伸 + 自适应二值化，输出 PNG
+func enhanceThermal(fileBytes []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, err
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if side := envInt("THERMAL_ENHANCE_MAX_SIDE", 2000); w > side || h > side {
+		if w >= h {
+			w, h = side, max(1, h*side/w)
+		} else {
+			w, h = max(1, w*side/h), side
+		}
+	}
+	rgba := resample(src, w, h)
+
+	gray := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(luma(rgba, x, y) / 1000)
+			gray[y*w+x] = v
+			hist[v]++
+		}
+	}
+
+	// 1. 对比度拉伸
+	lo, hi := histPercentile(hist, len(gray), 0.01), histPercentile(hist, len(gray), 0.99)
+	if hi > lo {
+		for i, v := range gray {
+			s := (int(v) - lo) * 255 / (hi - lo)
+			gray[i] = uint8(min(255, max(0, s)))
+		}
+	}
+
+	// 2. 自适应二值化：比局部均值暗 8% 以上的算字迹。积分图求窗口均值
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(gray[y*w+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+	r := max(8, min(w, h)/48)
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := max(0, y-r), min(h, y+r+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(0, x-r), min(w, x+r+1)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			area := int64((y1 - y0) * (x1 - x0))
+			if int64(gray[y*w+x])*area*100 < sum*92 {
+				out.Pix[y*out.Stride+x] = 0
+			} else {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// histPercentile 直方图中第 p 分位的灰度值
+func histPercentile(hist [256]int, total int, p float64) int {
+	target, seen := int(float64(total)*p), 0
+	for v, n := range hist {
+		seen += n
+		if seen > target {
+			return v
+		}
+	}
+	return 255
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// fadedTicket 泛黄、左暗右亮的纸面上一道发灰的竖笔画（x 在 [98, 102) 之间）
+func fadedTicket(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			light := uint8(200 + x/5)
+			c := color.RGBA{light, light - 10, light - 60, 255}
+			if x >= 98 && x < 102 && y >= 20 && y < 80 {
+				c = color.RGBA{150, 148, 140, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEnhanceThermal(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSide string
+		wantW   int
+		wantH   int
+	}{
+		{"原尺寸", "", 200, 100},
+		{"超过长边上限先缩小", "100", 100, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("THERMAL_ENHANCE_MAX_SIDE", tt.maxSide)
+			out, err := enhanceThermal(fadedTicket(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gray, ok := img.(*image.Gray)
+			if !ok || gray.Bounds().Dx() != tt.wantW || gray.Bounds().Dy() != tt.wantH {
+				t.Fatalf("output = %T %v", img, img.Bounds())
+			}
+			// 笔画变黑，左侧较暗的纸面和右侧较亮的纸面都变白
+			stroke, left, right := gray.GrayAt(tt.wantW/2, tt.wantH/2), gray.GrayAt(tt.wantW/10, tt.wantH/2), gray.GrayAt(tt.wantW*9/10, tt.wantH/2)
+			if stroke.Y != 0 || left.Y != 255 || right.Y != 255 {
+				t.Errorf("stroke = %d, paper = %d/%d", stroke.Y, left.Y, right.Y)
+			}
+			for _, v := range gray.Pix {
+				if v != 0 && v != 255 {
+					t.Fatalf("pixel %d is not binary", v)
+				}
+			}
+		})
+	}
+	if _, err := enhanceThermal([]byte("not an image")); err == nil {
+		t.Error("enhanceThermal() accepted invalid image")
+	}
+}
+
+func TestIncompleteRows(t *testing.T) {
+	ssq := UserTicket{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}
+	short := UserTicket{Red: []string{"02", "11", "15", "21", "28"}, Blue: []string{"07"}}
+	garbled := UserTicket{Red: []string{"02", "11", "I5", "21", "28", "33"}, Blue: []string{"07"}}
+	tests := []struct {
+		name string
+		data []LotteryData
+		want int
+	}{
+		{"完整", []LotteryData{{Type: "双色球", Tickets: []UserTicket{ssq, ssq}}}, 0},
+		{"缺号和认错字各算一行", []LotteryData{{Type: "双色球", Tickets: []UserTicket{ssq, short, garbled}}}, 2},
+		{"号码超出范围", []LotteryData{{Type: "双色球", Tickets: []UserTicket{{Red: ssq.Red, Blue: []string{"17"}}}}}, 1},
+		{"没有号码行按一行计", []LotteryData{{Type: "双色球"}, {Type: "大乐透"}}, 2},
+		{"彩种名称认错字先纠正", []LotteryData{{Type: "双色求", Tickets: []UserTicket{ssq}}}, 0},
+		{"不认识的彩种只看是否都是数字", []LotteryData{{Type: "未知", Tickets: []UserTicket{{Red: []string{"1", "2"}}, {Red: []string{"1", "?"}}, {}}}}, 2},
+		{"附加玩法", []LotteryData{{Type: "双色球", Tickets: []UserTicket{ssq}, Sections: []LotteryData{{Type: "大乐透", Tickets: []UserTicket{ssq}}}}}, 1},
+	}
+	for _, tt := range tests {
+		if got := incompleteRows(tt.data); got != tt.want {
+			t.Errorf("%s: incompleteRows() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryEnhanced(t *testing.T) {
+	t.Setenv("OCR_PROVIDER", "mock")
+	complete := `[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21","28","33"],"blue":["07"]}]}]`
+	stillShort := `[{"type":"双色球","issue":"2025107","tickets":[{"red":["02","11","15","21"],"blue":["07"]}]}]`
+	faded := []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28"}, Blue: []string{"07"}}}}}
+	done := []LotteryData{{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}}}}}
+
+	tests := []struct {
+		name         string
+		disabled     bool
+		image        []byte
+		fixture      string // 增强图的识别结果，为空时识别失败
+		data         []LotteryData
+		wantRed      int
+		wantEnhanced bool
+		wantAttempts int
+		wantFailures int
+	}{
+		{"结果完整不重试", false, nil, complete, done, 6, false, 0, 0},
+		{"增强后更完整", false, nil, complete, faded, 6, true, 1, 0},
+		{"增强后没有改善", false, nil, stillShort, faded, 5, false, 1, 0},
+		{"重新识别失败", false, nil, "", faded, 5, false, 1, 1},
+		{"关闭增强", true, nil, complete, faded, 5, false, 0, 0},
+		{"原图无法解码", false, []byte("not an image"), complete, faded, 5, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestOCROutcomes(t)
+			dir := t.TempDir()
+			if tt.fixture != "" {
+				writeTestFile(t, dir, "default.json", []byte(tt.fixture))
+			}
+			t.Setenv("OCR_MOCK_DIR", dir)
+			if tt.disabled {
+				t.Setenv("THERMAL_ENHANCE", "false")
+			}
+			img := tt.image
+			if img == nil {
+				img = fadedTicket(t)
+			}
+			got := retryEnhanced(context.Background(), img, "", tt.data)
+			if len(got) != 1 || len(got[0].Tickets[0].Red) != tt.wantRed || got[0].Enhanced != tt.wantEnhanced {
+				t.Errorf("retryEnhanced() = %+v", got)
+			}
+			var sum ocrOutcomeCount
+			ocrOutcomes.Each("", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(_ time.Time, c ocrOutcomeCount) {
+				sum.Attempts += c.Attempts
+				sum.Failures += c.Failures
+			})
+			if sum.Attempts != tt.wantAttempts || sum.Failures != tt.wantFailures {
+				t.Errorf("outcomes = %+v, want %d attempts %d failures", sum, tt.wantAttempts, tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// TRENDS: 按时间分桶的运营趋势（看板 / Grafana）
+// ==========================================
+
+// GET /api/v1/admin/trends?from=2025-09-01&to=2025-09-30&bucket=day&tenant=shop-a
+//
+// 返回连续的时间序列，没有数据的桶也给出一行（数值为 0），便于直接画图：
+//
+//	scans / tickets              扫描次数与票数（按最初扫描的时间归桶，重新识别、更正的版本不重复计数）
+//	drawn_tickets                已开奖的票数，中奖率的分母
+//	winning_tickets / win_rate   中奖票数与中奖率，按最新开奖数据和记录的最新版本计算
+//	prize_fen / avg_prize_fen    中奖金额合计与每张中奖票的平均奖金
+//	ocr_attempts / ocr_failures  识别次数与失败次数（含命中识别缓存的），ocr_failure_rate 为失败率
+//
+// bucket 可选 hour、day（默认）、week（周一起）、month，按北京时间划分；不带 from/to 时为最近 30 天，
+// 单次最多 1000 个桶。tenant 为空时汇总全部租户。Grafana 可用 Infinity 等 JSON 数据源直接读取 points，
+// time 为桶的起始时间（RFC 3339）。内置看板见 /admin（web/admin.html）。
+// 识别次数单独按小时累计在 data/ocr_outcomes.json，保留 OCR_STATS_RETENTION（默认 400 天）
+
+// ocrOutcomeCount 一个小时内某租户的识别次数
+type ocrOutcomeCount struct {
+	Attempts int `json:"attempts"`
+	Failures int `json:"failures"`
+}
+
+// ocrOutcomeMeter 小时（2006-01-02T15，北京时间）→ 租户 → 识别次数
+type ocrOutcomeMeter struct {
+	mu    sync.Mutex
+	path  string
+	hours map[string]map[string]*ocrOutcomeCount
+}
+
+var ocrOutcomes = &ocrOutcomeMeter{hours: map[string]map[string]*ocrOutcomeCount{}}
+
+const ocrOutcomeHour = "2006-01-02T15"
+
+// Load 读取历史累计，文件不存在时从零开始
+func (m *ocrOutcomeMeter) Load(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.path = path
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &m.hours)
+}
+
+// save 调用方需持有锁；写失败只记日志
+func (m *ocrOutcomeMeter) save() {
+	if m.path == "" {
+		return
+	}
+	raw, err := json.Marshal(m.hours)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(m.path+".tmp", raw, 0o644); err != nil {
+		log.Printf("保存识别统计失败: %v", err)
+		return
+	}
+	if err := os.Rename(m.path+".tmp", m.path); err != nil {
+		log.Printf("保存识别统计失败: %v", err)
+	}
+}
+
+// Record 一次识别的结果；进入新的小时时清理超过保留期的数据
+func (m *ocrOutcomeMeter) Record(tenant string, failed bool) {
+	if tenant == "" {
+		tenant = "default"
+	}
+	now := time.Now().In(chinaTime)
+	hour := now.Format(ocrOutcomeHour)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenants, ok := m.hours[hour]
+	if !ok {
+		tenants = map[string]*ocrOutcomeCount{}
+		m.hours[hour] = tenants
+		oldest := now.Add(-envDuration("OCR_STATS_RETENTION", 400*24*time.Hour)).Format(ocrOutcomeHour)
+		for h := range m.hours {
+			if h < oldest {
+				delete(m.hours, h)
+			}
+		}
+	}
+	c, ok := tenants[tenant]
+	if !ok {
+		c = &ocrOutcomeCount{}
+		tenants[tenant] = c
+	}
+	c.Attempts++
+	if failed {
+		c.Failures++
+	}
+	m.save()
+}
+
+// Each 逐小时遍历 [from, to) 内的计数；tenant 为空时合计全部租户
+func (m *ocrOutcomeMeter) Each(tenant string, from, to time.Time, fn func(hour time.Time, c ocrOutcomeCount)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for h, tenants := range m.hours {
+		t, err := time.ParseInLocation(ocrOutcomeHour, h, chinaTime)
+		if err != nil || t.Before(from) || !t.Before(to) {
+			continue
+		}
+		var sum ocrOutcomeCount
+		for name, c := range tenants {
+			if tenant == "" || name == tenant {
+				sum.Attempts += c.Attempts
+				sum.Failures += c.Failures
+			}
+		}
+		fn(t, sum)
+	}
+}
+
+// TrendPoint 一个时间桶
+type TrendPoint struct {
+	Time           time.Time `json:"time"`
+	Scans          int       `json:"scans"`
+	Tickets        int       `json:"tickets"`
+	DrawnTickets   int       `json:"drawn_tickets"`
+	WinningTickets int       `json:"winning_tickets"`
+	WinRate        float64   `json:"win_rate"`
+	Prize          Fen       `json:"prize_fen"`
+	AvgPrize       Fen       `json:"avg_prize_fen"`
+	OCRAttempts    int       `json:"ocr_attempts"`
+	OCRFailures    int       `json:"ocr_failures"`
+	OCRFailureRate float64   `json:"ocr_failure_rate"`
+}
+
+var trendBuckets = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// bucketStart t 所在桶的起始时间（北京时间）
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.In(chinaTime)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, chinaTime)
+	switch bucket {
+	case "hour":
+		return day.Add(time.Duration(t.Hour()) * time.Hour)
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, chinaTime)
+	}
+	return day
+}
+
+func nextBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// Latest 扫描时间在 [from, to) 内的票，每张票取最新版本；返回的 Time 为最初扫描的时间
+func (s *historyStore) Latest(tenant string, from, to time.Time) []ScanRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	latest := map[string]int{}
+	first := map[string]time.Time{}
+	for i, r := range s.records {
+		if tenant != "" && r.Tenant != tenant {
+			continue
+		}
+		root := r.rootID()
+		if r.OriginalID == "" {
+			first[root] = r.Time
+		}
+		latest[root] = i
+	}
+	var out []ScanRecord
+	for root, i := range latest {
+		t, ok := first[root]
+		if !ok || t.Before(from) || !t.Before(to) {
+			continue
+		}
+		r := s.records[i]
+		r.Time = t
+		out = append(out, r)
+	}
+	return out
+}
+
+// trendSeries 生成 [from, to) 的时间序列
+func trendSeries(tenant string, from, to time.Time, bucket string) []TrendPoint {
+	var points []TrendPoint
+	index := map[time.Time]int{}
+	for t := bucketStart(from, bucket); t.Before(to); t = nextBucket(t, bucket) {
+		index[t] = len(points)
+		points = append(points, TrendPoint{Time: t})
+	}
+	at := func(t time.Time) *TrendPoint {
+		if i, ok := index[bucketStart(t, bucket)]; ok {
+			return &points[i]
+		}
+		return nil
+	}
+
+	for _, r := range scanHistory.Latest(tenant, from, to) {
+		p := at(r.Time)
+		if p == nil {
+			continue
+		}
+		p.Scans++
+		for _, l := range r.Lotteries {
+			_, won, pending, ok := ticketOutcome(l)
+			p.Tickets++
+			if !ok || pending {
+				continue
+			}
+			p.DrawnTickets++
+			if won > 0 {
+				p.WinningTickets++
+				p.Prize += won
+			}
+		}
+	}
+	ocrOutcomes.Each(tenant, from, to, func(hour time.Time, c ocrOutcomeCount) {
+		if p := at(hour); p != nil {
+			p.OCRAttempts += c.Attempts
+			p.OCRFailures += c.Failures
+		}
+	})
+
+	for i := range points {
+		p := &points[i]
+		if p.DrawnTickets > 0 {
+			p.WinRate = float64(p.WinningTickets) / float64(p.DrawnTickets)
+		}
+		if p.WinningTickets > 0 {
+			p.AvgPrize = p.Prize / Fen(p.WinningTickets)
+		}
+		if p.OCRAttempts > 0 {
+			p.OCRFailureRate = float64(p.OCRFailures) / float64(p.OCRAttempts)
+		}
+	}
+	return points
+}
+
+// trendsHandler GET /api/v1/admin/trends，参数见文件开头
+func trendsHandler(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", "day")
+	if !trendBuckets[bucket] {
+		c.JSON(400, gin.H{"error": "bucket 应为 hour/day/week/month"})
+		return
+	}
+	now := time.Now().In(chinaTime)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, chinaTime).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, chinaTime)
+		if err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("%s 格式应为 2006-01-02", name)})
+			return
+		}
+		if name == "to" {
+			t = t.AddDate(0, 0, 1) // 含当天
+		}
+		*dst = t
+	}
+	if !to.After(from) {
+		c.JSON(400, gin.H{"error": "to 不能早于 from"})
+		return
+	}
+	// 第一个桶从 from 所在周 / 月的开头算起，避免只统计了半个桶
+	from = bucketStart(from, bucket)
+	n := 0
+	for t := from; t.Before(to) && n <= 1000; t = nextBucket(t, bucket) {
+		n++
+	}
+	if n > 1000 {
+		c.JSON(400, gin.H{"error": "分桶过多，请缩短时间范围"})
+		return
+	}
+
+	c.JSON(200, gin.H{"bucket": bucket, "from": from, "to": to, "points": trendSeries(c.Query("tenant"), from, to, bucket)})
+}
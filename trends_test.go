@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestOCROutcomes 换成空的识别次数统计
+func useTestOCROutcomes(t *testing.T) {
+	t.Helper()
+	old := ocrOutcomes
+	t.Cleanup(func() { ocrOutcomes = old })
+	ocrOutcomes = &ocrOutcomeMeter{hours: map[string]map[string]*ocrOutcomeCount{}}
+}
+
+func TestBucketStart(t *testing.T) {
+	// 2025-09-10 是星期三；UTC 16:30 已是北京时间 9 月 11 日 00:30
+	at := time.Date(2025, 9, 10, 14, 30, 0, 0, chinaTime)
+	late := time.Date(2025, 9, 10, 16, 30, 0, 0, time.UTC)
+	tests := []struct {
+		t        time.Time
+		bucket   string
+		want     string
+		wantNext string
+	}{
+		{at, "hour", "2025-09-10 14:00", "2025-09-10 15:00"},
+		{at, "day", "2025-09-10 00:00", "2025-09-11 00:00"},
+		{at, "week", "2025-09-08 00:00", "2025-09-15 00:00"},
+		{at, "month", "2025-09-01 00:00", "2025-10-01 00:00"},
+		{late, "day", "2025-09-11 00:00", "2025-09-12 00:00"},
+		{time.Date(2025, 9, 14, 23, 0, 0, 0, chinaTime), "week", "2025-09-08 00:00", "2025-09-15 00:00"},
+		{time.Date(2025, 9, 15, 0, 0, 0, 0, chinaTime), "week", "2025-09-15 00:00", "2025-09-22 00:00"},
+	}
+	for _, tt := range tests {
+		start := bucketStart(tt.t, tt.bucket)
+		next := nextBucket(start, tt.bucket)
+		if got := start.Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("bucketStart(%s, %s) = %s, want %s", tt.t, tt.bucket, got, tt.want)
+		}
+		if got := next.Format("2006-01-02 15:04"); got != tt.wantNext {
+			t.Errorf("nextBucket(%s, %s) = %s, want %s", start, tt.bucket, got, tt.wantNext)
+		}
+	}
+}
+
+func TestOCROutcomeMeter(t *testing.T) {
+	t.Setenv("OCR_STATS_RETENTION", "48h")
+	path := filepath.Join(t.TempDir(), "ocr_outcomes.json")
+	now := time.Now().In(chinaTime)
+	stale := now.Add(-72 * time.Hour).Format(ocrOutcomeHour)
+	m := &ocrOutcomeMeter{hours: map[string]map[string]*ocrOutcomeCount{
+		stale: {"shop-a": {Attempts: 9}},
+	}}
+	if err := m.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	m.Record("shop-a", false)
+	m.Record("shop-a", true)
+	m.Record("", false)
+
+	hour := bucketStart(now, "hour")
+	tests := []struct {
+		name   string
+		tenant string
+		from   time.Time
+		want   ocrOutcomeCount
+	}{
+		{"全部租户", "", hour, ocrOutcomeCount{Attempts: 3, Failures: 1}},
+		{"按租户", "shop-a", hour, ocrOutcomeCount{Attempts: 2, Failures: 1}},
+		{"未指定租户记为 default", "default", hour, ocrOutcomeCount{Attempts: 1}},
+		{"超过保留期的已清理", "", now.Add(-100 * time.Hour), ocrOutcomeCount{Attempts: 3, Failures: 1}},
+		{"不在时间范围内", "", hour.Add(time.Hour), ocrOutcomeCount{}},
+	}
+	for _, tt := range tests {
+		var sum ocrOutcomeCount
+		m.Each(tt.tenant, tt.from, hour.Add(time.Hour), func(_ time.Time, c ocrOutcomeCount) {
+			sum.Attempts += c.Attempts
+			sum.Failures += c.Failures
+		})
+		if sum != tt.want {
+			t.Errorf("%s: Each() = %+v, want %+v", tt.name, sum, tt.want)
+		}
+	}
+
+	loaded := &ocrOutcomeMeter{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if c := loaded.hours[hour.Format(ocrOutcomeHour)]["shop-a"]; c == nil || c.Attempts != 2 {
+		t.Errorf("loaded = %+v", loaded.hours)
+	}
+}
+
+// 每张票只按最初扫描的时间计一次，内容取最新版本
+func TestHistoryStoreLatest(t *testing.T) {
+	day := time.Date(2025, 9, 10, 0, 0, 0, 0, chinaTime)
+	s := &historyStore{records: []ScanRecord{
+		{ID: "a", Tenant: "shop-a", Time: day.Add(time.Hour), Lotteries: []LotteryData{{Issue: "1"}}},
+		{ID: "b", Tenant: "shop-a", Time: day.Add(-time.Hour), Lotteries: []LotteryData{{Issue: "1"}}},
+		{ID: "a2", Tenant: "shop-a", Time: day.AddDate(0, 0, 3), OriginalID: "a", Version: 2, Lotteries: []LotteryData{{Issue: "2"}}},
+		{ID: "b2", Tenant: "shop-a", Time: day.Add(2 * time.Hour), OriginalID: "b", Version: 2},
+		{ID: "c", Tenant: "shop-b", Time: day.Add(time.Hour)},
+	}}
+	tests := []struct {
+		tenant string
+		want   string
+	}{
+		{"shop-a", "a2"},
+		{"", "a2,c"},
+		{"shop-c", ""},
+	}
+	for _, tt := range tests {
+		got := s.Latest(tt.tenant, day, day.AddDate(0, 0, 1))
+		var ids string
+		for _, r := range got {
+			if r.ID == "a2" && (!r.Time.Equal(day.Add(time.Hour)) || r.Lotteries[0].Issue != "2") {
+				t.Errorf("a2 = %+v", r)
+			}
+		}
+		if len(got) > 1 && got[0].ID > got[1].ID {
+			got[0], got[1] = got[1], got[0]
+		}
+		for i, r := range got {
+			if i > 0 {
+				ids += ","
+			}
+			ids += r.ID
+		}
+		if ids != tt.want {
+			t.Errorf("Latest(%q) = %s, want %s", tt.tenant, ids, tt.want)
+		}
+	}
+}
+
+func TestTrendSeries(t *testing.T) {
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	useTestOCROutcomes(t)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+
+	day := time.Date(2025, 9, 10, 0, 0, 0, 0, chinaTime)
+	ticket := func(issue, blue string) LotteryData {
+		return LotteryData{Type: "双色球", Issue: issue, Tickets: []UserTicket{{Red: []string{"02", "11", "15", "21", "28", "01"}, Blue: []string{blue}, Multiplier: 1}}}
+	}
+	lose := LotteryData{Type: "双色球", Issue: "2025107", Tickets: []UserTicket{{Red: []string{"01", "03", "04", "05", "06", "08"}, Blue: []string{"09"}, Multiplier: 1}}}
+	scanHistory = &historyStore{records: []ScanRecord{
+		// 第一天：一张中三等奖、一张未中、一张未开奖
+		{ID: "a", Tenant: "shop-a", Time: day.Add(time.Hour), Lotteries: []LotteryData{ticket("2025107", "07"), lose}},
+		{ID: "b", Tenant: "shop-a", Time: day.Add(2 * time.Hour), Lotteries: []LotteryData{ticket("2025108", "07")}},
+		// 第三天：更正后的新版本按原记录的时间计
+		{ID: "c", Tenant: "shop-a", Time: day.AddDate(0, 0, 2), Lotteries: []LotteryData{lose}},
+		{ID: "c2", Tenant: "shop-a", Time: day.AddDate(0, 0, 5), OriginalID: "c", Version: 2, Lotteries: []LotteryData{ticket("2025107", "07")}},
+	}}
+	ocrOutcomes.hours[day.Add(time.Hour).Format(ocrOutcomeHour)] = map[string]*ocrOutcomeCount{"shop-a": {Attempts: 4, Failures: 1}}
+
+	points := trendSeries("", day, day.AddDate(0, 0, 3), "day")
+	want := []TrendPoint{
+		{Time: day, Scans: 2, Tickets: 3, DrawnTickets: 2, WinningTickets: 1, WinRate: 0.5, Prize: 300000, AvgPrize: 300000, OCRAttempts: 4, OCRFailures: 1, OCRFailureRate: 0.25},
+		{Time: day.AddDate(0, 0, 1)},
+		{Time: day.AddDate(0, 0, 2), Scans: 1, Tickets: 1, DrawnTickets: 1, WinningTickets: 1, WinRate: 1, Prize: 300000, AvgPrize: 300000},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(points), len(want))
+	}
+	for i := range want {
+		if !points[i].Time.Equal(want[i].Time) {
+			t.Errorf("points[%d].Time = %s, want %s", i, points[i].Time, want[i].Time)
+		}
+		points[i].Time = want[i].Time
+		if points[i] != want[i] {
+			t.Errorf("points[%d] = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+	if got := trendSeries("shop-b", day, day.AddDate(0, 0, 1), "hour"); len(got) != 24 || got[1].Scans != 0 || got[1].OCRAttempts != 0 {
+		t.Errorf("shop-b = %d points: %+v", len(got), got[1])
+	}
+}
+
+func TestTrendsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestOCROutcomes(t)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+	scanHistory = &historyStore{}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantPoints int
+		wantFrom   string
+	}{
+		{"", 200, 30, ""},
+		{"?from=2025-09-01&to=2025-09-30", 200, 30, "2025-09-01"},
+		{"?from=2025-09-03&to=2025-09-30&bucket=week", 200, 5, "2025-09-01"},
+		{"?from=2025-01-15&to=2025-09-30&bucket=month", 200, 9, "2025-01-01"},
+		{"?from=2025-09-01&to=2025-09-01&bucket=hour", 200, 24, "2025-09-01"},
+		{"?bucket=year", 400, 0, ""},
+		{"?from=2025/09/01", 400, 0, ""},
+		{"?from=2025-09-30&to=2025-09-01", 400, 0, ""},
+		{"?from=2025-01-01&to=2025-09-30&bucket=hour", 400, 0, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/admin/trends"+tt.query, nil)
+		trendsHandler(c)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.wantStatus, w.Body)
+			continue
+		}
+		if w.Code != 200 {
+			continue
+		}
+		var resp struct {
+			From   time.Time    `json:"from"`
+			Points []TrendPoint `json:"points"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Points) != tt.wantPoints {
+			t.Errorf("%s: got %d points, want %d", tt.query, len(resp.Points), tt.wantPoints)
+		}
+		if tt.wantFrom != "" && resp.From.In(chinaTime).Format("2006-01-02") != tt.wantFrom {
+			t.Errorf("%s: from = %s, want %s", tt.query, resp.From, tt.wantFrom)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// TYPED: 手工输入号码验奖（POST /api/v1/verify/text）
+// ==========================================
+
+// 照片反复识别失败的用户可以直接打字，例如
+//
+//	双色球 期号2025107
+//	红 02 11 15 21 28 33 蓝 07 2倍
+//	03 08 12 19 25 30 + 11
+//
+// 每行一注（也可用 ；分隔），只写了彩种、期号的行作用于后面所有号码行。红/蓝（前区/后区）之间用
+// 红、蓝、+、| 或 / 分隔；号码个数正好是一注单式时也可以不分隔。胆拖写作 “胆 01 02 拖 03 04 …”。
+// 没写彩种时按号码个数推断：6+1 为双色球，5+2 为大乐透，5 个一位数为排列5
+
+var (
+	typedIssueRe      = regexp.MustCompile(`(?:期号|期數|期|第)\s*[:：]?\s*(\d{5,7})\s*期?|(\d{5,7})\s*期`)
+	typedMultiplierRe = regexp.MustCompile(`(\d{1,3})\s*倍|[x×*]\s*(\d{1,3})`)
+	typedTokenRe      = regexp.MustCompile(`红球?|蓝球?|前区|后区|胆码?|拖码?|red|blue|追加|[+|/]|\d+`)
+	typedLineSplitRe  = regexp.MustCompile(`[\n\r;；]+`)
+)
+
+// typedGameNames 可识别的彩种写法，长的优先（“排列5” 先于数字被拆开）
+func typedGameNames() []string {
+	names := []string{"排列五"}
+	for name := range gameSpecs {
+		names = append(names, name)
+	}
+	for alias := range gameAliases {
+		names = append(names, alias)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	return names
+}
+
+// normalizeTypedText 全角数字、符号转半角，统一小写
+func normalizeTypedText(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '０' && r <= '９':
+			r = '0' + (r - '０')
+		case r == '＋':
+			r = '+'
+		case r == '｜':
+			r = '|'
+		case r == '／':
+			r = '/'
+		case r == '，' || r == '、' || r == ',':
+			r = ' '
+		}
+		sb.WriteRune(r)
+	}
+	return strings.ToLower(sb.String())
+}
+
+// typedRow 一行号码的中间结果，按彩种规则整理前不知道哪些是红球
+type typedRow struct {
+	red, blue, dan, blueDan []string
+	separated               bool // 明确写了红/蓝分隔
+	multiplier              int
+	addOn                   bool
+}
+
+// parseTypedTicket 解析手工输入的号码，返回一张票
+func parseTypedTicket(text string) (LotteryData, error) {
+	text = normalizeTypedText(text)
+	var lottery LotteryData
+	var rows []typedRow
+	globalMultiplier := 0
+
+	for _, line := range typedLineSplitRe.Split(text, -1) {
+		// 先取出彩种、期号、倍数，剩下的数字才是号码
+		for _, name := range typedGameNames() {
+			if strings.Contains(line, name) {
+				if lottery.Type == "" {
+					lottery.Type = canonicalGame(strings.Replace(name, "排列五", "排列5", 1))
+				}
+				line = strings.ReplaceAll(line, name, " ")
+			}
+		}
+		if m := typedIssueRe.FindStringSubmatch(line); m != nil {
+			lottery.Issue = m[1] + m[2]
+			line = typedIssueRe.ReplaceAllString(line, " ")
+		}
+		multiplier := 0
+		if m := typedMultiplierRe.FindStringSubmatch(line); m != nil {
+			multiplier, _ = strconv.Atoi(m[1] + m[2])
+			line = typedMultiplierRe.ReplaceAllString(line, " ")
+		}
+
+		row := typedRow{multiplier: multiplier}
+		blue, dan := false, false
+		for _, tok := range typedTokenRe.FindAllString(line, -1) {
+			switch {
+			case strings.HasPrefix(tok, "红") || tok == "前区" || tok == "red":
+				blue, dan = false, false
+			case strings.HasPrefix(tok, "蓝") || tok == "后区" || tok == "blue" || tok == "+" || tok == "|" || tok == "/":
+				blue, dan, row.separated = true, false, true
+			case strings.HasPrefix(tok, "胆"):
+				dan = true
+			case strings.HasPrefix(tok, "拖"):
+				dan = false
+			case tok == "追加":
+				row.addOn = true
+			case blue && dan:
+				row.blueDan = append(row.blueDan, tok)
+			case blue:
+				row.blue = append(row.blue, tok)
+			case dan:
+				row.dan = append(row.dan, tok)
+			default:
+				row.red = append(row.red, tok)
+			}
+		}
+		if len(row.red)+len(row.blue)+len(row.dan)+len(row.blueDan) == 0 {
+			// 只有彩种、期号、倍数的说明行
+			if multiplier > 0 {
+				globalMultiplier = multiplier
+			}
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return lottery, fmt.Errorf("没有找到号码")
+	}
+	if lottery.Type == "" {
+		lottery.Type = inferTypedGame(rows[0])
+		if lottery.Type == "" {
+			return lottery, fmt.Errorf("无法判断彩种，请在号码前注明彩种，如 “双色球”")
+		}
+	}
+	spec, ok := specOf(lottery.Type)
+	if !ok {
+		return lottery, fmt.Errorf("不支持的彩种: %s", lottery.Type)
+	}
+
+	for i, row := range rows {
+		t, err := typedRowTicket(spec, row)
+		if err != nil {
+			return lottery, fmt.Errorf("第%d注: %v", i+1, err)
+		}
+		if t.Multiplier == 0 {
+			t.Multiplier = globalMultiplier
+		}
+		if t.Multiplier <= 0 {
+			t.Multiplier = 1
+		}
+		lottery.Tickets = append(lottery.Tickets, t)
+	}
+	return lottery, nil
+}
+
+// inferTypedGame 按号码个数推断彩种；复式同时可能是两种彩种时不猜
+func inferTypedGame(row typedRow) string {
+	red, blue := len(row.red)+len(row.dan), len(row.blue)+len(row.blueDan)
+	if !row.separated {
+		switch {
+		case red == 7:
+			return "双色球"
+		case red == 5 && allSingleDigits(row.red), red == 1 && len(row.red[0]) == 5:
+			return "排列5"
+		}
+		return ""
+	}
+	switch {
+	case red >= 6 && blue == 1:
+		return "双色球"
+	case red == 5 && blue >= 2:
+		return "大乐透"
+	}
+	return ""
+}
+
+func allSingleDigits(nums []string) bool {
+	for _, n := range nums {
+		if len(n) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// typedRowTicket 按彩种规则把一行号码整理为 UserTicket 并校验
+func typedRowTicket(spec gameSpec, row typedRow) (UserTicket, error) {
+	t := UserTicket{Multiplier: row.multiplier, AddOn: row.addOn}
+	if spec.Ordered {
+		// 排列类可以连写 “12345”
+		var digits []string
+		for _, n := range append(row.red, row.blue...) {
+			for _, d := range n {
+				digits = append(digits, string(d))
+			}
+		}
+		t.Red = digits
+		if len(t.Red) != len(spec.Zones) {
+			return t, fmt.Errorf("%s应为 %d 位数字，实际 %d 位", spec.Name, len(spec.Zones), len(t.Red))
+		}
+	} else {
+		t.Red, t.Blue, t.Dan, t.BlueDan = row.red, row.blue, row.dan, row.blueDan
+		if !row.separated {
+			// 没写分隔：个数正好是一注单式时按规则拆开
+			redPick, bluePick := 0, 0
+			for _, z := range spec.Zones {
+				if z.Field == "blue" {
+					bluePick += z.Pick
+				} else {
+					redPick += z.Pick
+				}
+			}
+			if len(t.Dan) > 0 || len(t.Red) != redPick+bluePick {
+				if bluePick > 0 {
+					return t, fmt.Errorf("请用 “蓝” 或 “+” 分开红球和蓝球")
+				}
+			} else {
+				t.Red, t.Blue = row.red[:redPick], row.red[redPick:]
+			}
+		}
+	}
+	for _, z := range spec.Zones {
+		if !spec.Ordered {
+			t.Red, t.Blue = padNumbers(t.Red, z, "red"), padNumbers(t.Blue, z, "blue")
+			t.Dan, t.BlueDan = padNumbers(t.Dan, z, "red"), padNumbers(t.BlueDan, z, "blue")
+		}
+		dan, tuo := zoneSelection(z, t)
+		if err := validateSelection(z, dan, tuo); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// padNumbers 把该区号码补齐位数（“7” → “07”）
+func padNumbers(nums []string, z gameZone, field string) []string {
+	if z.Field != field {
+		return nums
+	}
+	out := make([]string, len(nums))
+	for i, n := range nums {
+		if v, err := strconv.Atoi(n); err == nil {
+			n = z.format(v)
+		}
+		out[i] = n
+	}
+	return out
+}
+
+// typedVerifyHandler 接受 JSON {"text": "...", "winning": ...} 或纯文本请求体
+func typedVerifyHandler(c *gin.Context) {
+	var req struct {
+		Text    string          `json:"text"`
+		Winning json.RawMessage `json:"winning"`
+	}
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error()})
+			return
+		}
+	} else {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "读取请求失败"})
+			return
+		}
+		req.Text = string(body)
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		c.JSON(400, gin.H{"error": "text 不能为空"})
+		return
+	}
+	lottery, err := parseTypedTicket(req.Text)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "号码格式错误: " + err.Error()})
+		return
+	}
+	supplied, err := parseSuppliedDraws(req.Winning)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "winning 格式错误: " + err.Error()})
+		return
+	}
+	budget := newRequestBudget(withSuppliedDraws(c.Request.Context(), supplied))
+	defer budget.Done()
+	results, err := verifyAll(budget, []LotteryData{lottery})
+	if err != nil {
+		onScanFailed(tenantOf(c), err.Error())
+		respondStageError(c, "验奖失败: ", err)
+		return
+	}
+	if len(supplied) == 0 {
+		onScanCompleted(originOf(c), nil, results)
+	}
+	respondResults(c, results)
+}
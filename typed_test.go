@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTypedTicket(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		game    string
+		issue   string
+		want    []string // 每注 "红|蓝|胆 倍数"
+		wantErr string
+	}{
+		{"彩种期号单独一行", "双色球 期号2025107\n红 02 11 15 21 28 33 蓝 07 2倍\n03 08 12 19 25 30 + 11",
+			"双色球", "2025107", []string{"02 11 15 21 28 33|07| 2", "03 08 12 19 25 30|11| 1"}, ""},
+		{"分号分隔、全角数字", "双色球 第２０２５１０７期；１ ２ ３ ４ ５ ６ ＋ ７", "双色球", "2025107", []string{"01 02 03 04 05 06|07| 1"}, ""},
+		{"单式不写分隔", "双色球 1 2 3 4 5 6 7", "双色球", "", []string{"01 02 03 04 05 06|07| 1"}, ""},
+		{"说明行的倍数作用于所有行", "ssq 5倍\n01 02 03 04 05 06 + 07\n01 02 03 04 05 06 + 08 x2", "双色球", "",
+			[]string{"01 02 03 04 05 06|07| 5", "01 02 03 04 05 06|08| 2"}, ""},
+		{"胆拖", "双色球 胆 01 02 拖 03 04 05 06 07 蓝 09", "双色球", "", []string{"03 04 05 06 07|09|01 02 1"}, ""},
+		{"按个数推断双色球", "01,02,03,04,05,06,07", "双色球", "", []string{"01 02 03 04 05 06|07| 1"}, ""},
+		{"按个数推断大乐透", "01 02 03 04 05 + 01 02 追加", "大乐透", "", []string{"01 02 03 04 05|01 02| 1"}, ""},
+		{"排列五连写", "排列五 25100期 12345", "排列5", "25100", []string{"1 2 3 4 5|| 1"}, ""},
+		{"没有号码", "双色球 2025107期", "", "", nil, "没有找到号码"},
+		{"无法推断彩种", "01 02 03", "", "", nil, "无法判断彩种"},
+		{"复式必须写分隔", "双色球 01 02 03 04 05 06 07 08", "", "", nil, "第1注: 请用"},
+		{"排列位数不对", "排列5 1234", "", "", nil, "应为 5 位数字"},
+		{"号码超出范围", "双色球 01 02 03 04 05 34 + 07", "", "", nil, "第1注"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTypedTicket(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseTypedTicket() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != tt.game || got.Issue != tt.issue {
+				t.Errorf("type, issue = %q, %q, want %q, %q", got.Type, got.Issue, tt.game, tt.issue)
+			}
+			var rows []string
+			for _, tk := range got.Tickets {
+				rows = append(rows, fmt.Sprintf("%s|%s|%s %d", strings.Join(tk.Red, " "), strings.Join(tk.Blue, " "), strings.Join(tk.Dan, " "), tk.Multiplier))
+			}
+			if strings.Join(rows, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("tickets =\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+	if got, _ := parseTypedTicket("大乐透 01 02 03 04 05 + 01 02 追加"); !got.Tickets[0].AddOn {
+		t.Error("追加 not parsed")
+	}
+}
+
+func TestTypedVerifyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestDraws(t, DrawRecord{Game: "双色球", Issue: "2025107", Red: []string{"02", "11", "15", "21", "28", "33"}, Blue: []string{"07"}})
+	verifyCache.Purge()
+	t.Cleanup(verifyCache.Purge)
+	oldHistory := scanHistory
+	t.Cleanup(func() { scanHistory = oldHistory })
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantPrize   Fen
+		wantHistory int
+	}{
+		{"纯文本", "text/plain", "双色球 2025107期 02 11 15 21 28 33 + 07", 200, 5000000 * Yuan, 1},
+		{"JSON", "application/json", `{"text": "双色球 2025107期 01 03 04 05 06 08 + 07"}`, 200, 5 * Yuan, 1},
+		{"按提供的号码验奖不记历史", "application/json", `{"text": "双色球 01 03 04 05 06 08 + 09", "winning": {"red": ["01","03","04","05","06","08"], "blue": ["09"]}}`, 200, 5000000 * Yuan, 0},
+		{"内容为空", "text/plain", "  ", 400, 0, 0},
+		{"号码格式错误", "text/plain", "01 02 03", 400, 0, 0},
+		{"JSON 格式错误", "application/json", `{"text":`, 400, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanHistory = &historyStore{}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/verify/text", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", tt.contentType)
+			typedVerifyHandler(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == 200 {
+				var results []VerificationResult
+				if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 {
+					t.Fatalf("body = %s: %v", w.Body, err)
+				}
+				if results[0].TotalPrize != tt.wantPrize {
+					t.Errorf("TotalPrize = %s, want %s", results[0].TotalPrize, tt.wantPrize)
+				}
+			}
+			if n := len(scanHistory.records); n != tt.wantHistory {
+				t.Errorf("history = %d records, want %d", n, tt.wantHistory)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// UPLOAD: 断点续传上传（tus 风格）
+// ==========================================
+
+// 移动网络下大图上传经常中途断开，只能从头再传。客户端先创建上传会话，再分块 PATCH，
+// 断线后 HEAD 查询已收到的字节数从断点继续；传完后在 /api/v1/scan、/api/v1/ocr 的
+// 表单字段（或查询参数）upload_id 引用这次上传，代替 image 文件：
+//
+//	POST   /api/v1/uploads        Upload-Length: 3145728      → 201，Location 与 upload_id
+//	PATCH  /api/v1/uploads/:id    Upload-Offset: 0，请求体为分块 → 204，Upload-Offset 为新的偏移
+//	HEAD   /api/v1/uploads/:id                                 → Upload-Offset / Upload-Length
+//	DELETE /api/v1/uploads/:id
+//
+// 会话保存在 data/uploads，超过 UPLOAD_RETENTION（默认 24h）未完成或未使用的会话在新建会话时清理
+
+// UploadSession 一次上传的元数据，数据本身在 <id>.part
+type UploadSession struct {
+	ID        string    `json:"upload_id"`
+	Tenant    string    `json:"tenant"`
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"offset"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (u UploadSession) Complete() bool { return u.Offset == u.Length }
+
+// uploadStore 上传会话目录
+type uploadStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var uploads *uploadStore
+
+func newUploadStore(dir string) (*uploadStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &uploadStore{dir: dir}, nil
+}
+
+func (s *uploadStore) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+func (s *uploadStore) dataPath(id string) string { return filepath.Join(s.dir, id+".part") }
+
+// uploadMaxBytes 单个上传的大小上限，UPLOAD_MAX_BYTES 默认 20MB
+func uploadMaxBytes() int64 { return int64(envInt("UPLOAD_MAX_BYTES", 20<<20)) }
+
+func (s *uploadStore) Create(tenant string, length int64) (UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	u := UploadSession{
+		ID: newJobID(), Tenant: tenant, Length: length, CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(envDuration("UPLOAD_RETENTION", 24*time.Hour)),
+	}
+	if err := os.WriteFile(s.dataPath(u.ID), nil, 0o644); err != nil {
+		return u, err
+	}
+	return u, s.save(u)
+}
+
+func (s *uploadStore) save(u UploadSession) error {
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	p := s.metaPath(u.ID)
+	if err := os.WriteFile(p+".tmp", raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// Get 只返回属于该租户且未过期的会话
+func (s *uploadStore) Get(tenant, id string) (UploadSession, bool) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return UploadSession{}, false
+	}
+	raw, err := os.ReadFile(s.metaPath(id))
+	if err != nil {
+		return UploadSession{}, false
+	}
+	var u UploadSession
+	if err := json.Unmarshal(raw, &u); err != nil || u.Tenant != tenant || time.Now().After(u.ExpiresAt) {
+		return UploadSession{}, false
+	}
+	return u, true
+}
+
+// Append 在 offset 处追加一块数据；offset 与已收到的字节数不一致时返回 errUploadOffset
+func (s *uploadStore) Append(tenant, id string, offset int64, body io.Reader) (UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.Get(tenant, id)
+	if !ok {
+		return u, errUploadNotFound
+	}
+	if offset != u.Offset {
+		return u, errUploadOffset
+	}
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return u, err
+	}
+	defer f.Close()
+	// 断线时已写入的部分同样计入偏移，客户端 HEAD 后从这里继续
+	n, copyErr := io.Copy(io.NewOffsetWriter(f, u.Offset), io.LimitReader(body, u.Length-u.Offset+1))
+	u.Offset += n
+	if u.Offset > u.Length {
+		f.Truncate(u.Length)
+		u.Offset = u.Length
+		if err := s.save(u); err != nil {
+			return u, err
+		}
+		return u, errUploadTooLarge
+	}
+	if err := s.save(u); err != nil {
+		return u, err
+	}
+	return u, copyErr
+}
+
+// Bytes 已完成上传的内容
+func (s *uploadStore) Bytes(tenant, id string) ([]byte, UploadSession, error) {
+	u, ok := s.Get(tenant, id)
+	if !ok {
+		return nil, u, errUploadNotFound
+	}
+	if !u.Complete() {
+		return nil, u, fmt.Errorf("上传尚未完成（已收到 %d/%d 字节）", u.Offset, u.Length)
+	}
+	raw, err := os.ReadFile(s.dataPath(id))
+	return raw, u, err
+}
+
+func (s *uploadStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Remove(s.dataPath(id))
+	os.Remove(s.metaPath(id))
+}
+
+// prune 删除过期会话；调用方需持有锁
+func (s *uploadStore) prune(now time.Time) {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var u UploadSession
+		if json.Unmarshal(raw, &u) == nil && now.After(u.ExpiresAt) {
+			id := strings.TrimSuffix(filepath.Base(f), ".json")
+			os.Remove(s.dataPath(id))
+			os.Remove(f)
+		}
+	}
+}
+
+var (
+	errUploadNotFound = errors.New("上传不存在或已过期")
+	errUploadOffset   = errors.New("Upload-Offset 与已收到的字节数不一致")
+	errUploadTooLarge = errors.New("数据超出 Upload-Length")
+)
+
+func setUploadHeaders(c *gin.Context, u UploadSession) {
+	c.Header("Tus-Resumable", "1.0.0")
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Header("Cache-Control", "no-store")
+}
+
+// uploadCreateHandler 长度取 Upload-Length 头，或 JSON {"length": ...}
+func uploadCreateHandler(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		var req struct {
+			Length int64 `json:"length"`
+		}
+		if c.ShouldBindJSON(&req) != nil {
+			c.JSON(400, gin.H{"error": "缺少 Upload-Length"})
+			return
+		}
+		length = req.Length
+	}
+	if length <= 0 {
+		c.JSON(400, gin.H{"error": "Upload-Length 必须大于 0"})
+		return
+	}
+	if length > uploadMaxBytes() {
+		c.JSON(413, gin.H{"error": fmt.Sprintf("文件过大，最多 %d 字节", uploadMaxBytes())})
+		return
+	}
+	u, err := uploads.Create(tenantOf(c), length)
+	if err != nil {
+		log.Printf("创建上传会话失败: %v", err)
+		c.JSON(500, gin.H{"error": "创建上传会话失败"})
+		return
+	}
+	setUploadHeaders(c, u)
+	c.Header("Location", "/api/v1/uploads/"+u.ID)
+	c.JSON(201, u)
+}
+
+func uploadHeadHandler(c *gin.Context) {
+	u, ok := uploads.Get(tenantOf(c), c.Param("id"))
+	if !ok {
+		c.Status(404)
+		return
+	}
+	setUploadHeaders(c, u)
+	c.Status(200)
+}
+
+func uploadStatusHandler(c *gin.Context) {
+	u, ok := uploads.Get(tenantOf(c), c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": errUploadNotFound.Error()})
+		return
+	}
+	setUploadHeaders(c, u)
+	c.JSON(200, gin.H{"upload": u, "complete": u.Complete()})
+}
+
+func uploadPatchHandler(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{"error": "缺少 Upload-Offset"})
+		return
+	}
+	u, err := uploads.Append(tenantOf(c), c.Param("id"), offset, c.Request.Body)
+	switch {
+	case errors.Is(err, errUploadNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errUploadOffset):
+		setUploadHeaders(c, u)
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errUploadTooLarge):
+		setUploadHeaders(c, u)
+		c.JSON(413, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		// 连接中断：已写入的部分保留，下次从 Upload-Offset 继续
+		log.Printf("上传 %s 中断于 %d 字节: %v", u.ID, u.Offset, err)
+		setUploadHeaders(c, u)
+		c.JSON(400, gin.H{"error": "上传中断，请从 Upload-Offset 继续"})
+		return
+	}
+	setUploadHeaders(c, u)
+	c.Status(204)
+}
+
+func uploadDeleteHandler(c *gin.Context) {
+	if _, ok := uploads.Get(tenantOf(c), c.Param("id")); !ok {
+		c.JSON(404, gin.H{"error": errUploadNotFound.Error()})
+		return
+	}
+	uploads.Delete(c.Param("id"))
+	c.Status(204)
+}
+
+// scanImageOf 读取扫描图片：表单字段或查询参数 upload_id 引用已完成的上传，否则读 image 文件；
+// 表单尚未解析时请求体的读取也受 ctx 约束，ctx 超时返回 504。失败时已写好错误响应
+func scanImageOf(ctx context.Context, c *gin.Context) ([]byte, bool) {
+	if body := c.Request.Body; c.Request.MultipartForm == nil && body != nil {
+		c.Request.Body = ctxReadCloser{ctxReader{ctx, body}, body}
+		defer func() { c.Request.Body = body }()
+	}
+	failed := func(status int, msg string) ([]byte, bool) {
+		if err := ctx.Err(); err != nil {
+			respondStageError(c, "", &budgetError{Stage: stagePreprocess, Err: err})
+			return nil, false
+		}
+		c.JSON(status, gin.H{"error": msg})
+		return nil, false
+	}
+	id := c.PostForm("upload_id")
+	if id == "" {
+		id = c.Query("upload_id")
+	}
+	if id != "" {
+		raw, _, err := uploads.Bytes(tenantOf(c), id)
+		switch {
+		case errors.Is(err, errUploadNotFound):
+			return failed(404, err.Error())
+		case err != nil:
+			return failed(409, err.Error())
+		}
+		return raw, true
+	}
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		return failed(400, "请上传名为 'image' 的文件，或用 upload_id 引用已完成的上传")
+	}
+	defer file.Close()
+	c.Set("image_name", header.Filename)
+	fileBytes, err := io.ReadAll(ctxReader{ctx, file})
+	if err != nil {
+		return failed(400, "读取图片失败: "+err.Error())
+	}
+	return fileBytes, true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestUploads 换成临时目录里的上传会话
+func useTestUploads(t *testing.T) {
+	t.Helper()
+	old := uploads
+	t.Cleanup(func() { uploads = old })
+	var err error
+	if uploads, err = newUploadStore(filepath.Join(t.TempDir(), "uploads")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func uploadRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v1/uploads", uploadCreateHandler)
+	r.HEAD("/api/v1/uploads/:id", uploadHeadHandler)
+	r.GET("/api/v1/uploads/:id", uploadStatusHandler)
+	r.PATCH("/api/v1/uploads/:id", uploadPatchHandler)
+	r.DELETE("/api/v1/uploads/:id", uploadDeleteHandler)
+	return r
+}
+
+func TestUploadCreateHandler(t *testing.T) {
+	useTestUploads(t)
+	t.Setenv("UPLOAD_MAX_BYTES", "100")
+	r := uploadRouter()
+	tests := []struct {
+		name       string
+		header     string
+		body       string
+		wantStatus int
+	}{
+		{"Upload-Length 头", "10", "", 201},
+		{"JSON 长度", "", `{"length": 10}`, 201},
+		{"缺少长度", "", "", 400},
+		{"长度为 0", "0", "", 400},
+		{"超过上限", "101", "", 413},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/uploads", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("Upload-Length", tt.header)
+			}
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == 201 && (!strings.HasPrefix(w.Header().Get("Location"), "/api/v1/uploads/") || w.Header().Get("Upload-Offset") != "0") {
+				t.Errorf("headers = %v", w.Header())
+			}
+		})
+	}
+}
+
+// 分块上传、断点查询、偏移冲突、超长和跨租户访问，按顺序在同一个会话上执行
+func TestUploadResume(t *testing.T) {
+	useTestUploads(t)
+	r := uploadRouter()
+	u, err := uploads.Create("shop-a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/v1/uploads/" + u.ID
+
+	steps := []struct {
+		name       string
+		method     string
+		tenant     string
+		offset     string
+		body       string
+		wantStatus int
+		wantOffset string
+	}{
+		{"第一块", "PATCH", "shop-a", "0", "01234", 204, "5"},
+		{"查询断点", "HEAD", "shop-a", "", "", 200, "5"},
+		{"偏移不一致", "PATCH", "shop-a", "3", "xx", 409, "5"},
+		{"缺少偏移", "PATCH", "shop-a", "", "xx", 400, ""},
+		{"其他租户看不到", "HEAD", "shop-b", "", "", 404, ""},
+		{"其他租户不能写入", "PATCH", "shop-b", "5", "56789", 404, ""},
+		{"剩余部分", "PATCH", "shop-a", "5", "56789", 204, "10"},
+		{"超出长度", "PATCH", "shop-a", "10", "x", 413, "10"},
+		{"状态", "GET", "shop-a", "", "", 200, "10"},
+	}
+	for _, st := range steps {
+		req := httptest.NewRequest(st.method, path, strings.NewReader(st.body))
+		req.Header.Set("X-Tenant-ID", st.tenant)
+		if st.offset != "" {
+			req.Header.Set("Upload-Offset", st.offset)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != st.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", st.name, w.Code, st.wantStatus, w.Body)
+		}
+		if got := w.Header().Get("Upload-Offset"); st.wantOffset != "" && got != st.wantOffset {
+			t.Errorf("%s: Upload-Offset = %q, want %q", st.name, got, st.wantOffset)
+		}
+	}
+
+	raw, _, err := uploads.Bytes("shop-a", u.ID)
+	if err != nil || string(raw) != "0123456789" {
+		t.Errorf("Bytes() = %q, %v", raw, err)
+	}
+
+	req := httptest.NewRequest("DELETE", path, nil)
+	req.Header.Set("X-Tenant-ID", "shop-a")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if _, ok := uploads.Get("shop-a", u.ID); w.Code != 204 || ok {
+		t.Errorf("DELETE status = %d, session still exists = %v", w.Code, ok)
+	}
+}
+
+func TestUploadStore(t *testing.T) {
+	useTestUploads(t)
+	partial, _ := uploads.Create("shop-a", 10)
+	uploads.Append("shop-a", partial.ID, 0, strings.NewReader("abc"))
+
+	t.Setenv("UPLOAD_RETENTION", "1ms")
+	expired, _ := uploads.Create("shop-a", 10)
+	time.Sleep(5 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr string
+	}{
+		{"未完成", partial.ID, "上传尚未完成（已收到 3/10 字节）"},
+		{"已过期", expired.ID, errUploadNotFound.Error()},
+		{"拒绝路径穿越", "../" + partial.ID, errUploadNotFound.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := uploads.Bytes("shop-a", tt.id)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Bytes() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 新建会话时清理过期会话的文件
+	t.Setenv("UPLOAD_RETENTION", "")
+	uploads.Create("shop-a", 1)
+	if files, _ := filepath.Glob(filepath.Join(uploads.dir, expired.ID+"*")); len(files) != 0 {
+		t.Errorf("expired session not pruned: %v", files)
+	}
+}
+
+// 扫描、识别接口用 upload_id 引用已完成的上传代替 image 文件
+func TestScanImageOfUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestUploads(t)
+	done, _ := uploads.Create("shop-a", 3)
+	uploads.Append("shop-a", done.ID, 0, strings.NewReader("img"))
+	partial, _ := uploads.Create("shop-a", 3)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"已完成的上传", "?upload_id=" + done.ID, 200},
+		{"未完成", "?upload_id=" + partial.ID, 409},
+		{"不存在", "?upload_id=missing", 404},
+		{"没有图片", "", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/api/v1/scan"+tt.query, nil)
+			c.Request.Header.Set("X-Tenant-ID", "shop-a")
+			raw, ok := scanImageOf(context.Background(), c)
+			if ok != (tt.wantStatus == 200) {
+				t.Fatalf("scanImageOf() ok = %v", ok)
+			}
+			if ok {
+				if string(raw) != "img" {
+					t.Errorf("scanImageOf() = %q", raw)
+				}
+				return
+			}
+			var resp map[string]string
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != tt.wantStatus || resp["error"] == "" {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
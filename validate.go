@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==========================================
+// VALIDATE: JSON 请求体按声明的结构校验，逐字段报错
+// ==========================================
+
+// 直接 ShouldBindJSON 出错时只能告诉调用方 "json: cannot unmarshal number into Go struct field…"，
+// 看不出是第几张票第几个号码。需要校验的接口为请求体声明 jsonSchema，先按声明逐项检查，
+// 全部通过后再解码到结构体。校验失败返回 400：
+//
+//	{"error": "请求参数校验失败", "code": "VALIDATION_FAILED",
+//	 "fields": [{"field": "lotteries[0].tickets[0].red[2]", "error": "不是有效的号码"}]}
+//
+// 字段路径与请求体一致（数组下标从 0 开始），同一请求的全部错误一次返回
+
+const (
+	jsonString  = "string"
+	jsonNumber  = "number"
+	jsonInteger = "integer"
+	jsonBool    = "boolean"
+	jsonArray   = "array"
+	jsonObject  = "object"
+	jsonAny     = "any"
+)
+
+// jsonSchema 一个字段的声明；只覆盖本服务请求体用到的约束
+type jsonSchema struct {
+	Type       string
+	Required   []string               // 对象的必填字段
+	Fields     map[string]*jsonSchema // 对象的已知字段，未声明的字段忽略
+	Items      *jsonSchema            // 数组元素
+	MinItems   int
+	MaxItems   int            // 0 表示不限
+	Pattern    *regexp.Regexp // 字符串需匹配
+	PatternMsg string         // 不匹配时的提示
+	Enum       []string
+	Min        *float64 // 数值下限
+}
+
+// FieldError 一处校验错误
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func jsonTypeOf(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return jsonString
+	case bool:
+		return jsonBool
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return jsonInteger
+		}
+		return jsonNumber
+	case []interface{}:
+		return jsonArray
+	case map[string]interface{}:
+		return jsonObject
+	case nil:
+		return "null"
+	}
+	return "unknown"
+}
+
+var jsonTypeNames = map[string]string{
+	jsonString: "字符串", jsonNumber: "数字", jsonInteger: "整数", jsonBool: "布尔值", jsonArray: "数组", jsonObject: "对象",
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// validate 逐项校验，错误追加到 errs
+func (s *jsonSchema) validate(path string, v interface{}, errs *[]FieldError) {
+	if s.Type == jsonAny {
+		return
+	}
+	got := jsonTypeOf(v)
+	typeOK := got == s.Type || (s.Type == jsonNumber && got == jsonInteger)
+	if !typeOK {
+		if s.Pattern != nil && got != jsonString {
+			*errs = append(*errs, FieldError{path, s.PatternMsg})
+			return
+		}
+		*errs = append(*errs, FieldError{path, "应为" + jsonTypeNames[s.Type]})
+		return
+	}
+	switch s.Type {
+	case jsonString:
+		str := v.(string)
+		if s.Pattern != nil && !s.Pattern.MatchString(str) {
+			*errs = append(*errs, FieldError{path, s.PatternMsg})
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			*errs = append(*errs, FieldError{path, "只能是 " + strings.Join(s.Enum, "、")})
+		}
+	case jsonNumber, jsonInteger:
+		f, _ := v.(json.Number).Float64()
+		if s.Min != nil && f < *s.Min {
+			*errs = append(*errs, FieldError{path, fmt.Sprintf("不能小于 %v", *s.Min)})
+		}
+	case jsonArray:
+		list := v.([]interface{})
+		if len(list) < s.MinItems {
+			if s.MinItems == 1 {
+				*errs = append(*errs, FieldError{path, "不能为空"})
+			} else {
+				*errs = append(*errs, FieldError{path, fmt.Sprintf("至少 %d 项", s.MinItems)})
+			}
+		}
+		if s.MaxItems > 0 && len(list) > s.MaxItems {
+			*errs = append(*errs, FieldError{path, fmt.Sprintf("最多 %d 项", s.MaxItems)})
+		}
+		if s.Items != nil {
+			for i, item := range list {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case jsonObject:
+		obj := v.(map[string]interface{})
+		for _, name := range s.Required {
+			if val, ok := obj[name]; !ok || val == nil {
+				*errs = append(*errs, FieldError{joinPath(path, name), "必填"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fs, ok := s.Fields[name]
+			if !ok || obj[name] == nil {
+				continue
+			}
+			fs.validate(joinPath(path, name), obj[name], errs)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// bindValidJSON 读取请求体，按 schema 校验后解码到 dst；失败时已写出 400 响应
+func bindValidJSON(c *gin.Context, schema *jsonSchema, dst interface{}) bool {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "读取请求失败"})
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error(), "code": "VALIDATION_FAILED"})
+		return false
+	}
+	var errs []FieldError
+	schema.validate("", doc, &errs)
+	if len(errs) > 0 {
+		respondFieldErrors(c, errs)
+		return false
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		c.JSON(400, gin.H{"error": "请求格式错误: " + err.Error(), "code": "VALIDATION_FAILED"})
+		return false
+	}
+	return true
+}
+
+// respondFieldErrors 写出逐字段的校验错误，提示文案按请求语言翻译
+func respondFieldErrors(c *gin.Context, errs []FieldError) {
+	if cat := catalogOf(c); cat != nil {
+		for i := range errs {
+			errs[i].Error = cat.T(errs[i].Error)
+		}
+	}
+	c.JSON(400, gin.H{"error": "请求参数校验失败", "code": "VALIDATION_FAILED", "fields": errs})
+}
+
+// ------------------------------------------
+// 各接口的请求体声明
+// ------------------------------------------
+
+var (
+	ballNumberRe = regexp.MustCompile(`^[0-9]{1,2}$`)
+	issueRe      = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+)
+
+var ballSchema = &jsonSchema{Type: jsonString, Pattern: ballNumberRe, PatternMsg: "不是有效的号码"}
+
+func ballsSchema(minItems int) *jsonSchema {
+	return &jsonSchema{Type: jsonArray, Items: ballSchema, MinItems: minItems, MaxItems: 35}
+}
+
+var userTicketSchema = &jsonSchema{
+	Type:     jsonObject,
+	Required: []string{"red"},
+	Fields: map[string]*jsonSchema{
+		"red":         ballsSchema(1),
+		"blue":        ballsSchema(0),
+		"dan":         ballsSchema(0),
+		"blue_dan":    ballsSchema(0),
+		"multiplier":  {Type: jsonInteger, Min: floatPtr(0)},
+		"mode":        {Type: jsonString},
+		"add_on":      {Type: jsonBool},
+		"pick_method": {Type: jsonString},
+	},
+}
+
+// newLotteryDataSchema 附加玩法与主玩法结构相同，共用同一组字段声明
+func newLotteryDataSchema() *jsonSchema {
+	s := &jsonSchema{
+		Type:     jsonObject,
+		Required: []string{"type", "issue", "tickets"},
+		Fields: map[string]*jsonSchema{
+			"type":      {Type: jsonString, Pattern: regexp.MustCompile(`\S`), PatternMsg: "不能为空"},
+			"issue":     {Type: jsonString, Pattern: issueRe, PatternMsg: "不是有效的期号"},
+			"sale_time": {Type: jsonString},
+			"serial":    {Type: jsonString},
+			"station":   {Type: jsonString},
+			"amount":    {Type: jsonInteger, Min: floatPtr(0)},
+			"tickets":   {Type: jsonArray, Items: userTicketSchema, MinItems: 1},
+		},
+	}
+	// 附加玩法一般不单独打印期号，沿用主玩法的
+	section := *s
+	section.Required = []string{"type", "tickets"}
+	s.Fields["sections"] = &jsonSchema{Type: jsonArray, Items: &section}
+	return s
+}
+
+var lotteryDataSchema = newLotteryDataSchema()
+
+var lotteriesSchema = &jsonSchema{Type: jsonArray, Items: lotteryDataSchema, MinItems: 1, MaxItems: 50}
+
+// verifyRequestSchema POST /api/v1/verify
+var verifyRequestSchema = &jsonSchema{
+	Type:     jsonObject,
+	Required: []string{"lotteries"},
+	Fields:   map[string]*jsonSchema{"lotteries": lotteriesSchema, "winning": {Type: jsonAny}},
+}
+
+// reviewResolveSchema POST /api/v1/reviews/:id/resolve
+var reviewResolveSchema = &jsonSchema{
+	Type: jsonObject,
+	Fields: map[string]*jsonSchema{
+		"accept":    {Type: jsonString, Enum: []string{"primary", "secondary"}},
+		"lotteries": lotteriesSchema,
+		"reviewer":  {Type: jsonString},
+	},
+}
+
+// authTokenSchema POST /api/v1/auth/token
+var authTokenSchema = &jsonSchema{
+	Type:     jsonObject,
+	Required: []string{"user_id"},
+	Fields: map[string]*jsonSchema{
+		"user_id":     {Type: jsonString, Pattern: regexp.MustCompile(`\S`), PatternMsg: "不能为空"},
+		"device_id":   {Type: jsonString},
+		"device_name": {Type: jsonString},
+	},
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaErrors 按 schema 校验 body，返回 "字段: 错误" 列表
+func schemaErrors(t *testing.T, schema *jsonSchema, body string) []string {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	var errs []FieldError
+	schema.validate("", doc, &errs)
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Field+": "+e.Error)
+	}
+	return out
+}
+
+func TestVerifyRequestSchema(t *testing.T) {
+	const ticket = `{"red": ["02", "11", "15", "21", "28", "33"], "blue": ["07"], "multiplier": 2}`
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"合法请求", `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": [` + ticket + `]}], "winning": {"red": ["01"]}}`, nil},
+		{"未声明的字段忽略", `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": [` + ticket + `], "extra": 1}], "client": "app"}`, nil},
+		{"缺少 lotteries", `{}`, []string{"lotteries: 必填"}},
+		{"lotteries 为 null", `{"lotteries": null}`, []string{"lotteries: 必填"}},
+		{"lotteries 为空", `{"lotteries": []}`, []string{"lotteries: 不能为空"}},
+		{"请求体不是对象", `[]`, []string{": 应为对象"}},
+		{"缺少必填字段", `{"lotteries": [{"type": "双色球"}]}`, []string{"lotteries[0].issue: 必填", "lotteries[0].tickets: 必填"}},
+		{"号码写成数字", `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["02", 11, "x15"]}]}]}`,
+			[]string{"lotteries[0].tickets[0].red[1]: 不是有效的号码", "lotteries[0].tickets[0].red[2]: 不是有效的号码"}},
+		{"类型错误", `{"lotteries": [{"type": "双色球", "issue": 2025107, "amount": "10", "tickets": [{"red": ["01"], "multiplier": 1.5, "add_on": "yes"}]}]}`,
+			[]string{"lotteries[0].amount: 应为整数", "lotteries[0].issue: 不是有效的期号", "lotteries[0].tickets[0].add_on: 应为布尔值", "lotteries[0].tickets[0].multiplier: 应为整数"}},
+		{"倍数不能为负", `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": ["01"], "multiplier": -1}]}]}`,
+			[]string{"lotteries[0].tickets[0].multiplier: 不能小于 0"}},
+		{"彩种为空白", `{"lotteries": [{"type": " ", "issue": "2025107", "tickets": [` + ticket + `]}]}`, []string{"lotteries[0].type: 不能为空"}},
+		{"附加玩法沿用主玩法期号", `{"lotteries": [{"type": "七星彩", "issue": "25107", "tickets": [` + ticket + `], "sections": [{"type": "生肖乐", "tickets": [{"red": ["05"]}]}]}]}`, nil},
+		{"附加玩法的号码", `{"lotteries": [{"type": "七星彩", "issue": "25107", "tickets": [` + ticket + `], "sections": [{"type": "生肖乐", "tickets": [{"red": ["牛"]}]}]}]}`,
+			[]string{"lotteries[0].sections[0].tickets[0].red[0]: 不是有效的号码"}},
+		{"号码过多", `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": [{"red": [` + strings.Repeat(`"01",`, 35) + `"01"]}]}]}`,
+			[]string{"lotteries[0].tickets[0].red: 最多 35 项"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schemaErrors(t, verifyRequestSchema, tt.body)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewResolveAndAuthSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *jsonSchema
+		body   string
+		want   []string
+	}{
+		{"采纳主结果", reviewResolveSchema, `{"accept": "primary", "reviewer": "张三"}`, nil},
+		{"采纳值不对", reviewResolveSchema, `{"accept": "both"}`, []string{"accept: 只能是 primary、secondary"}},
+		{"修正后的票面", reviewResolveSchema, `{"lotteries": [{"type": "双色球", "issue": "2025107", "tickets": []}]}`, []string{"lotteries[0].tickets: 不能为空"}},
+		{"签发令牌", authTokenSchema, `{"user_id": "u1", "device_id": "k1"}`, nil},
+		{"缺少用户标识", authTokenSchema, `{"device_id": "k1"}`, []string{"user_id: 必填"}},
+		{"用户标识为空白", authTokenSchema, `{"user_id": "  "}`, []string{"user_id: 不能为空"}},
+		{"用户标识类型错误", authTokenSchema, `{"user_id": 42}`, []string{"user_id: 不能为空"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schemaErrors(t, tt.schema, tt.body)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindValidJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestCatalogs(t, t.TempDir())
+	r := gin.New()
+	r.Use(localeMiddleware())
+	r.POST("/api/v1/auth/token", func(c *gin.Context) {
+		var req struct {
+			UserID string `json:"user_id"`
+		}
+		if !bindValidJSON(c, authTokenSchema, &req) {
+			return
+		}
+		c.JSON(200, gin.H{"user_id": req.UserID})
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		accept     string
+		wantStatus int
+		wantError  string
+		wantFields []FieldError
+	}{
+		{"通过后解码", `{"user_id": "u1"}`, "", 200, "", nil},
+		{"逐字段报错", `{"device_id": 1}`, "", 400, "请求参数校验失败", []FieldError{{"user_id", "必填"}, {"device_id", "应为字符串"}}},
+		{"按请求语言翻译", `{}`, "en", 400, "Request validation failed", []FieldError{{"user_id", "is required"}}},
+		{"JSON 格式错误", `{"user_id": `, "", 400, "请求格式错误", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/auth/token", bytes.NewBufferString(tt.body))
+			if tt.accept != "" {
+				req.Header.Set("Accept-Language", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var resp struct {
+				Error  string       `json:"error"`
+				Code   string       `json:"code"`
+				Fields []FieldError `json:"fields"`
+				UserID string       `json:"user_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code == 200 {
+				if resp.UserID != "u1" {
+					t.Errorf("user_id = %q", resp.UserID)
+				}
+				return
+			}
+			if resp.Code != "VALIDATION_FAILED" || !strings.HasPrefix(resp.Error, tt.wantError) {
+				t.Errorf("error = %q code = %q, want %q", resp.Error, resp.Code, tt.wantError)
+			}
+			if fmt.Sprint(resp.Fields) != fmt.Sprint(tt.wantFields) {
+				t.Errorf("fields = %+v, want %+v", resp.Fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// WATCH: 目录监听模式（扫描仪输出目录自动验奖）
+// ==========================================
+
+// WatchResult 每张图片旁边写出的 JSON 结果
+type WatchResult struct {
+	File          string               `json:"file"`
+	ProcessedAt   time.Time            `json:"processed_at"`
+	Results       []VerificationResult `json:"results,omitempty"`
+	TotalPrize    int64                `json:"total_prize"` // 元
+	TotalPrizeFen Fen                  `json:"total_prize_fen"`
+	JobID         string               `json:"job_id,omitempty"`    // AI 服务熔断时转入排队任务
+	ReviewID      string               `json:"review_id,omitempty"` // 高额票据转人工复核
+	Error         string               `json:"error,omitempty"`
+}
+
+// dirWatcher 定时轮询目录：文件大小和修改时间连续两轮不变才处理，避免读到扫描仪写了一半的文件。
+// 处理完的图片连同同名 .json 结果移入 done/，失败的移入 failed/，当天的汇总报告写到 reports/
+type dirWatcher struct {
+	dir    string
+	apiKey string
+	origin ScanOrigin
+	seen   map[string]os.FileInfo
+}
+
+// runDirWatch -watch=<dir> 或 WATCH_DIR 开启；WATCH_TENANT 指定结果归属的租户
+func runDirWatch(dir, apiKey string) {
+	for _, sub := range []string{"done", "failed", "reports"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			log.Printf("目录监听启动失败: %v", err)
+			return
+		}
+	}
+	tenant := os.Getenv("WATCH_TENANT")
+	if tenant == "" {
+		tenant = "default"
+	}
+	w := &dirWatcher{
+		dir: dir, apiKey: apiKey,
+		origin: ScanOrigin{Tenant: tenant, DeviceID: "watch:" + filepath.Base(dir)},
+		seen:   map[string]os.FileInfo{},
+	}
+	log.Printf("开始监听目录 %s", dir)
+	ticker := time.NewTicker(envDuration("WATCH_POLL_INTERVAL", 2*time.Second))
+	defer ticker.Stop()
+	for range ticker.C {
+		w.poll()
+	}
+}
+
+func (w *dirWatcher) poll() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		log.Printf("读取监听目录失败: %v", err)
+		return
+	}
+	current := map[string]os.FileInfo{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !zipImageExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+		current[name] = info
+		prev, ok := w.seen[name]
+		if !ok || prev.Size() != info.Size() || !prev.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+		// 熔断期间且没开排队时先不处理，免得每轮都记一次识别失败
+		if ocrBreaker.State() == breakerOpen && !queueOnOutage() {
+			continue
+		}
+		if w.process(name) {
+			delete(current, name)
+		}
+	}
+	w.seen = current
+}
+
+// process 处理一张图片，返回 false 表示留在原处下一轮重试（AI 服务熔断且未开启排队）
+func (w *dirWatcher) process(name string) bool {
+	src := filepath.Join(w.dir, name)
+	out := WatchResult{File: name, ProcessedAt: time.Now()}
+	fileBytes, err := os.ReadFile(src)
+	if err != nil {
+		out.Error = "读取文件失败: " + err.Error()
+		w.finish(src, "failed", out)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("WATCH_SCAN_TIMEOUT", 2*time.Minute))
+	results, job, err := runScan(withImageName(ctx, name), w.origin, fileBytes, w.apiKey)
+	cancel()
+	rr, review := asReviewRequired(err)
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		return false
+	case review:
+		out.ReviewID = rr.ID
+		w.finish(src, "done", out)
+	case err != nil:
+		out.Error = err.Error()
+		w.finish(src, "failed", out)
+	case job != nil:
+		out.JobID = job.ID
+		w.finish(src, "done", out)
+	default:
+		out.Results = results
+		for _, r := range results {
+			out.TotalPrizeFen += r.TotalPrize
+		}
+		out.TotalPrize = out.TotalPrizeFen.Yuan()
+		w.finish(src, "done", out)
+	}
+	return true
+}
+
+// finish 把图片移入子目录并在旁边写出结果；重名时加时间戳
+func (w *dirWatcher) finish(src, sub string, out WatchResult) {
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	ext := filepath.Ext(src)
+	dst := filepath.Join(w.dir, sub, base+ext)
+	if _, err := os.Stat(dst); err == nil {
+		base = fmt.Sprintf("%s_%s", base, out.ProcessedAt.Format("20060102150405"))
+		dst = filepath.Join(w.dir, sub, base+ext)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		log.Printf("移动文件 %s 失败: %v", src, err)
+		return
+	}
+	raw, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(w.dir, sub, base+".json"), raw, 0o644); err != nil {
+		log.Printf("写出结果 %s 失败: %v", base, err)
+		return
+	}
+	if out.Error != "" {
+		log.Printf("目录监听: %s 处理失败: %s", filepath.Base(src), out.Error)
+	} else {
+		log.Printf("目录监听: %s 处理完成，中奖 %s", filepath.Base(src), out.TotalPrizeFen)
+	}
+	if err := w.writeReport(out.ProcessedAt); err != nil {
+		log.Printf("目录监听: 更新汇总报告失败: %v", err)
+	}
+}
+
+// writeReport 按 done/ 和 failed/ 里当天的结果重新生成 reports/<日期>.json 与 .html；
+// 转入排队的图片从任务队列取最新状态，日终再处理一张图片或重启后报告即是最终结果
+func (w *dirWatcher) writeReport(day time.Time) error {
+	date := day.Format("2006-01-02")
+	var results []WatchResult
+	for _, sub := range []string{"done", "failed"} {
+		files, _ := filepath.Glob(filepath.Join(w.dir, sub, "*.json"))
+		for _, f := range files {
+			raw, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			var r WatchResult
+			if json.Unmarshal(raw, &r) != nil || r.ProcessedAt.Format("2006-01-02") != date {
+				continue
+			}
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ProcessedAt.Before(results[j].ProcessedAt) })
+
+	items := make([]BatchItem, 0, len(results))
+	for i, r := range results {
+		item := BatchItem{ImageIndex: i + 1, FileName: r.File, Status: JobDone, Results: r.Results, JobID: r.JobID, ReviewID: r.ReviewID, Error: r.Error}
+		switch {
+		case r.Error != "":
+			item.Status = JobFailed
+		case r.ReviewID != "":
+			item.Status = JobReview
+		case r.JobID != "":
+			item.Status = JobQueued
+			if job, ok := jobQueue.Get(r.JobID); ok {
+				item.Status, item.Results, item.Error = job.Status, job.Results, job.Error
+			}
+		}
+		items = append(items, item)
+	}
+	report := buildBatchReport("watch", "批量验奖汇总报告", items)
+	report.BatchID = date
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	page, err := renderReportHTML(nil, report)
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(w.dir, "reports", date)
+	if err := os.WriteFile(base+".json", raw, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(base+".html", page, 0o644)
+}
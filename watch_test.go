@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T) *dirWatcher {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range []string{"done", "failed", "reports"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &dirWatcher{dir: dir, seen: map[string]os.FileInfo{}}
+}
+
+func TestDirWatcherPoll(t *testing.T) {
+	w := newTestWatcher(t)
+	writeTestFile(t, w.dir, "a.jpg", []byte{1, 2})
+	writeTestFile(t, w.dir, "b.PNG", []byte{1})
+	writeTestFile(t, w.dir, "notes.txt", []byte{1})
+	writeTestFile(t, w.dir, ".partial.jpg", []byte{1})
+	writeTestFile(t, w.dir, "empty.jpg", nil)
+	w.poll()
+
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{"图片记下等下一轮", "a.jpg", true},
+		{"扩展名不分大小写", "b.PNG", true},
+		{"不是图片", "notes.txt", false},
+		{"隐藏文件", ".partial.jpg", false},
+		{"空文件", "empty.jpg", false},
+		{"子目录", "done", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := w.seen[tt.file]; ok != tt.want {
+				t.Errorf("seen[%s] = %v, want %v", tt.file, ok, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(w.dir, tt.file)); err != nil {
+				t.Errorf("%s should stay in place: %v", tt.file, err)
+			}
+		})
+	}
+
+	// 还在写入的文件大小变了，这一轮仍不处理
+	writeTestFile(t, w.dir, "a.jpg", []byte{1, 2, 3})
+	os.Remove(filepath.Join(w.dir, "b.PNG"))
+	w.poll()
+	if info, ok := w.seen["a.jpg"]; !ok || info.Size() != 3 {
+		t.Errorf("seen[a.jpg] = %v, want size 3", info)
+	}
+	if _, ok := w.seen["b.PNG"]; ok {
+		t.Error("removed file should be forgotten")
+	}
+	if _, err := os.Stat(filepath.Join(w.dir, "a.jpg")); err != nil {
+		t.Errorf("changed file should stay in place: %v", err)
+	}
+}
+
+func TestDirWatcherFinish(t *testing.T) {
+	useTestQueue(t)
+	job, err := jobQueue.Enqueue([]byte{1}, ScanOrigin{Tenant: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2025, 9, 16, 21, 0, 0, 0, chinaTime)
+	w := newTestWatcher(t)
+	// 前一天的结果不进今天的报告
+	writeTestFile(t, w.dir, "done/old.json", []byte(`{"file":"old.jpg","processed_at":"2025-09-15T10:00:00+08:00"}`))
+
+	tests := []struct {
+		name     string
+		file     string
+		sub      string
+		out      WatchResult
+		wantBase string
+	}{
+		{"验奖完成", "a.jpg", "done", WatchResult{TotalPrizeFen: 5 * Yuan, TotalPrize: 5}, "a"},
+		{"识别失败", "b.jpg", "failed", WatchResult{Error: "AI 识别失败"}, "b"},
+		{"转人工复核", "c.jpg", "done", WatchResult{ReviewID: "r1"}, "c"},
+		{"转入排队", "d.jpg", "done", WatchResult{JobID: job.ID}, "d"},
+		{"重名加时间戳", "a.jpg", "done", WatchResult{}, "a_20250916210400"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := writeTestFile(t, w.dir, tt.file, []byte{1})
+			tt.out.File = tt.file
+			tt.out.ProcessedAt = day.Add(time.Duration(i) * time.Minute)
+			w.finish(src, tt.sub, tt.out)
+
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("source still exists: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(w.dir, tt.sub, tt.wantBase+".jpg")); err != nil {
+				t.Errorf("image not moved: %v", err)
+			}
+			raw, err := os.ReadFile(filepath.Join(w.dir, tt.sub, tt.wantBase+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got WatchResult
+			json.Unmarshal(raw, &got)
+			if got.File != tt.file || got.Error != tt.out.Error || got.JobID != tt.out.JobID || got.ReviewID != tt.out.ReviewID {
+				t.Errorf("result = %+v, want %+v", got, tt.out)
+			}
+		})
+	}
+
+	raw, err := os.ReadFile(filepath.Join(w.dir, "reports", "2025-09-16.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(w.dir, "reports", "2025-09-16.html")); err != nil {
+		t.Errorf("html report missing: %v", err)
+	}
+	var report BatchReport
+	json.Unmarshal(raw, &report)
+	if report.BatchID != "2025-09-16" || report.Images != 5 || report.Complete {
+		t.Fatalf("report = %s", raw)
+	}
+	want := []struct{ file, kind string }{{"b.jpg", "failed"}, {"c.jpg", "review"}, {"d.jpg", "queued"}}
+	if len(report.Exceptions) != len(want) {
+		t.Fatalf("exceptions = %+v, want %v", report.Exceptions, want)
+	}
+	for i, e := range report.Exceptions {
+		if e.FileName != want[i].file || e.Kind != want[i].kind {
+			t.Errorf("exceptions[%d] = %s/%s, want %s/%s", i, e.FileName, e.Kind, want[i].file, want[i].kind)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// WORKER: API 与排队任务处理分开部署
+// ==========================================
+
+// 默认一个进程既处理请求也处理排队任务。流量大时可以用 -role（或 SERVICE_ROLE）拆开：
+//
+//	all     默认，两者都做
+//	api     只处理请求，排队任务交给 worker
+//	worker  只处理排队任务，不监听端口，也不运行开奖同步、兑奖提醒、目录监听等后台任务
+//
+// worker 与 API 进程需要共用任务存储（同一个 data/jobs 目录或同一个 Redis，见 jobstore.go）。
+// 开奖数据由 API 进程同步，worker 定期从 data/draws.json 重新读取（WORKER_DRAWS_RELOAD，默认 1m）
+
+const (
+	serviceRoleAll    = "all"
+	serviceRoleAPI    = "api"
+	serviceRoleWorker = "worker"
+)
+
+func parseServiceRole(s string) (string, error) {
+	switch role := strings.ToLower(strings.TrimSpace(s)); role {
+	case "":
+		return serviceRoleAll, nil
+	case serviceRoleAll, serviceRoleAPI, serviceRoleWorker:
+		return role, nil
+	}
+	return "", fmt.Errorf("未知的进程角色 %q，应为 all、api 或 worker", s)
+}
+
+// runWorker worker 角色的主循环，不返回
+func runWorker(apiKey string) {
+	go func() {
+		interval := envDuration("WORKER_DRAWS_RELOAD", time.Minute)
+		for range time.Tick(interval) {
+			if err := draws.Load(filepath.Join(dataDir(), "draws.json")); err != nil {
+				log.Printf("重新读取开奖数据失败: %v", err)
+			}
+		}
+	}()
+	log.Printf("以 worker 角色启动，只处理排队任务")
+	jobQueue.Run(apiKey)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestParseServiceRole(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", serviceRoleAll, false},
+		{"all", serviceRoleAll, false},
+		{" API ", serviceRoleAPI, false},
+		{"worker", serviceRoleWorker, false},
+		{"scheduler", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseServiceRole(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseServiceRole(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}